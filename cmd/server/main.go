@@ -17,19 +17,25 @@ import (
 
 	"github.com/joho/godotenv"
 	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	jwtaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/jwt_access"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cloudconfig"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cmd"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/errorreport"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/notify"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/store"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
-	log "github.com/sirupsen/logrus"
 )
 
 var (
@@ -62,9 +68,23 @@ func main() {
 	var iflowCookie bool
 	var noBrowser bool
 	var oauthCallbackPort int
+	var callbackHost string
+	var callbackTimeout time.Duration
 	var antigravityLogin bool
 	var projectID string
 	var vertexImport string
+	var setProject string
+	var encryptAuth bool
+	var authExport string
+	var authImport string
+	var passphraseEnv string
+	var bundleConfig bool
+	var printConfig bool
+	var validateConfig bool
+	var usageExport string
+	var usageExportFormat string
+	var usageFrom string
+	var usageTo string
 	var configPath string
 	var password string
 
@@ -76,13 +96,33 @@ func main() {
 	flag.BoolVar(&iflowLogin, "iflow-login", false, "Login to iFlow using OAuth")
 	flag.BoolVar(&iflowCookie, "iflow-cookie", false, "Login to iFlow using Cookie")
 	flag.BoolVar(&noBrowser, "no-browser", false, "Don't open browser automatically for OAuth")
-	flag.IntVar(&oauthCallbackPort, "oauth-callback-port", 0, "Override OAuth callback port (defaults to provider-specific port)")
+	flag.IntVar(&oauthCallbackPort, "oauth-callback-port", -1, "Override OAuth callback port for logins (defaults to provider-specific port; 0 picks a free port where the provider allows it)")
+	flag.StringVar(&callbackHost, "callback-host", "", "Interface the OAuth callback server binds to (defaults to all interfaces)")
+	flag.DurationVar(&callbackTimeout, "callback-timeout", 0, "How long logins wait for the OAuth callback (default 5m)")
 	flag.BoolVar(&antigravityLogin, "antigravity-login", false, "Login to Antigravity using OAuth")
 	flag.StringVar(&projectID, "project_id", "", "Project ID (Gemini only, not required)")
 	flag.StringVar(&configPath, "config", DefaultConfigPath, "Configure File Path")
 	flag.StringVar(&vertexImport, "vertex-import", "", "Import Vertex service account key JSON file")
+	flag.BoolVar(&encryptAuth, "encrypt-auth", false, "Encrypt plaintext auth files in place using CLIPROXY_AUTH_KEY")
+	flag.StringVar(&setProject, "set-project", "", "Change the Gemini project of an existing auth file (use with -project_id)")
+	flag.StringVar(&authExport, "auth-export", "", "Export all auth files into a passphrase-encrypted bundle at this path")
+	flag.StringVar(&authImport, "auth-import", "", "Import auth files from a bundle created with -auth-export")
+	flag.StringVar(&passphraseEnv, "passphrase-env", "", "Environment variable holding the bundle passphrase (for -auth-export/-auth-import)")
+	flag.BoolVar(&bundleConfig, "bundle-config", false, "Include the config file in the bundle written by -auth-export")
+	flag.BoolVar(&printConfig, "print-config", false, "Print the effective configuration, with overrides applied and file secrets shown as references, and exit")
+	flag.BoolVar(&validateConfig, "validate-config", false, "Check the config file, its auth directories and the listen port, print each problem with its line, and exit non-zero on errors")
+	flag.StringVar(&usageExport, "usage-export", "", "Export the persisted usage history as a table at this path (\"-\" for stdout) and exit")
+	flag.StringVar(&usageExportFormat, "usage-export-format", "csv", "Format of -usage-export (csv)")
+	flag.StringVar(&usageFrom, "usage-from", "", "First day (2006-01-02) or RFC 3339 time exported by -usage-export")
+	flag.StringVar(&usageTo, "usage-to", "", "Last day (2006-01-02) or RFC 3339 time exported by -usage-export")
 	flag.StringVar(&password, "password", "", "")
 
+	// Config field overrides; precedence is flag > CLIPROXY_* env > config file.
+	overrideFields := config.OverrideFields()
+	for _, field := range overrideFields {
+		flag.String(field.Key, "", fmt.Sprintf("%s (env %s)", field.Usage, field.Env))
+	}
+
 	flag.CommandLine.Usage = func() {
 		out := flag.CommandLine.Output()
 		_, _ = fmt.Fprintf(out, "Usage of %s\n", os.Args[0])
@@ -162,6 +202,31 @@ func main() {
 		}
 		return "", false
 	}
+
+	flagOverrides := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		for _, field := range overrideFields {
+			if field.Key == f.Name {
+				flagOverrides[f.Name] = f.Value.String()
+			}
+		}
+	})
+	overrides, errOverrides := config.ResolveOverrides(flagOverrides, os.LookupEnv)
+	if errOverrides != nil {
+		log.Errorf("invalid config override: %v", errOverrides)
+		return
+	}
+	config.SetOverrides(overrides)
+
+	// Validation reads the local file only and never waits for cloud deploy configuration.
+	if validateConfig {
+		validatePath := configPath
+		if validatePath == "" {
+			validatePath = filepath.Join(wd, "config.yaml")
+		}
+		os.Exit(cmd.DoValidateConfig(validatePath))
+	}
+
 	writableBase := util.WritablePath()
 	if value, ok := lookupEnv("PGSTORE_DSN", "pgstore_dsn"); ok {
 		usePostgresStore = true
@@ -220,6 +285,37 @@ func main() {
 		isCloudDeploy = true
 	}
 
+	// In cloud deploy mode the configuration may live in an object store; fetch it into the
+	// local config path before loading, standing by until that succeeds.
+	var cloudConfig *cloudconfig.Source
+	if value, ok := lookupEnv("CLIPROXY_CONFIG_URL", "cliproxy_config_url"); ok && isCloudDeploy {
+		if usePostgresStore || useGitStore || useObjectStore {
+			log.Warn("CLIPROXY_CONFIG_URL is ignored because a remote token store provides the configuration")
+		} else {
+			cloudConfig, err = cloudconfig.New(value)
+			if err != nil {
+				log.Errorf("failed to configure cloud config source: %v", err)
+				return
+			}
+			cachePath := configPath
+			if cachePath == "" {
+				cachePath = filepath.Join(wd, "config.yaml")
+			}
+			if !cmd.WaitForCloudConfig(cloudConfig, cachePath) {
+				return
+			}
+		}
+	} else if isCloudDeploy && !usePostgresStore && !useGitStore && !useObjectStore && authImport == "" {
+		// Without a remote source, stand by until a configuration is written to the local path.
+		localPath := configPath
+		if localPath == "" {
+			localPath = filepath.Join(wd, "config.yaml")
+		}
+		if !cmd.WaitForConfigFile(localPath) {
+			return
+		}
+	}
+
 	// Determine and load the configuration file.
 	// Prefer the Postgres store when configured, otherwise fallback to git or local files.
 	var configFilePath string
@@ -366,7 +462,8 @@ func main() {
 		}
 	} else if configPath != "" {
 		configFilePath = configPath
-		cfg, err = config.LoadConfigOptional(configPath, isCloudDeploy)
+		// A bundle import may carry the config for a fresh machine, so a missing file is fine then.
+		cfg, err = config.LoadConfigOptional(configPath, isCloudDeploy || authImport != "")
 	} else {
 		wd, err = os.Getwd()
 		if err != nil {
@@ -374,7 +471,7 @@ func main() {
 			return
 		}
 		configFilePath = filepath.Join(wd, "config.yaml")
-		cfg, err = config.LoadConfigOptional(configFilePath, isCloudDeploy)
+		cfg, err = config.LoadConfigOptional(configFilePath, isCloudDeploy || authImport != "")
 	}
 	if err != nil {
 		log.Errorf("failed to load config: %v", err)
@@ -405,17 +502,34 @@ func main() {
 		}
 	}
 	usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
+	usage.SetStatisticsMaxKeys(cfg.EffectiveUsageStatisticsMaxKeys())
 	coreauth.SetQuotaCooldownDisabled(cfg.DisableCooling)
+	util.ConfigureTransports(cfg.HTTPTransport)
+	registry.UpstreamModelCache().SetTTL(cfg.EffectiveModelCacheTTL())
 
 	if err = logging.ConfigureLogOutput(cfg); err != nil {
 		log.Errorf("failed to configure log output: %v", err)
 		return
 	}
+	logging.ConfigureLogSampling(cfg.LogSampling)
+	if err = logging.ConfigureAuditLog(cfg); err != nil {
+		log.Errorf("failed to configure audit log, audit logging is disabled: %v", err)
+	}
+	if err = tracing.Configure(cfg.Tracing); err != nil {
+		log.Errorf("failed to configure tracing: %v", err)
+	}
+	if err = errorreport.Configure(cfg.Sentry); err != nil {
+		log.Errorf("failed to configure error reporting: %v", err)
+	}
+	notify.Configure(cfg.Notifications)
 
 	log.Infof("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
 
 	// Set the log level based on the configuration.
 	util.SetLogLevel(cfg)
+	for _, source := range cfg.ValueSources() {
+		log.Debugf("effective config: %s", source)
+	}
 
 	if resolvedAuthDir, errResolveAuthDir := util.ResolveAuthDir(cfg.AuthDir); errResolveAuthDir != nil {
 		log.Errorf("failed to resolve auth directory: %v", errResolveAuthDir)
@@ -427,8 +541,15 @@ func main() {
 
 	// Create login options to be used in authentication flows.
 	options := &cmd.LoginOptions{
-		NoBrowser:    noBrowser,
-		CallbackPort: oauthCallbackPort,
+		NoBrowser:       noBrowser,
+		CallbackHost:    callbackHost,
+		CallbackTimeout: callbackTimeout,
+	}
+	switch {
+	case oauthCallbackPort == 0:
+		options.CallbackPort = sdkAuth.CallbackPortAuto
+	case oauthCallbackPort > 0:
+		options.CallbackPort = oauthCallbackPort
 	}
 
 	// Register the shared token store once so all components use the same persistence backend.
@@ -444,12 +565,37 @@ func main() {
 
 	// Register built-in access providers before constructing services.
 	configaccess.Register()
+	jwtaccess.Register()
 
 	// Handle different command modes based on the provided flags.
 
-	if vertexImport != "" {
+	if printConfig {
+		cmd.DoPrintEffectiveConfig(cfg)
+	} else if vertexImport != "" {
 		// Handle Vertex service account import
 		cmd.DoVertexImport(cfg, vertexImport)
+	} else if encryptAuth {
+		// Handle one-shot encryption of existing auth files
+		if usePostgresStore || useObjectStore || useGitStore {
+			log.Error("encrypt-auth only applies to the local file token store")
+		} else {
+			cmd.DoEncryptAuthFiles(cfg)
+		}
+	} else if authExport != "" || authImport != "" {
+		// Handle auth bundle export/import for machine migration
+		if usePostgresStore || useObjectStore || useGitStore {
+			log.Error("auth-export and auth-import only apply to the local file token store")
+		} else if authExport != "" {
+			cmd.DoExportAuthBundle(cfg, configFilePath, authExport, passphraseEnv, bundleConfig)
+		} else {
+			cmd.DoImportAuthBundle(cfg, configFilePath, authImport, passphraseEnv)
+		}
+	} else if usageExport != "" {
+		// Handle export of the persisted usage history
+		cmd.DoExportUsage(cfg, usageExport, usageExportFormat, usageFrom, usageTo)
+	} else if setProject != "" {
+		// Handle Gemini project override for an existing auth file
+		cmd.DoSetGeminiProject(cfg, setProject, projectID)
 	} else if login {
 		// Handle Google/Gemini login
 		cmd.DoLogin(cfg, projectID, options)
@@ -469,7 +615,8 @@ func main() {
 	} else if iflowCookie {
 		cmd.DoIFlowCookieAuth(cfg, options)
 	} else {
-		// In cloud deploy mode without config file, just wait for shutdown signals
+		// A remote token store in cloud deploy mode may still provide no usable config; wait
+		// for shutdown signals then.
 		if isCloudDeploy && !configFileExists {
 			// No config file available, just wait for shutdown
 			cmd.WaitForCloudDeploy()
@@ -477,6 +624,17 @@ func main() {
 		}
 		// Start the main proxy service
 		managementasset.StartAutoUpdater(context.Background(), configFilePath)
+		if cloudConfig != nil {
+			refresh := cloudconfig.DefaultRefreshInterval
+			if value, ok := lookupEnv("CLIPROXY_CONFIG_REFRESH_INTERVAL", "cliproxy_config_refresh_interval"); ok {
+				if parsed, errParse := time.ParseDuration(value); errParse == nil && parsed > 0 {
+					refresh = parsed
+				} else {
+					log.Warnf("invalid CLIPROXY_CONFIG_REFRESH_INTERVAL %q; using %s", value, refresh)
+				}
+			}
+			go cloudConfig.Run(context.Background(), configFilePath, refresh)
+		}
 		cmd.StartService(cfg, configFilePath, password)
 	}
 }
@@ -0,0 +1,222 @@
+package jwtaccess
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+const (
+	// jwksFetchTimeout bounds one download of the key set.
+	jwksFetchTimeout = 10 * time.Second
+	// jwksMinRefetch keeps tokens with unknown key ids from hammering the key endpoint.
+	jwksMinRefetch = time.Minute
+	// jwksMaxBytes caps the size of a key set document.
+	jwksMaxBytes = 1 << 20
+)
+
+// signingKey is one usable key of a JWKS.
+type signingKey struct {
+	id  string
+	alg string
+	key crypto.PublicKey
+}
+
+// keySet downloads and caches the keys published at a JWKS URL. The set is fetched again
+// once it is older than refresh, or when a token names a key id it does not hold; a failed
+// fetch keeps the previous keys.
+type keySet struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu        sync.Mutex
+	keys      []signingKey
+	fetchedAt time.Time
+	triedAt   time.Time
+	// fetching is closed when the fetch in progress, if any, completes.
+	fetching chan struct{}
+}
+
+func newKeySet(url string, refresh time.Duration) *keySet {
+	return &keySet{url: url, refresh: refresh, client: &http.Client{Timeout: jwksFetchTimeout}}
+}
+
+// candidates returns the keys that may have signed a token with header kid and alg. The key
+// set is fetched without holding the lock; callers that hold no matching key meanwhile wait
+// for that fetch instead of starting their own.
+func (s *keySet) candidates(kid, alg string) []signingKey {
+	s.mu.Lock()
+	now := time.Now()
+	stale := s.fetchedAt.IsZero() || (s.refresh > 0 && now.Sub(s.fetchedAt) > s.refresh)
+	matches := matchingKeys(s.keys, kid, alg)
+	if !stale && len(matches) > 0 {
+		s.mu.Unlock()
+		return matches
+	}
+	if done := s.fetching; done != nil {
+		s.mu.Unlock()
+		if len(matches) > 0 {
+			return matches
+		}
+		<-done
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return matchingKeys(s.keys, kid, alg)
+	}
+	if now.Sub(s.triedAt) < jwksMinRefetch {
+		s.mu.Unlock()
+		return matches
+	}
+	s.triedAt = now
+	done := make(chan struct{})
+	s.fetching = done
+	s.mu.Unlock()
+
+	keys, err := s.fetch()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetching = nil
+	close(done)
+	if err != nil {
+		log.Warnf("jwt auth: fetching %s failed: %v", s.url, err)
+		return matches
+	}
+	s.keys, s.fetchedAt = keys, now
+	return matchingKeys(s.keys, kid, alg)
+}
+
+func matchingKeys(keys []signingKey, kid, alg string) []signingKey {
+	var out []signingKey
+	for _, key := range keys {
+		if kid != "" && key.id != kid {
+			continue
+		}
+		if key.alg != "" && key.alg != alg {
+			continue
+		}
+		out = append(out, key)
+	}
+	return out
+}
+
+func (s *keySet) fetch() ([]signingKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, jwksMaxBytes))
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(body)
+}
+
+// jwk holds the members of a JSON Web Key the verifier uses.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS returns the signature keys of a JWKS document. Keys of unsupported types are
+// skipped; a document without any usable key is an error.
+func parseJWKS(data []byte) ([]signingKey, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	var keys []signingKey
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			log.Debugf("jwt auth: skipping key %q: %v", k.Kid, err)
+			continue
+		}
+		keys = append(keys, signingKey{id: k.Kid, alg: k.Alg, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS holds no usable signature key")
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, errN := decode(k.N)
+		e, errE := decode(k.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, errX := decode(k.X)
+		y, errY := decode(k.Y)
+		if errX != nil || errY != nil {
+			return nil, errors.New("invalid EC key")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return key, nil
+	case "OKP":
+		x, err := decode(k.X)
+		if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
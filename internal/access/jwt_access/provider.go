@@ -0,0 +1,249 @@
+// Package jwtaccess authenticates clients by JWT bearer tokens, such as service tokens issued
+// by an SSO provider, verified against the keys published at a JWKS URL.
+package jwtaccess
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+var registerOnce sync.Once
+
+// Register makes the jwt access provider available to the access manager.
+func Register() {
+	registerOnce.Do(func() {
+		sdkaccess.RegisterProvider(sdkconfig.AccessProviderTypeJWT, newProvider)
+	})
+}
+
+type provider struct {
+	name string
+	opts config.JWTProviderOptions
+	keys *keySet
+	now  func() time.Time
+}
+
+func newProvider(cfg *sdkconfig.AccessProvider, _ *sdkconfig.SDKConfig) (sdkaccess.Provider, error) {
+	opts, err := config.ParseJWTProviderOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	name := cfg.Name
+	if name == "" {
+		name = sdkconfig.AccessProviderTypeJWT
+	}
+	return &provider{name: name, opts: opts, keys: newKeySet(opts.JWKSURL, opts.JWKSRefresh), now: time.Now}, nil
+}
+
+func (p *provider) Identifier() string {
+	if p == nil || p.name == "" {
+		return sdkconfig.AccessProviderTypeJWT
+	}
+	return p.name
+}
+
+// Authenticate verifies the JWT the request carries where clients put their API key. Requests
+// without anything shaped like a JWT are left to the other providers.
+func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.Result, error) {
+	if p == nil {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	token, source := bearerJWT(r)
+	if token == "" {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	claims, err := p.verify(token)
+	if err != nil {
+		log.Debugf("jwt auth: %s: rejected token from %s: %v", p.Identifier(), source, err)
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	principal := claimString(claims[p.opts.PrincipalClaim])
+	if principal == "" {
+		log.Debugf("jwt auth: %s: token has no %s claim", p.Identifier(), p.opts.PrincipalClaim)
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	metadata := map[string]string{"source": source}
+	if iss := claimString(claims["iss"]); iss != "" {
+		metadata["issuer"] = iss
+	}
+	if sub := claimString(claims["sub"]); sub != "" {
+		metadata["subject"] = sub
+	}
+	for label, claim := range p.opts.LabelClaims {
+		if value := claimString(claims[claim]); value != "" {
+			metadata[sdkaccess.MetadataUsageLabelPrefix+label] = value
+		}
+	}
+	return &sdkaccess.Result{Provider: p.Identifier(), Principal: principal, Metadata: metadata}, nil
+}
+
+// bearerJWT returns the first credential of the request that has the shape of a JWT, and
+// where it was found.
+func bearerJWT(r *http.Request) (string, string) {
+	candidates := []struct{ value, source string }{
+		{strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")), "authorization"},
+		{r.Header.Get("X-Api-Key"), "x-api-key"},
+		{r.Header.Get("X-Goog-Api-Key"), "x-goog-api-key"},
+	}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate.value, "eyJ") && strings.Count(candidate.value, ".") == 2 {
+			return candidate.value, candidate.source
+		}
+	}
+	return "", ""
+}
+
+// verify checks the signature and the registered claims of token and returns its claims.
+func (p *provider) verify(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	headerJSON, errHeader := base64.RawURLEncoding.DecodeString(parts[0])
+	payload, errPayload := base64.RawURLEncoding.DecodeString(parts[1])
+	signature, errSignature := base64.RawURLEncoding.DecodeString(parts[2])
+	if errHeader != nil || errPayload != nil || errSignature != nil {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, errors.New("malformed header")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range p.keys.candidates(header.Kid, header.Alg) {
+		if verifySignature(header.Alg, key.key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("signature not verified (alg %q, kid %q)", header.Alg, header.Kid)
+	}
+
+	var claims map[string]any
+	decoder := json.NewDecoder(strings.NewReader(string(payload)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, errors.New("malformed claims")
+	}
+	now := p.now()
+	exp, ok := claimTime(claims["exp"])
+	if !ok {
+		return nil, errors.New("no exp claim")
+	}
+	if now.After(exp.Add(p.opts.Leeway)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, hasNbf := claimTime(claims["nbf"]); hasNbf && now.Add(p.opts.Leeway).Before(nbf) {
+		return nil, errors.New("token not valid yet")
+	}
+	if p.opts.Issuer != "" && claimString(claims["iss"]) != p.opts.Issuer {
+		return nil, fmt.Errorf("issuer %q not accepted", claimString(claims["iss"]))
+	}
+	if len(p.opts.Audiences) > 0 && !audienceMatches(claims["aud"], p.opts.Audiences) {
+		return nil, errors.New("audience not accepted")
+	}
+	return claims, nil
+}
+
+// algorithms are the accepted JWS algorithms. HMAC and "none" are not among them: a JWKS
+// only publishes public keys. For ECDSA, size is the curve's coordinate size in bytes.
+var algorithms = map[string]struct {
+	hash crypto.Hash
+	size int
+}{
+	"RS256": {hash: crypto.SHA256}, "RS384": {hash: crypto.SHA384}, "RS512": {hash: crypto.SHA512},
+	"PS256": {hash: crypto.SHA256}, "PS384": {hash: crypto.SHA384}, "PS512": {hash: crypto.SHA512},
+	"ES256": {hash: crypto.SHA256, size: 32}, "ES384": {hash: crypto.SHA384, size: 48}, "ES512": {hash: crypto.SHA512, size: 66},
+	"EdDSA": {},
+}
+
+// verifySignature checks a JWS signature made with alg.
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) bool {
+	spec, ok := algorithms[alg]
+	if !ok {
+		return false
+	}
+	if alg == "EdDSA" {
+		pub, isEd := key.(ed25519.PublicKey)
+		return isEd && ed25519.Verify(pub, signed, signature)
+	}
+	h := spec.hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch alg[:2] {
+	case "RS":
+		pub, isRSA := key.(*rsa.PublicKey)
+		return isRSA && rsa.VerifyPKCS1v15(pub, spec.hash, digest, signature) == nil
+	case "PS":
+		pub, isRSA := key.(*rsa.PublicKey)
+		return isRSA && rsa.VerifyPSS(pub, spec.hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+	default:
+		pub, isEC := key.(*ecdsa.PublicKey)
+		if !isEC || (pub.Curve.Params().BitSize+7)/8 != spec.size || len(signature) != 2*spec.size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:spec.size])
+		s := new(big.Int).SetBytes(signature[spec.size:])
+		return ecdsa.Verify(pub, digest, r, s)
+	}
+}
+
+func audienceMatches(aud any, accepted []string) bool {
+	switch value := aud.(type) {
+	case string:
+		return slices.Contains(accepted, value)
+	case []any:
+		for _, item := range value {
+			if s, ok := item.(string); ok && slices.Contains(accepted, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func claimTime(value any) (time.Time, bool) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// claimString returns a string or number claim as text.
+func claimString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+package jwtaccess
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func b64(data []byte) string { return base64.RawURLEncoding.EncodeToString(data) }
+
+func signToken(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		signature = sig
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + b64(signature)
+}
+
+func TestJWTProviderAuthenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ec key: %v", err)
+	}
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "alg": "RS256", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+	}})
+	fetches := 0
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches++
+		_, _ = w.Write(jwks)
+	}))
+	defer jwksServer.Close()
+
+	built, err := newProvider(&sdkconfig.AccessProvider{Name: "sso", Type: "jwt", Config: map[string]any{
+		"jwks-url":        jwksServer.URL,
+		"issuer":          "https://sso.example.com/",
+		"audience":        []any{"cliproxy"},
+		"principal-claim": "azp",
+		"label-claims":    map[string]any{"team": "team"},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("newProvider: %v", err)
+	}
+	now := time.Now()
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss": "https://sso.example.com/", "aud": "cliproxy", "sub": "user-1", "azp": "billing-service",
+			"team": "payments", "exp": now.Add(time.Hour).Unix(), "nbf": now.Add(-time.Minute).Unix(),
+		}
+	}
+	authenticate := func(token string) (*sdkaccess.Result, error) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return built.Authenticate(context.Background(), req)
+	}
+
+	for _, token := range []string{signToken(t, "RS256", "rsa-1", rsaKey, valid()), signToken(t, "ES256", "ec-1", ecKey, valid())} {
+		result, errAuth := authenticate(token)
+		if errAuth != nil {
+			t.Fatalf("valid token: %v", errAuth)
+		}
+		if result.Principal != "billing-service" || result.Provider != "sso" || result.Metadata[sdkaccess.MetadataUsageLabelPrefix+"team"] != "payments" {
+			t.Fatalf("result = %+v", result)
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", fetches)
+	}
+
+	rejected := map[string]string{
+		"expired":      signToken(t, "RS256", "rsa-1", rsaKey, with(valid(), "exp", now.Add(-time.Hour).Unix())),
+		"not yet":      signToken(t, "RS256", "rsa-1", rsaKey, with(valid(), "nbf", now.Add(time.Hour).Unix())),
+		"issuer":       signToken(t, "RS256", "rsa-1", rsaKey, with(valid(), "iss", "https://evil.example.com/")),
+		"audience":     signToken(t, "RS256", "rsa-1", rsaKey, with(valid(), "aud", []string{"other"})),
+		"no principal": signToken(t, "RS256", "rsa-1", rsaKey, with(valid(), "azp", nil)),
+		"wrong alg":    signToken(t, "RS256", "ec-1", rsaKey, valid()),
+	}
+	good := signToken(t, "RS256", "rsa-1", rsaKey, valid())
+	parts := strings.Split(good, ".")
+	forged, _ := json.Marshal(with(valid(), "azp", "admin"))
+	rejected["tampered"] = parts[0] + "." + b64(forged) + "." + parts[2]
+	none, _ := json.Marshal(map[string]string{"alg": "none"})
+	rejected["alg none"] = b64(none) + "." + parts[1] + "."
+	for name, token := range rejected {
+		if _, errAuth := authenticate(token); !errors.Is(errAuth, sdkaccess.ErrInvalidCredential) {
+			t.Fatalf("%s: err = %v, want ErrInvalidCredential", name, errAuth)
+		}
+	}
+
+	// Static API keys are left to the other providers.
+	if _, errAuth := authenticate("sk-static-key"); !errors.Is(errAuth, sdkaccess.ErrNotHandled) {
+		t.Fatalf("static key: err = %v, want ErrNotHandled", errAuth)
+	}
+}
+
+func with(claims map[string]any, key string, value any) map[string]any {
+	if value == nil {
+		delete(claims, key)
+	} else {
+		claims[key] = value
+	}
+	return claims
+}
+
+func TestInlineAPIKeysKeptNextToJWT(t *testing.T) {
+	configaccess.Register()
+	Register()
+	root := &sdkconfig.SDKConfig{
+		APIKeys: []string{"static-key"},
+		Access: sdkconfig.AccessConfig{Providers: []sdkconfig.AccessProvider{{
+			Name: "sso", Type: "jwt", Config: map[string]any{"jwks-url": "https://sso.example.com/jwks", "issuer": "https://sso.example.com/"},
+		}}},
+	}
+	providers, err := sdkaccess.BuildProviders(root)
+	if err != nil {
+		t.Fatalf("BuildProviders: %v", err)
+	}
+	if len(providers) != 2 || providers[1].Identifier() != sdkconfig.DefaultAccessProviderName {
+		ids := make([]string, 0, len(providers))
+		for _, p := range providers {
+			ids = append(ids, p.Identifier())
+		}
+		t.Fatalf("providers = %v, want sso and the inline api-keys", ids)
+	}
+}
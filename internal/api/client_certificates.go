@@ -0,0 +1,102 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"golang.org/x/crypto/acme"
+)
+
+// clientCertificateProvider is the access provider reported for requests authenticated by
+// their client certificate.
+const clientCertificateProvider = "client-certificate"
+
+// applyClientAuth makes tlsConfig ask for client certificates signed by the CAs in
+// clientAuth.CA. With acme, TLS-ALPN challenges are still answered without one, since the CA
+// validating the domain has no client certificate; such handshakes can only negotiate
+// acme-tls/1, on which closeACMEConnections serves nothing.
+func applyClientAuth(tlsConfig *tls.Config, clientAuth config.TLSClientAuthConfig, acmeEnabled bool) error {
+	if !clientAuth.Enabled() {
+		return nil
+	}
+	pem, err := os.ReadFile(clientAuth.CA)
+	if err != nil {
+		return fmt.Errorf("tls.client-auth: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("tls.client-auth: no certificates found in %s", clientAuth.CA)
+	}
+	challenge := tlsConfig.Clone()
+	challenge.NextProtos = []string{acme.ALPNProto}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	if clientAuth.Optional {
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	if !acmeEnabled {
+		return nil
+	}
+	tlsConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		if slices.Contains(hello.SupportedProtos, acme.ALPNProto) {
+			return challenge, nil
+		}
+		return nil, nil
+	}
+	return nil
+}
+
+// closeACMEConnections makes server close connections that negotiated acme-tls/1 once the
+// handshake, which is all an ACME TLS-ALPN challenge needs, is done, so no request is ever
+// served on them. HTTP/2 is enabled explicitly, as a TLSNextProto map alone turns it off.
+func closeACMEConnections(server *http.Server) {
+	if server.TLSNextProto == nil {
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+	server.TLSNextProto[acme.ALPNProto] = func(_ *http.Server, conn *tls.Conn, _ http.Handler) {
+		_ = conn.Close()
+	}
+	if server.Protocols == nil {
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetHTTP2(true)
+	}
+}
+
+// withoutClientAuth returns a copy of tlsConfig that does not ask for client certificates,
+// for the management listener, whose clients authenticate with the management key.
+func withoutClientAuth(tlsConfig *tls.Config) *tls.Config {
+	if tlsConfig == nil || tlsConfig.ClientAuth == tls.NoClientCert {
+		return tlsConfig
+	}
+	clone := tlsConfig.Clone()
+	clone.ClientAuth = tls.NoClientCert
+	clone.ClientCAs = nil
+	clone.GetConfigForClient = nil
+	return clone
+}
+
+// authenticateClientCertificate authenticates the request as the API key its verified client
+// certificate's common name maps to. It reports false, leaving the request to API key
+// authentication, when there is no such certificate or mapping.
+func (s *Server) authenticateClientCertificate(c *gin.Context) bool {
+	state := c.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 || s.cfg == nil {
+		return false
+	}
+	commonName := state.VerifiedChains[0][0].Subject.CommonName
+	apiKey, ok := s.cfg.TLS.ClientAuth.APIKeyFor(commonName)
+	if !ok {
+		return false
+	}
+	c.Set("apiKey", apiKey)
+	c.Set("accessProvider", clientCertificateProvider)
+	c.Set("accessMetadata", map[string]string{"source": clientCertificateProvider, "common-name": commonName})
+	return true
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	proxyconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"golang.org/x/crypto/acme"
+)
+
+// issueClientCertificate returns a client certificate for commonName signed by the CA.
+func issueClientCertificate(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, commonName string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// newClientCA returns a CA for client certificates and the path of its PEM file.
+func newClientCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey, string) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate CA key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test clients CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+	caPath := filepath.Join(t.TempDir(), "clients-ca.pem")
+	if err = os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600); err != nil {
+		t.Fatalf("write CA: %v", err)
+	}
+	return ca, caKey, caPath
+}
+
+func TestClientCertificateAuthenticatesAsMappedKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ca, caKey, caPath := newClientCA(t)
+	clientAuth := proxyconfig.TLSClientAuthConfig{CA: caPath, Identities: map[string]string{"billing": "billing-key"}}
+	s := &Server{cfg: &proxyconfig.Config{TLS: proxyconfig.TLSConfig{Enable: true, ClientAuth: clientAuth}}}
+	engine := gin.New()
+	engine.GET("/whoami", func(c *gin.Context) {
+		if !s.authenticateClientCertificate(c) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.String(http.StatusOK, c.GetString("apiKey"))
+	})
+	ts := httptest.NewUnstartedServer(engine)
+	ts.TLS = &tls.Config{}
+	if err := applyClientAuth(ts.TLS, clientAuth, false); err != nil {
+		t.Fatalf("applyClientAuth: %v", err)
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	get := func(certs ...tls.Certificate) (int, string, error) {
+		// A transport per call, so that no connection made with another certificate is reused.
+		transport := ts.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.Certificates = certs
+		client := &http.Client{Transport: transport}
+		resp, errGet := client.Get(ts.URL + "/whoami")
+		if errGet != nil {
+			return 0, "", errGet
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body), nil
+	}
+
+	if code, body, errGet := get(issueClientCertificate(t, ca, caKey, "billing")); errGet != nil || code != http.StatusOK || body != "billing-key" {
+		t.Fatalf("mapped certificate: %d %q %v", code, body, errGet)
+	}
+	if code, _, errGet := get(issueClientCertificate(t, ca, caKey, "unknown")); errGet != nil || code != http.StatusUnauthorized {
+		t.Fatalf("unmapped certificate: %d %v, want 401", code, errGet)
+	}
+	if _, _, errGet := get(); errGet == nil {
+		t.Fatal("connection without a client certificate should fail the handshake")
+	}
+}
+
+func TestACMEProtocolServesNoRequests(t *testing.T) {
+	ca, caKey, caPath := newClientCA(t)
+	clientAuth := proxyconfig.TLSClientAuthConfig{CA: caPath}
+
+	for _, acmeEnabled := range []bool{false, true} {
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		// The client does not verify the server, so any certificate will do.
+		ts.TLS = &tls.Config{Certificates: []tls.Certificate{issueClientCertificate(t, ca, caKey, "server")}}
+		if err := applyClientAuth(ts.TLS, clientAuth, acmeEnabled); err != nil {
+			t.Fatalf("applyClientAuth: %v", err)
+		}
+		closeACMEConnections(ts.Config)
+		ts.StartTLS()
+
+		// A client without a certificate that offers acme-tls/1 must get no response, whether
+		// the handshake is refused or completes for an ACME challenge.
+		conn, err := tls.Dial("tcp", ts.Listener.Addr().String(), &tls.Config{
+			InsecureSkipVerify: true,
+			NextProtos:         []string{acme.ALPNProto, "http/1.1"},
+		})
+		if err == nil {
+			_, _ = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+			if resp, errRead := http.ReadResponse(bufio.NewReader(conn), nil); errRead == nil {
+				t.Errorf("acme %v: got status %d over acme-tls/1, want the connection refused", acmeEnabled, resp.StatusCode)
+			}
+			_ = conn.Close()
+		}
+		ts.Close()
+	}
+}
@@ -0,0 +1,164 @@
+package management
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// ListAccounts reports every credential with its health, cooldown and usage summary.
+func (h *Handler) ListAccounts(c *gin.Context) {
+	if h == nil || h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	now := time.Now()
+	cooldowns := make(map[string][]coreauth.CooldownEntry)
+	for _, entry := range h.authManager.Cooldowns() {
+		cooldowns[entry.AuthID] = append(cooldowns[entry.AuthID], entry)
+	}
+	usageByIndex := h.usageStats.AuthUsage()
+	auths := h.authManager.List()
+	sort.Slice(auths, func(i, j int) bool { return auths[i].ID < auths[j].ID })
+	accounts := make([]gin.H, 0, len(auths))
+	for _, auth := range auths {
+		if auth == nil {
+			continue
+		}
+		auth.EnsureIndex()
+		entry := gin.H{
+			"id":             auth.ID,
+			"auth_index":     auth.Index,
+			"name":           auth.FileName,
+			"provider":       strings.TrimSpace(auth.Provider),
+			"label":          auth.Label,
+			"status":         auth.Status,
+			"status_message": auth.StatusMessage,
+			"disabled":       auth.Disabled,
+			"persistent":     accountStatePersistent(auth),
+			"health":         auth.Health,
+			"in_flight":      h.authManager.InFlight(auth.ID),
+			"cooldowns":      cooldownsOrEmpty(cooldowns[auth.ID]),
+			"usage":          usageByIndex[auth.Index],
+		}
+		if email := authEmail(auth); email != "" {
+			entry["email"] = email
+		}
+		if quota, ok := usage.GetQuotaTracker().Status(auth, now); ok {
+			entry["daily_quota"] = quota
+		}
+		accounts = append(accounts, entry)
+	}
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+// DisableAccount takes a credential out of rotation without deleting it.
+func (h *Handler) DisableAccount(c *gin.Context) { h.setAccountDisabled(c, true) }
+
+// EnableAccount puts a disabled credential back into rotation.
+func (h *Handler) EnableAccount(c *gin.Context) { h.setAccountDisabled(c, false) }
+
+func (h *Handler) setAccountDisabled(c *gin.Context, disabled bool) {
+	if h == nil || h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	target := h.findAccount(c.Param("id"))
+	if target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+	if !disabled {
+		// A credential disabled for an unusable proxy_url must not silently egress elsewhere.
+		if errProxy := target.ApplyMetadataProxyURL(); errProxy != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("account cannot be enabled: invalid proxy_url: %v", errProxy)})
+			return
+		}
+	}
+
+	persistent := accountStatePersistent(target)
+	target.Disabled = disabled
+	if disabled {
+		target.Status = coreauth.StatusDisabled
+		target.StatusMessage = "disabled via management API"
+	} else {
+		target.Status = coreauth.StatusActive
+		target.StatusMessage = ""
+	}
+	if persistent {
+		if target.Metadata == nil {
+			target.Metadata = make(map[string]any)
+		}
+		target.Metadata["disabled"] = disabled
+	}
+	target.UpdatedAt = time.Now()
+	if _, err := h.authManager.Update(c.Request.Context(), target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to update account: %v", err)})
+		return
+	}
+
+	resp := gin.H{"status": "ok", "id": target.ID, "disabled": disabled, "persistent": persistent}
+	if disabled && h.healthyAccounts(target.Provider) == 0 {
+		warning := fmt.Sprintf("no healthy %s accounts remain in rotation; requests for this provider will fail", target.Provider)
+		log.Warnf("management: account %s disabled; %s", target.ID, warning)
+		resp["warning"] = warning
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// findAccount resolves an account by auth index, ID or file name.
+func (h *Handler) findAccount(ref string) *coreauth.Auth {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil
+	}
+	if auth, ok := h.authManager.GetByID(ref); ok {
+		return auth
+	}
+	for _, auth := range h.authManager.List() {
+		if auth == nil {
+			continue
+		}
+		auth.EnsureIndex()
+		if auth.Index == ref || auth.FileName == ref {
+			return auth
+		}
+	}
+	return nil
+}
+
+// healthyAccounts counts credentials of provider that can currently serve requests.
+func (h *Handler) healthyAccounts(provider string) int {
+	now := time.Now()
+	count := 0
+	for _, auth := range h.authManager.List() {
+		if auth == nil || auth.Provider != provider || auth.Disabled || auth.Status == coreauth.StatusDisabled {
+			continue
+		}
+		if auth.Health.Unhealthy || (auth.Unavailable && auth.NextRetryAfter.After(now)) {
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// accountStatePersistent reports whether a disabled flag is written back to the auth file.
+// Config-derived and runtime-only credentials keep the flag in memory until restart.
+func accountStatePersistent(auth *coreauth.Auth) bool {
+	return !isRuntimeOnlyAuth(auth) && strings.TrimSpace(authAttribute(auth, "path")) != ""
+}
+
+func cooldownsOrEmpty(entries []coreauth.CooldownEntry) []coreauth.CooldownEntry {
+	if entries == nil {
+		return []coreauth.CooldownEntry{}
+	}
+	return entries
+}
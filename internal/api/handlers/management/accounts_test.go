@@ -0,0 +1,62 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestDisableAccountPersistsAndWarnsOnLastHealthy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryAuthStore{}
+	manager := coreauth.NewManager(store, nil, nil)
+	for _, id := range []string{"a.json", "b.json"} {
+		auth := &coreauth.Auth{
+			ID:         id,
+			FileName:   id,
+			Provider:   "codex",
+			Status:     coreauth.StatusActive,
+			Attributes: map[string]string{"path": "/auths/" + id},
+			Metadata:   map[string]any{"type": "codex"},
+		}
+		if _, err := manager.Register(context.Background(), auth); err != nil {
+			t.Fatalf("register %s: %v", id, err)
+		}
+	}
+	h := NewHandlerWithoutConfigFilePath(nil, manager)
+
+	call := func(handler gin.HandlerFunc, id string) map[string]any {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v0/management/accounts/"+id, nil)
+		c.Params = gin.Params{{Key: "id", Value: id}}
+		handler(c)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", id, rec.Code, rec.Body.String())
+		}
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return body
+	}
+
+	if body := call(h.DisableAccount, "a.json"); body["warning"] != nil {
+		t.Fatalf("unexpected warning while b.json is healthy: %v", body["warning"])
+	}
+	if saved := store.items["a.json"]; saved == nil || saved.Metadata["disabled"] != true {
+		t.Fatal("disabled flag was not written to the store")
+	}
+	if body := call(h.DisableAccount, "b.json"); body["warning"] == nil {
+		t.Fatal("expected a warning when the last healthy account is disabled")
+	}
+	call(h.EnableAccount, "a.json")
+	if saved := store.items["a.json"]; saved.Disabled || saved.Metadata["disabled"] != false {
+		t.Fatal("enable was not persisted")
+	}
+}
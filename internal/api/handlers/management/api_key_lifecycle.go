@@ -0,0 +1,301 @@
+package management
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// generatedAPIKeyBytes is the entropy of the keys the management API issues.
+const generatedAPIKeyBytes = 24
+
+// apiKeyStatus is one client key as listed by GetAPIKeyStatus.
+type apiKeyStatus struct {
+	APIKey    string     `json:"api-key"`
+	State     string     `json:"state"`
+	ExpiresAt *time.Time `json:"expires-at,omitempty"`
+}
+
+// expiryRequest is how a request sets a key's expiry: an absolute time or a duration from now.
+type expiryRequest struct {
+	ExpiresAt *time.Time `json:"expires-at"`
+	ExpiresIn string     `json:"expires-in"`
+}
+
+// resolve returns the requested expiry, or nil when none is requested.
+func (r expiryRequest) resolve(now time.Time) (*time.Time, bool) {
+	if r.ExpiresIn = strings.TrimSpace(r.ExpiresIn); r.ExpiresIn != "" {
+		d, err := time.ParseDuration(r.ExpiresIn)
+		if err != nil || d <= 0 || r.ExpiresAt != nil {
+			return nil, false
+		}
+		at := now.Add(d).UTC().Truncate(time.Second)
+		return &at, true
+	}
+	if r.ExpiresAt != nil {
+		at := r.ExpiresAt.UTC()
+		return &at, true
+	}
+	return nil, true
+}
+
+// GetAPIKeyStatus lists the client api-keys with whether each is active, disabled or expired.
+func (h *Handler) GetAPIKeyStatus(c *gin.Context) {
+	now := time.Now()
+	keys := make([]apiKeyStatus, 0, len(h.cfg.APIKeys))
+	for _, key := range h.cfg.APIKeys {
+		settings := h.cfg.APIKeySettingsFor(key)
+		status := apiKeyStatus{APIKey: key, State: settings.State(now)}
+		if settings != nil {
+			status.ExpiresAt = settings.ExpiresAt
+		}
+		keys = append(keys, status)
+	}
+	c.JSON(http.StatusOK, gin.H{"api-keys": keys})
+}
+
+// CreateAPIKey adds a client key, generated unless the body names one, optionally with an
+// expiry, and returns it.
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var body struct {
+		APIKey string `json:"api-key"`
+		expiryRequest
+	}
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	expiresAt, ok := body.resolve(time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "give either expires-at or a positive expires-in duration"})
+		return
+	}
+	key := strings.TrimSpace(body.APIKey)
+	if key == "" {
+		generated, err := generateAPIKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate api key"})
+			return
+		}
+		key = generated
+	}
+	if slices.Contains(h.cfg.APIKeys, key) {
+		c.JSON(http.StatusConflict, gin.H{"error": "api key already exists"})
+		return
+	}
+	h.cfg.APIKeys = append(h.cfg.APIKeys, key)
+	h.dropInlineAPIKeyProviders()
+	if expiresAt != nil {
+		h.apiKeySettings(key).ExpiresAt = expiresAt
+	}
+	if !h.saveConfig(c) {
+		return
+	}
+	c.JSON(http.StatusOK, apiKeyStatus{APIKey: key, State: config.APIKeyStateActive, ExpiresAt: expiresAt})
+}
+
+// DisableAPIKey refuses every request made with a client key until it is enabled again.
+func (h *Handler) DisableAPIKey(c *gin.Context) { h.setAPIKeyDisabled(c, true) }
+
+// EnableAPIKey lifts DisableAPIKey.
+func (h *Handler) EnableAPIKey(c *gin.Context) { h.setAPIKeyDisabled(c, false) }
+
+func (h *Handler) setAPIKeyDisabled(c *gin.Context, disabled bool) {
+	key, ok := h.bindExistingAPIKey(c, nil)
+	if !ok {
+		return
+	}
+	h.apiKeySettings(key).Disabled = disabled
+	h.pruneAPIKeySettings(key)
+	h.persist(c)
+}
+
+// PutAPIKeyExpiry sets or, when neither expires-at nor expires-in is given, clears the expiry
+// of a client key.
+func (h *Handler) PutAPIKeyExpiry(c *gin.Context) {
+	var expiry expiryRequest
+	key, ok := h.bindExistingAPIKey(c, &expiry)
+	if !ok {
+		return
+	}
+	expiresAt, valid := expiry.resolve(time.Now())
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "give either expires-at or a positive expires-in duration"})
+		return
+	}
+	h.apiKeySettings(key).ExpiresAt = expiresAt
+	h.pruneAPIKeySettings(key)
+	h.persist(c)
+}
+
+// RotateAPIKey replaces a client key with a generated one that inherits its settings and
+// mapped client certificates, and starts enabled. With a grace-period the old key stays
+// usable until it ends; otherwise it is removed at once. The new key starts with what the old
+// one has used of its quota. The body may give the new key an expiry of its own.
+func (h *Handler) RotateAPIKey(c *gin.Context) {
+	var body struct {
+		GracePeriod string `json:"grace-period"`
+		expiryRequest
+	}
+	oldKey, ok := h.bindExistingAPIKey(c, &body)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	var grace time.Duration
+	if value := strings.TrimSpace(body.GracePeriod); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid grace-period"})
+			return
+		}
+		grace = d
+	}
+	expiresAt, valid := body.resolve(now)
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "give either expires-at or a positive expires-in duration"})
+		return
+	}
+	newKey, err := generateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate api key"})
+		return
+	}
+
+	var inherited config.APIKeySettings
+	if settings := h.cfg.APIKeySettingsFor(oldKey); settings != nil {
+		inherited = cloneAPIKeySettings(*settings)
+	}
+	inherited.APIKey = newKey
+	inherited.Disabled = false
+	if expiresAt != nil {
+		inherited.ExpiresAt = expiresAt
+	}
+
+	index := slices.Index(h.cfg.APIKeys, oldKey)
+	var oldExpiresAt *time.Time
+	if grace > 0 {
+		h.cfg.APIKeys = slices.Insert(h.cfg.APIKeys, index+1, newKey)
+		end := now.Add(grace).UTC().Truncate(time.Second)
+		old := h.apiKeySettings(oldKey)
+		if old.ExpiresAt == nil || old.ExpiresAt.After(end) {
+			old.ExpiresAt = &end
+		}
+		oldExpiresAt = old.ExpiresAt
+	} else {
+		h.cfg.APIKeys[index] = newKey
+		h.cfg.APIKeySettings = slices.DeleteFunc(h.cfg.APIKeySettings, func(s config.APIKeySettings) bool { return s.APIKey == oldKey })
+	}
+	h.cfg.APIKeySettings = append(h.cfg.APIKeySettings, inherited)
+	h.pruneAPIKeySettings(newKey)
+	for cn, key := range h.cfg.TLS.ClientAuth.Identities {
+		if key == oldKey {
+			h.cfg.TLS.ClientAuth.Identities[cn] = newKey
+		}
+	}
+	h.dropInlineAPIKeyProviders()
+	if !h.saveConfig(c) {
+		return
+	}
+	usage.GetKeyQuotaTracker().CarryOver(oldKey, newKey, grace > 0)
+	c.JSON(http.StatusOK, gin.H{
+		"api-key":              newKey,
+		"expires-at":           inherited.ExpiresAt,
+		"previous-expires-at":  oldExpiresAt,
+		"previous-key-removed": grace == 0,
+	})
+}
+
+// bindExistingAPIKey binds the body, whose api-key must be one of the client api-keys, into
+// extra as well when given. It answers 400 or 404 and reports false otherwise.
+func (h *Handler) bindExistingAPIKey(c *gin.Context, extra any) (string, bool) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return "", false
+	}
+	var body struct {
+		APIKey string `json:"api-key"`
+	}
+	if errBody := json.Unmarshal(data, &body); errBody != nil || strings.TrimSpace(body.APIKey) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body: api-key is required"})
+		return "", false
+	}
+	if extra != nil {
+		if errExtra := json.Unmarshal(data, extra); errExtra != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+			return "", false
+		}
+	}
+	key := strings.TrimSpace(body.APIKey)
+	if !slices.Contains(h.cfg.APIKeys, key) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return "", false
+	}
+	return key, true
+}
+
+// apiKeySettings returns the api-key-settings entry of key, adding an empty one when it has
+// none.
+func (h *Handler) apiKeySettings(key string) *config.APIKeySettings {
+	if settings := h.cfg.APIKeySettingsFor(key); settings != nil {
+		return settings
+	}
+	h.cfg.APIKeySettings = append(h.cfg.APIKeySettings, config.APIKeySettings{APIKey: key})
+	return &h.cfg.APIKeySettings[len(h.cfg.APIKeySettings)-1]
+}
+
+// pruneAPIKeySettings drops the api-key-settings entry of key when nothing is left in it, so
+// enabling a key or clearing its expiry leaves no empty entry in the config file.
+func (h *Handler) pruneAPIKeySettings(key string) {
+	empty := config.APIKeySettings{APIKey: key}
+	h.cfg.APIKeySettings = slices.DeleteFunc(h.cfg.APIKeySettings, func(s config.APIKeySettings) bool {
+		return reflect.DeepEqual(s, empty)
+	})
+}
+
+// cloneAPIKeySettings copies settings so the copy can be edited on its own.
+func cloneAPIKeySettings(settings config.APIKeySettings) config.APIKeySettings {
+	if settings.SystemPrompt != nil {
+		prompt := *settings.SystemPrompt
+		settings.SystemPrompt = &prompt
+	}
+	if settings.Scopes != nil {
+		scopes := *settings.Scopes
+		scopes.Routes = slices.Clone(scopes.Routes)
+		scopes.Models = slices.Clone(scopes.Models)
+		scopes.Providers = slices.Clone(scopes.Providers)
+		settings.Scopes = &scopes
+	}
+	if settings.Quota != nil {
+		quota := *settings.Quota
+		settings.Quota = &quota
+	}
+	settings.AllowedIPs = slices.Clone(settings.AllowedIPs)
+	settings.DeniedIPs = slices.Clone(settings.DeniedIPs)
+	if settings.ExpiresAt != nil {
+		at := *settings.ExpiresAt
+		settings.ExpiresAt = &at
+	}
+	return settings
+}
+
+// generateAPIKey returns a new random client key.
+func generateAPIKey() (string, error) {
+	buf := make([]byte, generatedAPIKeyBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "sk-" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,103 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestAPIKeyLifecyclePersists(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 8317\napi-keys:\n  - \"old-key\"\n"+
+		"auth:\n  providers:\n    - name: sso\n      type: jwt\n      config:\n        jwks-url: \"https://sso.example.com/jwks\"\n        issuer: \"https://sso.example.com/\"\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.APIKeySettings = []config.APIKeySettings{{APIKey: "old-key", Scopes: &config.APIKeyScopes{Models: []string{"gemini-*"}}}}
+	cfg.TLS.ClientAuth.Identities = map[string]string{"billing": "old-key"}
+	h := NewHandler(cfg, configPath, nil)
+
+	engine := gin.New()
+	engine.GET("/api-keys/status", h.GetAPIKeyStatus)
+	engine.POST("/api-keys/create", h.CreateAPIKey)
+	engine.POST("/api-keys/disable", h.DisableAPIKey)
+	engine.POST("/api-keys/enable", h.EnableAPIKey)
+	engine.POST("/api-keys/rotate", h.RotateAPIKey)
+	call := func(method, path, body string, wantStatus int) map[string]any {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if rec.Code != wantStatus {
+			t.Fatalf("%s %s: status %d, want %d: %s", method, path, rec.Code, wantStatus, rec.Body.String())
+		}
+		var out map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		return out
+	}
+
+	created := call(http.MethodPost, "/api-keys/create", `{"expires-in":"720h"}`, http.StatusOK)
+	newKey, _ := created["api-key"].(string)
+	if !strings.HasPrefix(newKey, "sk-") || created["expires-at"] == nil {
+		t.Fatalf("create = %v, want a generated key with an expiry", created)
+	}
+	call(http.MethodPost, "/api-keys/create", `{"api-key":"old-key"}`, http.StatusConflict)
+	call(http.MethodPost, "/api-keys/disable", `{"api-key":"missing"}`, http.StatusNotFound)
+	call(http.MethodPost, "/api-keys/disable", `{"api-key":"`+newKey+`"}`, http.StatusOK)
+	if state := cfg.APIKeySettingsFor(newKey).State(time.Now()); state != config.APIKeyStateDisabled {
+		t.Fatalf("state after disable = %s", state)
+	}
+
+	rotated := call(http.MethodPost, "/api-keys/rotate", `{"api-key":"old-key","grace-period":"1h"}`, http.StatusOK)
+	rotatedKey, _ := rotated["api-key"].(string)
+	if rotatedKey == "" || rotated["previous-key-removed"] != false {
+		t.Fatalf("rotate = %v", rotated)
+	}
+
+	saved, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("reload config: %v", err)
+	}
+	if len(saved.Access.Providers) != 1 || saved.Access.Providers[0].Name != "sso" {
+		t.Fatalf("saved access providers = %+v, want the jwt provider kept", saved.Access.Providers)
+	}
+	if len(saved.APIKeys) != 3 || saved.APIKeys[0] != "old-key" || saved.APIKeys[1] != rotatedKey {
+		t.Fatalf("saved api-keys = %v", saved.APIKeys)
+	}
+	now := time.Now()
+	if old := saved.APIKeySettingsFor("old-key"); old.State(now) != config.APIKeyStateActive || old.ExpiresAt == nil ||
+		old.State(now.Add(2*time.Hour)) != config.APIKeyStateExpired {
+		t.Fatalf("old key settings = %+v, want it to expire within the grace period", old)
+	}
+	if inherited := saved.APIKeySettingsFor(rotatedKey); inherited == nil || inherited.Scopes == nil || inherited.Scopes.Models[0] != "gemini-*" {
+		t.Fatalf("rotated key settings = %+v, want the old key's scopes", inherited)
+	}
+	if saved.APIKeySettingsFor(newKey).State(now) != config.APIKeyStateDisabled {
+		t.Fatal("disabled flag was not saved")
+	}
+	if cfg.TLS.ClientAuth.Identities["billing"] != rotatedKey {
+		t.Fatalf("client certificate still mapped to %q", cfg.TLS.ClientAuth.Identities["billing"])
+	}
+
+	call(http.MethodPost, "/api-keys/enable", `{"api-key":"`+newKey+`"}`, http.StatusOK)
+	status := call(http.MethodGet, "/api-keys/status", "", http.StatusOK)
+	states := make(map[string]string)
+	for _, entry := range status["api-keys"].([]any) {
+		item := entry.(map[string]any)
+		states[item["api-key"].(string)] = item["state"].(string)
+	}
+	if states[newKey] != config.APIKeyStateActive || states["old-key"] != config.APIKeyStateActive {
+		t.Fatalf("states = %v", states)
+	}
+}
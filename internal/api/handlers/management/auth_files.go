@@ -23,16 +23,18 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/antigravity"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/claude"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/codex"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/filecrypt"
 	geminiAuth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
 	iflowauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/iflow"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/qwen"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
-	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -339,7 +341,7 @@ func (h *Handler) listAuthFilesFromDisk(c *gin.Context) {
 
 			// Read file to get type field
 			full := filepath.Join(h.cfg.AuthDir, name)
-			if data, errRead := os.ReadFile(full); errRead == nil {
+			if data, errRead := filecrypt.ReadFile(full); errRead == nil {
 				typeValue := gjson.GetBytes(data, "type").String()
 				emailValue := gjson.GetBytes(data, "email").String()
 				fileData["type"] = typeValue
@@ -405,6 +407,12 @@ func (h *Handler) buildAuthFileEntry(auth *coreauth.Auth) gin.H {
 	if !auth.LastRefreshedAt.IsZero() {
 		entry["last_refresh"] = auth.LastRefreshedAt
 	}
+	if dir := strings.TrimSpace(authAttribute(auth, "auth_dir")); dir != "" {
+		entry["auth_dir"] = dir
+	}
+	if quota, ok := usage.GetQuotaTracker().Status(auth, time.Now()); ok {
+		entry["daily_quota"] = quota
+	}
 	if path != "" {
 		entry["path"] = path
 		entry["source"] = "file"
@@ -501,10 +509,12 @@ func isRuntimeOnlyAuth(auth *coreauth.Auth) bool {
 	return strings.EqualFold(strings.TrimSpace(auth.Attributes["runtime_only"]), "true")
 }
 
-// Download single auth file by name
+// Download single auth file by name. The name is a file name, looked up in every auth
+// directory in scan order, or the full path ID of a file in one of them.
 func (h *Handler) DownloadAuthFile(c *gin.Context) {
 	name := c.Query("name")
-	if name == "" || strings.Contains(name, string(os.PathSeparator)) {
+	full, ok := h.resolveAuthFileName(name)
+	if !ok {
 		c.JSON(400, gin.H{"error": "invalid name"})
 		return
 	}
@@ -512,20 +522,63 @@ func (h *Handler) DownloadAuthFile(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "name must end with .json"})
 		return
 	}
-	full := filepath.Join(h.cfg.AuthDir, name)
-	data, err := os.ReadFile(full)
+	data, err := filecrypt.ReadFile(full)
 	if err != nil {
 		if os.IsNotExist(err) {
 			c.JSON(404, gin.H{"error": "file not found"})
+		} else if errors.Is(err, filecrypt.ErrKeyUnavailable) || errors.Is(err, filecrypt.ErrDecrypt) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		} else {
 			c.JSON(500, gin.H{"error": fmt.Sprintf("failed to read file: %v", err)})
 		}
 		return
 	}
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", name))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(full)))
 	c.Data(200, "application/json", data)
 }
 
+// authDirectories returns the resolved auth directories, the writable one first.
+func (h *Handler) authDirectories() []string {
+	dirs := []string{h.cfg.AuthDir}
+	for _, dir := range h.cfg.AuthDirectories() {
+		if resolved, errResolve := util.ResolveAuthDir(dir); errResolve == nil {
+			dir = resolved
+		}
+		if dir != "" && filepath.Clean(dir) != filepath.Clean(h.cfg.AuthDir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// resolveAuthFileName maps a file name or full path ID to a file in one of the auth
+// directories. A bare name missing from every directory maps into the writable one.
+func (h *Handler) resolveAuthFileName(name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+	dirs := h.authDirectories()
+	if filepath.IsAbs(name) {
+		clean := filepath.Clean(name)
+		for _, dir := range dirs {
+			if filepath.Dir(clean) == filepath.Clean(dir) {
+				return clean, true
+			}
+		}
+		return "", false
+	}
+	if strings.Contains(name, string(os.PathSeparator)) {
+		return "", false
+	}
+	for _, dir := range dirs {
+		full := filepath.Join(dir, name)
+		if _, errStat := os.Stat(full); errStat == nil {
+			return full, true
+		}
+	}
+	return filepath.Join(h.cfg.AuthDir, name), true
+}
+
 // Upload auth file: multipart or raw JSON with ?name=
 func (h *Handler) UploadAuthFile(c *gin.Context) {
 	if h.authManager == nil {
@@ -554,6 +607,12 @@ func (h *Handler) UploadAuthFile(c *gin.Context) {
 			c.JSON(500, gin.H{"error": fmt.Sprintf("failed to read saved file: %v", errRead)})
 			return
 		}
+		if filecrypt.Enabled() {
+			if _, errEncrypt := filecrypt.EncryptFileInPlace(dst); errEncrypt != nil {
+				c.JSON(500, gin.H{"error": fmt.Sprintf("failed to encrypt saved file: %v", errEncrypt)})
+				return
+			}
+		}
 		if errReg := h.registerAuthFromFile(ctx, dst, data); errReg != nil {
 			c.JSON(500, gin.H{"error": errReg.Error()})
 			return
@@ -581,7 +640,12 @@ func (h *Handler) UploadAuthFile(c *gin.Context) {
 			dst = abs
 		}
 	}
-	if errWrite := os.WriteFile(dst, data, 0o600); errWrite != nil {
+	sealed, errSeal := filecrypt.Seal(data)
+	if errSeal != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to encrypt file: %v", errSeal)})
+		return
+	}
+	if errWrite := os.WriteFile(dst, sealed, 0o600); errWrite != nil {
 		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to write file: %v", errWrite)})
 		return
 	}
@@ -632,12 +696,11 @@ func (h *Handler) DeleteAuthFile(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok", "deleted": deleted})
 		return
 	}
-	name := c.Query("name")
-	if name == "" || strings.Contains(name, string(os.PathSeparator)) {
+	full, ok := h.resolveAuthFileName(c.Query("name"))
+	if !ok {
 		c.JSON(400, gin.H{"error": "invalid name"})
 		return
 	}
-	full := filepath.Join(h.cfg.AuthDir, filepath.Base(name))
 	if !filepath.IsAbs(full) {
 		if abs, errAbs := filepath.Abs(full); errAbs == nil {
 			full = abs
@@ -686,7 +749,7 @@ func (h *Handler) registerAuthFromFile(ctx context.Context, path string, data []
 	}
 	if data == nil {
 		var err error
-		data, err = os.ReadFile(path)
+		data, err = filecrypt.ReadFile(path)
 		if err != nil {
 			return fmt.Errorf("failed to read auth file: %w", err)
 		}
@@ -807,6 +870,63 @@ func (h *Handler) PatchAuthFileStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok", "disabled": *req.Disabled})
 }
 
+// PatchAuthFileProject moves a Gemini OAuth credential to another Google Cloud project.
+func (h *Handler) PatchAuthFileProject(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+
+	var req struct {
+		Name      string `json:"name"`
+		ProjectID string `json:"project_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	projectID := strings.TrimSpace(req.ProjectID)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if projectID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "project_id is required"})
+		return
+	}
+
+	var targetAuth *coreauth.Auth
+	if auth, ok := h.authManager.GetByID(name); ok {
+		targetAuth = auth
+	} else {
+		for _, auth := range h.authManager.List() {
+			if auth.FileName == name {
+				targetAuth = auth
+				break
+			}
+		}
+	}
+	if targetAuth == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "auth file not found"})
+		return
+	}
+
+	if err := geminiAuth.SetAuthProject(targetAuth, projectID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	targetAuth.UpdatedAt = time.Now()
+
+	if _, err := h.authManager.Update(c.Request.Context(), targetAuth); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to update auth: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "project_id": projectID})
+}
+
 func (h *Handler) disableAuth(ctx context.Context, id string) {
 	if h == nil || h.authManager == nil {
 		return
@@ -851,6 +971,9 @@ func (h *Handler) tokenStoreWithBaseDir() coreauth.Store {
 		if dirSetter, ok := store.(interface{ SetBaseDir(string) }); ok {
 			dirSetter.SetBaseDir(h.cfg.AuthDir)
 		}
+		if dirsSetter, ok := store.(interface{ SetAuthDirs([]string) }); ok {
+			dirsSetter.SetAuthDirs(h.cfg.AuthDirectories())
+		}
 	}
 	return store
 }
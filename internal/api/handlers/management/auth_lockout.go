@@ -0,0 +1,193 @@
+package management
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// attemptMaxFailures is how many failed authentications in a row lock a source out.
+const attemptMaxFailures = 5
+
+// attemptBaseLockout is the first lockout; each further lockout of the same source doubles
+// it, up to attemptMaxLockout.
+const attemptBaseLockout = time.Minute
+
+// attemptMaxLockout caps the lockout duration.
+const attemptMaxLockout = time.Hour
+
+// LockoutEntry describes a source with failed management authentications.
+type LockoutEntry struct {
+	Source       string    `json:"source"`
+	Failures     int       `json:"failures"`
+	Lockouts     int       `json:"lockouts"`
+	LockedUntil  time.Time `json:"locked_until,omitempty"`
+	RetryAfter   int64     `json:"retry_after_seconds,omitempty"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// managementSource returns the address management access control applies to. It is the
+// source resolved by the allowlist middleware, which honours trusted proxies, and otherwise
+// the peer address; forwarding headers from untrusted clients are never believed.
+func managementSource(c *gin.Context) string {
+	if source, ok := c.Get(middleware.SourceAddrKey); ok {
+		if s, _ := source.(string); s != "" {
+			return s
+		}
+	}
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		return c.Request.RemoteAddr
+	}
+	return host
+}
+
+// lockedFor returns how much longer source is locked out, or zero.
+func (h *Handler) lockedFor(source string, now time.Time) time.Duration {
+	h.attemptsMu.Lock()
+	defer h.attemptsMu.Unlock()
+	if ai := h.failedAttempts[source]; ai != nil && !ai.stale(now) && now.Before(ai.blockedUntil) {
+		return ai.blockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// recordAuthFailure counts a failed authentication from source and returns the lockout it
+// triggered, or zero. A stale entry starts over.
+func (h *Handler) recordAuthFailure(source string, now time.Time) time.Duration {
+	h.attemptsMu.Lock()
+	defer h.attemptsMu.Unlock()
+	ai := h.failedAttempts[source]
+	if ai == nil || ai.stale(now) {
+		if ai == nil && len(h.failedAttempts) >= attemptMaxSources {
+			h.evictAttemptLocked(now)
+		}
+		ai = &attemptInfo{}
+		h.failedAttempts[source] = ai
+	}
+	ai.count++
+	ai.lastActivity = now
+	if ai.count < attemptMaxFailures {
+		return 0
+	}
+	ai.count = 0
+	ai.lockouts++
+	lockout := attemptBaseLockout
+	for i := 1; i < ai.lockouts && lockout < attemptMaxLockout; i++ {
+		lockout *= 2
+	}
+	lockout = min(lockout, attemptMaxLockout)
+	ai.blockedUntil = now.Add(lockout)
+	return lockout
+}
+
+// evictAttemptLocked makes room for a new source once attemptMaxSources are tracked. Stale
+// entries go first; otherwise the longest idle source that is not locked out is dropped, and
+// only when every source is locked out the one whose lockout ends first.
+func (h *Handler) evictAttemptLocked(now time.Time) {
+	h.purgeStaleAttemptsLocked(now)
+	if len(h.failedAttempts) < attemptMaxSources {
+		return
+	}
+	var victim string
+	var victimInfo *attemptInfo
+	for source, ai := range h.failedAttempts {
+		if victimInfo == nil {
+			victim, victimInfo = source, ai
+			continue
+		}
+		locked, victimLocked := now.Before(ai.blockedUntil), now.Before(victimInfo.blockedUntil)
+		switch {
+		case locked != victimLocked:
+			if !locked {
+				victim, victimInfo = source, ai
+			}
+		case locked:
+			if ai.blockedUntil.Before(victimInfo.blockedUntil) {
+				victim, victimInfo = source, ai
+			}
+		case ai.lastActivity.Before(victimInfo.lastActivity):
+			victim, victimInfo = source, ai
+		}
+	}
+	delete(h.failedAttempts, victim)
+}
+
+// recordAuthSuccess forgets the failures of source.
+func (h *Handler) recordAuthSuccess(source string) {
+	h.attemptsMu.Lock()
+	delete(h.failedAttempts, source)
+	h.attemptsMu.Unlock()
+}
+
+// auditAuth logs the outcome of a management authentication.
+func auditAuth(c *gin.Context, source string, success bool, detail string) {
+	entry := log.WithFields(log.Fields{
+		"audit":    "management-auth",
+		"source":   source,
+		"method":   c.Request.Method,
+		"endpoint": c.Request.URL.Path,
+		"success":  success,
+	})
+	if success {
+		entry.Infof("management authentication succeeded: %s", detail)
+		return
+	}
+	entry.Warnf("management authentication failed: %s", detail)
+}
+
+// abortLockedOut answers a request from a locked-out source with 429 and Retry-After.
+func abortLockedOut(c *gin.Context, remaining time.Duration) {
+	seconds := int64((remaining + time.Second - 1) / time.Second)
+	c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many failed attempts; try again in " + (time.Duration(seconds) * time.Second).String()})
+}
+
+// GetLockouts lists the sources with failed management authentications, locked out first.
+func (h *Handler) GetLockouts(c *gin.Context) {
+	now := time.Now()
+	h.attemptsMu.Lock()
+	entries := make([]LockoutEntry, 0, len(h.failedAttempts))
+	for source, ai := range h.failedAttempts {
+		if ai.stale(now) {
+			continue
+		}
+		entry := LockoutEntry{Source: source, Failures: ai.count, Lockouts: ai.lockouts, LastActivity: ai.lastActivity}
+		if now.Before(ai.blockedUntil) {
+			entry.LockedUntil = ai.blockedUntil
+			entry.RetryAfter = int64(ai.blockedUntil.Sub(now).Round(time.Second) / time.Second)
+		}
+		entries = append(entries, entry)
+	}
+	h.attemptsMu.Unlock()
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].RetryAfter != entries[j].RetryAfter {
+			return entries[i].RetryAfter > entries[j].RetryAfter
+		}
+		return entries[i].Source < entries[j].Source
+	})
+	c.JSON(http.StatusOK, gin.H{"lockouts": entries})
+}
+
+// DeleteLockouts clears the failures and lockout of ?source=, or of every source without it.
+func (h *Handler) DeleteLockouts(c *gin.Context) {
+	source := strings.TrimSpace(c.Query("source"))
+	h.attemptsMu.Lock()
+	removed := 0
+	if source == "" {
+		removed = len(h.failedAttempts)
+		clear(h.failedAttempts)
+	} else if _, ok := h.failedAttempts[source]; ok {
+		delete(h.failedAttempts, source)
+		removed = 1
+	}
+	h.attemptsMu.Unlock()
+	c.JSON(http.StatusOK, gin.H{"removed": removed})
+}
@@ -0,0 +1,113 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestMiddlewareLocksOutRepeatedFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHandlerWithoutConfigFilePath(&config.Config{}, nil)
+	h.SetLocalPassword("local-pass")
+	h.envSecret = "env-secret"
+
+	engine := gin.New()
+	engine.Use(h.Middleware())
+	engine.GET("/v0/management/lockouts", h.GetLockouts)
+
+	get := func(remote, password, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v0/management/lockouts", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("Authorization", "Bearer "+password)
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		rr := httptest.NewRecorder()
+		engine.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < attemptMaxFailures; i++ {
+		// A forged forwarding header does not spread the failures over several sources.
+		if rr := get("127.0.0.1:4000", "wrong", "198.51.100.9"); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status %d, want 401", i, rr.Code)
+		}
+	}
+	rr := get("127.0.0.1:4000", "local-pass", "")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" {
+		t.Fatalf("locked out: status %d, Retry-After %q; want 429 and 60", rr.Code, rr.Header().Get("Retry-After"))
+	}
+
+	if rr = get("[::1]:4000", "local-pass", ""); rr.Code != http.StatusOK {
+		t.Fatalf("other source: status %d, body %s", rr.Code, rr.Body.String())
+	}
+	var body struct {
+		Lockouts []LockoutEntry `json:"lockouts"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode lockouts: %v", err)
+	}
+	if len(body.Lockouts) != 1 || body.Lockouts[0].Source != "127.0.0.1" || body.Lockouts[0].RetryAfter <= 0 {
+		t.Fatalf("unexpected lockouts: %+v", body.Lockouts)
+	}
+}
+
+func TestRecordAuthFailureDoublesLockout(t *testing.T) {
+	h := NewHandlerWithoutConfigFilePath(nil, nil)
+	now := time.Now()
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for round, expected := range want {
+		var lockout time.Duration
+		for i := 0; i < attemptMaxFailures; i++ {
+			lockout = h.recordAuthFailure("203.0.113.1", now)
+		}
+		if lockout != expected {
+			t.Fatalf("lockout %d = %s, want %s", round+1, lockout, expected)
+		}
+	}
+	for i := 0; i < 10*attemptMaxFailures; i++ {
+		h.recordAuthFailure("203.0.113.1", now)
+	}
+	if got := h.lockedFor("203.0.113.1", now); got != attemptMaxLockout {
+		t.Fatalf("capped lockout = %s, want %s", got, attemptMaxLockout)
+	}
+	h.recordAuthSuccess("203.0.113.1")
+	if got := h.lockedFor("203.0.113.1", now); got != 0 {
+		t.Fatalf("after success locked for %s", got)
+	}
+}
+
+func TestFailedAttemptsExpireAndStayBounded(t *testing.T) {
+	h := NewHandlerWithoutConfigFilePath(nil, nil)
+	now := time.Now()
+	for i := 0; i < attemptMaxFailures; i++ {
+		h.recordAuthFailure("203.0.113.1", now)
+	}
+	later := now.Add(attemptMaxLockout + attemptMaxIdleTime + time.Minute)
+	if got := h.lockedFor("203.0.113.1", later); got != 0 {
+		t.Fatalf("expired entry locked for %s", got)
+	}
+	// The expired entry starts over, so its next lockout is the base one again.
+	for i := 0; i < attemptMaxFailures; i++ {
+		if lockout := h.recordAuthFailure("203.0.113.1", later); i == attemptMaxFailures-1 && lockout != attemptBaseLockout {
+			t.Fatalf("lockout after expiry = %s, want %s", lockout, attemptBaseLockout)
+		}
+	}
+
+	for i := 0; i < attemptMaxSources+10; i++ {
+		h.recordAuthFailure("198.51.100."+strconv.Itoa(i), later.Add(time.Duration(i)*time.Millisecond))
+	}
+	if n := len(h.failedAttempts); n != attemptMaxSources {
+		t.Fatalf("tracked %d sources, want %d", n, attemptMaxSources)
+	}
+	if got := h.lockedFor("203.0.113.1", later); got == 0 {
+		t.Fatal("a locked-out source was evicted before idle ones")
+	}
+}
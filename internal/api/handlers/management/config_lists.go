@@ -109,14 +109,20 @@ func (h *Handler) GetAPIKeys(c *gin.Context) { c.JSON(200, gin.H{"api-keys": h.c
 func (h *Handler) PutAPIKeys(c *gin.Context) {
 	h.putStringList(c, func(v []string) {
 		h.cfg.APIKeys = append([]string(nil), v...)
-		h.cfg.Access.Providers = nil
+		h.dropInlineAPIKeyProviders()
 	}, nil)
 }
 func (h *Handler) PatchAPIKeys(c *gin.Context) {
-	h.patchStringList(c, &h.cfg.APIKeys, func() { h.cfg.Access.Providers = nil })
+	h.patchStringList(c, &h.cfg.APIKeys, h.dropInlineAPIKeyProviders)
 }
 func (h *Handler) DeleteAPIKeys(c *gin.Context) {
-	h.deleteFromStringList(c, &h.cfg.APIKeys, func() { h.cfg.Access.Providers = nil })
+	h.deleteFromStringList(c, &h.cfg.APIKeys, h.dropInlineAPIKeyProviders)
+}
+
+// dropInlineAPIKeyProviders removes the config-api-key providers, which would otherwise shadow
+// the edited api-keys, and keeps every other access provider.
+func (h *Handler) dropInlineAPIKeyProviders() {
+	h.cfg.Access.Providers = config.WithoutInlineAPIKeyProviders(h.cfg.Access.Providers)
 }
 
 // gemini-api-key: []GeminiKey
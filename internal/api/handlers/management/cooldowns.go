@@ -0,0 +1,21 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// GetCooldowns lists credentials that are cooling down after upstream 429 responses.
+func (h *Handler) GetCooldowns(c *gin.Context) {
+	if h == nil || h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	cooldowns := h.authManager.Cooldowns()
+	if cooldowns == nil {
+		cooldowns = []coreauth.CooldownEntry{}
+	}
+	c.JSON(http.StatusOK, gin.H{"cooldowns": cooldowns})
+}
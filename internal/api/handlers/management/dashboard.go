@@ -0,0 +1,173 @@
+package management
+
+import (
+	"embed"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+//go:embed dashboard/index.html dashboard/app.js dashboard/app.css
+var dashboardFiles embed.FS
+
+const (
+	dashboardDefaultWindow = 24 * time.Hour
+	dashboardMaxWindow     = 30 * 24 * time.Hour
+	dashboardMinBucket     = time.Minute
+	dashboardMaxBuckets    = 500
+	dashboardDefaultTop    = 10
+	dashboardMaxTop        = 100
+)
+
+// dashboardCSP allows the page nothing beyond its own embedded script, stylesheet and the
+// management API it polls.
+const dashboardCSP = "default-src 'none'; script-src 'self'; style-src 'self'; connect-src 'self'; img-src 'self' data:; frame-ancestors 'none'; base-uri 'none'; form-action 'none'"
+
+var dashboardAssetTypes = map[string]string{
+	"app.js":  "text/javascript; charset=utf-8",
+	"app.css": "text/css; charset=utf-8",
+}
+
+// dashboardAccount is the per-credential row of the dashboard.
+type dashboardAccount struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Provider  string                 `json:"provider"`
+	Status    string                 `json:"status"`
+	Disabled  bool                   `json:"disabled"`
+	Unhealthy bool                   `json:"unhealthy"`
+	Cooldowns int                    `json:"cooldowns"`
+	Usage     usage.AuthUsageSummary `json:"usage"`
+}
+
+// DashboardPage serves the embedded usage dashboard. The page itself is public to anyone who
+// can reach the management API; it asks for the management key and sends it with every
+// data request.
+func (h *Handler) DashboardPage(c *gin.Context) {
+	h.serveDashboardFile(c, "index.html", "text/html; charset=utf-8")
+}
+
+// DashboardAsset serves the dashboard's script and stylesheet.
+func (h *Handler) DashboardAsset(c *gin.Context) {
+	name := c.Param("file")
+	contentType, ok := dashboardAssetTypes[name]
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	h.serveDashboardFile(c, name, contentType)
+}
+
+func (h *Handler) serveDashboardFile(c *gin.Context, name, contentType string) {
+	data, err := dashboardFiles.ReadFile("dashboard/" + name)
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	setDashboardHeaders(c)
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+func setDashboardHeaders(c *gin.Context) {
+	c.Header("Content-Security-Policy", dashboardCSP)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Referrer-Policy", "no-referrer")
+}
+
+// GetDashboardData returns the usage series, top models and keys and, when the auth manager
+// is available, the account list the dashboard renders. Query parameters: window (duration,
+// default 24h), bucket (duration, default window/48) and top (default 10).
+func (h *Handler) GetDashboardData(c *gin.Context) {
+	window, err := dashboardDuration(c.Query("window"), dashboardDefaultWindow)
+	if err != nil || window <= 0 || window > dashboardMaxWindow {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid window"})
+		return
+	}
+	bucket, err := dashboardDuration(c.Query("bucket"), window/48)
+	if err != nil || bucket <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bucket"})
+		return
+	}
+	// Coarsen the buckets rather than reject windows that would need too many of them.
+	bucket = max(bucket.Truncate(time.Minute), dashboardMinBucket, window/dashboardMaxBuckets)
+	top := dashboardDefaultTop
+	if raw := strings.TrimSpace(c.Query("top")); raw != "" {
+		top, err = strconv.Atoi(raw)
+		if err != nil || top <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid top"})
+			return
+		}
+		top = min(top, dashboardMaxTop)
+	}
+
+	stats := h.usageStats
+	if stats == nil {
+		stats = usage.NewRequestStatistics()
+	}
+	now := time.Now().UTC()
+	body := gin.H{
+		"usage":              stats.Dashboard(now, now.Add(-window), bucket, top),
+		"statistics_enabled": usage.StatisticsEnabled(),
+	}
+	if accounts := h.dashboardAccounts(stats); accounts != nil {
+		body["accounts"] = accounts
+		body["transient_retries"] = h.authManager.TransientRetryCounts()
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, body)
+}
+
+// dashboardAccounts summarises the credentials, or returns nil without an auth manager so the
+// page can hide the section.
+func (h *Handler) dashboardAccounts(stats *usage.RequestStatistics) []dashboardAccount {
+	if h == nil || h.authManager == nil {
+		return nil
+	}
+	cooldowns := make(map[string]int)
+	for _, entry := range h.authManager.Cooldowns() {
+		cooldowns[entry.AuthID]++
+	}
+	usageByIndex := stats.AuthUsage()
+	auths := h.authManager.List()
+	out := make([]dashboardAccount, 0, len(auths))
+	for _, auth := range auths {
+		if auth == nil {
+			continue
+		}
+		auth.EnsureIndex()
+		name := auth.Label
+		if email := authEmail(auth); email != "" {
+			name = email
+		}
+		if name == "" {
+			name = auth.ID
+		}
+		out = append(out, dashboardAccount{
+			ID:        auth.ID,
+			Name:      name,
+			Provider:  strings.TrimSpace(auth.Provider),
+			Status:    string(auth.Status),
+			Disabled:  auth.Disabled,
+			Unhealthy: auth.Health.Unhealthy,
+			Cooldowns: cooldowns[auth.ID],
+			Usage:     usageByIndex[auth.Index],
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func dashboardDuration(raw string, fallback time.Duration) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(raw)
+}
@@ -0,0 +1,204 @@
+"use strict";
+
+(function () {
+  var DATA_URL = "/v0/management/dashboard/data";
+  var POLL_MS = 15000;
+  var STORAGE_KEY = "cliproxy-management-key";
+  var timer = null;
+
+  function $(id) { return document.getElementById(id); }
+
+  function fmt(n) { return (n || 0).toLocaleString(); }
+
+  function el(tag, text, cls) {
+    var node = document.createElement(tag);
+    if (text !== undefined) node.textContent = text;
+    if (cls) node.className = cls;
+    return node;
+  }
+
+  function showLogin(message) {
+    stopPolling();
+    $("dashboard").hidden = true;
+    $("controls").hidden = true;
+    $("login").hidden = false;
+    $("login-error").textContent = message || "";
+    $("password").focus();
+  }
+
+  function showDashboard() {
+    $("login").hidden = true;
+    $("dashboard").hidden = false;
+    $("controls").hidden = false;
+  }
+
+  function stopPolling() {
+    if (timer) {
+      clearTimeout(timer);
+      timer = null;
+    }
+  }
+
+  function schedule() {
+    stopPolling();
+    timer = setTimeout(refresh, POLL_MS);
+  }
+
+  function refresh() {
+    var key = sessionStorage.getItem(STORAGE_KEY);
+    if (!key) {
+      showLogin();
+      return;
+    }
+    var url = DATA_URL + "?window=" + encodeURIComponent($("window").value);
+    fetch(url, { headers: { "Authorization": "Bearer " + key }, cache: "no-store", credentials: "omit" })
+      .then(function (resp) {
+        if (resp.status === 401) {
+          sessionStorage.removeItem(STORAGE_KEY);
+          showLogin("The management key was rejected.");
+          return null;
+        }
+        if (resp.status === 429) {
+          var retry = resp.headers.get("Retry-After");
+          sessionStorage.removeItem(STORAGE_KEY);
+          showLogin("Too many failed attempts. Try again" + (retry ? " in " + retry + " seconds." : " later."));
+          return null;
+        }
+        if (!resp.ok) throw new Error("HTTP " + resp.status);
+        return resp.json();
+      })
+      .then(function (data) {
+        if (!data) return;
+        showDashboard();
+        render(data);
+        schedule();
+      })
+      .catch(function (err) {
+        notice("Could not load usage: " + err.message);
+        schedule();
+      });
+  }
+
+  function notice(message) {
+    var node = $("notice");
+    node.textContent = message || "";
+    node.hidden = !message;
+  }
+
+  function render(data) {
+    var usage = data.usage || {};
+    var totals = usage.totals || {};
+    notice(data.statistics_enabled ? "" : "Usage statistics are disabled; figures only cover what was recorded while they were enabled.");
+    $("total-requests").textContent = fmt(totals.requests);
+    $("total-success").textContent = fmt(totals.success);
+    $("total-failure").textContent = fmt(totals.failure);
+    $("total-tokens").textContent = fmt(totals.tokens);
+    $("tokens-24h").textContent = fmt(totals.last_24h_tokens);
+    $("updated").textContent = "Updated " + new Date(usage.generated_at || Date.now()).toLocaleTimeString();
+    renderChart(usage.series || [], usage.bucket_seconds || 0);
+    renderRows($("models"), usage.models || [], false);
+    renderRows($("keys"), usage.api_keys || [], true);
+    renderAccounts(data.accounts);
+  }
+
+  function renderChart(series, bucketSeconds) {
+    var svg = $("chart");
+    var ns = "http://www.w3.org/2000/svg";
+    while (svg.firstChild) svg.removeChild(svg.firstChild);
+    if (!series.length) return;
+    var peak = 1;
+    series.forEach(function (b) { peak = Math.max(peak, b.requests); });
+    var width = 800 / series.length;
+    series.forEach(function (b, i) {
+      var total = (b.requests / peak) * 190;
+      var failed = (b.failures / peak) * 190;
+      var x = i * width;
+      var ok = document.createElementNS(ns, "rect");
+      ok.setAttribute("class", "ok");
+      ok.setAttribute("x", x);
+      ok.setAttribute("width", Math.max(width - 1, 1));
+      ok.setAttribute("y", 200 - total);
+      ok.setAttribute("height", total - failed);
+      var bad = document.createElementNS(ns, "rect");
+      bad.setAttribute("class", "failed");
+      bad.setAttribute("x", x);
+      bad.setAttribute("width", Math.max(width - 1, 1));
+      bad.setAttribute("y", 200 - failed);
+      bad.setAttribute("height", failed);
+      var title = document.createElementNS(ns, "title");
+      var start = new Date(b.start);
+      var end = new Date(start.getTime() + bucketSeconds * 1000);
+      title.textContent = start.toLocaleString() + " – " + end.toLocaleTimeString() + ": " +
+        fmt(b.requests) + " requests, " + fmt(b.failures) + " failed, " + fmt(b.tokens) + " tokens";
+      ok.appendChild(title);
+      svg.appendChild(ok);
+      svg.appendChild(bad);
+    });
+  }
+
+  function renderRows(table, rows, withDenied) {
+    var body = table.tBodies[0];
+    body.textContent = "";
+    if (!rows.length) {
+      var empty = el("tr");
+      var cell = el("td", "No requests in this window.", "muted");
+      cell.colSpan = withDenied ? 5 : 4;
+      empty.appendChild(cell);
+      body.appendChild(empty);
+      return;
+    }
+    rows.forEach(function (r) {
+      var tr = el("tr");
+      tr.appendChild(el("td", r.name));
+      tr.appendChild(el("td", fmt(r.requests), "num"));
+      tr.appendChild(el("td", fmt(r.failures), r.failures ? "num bad" : "num"));
+      tr.appendChild(el("td", fmt(r.tokens), "num"));
+      if (withDenied) tr.appendChild(el("td", fmt(r.denied), r.denied ? "num bad" : "num"));
+      body.appendChild(tr);
+    });
+  }
+
+  function renderAccounts(accounts) {
+    var table = $("accounts");
+    var body = table.tBodies[0];
+    body.textContent = "";
+    var available = Array.isArray(accounts) && accounts.length > 0;
+    table.hidden = !available;
+    $("accounts-empty").hidden = available;
+    if (!available) return;
+    accounts.forEach(function (a) {
+      var usage = a.usage || {};
+      var health = a.disabled ? "disabled" : a.unhealthy ? "unhealthy" : a.cooldowns ? a.cooldowns + " cooling down" : "ok";
+      var tr = el("tr");
+      tr.appendChild(el("td", a.name));
+      tr.appendChild(el("td", a.provider));
+      tr.appendChild(el("td", a.status));
+      tr.appendChild(el("td", health, health === "ok" ? "" : "bad"));
+      tr.appendChild(el("td", fmt(usage.requests), "num"));
+      tr.appendChild(el("td", fmt(usage.last_24h_requests), "num"));
+      tr.appendChild(el("td", fmt(usage.failures), usage.failures ? "num bad" : "num"));
+      body.appendChild(tr);
+    });
+  }
+
+  document.addEventListener("DOMContentLoaded", function () {
+    $("login").addEventListener("submit", function (ev) {
+      ev.preventDefault();
+      var key = $("password").value;
+      if (!key) return;
+      sessionStorage.setItem(STORAGE_KEY, key);
+      $("password").value = "";
+      refresh();
+    });
+    $("logout").addEventListener("click", function () {
+      sessionStorage.removeItem(STORAGE_KEY);
+      showLogin();
+    });
+    $("window").addEventListener("change", refresh);
+    document.addEventListener("visibilitychange", function () {
+      if (document.hidden) stopPolling();
+      else refresh();
+    });
+    refresh();
+  });
+})();
@@ -0,0 +1,110 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestDashboardPageSetsSecurityHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHandlerWithoutConfigFilePath(&config.Config{}, nil)
+	engine := gin.New()
+	engine.GET("/v0/management/dashboard", h.DashboardPage)
+	engine.GET("/v0/management/dashboard/assets/:file", h.DashboardAsset)
+
+	for path, contentType := range map[string]string{
+		"/v0/management/dashboard":                "text/html",
+		"/v0/management/dashboard/assets/app.js":  "text/javascript",
+		"/v0/management/dashboard/assets/app.css": "text/css",
+	} {
+		rr := httptest.NewRecorder()
+		engine.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), contentType) {
+			t.Fatalf("%s: status %d, content type %q", path, rr.Code, rr.Header().Get("Content-Type"))
+		}
+		if rr.Header().Get("Content-Security-Policy") != dashboardCSP || rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Fatalf("%s: missing security headers: %v", path, rr.Header())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	engine.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v0/management/dashboard/assets/index.html", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown asset: status %d, want 404", rr.Code)
+	}
+}
+
+func TestGetDashboardDataBucketsAndRanks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	enabled := usage.StatisticsEnabled()
+	usage.SetStatisticsEnabled(true)
+	defer usage.SetStatisticsEnabled(enabled)
+
+	stats := usage.NewRequestStatistics()
+	now := time.Now()
+	record := func(key, model string, ago time.Duration, failed bool) {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey: key, Model: model, RequestedAt: now.Add(-ago), Failed: failed,
+			Detail: coreusage.Detail{TotalTokens: 10},
+		})
+	}
+	record("sk-client-alpha-0001", "gemini-2.5-pro", 10*time.Minute, false)
+	record("sk-client-alpha-0001", "gemini-2.5-pro", 20*time.Minute, true)
+	record("sk-client-beta-0002", "claude-sonnet", 90*time.Minute, false)
+	record("sk-client-beta-0002", "claude-sonnet", 48*time.Hour, false)
+
+	h := NewHandlerWithoutConfigFilePath(&config.Config{}, nil)
+	h.SetUsageStatistics(stats)
+	engine := gin.New()
+	engine.GET("/data", h.GetDashboardData)
+
+	rr := httptest.NewRecorder()
+	engine.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/data?window=6h&bucket=1h&top=1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rr.Code, rr.Body.String())
+	}
+	var body struct {
+		Usage    usage.DashboardData `json:"usage"`
+		Accounts []dashboardAccount  `json:"accounts"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	data := body.Usage
+	if data.Bucket != 3600 || len(data.Series) < 6 || len(data.Series) > 7 {
+		t.Fatalf("bucket %d with %d intervals", data.Bucket, len(data.Series))
+	}
+	var requests, failures int64
+	for _, b := range data.Series {
+		requests += b.Requests
+		failures += b.Failures
+	}
+	if requests != 3 || failures != 1 || data.Totals.Requests != 4 {
+		t.Fatalf("series counted %d requests and %d failures, totals %+v", requests, failures, data.Totals)
+	}
+	if len(data.Models) != 1 || data.Models[0].Name != "gemini-2.5-pro" || data.Models[0].Requests != 2 {
+		t.Fatalf("models = %+v", data.Models)
+	}
+	if len(data.APIKeys) != 1 || strings.Contains(data.APIKeys[0].Name, "alpha") {
+		t.Fatalf("api keys not ranked and masked: %+v", data.APIKeys)
+	}
+	if body.Accounts != nil {
+		t.Fatalf("accounts reported without an auth manager: %+v", body.Accounts)
+	}
+
+	rr = httptest.NewRecorder()
+	engine.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/data?window=-1h", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("negative window: status %d, want 400", rr.Code)
+	}
+}
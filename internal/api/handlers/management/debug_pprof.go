@@ -0,0 +1,52 @@
+package management
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The net/http/pprof import also registers its handlers on http.DefaultServeMux; no listener
+// in this program serves that mux, so the profiles are only reachable through these handlers.
+
+var processStart = time.Now()
+
+// PprofIndex lists the available runtime profiles.
+func (h *Handler) PprofIndex(c *gin.Context) {
+	pprof.Index(c.Writer, c.Request)
+}
+
+// PprofProfile serves one profile by name: heap, goroutine, allocs, block, mutex and
+// threadcreate snapshots, a CPU profile, an execution trace, the command line or symbols.
+func (h *Handler) PprofProfile(c *gin.Context) {
+	switch name := c.Param("name"); name {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// GetRuntimeStats reports goroutine, scheduler, GC and memory statistics of the process.
+func (h *Handler) GetRuntimeStats(c *gin.Context) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	c.JSON(http.StatusOK, gin.H{
+		"go_version":     runtime.Version(),
+		"uptime_seconds": int64(time.Since(processStart).Seconds()),
+		"goroutines":     runtime.NumGoroutine(),
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+		"num_cpu":        runtime.NumCPU(),
+		"num_gc":         mem.NumGC,
+		"memstats":       mem,
+	})
+}
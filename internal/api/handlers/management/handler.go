@@ -6,7 +6,6 @@ import (
 	"crypto/subtle"
 	"fmt"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -15,6 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
@@ -23,16 +23,26 @@ import (
 
 type attemptInfo struct {
 	count        int
+	lockouts     int // lockouts so far; each one doubles the next
 	blockedUntil time.Time
 	lastActivity time.Time // track last activity for cleanup
 }
 
+// stale reports whether the entry is no longer banned and has been idle beyond
+// attemptMaxIdleTime, so its failures and lockouts no longer count.
+func (ai *attemptInfo) stale(now time.Time) bool {
+	return !now.Before(ai.blockedUntil) && now.Sub(ai.lastActivity) > attemptMaxIdleTime
+}
+
 // attemptCleanupInterval controls how often stale IP entries are purged
 const attemptCleanupInterval = 1 * time.Hour
 
 // attemptMaxIdleTime controls how long an IP can be idle before cleanup
 const attemptMaxIdleTime = 2 * time.Hour
 
+// attemptMaxSources caps how many IPs failedAttempts tracks at once
+const attemptMaxSources = 10000
+
 // Handler aggregates config reference, persistence path and helpers.
 type Handler struct {
 	cfg                 *config.Config
@@ -47,12 +57,15 @@ type Handler struct {
 	allowRemoteOverride bool
 	envSecret           string
 	logDir              string
+	keepAliveStatus     func() KeepAliveStatus
 }
 
 // NewHandler creates a new management handler instance.
 func NewHandler(cfg *config.Config, configFilePath string, manager *coreauth.Manager) *Handler {
-	envSecret, _ := os.LookupEnv("MANAGEMENT_PASSWORD")
-	envSecret = strings.TrimSpace(envSecret)
+	envSecret, _, errSecret := config.LookupSecretEnv("MANAGEMENT_PASSWORD")
+	if errSecret != nil {
+		log.Errorf("management: %v; remote access with the management password is disabled", errSecret)
+	}
 
 	h := &Handler{
 		cfg:                 cfg,
@@ -83,16 +96,14 @@ func (h *Handler) startAttemptCleanup() {
 // purgeStaleAttempts removes IP entries that have been idle beyond attemptMaxIdleTime
 // and whose ban (if any) has expired.
 func (h *Handler) purgeStaleAttempts() {
-	now := time.Now()
 	h.attemptsMu.Lock()
 	defer h.attemptsMu.Unlock()
+	h.purgeStaleAttemptsLocked(time.Now())
+}
+
+func (h *Handler) purgeStaleAttemptsLocked(now time.Time) {
 	for ip, ai := range h.failedAttempts {
-		// Skip if still banned
-		if !ai.blockedUntil.IsZero() && now.Before(ai.blockedUntil) {
-			continue
-		}
-		// Remove if idle too long
-		if now.Sub(ai.lastActivity) > attemptMaxIdleTime {
+		if ai.stale(now) {
 			delete(h.failedAttempts, ip)
 		}
 	}
@@ -131,16 +142,15 @@ func (h *Handler) SetLogDirectory(dir string) {
 // Middleware enforces access control for management endpoints.
 // All requests (local and remote) require a valid management key.
 // Additionally, remote access requires allow-remote-management=true.
+// Every source, local ones included, is locked out after repeated failures, and each
+// authentication outcome is logged with the source address and endpoint.
 func (h *Handler) Middleware() gin.HandlerFunc {
-	const maxFailures = 5
-	const banDuration = 30 * time.Minute
-
 	return func(c *gin.Context) {
 		c.Header("X-CPA-VERSION", buildinfo.Version)
 		c.Header("X-CPA-COMMIT", buildinfo.Commit)
 		c.Header("X-CPA-BUILD-DATE", buildinfo.BuildDate)
 
-		clientIP := c.ClientIP()
+		clientIP := managementSource(c)
 		localClient := clientIP == "127.0.0.1" || clientIP == "::1"
 		cfg := h.cfg
 		var (
@@ -156,45 +166,28 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 		}
 		envSecret := h.envSecret
 
-		fail := func() {}
-		if !localClient {
-			h.attemptsMu.Lock()
-			ai := h.failedAttempts[clientIP]
-			if ai != nil {
-				if !ai.blockedUntil.IsZero() {
-					if time.Now().Before(ai.blockedUntil) {
-						remaining := time.Until(ai.blockedUntil).Round(time.Second)
-						h.attemptsMu.Unlock()
-						c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("IP banned due to too many failed attempts. Try again in %s", remaining)})
-						return
-					}
-					// Ban expired, reset state
-					ai.blockedUntil = time.Time{}
-					ai.count = 0
-				}
-			}
-			h.attemptsMu.Unlock()
-
-			if !allowRemote {
-				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "remote management disabled"})
-				return
+		if remaining := h.lockedFor(clientIP, time.Now()); remaining > 0 {
+			auditAuth(c, clientIP, false, "source is locked out")
+			abortLockedOut(c, remaining)
+			return
+		}
+		succeed := func(detail string) {
+			h.recordAuthSuccess(clientIP)
+			auditAuth(c, clientIP, true, detail)
+			c.Next()
+		}
+		fail := func(status int, message string) {
+			auditAuth(c, clientIP, false, message)
+			if lockout := h.recordAuthFailure(clientIP, time.Now()); lockout > 0 {
+				log.Warnf("management: locking out %s for %s after %d failed attempts", clientIP, lockout, attemptMaxFailures)
 			}
+			c.AbortWithStatusJSON(status, gin.H{"error": message})
+		}
 
-			fail = func() {
-				h.attemptsMu.Lock()
-				aip := h.failedAttempts[clientIP]
-				if aip == nil {
-					aip = &attemptInfo{}
-					h.failedAttempts[clientIP] = aip
-				}
-				aip.count++
-				aip.lastActivity = time.Now()
-				if aip.count >= maxFailures {
-					aip.blockedUntil = time.Now().Add(banDuration)
-					aip.count = 0
-				}
-				h.attemptsMu.Unlock()
-			}
+		if !localClient && !allowRemote {
+			auditAuth(c, clientIP, false, "remote management disabled")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "remote management disabled"})
+			return
 		}
 		if secretHash == "" && envSecret == "" {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "remote management key not set"})
@@ -216,58 +209,57 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 		}
 
 		if provided == "" {
-			if !localClient {
-				fail()
-			}
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing management key"})
+			fail(http.StatusUnauthorized, "missing management key")
 			return
 		}
 
 		if localClient {
 			if lp := h.localPassword; lp != "" {
 				if subtle.ConstantTimeCompare([]byte(provided), []byte(lp)) == 1 {
-					c.Next()
+					succeed("local password")
 					return
 				}
 			}
 		}
 
 		if envSecret != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(envSecret)) == 1 {
-			if !localClient {
-				h.attemptsMu.Lock()
-				if ai := h.failedAttempts[clientIP]; ai != nil {
-					ai.count = 0
-					ai.blockedUntil = time.Time{}
-				}
-				h.attemptsMu.Unlock()
-			}
-			c.Next()
+			succeed("MANAGEMENT_PASSWORD")
 			return
 		}
 
-		if secretHash == "" || bcrypt.CompareHashAndPassword([]byte(secretHash), []byte(provided)) != nil {
-			if !localClient {
-				fail()
+		if scopes := clientKeyScopes(cfg, provided); scopes != nil {
+			if !scopedManagementAllowed(scopes, c) {
+				usage.GetRequestStatistics().RecordDenied(provided)
+				auditAuth(c, clientIP, false, "api key scopes do not allow this request")
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key scopes do not allow this request"})
+				return
 			}
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid management key"})
+			c.Set(scopedAPIKeyContextKey, provided)
+			succeed("scoped api key")
 			return
 		}
 
-		if !localClient {
-			h.attemptsMu.Lock()
-			if ai := h.failedAttempts[clientIP]; ai != nil {
-				ai.count = 0
-				ai.blockedUntil = time.Time{}
-			}
-			h.attemptsMu.Unlock()
+		if secretHash == "" || bcrypt.CompareHashAndPassword([]byte(secretHash), []byte(provided)) != nil {
+			fail(http.StatusUnauthorized, "invalid management key")
+			return
 		}
 
-		c.Next()
+		succeed("management key")
 	}
 }
 
 // persist saves the current in-memory config to disk.
 func (h *Handler) persist(c *gin.Context) bool {
+	if !h.saveConfig(c) {
+		return false
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	return true
+}
+
+// saveConfig writes the configuration back to disk, answering 500 and reporting false when
+// that fails; the caller writes the success response.
+func (h *Handler) saveConfig(c *gin.Context) bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	// Preserve comments when writing
@@ -275,7 +267,6 @@ func (h *Handler) persist(c *gin.Context) bool {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to save config: %v", err)})
 		return false
 	}
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	return true
 }
 
@@ -0,0 +1,29 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// GetAuthHealth reports background health probe state for every credential.
+func (h *Handler) GetAuthHealth(c *gin.Context) {
+	if h == nil || h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	auths := h.authManager.HealthStates()
+	if auths == nil {
+		auths = []coreauth.AuthHealth{}
+	}
+	down := h.authManager.ProvidersDown()
+	if down == nil {
+		down = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":        h.cfg != nil && h.cfg.HealthCheck.Enabled,
+		"auths":          auths,
+		"providers_down": down,
+	})
+}
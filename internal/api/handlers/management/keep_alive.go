@@ -0,0 +1,36 @@
+package management
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeepAliveClient is one client registered with the keep-alive endpoint.
+type KeepAliveClient struct {
+	ID          string    `json:"id"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	IdleSeconds float64   `json:"idle_seconds"`
+	Pings       int64     `json:"pings"`
+}
+
+// KeepAliveStatus is the keep-alive watchdog's view of its clients.
+type KeepAliveStatus struct {
+	TimeoutSeconds float64           `json:"timeout_seconds"`
+	Clients        []KeepAliveClient `json:"clients"`
+}
+
+// SetKeepAliveStatus installs the source GetKeepAlive reports; nil means the keep-alive
+// endpoint is disabled.
+func (h *Handler) SetKeepAliveStatus(status func() KeepAliveStatus) { h.keepAliveStatus = status }
+
+// GetKeepAlive lists the clients the keep-alive watchdog is waiting on.
+func (h *Handler) GetKeepAlive(c *gin.Context) {
+	if h.keepAliveStatus == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "keep-alive endpoint is not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, h.keepAliveStatus())
+}
@@ -0,0 +1,44 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// GetAPIKeyQuotas lists every client key with a quota and what remains of it; the global-quota
+// is listed under usage.GlobalQuotaKey.
+func (h *Handler) GetAPIKeyQuotas(c *gin.Context) {
+	statuses := usage.GetKeyQuotaTracker().Statuses(time.Now())
+	if statuses == nil {
+		statuses = []usage.KeyQuotaStatus{}
+	}
+	c.JSON(http.StatusOK, gin.H{"quotas": statuses})
+}
+
+// TopUpAPIKeyQuota grants a key extra tokens for the current day and/or month.
+func (h *Handler) TopUpAPIKeyQuota(c *gin.Context) {
+	var body struct {
+		APIKey        string `json:"api-key"`
+		DailyTokens   int64  `json:"daily-tokens"`
+		MonthlyTokens int64  `json:"monthly-tokens"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.APIKey) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body: api-key is required"})
+		return
+	}
+	if body.DailyTokens < 0 || body.MonthlyTokens < 0 || body.DailyTokens+body.MonthlyTokens == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "daily-tokens or monthly-tokens must be positive"})
+		return
+	}
+	status, ok := usage.GetKeyQuotaTracker().TopUp(strings.TrimSpace(body.APIKey), body.DailyTokens, body.MonthlyTokens, time.Now())
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key has no quota"})
+		return
+	}
+	usage.RequestPersistenceSave()
+	c.JSON(http.StatusOK, status)
+}
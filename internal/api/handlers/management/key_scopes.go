@@ -0,0 +1,68 @@
+package management
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// clientKeyScopes returns the scopes of provided when it is one of the client api-keys and
+// has scopes configured. Unscoped, disabled and expired client keys have no management access.
+func clientKeyScopes(cfg *config.Config, provided string) *config.APIKeyScopes {
+	if cfg == nil {
+		return nil
+	}
+	for _, key := range cfg.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(provided)) == 1 {
+			settings := cfg.APIKeySettingsFor(key)
+			if settings.State(time.Now()) != config.APIKeyStateActive {
+				return nil
+			}
+			return cfg.APIKeyScopesFor(key)
+		}
+	}
+	return nil
+}
+
+// scopedAPIKeyContextKey is the Gin context key under which the middleware stores the client
+// key of a scoped management request, so the usage handlers report on that key only.
+const scopedAPIKeyContextKey = "managementScopedAPIKey"
+
+// scopedUsageRoutes are the management routes a client key with the usage scope may read.
+// The full export is not among them: it is a backup of every key's statistics.
+var scopedUsageRoutes = []string{"/usage", "/usage/models", "/usage/keys", "/usage/persistence"}
+
+// scopedStatusRoutes are the management routes a client key with management-read may read. They
+// report state only and return no configuration, credentials, logs or request bodies.
+var scopedStatusRoutes = []string{"/health", "/cooldowns", "/accounts", "/transports", "/keep-alive", "/latest-version"}
+
+// scopedManagementAllowed reports whether a scoped client key may make the request. Only GET
+// requests to the listed routes are allowed: the usage endpoints with the usage scope, and the
+// status endpoints with management-read.
+func scopedManagementAllowed(scopes *config.APIKeyScopes, c *gin.Context) bool {
+	if c.Request.Method != http.MethodGet {
+		return false
+	}
+	path := strings.TrimSuffix(strings.TrimPrefix(c.Request.URL.Path, "/v0/management"), "/")
+	switch {
+	case slices.Contains(scopedUsageRoutes, path):
+		return scopes.Usage
+	case slices.Contains(scopedStatusRoutes, path):
+		return scopes.AllowsRoute(config.ScopeManagementRead)
+	default:
+		return false
+	}
+}
+
+// scopedAPIKey returns the client key of a scoped management request, or "" for the
+// management key.
+func scopedAPIKey(c *gin.Context) string {
+	key, _ := c.Get(scopedAPIKeyContextKey)
+	s, _ := key.(string)
+	return s
+}
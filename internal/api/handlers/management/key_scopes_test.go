@@ -0,0 +1,107 @@
+package management
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestScopedKeyManagementAllowlist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	cfg.APIKeys = []string{"reader-key"}
+	cfg.APIKeySettings = []config.APIKeySettings{{
+		APIKey: "reader-key",
+		Scopes: &config.APIKeyScopes{Routes: []string{config.ScopeManagementRead}, Usage: true},
+	}}
+	h := NewHandlerWithoutConfigFilePath(cfg, nil)
+	h.envSecret = "env-secret"
+
+	engine := gin.New()
+	engine.Use(h.Middleware())
+	engine.Any("/v0/management/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+	request := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "127.0.0.1:4000"
+		req.Header.Set("Authorization", "Bearer reader-key")
+		rr := httptest.NewRecorder()
+		engine.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for _, path := range []string{"/v0/management/usage", "/v0/management/usage/keys", "/v0/management/health", "/v0/management/accounts"} {
+		if code := request(http.MethodGet, path); code != http.StatusOK {
+			t.Fatalf("GET %s: status %d, want 200", path, code)
+		}
+	}
+	for _, path := range []string{
+		"/v0/management/auth-files/download?name=a.json",
+		"/v0/management/openai-compatibility",
+		"/v0/management/ampcode",
+		"/v0/management/ampcode/upstream-api-key",
+		"/v0/management/request-log-by-id/abc",
+		"/v0/management/request-error-logs",
+		"/v0/management/debug/pprof/",
+		"/v0/management/codex-auth-url",
+		"/v0/management/config",
+		"/v0/management/api-keys",
+		"/v0/management/usage/export",
+	} {
+		if code := request(http.MethodGet, path); code != http.StatusForbidden {
+			t.Fatalf("GET %s: status %d, want 403", path, code)
+		}
+	}
+	if code := request(http.MethodDelete, "/v0/management/usage"); code != http.StatusForbidden {
+		t.Fatalf("DELETE /usage: status %d, want 403", code)
+	}
+}
+
+func TestScopedKeySeesOnlyItsOwnUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	enabled := usage.StatisticsEnabled()
+	usage.SetStatisticsEnabled(true)
+	defer usage.SetStatisticsEnabled(enabled)
+
+	cfg := &config.Config{}
+	cfg.APIKeys = []string{"reader-key", "other-secret-key"}
+	cfg.APIKeySettings = []config.APIKeySettings{{APIKey: "reader-key", Scopes: &config.APIKeyScopes{Usage: true}}}
+	stats := usage.NewRequestStatistics()
+	for _, key := range []string{"reader-key", "other-secret-key", "other-secret-key"} {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey: key, Model: "gpt-5", RequestedAt: time.Now(), Detail: coreusage.Detail{TotalTokens: 10},
+		})
+	}
+	h := NewHandlerWithoutConfigFilePath(cfg, nil)
+	h.envSecret = "env-secret"
+	h.SetUsageStatistics(stats)
+
+	engine := gin.New()
+	mgmt := engine.Group("/v0/management", h.Middleware())
+	mgmt.GET("/usage", h.GetUsageStatistics)
+	mgmt.GET("/usage/keys", h.GetUsageKeys)
+	for _, path := range []string{"/v0/management/usage", "/v0/management/usage/keys"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "127.0.0.1:4000"
+		req.Header.Set("Authorization", "Bearer reader-key")
+		rr := httptest.NewRecorder()
+		engine.ServeHTTP(rr, req)
+		body := rr.Body.String()
+		if rr.Code != http.StatusOK || !strings.Contains(body, "reader-key") {
+			t.Fatalf("GET %s: status %d, body %s", path, rr.Code, body)
+		}
+		if strings.Contains(body, "other-secret-key") {
+			t.Fatalf("GET %s leaks another client key: %s", path, body)
+		}
+		if path == "/v0/management/usage" && !strings.Contains(body, `"total_requests":1,`) {
+			t.Fatalf("GET %s: totals should cover the caller's one request: %s", path, body)
+		}
+	}
+}
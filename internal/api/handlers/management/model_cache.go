@@ -0,0 +1,14 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+// DeleteModelCache drops every cached upstream model list, so the next registration of each
+// credential fetches its list from the provider again.
+func (h *Handler) DeleteModelCache(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"cleared": registry.UpstreamModelCache().InvalidateAll()})
+}
@@ -0,0 +1,19 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/notify"
+)
+
+// PostNotifyTest sends a test notification to every configured webhook and reports, per
+// webhook, whether it was accepted.
+func (h *Handler) PostNotifyTest(c *gin.Context) {
+	results := notify.Default().SendTest(c.Request.Context())
+	if len(results) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no notification webhooks configured"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
@@ -0,0 +1,13 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// GetTransportStats reports connection pool usage for each shared upstream transport.
+func (h *Handler) GetTransportStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"transports": util.TransportStats()})
+}
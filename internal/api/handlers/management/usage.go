@@ -2,10 +2,13 @@ package management
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 )
 
@@ -20,16 +23,105 @@ type usageImportPayload struct {
 	Usage   usage.StatisticsSnapshot `json:"usage"`
 }
 
-// GetUsageStatistics returns the in-memory request statistics snapshot.
+// GetUsageStatistics returns the in-memory request statistics snapshot. Each label query
+// parameter, written name=value, narrows it to the requests carrying that usage label. With
+// model-pricing configured the snapshot includes estimated costs.
 func (h *Handler) GetUsageStatistics(c *gin.Context) {
+	snapshot, ok := h.usageSnapshot(c)
+	if !ok {
+		return
+	}
+	body := gin.H{
+		"usage":           snapshot,
+		"failed_requests": snapshot.FailureCount,
+	}
+	if h != nil && h.authManager != nil {
+		body["transient_retries"] = h.authManager.TransientRetryCounts()
+	}
+	if persistence := usage.CurrentPersistenceStatus(); persistence.Enabled {
+		body["last_save_error"] = persistence.LastSaveError
+		body["last_successful_save"] = persistence.LastSave
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// GetUsageModels returns the models of the usage snapshot summed over API keys, busiest
+// first. It takes the label parameters of GetUsageStatistics.
+func (h *Handler) GetUsageModels(c *gin.Context) {
+	snapshot, ok := h.usageSnapshot(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"models": snapshot.ModelBreakdown()})
+}
+
+// GetUsageKeys returns the API keys of the usage snapshot with their models, busiest first.
+// It takes the label parameters of GetUsageStatistics.
+func (h *Handler) GetUsageKeys(c *gin.Context) {
+	snapshot, ok := h.usageSnapshot(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": snapshot.KeyBreakdown()})
+}
+
+// DeleteUsageStatistics resets the usage counters and details of this process and, when
+// usage persistence is enabled, empties its journal and saves the empty statistics, so
+// neither a crash nor a restart brings them back. Quota counters are kept.
+func (h *Handler) DeleteUsageStatistics(c *gin.Context) {
+	if h == nil || h.usageStats == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "usage statistics unavailable"})
+		return
+	}
+	cleared := usage.ResetStatistics(h.usageStats)
+	log.Infof("management: usage statistics reset, %d requests cleared", cleared)
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "cleared_requests": cleared})
+}
+
+// usageSnapshot returns the usage snapshot narrowed by the label query parameters and, with
+// model-pricing configured, with estimated costs. A scoped client key sees its own usage
+// only. It answers a bad filter itself.
+func (h *Handler) usageSnapshot(c *gin.Context) (usage.StatisticsSnapshot, bool) {
+	filter, err := usageLabelFilter(c.QueryArray("label"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return usage.StatisticsSnapshot{}, false
+	}
 	var snapshot usage.StatisticsSnapshot
 	if h != nil && h.usageStats != nil {
 		snapshot = h.usageStats.Snapshot()
+		if key := scopedAPIKey(c); key != "" {
+			snapshot = snapshot.ForAPIKey(key)
+		}
+		snapshot = snapshot.FilterByLabels(filter)
+		if h.cfg != nil && len(h.cfg.ModelPricing) > 0 {
+			snapshot = snapshot.WithEstimatedCosts(h.cfg.PriceFor)
+		}
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"usage":           snapshot,
-		"failed_requests": snapshot.FailureCount,
-	})
+	return snapshot, true
+}
+
+// usageLabelFilter parses label query parameters of the form name=value.
+func usageLabelFilter(params []string) (map[string]string, error) {
+	if len(params) == 0 {
+		return nil, nil
+	}
+	filter := make(map[string]string, len(params))
+	for _, param := range params {
+		name, value, ok := strings.Cut(param, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("invalid label filter %q: use name=value", param)
+		}
+		filter[name] = value
+	}
+	return filter, nil
+}
+
+// GetUsagePersistence reports where usage statistics are saved and, with leader election,
+// which replica currently saves them.
+func (h *Handler) GetUsagePersistence(c *gin.Context) {
+	c.JSON(http.StatusOK, usage.CurrentPersistenceStatus())
 }
 
 // ExportUsageStatistics returns a complete usage snapshot for backup/migration.
@@ -0,0 +1,72 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestUsageBreakdownsAndReset(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	enabled := usage.StatisticsEnabled()
+	usage.SetStatisticsEnabled(true)
+	defer usage.SetStatisticsEnabled(enabled)
+
+	stats := usage.NewRequestStatistics()
+	for _, r := range []struct {
+		key, model string
+		failed     bool
+	}{{"alice", "gpt-5", false}, {"alice", "gpt-5", true}, {"alice", "claude", false}, {"bob", "gpt-5", false}} {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey: r.key, Model: r.model, RequestedAt: time.Now(), Failed: r.failed,
+			Detail: coreusage.Detail{TotalTokens: 10},
+		})
+	}
+	h := NewHandlerWithoutConfigFilePath(&config.Config{}, nil)
+	h.SetUsageStatistics(stats)
+	engine := gin.New()
+	engine.GET("/usage/models", h.GetUsageModels)
+	engine.GET("/usage/keys", h.GetUsageKeys)
+	engine.DELETE("/usage", h.DeleteUsageStatistics)
+	call := func(method, path string, out any) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		engine.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d: %s", method, path, rr.Code, rr.Body.String())
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), out); err != nil {
+			t.Fatalf("decode %s: %v", path, err)
+		}
+	}
+
+	var models struct{ Models []usage.ModelUsage }
+	call(http.MethodGet, "/usage/models", &models)
+	if len(models.Models) != 2 || models.Models[0].Model != "gpt-5" || models.Models[0].TotalRequests != 3 ||
+		models.Models[0].FailureCount != 1 || models.Models[0].APIKeys != 2 {
+		t.Fatalf("models = %+v, want gpt-5 first with 3 requests from 2 keys", models.Models)
+	}
+	var keys struct {
+		APIKeys []usage.KeyUsage `json:"api_keys"`
+	}
+	call(http.MethodGet, "/usage/keys", &keys)
+	if len(keys.APIKeys) != 2 || keys.APIKeys[0].APIKey != "alice" || keys.APIKeys[0].TotalRequests != 3 || len(keys.APIKeys[0].Models) != 2 {
+		t.Fatalf("keys = %+v, want alice first with 3 requests over 2 models", keys.APIKeys)
+	}
+
+	var reset struct {
+		Cleared int64 `json:"cleared_requests"`
+	}
+	call(http.MethodDelete, "/usage", &reset)
+	if reset.Cleared != 4 || stats.Snapshot().TotalRequests != 0 {
+		t.Fatalf("reset cleared %d requests, %d left; want 4 and none", reset.Cleared, stats.Snapshot().TotalRequests)
+	}
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// keepAliveDefaultClient is the id of clients that ping without naming themselves, so a
+// single unnamed client behaves as before client ids existed.
+const keepAliveDefaultClient = "default"
+
+// keepAliveClientHeader and keepAliveClientQuery carry the client id; the header wins.
+const (
+	keepAliveClientHeader = "X-Keep-Alive-Client"
+	keepAliveClientQuery  = "client_id"
+)
+
+type keepAliveClient struct {
+	firstSeen time.Time
+	lastSeen  time.Time
+	pings     int64
+}
+
+// keepAliveWatchdog calls onTimeout once every client of the keep-alive endpoint has gone:
+// either all of them stayed silent for longer than timeout, or the last one deregistered.
+// Until a first client pings, the watchdog waits timeout from its start. A client silent
+// past the timeout is dropped and has to ping again to count.
+type keepAliveWatchdog struct {
+	timeout   time.Duration
+	onTimeout func()
+
+	mu       sync.Mutex
+	started  time.Time
+	clients  map[string]*keepAliveClient
+	lastLeft string
+	left     bool
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newKeepAliveWatchdog(timeout time.Duration, onTimeout func()) *keepAliveWatchdog {
+	return &keepAliveWatchdog{
+		timeout:   timeout,
+		onTimeout: onTimeout,
+		started:   time.Now(),
+		clients:   make(map[string]*keepAliveClient),
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+	}
+}
+
+// ping records a heartbeat from client id.
+func (w *keepAliveWatchdog) ping(id string) {
+	now := time.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pruneLocked(now)
+	client := w.clients[id]
+	if client == nil {
+		client = &keepAliveClient{firstSeen: now}
+		w.clients[id] = client
+		log.Infof("keep-alive: client %s registered (%d active)", id, len(w.clients))
+	}
+	client.lastSeen = now
+	client.pings++
+	w.left = false
+}
+
+// leave deregisters client id, reporting whether it was registered and how many clients
+// remain. When none remain the watchdog fires straight away.
+func (w *keepAliveWatchdog) leave(id string) (bool, int) {
+	w.mu.Lock()
+	w.pruneLocked(time.Now())
+	if _, ok := w.clients[id]; !ok {
+		remaining := len(w.clients)
+		w.mu.Unlock()
+		return false, remaining
+	}
+	delete(w.clients, id)
+	remaining := len(w.clients)
+	if remaining == 0 {
+		w.left, w.lastLeft = true, id
+	}
+	w.mu.Unlock()
+
+	log.Infof("keep-alive: client %s deregistered (%d active)", id, remaining)
+	if remaining == 0 {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+	return true, remaining
+}
+
+// pruneLocked drops the clients silent for longer than the timeout.
+func (w *keepAliveWatchdog) pruneLocked(now time.Time) {
+	for id, client := range w.clients {
+		if now.Sub(client.lastSeen) > w.timeout {
+			delete(w.clients, id)
+			log.Infof("keep-alive: client %s silent for %s, dropped", id, now.Sub(client.lastSeen).Round(time.Millisecond))
+		}
+	}
+}
+
+// snapshot lists the active clients by id.
+func (w *keepAliveWatchdog) snapshot() management.KeepAliveStatus {
+	now := time.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pruneLocked(now)
+	status := management.KeepAliveStatus{
+		TimeoutSeconds: w.timeout.Seconds(),
+		Clients:        make([]management.KeepAliveClient, 0, len(w.clients)),
+	}
+	for id, client := range w.clients {
+		status.Clients = append(status.Clients, management.KeepAliveClient{
+			ID:          id,
+			FirstSeen:   client.firstSeen,
+			LastSeen:    client.lastSeen,
+			IdleSeconds: now.Sub(client.lastSeen).Seconds(),
+			Pings:       client.pings,
+		})
+	}
+	sort.Slice(status.Clients, func(i, j int) bool { return status.Clients[i].ID < status.Clients[j].ID })
+	return status
+}
+
+// run waits for the last client to go and then calls onTimeout, unless stopped first.
+func (w *keepAliveWatchdog) run() {
+	timer := time.NewTimer(w.timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.wake:
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		w.mu.Lock()
+		left, lastLeft := w.left, w.lastLeft
+		lastSeen := w.started
+		for _, client := range w.clients {
+			if client.lastSeen.After(lastSeen) {
+				lastSeen = client.lastSeen
+			}
+		}
+		w.mu.Unlock()
+
+		idle := now.Sub(lastSeen)
+		switch {
+		case left:
+			log.Warnf("keep-alive: last client %s deregistered, shutting down", lastLeft)
+		case idle >= w.timeout:
+			log.Warnf("keep-alive: no client pinged for %s, shutting down", w.timeout)
+		default:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(w.timeout - idle)
+			continue
+		}
+		w.onTimeout()
+		return
+	}
+}
+
+// Stop ends the watchdog without calling onTimeout. It is safe on a nil watchdog.
+func (w *keepAliveWatchdog) Stop() {
+	if w == nil {
+		return
+	}
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+func (s *Server) enableKeepAlive(timeout time.Duration, onTimeout func()) {
+	if timeout <= 0 || onTimeout == nil {
+		return
+	}
+
+	s.keepAlive = newKeepAliveWatchdog(timeout, onTimeout)
+	s.mgmt.SetKeepAliveStatus(s.keepAlive.snapshot)
+
+	s.engine.GET("/keep-alive", s.handleKeepAlive)
+	s.engine.DELETE("/keep-alive", s.handleKeepAliveLeave)
+
+	go s.keepAlive.run()
+}
+
+// handleKeepAlive records a heartbeat from the calling client.
+func (s *Server) handleKeepAlive(c *gin.Context) {
+	if !s.checkKeepAlivePassword(c) {
+		return
+	}
+	s.keepAlive.ping(keepAliveClientID(c))
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleKeepAliveLeave deregisters the calling client, which signals an intentional exit.
+func (s *Server) handleKeepAliveLeave(c *gin.Context) {
+	if !s.checkKeepAlivePassword(c) {
+		return
+	}
+	id := keepAliveClientID(c)
+	known, remaining := s.keepAlive.leave(id)
+	if !known {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown keep-alive client", "client_id": id})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "remaining": remaining})
+}
+
+func keepAliveClientID(c *gin.Context) string {
+	if id := strings.TrimSpace(c.GetHeader(keepAliveClientHeader)); id != "" {
+		return id
+	}
+	if id := strings.TrimSpace(c.Query(keepAliveClientQuery)); id != "" {
+		return id
+	}
+	return keepAliveDefaultClient
+}
+
+// checkKeepAlivePassword rejects the request unless it carries the local management
+// password, when one is set.
+func (s *Server) checkKeepAlivePassword(c *gin.Context) bool {
+	if s.localPassword == "" {
+		return true
+	}
+	provided := strings.TrimSpace(c.GetHeader("Authorization"))
+	if provided != "" {
+		parts := strings.SplitN(provided, " ", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "bearer") {
+			provided = parts[1]
+		}
+	}
+	if provided == "" {
+		provided = strings.TrimSpace(c.GetHeader("X-Local-Password"))
+	}
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(s.localPassword)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid password"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
+	proxyconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestKeepAliveWaitsForEveryClient(t *testing.T) {
+	const timeout = 150 * time.Millisecond
+	fired := make(chan time.Time, 1)
+	hash, err := bcrypt.GenerateFromPassword([]byte("mgmt-secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+	cfg := &proxyconfig.Config{AuthDir: t.TempDir(), RemoteManagement: proxyconfig.RemoteManagement{SecretKey: string(hash)}}
+	server := NewServer(cfg, auth.NewManager(nil, nil, nil), sdkaccess.NewManager(), filepath.Join(t.TempDir(), "config.yaml"),
+		WithLocalManagementPassword("local-pass"),
+		WithKeepAliveEndpoint(timeout, func() { fired <- time.Now() }))
+	defer server.keepAlive.Stop()
+
+	call := func(method, path string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "127.0.0.1:40000"
+		req.Header.Set("Authorization", "Bearer local-pass")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		server.engine.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := call(http.MethodGet, "/keep-alive?client_id=launcher", nil); rr.Code != http.StatusOK {
+		t.Fatalf("launcher ping: status %d", rr.Code)
+	}
+	// The monitor keeps pinging well past the timeout; the launcher's silence alone does
+	// not shut anything down.
+	for i := 0; i < 6; i++ {
+		if rr := call(http.MethodGet, "/keep-alive", map[string]string{keepAliveClientHeader: "monitor"}); rr.Code != http.StatusOK {
+			t.Fatalf("monitor ping: status %d", rr.Code)
+		}
+		select {
+		case <-fired:
+			t.Fatal("watchdog fired while the monitor was still pinging")
+		case <-time.After(timeout / 3):
+		}
+	}
+
+	rr := call(http.MethodGet, "/v0/management/keep-alive", nil)
+	var status management.KeepAliveStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("management keep-alive: status %d, body %s", rr.Code, rr.Body.String())
+	}
+	if len(status.Clients) != 1 || status.Clients[0].ID != "monitor" || status.Clients[0].Pings != 6 {
+		t.Fatalf("clients = %+v, want only the monitor with 6 pings", status.Clients)
+	}
+
+	if rr := call(http.MethodDelete, "/keep-alive?client_id=launcher", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("deleting the dropped launcher: status %d, want 404", rr.Code)
+	}
+	left := time.Now()
+	if rr := call(http.MethodDelete, "/keep-alive?client_id=monitor", nil); rr.Code != http.StatusOK {
+		t.Fatalf("monitor leave: status %d", rr.Code)
+	}
+	select {
+	case at := <-fired:
+		if at.Sub(left) > timeout/2 {
+			t.Fatalf("watchdog fired %s after the last client left, want immediately", at.Sub(left))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watchdog did not fire after the last client left")
+	}
+}
+
+func TestKeepAliveFiresWhenAllClientsGoSilent(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	fired := make(chan struct{})
+	w := newKeepAliveWatchdog(timeout, func() { close(fired) })
+	go w.run()
+	defer w.Stop()
+
+	w.ping("a")
+	w.ping("b")
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("watchdog did not fire once every client was silent")
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// allowedByKeyQuota aborts the request with 429 and reports false when the authenticated key
+// or the global-quota has used up a budget. Requests already in flight when the budget runs
+// out still complete, so usage can end slightly above the cap. Listing models stays possible.
+func (s *Server) allowedByKeyQuota(c *gin.Context) bool {
+	if route, _, _ := classifyScopedRequest(c.Request.Method, c.Request.URL.Path); route == config.ScopeModels {
+		return true
+	}
+	now := time.Now()
+	tracker := usage.GetKeyQuotaTracker()
+	for _, apiKey := range []string{c.GetString("apiKey"), usage.GlobalQuotaKey} {
+		status, ok := tracker.Status(apiKey, now)
+		if !ok {
+			continue
+		}
+		exceeded, resetsAt := status.Exceeded()
+		if !exceeded {
+			continue
+		}
+		whose := "This API key has"
+		if apiKey == usage.GlobalQuotaKey {
+			whose = "This proxy has"
+		}
+		c.Header("Retry-After", strconv.FormatInt(int64(resetsAt.Sub(now).Round(time.Second)/time.Second), 10))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("%s used its quota; it resets at %s.", whose, resetsAt.UTC().Format(time.RFC3339)),
+				Type:    "rate_limit_error",
+				Code:    "quota_exceeded",
+			},
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
+	proxyconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestRotatedKeyKeepsExhaustedQuota(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 8317\napi-keys:\n  - \"quota-rotate-key\"\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := proxyconfig.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.APIKeySettings = []proxyconfig.APIKeySettings{{APIKey: "quota-rotate-key", Quota: &proxyconfig.APIKeyQuota{DailyTokens: 100}}}
+
+	tracker := usage.GetKeyQuotaTracker()
+	tracker.SetLimits(cfg.APIKeySettings)
+	defer tracker.SetLimits(nil)
+	tracker.HandleUsage(context.Background(), coreusage.Record{
+		APIKey:      "quota-rotate-key",
+		RequestedAt: time.Now(),
+		Detail:      coreusage.Detail{InputTokens: 60, OutputTokens: 40, TotalTokens: 100},
+	})
+
+	s := &Server{cfg: cfg}
+	engine := gin.New()
+	engine.POST("/api-keys/rotate", management.NewHandler(cfg, configPath, nil).RotateAPIKey)
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Set("apiKey", strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		if s.allowedByKeyQuota(c) {
+			c.Status(http.StatusOK)
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api-keys/rotate", strings.NewReader(`{"api-key":"quota-rotate-key"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rotate: status %d: %s", rec.Code, rec.Body.String())
+	}
+	var rotated struct {
+		APIKey string `json:"api-key"`
+	}
+	if errDecode := json.Unmarshal(rec.Body.Bytes(), &rotated); errDecode != nil || rotated.APIKey == "" {
+		t.Fatalf("rotate response %s: %v", rec.Body.String(), errDecode)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-5"}`))
+	req.Header.Set("Authorization", "Bearer "+rotated.APIKey)
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("new key after rotation: status %d, want 429: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+// chatRouteSuffixes are the request paths, after any provider prefix, that generate content.
+var chatRouteSuffixes = []string{
+	"/chat/completions",
+	"/completions",
+	"/messages",
+	"/messages/count_tokens",
+	"/responses",
+	"/responses/compact",
+	"/tokenize",
+}
+
+// clientAuthMiddleware refuses client requests from sources api-access does not allow,
+// authenticates the rest by client certificate or like AuthMiddleware, and then refuses
+// those made with a disabled or expired key, from a source the key may not be used from,
+// outside the key's scopes or over its quota, before any handler runs. Scopes are read from
+// the current configuration on every request, so edits take effect on reload. Requests let
+// through carry their usage labels.
+func (s *Server) clientAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.allowedBySource(c) {
+			return
+		}
+		if !s.authenticateClientCertificate(c) && !authenticateClient(c, s.accessManager) {
+			return
+		}
+		if !s.allowedByKeyState(c) || !s.allowedByKeySource(c) || !s.allowedByKeyScopes(c) || !s.allowedByKeyQuota(c) {
+			return
+		}
+		s.attachUsageLabels(c)
+		c.Next()
+	}
+}
+
+// allowedByKeyState aborts the request with 401 and reports false when the authenticated key
+// is disabled or has expired.
+func (s *Server) allowedByKeyState(c *gin.Context) bool {
+	apiKey := c.GetString("apiKey")
+	state := s.cfg.APIKeySettingsFor(apiKey).State(time.Now())
+	if state == config.APIKeyStateActive {
+		return true
+	}
+	usage.GetRequestStatistics().RecordDenied(apiKey)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: fmt.Sprintf("This API key is %s.", state),
+			Type:    "authentication_error",
+			Code:    "api_key_" + state,
+		},
+	})
+	return false
+}
+
+// allowedByKeyScopes aborts the request with 403 and reports false when the authenticated
+// key may not call the route, use the requested model or reach any provider serving it. Keys
+// scoped to providers also have them stored for the handlers, which dispatch the request to
+// those providers only.
+func (s *Server) allowedByKeyScopes(c *gin.Context) bool {
+	apiKey := c.GetString("apiKey")
+	scopes := s.cfg.APIKeyScopesFor(apiKey)
+	if scopes == nil {
+		return true
+	}
+	route, model, hasModel := classifyScopedRequest(c.Request.Method, c.Request.URL.Path)
+	if route == "" || !scopes.AllowsRoute(route) {
+		denyScope(c, apiKey, fmt.Sprintf("This API key is not allowed to call %s %s.", c.Request.Method, c.Request.URL.Path))
+		return false
+	}
+	if len(scopes.Providers) > 0 {
+		c.Set(handlers.AllowedProvidersContextKey, scopes.Providers)
+	}
+	if (len(scopes.Models) == 0 && len(scopes.Providers) == 0) || (route == config.ScopeModels && !hasModel) {
+		return true
+	}
+	if !hasModel {
+		var ok bool
+		if model, ok = requestBodyModel(c); !ok {
+			return false
+		}
+	}
+	if !scopes.AllowsModel(model) {
+		denyScope(c, apiKey, fmt.Sprintf("This API key is not allowed to use model %q.", model))
+		return false
+	}
+	if route != config.ScopeModels && !servedByAllowedProvider(scopes, model) {
+		denyScope(c, apiKey, fmt.Sprintf("This API key is not allowed to use the providers serving model %q.", model))
+		return false
+	}
+	return true
+}
+
+// servedByAllowedProvider reports whether a provider the key may use serves model. Models no
+// provider is registered for, such as "auto", are left to the handler to resolve.
+func servedByAllowedProvider(scopes *config.APIKeyScopes, model string) bool {
+	if len(scopes.Providers) == 0 {
+		return true
+	}
+	providers := util.GetProviderName(thinking.ParseSuffix(model).ModelName)
+	if len(providers) == 0 {
+		providers = util.GetProviderName(model)
+	}
+	return len(providers) == 0 || slices.ContainsFunc(providers, scopes.AllowsProvider)
+}
+
+// classifyScopedRequest returns the route scope of a request and, for Gemini-style paths that
+// name the model, the model. An empty scope means the route belongs to no scope.
+func classifyScopedRequest(method, path string) (scope string, model string, hasModel bool) {
+	path = strings.TrimRight(path, "/")
+	if _, action, ok := cutLast(path, "/models/"); ok {
+		model, verb, hasVerb := strings.Cut(action, ":")
+		model = strings.TrimPrefix(model, "models/")
+		if !hasVerb {
+			if method == http.MethodGet {
+				return config.ScopeModels, model, true
+			}
+			return "", model, true
+		}
+		switch verb {
+		case "embedContent", "batchEmbedContents":
+			return config.ScopeEmbeddings, model, true
+		default:
+			return config.ScopeChat, model, true
+		}
+	}
+	if method == http.MethodGet {
+		if strings.HasSuffix(path, "/models") {
+			return config.ScopeModels, "", false
+		}
+		return "", "", false
+	}
+	if strings.HasSuffix(path, "/embeddings") {
+		return config.ScopeEmbeddings, "", false
+	}
+	for _, suffix := range chatRouteSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return config.ScopeChat, "", false
+		}
+	}
+	return "", "", false
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// requestBodyModel reads the "model" field of the JSON body and restores the body for the
+// handler. A body larger than handlers.MaxRequestBodyBytes is answered with 413, and one that
+// cannot be read with 400, so that neither skips the model check; it then reports false.
+func requestBodyModel(c *gin.Context) (string, bool) {
+	if c.Request.Body == nil {
+		return "", true
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, handlers.MaxRequestBodyBytes))
+	if err != nil {
+		status, message, code := http.StatusBadRequest, "Failed to read the request body.", "invalid_body"
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status, code = http.StatusRequestEntityTooLarge, "request_too_large"
+			message = fmt.Sprintf("The request body is larger than %d bytes.", tooLarge.Limit)
+		}
+		c.AbortWithStatusJSON(status, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: message, Type: "invalid_request_error", Code: code},
+		})
+		return "", false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(data))
+	return gjson.GetBytes(data, "model").String(), true
+}
+
+func denyScope(c *gin.Context, apiKey, message string) {
+	usage.GetRequestStatistics().RecordDenied(apiKey)
+	c.AbortWithStatusJSON(http.StatusForbidden, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: message,
+			Type:    "permission_error",
+			Code:    "insufficient_scope",
+		},
+	})
+}
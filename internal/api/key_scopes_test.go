@@ -0,0 +1,235 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	proxyconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+func TestClassifyScopedRequest(t *testing.T) {
+	cases := []struct {
+		method, path string
+		scope, model string
+	}{
+		{http.MethodPost, "/v1/chat/completions", proxyconfig.ScopeChat, ""},
+		{http.MethodPost, "/v1/messages/count_tokens", proxyconfig.ScopeChat, ""},
+		{http.MethodPost, "/api/provider/openai/v1/responses", proxyconfig.ScopeChat, ""},
+		{http.MethodPost, "/v1/embeddings", proxyconfig.ScopeEmbeddings, ""},
+		{http.MethodGet, "/v1/models", proxyconfig.ScopeModels, ""},
+		{http.MethodGet, "/v1beta/models/gemini-2.5-pro", proxyconfig.ScopeModels, "gemini-2.5-pro"},
+		{http.MethodPost, "/v1beta/models/gemini-2.5-pro:streamGenerateContent", proxyconfig.ScopeChat, "gemini-2.5-pro"},
+		{http.MethodPost, "/v1beta/models/text-embedding-004:batchEmbedContents", proxyconfig.ScopeEmbeddings, "text-embedding-004"},
+		{http.MethodGet, "/api/threads", "", ""},
+	}
+	for _, tc := range cases {
+		scope, model, _ := classifyScopedRequest(tc.method, tc.path)
+		if scope != tc.scope || model != tc.model {
+			t.Fatalf("%s %s: got (%q, %q), want (%q, %q)", tc.method, tc.path, scope, model, tc.scope, tc.model)
+		}
+	}
+}
+
+func TestKeyScopesEnforced(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	usage.SetStatisticsEnabled(true)
+	defer usage.SetStatisticsEnabled(false)
+
+	s := &Server{cfg: &proxyconfig.Config{
+		APIKeySettings: []proxyconfig.APIKeySettings{{
+			APIKey: "scoped-key",
+			Scopes: &proxyconfig.APIKeyScopes{
+				Routes: []string{proxyconfig.ScopeChat},
+				Models: []string{"gemini-*"},
+			},
+		}},
+	}}
+	var reached int
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		c.Set("apiKey", strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		if s.allowedByKeyScopes(c) {
+			c.Next()
+		}
+	})
+	handler := func(c *gin.Context) {
+		reached++
+		if model := gjson.GetBytes(mustReadBody(t, c), "model").String(); c.Request.Method == http.MethodPost && model == "" {
+			t.Fatalf("handler did not receive the request body")
+		}
+		c.Status(http.StatusOK)
+	}
+	engine.POST("/v1/chat/completions", handler)
+	engine.GET("/v1/models", handler)
+	engine.POST("/v1beta/models/*action", handler)
+
+	do := func(key, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		rr := httptest.NewRecorder()
+		engine.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("scoped-key", http.MethodPost, "/v1/chat/completions", `{"model":"gemini-2.5-pro"}`); rr.Code != http.StatusOK {
+		t.Fatalf("allowed model: status %d, body %s", rr.Code, rr.Body.String())
+	}
+	rr := do("scoped-key", http.MethodPost, "/v1/chat/completions", `{"model":"gpt-5"}`)
+	if rr.Code != http.StatusForbidden || gjson.Get(rr.Body.String(), "error.type").String() != "permission_error" {
+		t.Fatalf("disallowed model: status %d, body %s", rr.Code, rr.Body.String())
+	}
+	if rr = do("scoped-key", http.MethodPost, "/v1beta/models/claude-sonnet-4:generateContent", `{}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("disallowed gemini path model: status %d", rr.Code)
+	}
+	if rr = do("scoped-key", http.MethodGet, "/v1/models", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("route outside scopes: status %d", rr.Code)
+	}
+	if rr = do("other-key", http.MethodPost, "/v1/chat/completions", `{"model":"gpt-5"}`); rr.Code != http.StatusOK {
+		t.Fatalf("unscoped key: status %d", rr.Code)
+	}
+	oversized := `{"padding":"` + strings.Repeat("a", handlers.MaxRequestBodyBytes) + `","model":"gemini-2.5-pro"}`
+	if rr = do("scoped-key", http.MethodPost, "/v1/chat/completions", oversized); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized body: status %d, want 413", rr.Code)
+	}
+	if reached != 2 {
+		t.Fatalf("handler reached %d times, want 2", reached)
+	}
+	if denied := usage.GetRequestStatistics().Snapshot().APIs["scoped-key"].DeniedRequests; denied != 3 {
+		t.Fatalf("denied requests = %d, want 3", denied)
+	}
+}
+
+func mustReadBody(t *testing.T, c *gin.Context) []byte {
+	t.Helper()
+	data, err := c.GetRawData()
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	return data
+}
+
+func TestSourceRestrictions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	s.applyAPIAccess(&proxyconfig.Config{
+		APIAccess: proxyconfig.APIAccessConfig{AllowedIPs: []string{"10.0.0.0/8"}, DeniedIPs: []string{"10.66.0.0/16"}},
+		APIKeySettings: []proxyconfig.APIKeySettings{
+			{APIKey: "vpn-key", AllowedIPs: []string{"10.8.0.0/16"}},
+		},
+	})
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		if !s.allowedBySource(c) {
+			return
+		}
+		c.Set("apiKey", strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		if s.allowedByKeySource(c) {
+			c.Next()
+		}
+	})
+	engine.GET("/v1/models", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	cases := []struct {
+		key, remote string
+		want        int
+	}{
+		{"office-key", "10.1.2.3:5000", http.StatusOK},
+		{"office-key", "203.0.113.9:5000", http.StatusForbidden},
+		{"office-key", "10.66.0.5:5000", http.StatusForbidden},
+		{"vpn-key", "10.8.4.4:5000", http.StatusOK},
+		{"vpn-key", "10.1.2.3:5000", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.RemoteAddr = tc.remote
+		req.Header.Set("Authorization", "Bearer "+tc.key)
+		rr := httptest.NewRecorder()
+		engine.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Fatalf("%s from %s: status %d, want %d", tc.key, tc.remote, rr.Code, tc.want)
+		}
+		if rr.Code == http.StatusForbidden && gjson.Get(rr.Body.String(), "error.code").String() != "source_not_allowed" {
+			t.Fatalf("%s from %s: body %s", tc.key, tc.remote, rr.Body.String())
+		}
+	}
+}
+
+func TestDisabledAndExpiredKeysRefused(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	s := &Server{cfg: &proxyconfig.Config{APIKeySettings: []proxyconfig.APIKeySettings{
+		{APIKey: "disabled-key", Disabled: true},
+		{APIKey: "expired-key", ExpiresAt: &past},
+		{APIKey: "expiring-key", ExpiresAt: &future},
+	}}}
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		c.Set("apiKey", strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		if s.allowedByKeyState(c) {
+			c.Next()
+		}
+	})
+	engine.GET("/v1/models", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for key, want := range map[string]string{"disabled-key": "api_key_disabled", "expired-key": "api_key_expired", "expiring-key": "", "plain-key": ""} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		rr := httptest.NewRecorder()
+		engine.ServeHTTP(rr, req)
+		if want == "" {
+			if rr.Code != http.StatusOK {
+				t.Fatalf("%s: status %d, want 200", key, rr.Code)
+			}
+			continue
+		}
+		if rr.Code != http.StatusUnauthorized || gjson.Get(rr.Body.String(), "error.code").String() != want {
+			t.Fatalf("%s: status %d, body %s; want 401 %s", key, rr.Code, rr.Body.String(), want)
+		}
+	}
+}
+
+func TestKeyProviderScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	modelRegistry := registry.GetGlobalRegistry()
+	modelRegistry.RegisterClient("test-key-provider-scopes", "claude", []*registry.ModelInfo{{ID: "scoped-sonnet", Created: time.Now().Unix()}})
+	t.Cleanup(func() { modelRegistry.UnregisterClient("test-key-provider-scopes") })
+
+	s := &Server{cfg: &proxyconfig.Config{APIKeySettings: []proxyconfig.APIKeySettings{{
+		APIKey: "gemini-only",
+		Scopes: &proxyconfig.APIKeyScopes{Providers: []string{"gemini", "vertex"}},
+	}}}}
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		c.Set("apiKey", "gemini-only")
+		if s.allowedByKeyScopes(c) {
+			c.Next()
+		}
+	})
+	var stored []string
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		stored = c.GetStringSlice(handlers.AllowedProvidersContextKey)
+		c.Status(http.StatusOK)
+	})
+	do := func(model string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		engine.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"`+model+`"}`)))
+		return rr
+	}
+
+	if rr := do("scoped-sonnet"); rr.Code != http.StatusForbidden || gjson.Get(rr.Body.String(), "error.code").String() != "insufficient_scope" {
+		t.Fatalf("model served by another provider: status %d, body %s", rr.Code, rr.Body.String())
+	}
+	// Models without a registered provider are left to the handler, which dispatches only to
+	// the stored providers.
+	if rr := do("auto"); rr.Code != http.StatusOK || len(stored) != 2 {
+		t.Fatalf("auto: status %d, stored providers %v", rr.Code, stored)
+	}
+}
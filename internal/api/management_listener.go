@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// managementOnlyPrefixes are the paths served only by the management listener when
+// management.listen is set.
+var managementOnlyPrefixes = []string{"/v0/management", "/management.html", "/metrics"}
+
+// managementListenerPaths are the paths the management listener serves: the management
+// API, the control panel and the metrics, plus the health probes for its own monitoring.
+var managementListenerPaths = append([]string{"/healthz", "/readyz"}, managementOnlyPrefixes...)
+
+func hasPathPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// pathFilter serves the requests whose path starts with one of prefixes, or, with exclude,
+// the ones that do not, and answers 404 to the rest.
+func pathFilter(next http.Handler, prefixes []string, exclude bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hasPathPrefix(r.URL.Path, prefixes) == exclude {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setupManagementListener splits the management endpoints off the API listener onto an HTTP
+// server of their own bound to management.listen. Both servers share the engine, so
+// authentication, allowed-ips and logging behave the same on either.
+func (s *Server) setupManagementListener(address string) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return
+	}
+	s.server.Handler = pathFilter(s.engine, managementOnlyPrefixes, true)
+	s.managementServer = &http.Server{
+		Addr:    address,
+		Handler: pathFilter(s.engine, managementListenerPaths, false),
+	}
+}
+
+// startManagementListener binds the management listener and serves it in the background.
+// Binding happens before it returns so that a taken address fails the start.
+func (s *Server) startManagementListener(tlsConfig *tls.Config) error {
+	if s.managementServer == nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", s.managementServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to start management listener: %v", err)
+	}
+	log.Infof("Management API listening on %s", s.managementServer.Addr)
+	go func() {
+		var errServe error
+		if tlsConfig != nil {
+			s.managementServer.TLSConfig = tlsConfig
+			closeACMEConnections(s.managementServer)
+			errServe = s.managementServer.ServeTLS(listener, "", "")
+		} else {
+			errServe = s.managementServer.Serve(listener)
+		}
+		if errServe != nil && !errors.Is(errServe, http.ErrServerClosed) {
+			log.Errorf("management listener stopped: %v", errServe)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) stopManagementListener(ctx context.Context) error {
+	if s.managementServer == nil {
+		return nil
+	}
+	if err := s.managementServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shutdown management listener: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// prometheusContentType is the media type of the Prometheus text exposition format.
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricsHandler serves the usage metrics for Prometheus when metrics.endpoint is set and
+// answers 404 otherwise, so the endpoint follows config reloads.
+func (s *Server) metricsHandler(c *gin.Context) {
+	cfg := s.cfg
+	if cfg == nil || !cfg.Metrics.Endpoint {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if token := cfg.Metrics.Token; token != "" {
+		provided, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(provided)), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid metrics token"})
+			return
+		}
+	}
+	var body bytes.Buffer
+	if err := usage.GetRequestStatistics().WriteMetrics(&body); err != nil {
+		log.WithError(err).Error("failed to render metrics")
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, prometheusContentType, body.Bytes())
+}
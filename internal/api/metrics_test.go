@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpointFollowsConfig(t *testing.T) {
+	server := newTestServer(t)
+	scrape := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		server.engine.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := scrape(""); rr.Code != http.StatusNotFound {
+		t.Fatalf("disabled endpoint answered %d, want 404", rr.Code)
+	}
+
+	server.cfg.Metrics.Endpoint = true
+	rr := scrape("")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("scrape answered %d with %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{"# TYPE cliproxy_requests_total counter", "# TYPE cliproxy_request_duration_seconds histogram"} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Fatalf("metrics missing %q:\n%s", want, rr.Body.String())
+		}
+	}
+
+	server.cfg.Metrics.Token = "scrape-secret"
+	if rr = scrape(""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("scrape without the token answered %d, want 401", rr.Code)
+	}
+	if rr = scrape("wrong"); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("scrape with a wrong token answered %d, want 401", rr.Code)
+	}
+	if rr = scrape("scrape-secret"); rr.Code != http.StatusOK {
+		t.Fatalf("scrape with the token answered %d, want 200", rr.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+// AuditLogMiddleware records every proxied request in the audit log while it is enabled.
+// The response is copied as it is written, after the client has received each chunk, so the
+// audit record never delays or alters what is sent.
+func AuditLogMiddleware(logger *logging.AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !logger.Enabled() || !shouldLogRequest(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		startedAt := time.Now()
+		var body []byte
+		if c.Request.Body != nil {
+			data, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				c.Next()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(data))
+			body = data
+		}
+		recorder := &auditResponseWriter{ResponseWriter: c.Writer, audit: logger.NewCapture()}
+		c.Writer = recorder
+
+		c.Next()
+
+		record := logging.AuditRecord{
+			RequestID:   logging.GetGinRequestID(c),
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			Status:      recorder.Status(),
+			Streaming:   strings.Contains(recorder.Header().Get("Content-Type"), "text/event-stream"),
+			StartedAt:   startedAt,
+			FirstByteAt: recorder.firstByteAt,
+			FinishedAt:  time.Now(),
+			Request:     body,
+		}
+		record.Response, record.ResponseRedacted, record.ResponseTruncated = recorder.audit.Result()
+		if apiKey, ok := c.Get("apiKey"); ok {
+			record.APIKey, _ = apiKey.(string)
+		}
+		record.Model = logging.GetGinRequestModel(c)
+		if usage, ok := c.Get(logging.AuditUsageKey); ok {
+			record.Usage, _ = usage.(*logging.AuditUsage)
+		}
+		if upstream, ok := c.Get(logging.AuditUpstreamRequestKey); ok {
+			record.UpstreamRequest, _ = upstream.([]byte)
+		}
+		if err := logger.Write(record); err != nil {
+			log.Warnf("%v", err)
+		}
+	}
+}
+
+// auditResponseWriter copies the response into an audit capture.
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	audit       *logging.AuditCapture
+	firstByteAt time.Time
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.capture(data[:n])
+	return n, err
+}
+
+func (w *auditResponseWriter) WriteString(data string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(data)
+	w.capture([]byte(data[:n]))
+	return n, err
+}
+
+func (w *auditResponseWriter) capture(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	if w.firstByteAt.IsZero() {
+		w.firstByteAt = time.Now()
+	}
+	w.audit.Write(data)
+}
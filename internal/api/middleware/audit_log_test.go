@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+func TestAuditLogMiddlewareAssemblesStreams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logger := &logging.AuditLogger{}
+	if err := logger.Configure(&config.Config{AuditLog: config.AuditLogConfig{Enabled: true, File: path}}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	defer func() { _ = logger.Close() }()
+
+	engine := gin.New()
+	engine.Use(AuditLogMiddleware(logger))
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Set("apiKey", "sk-client")
+		c.Set(logging.AuditUpstreamRequestKey, []byte(`{"contents":[]}`))
+		c.Header("Content-Type", "text/event-stream")
+		for _, chunk := range []string{"data: {\"n\":1}\n\n", "data: {\"n\":2}\n\n", "data: [DONE]\n\n"} {
+			_, _ = c.Writer.WriteString(chunk)
+			c.Writer.Flush()
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(`{"model":"gpt-5","stream":true}`))
+	engine.ServeHTTP(rec, req)
+	want := "data: {\"n\":1}\n\ndata: {\"n\":2}\n\ndata: [DONE]\n\n"
+	if rec.Body.String() != want {
+		t.Fatalf("client received %q", rec.Body.String())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	var doc struct {
+		Streaming       bool            `json:"streaming"`
+		Response        string          `json:"response"`
+		UpstreamRequest json.RawMessage `json:"upstream_request"`
+		FirstByteMs     *int64          `json:"first_byte_ms"`
+	}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decode audit record: %v\n%s", err, data)
+	}
+	if !doc.Streaming || doc.Response != want || string(doc.UpstreamRequest) != `{"contents":[]}` || doc.FirstByteMs == nil {
+		t.Fatalf("unexpected audit record: %s", data)
+	}
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// SourceAddrKey is the Gin context key under which SourceFilterMiddleware stores the source
+// address it resolved, for later access checks.
+const SourceAddrKey = "MANAGEMENT_SOURCE_ADDR"
+
+// SourceFilter decides from the source address whether a request may proceed.
+type SourceFilter struct {
+	// section names the config section the lists come from, for log messages.
+	section string
+	allowed []netip.Prefix
+	denied  []netip.Prefix
+	trusted []netip.Prefix
+	err     error
+}
+
+// NewSourceFilter compiles the management allowlist and denylist. An invalid entry makes the
+// filter refuse every request; Err reports why.
+func NewSourceFilter(cfg config.ManagementConfig) *SourceFilter {
+	return newSourceFilter("management", cfg.AllowedIPs, cfg.DeniedIPs, cfg.TrustedProxies)
+}
+
+// NewAPISourceFilter compiles api-access, the allowlist and denylist of the client API.
+func NewAPISourceFilter(cfg config.APIAccessConfig) *SourceFilter {
+	return newSourceFilter("api-access", cfg.AllowedIPs, cfg.DeniedIPs, cfg.TrustedProxies)
+}
+
+func newSourceFilter(section string, allowed, denied, trusted []string) *SourceFilter {
+	f := &SourceFilter{section: section}
+	if f.allowed, f.err = config.ParseIPPrefixes(allowed); f.err != nil {
+		return f
+	}
+	if f.denied, f.err = config.ParseIPPrefixes(denied); f.err != nil {
+		return f
+	}
+	f.trusted, f.err = config.ParseIPPrefixes(trusted)
+	return f
+}
+
+// Err returns the error that made the filter refuse everything, if any.
+func (f *SourceFilter) Err() error { return f.err }
+
+// SourceAddr returns the address the request came from. The nearest X-Forwarded-For entry
+// not added by a trusted proxy is used when the peer itself is trusted. It reports false for
+// connections without an IP peer, such as unix sockets.
+func (f *SourceFilter) SourceAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	source := peer.Unmap().WithZone("")
+	if !matchesAny(f.trusted, source) {
+		return source, true
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, errHop := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if errHop != nil {
+			break
+		}
+		source = hop.Unmap().WithZone("")
+		if !matchesAny(f.trusted, source) {
+			break
+		}
+	}
+	return source, true
+}
+
+// Allows reports whether the request may proceed, and the source address it was judged by.
+// Requests without an IP peer are local by construction and always allowed.
+func (f *SourceFilter) Allows(r *http.Request) (netip.Addr, bool) {
+	source, ok := f.SourceAddr(r)
+	if !ok {
+		return source, true
+	}
+	return source, f.AllowsAddr(source)
+}
+
+// AllowsAddr reports whether a request from source may proceed: it is in no denied range
+// and, when there are allowed ranges, in one of them.
+func (f *SourceFilter) AllowsAddr(source netip.Addr) bool {
+	if f.err != nil {
+		return false
+	}
+	if matchesAny(f.denied, source) {
+		return false
+	}
+	return len(f.allowed) == 0 || matchesAny(f.allowed, source)
+}
+
+func matchesAny(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// SourceFilterMiddleware refuses requests whose source the current filter does not allow
+// with 403. current is called on every request so that reloads take effect immediately.
+func SourceFilterMiddleware(current func() *SourceFilter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := current()
+		if filter == nil {
+			c.Next()
+			return
+		}
+		source, ok := filter.Allows(c.Request)
+		if !ok {
+			log.Warnf("%s: refused %s %s from %s (peer %s): source not allowed by %s.allowed-ips and denied-ips", filter.section, c.Request.Method, c.Request.URL.Path, source, c.Request.RemoteAddr, filter.section)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "source address not allowed"})
+			return
+		}
+		if source.IsValid() {
+			c.Set(SourceAddrKey, source.String())
+		}
+		c.Next()
+	}
+}
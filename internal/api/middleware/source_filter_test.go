@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestSourceFilterAllows(t *testing.T) {
+	filter := NewSourceFilter(config.ManagementConfig{
+		AllowedIPs:     []string{"localhost", "10.1.0.0/16", "2001:db8::/32", "192.0.2.7"},
+		TrustedProxies: []string{"10.9.0.1", "10.9.0.2"},
+	})
+	if err := filter.Err(); err != nil {
+		t.Fatalf("filter: %v", err)
+	}
+	cases := []struct {
+		remote string
+		xff    string
+		want   bool
+	}{
+		{"127.0.0.1:5000", "", true},
+		{"[::1]:5000", "", true},
+		{"[::ffff:192.0.2.7]:5000", "", true},
+		{"192.0.2.8:5000", "", false},
+		{"[2001:db8::5]:443", "", true},
+		{"[fe80::1%eth0]:443", "", false},
+		// Untrusted peers cannot forge their source.
+		{"203.0.113.5:5000", "10.1.2.3", false},
+		{"10.1.2.3:5000", "203.0.113.5", true},
+		// Trusted proxies are skipped from the right.
+		{"10.9.0.1:5000", "203.0.113.5, 10.1.2.3, 10.9.0.2", true},
+		{"10.9.0.1:5000", "10.1.2.3, 203.0.113.5", false},
+		{"10.9.0.1:5000", "", false},
+		// No IP peer, as over a unix socket.
+		{"@", "", true},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/v0/management/config", nil)
+		req.RemoteAddr = tc.remote
+		if tc.xff != "" {
+			req.Header.Set("X-Forwarded-For", tc.xff)
+		}
+		if source, got := filter.Allows(req); got != tc.want {
+			t.Fatalf("remote %s xff %q: allowed=%v (source %s), want %v", tc.remote, tc.xff, got, source, tc.want)
+		}
+	}
+}
+
+func TestSourceFilterMiddlewareHotSwap(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	filter := NewSourceFilter(config.ManagementConfig{AllowedIPs: []string{"10.0.0.0/8"}})
+	engine := gin.New()
+	engine.Use(SourceFilterMiddleware(func() *SourceFilter { return filter }))
+	engine.GET("/v0/management/usage", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	get := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/v0/management/usage", nil)
+		req.RemoteAddr = "192.168.1.4:1234"
+		rr := httptest.NewRecorder()
+		engine.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := get(); code != http.StatusForbidden {
+		t.Fatalf("status %d, want 403", code)
+	}
+	filter = NewSourceFilter(config.ManagementConfig{AllowedIPs: []string{"192.168.0.0/16"}})
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("after reload: status %d, want 200", code)
+	}
+	filter = NewSourceFilter(config.ManagementConfig{AllowedIPs: []string{"not-an-ip"}})
+	if code := get(); code != http.StatusForbidden || filter.Err() == nil {
+		t.Fatalf("invalid list: status %d, err %v; want 403 and an error", code, filter.Err())
+	}
+}
+
+func TestSourceFilterDeniedIPs(t *testing.T) {
+	filter := NewAPISourceFilter(config.APIAccessConfig{
+		AllowedIPs: []string{"10.0.0.0/8"},
+		DeniedIPs:  []string{"10.66.0.0/16", "192.0.2.1"},
+	})
+	cases := []struct {
+		remote string
+		want   bool
+	}{
+		{"10.1.2.3:5000", true},
+		{"10.66.1.1:5000", false},
+		{"192.0.2.1:5000", false},
+		{"192.0.2.2:5000", false},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.RemoteAddr = tc.remote
+		if _, got := filter.Allows(req); got != tc.want {
+			t.Fatalf("%s: allowed = %t, want %t", tc.remote, got, tc.want)
+		}
+	}
+	// A denylist alone refuses only what it lists.
+	denyOnly := NewAPISourceFilter(config.APIAccessConfig{DeniedIPs: []string{"203.0.113.0/24"}})
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.RemoteAddr = "198.51.100.7:443"
+	if _, ok := denyOnly.Allows(req); !ok {
+		t.Fatal("source outside the denylist should be allowed")
+	}
+}
@@ -11,8 +11,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
-	log "github.com/sirupsen/logrus"
 )
 
 // Option configures the AmpModule.
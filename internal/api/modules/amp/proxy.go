@@ -12,7 +12,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	log "github.com/sirupsen/logrus"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 )
 
 func removeQueryValuesMatching(req *http.Request, key string, match string) {
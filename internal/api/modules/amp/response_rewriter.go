@@ -6,7 +6,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	log "github.com/sirupsen/logrus"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
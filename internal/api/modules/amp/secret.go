@@ -11,7 +11,7 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
-	log "github.com/sirupsen/logrus"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 )
 
 // SecretSource provides Amp API keys with configurable precedence and caching
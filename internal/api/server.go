@@ -6,7 +6,6 @@ package api
 
 import (
 	"context"
-	"crypto/subtle"
 	"errors"
 	"fmt"
 	"net/http"
@@ -25,8 +24,13 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
 	ampmodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/amp"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/errorreport"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/notify"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -36,7 +40,6 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
-	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
@@ -93,6 +96,9 @@ func WithLocalManagementPassword(password string) ServerOption {
 }
 
 // WithKeepAliveEndpoint enables a keep-alive endpoint with the provided timeout and callback.
+// Clients ping GET /keep-alive, naming themselves with the X-Keep-Alive-Client header or the
+// client_id query parameter, and may leave with DELETE /keep-alive. onTimeout is called once
+// every client has been silent for timeout, or right after the last one leaves.
 func WithKeepAliveEndpoint(timeout time.Duration, onTimeout func()) ServerOption {
 	return func(cfg *serverOptionConfig) {
 		if timeout <= 0 || onTimeout == nil {
@@ -120,6 +126,13 @@ type Server struct {
 	// server is the underlying HTTP server.
 	server *http.Server
 
+	// tlsCancel stops the certificate watcher or ACME challenge listener started with TLS.
+	tlsCancel context.CancelFunc
+
+	// managementServer serves the management endpoints on management.listen; nil when they
+	// share the API listener.
+	managementServer *http.Server
+
 	// handlers contains the API handlers for processing requests.
 	handlers *handlers.BaseAPIHandler
 
@@ -159,17 +172,22 @@ type Server struct {
 	managementRoutesRegistered atomic.Bool
 	// managementRoutesEnabled controls whether management endpoints serve real handlers.
 	managementRoutesEnabled atomic.Bool
+	// pprofEnabled mirrors debug-pprof and gates the profiling endpoints.
+	pprofEnabled atomic.Bool
+	// managementFilter holds the compiled management.allowed-ips.
+	managementFilter atomic.Pointer[middleware.SourceFilter]
+	// apiSourceFilters holds the compiled api-access and per-key source lists.
+	apiSourceFilters atomic.Pointer[apiSourceFilters]
+
+	// usageLabels holds the compiled usage-labels; nil when none are configured.
+	usageLabels atomic.Pointer[usageLabelReader]
 
 	// envManagementSecret indicates whether MANAGEMENT_PASSWORD is configured.
 	envManagementSecret bool
 
 	localPassword string
 
-	keepAliveEnabled   bool
-	keepAliveTimeout   time.Duration
-	keepAliveOnTimeout func()
-	keepAliveHeartbeat chan struct{}
-	keepAliveStop      chan struct{}
+	keepAlive *keepAliveWatchdog
 }
 
 // NewServer creates and initializes a new API server instance.
@@ -201,8 +219,10 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	}
 
 	// Add middleware
+	engine.Use(tracing.Middleware())
 	engine.Use(logging.GinLogrusLogger())
 	engine.Use(logging.GinLogrusRecovery())
+	engine.Use(errorreport.Middleware())
 	for _, mw := range optionState.extraMiddleware {
 		engine.Use(mw)
 	}
@@ -223,6 +243,8 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		}
 	}
 
+	engine.Use(middleware.AuditLogMiddleware(logging.DefaultAuditLogger()))
+
 	engine.Use(corsMiddleware())
 	wd, err := os.Getwd()
 	if err != nil {
@@ -268,12 +290,12 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	s.setupRoutes()
 
 	// Register Amp module using V2 interface with Context
-	s.ampModule = ampmodule.NewLegacy(accessManager, AuthMiddleware(accessManager))
+	s.ampModule = ampmodule.NewLegacy(accessManager, s.clientAuthMiddleware())
 	ctx := modules.Context{
 		Engine:         engine,
 		BaseHandler:    s.handlers,
 		Config:         cfg,
-		AuthMiddleware: AuthMiddleware(accessManager),
+		AuthMiddleware: s.clientAuthMiddleware(),
 	}
 	if err := modules.RegisterModule(ctx, s.ampModule); err != nil {
 		log.Errorf("Failed to register Amp module: %v", err)
@@ -287,6 +309,10 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	// Register management routes when configuration or environment secrets are available.
 	hasManagementSecret := cfg.RemoteManagement.SecretKey != "" || envManagementSecret
 	s.managementRoutesEnabled.Store(hasManagementSecret)
+	s.pprofEnabled.Store(cfg.DebugPprof)
+	s.applyManagementFilter(cfg)
+	s.applyUsageLabels(cfg)
+	s.applyAPIAccess(cfg)
 	if hasManagementSecret {
 		s.registerManagementRoutes()
 	}
@@ -300,6 +326,7 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		Addr:    fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
 		Handler: engine,
 	}
+	s.setupManagementListener(cfg.Management.Listen)
 
 	return s
 }
@@ -316,11 +343,12 @@ func (s *Server) setupRoutes() {
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
-	v1.Use(AuthMiddleware(s.accessManager))
+	v1.Use(s.clientAuthMiddleware())
 	{
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
 		v1.POST("/completions", openaiHandlers.Completions)
+		v1.POST("/tokenize", openaiHandlers.Tokenize)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
@@ -329,13 +357,21 @@ func (s *Server) setupRoutes() {
 
 	// Gemini compatible API routes
 	v1beta := s.engine.Group("/v1beta")
-	v1beta.Use(AuthMiddleware(s.accessManager))
+	v1beta.Use(s.clientAuthMiddleware())
 	{
 		v1beta.GET("/models", geminiHandlers.GeminiModels)
 		v1beta.POST("/models/*action", geminiHandlers.GeminiHandler)
 		v1beta.GET("/models/*action", geminiHandlers.GeminiGetHandler)
 	}
 
+	// Liveness and readiness probes for orchestrators; intentionally unauthenticated.
+	s.engine.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	s.engine.GET("/readyz", s.readinessHandler)
+	// Usage metrics for Prometheus; off unless metrics.endpoint is set.
+	s.engine.GET("/metrics", s.metricsHandler)
+
 	// Root endpoint
 	s.engine.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -446,13 +482,14 @@ func (s *Server) AttachWebsocketRoute(path string, handler http.Handler) {
 	s.wsRoutes[trimmed] = struct{}{}
 	s.wsRouteMu.Unlock()
 
-	authMiddleware := AuthMiddleware(s.accessManager)
 	conditionalAuth := func(c *gin.Context) {
 		if !s.wsAuthEnabled.Load() {
 			c.Next()
 			return
 		}
-		authMiddleware(c)
+		if authenticateClient(c, s.accessManager) && s.allowedByKeyState(c) {
+			c.Next()
+		}
 	}
 	finalHandler := func(c *gin.Context) {
 		handler.ServeHTTP(c.Writer, c.Request)
@@ -472,18 +509,47 @@ func (s *Server) registerManagementRoutes() {
 
 	log.Info("management routes registered after secret key configuration")
 
+	// The dashboard page and its assets carry no data, so they skip the key check; the page
+	// authenticates its own calls to /dashboard/data.
+	dashboard := s.engine.Group("/v0/management/dashboard")
+	dashboard.Use(s.managementAvailabilityMiddleware(), middleware.SourceFilterMiddleware(s.managementFilter.Load))
+	{
+		dashboard.GET("", s.mgmt.DashboardPage)
+		dashboard.GET("/assets/:file", s.mgmt.DashboardAsset)
+	}
+
 	mgmt := s.engine.Group("/v0/management")
-	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.Middleware())
+	mgmt.Use(s.managementAvailabilityMiddleware(), middleware.SourceFilterMiddleware(s.managementFilter.Load), s.mgmt.Middleware())
 	{
 		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
+		mgmt.DELETE("/usage", s.mgmt.DeleteUsageStatistics)
+		mgmt.GET("/usage/models", s.mgmt.GetUsageModels)
+		mgmt.GET("/usage/keys", s.mgmt.GetUsageKeys)
 		mgmt.GET("/usage/export", s.mgmt.ExportUsageStatistics)
 		mgmt.POST("/usage/import", s.mgmt.ImportUsageStatistics)
+		mgmt.GET("/usage/persistence", s.mgmt.GetUsagePersistence)
 		mgmt.GET("/config", s.mgmt.GetConfig)
+		mgmt.GET("/health", s.mgmt.GetAuthHealth)
+		mgmt.GET("/cooldowns", s.mgmt.GetCooldowns)
+		mgmt.GET("/lockouts", s.mgmt.GetLockouts)
+		mgmt.DELETE("/lockouts", s.mgmt.DeleteLockouts)
+		mgmt.GET("/transports", s.mgmt.GetTransportStats)
+		mgmt.GET("/keep-alive", s.mgmt.GetKeepAlive)
+		mgmt.DELETE("/model-cache", s.mgmt.DeleteModelCache)
+		mgmt.POST("/notify/test", s.mgmt.PostNotifyTest)
+		mgmt.GET("/accounts", s.mgmt.ListAccounts)
+		mgmt.POST("/accounts/:id/disable", s.mgmt.DisableAccount)
+		mgmt.POST("/accounts/:id/enable", s.mgmt.EnableAccount)
 		mgmt.GET("/config.yaml", s.mgmt.GetConfigYAML)
 		mgmt.PUT("/config.yaml", s.mgmt.PutConfigYAML)
 		mgmt.GET("/latest-version", s.mgmt.GetLatestVersion)
 
 		mgmt.GET("/debug", s.mgmt.GetDebug)
+		debug := mgmt.Group("/debug", s.pprofAvailabilityMiddleware())
+		debug.GET("/pprof/", s.mgmt.PprofIndex)
+		debug.GET("/pprof/:name", s.mgmt.PprofProfile)
+		debug.POST("/pprof/:name", s.mgmt.PprofProfile)
+		debug.GET("/runtime", s.mgmt.GetRuntimeStats)
 		mgmt.PUT("/debug", s.mgmt.PutDebug)
 		mgmt.PATCH("/debug", s.mgmt.PutDebug)
 
@@ -519,9 +585,18 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PATCH("/quota-exceeded/switch-preview-model", s.mgmt.PutSwitchPreviewModel)
 
 		mgmt.GET("/api-keys", s.mgmt.GetAPIKeys)
+		mgmt.GET("/api-key-quotas", s.mgmt.GetAPIKeyQuotas)
+		mgmt.POST("/api-key-quotas/top-up", s.mgmt.TopUpAPIKeyQuota)
+		mgmt.GET("/dashboard/data", s.mgmt.GetDashboardData)
 		mgmt.PUT("/api-keys", s.mgmt.PutAPIKeys)
 		mgmt.PATCH("/api-keys", s.mgmt.PatchAPIKeys)
 		mgmt.DELETE("/api-keys", s.mgmt.DeleteAPIKeys)
+		mgmt.GET("/api-keys/status", s.mgmt.GetAPIKeyStatus)
+		mgmt.POST("/api-keys/create", s.mgmt.CreateAPIKey)
+		mgmt.POST("/api-keys/disable", s.mgmt.DisableAPIKey)
+		mgmt.POST("/api-keys/enable", s.mgmt.EnableAPIKey)
+		mgmt.POST("/api-keys/rotate", s.mgmt.RotateAPIKey)
+		mgmt.PUT("/api-keys/expiry", s.mgmt.PutAPIKeyExpiry)
 
 		mgmt.GET("/gemini-api-key", s.mgmt.GetGeminiKeys)
 		mgmt.PUT("/gemini-api-key", s.mgmt.PutGeminiKeys)
@@ -616,6 +691,7 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.POST("/auth-files", s.mgmt.UploadAuthFile)
 		mgmt.DELETE("/auth-files", s.mgmt.DeleteAuthFile)
 		mgmt.PATCH("/auth-files/status", s.mgmt.PatchAuthFileStatus)
+		mgmt.PATCH("/auth-files/project", s.mgmt.PatchAuthFileProject)
 		mgmt.POST("/vertex/import", s.mgmt.ImportVertexCredential)
 
 		mgmt.GET("/anthropic-auth-url", s.mgmt.RequestAnthropicToken)
@@ -640,6 +716,42 @@ func (s *Server) managementAvailabilityMiddleware() gin.HandlerFunc {
 	}
 }
 
+// pprofAvailabilityMiddleware hides the profiling endpoints unless debug-pprof is enabled.
+func (s *Server) pprofAvailabilityMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.pprofEnabled.Load() {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		c.Next()
+	}
+}
+
+// readinessHandler reports 503 while every credential of some provider is marked unhealthy.
+// Usage persistence is reported alongside but does not affect the status: requests are still
+// served while statistics cannot be saved.
+func (s *Server) readinessHandler(c *gin.Context) {
+	var down []string
+	if s.handlers != nil && s.handlers.AuthManager != nil {
+		down = s.handlers.AuthManager.ProvidersDown()
+	}
+	body := gin.H{"status": "ready"}
+	if persistence := usage.CurrentPersistenceStatus(); persistence.Enabled {
+		body["usage_persistence"] = gin.H{
+			"failing":              persistence.Failing,
+			"using_fallback":       persistence.UsingFallback,
+			"last_save_error":      persistence.LastSaveError,
+			"last_successful_save": persistence.LastSave,
+		}
+	}
+	if len(down) > 0 {
+		body["status"], body["providers_down"] = "degraded", down
+		c.JSON(http.StatusServiceUnavailable, body)
+		return
+	}
+	c.JSON(http.StatusOK, body)
+}
+
 func (s *Server) serveManagementControlPanel(c *gin.Context) {
 	cfg := s.cfg
 	if cfg == nil || cfg.RemoteManagement.DisableControlPanel {
@@ -667,84 +779,6 @@ func (s *Server) serveManagementControlPanel(c *gin.Context) {
 	c.File(filePath)
 }
 
-func (s *Server) enableKeepAlive(timeout time.Duration, onTimeout func()) {
-	if timeout <= 0 || onTimeout == nil {
-		return
-	}
-
-	s.keepAliveEnabled = true
-	s.keepAliveTimeout = timeout
-	s.keepAliveOnTimeout = onTimeout
-	s.keepAliveHeartbeat = make(chan struct{}, 1)
-	s.keepAliveStop = make(chan struct{}, 1)
-
-	s.engine.GET("/keep-alive", s.handleKeepAlive)
-
-	go s.watchKeepAlive()
-}
-
-func (s *Server) handleKeepAlive(c *gin.Context) {
-	if s.localPassword != "" {
-		provided := strings.TrimSpace(c.GetHeader("Authorization"))
-		if provided != "" {
-			parts := strings.SplitN(provided, " ", 2)
-			if len(parts) == 2 && strings.EqualFold(parts[0], "bearer") {
-				provided = parts[1]
-			}
-		}
-		if provided == "" {
-			provided = strings.TrimSpace(c.GetHeader("X-Local-Password"))
-		}
-		if subtle.ConstantTimeCompare([]byte(provided), []byte(s.localPassword)) != 1 {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid password"})
-			return
-		}
-	}
-
-	s.signalKeepAlive()
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
-}
-
-func (s *Server) signalKeepAlive() {
-	if !s.keepAliveEnabled {
-		return
-	}
-	select {
-	case s.keepAliveHeartbeat <- struct{}{}:
-	default:
-	}
-}
-
-func (s *Server) watchKeepAlive() {
-	if !s.keepAliveEnabled {
-		return
-	}
-
-	timer := time.NewTimer(s.keepAliveTimeout)
-	defer timer.Stop()
-
-	for {
-		select {
-		case <-timer.C:
-			log.Warnf("keep-alive endpoint idle for %s, shutting down", s.keepAliveTimeout)
-			if s.keepAliveOnTimeout != nil {
-				s.keepAliveOnTimeout()
-			}
-			return
-		case <-s.keepAliveHeartbeat:
-			if !timer.Stop() {
-				select {
-				case <-timer.C:
-				default:
-				}
-			}
-			timer.Reset(s.keepAliveTimeout)
-		case <-s.keepAliveStop:
-			return
-		}
-	}
-}
-
 // unifiedModelsHandler creates a unified handler for the /v1/models endpoint
 // that routes to different handlers based on the User-Agent header.
 // If User-Agent starts with "claude-cli", it routes to Claude handler,
@@ -776,18 +810,28 @@ func (s *Server) Start() error {
 
 	useTLS := s.cfg != nil && s.cfg.TLS.Enable
 	if useTLS {
-		cert := strings.TrimSpace(s.cfg.TLS.Cert)
-		key := strings.TrimSpace(s.cfg.TLS.Key)
-		if cert == "" || key == "" {
+		if !s.cfg.TLS.ACME.Enabled() && (strings.TrimSpace(s.cfg.TLS.Cert) == "" || strings.TrimSpace(s.cfg.TLS.Key) == "") {
 			return fmt.Errorf("failed to start HTTPS server: tls.cert or tls.key is empty")
 		}
+		tlsConfig, err := s.setupTLS()
+		if err != nil {
+			return fmt.Errorf("failed to start HTTPS server: %v", err)
+		}
+		s.server.TLSConfig = tlsConfig
+		closeACMEConnections(s.server)
+		if err = s.startManagementListener(withoutClientAuth(tlsConfig)); err != nil {
+			return err
+		}
 		log.Debugf("Starting API server on %s with TLS", s.server.Addr)
-		if errServeTLS := s.server.ListenAndServeTLS(cert, key); errServeTLS != nil && !errors.Is(errServeTLS, http.ErrServerClosed) {
+		if errServeTLS := s.server.ListenAndServeTLS("", ""); errServeTLS != nil && !errors.Is(errServeTLS, http.ErrServerClosed) {
 			return fmt.Errorf("failed to start HTTPS server: %v", errServeTLS)
 		}
 		return nil
 	}
 
+	if err := s.startManagementListener(nil); err != nil {
+		return err
+	}
 	log.Debugf("Starting API server on %s", s.server.Addr)
 	if errServe := s.server.ListenAndServe(); errServe != nil && !errors.Is(errServe, http.ErrServerClosed) {
 		return fmt.Errorf("failed to start HTTP server: %v", errServe)
@@ -796,6 +840,15 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// Handler returns the fully configured router the HTTP server serves, for dispatching
+// requests in-process without going through the listener.
+func (s *Server) Handler() http.Handler {
+	if s == nil || s.server == nil {
+		return nil
+	}
+	return s.server.Handler
+}
+
 // Stop gracefully shuts down the API server without interrupting any
 // active connections.
 //
@@ -807,17 +860,31 @@ func (s *Server) Start() error {
 func (s *Server) Stop(ctx context.Context) error {
 	log.Debug("Stopping API server...")
 
-	if s.keepAliveEnabled {
-		select {
-		case s.keepAliveStop <- struct{}{}:
-		default:
-		}
-	}
+	s.keepAlive.Stop()
 
 	// Shutdown the HTTP server.
 	if err := s.server.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
 	}
+	if err := s.stopManagementListener(ctx); err != nil {
+		return err
+	}
+	if s.tlsCancel != nil {
+		s.tlsCancel()
+	}
+
+	// Write request logs still buffered once no more requests can add to them.
+	if stopper, ok := s.requestLogger.(interface{ Stop() error }); ok {
+		if err := stopper.Stop(); err != nil {
+			log.Errorf("failed to flush request logs: %v", err)
+		}
+	}
+	if err := tracing.Shutdown(ctx); err != nil {
+		log.Errorf("failed to flush traces: %v", err)
+	}
+	if !errorreport.Flush(ctx) {
+		log.Warn("error reports still queued at shutdown were dropped")
+	}
 
 	log.Debug("API server stopped")
 	return nil
@@ -843,6 +910,16 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
+// applyManagementFilter compiles management.allowed-ips for the management routes. A list
+// that does not parse closes the management API until it is corrected.
+func (s *Server) applyManagementFilter(cfg *config.Config) {
+	filter := middleware.NewSourceFilter(cfg.Management)
+	if err := filter.Err(); err != nil {
+		log.Errorf("management: %v; refusing management requests until the configuration is fixed", err)
+	}
+	s.managementFilter.Store(filter)
+}
+
 func (s *Server) applyAccessConfig(oldCfg, newCfg *config.Config) {
 	if s == nil || s.accessManager == nil || newCfg == nil {
 		return
@@ -878,15 +955,39 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
-	if oldCfg == nil || oldCfg.LoggingToFile != cfg.LoggingToFile || oldCfg.LogsMaxTotalSizeMB != cfg.LogsMaxTotalSizeMB {
+	if oldCfg == nil || oldCfg.LogOutput() != cfg.LogOutput() || oldCfg.Logging.Syslog != cfg.Logging.Syslog || oldCfg.LogsMaxTotalSizeMB != cfg.LogsMaxTotalSizeMB {
 		if err := logging.ConfigureLogOutput(cfg); err != nil {
 			log.Errorf("failed to reconfigure log output: %v", err)
 		}
 	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.AuditLog, cfg.AuditLog) {
+		if err := logging.ConfigureAuditLog(cfg); err != nil {
+			log.Errorf("failed to configure audit log, audit logging is disabled: %v", err)
+		}
+	}
+	if oldCfg == nil || oldCfg.LogSampling != cfg.LogSampling {
+		logging.ConfigureLogSampling(cfg.LogSampling)
+	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Tracing, cfg.Tracing) {
+		if err := tracing.Configure(cfg.Tracing); err != nil {
+			log.Errorf("failed to configure tracing: %v", err)
+		}
+	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Notifications, cfg.Notifications) {
+		notify.Configure(cfg.Notifications)
+	}
+	if oldCfg == nil || oldCfg.Sentry != cfg.Sentry {
+		if err := errorreport.Configure(cfg.Sentry); err != nil {
+			log.Errorf("failed to configure error reporting: %v", err)
+		}
+	}
 
 	if oldCfg == nil || oldCfg.UsageStatisticsEnabled != cfg.UsageStatisticsEnabled {
 		usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
 	}
+	if oldCfg == nil || oldCfg.UsageStatisticsMaxKeys != cfg.UsageStatisticsMaxKeys {
+		usage.SetStatisticsMaxKeys(cfg.EffectiveUsageStatisticsMaxKeys())
+	}
 
 	if s.requestLogger != nil && (oldCfg == nil || oldCfg.ErrorLogsMaxFiles != cfg.ErrorLogsMaxFiles) {
 		if setter, ok := s.requestLogger.(interface{ SetErrorLogsMaxFiles(int) }); ok {
@@ -894,10 +995,31 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	if oldCfg != nil {
+		// Client certificate identities are read per request; the rest of tls is bound to
+		// the listeners.
+		oldTLS, newTLS := oldCfg.TLS, cfg.TLS
+		oldTLS.ClientAuth.Identities, newTLS.ClientAuth.Identities = nil, nil
+		if !reflect.DeepEqual(oldTLS, newTLS) {
+			log.Warn("tls settings changed; restart the server to apply them (renewed certificate files are picked up without one)")
+		}
+	}
+	if oldCfg != nil && oldCfg.Management.Listen != cfg.Management.Listen {
+		log.Warnf("management.listen changed from %q to %q; restart the server to apply it", oldCfg.Management.Listen, cfg.Management.Listen)
+	}
+
 	if oldCfg == nil || oldCfg.DisableCooling != cfg.DisableCooling {
 		auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 	}
 
+	if oldCfg == nil || oldCfg.ModelCacheTTL != cfg.ModelCacheTTL {
+		registry.UpstreamModelCache().SetTTL(cfg.EffectiveModelCacheTTL())
+	}
+
+	if oldCfg == nil || oldCfg.HTTPTransport != cfg.HTTPTransport {
+		util.ConfigureTransports(cfg.HTTPTransport)
+	}
+
 	if s.handlers != nil && s.handlers.AuthManager != nil {
 		s.handlers.AuthManager.SetRetryConfig(cfg.RequestRetry, time.Duration(cfg.MaxRetryInterval)*time.Second)
 	}
@@ -906,6 +1028,18 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 	if oldCfg == nil || oldCfg.Debug != cfg.Debug {
 		util.SetLogLevel(cfg)
 	}
+	if oldCfg == nil || oldCfg.DebugPprof != cfg.DebugPprof {
+		s.pprofEnabled.Store(cfg.DebugPprof)
+	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Management, cfg.Management) {
+		s.applyManagementFilter(cfg)
+	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.UsageLabels, cfg.UsageLabels) {
+		s.applyUsageLabels(cfg)
+	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.APIAccess, cfg.APIAccess) || !reflect.DeepEqual(oldCfg.APIKeySettings, cfg.APIKeySettings) {
+		s.applyAPIAccess(cfg)
+	}
 
 	prevSecretEmpty := true
 	if oldCfg != nil {
@@ -978,6 +1112,9 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 	if dirSetter, ok := tokenStore.(interface{ SetBaseDir(string) }); ok {
 		dirSetter.SetBaseDir(cfg.AuthDir)
 	}
+	if dirsSetter, ok := tokenStore.(interface{ SetAuthDirs([]string) }); ok {
+		dirsSetter.SetAuthDirs(cfg.AuthDirectories())
+	}
 	authEntries := util.CountAuthFiles(context.Background(), tokenStore)
 	geminiAPIKeyCount := len(cfg.GeminiKey)
 	claudeAPIKeyCount := len(cfg.ClaudeKey)
@@ -1015,32 +1152,39 @@ func (s *Server) SetWebsocketAuthChangeHandler(fn func(bool, bool)) {
 // it allows all requests (legacy behaviour).
 func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if manager == nil {
+		if authenticateClient(c, manager) {
 			c.Next()
-			return
 		}
+	}
+}
+
+// authenticateClient authenticates the request and stores the principal in the context. On
+// failure it aborts the request and reports false.
+func authenticateClient(c *gin.Context, manager *sdkaccess.Manager) bool {
+	if manager == nil {
+		return true
+	}
 
-		result, err := manager.Authenticate(c.Request.Context(), c.Request)
-		if err == nil {
-			if result != nil {
-				c.Set("apiKey", result.Principal)
-				c.Set("accessProvider", result.Provider)
-				if len(result.Metadata) > 0 {
-					c.Set("accessMetadata", result.Metadata)
-				}
+	result, err := manager.Authenticate(c.Request.Context(), c.Request)
+	if err == nil {
+		if result != nil {
+			c.Set("apiKey", result.Principal)
+			c.Set("accessProvider", result.Provider)
+			if len(result.Metadata) > 0 {
+				c.Set("accessMetadata", result.Metadata)
 			}
-			c.Next()
-			return
 		}
+		return true
+	}
 
-		switch {
-		case errors.Is(err, sdkaccess.ErrNoCredentials):
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing API key"})
-		case errors.Is(err, sdkaccess.ErrInvalidCredential):
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
-		default:
-			log.Errorf("authentication middleware error: %v", err)
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Authentication service error"})
-		}
+	switch {
+	case errors.Is(err, sdkaccess.ErrNoCredentials):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing API key"})
+	case errors.Is(err, sdkaccess.ErrInvalidCredential):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+	default:
+		log.Errorf("authentication middleware error: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Authentication service error"})
 	}
+	return false
 }
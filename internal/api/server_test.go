@@ -1,6 +1,9 @@
 package api
 
 import (
+	"bytes"
+	"compress/gzip"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -13,6 +16,7 @@ import (
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func newTestServer(t *testing.T) *Server {
@@ -109,3 +113,115 @@ func TestAmpProviderModelRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestPprofEndpointsRequireManagementAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hash, err := bcrypt.GenerateFromPassword([]byte("remote-secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+	cfg := &proxyconfig.Config{
+		AuthDir:          t.TempDir(),
+		DebugPprof:       true,
+		RemoteManagement: proxyconfig.RemoteManagement{SecretKey: string(hash)},
+	}
+	server := NewServer(cfg, auth.NewManager(nil, nil, nil), sdkaccess.NewManager(),
+		filepath.Join(t.TempDir(), "config.yaml"), WithLocalManagementPassword("local-pass"))
+
+	get := func(path, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "127.0.0.1:40000"
+		if password != "" {
+			req.Header.Set("Authorization", "Bearer "+password)
+		}
+		rr := httptest.NewRecorder()
+		server.engine.ServeHTTP(rr, req)
+		return rr
+	}
+
+	paths := []string{
+		"/v0/management/debug/pprof/",
+		"/v0/management/debug/pprof/heap",
+		"/v0/management/debug/pprof/goroutine?debug=1",
+		"/v0/management/debug/runtime",
+	}
+	for _, path := range paths {
+		if rr := get(path, ""); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("%s without password: status %d, want 401", path, rr.Code)
+		}
+	}
+
+	rr := get("/v0/management/debug/pprof/heap", "local-pass")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("heap profile: status %d, body %s", rr.Code, rr.Body.String())
+	}
+	// Binary profiles are gzip-compressed protocol buffers.
+	reader, err := gzip.NewReader(bytes.NewReader(rr.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("heap profile is not gzip: %v", err)
+	}
+	if raw, errRead := io.ReadAll(reader); errRead != nil || len(raw) == 0 {
+		t.Fatalf("heap profile did not decompress: %v", errRead)
+	}
+	if rr = get("/v0/management/debug/pprof/goroutine?debug=1", "local-pass"); !strings.Contains(rr.Body.String(), "goroutine profile:") {
+		t.Fatalf("goroutine profile: status %d, body %.200s", rr.Code, rr.Body.String())
+	}
+	if rr = get("/v0/management/debug/pprof/", "local-pass"); !strings.Contains(rr.Body.String(), "heap") {
+		t.Fatalf("profile index: status %d, body %.200s", rr.Code, rr.Body.String())
+	}
+	if rr = get("/v0/management/debug/runtime", "local-pass"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"goroutines"`) {
+		t.Fatalf("runtime stats: status %d, body %.200s", rr.Code, rr.Body.String())
+	}
+
+	server.pprofEnabled.Store(false)
+	if rr = get("/v0/management/debug/pprof/heap", "local-pass"); rr.Code != http.StatusNotFound {
+		t.Fatalf("with debug-pprof disabled: status %d, want 404", rr.Code)
+	}
+}
+
+func TestManagementListenerSplitsRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hash, err := bcrypt.GenerateFromPassword([]byte("remote-secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+	cfg := &proxyconfig.Config{
+		AuthDir:          t.TempDir(),
+		RemoteManagement: proxyconfig.RemoteManagement{SecretKey: string(hash)},
+		Management:       proxyconfig.ManagementConfig{Listen: "127.0.0.1:0"},
+	}
+	server := NewServer(cfg, auth.NewManager(nil, nil, nil), sdkaccess.NewManager(),
+		filepath.Join(t.TempDir(), "config.yaml"), WithLocalManagementPassword("local-pass"))
+	if server.managementServer == nil {
+		t.Fatal("management listener not configured")
+	}
+
+	get := func(handler http.Handler, path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "127.0.0.1:40000"
+		req.Header.Set("Authorization", "Bearer local-pass")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for _, path := range []string{"/v0/management/config", "/management.html", "/metrics"} {
+		if code := get(server.server.Handler, path); code != http.StatusNotFound {
+			t.Fatalf("API listener %s: status %d, want 404", path, code)
+		}
+	}
+	if code := get(server.managementServer.Handler, "/v0/management/config"); code != http.StatusOK {
+		t.Fatalf("management listener config: status %d, want 200", code)
+	}
+	if code := get(server.managementServer.Handler, "/healthz"); code != http.StatusOK {
+		t.Fatalf("management listener healthz: status %d, want 200", code)
+	}
+	for _, path := range []string{"/v1/models", "/v0/managementx"} {
+		if code := get(server.managementServer.Handler, path); code != http.StatusNotFound {
+			t.Fatalf("management listener %s: status %d, want 404", path, code)
+		}
+	}
+	if code := get(server.server.Handler, "/healthz"); code != http.StatusOK {
+		t.Fatalf("API listener healthz: status %d, want 200", code)
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// apiSourceFilters are the compiled api-access lists and the source lists of the keys in
+// api-key-settings that have any.
+type apiSourceFilters struct {
+	api  *middleware.SourceFilter
+	keys map[string]*middleware.SourceFilter
+}
+
+func newAPISourceFilters(cfg *config.Config) *apiSourceFilters {
+	filters := &apiSourceFilters{api: middleware.NewAPISourceFilter(cfg.APIAccess)}
+	if err := filters.api.Err(); err != nil {
+		log.Errorf("api-access: %v; refusing API requests until the configuration is fixed", err)
+	}
+	for _, settings := range cfg.APIKeySettings {
+		if len(settings.AllowedIPs) == 0 && len(settings.DeniedIPs) == 0 {
+			continue
+		}
+		if filters.keys == nil {
+			filters.keys = make(map[string]*middleware.SourceFilter)
+		}
+		filter := middleware.NewAPISourceFilter(config.APIAccessConfig{AllowedIPs: settings.AllowedIPs, DeniedIPs: settings.DeniedIPs})
+		if err := filter.Err(); err != nil {
+			log.Errorf("api-key-settings: %v; refusing requests with key %s until the configuration is fixed", err, util.HideAPIKey(settings.APIKey))
+		}
+		filters.keys[settings.APIKey] = filter
+	}
+	return filters
+}
+
+// applyAPIAccess compiles api-access and the per-key source lists for the client routes.
+func (s *Server) applyAPIAccess(cfg *config.Config) {
+	s.apiSourceFilters.Store(newAPISourceFilters(cfg))
+}
+
+// allowedBySource aborts the request with 403 and reports false when api-access refuses its
+// source.
+func (s *Server) allowedBySource(c *gin.Context) bool {
+	filters := s.apiSourceFilters.Load()
+	if filters == nil {
+		return true
+	}
+	source, ok := filters.api.Allows(c.Request)
+	if !ok {
+		log.Warnf("api-access: refused %s %s from %s (peer %s)", c.Request.Method, c.Request.URL.Path, source, c.Request.RemoteAddr)
+		denySource(c, "", "This source address is not allowed to use the API.")
+		return false
+	}
+	return true
+}
+
+// allowedByKeySource aborts the request with 403 and reports false when the authenticated
+// key may not be used from the request's source.
+func (s *Server) allowedByKeySource(c *gin.Context) bool {
+	filters := s.apiSourceFilters.Load()
+	if filters == nil || len(filters.keys) == 0 {
+		return true
+	}
+	apiKey := c.GetString("apiKey")
+	filter, ok := filters.keys[apiKey]
+	if !ok {
+		return true
+	}
+	source, ok := filters.api.SourceAddr(c.Request)
+	if !ok || filter.AllowsAddr(source) {
+		return true
+	}
+	log.Warnf("api-key-settings: refused %s %s from %s for key %s", c.Request.Method, c.Request.URL.Path, source, util.HideAPIKey(apiKey))
+	denySource(c, apiKey, "This API key is not allowed to be used from this source address.")
+	return false
+}
+
+func denySource(c *gin.Context, apiKey, message string) {
+	if apiKey != "" {
+		usage.GetRequestStatistics().RecordDenied(apiKey)
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: message,
+			Type:    "permission_error",
+			Code:    "source_not_allowed",
+		},
+	})
+}
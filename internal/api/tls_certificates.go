@@ -0,0 +1,175 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certificateReloadDelay lets a renewal that writes the certificate and the key one after
+// the other finish before the pair is read again.
+const certificateReloadDelay = 500 * time.Millisecond
+
+// certificateReloader serves the key pair at tls.cert and tls.key and reads it again when
+// either file changes or the process receives SIGHUP. A pair that fails to load is logged
+// and the previous certificate stays in use.
+type certificateReloader struct {
+	certPath string
+	keyPath  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newCertificateReloader(certPath, keyPath string) (*certificateReloader, error) {
+	r := &certificateReloader{certPath: certPath, keyPath: keyPath}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload reads the key pair and reports whether it differs from the one being served.
+func (r *certificateReloader) reload() (bool, error) {
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := r.cert == nil || len(r.cert.Certificate) == 0 || !bytes.Equal(r.cert.Certificate[0], cert.Certificate[0])
+	r.cert = &cert
+	return changed, nil
+}
+
+func (r *certificateReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// watch reloads the key pair until ctx is done. The directories are watched rather than the
+// files, so that certificates replaced by rename or through a symlink swap, as Kubernetes
+// secret mounts do, are noticed too.
+func (r *certificateReloader) watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var events <-chan fsnotify.Event
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Warnf("tls: cannot watch the certificate files, only SIGHUP reloads them: %v", err)
+	} else {
+		defer func() { _ = watcher.Close() }()
+		for _, dir := range []string{filepath.Dir(r.certPath), filepath.Dir(r.keyPath)} {
+			if errAdd := watcher.Add(dir); errAdd != nil {
+				log.Warnf("tls: cannot watch %s: %v", dir, errAdd)
+			}
+		}
+		events = watcher.Events
+	}
+
+	delay := time.NewTimer(certificateReloadDelay)
+	delay.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			delay.Stop()
+			return
+		case <-hup:
+			r.reloadAndLog("SIGHUP")
+		case _, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			delay.Reset(certificateReloadDelay)
+		case <-delay.C:
+			r.reloadAndLog("file change")
+		}
+	}
+}
+
+func (r *certificateReloader) reloadAndLog(reason string) {
+	changed, err := r.reload()
+	switch {
+	case err != nil:
+		log.Errorf("tls: reloading the certificate after %s failed; keeping the current one: %v", reason, err)
+	case changed:
+		log.Infof("tls: certificate reloaded from %s after %s", r.certPath, reason)
+	}
+}
+
+// setupTLS prepares the certificate source for the listeners: an ACME manager when
+// tls.acme has domains, the reloading key pair otherwise. It starts what has to run in the
+// background, the file watcher or the HTTP-01 challenge listener, until the server stops.
+func (s *Server) setupTLS() (*tls.Config, error) {
+	tlsCfg := s.cfg.TLS
+	ctx, cancel := context.WithCancel(context.Background())
+	if tlsCfg.ACME.Enabled() {
+		cacheDir := tlsCfg.ACME.CacheDirFor(s.configFilePath)
+		if err := os.MkdirAll(cacheDir, 0o700); err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to create ACME cache directory: %v", err)
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(cacheDir),
+			HostPolicy: autocert.HostWhitelist(tlsCfg.ACME.Domains...),
+			Email:      strings.TrimSpace(tlsCfg.ACME.Email),
+		}
+		if directory := strings.TrimSpace(tlsCfg.ACME.DirectoryURL); directory != "" {
+			manager.Client = &acme.Client{DirectoryURL: directory}
+		}
+		if addr := strings.TrimSpace(tlsCfg.ACME.HTTPChallengeAddr); addr != "" {
+			challenge := &http.Server{Addr: addr, Handler: manager.HTTPHandler(nil)}
+			go func() {
+				if errServe := challenge.ListenAndServe(); errServe != nil && !errors.Is(errServe, http.ErrServerClosed) {
+					log.Errorf("tls: ACME HTTP challenge listener on %s stopped: %v", addr, errServe)
+				}
+			}()
+			go func() {
+				<-ctx.Done()
+				_ = challenge.Close()
+			}()
+		}
+		tlsConfig := manager.TLSConfig()
+		if err := applyClientAuth(tlsConfig, tlsCfg.ClientAuth, tlsCfg.ACME.Enabled()); err != nil {
+			cancel()
+			return nil, err
+		}
+		log.Infof("tls: obtaining certificates for %s through ACME", strings.Join(tlsCfg.ACME.Domains, ", "))
+		s.tlsCancel = cancel
+		return tlsConfig, nil
+	}
+
+	reloader, err := newCertificateReloader(strings.TrimSpace(tlsCfg.Cert), strings.TrimSpace(tlsCfg.Key))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	tlsConfig := &tls.Config{GetCertificate: reloader.getCertificate}
+	if err = applyClientAuth(tlsConfig, tlsCfg.ClientAuth, false); err != nil {
+		cancel()
+		return nil, err
+	}
+	go reloader.watch(ctx)
+	s.tlsCancel = cancel
+	return tlsConfig, nil
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestCertificate(t *testing.T, certPath, keyPath, commonName string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	// Write the key first so that the watcher never sees a certificate without its key for long.
+	if err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	if err = os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write certificate: %v", err)
+	}
+}
+
+func servedCommonName(t *testing.T, r *certificateReloader) string {
+	t.Helper()
+	cert, err := r.getCertificate(nil)
+	if err != nil || cert == nil {
+		t.Fatalf("getCertificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("parse served certificate: %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertificateReloaderPicksUpRenewedFiles(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeTestCertificate(t, certPath, keyPath, "first")
+
+	reloader, err := newCertificateReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("newCertificateReloader: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.watch(ctx)
+	// Give the watcher time to register the directory.
+	time.Sleep(100 * time.Millisecond)
+
+	writeTestCertificate(t, certPath, keyPath, "second")
+	deadline := time.Now().Add(5 * time.Second)
+	for servedCommonName(t, reloader) != "second" {
+		if time.Now().After(deadline) {
+			t.Fatal("renewed certificate was not picked up")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// A broken pair is reported and the working certificate keeps being served.
+	if err = os.WriteFile(certPath, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err = reloader.reload(); err == nil {
+		t.Fatal("reload of a broken certificate should fail")
+	}
+	if got := servedCommonName(t, reloader); got != "second" {
+		t.Fatalf("served %q after a failed reload, want second", got)
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+)
+
+// usageLabelsKey is the Gin context key the usage labels of a request are stored under for
+// the usage reporters.
+const usageLabelsKey = "usageLabels"
+
+// usageLabelReader reads the configured usage labels from request headers.
+type usageLabelReader struct {
+	headers  []config.UsageLabelHeader
+	trusted  []netip.Prefix
+	maxValue int
+	// closed is set when trusted-sources does not parse; no labels are read until it does.
+	closed bool
+}
+
+// newUsageLabelReader compiles usage-labels; it returns nil when no label is configured.
+func newUsageLabelReader(cfg config.UsageLabels) *usageLabelReader {
+	headers := cfg.LabelHeaders()
+	if len(headers) == 0 {
+		return nil
+	}
+	r := &usageLabelReader{maxValue: cfg.ValueLimit()}
+	for _, header := range headers {
+		if config.ValidUsageLabelName(header.Name) {
+			r.headers = append(r.headers, header)
+		}
+	}
+	trusted, err := config.ParseIPPrefixes(cfg.TrustedSources)
+	if err != nil {
+		log.Errorf("usage-labels: trusted-sources: %v; ignoring usage labels until the configuration is fixed", err)
+		r.closed = true
+	}
+	r.trusted = trusted
+	return r
+}
+
+// read returns the labels of the request, or nil when it has none or its peer is not trusted.
+// The peer address is used as is: a gateway setting the headers is the peer itself.
+func (r *usageLabelReader) read(c *gin.Context) map[string]string {
+	if r == nil || r.closed || !r.trusts(c.Request.RemoteAddr) {
+		return nil
+	}
+	var labels map[string]string
+	for _, header := range r.headers {
+		value := strings.TrimSpace(c.Request.Header.Get(header.Header))
+		if value == "" {
+			continue
+		}
+		if len(value) > r.maxValue || strings.ContainsFunc(value, unicode.IsControl) {
+			log.Debugf("usage-labels: ignoring %s value of %d bytes", header.Header, len(value))
+			continue
+		}
+		if labels == nil {
+			labels = make(map[string]string, len(r.headers))
+		}
+		labels[header.Name] = value
+	}
+	return labels
+}
+
+// trusts reports whether labels from the peer at remoteAddr are used. Peers without an IP
+// address, such as unix socket clients, are local and always trusted.
+func (r *usageLabelReader) trusts(remoteAddr string) bool {
+	if len(r.trusted) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil {
+		return true
+	}
+	peer = peer.Unmap().WithZone("")
+	for _, prefix := range r.trusted {
+		if prefix.Contains(peer) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyUsageLabels compiles usage-labels for the client routes.
+func (s *Server) applyUsageLabels(cfg *config.Config) {
+	s.usageLabels.Store(newUsageLabelReader(cfg.UsageLabels))
+}
+
+// attachUsageLabels stores the request's usage labels for the usage reporters. Labels the
+// access provider took from the credential, such as JWT claims, win over header labels.
+func (s *Server) attachUsageLabels(c *gin.Context) {
+	labels := s.usageLabels.Load().read(c)
+	if metadata, ok := c.Get("accessMetadata"); ok && s.cfg != nil {
+		for key, value := range metadata.(map[string]string) {
+			name, isLabel := strings.CutPrefix(key, sdkaccess.MetadataUsageLabelPrefix)
+			if !isLabel || !config.ValidUsageLabelName(name) || value == "" || len(value) > s.cfg.UsageLabels.ValueLimit() {
+				continue
+			}
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			labels[name] = value
+		}
+	}
+	if labels != nil {
+		c.Set(usageLabelsKey, labels)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func usageLabelContext(remoteAddr string, headers map[string]string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Request.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		c.Request.Header.Set(name, value)
+	}
+	return c
+}
+
+func TestUsageLabelReader(t *testing.T) {
+	reader := newUsageLabelReader(config.UsageLabels{
+		Headers:        map[string]string{"team": "x-usage-label-team", "project": "X-Usage-Label-Project"},
+		TrustedSources: []string{"10.0.0.10"},
+		MaxValueLength: 8,
+	})
+	headers := map[string]string{
+		"X-Usage-Label-Team":    " infra ",
+		"X-Usage-Label-Project": "too-long-value",
+		"X-Usage-Label-Other":   "ignored",
+	}
+
+	labels := reader.read(usageLabelContext("10.0.0.10:4711", headers))
+	if len(labels) != 1 || labels["team"] != "infra" {
+		t.Fatalf("labels from a trusted gateway = %v, want only team=infra", labels)
+	}
+	if labels := reader.read(usageLabelContext("192.0.2.1:4711", headers)); labels != nil {
+		t.Fatalf("labels from an untrusted peer = %v, want none", labels)
+	}
+	// The gateway is the peer; a forwarded address does not make a client trusted.
+	forwarded := map[string]string{"X-Usage-Label-Team": "infra", "X-Forwarded-For": "10.0.0.10"}
+	if labels := reader.read(usageLabelContext("192.0.2.1:4711", forwarded)); labels != nil {
+		t.Fatalf("labels behind X-Forwarded-For = %v, want none", labels)
+	}
+	control := map[string]string{"X-Usage-Label-Team": "in\x7ffra"}
+	if labels := reader.read(usageLabelContext("10.0.0.10:4711", control)); labels != nil {
+		t.Fatalf("labels with control characters = %v, want none", labels)
+	}
+
+	open := newUsageLabelReader(config.UsageLabels{Headers: map[string]string{"team": "X-Team"}})
+	value := strings.Repeat("a", config.DefaultUsageLabelMaxValueLength)
+	if labels := open.read(usageLabelContext("192.0.2.1:4711", map[string]string{"X-Team": value})); labels["team"] != value {
+		t.Fatalf("labels without trusted-sources = %v, want the header used", labels)
+	}
+	if newUsageLabelReader(config.UsageLabels{}) != nil {
+		t.Fatal("a reader without labels should be nil")
+	}
+	var none *usageLabelReader
+	if labels := none.read(usageLabelContext("10.0.0.10:4711", headers)); labels != nil {
+		t.Fatalf("nil reader returned %v", labels)
+	}
+}
@@ -0,0 +1,147 @@
+// Package bundle packs auth files, and optionally the config file, into a single
+// passphrase-encrypted archive used to move a proxy installation to another machine.
+//
+// A bundle is a fixed header (magic, format version, scrypt salt, GCM nonce) followed by
+// AES-256-GCM ciphertext of a JSON manifest. The header is bound as additional data, so a
+// tampered version or salt fails to decrypt.
+package bundle
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// FormatVersion is the bundle format written by this build.
+const FormatVersion uint16 = 1
+
+var magic = []byte("CPXBUNDL")
+
+const (
+	saltSize   = 16
+	nonceSize  = 12
+	headerSize = 8 + 2 + saltSize + nonceSize
+
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	// ErrNotBundle reports input that does not start with the bundle header.
+	ErrNotBundle = errors.New("not an auth bundle")
+	// ErrWrongPassphrase reports a bundle that does not open with the given passphrase.
+	ErrWrongPassphrase = errors.New("bundle cannot be decrypted: wrong passphrase or corrupted file")
+)
+
+// File is one auth file inside a bundle. Name is relative to the auth directory.
+type File struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+// Contents is the decrypted payload of a bundle.
+type Contents struct {
+	CreatedAt time.Time `json:"created_at"`
+	Files     []File    `json:"files"`
+	// Config holds the raw config file when the bundle was exported with it.
+	Config []byte `json:"config,omitempty"`
+}
+
+// Seal encrypts contents with a key derived from passphrase.
+func Seal(contents Contents, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("bundle: passphrase is empty")
+	}
+	for _, file := range contents.Files {
+		if err := ValidateName(file.Name); err != nil {
+			return nil, err
+		}
+	}
+	plain, err := json.Marshal(contents)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: encode: %w", err)
+	}
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint16(header[8:10], FormatVersion)
+	if _, err = rand.Read(header[10:]); err != nil {
+		return nil, fmt.Errorf("bundle: generate salt: %w", err)
+	}
+	gcm, err := newGCM(passphrase, header[10:10+saltSize])
+	if err != nil {
+		return nil, err
+	}
+	nonce := header[10+saltSize:]
+	return gcm.Seal(header, nonce, plain, header), nil
+}
+
+// Open decrypts a bundle. Bundles written by a newer format version are refused.
+func Open(data []byte, passphrase string) (*Contents, error) {
+	if len(data) < headerSize || !bytes.Equal(data[:8], magic) {
+		return nil, ErrNotBundle
+	}
+	version := binary.BigEndian.Uint16(data[8:10])
+	if version > FormatVersion {
+		return nil, fmt.Errorf("bundle format version %d was created by a newer release (this build reads up to %d); upgrade before importing", version, FormatVersion)
+	}
+	header := data[:headerSize]
+	gcm, err := newGCM(passphrase, header[10:10+saltSize])
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, header[10+saltSize:], data[headerSize:], header)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	var contents Contents
+	if err = json.Unmarshal(plain, &contents); err != nil {
+		return nil, fmt.Errorf("bundle: decode manifest: %w", err)
+	}
+	for _, file := range contents.Files {
+		if err = ValidateName(file.Name); err != nil {
+			return nil, err
+		}
+		if !json.Valid(file.Data) {
+			return nil, fmt.Errorf("bundle: %s is not a valid JSON auth file", file.Name)
+		}
+	}
+	return &contents, nil
+}
+
+// ValidateName rejects absolute paths and names that escape the auth directory.
+func ValidateName(name string) error {
+	if name == "" || strings.Contains(name, "\\") || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return fmt.Errorf("bundle: invalid auth file name %q", name)
+	}
+	if !strings.HasSuffix(strings.ToLower(name), ".json") {
+		return fmt.Errorf("bundle: %s is not a .json auth file", name)
+	}
+	return nil
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	return gcm, nil
+}
@@ -0,0 +1,57 @@
+package bundle
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	contents := Contents{
+		Files:  []File{{Name: "gemini-user.json", Data: []byte(`{"type":"gemini"}`)}, {Name: "team/codex.json", Data: []byte(`{"type":"codex"}`)}},
+		Config: []byte("port: 8317\n"),
+	}
+	sealed, err := Seal(contents, "correct horse")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if strings.Contains(string(sealed), "gemini") {
+		t.Fatal("bundle leaks plaintext")
+	}
+	opened, err := Open(sealed, "correct horse")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if len(opened.Files) != 2 || opened.Files[1].Name != "team/codex.json" || string(opened.Config) != "port: 8317\n" {
+		t.Fatalf("unexpected contents: %+v", opened)
+	}
+	if _, err = Open(sealed, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Open with wrong passphrase: got %v", err)
+	}
+}
+
+func TestOpenRefusesNewerFormat(t *testing.T) {
+	sealed, err := Seal(Contents{}, "pass")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	binary.BigEndian.PutUint16(sealed[8:10], FormatVersion+1)
+	if _, err = Open(sealed, "pass"); err == nil || !strings.Contains(err.Error(), "newer release") {
+		t.Fatalf("expected newer-format refusal, got %v", err)
+	}
+	if _, err = Open([]byte("{}"), "pass"); !errors.Is(err, ErrNotBundle) {
+		t.Fatalf("expected ErrNotBundle, got %v", err)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"../escape.json", "/etc/passwd.json", "a\\b.json", "notes.txt", ""} {
+		if err := ValidateName(name); err == nil {
+			t.Fatalf("ValidateName(%q) accepted an unsafe name", name)
+		}
+	}
+	if err := ValidateName("dir/ok.json"); err != nil {
+		t.Fatalf("ValidateName rejected a nested name: %v", err)
+	}
+}
@@ -14,7 +14,7 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
-	log "github.com/sirupsen/logrus"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 )
 
 // OAuth configuration constants for Claude/Anthropic
@@ -38,6 +38,16 @@ type ClaudeTokenStorage struct {
 	Expire string `json:"expired"`
 }
 
+// MarshalToken returns the JSON SaveTokenToFile writes.
+func (ts *ClaudeTokenStorage) MarshalToken() ([]byte, error) {
+	ts.Type = "claude"
+	data, err := json.Marshal(ts)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
 // SaveTokenToFile serializes the Claude token storage to a JSON file.
 // This method creates the necessary directory structure and writes the token
 // data in JSON format to the specified file path for persistent storage.
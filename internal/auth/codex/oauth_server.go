@@ -6,11 +6,12 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 )
 
 // OAuthServer handles the local HTTP server for OAuth callbacks.
@@ -19,6 +20,8 @@ import (
 type OAuthServer struct {
 	// server is the underlying HTTP server instance
 	server *http.Server
+	// host is the interface the server binds to; empty means all interfaces
+	host string
 	// port is the port number on which the server listens
 	port int
 	// resultChan is a channel for sending OAuth results
@@ -60,6 +63,11 @@ func NewOAuthServer(port int) *OAuthServer {
 	}
 }
 
+// SetHost sets the interface the callback server binds to; empty means all interfaces.
+func (s *OAuthServer) SetHost(host string) {
+	s.host = strings.TrimSpace(host)
+}
+
 // Start starts the OAuth callback server.
 // It sets up the HTTP handlers for the callback and success endpoints,
 // and begins listening on the specified port.
@@ -84,7 +92,7 @@ func (s *OAuthServer) Start() error {
 	mux.HandleFunc("/success", s.handleSuccess)
 
 	s.server = &http.Server{
-		Addr:         fmt.Sprintf(":%d", s.port),
+		Addr:         net.JoinHostPort(s.host, strconv.Itoa(s.port)),
 		Handler:      mux,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
@@ -295,7 +303,7 @@ func (s *OAuthServer) sendResult(result *OAuthResult) {
 // Returns:
 //   - bool: True if the port is available, false otherwise
 func (s *OAuthServer) isPortAvailable() bool {
-	addr := fmt.Sprintf(":%d", s.port)
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		return false
@@ -24,3 +24,9 @@ func (ts *EmptyStorage) SaveTokenToFile(_ string) error {
 	ts.Type = "empty"
 	return nil
 }
+
+// MarshalToken returns nil, as SaveTokenToFile writes nothing.
+func (ts *EmptyStorage) MarshalToken() ([]byte, error) {
+	ts.Type = "empty"
+	return nil, nil
+}
@@ -0,0 +1,288 @@
+// Package filecrypt provides optional at-rest encryption for auth token files.
+// Encrypted files start with a marker line followed by the base64 of a scrypt salt, a GCM
+// nonce and the AES-256-GCM ciphertext, so encrypted and plaintext JSON files can live side
+// by side in an auth directory.
+package filecrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// EnvKey names the environment variable holding the auth file encryption secret.
+const EnvKey = "CLIPROXY_AUTH_KEY"
+
+// keychainService is the service name looked up in the OS keychain when EnvKey is unset.
+const keychainService = "cli-proxy-api"
+
+// marker prefixes every encrypted auth file; it is also bound as GCM additional data.
+var marker = []byte("CLIPROXY-ENCRYPTED:v1\n")
+
+// The secret is usually a passphrase, so keys are stretched with scrypt, using the same cost
+// as auth bundles.
+const (
+	saltSize = 16
+	scryptN  = 1 << 15
+	scryptR  = 8
+	scryptP  = 1
+)
+
+var (
+	// ErrKeyUnavailable reports an encrypted file read without any configured key.
+	ErrKeyUnavailable = errors.New("auth file is encrypted but no key is configured (set " + EnvKey + "); re-login required")
+	// ErrDecrypt reports an encrypted file that does not open with the configured key.
+	ErrDecrypt = errors.New("auth file cannot be decrypted with the configured key; re-login required")
+)
+
+var (
+	keyOnce sync.Once
+	keyMu   sync.Mutex
+	secret  string
+	// writeSalt is the salt of every file this process encrypts, so a key is derived once
+	// for writing, and keys holds the keys derived so far by salt.
+	writeSalt []byte
+	keys      map[string][]byte
+)
+
+// currentSecret returns the encryption secret, or "" when encryption is not configured.
+func currentSecret() string {
+	keyOnce.Do(func() {
+		value := strings.TrimSpace(os.Getenv(EnvKey))
+		if value == "" {
+			value = keychainSecret()
+		}
+		keyMu.Lock()
+		setSecretLocked(value)
+		keyMu.Unlock()
+	})
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	return secret
+}
+
+// SetKey overrides the encryption secret; an empty secret disables encryption.
+// It is intended for embedders and tests that do not use the environment.
+func SetKey(value string) {
+	keyOnce.Do(func() {})
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	setSecretLocked(strings.TrimSpace(value))
+}
+
+func setSecretLocked(value string) {
+	secret = value
+	writeSalt = nil
+	keys = nil
+}
+
+// keyFor derives, or returns the cached, AES-256 key of the current secret for salt.
+func keyFor(salt []byte) ([]byte, error) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if secret == "" {
+		return nil, ErrKeyUnavailable
+	}
+	if key, ok := keys[string(salt)]; ok {
+		return key, nil
+	}
+	key, err := scrypt.Key([]byte(secret), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("filecrypt: derive key: %w", err)
+	}
+	if keys == nil {
+		keys = make(map[string][]byte)
+	}
+	keys[string(salt)] = key
+	return key, nil
+}
+
+// saltForWrite returns the salt files are encrypted with, generating it on first use.
+func saltForWrite() ([]byte, error) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if writeSalt == nil {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("filecrypt: generate salt: %w", err)
+		}
+		writeSalt = salt
+	}
+	return writeSalt, nil
+}
+
+// keychainSecret reads the secret from the platform keychain CLI when one is installed.
+func keychainSecret() string {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		if _, err := exec.LookPath("security"); err != nil {
+			return ""
+		}
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-w")
+	case "linux", "freebsd":
+		if _, err := exec.LookPath("secret-tool"); err != nil {
+			return ""
+		}
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService)
+	default:
+		return ""
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Enabled reports whether an encryption key is configured.
+func Enabled() bool {
+	return currentSecret() != ""
+}
+
+// IsEncrypted reports whether data carries the encrypted file marker.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, marker)
+}
+
+// Encrypt seals plaintext with the configured key.
+func Encrypt(plain []byte) ([]byte, error) {
+	if currentSecret() == "" {
+		return nil, fmt.Errorf("filecrypt: %s is not set", EnvKey)
+	}
+	salt, err := saltForWrite()
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(salt)
+	if err != nil {
+		return nil, err
+	}
+	sealed := make([]byte, saltSize+gcm.NonceSize(), saltSize+gcm.NonceSize()+len(plain)+gcm.Overhead())
+	copy(sealed, salt)
+	nonce := sealed[saltSize:]
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("filecrypt: generate nonce: %w", err)
+	}
+	sealed = gcm.Seal(sealed, nonce, plain, marker)
+	out := make([]byte, len(marker)+base64.StdEncoding.EncodedLen(len(sealed)))
+	copy(out, marker)
+	base64.StdEncoding.Encode(out[len(marker):], sealed)
+	return out, nil
+}
+
+// Decrypt opens an encrypted file body. Plaintext input is returned unchanged.
+func Decrypt(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if currentSecret() == "" {
+		return nil, ErrKeyUnavailable
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data[len(marker):])))
+	if err != nil || len(sealed) < saltSize {
+		return nil, ErrDecrypt
+	}
+	gcm, err := newGCM(sealed[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[saltSize:]
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], marker)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+// Seal encrypts data when a key is configured and data is not already encrypted.
+func Seal(data []byte) ([]byte, error) {
+	if !Enabled() || IsEncrypted(data) {
+		return data, nil
+	}
+	return Encrypt(data)
+}
+
+// ReadFile reads an auth file and decrypts it when needed.
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(data)
+}
+
+// EncryptFileInPlace encrypts a plaintext auth file, reporting whether it was rewritten.
+func EncryptFileInPlace(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if len(data) == 0 || IsEncrypted(data) {
+		return false, nil
+	}
+	sealed, err := Encrypt(data)
+	if err != nil {
+		return false, err
+	}
+	if err = WriteFileAtomic(path, sealed, 0o600); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it into place.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Chmod(tmpName, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpName, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmpName)
+	}
+	return err
+}
+
+func newGCM(salt []byte) (cipher.AEAD, error) {
+	key, err := keyFor(salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("filecrypt: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("filecrypt: %w", err)
+	}
+	return gcm, nil
+}
@@ -0,0 +1,65 @@
+package filecrypt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	SetKey("test-secret")
+	t.Cleanup(func() { SetKey("") })
+
+	plain := []byte(`{"type":"claude","refresh_token":"rt"}`)
+	sealed, err := Encrypt(plain)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !IsEncrypted(sealed) {
+		t.Fatal("sealed data missing marker")
+	}
+	got, err := Decrypt(sealed)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if string(got) != string(plain) {
+		t.Fatalf("Decrypt = %q, want %q", got, plain)
+	}
+	if passthrough, _ := Decrypt(plain); string(passthrough) != string(plain) {
+		t.Fatal("plaintext should pass through Decrypt unchanged")
+	}
+
+	SetKey("other-secret")
+	if _, err = Decrypt(sealed); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("wrong key error = %v, want ErrDecrypt", err)
+	}
+	SetKey("")
+	if _, err = Decrypt(sealed); !errors.Is(err, ErrKeyUnavailable) {
+		t.Fatalf("missing key error = %v, want ErrKeyUnavailable", err)
+	}
+}
+
+func TestEncryptFileInPlace(t *testing.T) {
+	SetKey("test-secret")
+	t.Cleanup(func() { SetKey("") })
+
+	path := filepath.Join(t.TempDir(), "auth.json")
+	if err := os.WriteFile(path, []byte(`{"type":"codex"}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	changed, err := EncryptFileInPlace(path)
+	if err != nil || !changed {
+		t.Fatalf("EncryptFileInPlace = %v, %v; want true, nil", changed, err)
+	}
+	if changed, err = EncryptFileInPlace(path); err != nil || changed {
+		t.Fatalf("second pass = %v, %v; want false, nil", changed, err)
+	}
+	data, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != `{"type":"codex"}` {
+		t.Fatalf("ReadFile = %q", data)
+	}
+}
@@ -18,9 +18,9 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/codex"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/browser"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
-	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"golang.org/x/net/proxy"
 
@@ -52,7 +52,11 @@ type GeminiAuth struct {
 type WebLoginOptions struct {
 	NoBrowser    bool
 	CallbackPort int
-	Prompt       func(string) (string, error)
+	// CallbackHost is the interface the callback server binds to; empty means all interfaces.
+	CallbackHost string
+	// CallbackTimeout bounds the wait for the browser redirect; zero means five minutes.
+	CallbackTimeout time.Duration
+	Prompt          func(string) (string, error)
 }
 
 // NewGeminiAuth creates a new instance of GeminiAuth.
@@ -238,7 +242,15 @@ func (g *GeminiAuth) getTokenFromWeb(ctx context.Context, config *oauth2.Config,
 
 	// Create a new HTTP server with its own multiplexer.
 	mux := http.NewServeMux()
-	server := &http.Server{Addr: fmt.Sprintf(":%d", callbackPort), Handler: mux}
+	callbackHost := ""
+	callbackTimeout := misc.DefaultOAuthCallbackTimeout
+	if opts != nil {
+		callbackHost = opts.CallbackHost
+		if opts.CallbackTimeout > 0 {
+			callbackTimeout = opts.CallbackTimeout
+		}
+	}
+	server := &http.Server{Addr: misc.OAuthCallbackListenAddr(callbackHost, callbackPort), Handler: mux}
 	config.RedirectURL = callbackURL
 
 	mux.HandleFunc("/oauth2callback", func(w http.ResponseWriter, r *http.Request) {
@@ -312,11 +324,12 @@ func (g *GeminiAuth) getTokenFromWeb(ctx context.Context, config *oauth2.Config,
 		fmt.Printf("Please open this URL in your browser:\n\n%s\n", authURL)
 	}
 
+	misc.PrintOAuthCallbackListening(callbackHost, callbackPort, "/oauth2callback")
 	fmt.Println("Waiting for authentication callback...")
 
 	// Wait for the authorization code or an error.
 	var authCode string
-	timeoutTimer := time.NewTimer(5 * time.Minute)
+	timeoutTimer := time.NewTimer(callbackTimeout)
 	defer timeoutTimer.Stop()
 
 	var manualPromptTimer *time.Timer
@@ -368,7 +381,7 @@ waitForCallback:
 			authCode = parsed.Code
 			break waitForCallback
 		case <-timeoutTimer.C:
-			return nil, fmt.Errorf("oauth flow timed out")
+			return nil, misc.OAuthCallbackTimeoutError("gemini", callbackTimeout, callbackPort)
 		}
 	}
 
@@ -10,8 +10,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
-	log "github.com/sirupsen/logrus"
 )
 
 // GeminiTokenStorage stores OAuth2 token information for Google Gemini API authentication.
@@ -37,6 +37,16 @@ type GeminiTokenStorage struct {
 	Type string `json:"type"`
 }
 
+// MarshalToken returns the JSON SaveTokenToFile writes.
+func (ts *GeminiTokenStorage) MarshalToken() ([]byte, error) {
+	ts.Type = "gemini"
+	data, err := json.Marshal(ts)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
 // SaveTokenToFile serializes the Gemini token storage to a JSON file.
 // This method creates the necessary directory structure and writes the token
 // data in JSON format to the specified file path for persistent storage.
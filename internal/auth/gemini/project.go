@@ -0,0 +1,31 @@
+package gemini
+
+import (
+	"fmt"
+	"strings"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// SetAuthProject updates the stored Google Cloud project of a Gemini OAuth credential in place.
+// Credentials synthesized from a multi-project file must be updated through their parent.
+func SetAuthProject(auth *coreauth.Auth, projectID string) error {
+	if auth == nil {
+		return fmt.Errorf("auth is nil")
+	}
+	provider := strings.ToLower(strings.TrimSpace(auth.Provider))
+	if provider != "gemini" && provider != "gemini-cli" {
+		return fmt.Errorf("%s is a %s credential; project selection only applies to Gemini", auth.ID, auth.Provider)
+	}
+	if auth.Attributes != nil && strings.EqualFold(auth.Attributes["runtime_only"], "true") {
+		return fmt.Errorf("%s is derived from a multi-project credential; update %s instead", auth.ID, auth.Attributes["gemini_virtual_parent"])
+	}
+	if auth.Metadata == nil {
+		return fmt.Errorf("%s has no stored token metadata", auth.ID)
+	}
+	auth.Metadata["project_id"] = strings.TrimSpace(projectID)
+	// The new project has not been verified for Cloud AI API enablement yet.
+	auth.Metadata["checked"] = false
+	auth.Metadata["auto"] = false
+	return nil
+}
@@ -23,6 +23,16 @@ type IFlowTokenStorage struct {
 	Type         string `json:"type"`
 }
 
+// MarshalToken returns the JSON SaveTokenToFile writes.
+func (ts *IFlowTokenStorage) MarshalToken() ([]byte, error) {
+	ts.Type = "iflow"
+	data, err := json.Marshal(ts)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
 // SaveTokenToFile serialises the token storage to disk.
 func (ts *IFlowTokenStorage) SaveTokenToFile(authFilePath string) error {
 	misc.LogSavingCredentials(authFilePath)
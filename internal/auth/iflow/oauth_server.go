@@ -5,11 +5,12 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 )
 
 const errorRedirectURL = "https://iflow.cn/oauth/error"
@@ -24,6 +25,7 @@ type OAuthResult struct {
 // OAuthServer provides a minimal HTTP server for handling the iFlow OAuth callback.
 type OAuthServer struct {
 	server  *http.Server
+	host    string
 	port    int
 	result  chan *OAuthResult
 	errChan chan error
@@ -40,6 +42,11 @@ func NewOAuthServer(port int) *OAuthServer {
 	}
 }
 
+// SetHost sets the interface the callback listener binds to; empty means all interfaces.
+func (s *OAuthServer) SetHost(host string) {
+	s.host = strings.TrimSpace(host)
+}
+
 // Start launches the callback listener.
 func (s *OAuthServer) Start() error {
 	s.mu.Lock()
@@ -55,7 +62,7 @@ func (s *OAuthServer) Start() error {
 	mux.HandleFunc("/oauth2callback", s.handleCallback)
 
 	s.server = &http.Server{
-		Addr:         fmt.Sprintf(":%d", s.port),
+		Addr:         net.JoinHostPort(s.host, strconv.Itoa(s.port)),
 		Handler:      mux,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
@@ -133,7 +140,7 @@ func (s *OAuthServer) sendResult(res *OAuthResult) {
 }
 
 func (s *OAuthServer) isPortAvailable() bool {
-	addr := fmt.Sprintf(":%d", s.port)
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		return false
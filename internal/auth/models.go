@@ -15,3 +15,12 @@ type TokenStorage interface {
 	//   - error: An error if the save operation fails, nil otherwise
 	SaveTokenToFile(authFilePath string) error
 }
+
+// TokenMarshaler is implemented by token storages that can encode themselves in memory,
+// exactly as SaveTokenToFile would write them, so the file can be encrypted before any of it
+// reaches the disk.
+type TokenMarshaler interface {
+	// MarshalToken returns the file contents SaveTokenToFile would write, or nil when it
+	// would write nothing.
+	MarshalToken() ([]byte, error)
+}
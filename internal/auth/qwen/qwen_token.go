@@ -32,6 +32,16 @@ type QwenTokenStorage struct {
 	Expire string `json:"expired"`
 }
 
+// MarshalToken returns the JSON SaveTokenToFile writes.
+func (ts *QwenTokenStorage) MarshalToken() ([]byte, error) {
+	ts.Type = "qwen"
+	data, err := json.Marshal(ts)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
 // SaveTokenToFile serializes the Qwen token storage to a JSON file.
 // This method creates the necessary directory structure and writes the token
 // data in JSON format to the specified file path for persistent storage.
@@ -8,8 +8,8 @@ import (
 	"os"
 	"path/filepath"
 
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
-	log "github.com/sirupsen/logrus"
 )
 
 // VertexCredentialStorage stores the service account JSON for Vertex AI access.
@@ -32,6 +32,22 @@ type VertexCredentialStorage struct {
 	Type string `json:"type"`
 }
 
+// MarshalToken returns the JSON SaveTokenToFile writes.
+func (s *VertexCredentialStorage) MarshalToken() ([]byte, error) {
+	if s == nil {
+		return nil, fmt.Errorf("vertex credential: storage is nil")
+	}
+	if s.ServiceAccount == nil {
+		return nil, fmt.Errorf("vertex credential: service account content is empty")
+	}
+	s.Type = "vertex"
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("vertex credential: encode failed: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
 // SaveTokenToFile writes the credential payload to the given file path in JSON format.
 // It ensures the parent directory exists and logs the operation for transparency.
 func (s *VertexCredentialStorage) SaveTokenToFile(authFilePath string) error {
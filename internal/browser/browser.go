@@ -7,7 +7,7 @@ import (
 	"os/exec"
 	"runtime"
 
-	log "github.com/sirupsen/logrus"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/skratchdot/open-golang/open"
 )
 
@@ -0,0 +1,305 @@
+// Package cloudconfig fetches the configuration file from an object store, an HTTPS URL or a
+// mounted file such as a Kubernetes ConfigMap for cloud deploy mode, keeps a validated local
+// copy, and refreshes it periodically so that remote changes reach the server through the
+// config file watcher.
+package cloudconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// DefaultRefreshInterval is how often the remote configuration is fetched again.
+const DefaultRefreshInterval = 5 * time.Minute
+
+// fetchTimeout bounds a single fetch.
+const fetchTimeout = 30 * time.Second
+
+// maxConfigSize caps the size of a fetched configuration.
+const maxConfigSize = 8 << 20
+
+// gcsReadScope is the OAuth scope used to read GCS objects.
+const gcsReadScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+// Source is a remote configuration file: gs://bucket/object, s3://bucket/key, an http(s) URL
+// or file:///path. A file source suits a ConfigMap mount: the kubelet replaces the mounted
+// file through a symlink swap, which polling its contents notices where watching it does not.
+type Source struct {
+	raw      string
+	location *url.URL
+	client   *http.Client
+	s3       *minio.Client
+
+	mu   sync.Mutex
+	last []byte
+}
+
+// New parses rawURL, which may also be an absolute path, and prepares the client for its scheme. Credentials come from the
+// environment: Google application default credentials (including instance metadata) for gs://,
+// and the AWS environment, shared credentials file or instance role for s3://.
+func New(rawURL string) (*Source, error) {
+	raw := strings.TrimSpace(rawURL)
+	location, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("cloud config: invalid url %q: %w", rawURL, err)
+	}
+	if location.Scheme == "" && filepath.IsAbs(raw) {
+		location = &url.URL{Scheme: "file", Path: raw}
+	}
+	s := &Source{raw: raw, location: location, client: &http.Client{Timeout: fetchTimeout}}
+	switch location.Scheme {
+	case "file":
+		if location.Host != "" || !filepath.IsAbs(location.Path) {
+			return nil, fmt.Errorf("cloud config: invalid url %q: want file:///absolute/path", rawURL)
+		}
+	case "http", "https":
+		if location.Host == "" {
+			return nil, fmt.Errorf("cloud config: invalid url %q: missing host", rawURL)
+		}
+	case "gs", "s3":
+		if location.Host == "" || strings.Trim(location.Path, "/") == "" {
+			return nil, fmt.Errorf("cloud config: invalid url %q: want %s://bucket/path", rawURL, location.Scheme)
+		}
+		if location.Scheme == "s3" {
+			if s.s3, err = NewS3Client(); err != nil {
+				return nil, fmt.Errorf("cloud config: %w", err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("cloud config: unsupported url %q: use gs://, s3://, https:// or file://", rawURL)
+	}
+	return s, nil
+}
+
+// String returns the configured URL.
+func (s *Source) String() string { return s.raw }
+
+// NewS3Client returns a client for s3:// URLs. AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL points
+// it at an S3-compatible service; credentials come from the AWS environment, the shared
+// credentials file or the instance role.
+func NewS3Client() (*minio.Client, error) {
+	endpoint := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL")
+	secure := true
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+	} else if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		endpoint, secure = u.Host, u.Scheme != "http"
+	}
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{Client: &http.Client{Timeout: fetchTimeout}},
+	})
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: secure,
+		Region: firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create s3 client: %w", err)
+	}
+	return client, nil
+}
+
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Fetch downloads the remote configuration.
+func (s *Source) Fetch(ctx context.Context) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	var (
+		body io.ReadCloser
+		err  error
+	)
+	switch s.location.Scheme {
+	case "file":
+		body, err = os.Open(s.location.Path)
+	case "s3":
+		body, err = s.fetchS3(ctx)
+	case "gs":
+		body, err = s.fetchGCS(ctx)
+	default:
+		body, err = s.get(ctx, s.raw, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", s.raw, err)
+	}
+	defer func() { _ = body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(body, maxConfigSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", s.raw, err)
+	}
+	if len(data) > maxConfigSize {
+		return nil, fmt.Errorf("fetch %s: larger than %d bytes", s.raw, maxConfigSize)
+	}
+	return data, nil
+}
+
+func (s *Source) fetchS3(ctx context.Context) (io.ReadCloser, error) {
+	object, err := s.s3.GetObject(ctx, s.location.Host, strings.TrimPrefix(s.location.Path, "/"), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy; Stat surfaces missing objects and denied credentials here.
+	if _, err = object.Stat(); err != nil {
+		_ = object.Close()
+		return nil, err
+	}
+	return object, nil
+}
+
+// fetchGCS reads the object through the GCS JSON API. STORAGE_EMULATOR_HOST, as understood
+// by the Google client libraries, points it at an emulator without credentials.
+func (s *Source) fetchGCS(ctx context.Context) (io.ReadCloser, error) {
+	base := "https://storage.googleapis.com"
+	var tokens oauth2.TokenSource
+	if emulator := firstEnv("STORAGE_EMULATOR_HOST"); emulator != "" {
+		base = emulator
+		if !strings.Contains(base, "://") {
+			base = "http://" + base
+		}
+	} else {
+		var err error
+		if tokens, err = google.DefaultTokenSource(ctx, gcsReadScope); err != nil {
+			return nil, fmt.Errorf("google credentials: %w", err)
+		}
+	}
+	object := url.PathEscape(strings.TrimPrefix(s.location.Path, "/"))
+	endpoint := strings.TrimRight(base, "/") + "/storage/v1/b/" + url.PathEscape(s.location.Host) + "/o/" + object + "?alt=media"
+	return s.get(ctx, endpoint, tokens)
+}
+
+func (s *Source) get(ctx context.Context, endpoint string, tokens oauth2.TokenSource) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if tokens != nil {
+		token, errToken := tokens.Token()
+		if errToken != nil {
+			return nil, fmt.Errorf("google credentials: %w", errToken)
+		}
+		token.SetAuthHeader(req)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return resp.Body, nil
+}
+
+// Sync fetches the remote configuration and, when it differs from the last one written,
+// validates it and replaces the file at path. It reports whether the file was replaced.
+// The comparison is against the last fetched content, so local edits made through the
+// management API are only overwritten when the remote file changes.
+func (s *Source) Sync(ctx context.Context, path string) (bool, error) {
+	data, err := s.Fetch(ctx)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last != nil && bytes.Equal(s.last, data) {
+		return false, nil
+	}
+	if err = writeValidated(path, data); err != nil {
+		return false, fmt.Errorf("%s: %w", s.raw, err)
+	}
+	s.last = data
+	return true, nil
+}
+
+// writeValidated loads data as a configuration from a temporary file next to path and moves
+// it into place only if it loads and validates.
+func writeValidated(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".remote-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() { _ = os.Remove(tmpName) }()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = CheckFile(tmpName); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
+
+// CheckFile reports why the configuration file at path cannot start the server, or nil if
+// it loads, sets a port and validates.
+func CheckFile(path string) error {
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if cfg.Port == 0 {
+		return errors.New("invalid configuration: port is not set")
+	}
+	if err = cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+// Run re-fetches the configuration every interval until ctx is done. Changes are written
+// to path, where the config watcher picks them up; failures keep the current file.
+func (s *Source) Run(ctx context.Context, path string, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := s.Sync(ctx, path)
+			switch {
+			case err != nil:
+				log.Warnf("cloud config: refresh failed, keeping the current configuration: %v", err)
+			case changed:
+				log.Infof("cloud config: configuration changed at %s; reloading", s.raw)
+			}
+		}
+	}
+}
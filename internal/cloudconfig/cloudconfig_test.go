@@ -0,0 +1,111 @@
+package cloudconfig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSyncWritesOnlyValidChangedConfig(t *testing.T) {
+	var mu sync.Mutex
+	remote := "port: 8317\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = w.Write([]byte(remote))
+	}))
+	defer server.Close()
+	setRemote := func(data string) {
+		mu.Lock()
+		remote = data
+		mu.Unlock()
+	}
+
+	source, err := New(server.URL + "/config.yaml")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if changed, errSync := source.Sync(context.Background(), path); errSync != nil || !changed {
+		t.Fatalf("first sync = %t, %v", changed, errSync)
+	}
+	if changed, errSync := source.Sync(context.Background(), path); errSync != nil || changed {
+		t.Fatalf("unchanged remote config should not rewrite the file: %t, %v", changed, errSync)
+	}
+
+	setRemote("port: 8317\nlogging:\n  output: carrier-pigeon\n")
+	if _, errSync := source.Sync(context.Background(), path); errSync == nil || !strings.Contains(errSync.Error(), "carrier-pigeon") {
+		t.Fatalf("an invalid remote config should be rejected, got %v", errSync)
+	}
+	// Loading may have added migrated defaults, but the port must still be the accepted one.
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "port: 8317\n") {
+		t.Fatalf("rejected config replaced the cached copy:\n%s", data)
+	}
+
+	setRemote("port: 9000\n")
+	if changed, errSync := source.Sync(context.Background(), path); errSync != nil || !changed {
+		t.Fatalf("changed remote config not written: %t, %v", changed, errSync)
+	}
+}
+
+func TestFetchGCSObjectFromEmulator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/storage/v1/b/my-bucket/o/deploy%2Fconfig.yaml" || r.URL.Query().Get("alt") != "media" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("port: 8317\n"))
+	}))
+	defer server.Close()
+	t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	source, err := New("gs://my-bucket/deploy/config.yaml")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	data, err := source.Fetch(context.Background())
+	if err != nil || string(data) != "port: 8317\n" {
+		t.Fatalf("Fetch = %q, %v", data, err)
+	}
+}
+
+func TestNewRejectsUnsupportedURLs(t *testing.T) {
+	for _, raw := range []string{"ftp://host/config.yaml", "gs://bucket-only", "https:///config.yaml"} {
+		if _, err := New(raw); err == nil {
+			t.Fatalf("New(%q) should fail", raw)
+		}
+	}
+}
+
+func TestSyncFromMountedFile(t *testing.T) {
+	dir := t.TempDir()
+	mounted := filepath.Join(dir, "configmap", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(mounted), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mounted, []byte("port: 8317\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, raw := range []string{"file://" + filepath.ToSlash(mounted), mounted} {
+		source, err := New(raw)
+		if err != nil {
+			t.Fatalf("New(%q): %v", raw, err)
+		}
+		path := filepath.Join(dir, "config.yaml")
+		if changed, errSync := source.Sync(context.Background(), path); errSync != nil || !changed {
+			t.Fatalf("sync from %q = %t, %v", raw, changed, errSync)
+		}
+		if err = CheckFile(path); err != nil {
+			t.Fatalf("CheckFile: %v", err)
+		}
+	}
+	if _, err := New("file://relative/config.yaml"); err == nil {
+		t.Fatal("a file url with a host should be rejected")
+	}
+}
@@ -8,8 +8,8 @@ import (
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/claude"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
-	log "github.com/sirupsen/logrus"
 )
 
 // DoClaudeLogin triggers the Claude OAuth flow through the shared authentication manager.
@@ -32,10 +32,12 @@ func DoClaudeLogin(cfg *config.Config, options *LoginOptions) {
 	manager := newAuthManager()
 
 	authOpts := &sdkAuth.LoginOptions{
-		NoBrowser:    options.NoBrowser,
-		CallbackPort: options.CallbackPort,
-		Metadata:     map[string]string{},
-		Prompt:       promptFn,
+		NoBrowser:       options.NoBrowser,
+		CallbackPort:    options.CallbackPort,
+		CallbackHost:    options.CallbackHost,
+		CallbackTimeout: options.CallbackTimeout,
+		Metadata:        map[string]string{},
+		Prompt:          promptFn,
 	}
 
 	_, savedPath, err := manager.Login(context.Background(), "claude", cfg, authOpts)
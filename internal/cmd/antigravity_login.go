@@ -5,8 +5,8 @@ import (
 	"fmt"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
-	log "github.com/sirupsen/logrus"
 )
 
 // DoAntigravityLogin triggers the OAuth flow for the antigravity provider and saves tokens.
@@ -22,10 +22,12 @@ func DoAntigravityLogin(cfg *config.Config, options *LoginOptions) {
 
 	manager := newAuthManager()
 	authOpts := &sdkAuth.LoginOptions{
-		NoBrowser:    options.NoBrowser,
-		CallbackPort: options.CallbackPort,
-		Metadata:     map[string]string{},
-		Prompt:       promptFn,
+		NoBrowser:       options.NoBrowser,
+		CallbackPort:    options.CallbackPort,
+		CallbackHost:    options.CallbackHost,
+		CallbackTimeout: options.CallbackTimeout,
+		Metadata:        map[string]string{},
+		Prompt:          promptFn,
 	}
 
 	record, savedPath, err := manager.Login(context.Background(), "antigravity", cfg, authOpts)
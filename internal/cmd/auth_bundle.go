@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/bundle"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/filecrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// DoExportAuthBundle packs every auth file from the configured auth directories, and the
+// config file when includeConfig is set, into a passphrase-encrypted bundle at outPath.
+// Encrypted auth files are decrypted first so the bundle opens on a machine with a different key.
+func DoExportAuthBundle(cfg *config.Config, configPath, outPath, passphraseEnv string, includeConfig bool) {
+	passphrase, ok := bundlePassphrase("auth-export", passphraseEnv)
+	if !ok {
+		return
+	}
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	contents := bundle.Contents{CreatedAt: time.Now().UTC()}
+	seen := make(map[string]string)
+	for _, dir := range cfg.AuthDirectories() {
+		resolved, errResolve := util.ResolveAuthDir(dir)
+		if errResolve != nil {
+			log.Errorf("auth-export: resolve %s failed: %v", dir, errResolve)
+			return
+		}
+		errWalk := filepath.WalkDir(resolved, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".json") {
+				return nil
+			}
+			rel, errRel := filepath.Rel(resolved, path)
+			if errRel != nil {
+				return errRel
+			}
+			name := filepath.ToSlash(rel)
+			if first, dup := seen[name]; dup {
+				log.Warnf("auth-export: skipping %s, %s was already exported from %s", path, name, first)
+				return nil
+			}
+			data, errRead := filecrypt.ReadFile(path)
+			if errRead != nil {
+				return fmt.Errorf("%s: %w", path, errRead)
+			}
+			seen[name] = resolved
+			contents.Files = append(contents.Files, bundle.File{Name: name, Data: data})
+			return nil
+		})
+		if errWalk != nil && !errors.Is(errWalk, fs.ErrNotExist) {
+			log.Errorf("auth-export: %v", errWalk)
+			return
+		}
+	}
+	if includeConfig {
+		data, errRead := os.ReadFile(configPath)
+		if errRead != nil {
+			log.Errorf("auth-export: read config %s failed: %v", configPath, errRead)
+			return
+		}
+		contents.Config = data
+	}
+	sealed, errSeal := bundle.Seal(contents, passphrase)
+	if errSeal != nil {
+		log.Errorf("auth-export: %v", errSeal)
+		return
+	}
+	out, errCreate := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errCreate != nil {
+		log.Errorf("auth-export: create %s failed: %v", outPath, errCreate)
+		return
+	}
+	_, errWrite := out.Write(sealed)
+	if errClose := out.Close(); errWrite == nil {
+		errWrite = errClose
+	}
+	if errWrite != nil {
+		_ = os.Remove(outPath)
+		log.Errorf("auth-export: write %s failed: %v", outPath, errWrite)
+		return
+	}
+	suffix := ""
+	if includeConfig {
+		suffix = " and the config file"
+	}
+	fmt.Printf("Exported %d auth files%s to %s\n", len(contents.Files), suffix, outPath)
+}
+
+// DoImportAuthBundle decrypts a bundle in memory and installs its auth files into the
+// writable auth directory with 0600 permissions. Existing files are never overwritten.
+// A bundled config is written to configPath only when no config exists there yet, and
+// its auth-dir then decides where the auth files go.
+func DoImportAuthBundle(cfg *config.Config, configPath, bundlePath, passphraseEnv string) {
+	passphrase, ok := bundlePassphrase("auth-import", passphraseEnv)
+	if !ok {
+		return
+	}
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	raw, errRead := os.ReadFile(bundlePath)
+	if errRead != nil {
+		log.Errorf("auth-import: read %s failed: %v", bundlePath, errRead)
+		return
+	}
+	contents, errOpen := bundle.Open(raw, passphrase)
+	if errOpen != nil {
+		log.Errorf("auth-import: %v", errOpen)
+		return
+	}
+	configWritten := false
+	if len(contents.Config) > 0 {
+		errWrite := writeNewFile(configPath, contents.Config)
+		switch {
+		case errors.Is(errWrite, fs.ErrExist):
+			fmt.Printf("Bundle includes a config file, but %s already exists; left unchanged\n", configPath)
+		case errWrite != nil:
+			log.Errorf("auth-import: write config %s failed: %v", configPath, errWrite)
+			return
+		default:
+			configWritten = true
+			fmt.Printf("Config written to %s\n", configPath)
+			// Install auth files where the imported config expects them.
+			if loaded, errLoad := config.LoadConfig(configPath); errLoad == nil {
+				cfg = loaded
+			} else {
+				log.Warnf("auth-import: imported config does not load (%v); using the current auth directory", errLoad)
+			}
+		}
+	}
+	authDir, errResolve := util.ResolveAuthDir(cfg.AuthDir)
+	if errResolve != nil || authDir == "" {
+		log.Errorf("auth-import: resolve auth directory failed (set auth-dir in the config): %v", errResolve)
+		return
+	}
+	if errMkdir := os.MkdirAll(authDir, 0o700); errMkdir != nil {
+		log.Errorf("auth-import: create %s failed: %v", authDir, errMkdir)
+		return
+	}
+	imported, skipped, failed := 0, 0, 0
+	for _, file := range contents.Files {
+		path := filepath.Join(authDir, filepath.FromSlash(file.Name))
+		data, errInstall := filecrypt.Seal(file.Data)
+		if errInstall == nil {
+			errInstall = os.MkdirAll(filepath.Dir(path), 0o700)
+		}
+		if errInstall == nil {
+			errInstall = writeNewFile(path, data)
+		}
+		switch {
+		case errors.Is(errInstall, fs.ErrExist):
+			skipped++
+			log.Warnf("auth-import: %s already exists; left unchanged", path)
+		case errInstall != nil:
+			failed++
+			log.Errorf("auth-import: %s: %v", path, errInstall)
+		default:
+			imported++
+		}
+	}
+	fmt.Printf("Imported %d auth files into %s (%d already present, %d failed)\n", imported, authDir, skipped, failed)
+	if configWritten {
+		fmt.Println("Review the imported config before starting the server; paths and ports may differ on this machine.")
+	}
+}
+
+// bundlePassphrase reads the bundle passphrase from the environment variable named by envName.
+func bundlePassphrase(op, envName string) (string, bool) {
+	envName = strings.TrimSpace(envName)
+	if envName == "" {
+		log.Errorf("%s: -passphrase-env is required and must name an environment variable holding the passphrase", op)
+		return "", false
+	}
+	passphrase := os.Getenv(envName)
+	if passphrase == "" {
+		log.Errorf("%s: environment variable %s is empty", op, envName)
+		return "", false
+	}
+	return passphrase, true
+}
+
+// writeNewFile creates path with 0600 permissions and fails with fs.ErrExist if it is already present.
+// The content goes straight to its final path so decrypted data never lands in a temp file.
+func writeNewFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/filecrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// DoEncryptAuthFiles encrypts every plaintext auth file in the configured auth directories
+// in place. Files that are already encrypted are left untouched.
+func DoEncryptAuthFiles(cfg *config.Config) {
+	if !filecrypt.Enabled() {
+		log.Errorf("encrypt-auth: no encryption key configured; set %s first", filecrypt.EnvKey)
+		return
+	}
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	encrypted, skipped, failed := 0, 0, 0
+	for _, dir := range cfg.AuthDirectories() {
+		resolved, errResolve := util.ResolveAuthDir(dir)
+		if errResolve != nil {
+			log.Errorf("encrypt-auth: resolve %s failed: %v", dir, errResolve)
+			continue
+		}
+		entries, errRead := os.ReadDir(resolved)
+		if errRead != nil {
+			log.Errorf("encrypt-auth: read %s failed: %v", resolved, errRead)
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name()), ".json") {
+				continue
+			}
+			path := filepath.Join(resolved, entry.Name())
+			changed, errEncrypt := filecrypt.EncryptFileInPlace(path)
+			switch {
+			case errEncrypt != nil:
+				failed++
+				log.Errorf("encrypt-auth: %s: %v", path, errEncrypt)
+			case changed:
+				encrypted++
+			default:
+				skipped++
+			}
+		}
+	}
+	fmt.Printf("Encrypted %d auth files (%d already encrypted or empty, %d failed)\n", encrypted, skipped, failed)
+}
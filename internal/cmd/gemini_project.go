@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// DoSetGeminiProject changes the Google Cloud project stored in an existing Gemini auth file,
+// so an account can be moved to another project without logging in again.
+func DoSetGeminiProject(cfg *config.Config, authName, projectID string) {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	if resolved, errResolve := util.ResolveAuthDir(cfg.AuthDir); errResolve == nil {
+		cfg.AuthDir = resolved
+	}
+	authName = strings.TrimSpace(authName)
+	projectID = strings.TrimSpace(projectID)
+	if authName == "" || projectID == "" {
+		log.Error("set-project: both the auth file name and -project_id are required")
+		return
+	}
+
+	store := sdkAuth.GetTokenStore()
+	if setter, ok := store.(interface{ SetBaseDir(string) }); ok {
+		setter.SetBaseDir(cfg.AuthDir)
+	}
+	ctx := context.Background()
+	auths, errList := store.List(ctx)
+	if errList != nil {
+		log.Errorf("set-project: list auth files failed: %v", errList)
+		return
+	}
+	target := findAuthByName(auths, authName)
+	if target == nil {
+		log.Errorf("set-project: auth file %s not found", authName)
+		return
+	}
+	if errSet := gemini.SetAuthProject(target, projectID); errSet != nil {
+		log.Errorf("set-project: %v", errSet)
+		return
+	}
+	savedPath, errSave := store.Save(ctx, target)
+	if errSave != nil {
+		log.Errorf("set-project: save auth file failed: %v", errSave)
+		return
+	}
+	fmt.Printf("Project for %s set to %s (%s)\n", authName, projectID, savedPath)
+}
+
+func findAuthByName(auths []*coreauth.Auth, name string) *coreauth.Auth {
+	for _, auth := range auths {
+		if auth != nil && (auth.ID == name || auth.FileName == name) {
+			return auth
+		}
+	}
+	return nil
+}
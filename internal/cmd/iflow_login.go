@@ -6,8 +6,8 @@ import (
 	"fmt"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
-	log "github.com/sirupsen/logrus"
 )
 
 // DoIFlowLogin performs the iFlow OAuth login via the shared authentication manager.
@@ -24,10 +24,12 @@ func DoIFlowLogin(cfg *config.Config, options *LoginOptions) {
 	}
 
 	authOpts := &sdkAuth.LoginOptions{
-		NoBrowser:    options.NoBrowser,
-		CallbackPort: options.CallbackPort,
-		Metadata:     map[string]string{},
-		Prompt:       promptFn,
+		NoBrowser:       options.NoBrowser,
+		CallbackPort:    options.CallbackPort,
+		CallbackHost:    options.CallbackHost,
+		CallbackTimeout: options.CallbackTimeout,
+		Metadata:        map[string]string{},
+		Prompt:          promptFn,
 	}
 
 	_, savedPath, err := manager.Login(context.Background(), "iflow", cfg, authOpts)
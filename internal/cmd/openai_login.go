@@ -5,11 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/codex"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
-	log "github.com/sirupsen/logrus"
 )
 
 // LoginOptions contains options for the login processes.
@@ -20,8 +21,15 @@ type LoginOptions struct {
 	NoBrowser bool
 
 	// CallbackPort overrides the local OAuth callback port when set (>0).
+	// sdkAuth.CallbackPortAuto asks for a free port where the provider allows it.
 	CallbackPort int
 
+	// CallbackHost is the interface the callback server binds to (all interfaces when empty).
+	CallbackHost string
+
+	// CallbackTimeout bounds the wait for the OAuth redirect (five minutes when zero).
+	CallbackTimeout time.Duration
+
 	// Prompt allows the caller to provide interactive input when needed.
 	Prompt func(prompt string) (string, error)
 }
@@ -46,10 +54,12 @@ func DoCodexLogin(cfg *config.Config, options *LoginOptions) {
 	manager := newAuthManager()
 
 	authOpts := &sdkAuth.LoginOptions{
-		NoBrowser:    options.NoBrowser,
-		CallbackPort: options.CallbackPort,
-		Metadata:     map[string]string{},
-		Prompt:       promptFn,
+		NoBrowser:       options.NoBrowser,
+		CallbackPort:    options.CallbackPort,
+		CallbackHost:    options.CallbackHost,
+		CallbackTimeout: options.CallbackTimeout,
+		Metadata:        map[string]string{},
+		Prompt:          promptFn,
 	}
 
 	_, savedPath, err := manager.Login(context.Background(), "codex", cfg, authOpts)
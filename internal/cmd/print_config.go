@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"gopkg.in/yaml.v3"
+)
+
+// DoPrintEffectiveConfig writes the loaded configuration, with flag and environment overrides
+// applied, to stdout as YAML. Secrets read from files show their "file:" reference.
+func DoPrintEffectiveConfig(cfg *config.Config) {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.WithSecretReferences()); err != nil {
+		log.Errorf("print-config: %v", err)
+	}
+	_ = enc.Close()
+}
@@ -6,14 +6,19 @@ package cmd
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cloudconfig"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
-	log "github.com/sirupsen/logrus"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 )
 
 // StartService builds and runs the proxy service using the exported SDK.
@@ -37,24 +42,248 @@ func StartService(cfg *config.Config, configPath string, localPassword string) {
 	if localPassword != "" {
 		var keepAliveCancel context.CancelFunc
 		runCtx, keepAliveCancel = context.WithCancel(ctxSignal)
-		builder = builder.WithServerOptions(api.WithKeepAliveEndpoint(10*time.Second, func() {
-			log.Warn("keep-alive endpoint idle for 10s, shutting down")
-			keepAliveCancel()
-		}))
+		builder = builder.WithServerOptions(api.WithKeepAliveEndpoint(10*time.Second, keepAliveCancel))
 	}
 
+	// Started below, once the service is built; reloads adjust it, its save worker halts as
+	// shutdown begins and the final save runs once the service has stopped, so it includes
+	// the last requests served.
+	var usagePersistence *usage.FileUsagePlugin
+	secretRefresh := newSecretRefresher(configPath, cfg)
+	builder = builder.WithHooks(cliproxy.Hooks{
+		OnBeforeStop: func(context.Context, *cliproxy.Service) {
+			usagePersistence.Halt()
+		},
+		OnConfigReload: func(_ context.Context, oldCfg, newCfg *config.Config) {
+			if oldCfg == nil || newCfg == nil {
+				return
+			}
+			secretRefresh.observe(newCfg)
+			if usagePersistence == nil {
+				if !oldCfg.UsagePersistence.Enabled() && newCfg.UsagePersistence.Enabled() {
+					log.Warn("usage persistence: usage-persistence was added; restart to start persisting statistics")
+				}
+				return
+			}
+			usagePersistence.Reconfigure(oldCfg.UsagePersistence, newCfg.UsagePersistence)
+		},
+	})
+
 	service, err := builder.Build()
 	if err != nil {
 		log.Errorf("failed to build proxy service: %v", err)
 		return
 	}
 
+	usageRedis, err := startUsageRedis(cfg)
+	if err != nil {
+		log.Errorf("failed to start shared usage statistics: %v", err)
+		return
+	}
+	if usageRedis != nil {
+		defer func() {
+			if errStop := usageRedis.Stop(); errStop != nil {
+				log.Errorf("failed to push usage statistics to redis on shutdown: %v", errStop)
+			}
+		}()
+	}
+
+	usagePersistence, err = startUsagePersistence(cfg)
+	if err != nil {
+		log.Errorf("failed to start usage persistence: %v", err)
+		return
+	}
+	if usagePersistence != nil {
+		defer func() {
+			if errStop := usagePersistence.Stop(); errStop != nil {
+				log.Errorf("failed to save usage statistics on shutdown: %v", errStop)
+			}
+		}()
+	}
+
+	if pusher := startMetricsPush(cfg); pusher != nil {
+		defer func() {
+			if errStop := pusher.Stop(); errStop != nil {
+				log.Errorf("failed to push final metrics on shutdown: %v", errStop)
+			}
+		}()
+	}
+
+	if webhook := startUsageWebhook(cfg); webhook != nil {
+		defer func() {
+			if errStop := webhook.Stop(); errStop != nil {
+				log.Errorf("failed to post final usage records on shutdown: %v", errStop)
+			}
+		}()
+	}
+
+	go secretRefresh.run(runCtx, service)
+
 	err = service.Run(runCtx)
 	if err != nil && !errors.Is(err, context.Canceled) {
 		log.Errorf("proxy service exited with error: %v", err)
 	}
 }
 
+// startUsageRedis starts sharing usage statistics through Redis when usage-redis is
+// configured. An unreachable server is not fatal: statistics are counted locally until it is.
+func startUsageRedis(cfg *config.Config) (*usage.RedisUsageStore, error) {
+	if cfg == nil || !cfg.UsageRedis.Enabled() {
+		return nil, nil
+	}
+	if !cfg.UsageStatisticsEnabled {
+		log.Warn("usage-redis is configured but usage-statistics-enabled is false; shared statistics disabled")
+		return nil, nil
+	}
+	store, err := usage.NewRedisUsageStore(cfg.UsageRedis, usage.GetRequestStatistics())
+	if err != nil {
+		return nil, err
+	}
+	if err = store.Ping(context.Background()); err != nil {
+		log.Warnf("usage-redis: %s is not reachable yet (%v); counting locally until it is", cfg.UsageRedis.Address, err)
+	}
+	coreusage.RegisterPlugin(store)
+	store.Start()
+	return store, nil
+}
+
+// startMetricsPush starts pushing usage metrics when metrics.pushgateway is configured.
+func startMetricsPush(cfg *config.Config) *usage.Pushgateway {
+	if cfg == nil || !cfg.Metrics.Pushgateway.Enabled() {
+		return nil
+	}
+	if !cfg.UsageStatisticsEnabled {
+		log.Warn("metrics.pushgateway is configured but usage-statistics-enabled is false; metrics push disabled")
+		return nil
+	}
+	pusher := usage.NewPushgateway(cfg.Metrics.Pushgateway, usage.GetRequestStatistics())
+	pusher.Start()
+	return pusher
+}
+
+// startUsageWebhook starts posting usage records when usage-webhook is configured. It does
+// not depend on usage-statistics-enabled: the records go out as they are made.
+func startUsageWebhook(cfg *config.Config) *usage.WebhookUsagePlugin {
+	if cfg == nil || !cfg.UsageWebhook.Enabled() {
+		return nil
+	}
+	plugin := usage.NewWebhookUsagePlugin(cfg.UsageWebhook)
+	coreusage.RegisterPlugin(plugin)
+	plugin.Start()
+	return plugin
+}
+
+// startUsagePersistence loads persisted usage statistics and starts periodic saving
+// when usage-persistence is configured. It returns nil when persistence is disabled.
+func startUsagePersistence(cfg *config.Config) (*usage.FileUsagePlugin, error) {
+	if cfg == nil || !cfg.UsagePersistence.Enabled() {
+		return nil, nil
+	}
+	if cfg.UsageRedis.Enabled() {
+		log.Info("usage-persistence is ignored because usage-redis holds the statistics")
+		return nil, nil
+	}
+	if !cfg.UsageStatisticsEnabled {
+		log.Warn("usage-persistence is configured but usage-statistics-enabled is false; persistence disabled")
+		return nil, nil
+	}
+	interval, err := cfg.UsagePersistence.ResolveSaveInterval()
+	if err != nil {
+		return nil, err
+	}
+	plugin := usage.NewFileUsagePlugin(cfg.UsagePersistence.LocalFile(), interval, usage.GetRequestStatistics())
+	if cfg.UsagePersistence.RemoteFile() {
+		remote, errRemote := usage.NewRemoteFile(cfg.UsagePersistence.File)
+		if errRemote != nil {
+			return nil, fmt.Errorf("usage-persistence: %w", errRemote)
+		}
+		plugin.SetRemoteFile(remote)
+		log.Infof("usage persistence: saving to %s through the working copy %s", remote, cfg.UsagePersistence.LocalFile())
+	}
+	plugin.SetMaxCorruptBackups(cfg.UsagePersistence.CorruptBackupLimit())
+	plugin.SetFallbackFile(cfg.UsagePersistence.FallbackFile())
+	plugin.SetRetention(cfg.UsagePersistence.KeepDays, cfg.UsagePersistence.MaxFileSizeMB)
+	plugin.SetKeyIdentifiers(cfg.UsagePersistence.KeyIdentifierMode())
+	if cfg.UsagePersistence.Journal {
+		plugin.EnableJournal()
+	}
+	if cfg.UsagePersistence.LeaderElection {
+		if interval <= 0 {
+			return nil, errors.New("usage-persistence: leader-election needs a positive save-interval")
+		}
+		plugin.EnableLeaderElection()
+	}
+	// The service applies the quotas only once it runs; Load needs them already to count the
+	// journal against them and to match hashed key counters to their keys.
+	usage.GetQuotaTracker().SetLimits(cfg.Routing.DailyQuotas)
+	usage.GetKeyQuotaTracker().SetGlobalLimit(cfg.GlobalQuota)
+	usage.GetKeyQuotaTracker().SetLimits(cfg.APIKeySettings)
+	if err = plugin.Load(); err != nil {
+		return nil, err
+	}
+	coreusage.RegisterPlugin(plugin)
+	plugin.Start()
+	return plugin, nil
+}
+
+// cloudConfigRetryInterval is how often cloud deploy mode retries fetching a configuration
+// it could not get at startup.
+const cloudConfigRetryInterval = 30 * time.Second
+
+// WaitForCloudConfig fetches the configuration from source into path, standing by and
+// retrying while the fetch fails. It returns false if a shutdown signal arrives first.
+func WaitForCloudConfig(source *cloudconfig.Source, path string) bool {
+	ctxSignal, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	for {
+		if _, err := source.Sync(ctxSignal, path); err == nil {
+			log.Infof("Cloud deploy mode: configuration fetched from %s into %s", source, path)
+			return true
+		} else if ctxSignal.Err() == nil {
+			log.Warnf("Cloud deploy mode: %v; API server is not started, retrying in %s", err, cloudConfigRetryInterval)
+		}
+		select {
+		case <-ctxSignal.Done():
+			log.Info("Cloud deploy mode: Shutdown signal received; exiting")
+			return false
+		case <-time.After(cloudConfigRetryInterval):
+		}
+	}
+}
+
+// configFilePollInterval is how often cloud deploy mode looks for a configuration file to
+// appear at its local path.
+const configFilePollInterval = 5 * time.Second
+
+// WaitForConfigFile stands by until the configuration file at path exists and can start the
+// server, so that a config written by an init container, a sidecar or kubectl cp starts the
+// service without a restart. It returns false if a shutdown signal arrives first.
+func WaitForConfigFile(path string) bool {
+	ctxSignal, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	var lastErr string
+	for {
+		if _, errStat := os.Stat(path); errStat == nil {
+			errCheck := cloudconfig.CheckFile(path)
+			if errCheck == nil {
+				log.Infof("Cloud deploy mode: configuration detected at %s; starting service", path)
+				return true
+			}
+			// Report each distinct problem once rather than on every poll.
+			if errCheck.Error() != lastErr {
+				lastErr = errCheck.Error()
+				log.Warnf("Cloud deploy mode: %s: %v; standing by for a valid configuration", path, errCheck)
+			}
+		}
+		select {
+		case <-ctxSignal.Done():
+			log.Info("Cloud deploy mode: Shutdown signal received; exiting")
+			return false
+		case <-time.After(configFilePollInterval):
+		}
+	}
+}
+
 // WaitForCloudDeploy waits indefinitely for shutdown signals in cloud deploy mode
 // when no configuration file is available.
 func WaitForCloudDeploy() {
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
+)
+
+// secretRefresher reads the secret files and secret:// references of the running config
+// again every secret-refresh-interval and reloads the service when one of them changed, so
+// a rotated credential is picked up without touching the config file.
+type secretRefresher struct {
+	configPath string
+
+	mu  sync.Mutex
+	cfg *config.Config
+}
+
+func newSecretRefresher(configPath string, cfg *config.Config) *secretRefresher {
+	return &secretRefresher{configPath: configPath, cfg: cfg}
+}
+
+// observe records cfg as the config the service now runs with.
+func (r *secretRefresher) observe(cfg *config.Config) {
+	r.mu.Lock()
+	r.cfg = cfg
+	r.mu.Unlock()
+}
+
+func (r *secretRefresher) current() *config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg
+}
+
+// run refreshes until ctx is done. The interval is read from the current config each time,
+// so a reload that changes or disables it takes effect after the pending wait.
+func (r *secretRefresher) run(ctx context.Context, service *cliproxy.Service) {
+	for {
+		interval := r.current().EffectiveSecretRefreshInterval()
+		wait := interval
+		if wait <= 0 {
+			wait = config.DefaultSecretRefreshInterval
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if interval > 0 {
+			r.refresh(ctx, service)
+		}
+	}
+}
+
+func (r *secretRefresher) refresh(ctx context.Context, service *cliproxy.Service) {
+	cfg := r.current()
+	if cfg.SecretVersion() == "" || r.configPath == "" {
+		return
+	}
+	next, err := config.LoadConfig(r.configPath)
+	if err != nil {
+		log.Warnf("secret refresh: %v; keeping the current secrets", err)
+		return
+	}
+	if next.SecretVersion() == cfg.SecretVersion() {
+		return
+	}
+	log.Info("secret refresh: a secret changed; reloading the configuration")
+	if err = service.Reload(ctx, next); err != nil {
+		log.Warnf("secret refresh: reload failed: %v", err)
+		return
+	}
+	r.observe(next)
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// DoExportUsage writes the requests persisted by usage-persistence, archives included, as a
+// table at outPath, or to standard output when outPath is "-". from and to are dates
+// (2006-01-02, in local time) or RFC 3339 timestamps and may be empty; the to date is
+// included. A remote file is downloaded and exported along with the local working copy and
+// the archives next to it.
+func DoExportUsage(cfg *config.Config, outPath, format, from, to string) {
+	if cfg == nil || !cfg.UsagePersistence.Enabled() {
+		log.Error("usage-export: usage-persistence.file is not configured")
+		return
+	}
+	start, err := parseExportTime(from, false)
+	if err != nil {
+		log.Errorf("usage-export: invalid -usage-from: %v", err)
+		return
+	}
+	end, err := parseExportTime(to, true)
+	if err != nil {
+		log.Errorf("usage-export: invalid -usage-to: %v", err)
+		return
+	}
+
+	var extra []string
+	if fallback := cfg.UsagePersistence.FallbackFile(); fallback != "" {
+		extra = append(extra, fallback)
+	}
+	if cfg.UsagePersistence.RemoteFile() {
+		downloaded, errDownload := downloadRemoteUsage(cfg.UsagePersistence.File)
+		if errDownload != nil {
+			log.Errorf("usage-export: %v", errDownload)
+			return
+		}
+		defer func() { _ = os.RemoveAll(filepath.Dir(downloaded)) }()
+		extra = append(extra, downloaded)
+	}
+	export := func(w io.Writer) (int, error) {
+		return usage.ExportPersisted(w, cfg.UsagePersistence.LocalFile(), extra, strings.ToLower(strings.TrimSpace(format)), start, end)
+	}
+	if outPath == "-" {
+		if _, err = export(os.Stdout); err != nil {
+			log.Errorf("usage-export: %v", err)
+		}
+		return
+	}
+	out, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		log.Errorf("usage-export: create %s failed: %v", outPath, err)
+		return
+	}
+	n, err := export(out)
+	if errClose := out.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		// A partial table must not pass for a complete one.
+		_ = os.Remove(outPath)
+		log.Errorf("usage-export: %v", err)
+		return
+	}
+	fmt.Printf("Exported %d requests to %s\n", n, outPath)
+}
+
+// downloadRemoteUsage downloads the remote usage file at rawURL into a new temporary directory
+// and returns the path of the copy.
+func downloadRemoteUsage(rawURL string) (string, error) {
+	remote, err := usage.NewRemoteFile(rawURL)
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp("", "usage-export-")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "usage.json")
+	if _, err = remote.Download(context.Background(), path); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("download %s: %w", remote, err)
+	}
+	return path, nil
+}
+
+// parseExportTime parses a -usage-from or -usage-to value. A bare date stands for the start of
+// that day, or with end set for the start of the next one, so that the day is included.
+func parseExportTime(value string, end bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if day, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		if end {
+			day = day.AddDate(0, 0, 1)
+		}
+		return day, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a date like 2006-01-02 nor an RFC 3339 timestamp", value)
+	}
+	return t, nil
+}
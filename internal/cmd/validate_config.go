@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/antigravity"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/filecrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/iflow"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"gopkg.in/yaml.v3"
+)
+
+// configIssue is one problem found by DoValidateConfig. Line is 0 when the problem cannot be
+// tied to a line of the file.
+type configIssue struct {
+	line    int
+	warning bool
+	message string
+}
+
+// loginCallbackPorts are the fixed local ports the OAuth logins listen on, including the
+// callback forwarders the management API starts for logins begun from the control panel.
+var loginCallbackPorts = map[int]string{
+	54545:                      "claude",
+	1455:                       "codex",
+	gemini.DefaultCallbackPort: "gemini",
+	antigravity.CallbackPort:   "antigravity",
+	iflow.CallbackPort:         "iflow",
+}
+
+var (
+	yamlLineError = regexp.MustCompile(`^\s*(?:yaml: )?line (\d+): (.*)$`)
+	issuePath     = regexp.MustCompile(`^([a-z][a-z0-9-]*(?:\[\d+\])*(?:\.[A-Za-z0-9_-]+(?:\[\d+\])*)*): `)
+)
+
+// DoValidateConfig checks the configuration file at configPath without starting the server
+// or changing the file, and prints every problem as file:line: severity: message. On top of
+// what loading checks, it looks at durations and other values the server would otherwise
+// reject or ignore, the TLS files, the auth directories and the credential files in them,
+// and the listen ports. It returns the process exit code: 1 if any error was found.
+func DoValidateConfig(configPath string) int {
+	issues := validateConfigFile(configPath)
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].line < issues[j].line })
+	errorCount, warningCount := 0, 0
+	for _, issue := range issues {
+		severity := "error"
+		if issue.warning {
+			severity = "warning"
+			warningCount++
+		} else {
+			errorCount++
+		}
+		location := configPath
+		if issue.line > 0 {
+			location += ":" + strconv.Itoa(issue.line)
+		}
+		fmt.Printf("%s: %s: %s\n", location, severity, issue.message)
+	}
+	if errorCount == 0 {
+		fmt.Printf("%s: configuration is valid (%d warnings)\n", configPath, warningCount)
+		return 0
+	}
+	fmt.Printf("%s: %d errors, %d warnings\n", configPath, errorCount, warningCount)
+	return 1
+}
+
+func validateConfigFile(configPath string) []configIssue {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return []configIssue{{message: err.Error()}}
+	}
+	var root yaml.Node
+	if err = yaml.Unmarshal(data, &root); err != nil {
+		return loadIssues(nil, err)
+	}
+
+	// Loading may migrate keys or hash the management key in place; do that on a copy.
+	tmp, err := os.CreateTemp("", "cliproxy-validate-*.yaml")
+	if err != nil {
+		return []configIssue{{message: err.Error()}}
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	_, err = tmp.Write(data)
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return []configIssue{{message: err.Error()}}
+	}
+	cfg, err := config.LoadConfig(tmp.Name())
+	if err != nil {
+		return loadIssues(&root, err)
+	}
+
+	var issues []configIssue
+	for _, errValidate := range joinedErrors(cfg.Validate()) {
+		issues = append(issues, pathIssue(&root, errValidate.Error()))
+	}
+	problem := func(path string, warning bool, format string, args ...any) {
+		issues = append(issues, configIssue{line: nodeLine(&root, path), warning: warning, message: fmt.Sprintf(format, args...)})
+	}
+
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		problem("port", false, "port: must be between 1 and 65535, got %d", cfg.Port)
+	} else {
+		if provider, ok := loginCallbackPorts[cfg.Port]; ok {
+			problem("port", true, "port: %d is the %s login callback port; %s logins fail while the server holds it", cfg.Port, provider, provider)
+		}
+		address := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+		if listener, errListen := net.Listen("tcp", address); errListen != nil {
+			problem("port", true, "port: cannot listen on %s: %v", address, errListen)
+		} else {
+			_ = listener.Close()
+		}
+	}
+
+	if listen := strings.TrimSpace(cfg.Management.Listen); listen != "" {
+		if listener, errListen := net.Listen("tcp", listen); errListen != nil {
+			problem("management.listen", true, "management: cannot listen on %s: %v", listen, errListen)
+		} else {
+			_ = listener.Close()
+		}
+	}
+
+	// Validate reports a missing cert or key; here only a pair that is set has to load.
+	if cfg.TLS.Enable && !cfg.TLS.ACME.Enabled() && strings.TrimSpace(cfg.TLS.Cert) != "" && strings.TrimSpace(cfg.TLS.Key) != "" {
+		if _, errPair := tls.LoadX509KeyPair(cfg.TLS.Cert, cfg.TLS.Key); errPair != nil {
+			problem("tls.cert", false, "tls: %v", errPair)
+		}
+	}
+	if ca := strings.TrimSpace(cfg.TLS.ClientAuth.CA); ca != "" {
+		if pem, errRead := os.ReadFile(ca); errRead != nil {
+			problem("tls.client-auth.ca", false, "tls.client-auth: %v", errRead)
+		} else if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			problem("tls.client-auth.ca", false, "tls.client-auth: no certificates found in %s", ca)
+		}
+	}
+	if cfg.TLS.Enable && cfg.TLS.ACME.Enabled() && cfg.Port != 443 {
+		problem("tls.acme", true, "tls: acme answers TLS-ALPN challenges on port %d; the CA connects to port 443, so forward it or set http-challenge-addr", cfg.Port)
+	}
+
+	for i, dir := range cfg.AuthDirectories() {
+		path := "auth-dir"
+		if len(cfg.AuthDirs) > 1 {
+			path = fmt.Sprintf("auth-dir[%d]", i)
+		}
+		resolved, errResolve := util.ResolveAuthDir(dir)
+		if errResolve != nil {
+			problem(path, false, "%s: %v", path, errResolve)
+			continue
+		}
+		info, errStat := os.Stat(resolved)
+		switch {
+		case errors.Is(errStat, fs.ErrNotExist):
+			problem(path, true, "%s: %s does not exist yet; it is created at startup", path, resolved)
+			continue
+		case errStat != nil:
+			problem(path, false, "%s: %v", path, errStat)
+			continue
+		case !info.IsDir():
+			problem(path, false, "%s: %s is not a directory", path, resolved)
+			continue
+		}
+		for _, credential := range credentialFileIssues(resolved) {
+			problem(path, credential.warning, "%s: %s", path, credential.message)
+		}
+	}
+	return issues
+}
+
+// credentialFileIssues reports the auth files under dir that the server would skip, as
+// errors, or load in a degraded state, as warnings.
+func credentialFileIssues(dir string) []configIssue {
+	var issues []configIssue
+	report := func(warning bool, format string, args ...any) {
+		issues = append(issues, configIssue{warning: warning, message: fmt.Sprintf(format, args...)})
+	}
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			report(false, "%v", walkErr)
+			return nil
+		}
+		if d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".json") {
+			return nil
+		}
+		data, err := filecrypt.ReadFile(path)
+		if err != nil {
+			report(false, "credential file %s: %v", path, err)
+			return nil
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		var metadata map[string]any
+		if err = json.Unmarshal(data, &metadata); err != nil {
+			report(false, "credential file %s: invalid JSON: %v", path, err)
+			return nil
+		}
+		if provider, _ := metadata["type"].(string); strings.TrimSpace(provider) == "" {
+			report(true, "credential file %s: no \"type\"; the account is loaded as an unknown provider", path)
+		}
+		if proxyURL, _ := metadata["proxy_url"].(string); proxyURL != "" {
+			if errProxy := util.ValidateProxyURL(proxyURL); errProxy != nil {
+				report(false, "credential file %s: proxy_url: %v; the account stays disabled until it is fixed", path, errProxy)
+			}
+		}
+		return nil
+	})
+	return issues
+}
+
+// loadIssues splits a load error into its problems, keeping the line numbers the YAML decoder
+// reports and looking up the line of the problems that name a config path.
+func loadIssues(root *yaml.Node, err error) []configIssue {
+	var issues []configIssue
+	for _, line := range strings.Split(err.Error(), "\n") {
+		for _, prefix := range []string{
+			"failed to parse config file: ",
+			"failed to expand environment references: ",
+			"failed to load secrets: ",
+			"yaml: unmarshal errors:",
+		} {
+			line = strings.TrimPrefix(line, prefix)
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if match := yamlLineError.FindStringSubmatch(line); match != nil {
+			n, _ := strconv.Atoi(match[1])
+			issues = append(issues, configIssue{line: n, message: match[2]})
+			continue
+		}
+		issues = append(issues, pathIssue(root, line))
+	}
+	return issues
+}
+
+// pathIssue ties message to the line of the config path it starts with.
+func pathIssue(root *yaml.Node, message string) configIssue {
+	issue := configIssue{message: strings.TrimSpace(message)}
+	match := issuePath.FindStringSubmatch(issue.message)
+	if match == nil {
+		return issue
+	}
+	path := match[1]
+	// Messages on a section often name the offending key first, as in
+	// "usage-persistence: max-corrupt-backups must not be negative".
+	for _, word := range strings.Fields(issue.message[len(match[0]):]) {
+		word = strings.Trim(word, `"',:`)
+		if findNode(root, path+"."+word) != nil {
+			path += "." + word
+			break
+		}
+	}
+	issue.line = nodeLine(root, path)
+	return issue
+}
+
+// joinedErrors returns the errors joined into err, or err itself.
+func joinedErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var out []error
+		for _, inner := range joined.Unwrap() {
+			out = append(out, joinedErrors(inner)...)
+		}
+		return out
+	}
+	return []error{err}
+}
+
+// nodeLine returns the line of the deepest node on path, such as "api-keys[1]" or
+// "upstream-headers.claude.forward-headers[0]", that exists in the document.
+func nodeLine(root *yaml.Node, path string) int {
+	for path != "" {
+		if node := findNode(root, path); node != nil {
+			return node.Line
+		}
+		cut := strings.LastIndexAny(path, ".[")
+		if cut <= 0 {
+			break
+		}
+		path = path[:cut]
+	}
+	return 0
+}
+
+// findNode returns the node at path: the key node for mapping entries, the item for sequence
+// indexes.
+func findNode(root *yaml.Node, path string) *yaml.Node {
+	if root == nil {
+		return nil
+	}
+	node := root
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+	var found *yaml.Node
+	for _, segment := range strings.Split(path, ".") {
+		name, rest, _ := strings.Cut(segment, "[")
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var value *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == name {
+				found, value = node.Content[i], node.Content[i+1]
+				break
+			}
+		}
+		if value == nil {
+			return nil
+		}
+		node = value
+		for rest != "" {
+			index, after, ok := strings.Cut(rest, "]")
+			n, err := strconv.Atoi(index)
+			if !ok || err != nil || node.Kind != yaml.SequenceNode || n < 0 || n >= len(node.Content) {
+				return nil
+			}
+			node, found = node.Content[n], node.Content[n]
+			rest = strings.TrimPrefix(after, "[")
+		}
+	}
+	return found
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidateConfigFileIssues(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		return path
+	}
+	badPEM := write("bad.pem", "not a certificate\n")
+	authDir := func(name, credential string) string {
+		write(filepath.Join(name, "account.json"), credential)
+		return filepath.Join(dir, name)
+	}
+
+	cases := []struct {
+		name    string
+		config  string
+		line    int
+		warning bool
+		message string
+	}{
+		{
+			name:    "decode error keeps the decoder line",
+			config:  "port: 18317\napi-keys:\n  - a\ndebug: [true]\n",
+			line:    4,
+			message: "cannot unmarshal",
+		},
+		{
+			name:    "validation error maps to the nested key",
+			config:  "port: 18317\nhealth-check:\n  interval: 30s\n  failure-threshold: -1\n",
+			line:    4,
+			message: "failure-threshold must not be negative",
+		},
+		{
+			name:    "port out of range",
+			config:  "debug: false\nport: 70000\n",
+			line:    2,
+			message: "must be between 1 and 65535",
+		},
+		{
+			name:    "tls pair that does not load",
+			config:  "port: 18317\ntls:\n  enable: true\n  cert: " + badPEM + "\n  key: " + badPEM + "\n",
+			line:    4,
+			message: "tls: ",
+		},
+		{
+			name:    "client ca without certificates",
+			config:  "port: 18317\ntls:\n  client-auth:\n    ca: " + badPEM + "\n",
+			line:    4,
+			message: "no certificates found",
+		},
+		{
+			name:    "missing client ca",
+			config:  "port: 18317\ntls:\n  client-auth:\n    ca: " + filepath.Join(dir, "missing.pem") + "\n",
+			line:    4,
+			message: "no such file",
+		},
+		{
+			name:    "credential file with invalid json",
+			config:  "port: 18317\nauth-dir: " + authDir("invalid", "{not json") + "\n",
+			line:    2,
+			message: "invalid JSON",
+		},
+		{
+			name:    "credential file without a type",
+			config:  "port: 18317\nauth-dir: " + authDir("untyped", `{"email":"a@example.com"}`) + "\n",
+			line:    2,
+			warning: true,
+			message: `no "type"`,
+		},
+		{
+			name:    "credential file with a bad proxy url",
+			config:  "port: 18317\nauth-dir: " + authDir("proxied", `{"type":"claude","proxy_url":"ftp://proxy"}`) + "\n",
+			line:    2,
+			message: "proxy_url",
+		},
+		{
+			name:    "second auth dir is named by index",
+			config:  "port: 18317\nauth-dir:\n  - " + filepath.Join(dir, "empty") + "\n  - " + authDir("second", "{not json") + "\n",
+			line:    4,
+			message: "auth-dir[1]: credential file",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			issues := validateConfigFile(write("config.yaml", tc.config))
+			for _, issue := range issues {
+				if strings.Contains(issue.message, tc.message) {
+					if issue.line != tc.line || issue.warning != tc.warning {
+						t.Fatalf("issue %q at line %d (warning %v), want line %d (warning %v)", issue.message, issue.line, issue.warning, tc.line, tc.warning)
+					}
+					return
+				}
+			}
+			t.Fatalf("no issue containing %q in %+v", tc.message, issues)
+		})
+	}
+}
+
+func TestNodeLine(t *testing.T) {
+	var root yaml.Node
+	data := "port: 8317\napi-keys:\n  - a\n  - b\nupstream-headers:\n  claude:\n    forward-headers:\n      - X-One\n"
+	if err := yaml.Unmarshal([]byte(data), &root); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	for path, want := range map[string]int{
+		"port":        1,
+		"api-keys[1]": 4,
+		"api-keys[5]": 2,
+		"upstream-headers.claude.forward-headers[0]": 8,
+		"upstream-headers.codex":                     5,
+		"missing":                                    0,
+	} {
+		if got := nodeLine(&root, path); got != want {
+			t.Errorf("nodeLine(%q) = %d, want %d", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// System prompt modes accepted by SystemPrompt.Mode.
+const (
+	SystemPromptPrepend  = "prepend"
+	SystemPromptAppend   = "append"
+	SystemPromptOverride = "override"
+)
+
+// Route scopes accepted by APIKeyScopes.Routes.
+const (
+	ScopeChat           = "chat"
+	ScopeEmbeddings     = "embeddings"
+	ScopeModels         = "models"
+	ScopeManagementRead = "management-read"
+)
+
+// APIKeySettings attaches per-client behaviour to one of the top-level api-keys.
+type APIKeySettings struct {
+	// APIKey is the client key the settings apply to.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// SystemPrompt injects or replaces the system prompt of every request made with the key.
+	SystemPrompt *SystemPrompt `yaml:"system-prompt,omitempty" json:"system-prompt,omitempty"`
+
+	// Scopes restricts what the key may do. A key without scopes may call every API route
+	// and no management route.
+	Scopes *APIKeyScopes `yaml:"scopes,omitempty" json:"scopes,omitempty"`
+
+	// Quota caps the tokens the key may consume per day and per month.
+	Quota *APIKeyQuota `yaml:"quota,omitempty" json:"quota,omitempty"`
+
+	// AllowedIPs and DeniedIPs restrict the sources the key may be used from, on top of
+	// api-access; requests from elsewhere get 403.
+	AllowedIPs []string `yaml:"allowed-ips,omitempty" json:"allowed-ips,omitempty"`
+	DeniedIPs  []string `yaml:"denied-ips,omitempty" json:"denied-ips,omitempty"`
+
+	// Disabled refuses every request made with the key while keeping it in api-keys, so it
+	// can be enabled again.
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+
+	// ExpiresAt is the RFC 3339 time from which the key is refused.
+	ExpiresAt *time.Time `yaml:"expires-at,omitempty" json:"expires-at,omitempty"`
+}
+
+// States reported by APIKeySettings.State.
+const (
+	APIKeyStateActive   = "active"
+	APIKeyStateDisabled = "disabled"
+	APIKeyStateExpired  = "expired"
+)
+
+// State reports whether the key may be used at now. Keys without settings are active.
+func (s *APIKeySettings) State(now time.Time) string {
+	switch {
+	case s == nil:
+		return APIKeyStateActive
+	case s.Disabled:
+		return APIKeyStateDisabled
+	case s.ExpiresAt != nil && !now.Before(*s.ExpiresAt):
+		return APIKeyStateExpired
+	default:
+		return APIKeyStateActive
+	}
+}
+
+// APIKeyQuota is a hard token budget for a client key. Once a budget is used up, requests
+// are refused with 429 until it resets.
+type APIKeyQuota struct {
+	// DailyTokens caps the tokens used per day; zero means no daily cap.
+	DailyTokens int64 `yaml:"daily-tokens,omitempty" json:"daily-tokens,omitempty"`
+
+	// MonthlyTokens caps the tokens used per month; zero means no monthly cap.
+	MonthlyTokens int64 `yaml:"monthly-tokens,omitempty" json:"monthly-tokens,omitempty"`
+
+	// ResetTimezone is the IANA zone whose midnight starts a new day (default UTC).
+	ResetTimezone string `yaml:"reset-timezone,omitempty" json:"reset-timezone,omitempty"`
+
+	// MonthlyResetDay is the day of the month, 1 to 28, on which the monthly budget resets
+	// (default 1).
+	MonthlyResetDay int `yaml:"monthly-reset-day,omitempty" json:"monthly-reset-day,omitempty"`
+
+	// MonthlyCostUSD caps the estimated spend per month in US dollars, priced with
+	// model-pricing; zero means no cost cap. Models without a price add nothing to it.
+	MonthlyCostUSD float64 `yaml:"monthly-cost-usd,omitempty" json:"monthly-cost-usd,omitempty"`
+
+	// WarnPercent is the share of a budget, 1 to 99, at which a warning is logged and a
+	// usage-alert notification sent, once per period; zero sends no warning.
+	WarnPercent int `yaml:"warn-percent,omitempty" json:"warn-percent,omitempty"`
+}
+
+// Enabled reports whether the quota caps anything.
+func (q *APIKeyQuota) Enabled() bool {
+	return q != nil && (q.DailyTokens > 0 || q.MonthlyTokens > 0 || q.MonthlyCostUSD > 0)
+}
+
+// ResetLocation returns the time zone of the quota's day and month boundaries.
+func (q APIKeyQuota) ResetLocation() *time.Location {
+	name := strings.TrimSpace(q.ResetTimezone)
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ResetDay returns the day of the month on which the monthly budget resets.
+func (q APIKeyQuota) ResetDay() int {
+	if q.MonthlyResetDay < 1 || q.MonthlyResetDay > 28 {
+		return 1
+	}
+	return q.MonthlyResetDay
+}
+
+// APIKeyScopes limits a client key to some routes and models.
+type APIKeyScopes struct {
+	// Routes lists the route groups the key may call: chat, embeddings, models and
+	// management-read (the management status endpoints health, cooldowns, accounts,
+	// transports, keep-alive and latest-version). Empty allows chat, embeddings and models.
+	Routes []string `yaml:"routes,omitempty" json:"routes,omitempty"`
+
+	// Models lists glob patterns, such as "gemini-*", for the models the key may use. Empty
+	// allows every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// Providers lists the providers, such as "gemini", "vertex", "claude", "codex" or the name
+	// of an openai-compatibility entry, the key's requests may be dispatched to. A model
+	// served by several providers is only routed to the listed ones. Empty allows every
+	// provider.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// Usage lets the key read the usage statistics through the management API.
+	Usage bool `yaml:"usage,omitempty" json:"usage,omitempty"`
+}
+
+// AllowsRoute reports whether the key may call routes in the given scope.
+func (s *APIKeyScopes) AllowsRoute(scope string) bool {
+	if s == nil {
+		return scope != ScopeManagementRead
+	}
+	if len(s.Routes) == 0 {
+		return scope == ScopeChat || scope == ScopeEmbeddings || scope == ScopeModels
+	}
+	return slices.Contains(s.Routes, scope)
+}
+
+// AllowsModel reports whether the key may use model.
+func (s *APIKeyScopes) AllowsModel(model string) bool {
+	if s == nil || len(s.Models) == 0 {
+		return true
+	}
+	for _, pattern := range s.Models {
+		if ok, _ := path.Match(pattern, model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsProvider reports whether the key's requests may be dispatched to provider.
+func (s *APIKeyScopes) AllowsProvider(provider string) bool {
+	if s == nil || len(s.Providers) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(s.Providers, func(name string) bool { return strings.EqualFold(name, provider) })
+}
+
+// SystemPrompt describes a system prompt applied to upstream requests after translation.
+type SystemPrompt struct {
+	// Mode is "prepend", "append" or "override". Override drops client-supplied system prompts.
+	Mode string `yaml:"mode" json:"mode"`
+
+	// Text is the prompt itself.
+	Text string `yaml:"text" json:"text"`
+}
+
+// NormalizedMode returns the lower-cased mode, defaulting to prepend.
+func (p SystemPrompt) NormalizedMode() string {
+	mode := strings.ToLower(strings.TrimSpace(p.Mode))
+	if mode == "" {
+		return SystemPromptPrepend
+	}
+	return mode
+}
+
+// APIKeyScopesFor returns the scopes configured for key, or nil when it is unrestricted.
+func (cfg *Config) APIKeyScopesFor(key string) *APIKeyScopes {
+	if settings := cfg.APIKeySettingsFor(key); settings != nil {
+		return settings.Scopes
+	}
+	return nil
+}
+
+// APIKeySettingsFor returns the settings configured for key, or nil.
+func (cfg *Config) APIKeySettingsFor(key string) *APIKeySettings {
+	if cfg == nil || key == "" {
+		return nil
+	}
+	for i := range cfg.APIKeySettings {
+		if cfg.APIKeySettings[i].APIKey == key {
+			return &cfg.APIKeySettings[i]
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) validateAPIKeySettings() []error {
+	var errs []error
+	seen := make(map[string]struct{}, len(cfg.APIKeySettings))
+	for i, settings := range cfg.APIKeySettings {
+		if strings.TrimSpace(settings.APIKey) == "" {
+			errs = append(errs, fmt.Errorf("api-key-settings[%d]: api-key is required", i))
+			continue
+		}
+		if _, dup := seen[settings.APIKey]; dup {
+			errs = append(errs, fmt.Errorf("api-key-settings[%d]: api-key is listed more than once", i))
+		}
+		seen[settings.APIKey] = struct{}{}
+		if prompt := settings.SystemPrompt; prompt != nil {
+			switch prompt.NormalizedMode() {
+			case SystemPromptPrepend, SystemPromptAppend, SystemPromptOverride:
+			default:
+				errs = append(errs, fmt.Errorf("api-key-settings[%d]: system-prompt mode %q must be prepend, append or override", i, prompt.Mode))
+			}
+			if strings.TrimSpace(prompt.Text) == "" {
+				errs = append(errs, fmt.Errorf("api-key-settings[%d]: system-prompt text is required", i))
+			}
+		}
+		if scopes := settings.Scopes; scopes != nil {
+			for _, route := range scopes.Routes {
+				switch route {
+				case ScopeChat, ScopeEmbeddings, ScopeModels, ScopeManagementRead:
+				default:
+					errs = append(errs, fmt.Errorf("api-key-settings[%d]: scopes route %q must be chat, embeddings, models or management-read", i, route))
+				}
+			}
+			for _, pattern := range scopes.Models {
+				if _, err := path.Match(pattern, ""); err != nil {
+					errs = append(errs, fmt.Errorf("api-key-settings[%d]: scopes model pattern %q: %w", i, pattern, err))
+				}
+			}
+			for _, provider := range scopes.Providers {
+				if strings.TrimSpace(provider) == "" {
+					errs = append(errs, fmt.Errorf("api-key-settings[%d]: scopes providers must not contain empty names", i))
+				}
+			}
+		}
+		if settings.Quota != nil {
+			errs = append(errs, settings.Quota.validate(fmt.Sprintf("api-key-settings[%d]: quota", i))...)
+		}
+		if _, err := ParseIPPrefixes(settings.AllowedIPs); err != nil {
+			errs = append(errs, fmt.Errorf("api-key-settings[%d]: allowed-ips: %w", i, err))
+		}
+		if _, err := ParseIPPrefixes(settings.DeniedIPs); err != nil {
+			errs = append(errs, fmt.Errorf("api-key-settings[%d]: denied-ips: %w", i, err))
+		}
+	}
+	if cfg.GlobalQuota != nil {
+		errs = append(errs, cfg.GlobalQuota.validate("global-quota:")...)
+	}
+	return errs
+}
+
+func (q *APIKeyQuota) validate(prefix string) []error {
+	var errs []error
+	if q.DailyTokens < 0 || q.MonthlyTokens < 0 {
+		errs = append(errs, fmt.Errorf("%s daily-tokens and monthly-tokens must not be negative", prefix))
+	}
+	if q.MonthlyCostUSD < 0 {
+		errs = append(errs, fmt.Errorf("%s monthly-cost-usd must not be negative", prefix))
+	}
+	if q.WarnPercent < 0 || q.WarnPercent > 99 {
+		errs = append(errs, fmt.Errorf("%s warn-percent must be between 1 and 99", prefix))
+	}
+	if q.MonthlyResetDay < 0 || q.MonthlyResetDay > 28 {
+		errs = append(errs, fmt.Errorf("%s monthly-reset-day must be between 1 and 28", prefix))
+	}
+	if tz := strings.TrimSpace(q.ResetTimezone); tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			errs = append(errs, fmt.Errorf("%s invalid reset-timezone %q: %w", prefix, tz, err))
+		}
+	}
+	return errs
+}
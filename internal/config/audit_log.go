@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Defaults applied to audit-log settings when unset.
+const (
+	DefaultAuditLogMaxSizeMB    = 100
+	DefaultAuditLogMaxBackups   = 10
+	DefaultAuditLogMaxBodyBytes = 1 << 20
+)
+
+// AuditLogConfig writes one JSON document per proxied request, with who made it, the provider
+// account that served it, its status and token counts and, unless omitted, the client
+// request, the translated upstream request and the response, to a file kept apart from the
+// normal logs.
+type AuditLogConfig struct {
+	// Enabled turns audit logging on. It is off by default.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// File is the audit log path. Empty uses audit/audit.jsonl under the logs directory.
+	File string `yaml:"file,omitempty" json:"file,omitempty"`
+	// MaxSizeMB rotates the file once it reaches this size (default 100).
+	MaxSizeMB int `yaml:"max-size-mb,omitempty" json:"max-size-mb,omitempty"`
+	// MaxBackups is how many rotated files are kept (default 10).
+	MaxBackups int `yaml:"max-backups,omitempty" json:"max-backups,omitempty"`
+	// MaxBodyBytes caps each recorded body after redaction (default 1 MiB).
+	MaxBodyBytes int `yaml:"max-body-bytes,omitempty" json:"max-body-bytes,omitempty"`
+	// StripImages replaces inline image data with a placeholder noting its size.
+	StripImages bool `yaml:"strip-images,omitempty" json:"strip-images,omitempty"`
+	// RedactPatterns are regular expressions; every match in a recorded body is replaced
+	// with "[REDACTED]".
+	RedactPatterns []string `yaml:"redact-patterns,omitempty" json:"redact-patterns,omitempty"`
+	// RedactFields are field names replaced with "[REDACTED]" wherever they appear: fields of
+	// the record itself, such as "api_key_hash" or "account", and keys inside recorded
+	// bodies, such as "user".
+	RedactFields []string `yaml:"redact-fields,omitempty" json:"redact-fields,omitempty"`
+	// OmitBodies leaves the request and response bodies out, recording metadata only.
+	OmitBodies bool `yaml:"omit-bodies,omitempty" json:"omit-bodies,omitempty"`
+	// PromptHash records the SHA-256 of the client request body as prompt_hash, so that
+	// repeated prompts can be matched without recording them.
+	PromptHash bool `yaml:"prompt-hash,omitempty" json:"prompt-hash,omitempty"`
+}
+
+// MaxSize returns the rotation size in megabytes.
+func (a AuditLogConfig) MaxSize() int {
+	if a.MaxSizeMB <= 0 {
+		return DefaultAuditLogMaxSizeMB
+	}
+	return a.MaxSizeMB
+}
+
+// Backups returns how many rotated files are kept.
+func (a AuditLogConfig) Backups() int {
+	if a.MaxBackups <= 0 {
+		return DefaultAuditLogMaxBackups
+	}
+	return a.MaxBackups
+}
+
+// BodyLimit returns the maximum recorded size of each body.
+func (a AuditLogConfig) BodyLimit() int {
+	if a.MaxBodyBytes <= 0 {
+		return DefaultAuditLogMaxBodyBytes
+	}
+	return a.MaxBodyBytes
+}
+
+// CompileRedactPatterns compiles RedactPatterns.
+func (a AuditLogConfig) CompileRedactPatterns() ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(a.RedactPatterns))
+	for i, raw := range a.RedactPatterns {
+		re, err := regexp.Compile(raw)
+		if err != nil {
+			return nil, fmt.Errorf("audit-log: redact-patterns[%d] %q: %w", i, raw, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+func (cfg *Config) validateAuditLog() []error {
+	var errs []error
+	if _, err := cfg.AuditLog.CompileRedactPatterns(); err != nil {
+		errs = append(errs, err)
+	}
+	for i, field := range cfg.AuditLog.RedactFields {
+		if strings.TrimSpace(field) == "" {
+			errs = append(errs, fmt.Errorf("audit-log: redact-fields[%d] must not be empty", i))
+		}
+	}
+	if cfg.AuditLog.MaxSizeMB < 0 || cfg.AuditLog.MaxBackups < 0 || cfg.AuditLog.MaxBodyBytes < 0 {
+		errs = append(errs, fmt.Errorf("audit-log: max-size-mb, max-backups and max-body-bytes must not be negative"))
+	}
+	return errs
+}
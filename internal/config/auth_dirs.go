@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duplicate policies for accounts found in more than one auth directory.
+const (
+	AuthDirDuplicatesLast  = "last"
+	AuthDirDuplicatesFirst = "first"
+)
+
+// AuthDirList holds the auth directories in scan order.
+// In YAML it accepts either a single path or a list of paths.
+type AuthDirList []string
+
+// UnmarshalYAML accepts a scalar path or a sequence of paths.
+func (l *AuthDirList) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if dir := strings.TrimSpace(node.Value); dir != "" {
+			*l = AuthDirList{dir}
+		} else {
+			*l = nil
+		}
+		return nil
+	case yaml.SequenceNode:
+		var raw []string
+		if err := node.Decode(&raw); err != nil {
+			return fmt.Errorf("auth-dir: %w", err)
+		}
+		out := make(AuthDirList, 0, len(raw))
+		for _, dir := range raw {
+			if dir = strings.TrimSpace(dir); dir != "" {
+				out = append(out, dir)
+			}
+		}
+		*l = out
+		return nil
+	default:
+		return fmt.Errorf("auth-dir: expected a path or a list of paths")
+	}
+}
+
+// MarshalYAML keeps the single-directory form as a plain scalar.
+func (l AuthDirList) MarshalYAML() (interface{}, error) {
+	if len(l) == 1 {
+		return l[0], nil
+	}
+	return []string(l), nil
+}
+
+// writableAuthDir returns the directory that receives new logins.
+func (cfg *Config) writableAuthDir() string {
+	if len(cfg.AuthDirs) == 0 {
+		return ""
+	}
+	if want := strings.TrimSpace(cfg.AuthDirWritable); want != "" {
+		for _, dir := range cfg.AuthDirs {
+			if dir == want {
+				return dir
+			}
+		}
+	}
+	return cfg.AuthDirs[0]
+}
+
+// AuthDirectories returns every auth directory in scan order. The writable entry is
+// replaced by AuthDir so resolved or mirrored paths are honored.
+func (cfg *Config) AuthDirectories() []string {
+	if cfg == nil {
+		return nil
+	}
+	if len(cfg.AuthDirs) <= 1 {
+		if cfg.AuthDir == "" {
+			return nil
+		}
+		return []string{cfg.AuthDir}
+	}
+	writable := cfg.writableAuthDir()
+	out := make([]string, 0, len(cfg.AuthDirs))
+	for _, dir := range cfg.AuthDirs {
+		if dir == writable && cfg.AuthDir != "" {
+			dir = cfg.AuthDir
+		}
+		out = append(out, dir)
+	}
+	return out
+}
+
+// AuthDirDuplicatePolicy returns the normalized duplicate policy, defaulting to last-wins.
+func (cfg *Config) AuthDirDuplicatePolicy() string {
+	if cfg != nil && strings.EqualFold(strings.TrimSpace(cfg.AuthDirDuplicates), AuthDirDuplicatesFirst) {
+		return AuthDirDuplicatesFirst
+	}
+	return AuthDirDuplicatesLast
+}
+
+func (cfg *Config) validateAuthDirs() []error {
+	var errs []error
+	if want := strings.TrimSpace(cfg.AuthDirWritable); want != "" && len(cfg.AuthDirs) > 0 && cfg.writableAuthDir() != want {
+		errs = append(errs, fmt.Errorf("auth-dir-writable: %q is not one of the configured auth-dir entries", want))
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.AuthDirDuplicates)) {
+	case "", AuthDirDuplicatesLast, AuthDirDuplicatesFirst:
+	default:
+		errs = append(errs, fmt.Errorf("auth-dir-duplicates: unknown policy %q (use %q or %q)", cfg.AuthDirDuplicates, AuthDirDuplicatesLast, AuthDirDuplicatesFirst))
+	}
+	return errs
+}
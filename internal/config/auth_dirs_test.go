@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestLoadConfigAuthDirList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "auth-dir:\n  - /auth/personal\n  - /auth/work\nauth-dir-writable: /auth/work\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.AuthDir != "/auth/work" {
+		t.Fatalf("AuthDir = %q, want the writable entry", cfg.AuthDir)
+	}
+	if got, want := cfg.AuthDirectories(), []string{"/auth/personal", "/auth/work"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("AuthDirectories = %v, want %v", got, want)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.AuthDirWritable = "/elsewhere"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected an unknown auth-dir-writable to fail validation")
+	}
+}
+
+func TestAuthDirListYAMLForms(t *testing.T) {
+	var single struct {
+		Dirs AuthDirList `yaml:"auth-dir"`
+	}
+	if err := yaml.Unmarshal([]byte(`auth-dir: "~/.cli-proxy-api"`), &single); err != nil {
+		t.Fatalf("unmarshal scalar: %v", err)
+	}
+	if !reflect.DeepEqual(single.Dirs, AuthDirList{"~/.cli-proxy-api"}) {
+		t.Fatalf("scalar form parsed as %v", single.Dirs)
+	}
+	out, err := yaml.Marshal(single)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(out) != "auth-dir: ~/.cli-proxy-api\n" {
+		t.Fatalf("single directory should stay a scalar, got %q", out)
+	}
+}
@@ -12,8 +12,9 @@ import (
 	"os"
 	"strings"
 	"syscall"
+	"time"
 
-	log "github.com/sirupsen/logrus"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/yaml.v3"
 )
@@ -35,12 +36,33 @@ type Config struct {
 	// RemoteManagement nests management-related options under 'remote-management'.
 	RemoteManagement RemoteManagement `yaml:"remote-management" json:"-"`
 
-	// AuthDir is the directory where authentication token files are stored.
-	AuthDir string `yaml:"auth-dir" json:"-"`
+	// Management limits the sources allowed to reach the management API.
+	Management ManagementConfig `yaml:"management,omitempty" json:"management,omitempty"`
+
+	// APIAccess limits the sources allowed to call the client API.
+	APIAccess APIAccessConfig `yaml:"api-access,omitempty" json:"api-access,omitempty"`
+
+	// AuthDir is the writable directory where authentication token files are stored and new
+	// logins are saved. It is derived from AuthDirs when the config is loaded.
+	AuthDir string `yaml:"-" json:"-"`
+
+	// AuthDirs lists every directory scanned for auth files; auth-dir may be a path or a list.
+	AuthDirs AuthDirList `yaml:"auth-dir,omitempty" json:"-"`
+
+	// AuthDirWritable selects which auth-dir entry receives new logins. Defaults to the first.
+	AuthDirWritable string `yaml:"auth-dir-writable,omitempty" json:"-"`
+
+	// AuthDirDuplicates decides which file wins when the same account appears in several
+	// auth directories: "last" (default) or "first".
+	AuthDirDuplicates string `yaml:"auth-dir-duplicates,omitempty" json:"-"`
 
 	// Debug enables or disables debug-level logging and other debug features.
 	Debug bool `yaml:"debug" json:"debug"`
 
+	// DebugPprof serves net/http/pprof profiles and runtime statistics under
+	// /v0/management/debug, behind management authentication.
+	DebugPprof bool `yaml:"debug-pprof,omitempty" json:"debug-pprof,omitempty"`
+
 	// CommercialMode disables high-overhead HTTP middleware features to minimize per-request memory usage.
 	CommercialMode bool `yaml:"commercial-mode" json:"commercial-mode"`
 
@@ -51,13 +73,64 @@ type Config struct {
 	// When exceeded, the oldest log files are deleted until within the limit. Set to 0 to disable.
 	LogsMaxTotalSizeMB int `yaml:"logs-max-total-size-mb" json:"logs-max-total-size-mb"`
 
+	// Logging selects the log output: stdout, rotating files, syslog or journald.
+	Logging LoggingConfig `yaml:"logging,omitempty" json:"logging,omitempty"`
+
+	// LogSampling keeps only a fraction of routine info lines, such as completed requests, from
+	// each log site under load. Warnings and errors are always written.
+	LogSampling LogSamplingConfig `yaml:"log-sampling,omitempty" json:"log-sampling,omitempty"`
+
+	// Tracing exports OpenTelemetry spans for inbound requests, account selection and upstream attempts.
+	Tracing TracingConfig `yaml:"tracing,omitempty" json:"tracing,omitempty"`
+
+	// Sentry reports panics, upstream failures and usage persistence errors when a DSN is set.
+	Sentry SentryConfig `yaml:"sentry,omitempty" json:"sentry,omitempty"`
+
+	// Notifications posts account and usage events to Slack or Discord webhooks.
+	Notifications NotificationsConfig `yaml:"notifications,omitempty" json:"notifications,omitempty"`
+
 	// ErrorLogsMaxFiles limits the number of error log files retained when request logging is disabled.
 	// When exceeded, the oldest error log files are deleted. Default is 10. Set to 0 to disable cleanup.
 	ErrorLogsMaxFiles int `yaml:"error-logs-max-files" json:"error-logs-max-files"`
 
+	// AuditLog records full, redacted request and response bodies for compliance.
+	AuditLog AuditLogConfig `yaml:"audit-log,omitempty" json:"audit-log,omitempty"`
+
 	// UsageStatisticsEnabled toggles in-memory usage aggregation; when false, usage data is discarded.
 	UsageStatisticsEnabled bool `yaml:"usage-statistics-enabled" json:"usage-statistics-enabled"`
 
+	// UsageStatisticsMaxKeys caps the distinct API keys, and the distinct models per key, tracked
+	// in usage statistics; further ones are aggregated under "__other__". 0 uses the default.
+	UsageStatisticsMaxKeys int `yaml:"usage-statistics-max-keys,omitempty" json:"usage-statistics-max-keys,omitempty"`
+
+	// UsageLabels attaches labels from trusted request headers to usage records.
+	UsageLabels UsageLabels `yaml:"usage-labels,omitempty" json:"usage-labels,omitempty"`
+
+	// UsagePersistence controls saving in-memory usage statistics to disk across restarts.
+	UsagePersistence UsagePersistence `yaml:"usage-persistence" json:"usage-persistence"`
+
+	// UsageRedis shares usage statistics between replicas through Redis; it replaces
+	// usage-persistence when set.
+	UsageRedis UsageRedis `yaml:"usage-redis,omitempty" json:"usage-redis,omitempty"`
+
+	// UsageWebhook posts usage records in batches to an external endpoint.
+	UsageWebhook UsageWebhook `yaml:"usage-webhook,omitempty" json:"usage-webhook,omitempty"`
+
+	// ModelPricing prices model tokens so usage statistics can estimate spend. The first
+	// entry matching a model applies.
+	ModelPricing []ModelPrice `yaml:"model-pricing,omitempty" json:"model-pricing,omitempty"`
+
+	// Metrics exports usage statistics as Prometheus metrics.
+	Metrics MetricsConfig `yaml:"metrics,omitempty" json:"metrics,omitempty"`
+
+	// ModelCacheTTL is a Go duration for reusing model lists fetched from upstream providers.
+	// Empty uses DefaultModelCacheTTL; "0" fetches a fresh list every time.
+	ModelCacheTTL string `yaml:"model-cache-ttl,omitempty" json:"model-cache-ttl,omitempty"`
+
+	// SecretRefreshInterval is a Go duration for re-reading secret files and secret://
+	// references. Empty uses DefaultSecretRefreshInterval; "0" reads them only on load.
+	SecretRefreshInterval string `yaml:"secret-refresh-interval,omitempty" json:"secret-refresh-interval,omitempty"`
+
 	// DisableCooling disables quota cooldown scheduling when true.
 	DisableCooling bool `yaml:"disable-cooling" json:"disable-cooling"`
 
@@ -65,13 +138,29 @@ type Config struct {
 	RequestRetry int `yaml:"request-retry" json:"request-retry"`
 	// MaxRetryInterval defines the maximum wait time in seconds before retrying a cooled-down credential.
 	MaxRetryInterval int `yaml:"max-retry-interval" json:"max-retry-interval"`
+	// TransientRetry retries connection resets and 502/503/529 answers that produced no output.
+	TransientRetry TransientRetryConfig `yaml:"transient-retry,omitempty" json:"transient-retry,omitempty"`
+
+	// UpstreamHeaders forwards client headers to and injects static headers into upstream
+	// requests, keyed by provider (claude, gemini, codex, an openai-compatibility name, ...).
+	UpstreamHeaders map[string]UpstreamHeaderRules `yaml:"upstream-headers,omitempty" json:"upstream-headers,omitempty"`
+
+	// ResponseCache serves repeated deterministic non-streaming requests from memory.
+	ResponseCache ResponseCacheConfig `yaml:"response-cache,omitempty" json:"response-cache,omitempty"`
 
 	// QuotaExceeded defines the behavior when a quota is exceeded.
 	QuotaExceeded QuotaExceeded `yaml:"quota-exceeded" json:"quota-exceeded"`
 
+	// AuthRefreshMargin is how long before token expiry the background refresher renews
+	// OAuth credentials (Go duration, default "5m"). It raises but never lowers provider leads.
+	AuthRefreshMargin string `yaml:"auth-refresh-margin,omitempty" json:"auth-refresh-margin,omitempty"`
+
 	// Routing controls credential selection behavior.
 	Routing RoutingConfig `yaml:"routing" json:"routing"`
 
+	// HealthCheck configures periodic credential health probes.
+	HealthCheck HealthCheckConfig `yaml:"health-check" json:"health-check"`
+
 	// WebsocketAuth enables or disables authentication for the WebSocket API.
 	WebsocketAuth bool `yaml:"ws-auth" json:"ws-auth"`
 
@@ -108,7 +197,29 @@ type Config struct {
 	// Payload defines default and override rules for provider payload parameters.
 	Payload PayloadConfig `yaml:"payload" json:"payload"`
 
+	// MaxOutputTokens clamps and defaults the completion length requested per model.
+	MaxOutputTokens MaxOutputTokensConfig `yaml:"max-output-tokens,omitempty" json:"max-output-tokens,omitempty"`
+
+	// APIKeySettings holds per-client-key behaviour such as system prompt injection.
+	APIKeySettings []APIKeySettings `yaml:"api-key-settings,omitempty" json:"api-key-settings,omitempty"`
+
+	// GlobalQuota budgets the traffic of all client keys together, with the same fields as a
+	// key's quota.
+	GlobalQuota *APIKeyQuota `yaml:"global-quota,omitempty" json:"global-quota,omitempty"`
+
 	legacyMigrationPending bool `yaml:"-" json:"-"`
+
+	// fileValues holds the config as read from disk before flag/env overrides were applied.
+	fileValues *Config `yaml:"-" json:"-"`
+	// valueSources records the source of each overridden field, keyed by override key.
+	valueSources map[string]string `yaml:"-" json:"-"`
+	// secretFiles maps each value loaded from a "file:" reference to the file it came from.
+	secretFiles map[string]string `yaml:"-" json:"-"`
+	// secretRefs maps each secret written as an environment or secret:// reference to that
+	// reference.
+	secretRefs map[string]string `yaml:"-" json:"-"`
+	// secretVersion identifies the values read from secret files and secrets managers.
+	secretVersion string `yaml:"-" json:"-"`
 }
 
 // TLSConfig holds HTTPS server settings.
@@ -119,6 +230,10 @@ type TLSConfig struct {
 	Cert string `yaml:"cert" json:"cert"`
 	// Key is the path to the TLS private key file.
 	Key string `yaml:"key" json:"key"`
+	// ACME obtains and renews the certificate automatically instead of reading Cert and Key.
+	ACME TLSACMEConfig `yaml:"acme,omitempty" json:"acme,omitempty"`
+	// ClientAuth asks clients for certificates signed by a configured CA (mutual TLS).
+	ClientAuth TLSClientAuthConfig `yaml:"client-auth,omitempty" json:"client-auth,omitempty"`
 }
 
 // RemoteManagement holds management API configuration under 'remote-management'.
@@ -147,8 +262,43 @@ type QuotaExceeded struct {
 // RoutingConfig configures how credentials are selected for requests.
 type RoutingConfig struct {
 	// Strategy selects the credential selection strategy.
-	// Supported values: "round-robin" (default), "fill-first".
+	// Supported values: "round-robin" (default), "fill-first", "weighted", "least-loaded".
 	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// DailyQuotas declares provider-side daily request limits; accounts close to their
+	// limit are skipped until the provider's quota day resets.
+	DailyQuotas []DailyQuota `yaml:"daily-quotas,omitempty" json:"daily-quotas,omitempty"`
+}
+
+// DefaultAuthRefreshMargin is the refresh margin used when auth-refresh-margin is unset or invalid.
+const DefaultAuthRefreshMargin = 5 * time.Minute
+
+// AuthRefreshMarginDuration returns the parsed auth-refresh-margin, falling back to
+// DefaultAuthRefreshMargin when the value is empty or invalid.
+func (cfg *Config) AuthRefreshMarginDuration() time.Duration {
+	if cfg == nil {
+		return DefaultAuthRefreshMargin
+	}
+	margin, err := parseAuthRefreshMargin(cfg.AuthRefreshMargin)
+	if err != nil {
+		return DefaultAuthRefreshMargin
+	}
+	return margin
+}
+
+func parseAuthRefreshMargin(raw string) (time.Duration, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return DefaultAuthRefreshMargin, nil
+	}
+	margin, err := time.ParseDuration(trimmed)
+	if err != nil {
+		return 0, fmt.Errorf("invalid auth-refresh-margin %q: %w", raw, err)
+	}
+	if margin < 0 {
+		return 0, fmt.Errorf("invalid auth-refresh-margin %q: must not be negative", raw)
+	}
+	return margin, nil
 }
 
 // OAuthModelAlias defines a model ID alias for a specific channel.
@@ -286,6 +436,9 @@ type ClaudeKey struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// Weight sets this credential's share of traffic under the weighted strategy; defaults to 1.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/claude-sonnet-4").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
@@ -334,6 +487,9 @@ type CodexKey struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// Weight sets this credential's share of traffic under the weighted strategy; defaults to 1.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/gpt-5-codex").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
@@ -379,6 +535,9 @@ type GeminiKey struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// Weight sets this credential's share of traffic under the weighted strategy; defaults to 1.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/gemini-3-pro-preview").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
@@ -446,6 +605,9 @@ type OpenAICompatibilityAPIKey struct {
 
 	// ProxyURL overrides the global proxy setting for this API key if provided.
 	ProxyURL string `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
+
+	// Weight sets this key's share of traffic under the weighted strategy; defaults to 1.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
 }
 
 // OpenAICompatibilityModel represents a model configuration for OpenAI compatibility,
@@ -505,8 +667,22 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 		return &Config{}, nil
 	}
 
+	// Map deprecated key names onto their replacements before decoding.
+	data, aliased, err := applyDeprecatedKeyAliasesToYAML(data, deprecatedKeyAliases)
+	if err != nil {
+		return nil, err
+	}
+
+	// Expand ${NAME} environment references in the values. An unset variable without a
+	// default fails the load, so a hot reload keeps the previous config.
+	data, envRefs, err := interpolateEnv(data, os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand environment references: %w", err)
+	}
+
 	// Unmarshal the YAML data into the Config struct.
 	var cfg Config
+	cfg.legacyMigrationPending = aliased
 	// Set defaults before unmarshal so that absent keys keep defaults.
 	cfg.Host = "" // Default empty: binds to all interfaces (IPv4 + IPv6)
 	cfg.LoggingToFile = false
@@ -524,6 +700,8 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	cfg.AuthDir = cfg.writableAuthDir()
+
 	var legacy legacyConfigData
 	if errLegacy := yaml.Unmarshal(data, &legacy); errLegacy == nil {
 		if cfg.migrateLegacyGeminiKeys(legacy.LegacyGeminiKeys) {
@@ -532,11 +710,16 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 		if cfg.migrateLegacyOpenAICompatibilityKeys(legacy.OpenAICompat) {
 			cfg.legacyMigrationPending = true
 		}
-		if cfg.migrateLegacyAmpConfig(&legacy) {
-			cfg.legacyMigrationPending = true
-		}
 	}
 
+	// Replace "file:" and secret:// references with the secrets they point to. A missing
+	// file or secret fails the load, so a hot reload keeps the previous config instead of
+	// running without the key.
+	if err = cfg.resolveSecretReferences(); err != nil {
+		return nil, fmt.Errorf("failed to load secrets: %w", err)
+	}
+	cfg.rememberEnvReferences(envRefs)
+
 	// Hash remote management key if plaintext is detected (nested)
 	// We consider a value to be already hashed if it looks like a bcrypt hash ($2a$, $2b$, or $2y$ prefix).
 	if cfg.RemoteManagement.SecretKey != "" && !looksLikeBcrypt(cfg.RemoteManagement.SecretKey) {
@@ -544,11 +727,20 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 		if errHash != nil {
 			return nil, fmt.Errorf("failed to hash remote management key: %w", errHash)
 		}
+		secretPath, fromFile := cfg.secretFromFile(cfg.RemoteManagement.SecretKey)
+		secretRef, fromRef := cfg.secretRefs[cfg.RemoteManagement.SecretKey]
 		cfg.RemoteManagement.SecretKey = hashed
 
-		// Persist the hashed value back to the config file to avoid re-hashing on next startup.
-		// Preserve YAML comments and ordering; update only the nested key.
-		_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
+		if fromFile {
+			// Keep the file reference in the config; the hash is only held in memory.
+			cfg.rememberSecretFile(hashed, secretPath)
+		} else if fromRef {
+			cfg.rememberSecretRef(hashed, secretRef)
+		} else {
+			// Persist the hashed value back to the config file to avoid re-hashing on next startup.
+			// Preserve YAML comments and ordering; update only the nested key.
+			_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
+		}
 	}
 
 	cfg.RemoteManagement.PanelGitHubRepository = strings.TrimSpace(cfg.RemoteManagement.PanelGitHubRepository)
@@ -567,6 +759,45 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Sync request authentication providers with inline API keys for backwards compatibility.
 	syncInlineAccessProvider(&cfg)
 
+	// Apply command-line and environment overrides on top of the file values.
+	cfg.applyOverrides()
+
+	cfg.sanitize()
+
+	if cfg.legacyMigrationPending {
+		fmt.Println("Detected legacy configuration keys, attempting to persist the normalized config...")
+		if !optional && configFile != "" {
+			if err := SaveConfigPreserveComments(configFile, &cfg); err != nil {
+				return nil, fmt.Errorf("failed to persist migrated legacy config: %w", err)
+			}
+			fmt.Println("Legacy configuration normalized and persisted.")
+		} else {
+			fmt.Println("Legacy configuration normalized in memory; persistence skipped.")
+		}
+	}
+
+	// Return the populated configuration struct.
+	return &cfg, nil
+}
+
+// Normalize prepares a configuration built in code the way LoadConfig prepares one read
+// from a file: a plaintext remote management key is hashed in memory, the provider entries
+// are sanitized, and the result is validated.
+func (cfg *Config) Normalize() error {
+	if cfg.RemoteManagement.SecretKey != "" && !looksLikeBcrypt(cfg.RemoteManagement.SecretKey) {
+		hashed, errHash := hashSecret(cfg.RemoteManagement.SecretKey)
+		if errHash != nil {
+			return fmt.Errorf("failed to hash remote management key: %w", errHash)
+		}
+		cfg.RemoteManagement.SecretKey = hashed
+	}
+	cfg.sanitize()
+	return cfg.Validate()
+}
+
+// sanitize normalizes the provider credentials, the OAuth model settings and the payload
+// rules, dropping entries that cannot be used.
+func (cfg *Config) sanitize() {
 	// Sanitize Gemini API key configuration and migrate legacy entries.
 	cfg.SanitizeGeminiKeys()
 
@@ -590,21 +821,6 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 
 	// Validate raw payload rules and drop invalid entries.
 	cfg.SanitizePayloadRules()
-
-	if cfg.legacyMigrationPending {
-		fmt.Println("Detected legacy configuration keys, attempting to persist the normalized config...")
-		if !optional && configFile != "" {
-			if err := SaveConfigPreserveComments(configFile, &cfg); err != nil {
-				return nil, fmt.Errorf("failed to persist migrated legacy config: %w", err)
-			}
-			fmt.Println("Legacy configuration normalized and persisted.")
-		} else {
-			fmt.Println("Legacy configuration normalized in memory; persistence skipped.")
-		}
-	}
-
-	// Return the populated configuration struct.
-	return &cfg, nil
 }
 
 // SanitizePayloadRules validates raw JSON payload rule params and drops invalid rules.
@@ -806,7 +1022,7 @@ func syncInlineAccessProvider(cfg *Config) {
 			cfg.APIKeys = append([]string(nil), provider.APIKeys...)
 		}
 	}
-	cfg.Access.Providers = nil
+	cfg.Access.Providers = WithoutInlineAPIKeyProviders(cfg.Access.Providers)
 }
 
 // looksLikeBcrypt returns true if the provided string appears to be a bcrypt hash.
@@ -931,9 +1147,9 @@ func SaveConfigPreserveComments(configFile string, cfg *Config) error {
 	}
 
 	// Remove deprecated sections before merging back the sanitized config.
-	removeLegacyAuthBlock(original.Content[0])
+	removeLegacyAuthBlock(original.Content[0], generated.Content[0])
 	removeLegacyOpenAICompatAPIKeys(original.Content[0])
-	removeLegacyAmpKeys(original.Content[0])
+	removeDeprecatedKeys(original.Content[0])
 	removeLegacyGenerativeLanguageKeys(original.Content[0])
 
 	pruneMappingToGeneratedKeys(original.Content[0], generated.Content[0], "oauth-excluded-models")
@@ -970,7 +1186,9 @@ func sanitizeConfigForPersist(cfg *Config) *Config {
 	}
 	clone := *cfg
 	clone.SDKConfig = cfg.SDKConfig
-	clone.SDKConfig.Access = AccessConfig{}
+	clone.SDKConfig.Access = AccessConfig{Providers: WithoutInlineAPIKeyProviders(cfg.Access.Providers)}
+	cfg.restoreFileValues(&clone)
+	cfg.restoreSecretFiles(&clone)
 	return &clone
 }
 
@@ -1149,6 +1367,10 @@ func mergeNodePreserve(dst, src *yaml.Node) {
 			dst.Content = dst.Content[:len(src.Content)]
 		}
 	case yaml.ScalarNode, yaml.AliasNode:
+		// Keep ${NAME} references that still expand to the value being saved.
+		if dst.Kind == yaml.ScalarNode && keepsEnvReference(dst.Value, src.Value) {
+			return
+		}
 		// For scalars, update Tag and Value but keep Style from dst to preserve quoting
 		dst.Kind = src.Kind
 		dst.Tag = src.Tag
@@ -1521,12 +1743,8 @@ func normalizeCollectionNodeStyles(node *yaml.Node) {
 
 // Legacy migration helpers (move deprecated config keys into structured fields).
 type legacyConfigData struct {
-	LegacyGeminiKeys      []string                    `yaml:"generative-language-api-key"`
-	OpenAICompat          []legacyOpenAICompatibility `yaml:"openai-compatibility"`
-	AmpUpstreamURL        string                      `yaml:"amp-upstream-url"`
-	AmpUpstreamAPIKey     string                      `yaml:"amp-upstream-api-key"`
-	AmpRestrictManagement *bool                       `yaml:"amp-restrict-management-to-localhost"`
-	AmpModelMappings      []AmpModelMapping           `yaml:"amp-model-mappings"`
+	LegacyGeminiKeys []string                    `yaml:"generative-language-api-key"`
+	OpenAICompat     []legacyOpenAICompatibility `yaml:"openai-compatibility"`
 }
 
 type legacyOpenAICompatibility struct {
@@ -1639,34 +1857,6 @@ func findOpenAICompatTarget(entries []OpenAICompatibility, legacyName, legacyBas
 	return nil
 }
 
-func (cfg *Config) migrateLegacyAmpConfig(legacy *legacyConfigData) bool {
-	if cfg == nil || legacy == nil {
-		return false
-	}
-	changed := false
-	if cfg.AmpCode.UpstreamURL == "" {
-		if val := strings.TrimSpace(legacy.AmpUpstreamURL); val != "" {
-			cfg.AmpCode.UpstreamURL = val
-			changed = true
-		}
-	}
-	if cfg.AmpCode.UpstreamAPIKey == "" {
-		if val := strings.TrimSpace(legacy.AmpUpstreamAPIKey); val != "" {
-			cfg.AmpCode.UpstreamAPIKey = val
-			changed = true
-		}
-	}
-	if legacy.AmpRestrictManagement != nil {
-		cfg.AmpCode.RestrictManagementToLocalhost = *legacy.AmpRestrictManagement
-		changed = true
-	}
-	if len(cfg.AmpCode.ModelMappings) == 0 && len(legacy.AmpModelMappings) > 0 {
-		cfg.AmpCode.ModelMappings = append([]AmpModelMapping(nil), legacy.AmpModelMappings...)
-		changed = true
-	}
-	return changed
-}
-
 func removeLegacyOpenAICompatAPIKeys(root *yaml.Node) {
 	if root == nil || root.Kind != yaml.MappingNode {
 		return
@@ -1686,16 +1876,6 @@ func removeLegacyOpenAICompatAPIKeys(root *yaml.Node) {
 	}
 }
 
-func removeLegacyAmpKeys(root *yaml.Node) {
-	if root == nil || root.Kind != yaml.MappingNode {
-		return
-	}
-	removeMapKey(root, "amp-upstream-url")
-	removeMapKey(root, "amp-upstream-api-key")
-	removeMapKey(root, "amp-restrict-management-to-localhost")
-	removeMapKey(root, "amp-model-mappings")
-}
-
 func removeLegacyGenerativeLanguageKeys(root *yaml.Node) {
 	if root == nil || root.Kind != yaml.MappingNode {
 		return
@@ -1703,9 +1883,44 @@ func removeLegacyGenerativeLanguageKeys(root *yaml.Node) {
 	removeMapKey(root, "generative-language-api-key")
 }
 
-func removeLegacyAuthBlock(root *yaml.Node) {
+// removeLegacyAuthBlock drops the config-api-key entries of auth.providers, whose keys now live
+// in api-keys, and the whole auth block once the config being saved has no other provider,
+// such as jwt, left in it.
+func removeLegacyAuthBlock(root, generated *yaml.Node) {
 	if root == nil || root.Kind != yaml.MappingNode {
 		return
 	}
+	idx := findMapKeyIndex(root, "auth")
+	if idx < 0 {
+		return
+	}
+	if findMapKeyIndex(generated, "auth") < 0 {
+		removeMapKey(root, "auth")
+		return
+	}
+	if auth := root.Content[idx+1]; auth != nil && auth.Kind == yaml.MappingNode {
+		if pIdx := findMapKeyIndex(auth, "providers"); pIdx >= 0 {
+			if seq := auth.Content[pIdx+1]; seq != nil && seq.Kind == yaml.SequenceNode {
+				kept := seq.Content[:0]
+				for _, item := range seq.Content {
+					if !isInlineAPIKeyProviderNode(item) {
+						kept = append(kept, item)
+					}
+				}
+				seq.Content = kept
+				if len(kept) > 0 {
+					return
+				}
+			}
+		}
+	}
 	removeMapKey(root, "auth")
 }
+
+func isInlineAPIKeyProviderNode(node *yaml.Node) bool {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return false
+	}
+	idx := findMapKeyIndex(node, "type")
+	return idx >= 0 && strings.EqualFold(strings.TrimSpace(node.Content[idx+1].Value), AccessProviderTypeConfigAPIKey)
+}
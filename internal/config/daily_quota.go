@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// Embedded zone data keeps reset-timezone working on hosts without a zoneinfo database.
+	_ "time/tzdata"
+)
+
+// DailyQuota declares a known provider-side daily request limit for OAuth accounts.
+type DailyQuota struct {
+	// Provider is the auth provider key the limit applies to (e.g. "gemini-cli").
+	Provider string `yaml:"provider" json:"provider"`
+
+	// DailyRequests is the number of requests each account may send per day.
+	// An auth file can override it with a "daily_requests" field.
+	DailyRequests int64 `yaml:"daily-requests" json:"daily-requests"`
+
+	// Margin takes an account out of rotation once this many requests or fewer remain,
+	// leaving room for requests that are already in flight.
+	Margin int64 `yaml:"margin,omitempty" json:"margin,omitempty"`
+
+	// ResetTimezone is the IANA zone whose midnight starts a new quota day.
+	// Google providers default to America/Los_Angeles, everything else to UTC.
+	ResetTimezone string `yaml:"reset-timezone,omitempty" json:"reset-timezone,omitempty"`
+}
+
+// googleQuotaProviders reset their daily quotas at midnight Pacific time.
+var googleQuotaProviders = map[string]struct{}{
+	"gemini":      {},
+	"gemini-cli":  {},
+	"vertex":      {},
+	"aistudio":    {},
+	"antigravity": {},
+}
+
+// ResetLocation returns the time zone used to compute quota days for the entry.
+func (q DailyQuota) ResetLocation() *time.Location {
+	name := strings.TrimSpace(q.ResetTimezone)
+	if name == "" {
+		if _, ok := googleQuotaProviders[strings.ToLower(strings.TrimSpace(q.Provider))]; ok {
+			name = "America/Los_Angeles"
+		}
+	}
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func (cfg *Config) validateDailyQuotas() []error {
+	var errs []error
+	seen := make(map[string]struct{}, len(cfg.Routing.DailyQuotas))
+	for i, quota := range cfg.Routing.DailyQuotas {
+		provider := strings.ToLower(strings.TrimSpace(quota.Provider))
+		if provider == "" {
+			errs = append(errs, fmt.Errorf("routing.daily-quotas[%d]: provider is required", i))
+			continue
+		}
+		if _, dup := seen[provider]; dup {
+			errs = append(errs, fmt.Errorf("routing.daily-quotas: provider %q is listed more than once", provider))
+		}
+		seen[provider] = struct{}{}
+		if quota.DailyRequests <= 0 {
+			errs = append(errs, fmt.Errorf("routing.daily-quotas[%s]: daily-requests must be positive", provider))
+		}
+		if quota.Margin < 0 || (quota.DailyRequests > 0 && quota.Margin >= quota.DailyRequests) {
+			errs = append(errs, fmt.Errorf("routing.daily-quotas[%s]: margin must be between 0 and daily-requests", provider))
+		}
+		if tz := strings.TrimSpace(quota.ResetTimezone); tz != "" {
+			if _, err := time.LoadLocation(tz); err != nil {
+				errs = append(errs, fmt.Errorf("routing.daily-quotas[%s]: invalid reset-timezone %q: %w", provider, tz, err))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"gopkg.in/yaml.v3"
+)
+
+// DeprecatedKeyAlias maps a renamed configuration key to its replacement.
+// Paths are dot-separated YAML key paths relative to the document root.
+type DeprecatedKeyAlias struct {
+	// Old is the deprecated key path still accepted by the loader.
+	Old string
+	// New is the key path the value is moved to.
+	New string
+	// RemovedIn is the release in which Old stops being recognised.
+	RemovedIn string
+}
+
+// deprecatedKeyAliases lists every renamed key the loader still accepts.
+// When renaming a key, add an entry here instead of writing a bespoke migration.
+var deprecatedKeyAliases = []DeprecatedKeyAlias{
+	{Old: "amp-upstream-url", New: "ampcode.upstream-url", RemovedIn: "v7.0.0"},
+	{Old: "amp-upstream-api-key", New: "ampcode.upstream-api-key", RemovedIn: "v7.0.0"},
+	{Old: "amp-restrict-management-to-localhost", New: "ampcode.restrict-management-to-localhost", RemovedIn: "v7.0.0"},
+	{Old: "amp-model-mappings", New: "ampcode.model-mappings", RemovedIn: "v7.0.0"},
+}
+
+// DeprecatedKeyAliases returns a copy of the deprecated key mapping table.
+func DeprecatedKeyAliases() []DeprecatedKeyAlias {
+	return append([]DeprecatedKeyAlias(nil), deprecatedKeyAliases...)
+}
+
+// deprecatedKeyWarned tracks which deprecated keys have already been reported so
+// repeated loads (for example on hot reload) do not flood the log.
+var deprecatedKeyWarned sync.Map
+
+// DeprecatedKeyConflictError reports that both a deprecated key and its
+// replacement are set to different values.
+type DeprecatedKeyConflictError struct {
+	Old string
+	New string
+}
+
+func (e *DeprecatedKeyConflictError) Error() string {
+	return fmt.Sprintf("config keys %q (deprecated) and %q are both set with different values; remove %q", e.Old, e.New, e.Old)
+}
+
+// applyDeprecatedKeyAliasesToYAML rewrites deprecated keys in raw YAML data.
+// Data that cannot be parsed is returned unchanged so the caller reports the parse error.
+func applyDeprecatedKeyAliasesToYAML(data []byte, aliases []DeprecatedKeyAlias) ([]byte, bool, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return data, false, nil
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0] == nil || root.Content[0].Kind != yaml.MappingNode {
+		return data, false, nil
+	}
+	changed, err := applyDeprecatedKeyAliases(root.Content[0], aliases)
+	if err != nil || !changed {
+		return data, false, err
+	}
+	out, err := yaml.Marshal(&root)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to rewrite deprecated config keys: %w", err)
+	}
+	return out, true, nil
+}
+
+// applyDeprecatedKeyAliases moves values stored under deprecated keys to their
+// replacement keys within the given root mapping node.
+// It returns true when at least one deprecated key was found.
+func applyDeprecatedKeyAliases(root *yaml.Node, aliases []DeprecatedKeyAlias) (bool, error) {
+	if root == nil || root.Kind != yaml.MappingNode {
+		return false, nil
+	}
+	changed := false
+	for _, alias := range aliases {
+		oldPath := splitKeyPath(alias.Old)
+		newPath := splitKeyPath(alias.New)
+		if len(oldPath) == 0 || len(newPath) == 0 {
+			continue
+		}
+		oldValue := lookupNodePath(root, oldPath)
+		if oldValue == nil {
+			continue
+		}
+		newValue := lookupNodePath(root, newPath)
+		if isNodeSet(newValue) && !nodesStructurallyEqual(oldValue, newValue) {
+			return false, &DeprecatedKeyConflictError{Old: alias.Old, New: alias.New}
+		}
+		if !isNodeSet(newValue) {
+			setNodePath(root, newPath, deepCopyNode(oldValue))
+		}
+		removeNodePath(root, oldPath)
+		warnDeprecatedKey(alias)
+		changed = true
+	}
+	return changed, nil
+}
+
+// removeDeprecatedKeys drops every deprecated key from the root mapping node.
+func removeDeprecatedKeys(root *yaml.Node) {
+	for _, alias := range deprecatedKeyAliases {
+		removeNodePath(root, splitKeyPath(alias.Old))
+	}
+}
+
+func warnDeprecatedKey(alias DeprecatedKeyAlias) {
+	if _, loaded := deprecatedKeyWarned.LoadOrStore(alias.Old, struct{}{}); loaded {
+		return
+	}
+	log.Warnf("config key %q is deprecated and will be removed in %s; use %q instead", alias.Old, alias.RemovedIn, alias.New)
+}
+
+func splitKeyPath(path string) []string {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+func lookupNodePath(root *yaml.Node, path []string) *yaml.Node {
+	node := root
+	for _, key := range path {
+		idx := findMapKeyIndex(node, key)
+		if idx < 0 {
+			return nil
+		}
+		node = node.Content[idx+1]
+	}
+	return node
+}
+
+func setNodePath(root *yaml.Node, path []string, value *yaml.Node) {
+	node := root
+	for i, key := range path {
+		if i == len(path)-1 {
+			if idx := findMapKeyIndex(node, key); idx >= 0 {
+				node.Content[idx+1] = value
+				return
+			}
+			if node.Kind != yaml.MappingNode {
+				node.Kind = yaml.MappingNode
+				node.Tag = "!!map"
+				node.Value = ""
+				node.Content = nil
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+			return
+		}
+		next := getOrCreateMapValue(node, key)
+		if next.Kind != yaml.MappingNode {
+			next.Kind = yaml.MappingNode
+			next.Tag = "!!map"
+			next.Value = ""
+			next.Content = nil
+		}
+		node = next
+	}
+}
+
+func removeNodePath(root *yaml.Node, path []string) {
+	if len(path) == 0 {
+		return
+	}
+	parent := root
+	if len(path) > 1 {
+		parent = lookupNodePath(root, path[:len(path)-1])
+	}
+	removeMapKey(parent, path[len(path)-1])
+}
+
+// isNodeSet reports whether the node carries an explicit, non-null value.
+func isNodeSet(node *yaml.Node) bool {
+	if node == nil {
+		return false
+	}
+	if node.Kind == yaml.ScalarNode {
+		return node.Tag != "!!null" && strings.TrimSpace(node.Value) != ""
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDeprecatedKeyAliases_TableIsConsistent(t *testing.T) {
+	seenOld := make(map[string]struct{}, len(deprecatedKeyAliases))
+	newKeys := make(map[string]struct{}, len(deprecatedKeyAliases))
+	for _, alias := range deprecatedKeyAliases {
+		newKeys[alias.New] = struct{}{}
+	}
+	for _, alias := range deprecatedKeyAliases {
+		if alias.Old == "" || alias.New == "" {
+			t.Fatalf("alias with empty key: %+v", alias)
+		}
+		if alias.Old == alias.New {
+			t.Fatalf("alias maps %q onto itself", alias.Old)
+		}
+		if strings.TrimSpace(alias.RemovedIn) == "" {
+			t.Fatalf("alias %q is missing a removal version", alias.Old)
+		}
+		if _, dup := seenOld[alias.Old]; dup {
+			t.Fatalf("duplicate alias for %q", alias.Old)
+		}
+		seenOld[alias.Old] = struct{}{}
+		if _, chained := newKeys[alias.Old]; chained {
+			t.Fatalf("alias %q is also a replacement key; point older aliases at the final key instead", alias.Old)
+		}
+	}
+}
+
+func TestDeprecatedKeyAliases_MapsEveryEntry(t *testing.T) {
+	for _, alias := range deprecatedKeyAliases {
+		t.Run(alias.Old, func(t *testing.T) {
+			root := mappingFromYAML(t, yamlForPath(splitKeyPath(alias.Old), "legacy-value"))
+			changed, err := applyDeprecatedKeyAliases(root, []DeprecatedKeyAlias{alias})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !changed {
+				t.Fatalf("expected %q to be reported as changed", alias.Old)
+			}
+			if lookupNodePath(root, splitKeyPath(alias.Old)) != nil {
+				t.Fatalf("expected %q to be removed", alias.Old)
+			}
+			got := lookupNodePath(root, splitKeyPath(alias.New))
+			if got == nil || got.Value != "legacy-value" {
+				t.Fatalf("expected %q to hold the legacy value, got %+v", alias.New, got)
+			}
+		})
+	}
+}
+
+func TestDeprecatedKeyAliases_ConflictAndAgreement(t *testing.T) {
+	alias := DeprecatedKeyAlias{Old: "old-key", New: "section.new-key", RemovedIn: "v9.9.9"}
+
+	root := mappingFromYAML(t, "old-key: a\nsection:\n  new-key: b\n")
+	_, err := applyDeprecatedKeyAliases(root, []DeprecatedKeyAlias{alias})
+	var conflict *DeprecatedKeyConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "old-key") || !strings.Contains(err.Error(), "section.new-key") {
+		t.Fatalf("conflict error should name both keys, got %q", err.Error())
+	}
+
+	root = mappingFromYAML(t, "old-key: same\nsection:\n  new-key: same\n")
+	changed, err := applyDeprecatedKeyAliases(root, []DeprecatedKeyAlias{alias})
+	if err != nil {
+		t.Fatalf("matching values should not conflict: %v", err)
+	}
+	if !changed || lookupNodePath(root, []string{"old-key"}) != nil {
+		t.Fatalf("expected old key to be dropped when values agree")
+	}
+}
+
+func TestLoadConfig_DeprecatedKeyConflict(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	content := "amp-upstream-url: https://old.example.com\nampcode:\n  upstream-url: https://new.example.com\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatalf("expected conflicting deprecated keys to fail loading")
+	}
+}
+
+func mappingFromYAML(t *testing.T, data string) *yaml.Node {
+	t.Helper()
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatalf("parse yaml: %v", err)
+	}
+	if len(doc.Content) == 0 {
+		t.Fatalf("empty yaml document")
+	}
+	return doc.Content[0]
+}
+
+func yamlForPath(path []string, value string) string {
+	var b strings.Builder
+	for i, key := range path {
+		b.WriteString(strings.Repeat("  ", i))
+		b.WriteString(key)
+		b.WriteString(":")
+		if i == len(path)-1 {
+			b.WriteString(" " + value)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
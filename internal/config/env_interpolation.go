@@ -0,0 +1,140 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envReference matches ${NAME} and ${NAME:-default} in config values; a leading "$$"
+// escapes the reference so that it is kept literally.
+var envReference = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// envInterpolationSkipKeys name YAML keys whose values are left untouched because they are
+// expanded later, per request.
+var envInterpolationSkipKeys = map[string]struct{}{
+	"inject-headers": {},
+}
+
+// expandEnvReferences substitutes ${NAME} references with lookup in value. An unset or
+// empty variable takes the default given as ${NAME:-default}; without one it is an error,
+// so that a missing secret fails the load instead of leaving the field empty.
+func expandEnvReferences(value string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+	var missing []string
+	out := envReference.ReplaceAllStringFunc(value, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		match := envReference.FindStringSubmatch(ref)
+		if v, ok := lookup(match[1]); ok && v != "" {
+			return v
+		}
+		if def, ok := strings.CutPrefix(match[2], ":-"); ok {
+			return def
+		}
+		missing = append(missing, match[1])
+		return ""
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// interpolateEnv expands the environment references in every scalar value of the YAML
+// document data. It returns data unchanged when nothing was expanded, and otherwise the
+// re-encoded document with, for each expanded value, the text it was written as.
+func interpolateEnv(data []byte, lookup func(string) (string, bool)) ([]byte, map[string]string, error) {
+	if !strings.Contains(string(data), "${") {
+		return data, nil, nil
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		// Leave the error to the decoder, which reports it with the usual context.
+		return data, nil, nil
+	}
+	refs := make(map[string]string)
+	var errs []error
+	var walk func(node *yaml.Node, path string)
+	expand := func(node *yaml.Node, path string) {
+		if node.Kind != yaml.ScalarNode {
+			walk(node, path)
+			return
+		}
+		if !strings.Contains(node.Value, "${") {
+			return
+		}
+		value, err := expandEnvReferences(node.Value, lookup)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			return
+		}
+		refs[value] = node.Value
+		// Let the expanded text resolve its own type, so that port: "${PORT}" is a number.
+		node.Value, node.Tag, node.Style = value, "", 0
+	}
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i].Value
+				if _, skip := envInterpolationSkipKeys[key]; skip {
+					continue
+				}
+				child := key
+				if path != "" {
+					child = path + "." + key
+				}
+				expand(node.Content[i+1], child)
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				expand(item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+	walk(&root, "")
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	if len(refs) == 0 {
+		return data, nil, nil
+	}
+	out, err := yaml.Marshal(&root)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, refs, nil
+}
+
+// rememberEnvReferences records, for every secret that was written as an environment
+// reference, the reference to show and save in its place.
+func (cfg *Config) rememberEnvReferences(refs map[string]string) {
+	for _, secret := range cfg.secretValues() {
+		if ref, ok := refs[*secret.value]; ok && *secret.value != "" {
+			cfg.rememberSecretRef(*secret.value, ref)
+		}
+	}
+}
+
+// keepsEnvReference reports whether original, a value as written in the config file, holds
+// environment references that still expand to value, so that saving value back would only
+// replace the reference with what it points to.
+func keepsEnvReference(original, value string) bool {
+	if !strings.Contains(original, "${") {
+		return false
+	}
+	expanded, err := expandEnvReferences(original, os.LookupEnv)
+	return err == nil && expanded == value
+}
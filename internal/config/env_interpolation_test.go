@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLoadConfigExpandsEnvReferences(t *testing.T) {
+	t.Setenv("TEST_CLIPROXY_PORT", "9000")
+	t.Setenv("TEST_CLIPROXY_CLIENT_KEY", "client-secret")
+	t.Setenv("TEST_CLIPROXY_MGMT_KEY", "admin-password")
+	t.Setenv("TEST_CLIPROXY_PROXY", "")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeTestFile(t, path, "# client keys come from the environment\n"+
+		"port: \"${TEST_CLIPROXY_PORT}\"\n"+
+		"proxy-url: \"${TEST_CLIPROXY_PROXY:-socks5://127.0.0.1:1080}\"\n"+
+		"api-keys:\n  - \"${TEST_CLIPROXY_CLIENT_KEY}\"\n  - \"literal-$${TEST_CLIPROXY_CLIENT_KEY}\"\n"+
+		"remote-management:\n  secret-key: \"${TEST_CLIPROXY_MGMT_KEY}\"\n"+
+		"upstream-headers:\n  claude:\n    inject-headers:\n      x-project: \"${TEST_CLIPROXY_UNSET}\"\n")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Port != 9000 || cfg.ProxyURL != "socks5://127.0.0.1:1080" {
+		t.Fatalf("port = %d, proxy-url = %q", cfg.Port, cfg.ProxyURL)
+	}
+	if cfg.APIKeys[0] != "client-secret" || cfg.APIKeys[1] != "literal-${TEST_CLIPROXY_CLIENT_KEY}" {
+		t.Fatalf("api-keys = %v", cfg.APIKeys)
+	}
+	if got := cfg.UpstreamHeaders["claude"].InjectHeaders["x-project"]; got != "${TEST_CLIPROXY_UNSET}" {
+		t.Fatalf("inject-headers expanded at load: %q", got)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(cfg.RemoteManagement.SecretKey), []byte("admin-password")) != nil {
+		t.Fatal("management secret from the environment should be hashed in memory")
+	}
+
+	// The hash is not written back over the reference, and neither is anything else.
+	cfg.Debug = true
+	if err = SaveConfigPreserveComments(path, cfg); err != nil {
+		t.Fatalf("SaveConfigPreserveComments: %v", err)
+	}
+	saved, _ := os.ReadFile(path)
+	printed, _ := yaml.Marshal(cfg.WithSecretReferences())
+	for _, ref := range []string{"${TEST_CLIPROXY_PORT}", "${TEST_CLIPROXY_CLIENT_KEY}", "${TEST_CLIPROXY_MGMT_KEY}", "${TEST_CLIPROXY_PROXY:-socks5://127.0.0.1:1080}", "# client keys"} {
+		if !strings.Contains(string(saved), ref) {
+			t.Fatalf("saved config lost %q:\n%s", ref, saved)
+		}
+	}
+	if !strings.Contains(string(saved), "debug: true") {
+		t.Fatalf("saved config lost the change:\n%s", saved)
+	}
+	for name, out := range map[string]string{"saved": string(saved), "printed": string(printed)} {
+		if strings.Contains(out, "client-secret") || strings.Contains(out, "$2a$") {
+			t.Fatalf("%s config exposes a secret:\n%s", name, out)
+		}
+	}
+
+	if _, err = LoadConfig(path); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	writeTestFile(t, path, "api-keys:\n  - \"${TEST_CLIPROXY_UNSET}\"\n")
+	if _, err = LoadConfig(path); err == nil || !strings.Contains(err.Error(), "api-keys[0]") {
+		t.Fatalf("unset variable error = %v, want it to name api-keys[0]", err)
+	}
+}
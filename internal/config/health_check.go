@@ -0,0 +1,63 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Defaults applied to health-check settings when unset or invalid.
+const (
+	DefaultHealthCheckInterval         = 5 * time.Minute
+	DefaultHealthCheckRecoveryInterval = 15 * time.Minute
+	DefaultHealthCheckTimeout          = 10 * time.Second
+	DefaultHealthCheckFailureThreshold = 3
+)
+
+// HealthCheckConfig configures periodic credential health probes.
+type HealthCheckConfig struct {
+	// Enabled toggles background health probes for credentials whose provider supports them.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Interval is the probe interval for healthy credentials (Go duration, default "5m").
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"`
+	// FailureThreshold is the number of consecutive failures before a credential leaves rotation (default 3).
+	FailureThreshold int `yaml:"failure-threshold,omitempty" json:"failure-threshold,omitempty"`
+	// RecoveryInterval is the re-probe interval for unhealthy credentials (Go duration, default "15m").
+	RecoveryInterval string `yaml:"recovery-interval,omitempty" json:"recovery-interval,omitempty"`
+	// Timeout bounds a single probe (Go duration, default "10s").
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// IntervalDuration returns the probe interval for healthy credentials.
+func (h HealthCheckConfig) IntervalDuration() time.Duration {
+	return positiveDurationOr(h.Interval, DefaultHealthCheckInterval)
+}
+
+// RecoveryIntervalDuration returns the re-probe interval for unhealthy credentials.
+func (h HealthCheckConfig) RecoveryIntervalDuration() time.Duration {
+	return positiveDurationOr(h.RecoveryInterval, DefaultHealthCheckRecoveryInterval)
+}
+
+// TimeoutDuration returns the per-probe timeout.
+func (h HealthCheckConfig) TimeoutDuration() time.Duration {
+	return positiveDurationOr(h.Timeout, DefaultHealthCheckTimeout)
+}
+
+// Threshold returns the consecutive failure count that marks a credential unhealthy.
+func (h HealthCheckConfig) Threshold() int {
+	if h.FailureThreshold <= 0 {
+		return DefaultHealthCheckFailureThreshold
+	}
+	return h.FailureThreshold
+}
+
+func positiveDurationOr(raw string, fallback time.Duration) time.Duration {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(trimmed)
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultMaxIdleConnsPerHost keeps enough warm connections per upstream host for
+	// concurrent streams; net/http's default of 2 forces a new handshake for most requests.
+	DefaultMaxIdleConnsPerHost = 32
+	// DefaultIdleConnTimeout closes pooled connections that stay unused this long.
+	DefaultIdleConnTimeout = 90 * time.Second
+)
+
+// HTTPTransportConfig tunes the shared transports used for upstream requests.
+type HTTPTransportConfig struct {
+	// MaxIdleConnsPerHost caps the idle connections kept per upstream host. 0 uses the default.
+	MaxIdleConnsPerHost int `yaml:"max-idle-conns-per-host,omitempty" json:"max-idle-conns-per-host,omitempty"`
+
+	// IdleConnTimeoutSeconds closes idle pooled connections after this many seconds. 0 uses the default.
+	IdleConnTimeoutSeconds int `yaml:"idle-conn-timeout-seconds,omitempty" json:"idle-conn-timeout-seconds,omitempty"`
+
+	// DisableHTTP2 stops transports from negotiating HTTP/2 with upstreams.
+	DisableHTTP2 bool `yaml:"disable-http2,omitempty" json:"disable-http2,omitempty"`
+}
+
+// EffectiveMaxIdleConnsPerHost returns the configured per-host idle limit or the default.
+func (c HTTPTransportConfig) EffectiveMaxIdleConnsPerHost() int {
+	if c.MaxIdleConnsPerHost > 0 {
+		return c.MaxIdleConnsPerHost
+	}
+	return DefaultMaxIdleConnsPerHost
+}
+
+// EffectiveIdleConnTimeout returns the configured idle timeout or the default.
+func (c HTTPTransportConfig) EffectiveIdleConnTimeout() time.Duration {
+	if c.IdleConnTimeoutSeconds > 0 {
+		return time.Duration(c.IdleConnTimeoutSeconds) * time.Second
+	}
+	return DefaultIdleConnTimeout
+}
+
+func (cfg *Config) validateHTTPTransport() []error {
+	var errs []error
+	if cfg.HTTPTransport.MaxIdleConnsPerHost < 0 {
+		errs = append(errs, fmt.Errorf("http-transport.max-idle-conns-per-host: must not be negative, got %d", cfg.HTTPTransport.MaxIdleConnsPerHost))
+	}
+	if cfg.HTTPTransport.IdleConnTimeoutSeconds < 0 {
+		errs = append(errs, fmt.Errorf("http-transport.idle-conn-timeout-seconds: must not be negative, got %d", cfg.HTTPTransport.IdleConnTimeoutSeconds))
+	}
+	return errs
+}
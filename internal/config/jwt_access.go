@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultJWTPrincipalClaim is the claim a JWT is attributed by when principal-claim is unset.
+	DefaultJWTPrincipalClaim = "sub"
+	// DefaultJWTLeeway is the clock skew tolerated on exp and nbf when leeway is unset.
+	DefaultJWTLeeway = time.Minute
+	// DefaultJWKSRefresh is how long a fetched key set is used before it is fetched again
+	// when jwks-refresh is unset.
+	DefaultJWKSRefresh = time.Hour
+)
+
+// JWTProviderOptions are the settings of a jwt access provider, read from its config map:
+//
+//	jwks-url:        where the signing keys are published (required; https unless loopback)
+//	issuer:          the required iss claim
+//	audience:        one or more accepted aud values (issuer, audience or both are required)
+//	principal-claim: the claim that identifies the caller (default sub)
+//	label-claims:    usage label name -> claim
+//	leeway:          clock skew tolerated on exp and nbf (default 1m)
+//	jwks-refresh:    how long a fetched key set is used (default 1h)
+//
+// The principal stands in for an API key: usage statistics are kept under it, and
+// api-key-settings entries whose api-key is the principal apply their scopes and quota.
+type JWTProviderOptions struct {
+	JWKSURL        string
+	Issuer         string
+	Audiences      []string
+	PrincipalClaim string
+	LabelClaims    map[string]string
+	Leeway         time.Duration
+	JWKSRefresh    time.Duration
+}
+
+// ParseJWTProviderOptions reads the options of a jwt access provider.
+func ParseJWTProviderOptions(provider *AccessProvider) (JWTProviderOptions, error) {
+	opts := JWTProviderOptions{
+		PrincipalClaim: DefaultJWTPrincipalClaim,
+		Leeway:         DefaultJWTLeeway,
+		JWKSRefresh:    DefaultJWKSRefresh,
+	}
+	raw := provider.Config
+	var err error
+	if opts.JWKSURL, err = optionString(raw, "jwks-url"); err != nil {
+		return opts, err
+	}
+	if u, errURL := url.Parse(opts.JWKSURL); opts.JWKSURL == "" || errURL != nil || u.Host == "" ||
+		(u.Scheme != "https" && (u.Scheme != "http" || !isLoopbackHost(u.Hostname()))) {
+		return opts, fmt.Errorf("jwks-url %q must be an https URL (http only for a loopback host)", opts.JWKSURL)
+	}
+	if opts.Issuer, err = optionString(raw, "issuer"); err != nil {
+		return opts, err
+	}
+	switch audience := raw["audience"].(type) {
+	case nil:
+	case string:
+		opts.Audiences = []string{strings.TrimSpace(audience)}
+	case []any:
+		for _, item := range audience {
+			value, ok := item.(string)
+			if !ok {
+				return opts, fmt.Errorf("audience must be a string or a list of strings")
+			}
+			opts.Audiences = append(opts.Audiences, strings.TrimSpace(value))
+		}
+	default:
+		return opts, fmt.Errorf("audience must be a string or a list of strings")
+	}
+	if opts.Issuer == "" && len(opts.Audiences) == 0 {
+		// Without either, a token the same identity provider signed for any other service
+		// would be accepted.
+		return opts, fmt.Errorf("issuer or audience is required")
+	}
+	if claim, errClaim := optionString(raw, "principal-claim"); errClaim != nil {
+		return opts, errClaim
+	} else if claim != "" {
+		opts.PrincipalClaim = claim
+	}
+	if labels, ok := raw["label-claims"]; ok && labels != nil {
+		entries, isMap := labels.(map[string]any)
+		if !isMap {
+			return opts, fmt.Errorf("label-claims must map usage label names to claims")
+		}
+		names := make([]string, 0, len(entries))
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		opts.LabelClaims = make(map[string]string, len(entries))
+		for _, name := range names {
+			claim, isString := entries[name].(string)
+			if !isString || strings.TrimSpace(claim) == "" {
+				return opts, fmt.Errorf("label-claims: %s must name a claim", name)
+			}
+			if !ValidUsageLabelName(name) {
+				return opts, fmt.Errorf("label-claims: %q is not a valid usage label name", name)
+			}
+			opts.LabelClaims[name] = strings.TrimSpace(claim)
+		}
+	}
+	for _, duration := range []struct {
+		key    string
+		target *time.Duration
+	}{
+		{"leeway", &opts.Leeway},
+		{"jwks-refresh", &opts.JWKSRefresh},
+	} {
+		value, errValue := optionString(raw, duration.key)
+		if errValue != nil {
+			return opts, errValue
+		}
+		if value == "" {
+			continue
+		}
+		d, errParse := time.ParseDuration(value)
+		if errParse != nil || d < 0 {
+			return opts, fmt.Errorf("%s: invalid duration %q", duration.key, value)
+		}
+		*duration.target = d
+	}
+	return opts, nil
+}
+
+// isLoopbackHost reports whether host names this machine, so plain http cannot be intercepted.
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func optionString(raw map[string]any, key string) (string, error) {
+	switch value := raw[key].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return strings.TrimSpace(value), nil
+	default:
+		return "", fmt.Errorf("%s must be a string", key)
+	}
+}
+
+func (cfg *Config) validateJWTProviders() []error {
+	var errs []error
+	for i := range cfg.Access.Providers {
+		provider := &cfg.Access.Providers[i]
+		if !strings.EqualFold(strings.TrimSpace(provider.Type), AccessProviderTypeJWT) {
+			continue
+		}
+		if _, err := ParseJWTProviderOptions(provider); err != nil {
+			errs = append(errs, fmt.Errorf("auth.providers[%d]: %w", i, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveKeepsJWTProvidersAndDropsInlineKeyProviders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeTestFile(t, path, "port: 8317\n"+
+		"api-keys:\n  - static-key\n"+
+		"# SSO service tokens\n"+
+		"auth:\n  providers:\n"+
+		"    - name: legacy\n      type: config-api-key\n      api-keys: [static-key]\n"+
+		"    - name: sso\n      type: jwt\n      config:\n        jwks-url: \"https://sso.example.com/jwks\"\n        issuer: \"https://sso.example.com/\"\n")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if len(cfg.Access.Providers) != 1 || cfg.Access.Providers[0].Name != "sso" {
+		t.Fatalf("loaded providers = %+v, want only sso", cfg.Access.Providers)
+	}
+
+	cfg.Debug = true
+	if err = SaveConfigPreserveComments(path, cfg); err != nil {
+		t.Fatalf("SaveConfigPreserveComments: %v", err)
+	}
+	saved, _ := os.ReadFile(path)
+	if strings.Contains(string(saved), "config-api-key") || !strings.Contains(string(saved), "# SSO service tokens") {
+		t.Fatalf("saved config should keep the auth block without the inline key provider:\n%s", saved)
+	}
+
+	reloaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(reloaded.Access.Providers) != 1 || reloaded.Access.Providers[0].Type != AccessProviderTypeJWT ||
+		reloaded.Access.Providers[0].Config["jwks-url"] != "https://sso.example.com/jwks" {
+		t.Fatalf("reloaded providers = %+v, want the jwt provider", reloaded.Access.Providers)
+	}
+	if !reloaded.Debug || len(reloaded.APIKeys) != 1 || reloaded.APIKeys[0] != "static-key" {
+		t.Fatalf("reloaded debug %v api-keys %v", reloaded.Debug, reloaded.APIKeys)
+	}
+
+	// Without any provider left, the auth block goes away entirely.
+	reloaded.Access.Providers = nil
+	if err = SaveConfigPreserveComments(path, reloaded); err != nil {
+		t.Fatalf("SaveConfigPreserveComments: %v", err)
+	}
+	if saved, _ = os.ReadFile(path); strings.Contains(string(saved), "auth:") {
+		t.Fatalf("auth block should be removed:\n%s", saved)
+	}
+}
+
+func TestParseJWTProviderOptionsRequiresBinding(t *testing.T) {
+	cases := []struct {
+		config map[string]any
+		ok     bool
+	}{
+		{map[string]any{"jwks-url": "https://sso.example.com/jwks", "issuer": "https://sso.example.com/"}, true},
+		{map[string]any{"jwks-url": "https://sso.example.com/jwks", "audience": "cliproxy"}, true},
+		{map[string]any{"jwks-url": "http://127.0.0.1:8080/jwks", "issuer": "local"}, true},
+		{map[string]any{"jwks-url": "http://localhost/jwks", "audience": []any{"cliproxy"}}, true},
+		{map[string]any{"jwks-url": "https://sso.example.com/jwks"}, false},
+		{map[string]any{"jwks-url": "http://sso.example.com/jwks", "issuer": "https://sso.example.com/"}, false},
+		{map[string]any{"jwks-url": "ftp://sso.example.com/jwks", "issuer": "https://sso.example.com/"}, false},
+	}
+	for _, tc := range cases {
+		_, err := ParseJWTProviderOptions(&AccessProvider{Type: AccessProviderTypeJWT, Config: tc.config})
+		if (err == nil) != tc.ok {
+			t.Errorf("ParseJWTProviderOptions(%v) error = %v, want ok %v", tc.config, err, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Defaults applied to log-sampling settings when unset.
+const (
+	DefaultLogSamplingBurst    = 10
+	DefaultLogSamplingInterval = time.Minute
+)
+
+// LogSamplingConfig thins out routine info and debug log lines under high request volume.
+// Warnings and errors are never sampled.
+type LogSamplingConfig struct {
+	// Rate keeps one in Rate lines from each log site once its burst is used up; 0 or 1 keeps all.
+	Rate int `yaml:"rate,omitempty" json:"rate,omitempty"`
+	// Burst is how many lines from each log site are kept per interval before sampling starts (default 10).
+	Burst int `yaml:"burst,omitempty" json:"burst,omitempty"`
+	// Interval is the sampling window and how often skipped lines are summarized (Go duration, default "1m").
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"`
+}
+
+// Enabled reports whether any lines are dropped.
+func (l LogSamplingConfig) Enabled() bool { return l.Rate > 1 }
+
+// BurstSize returns how many lines per site and interval are kept before sampling starts.
+func (l LogSamplingConfig) BurstSize() int {
+	if l.Burst <= 0 {
+		return DefaultLogSamplingBurst
+	}
+	return l.Burst
+}
+
+// IntervalDuration returns the sampling window.
+func (l LogSamplingConfig) IntervalDuration() time.Duration {
+	return positiveDurationOr(l.Interval, DefaultLogSamplingInterval)
+}
+
+func (cfg *Config) validateLogSampling() []error {
+	var errs []error
+	if cfg.LogSampling.Rate < 0 {
+		errs = append(errs, fmt.Errorf("log-sampling: rate must not be negative, got %d", cfg.LogSampling.Rate))
+	}
+	if cfg.LogSampling.Burst < 0 {
+		errs = append(errs, fmt.Errorf("log-sampling: burst must not be negative, got %d", cfg.LogSampling.Burst))
+	}
+	if raw := strings.TrimSpace(cfg.LogSampling.Interval); raw != "" {
+		if d, err := time.ParseDuration(raw); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("log-sampling: invalid interval %q: must be a positive duration", cfg.LogSampling.Interval))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Log outputs accepted by logging.output.
+const (
+	LogOutputStdout   = "stdout"
+	LogOutputFile     = "file"
+	LogOutputSyslog   = "syslog"
+	LogOutputJournald = "journald"
+)
+
+// Defaults applied to syslog settings when unset.
+const (
+	DefaultSyslogFacility = "daemon"
+	DefaultSyslogTag      = "cli-proxy-api"
+)
+
+// syslogFacilities maps facility names to their RFC 5424 codes.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// LoggingConfig selects where application logs are written.
+type LoggingConfig struct {
+	// Output is "stdout", "file", "syslog" or "journald" (Linux only). Empty follows
+	// logging-to-file.
+	Output string `yaml:"output,omitempty" json:"output,omitempty"`
+	// Syslog configures the "syslog" output; its tag also names the journald identifier.
+	Syslog SyslogConfig `yaml:"syslog,omitempty" json:"syslog,omitempty"`
+}
+
+// SyslogConfig sends RFC 5424 messages to a local or remote syslog daemon.
+type SyslogConfig struct {
+	// Network is "unix", "unixgram" or "udp". Empty uses the local socket when Address is
+	// empty or a path, and UDP otherwise.
+	Network string `yaml:"network,omitempty" json:"network,omitempty"`
+	// Address is a socket path or host:port. Empty tries /dev/log and the usual alternatives.
+	Address string `yaml:"address,omitempty" json:"address,omitempty"`
+	// Facility is the syslog facility name, such as "daemon" or "local0" (default "daemon").
+	Facility string `yaml:"facility,omitempty" json:"facility,omitempty"`
+	// Tag is the APP-NAME of every message and the journald SYSLOG_IDENTIFIER
+	// (default "cli-proxy-api").
+	Tag string `yaml:"tag,omitempty" json:"tag,omitempty"`
+}
+
+// LogOutput returns the effective log output: logging.output when set, else "file" or
+// "stdout" according to logging-to-file.
+func (cfg *Config) LogOutput() string {
+	if cfg == nil {
+		return LogOutputStdout
+	}
+	if output := strings.ToLower(strings.TrimSpace(cfg.Logging.Output)); output != "" {
+		return output
+	}
+	if cfg.LoggingToFile {
+		return LogOutputFile
+	}
+	return LogOutputStdout
+}
+
+// NetworkOrDefault returns the network used to reach the syslog daemon.
+func (s SyslogConfig) NetworkOrDefault() string {
+	if network := strings.ToLower(strings.TrimSpace(s.Network)); network != "" {
+		return network
+	}
+	address := strings.TrimSpace(s.Address)
+	if address == "" || strings.HasPrefix(address, "/") {
+		return "unix"
+	}
+	return "udp"
+}
+
+// FacilityCode returns the numeric syslog facility.
+func (s SyslogConfig) FacilityCode() int {
+	if code, ok := syslogFacilities[strings.ToLower(strings.TrimSpace(s.Facility))]; ok {
+		return code
+	}
+	return syslogFacilities[DefaultSyslogFacility]
+}
+
+// TagOrDefault returns the APP-NAME sent with every message.
+func (s SyslogConfig) TagOrDefault() string {
+	if tag := strings.TrimSpace(s.Tag); tag != "" {
+		return tag
+	}
+	return DefaultSyslogTag
+}
+
+func (cfg *Config) validateLogging() []error {
+	var errs []error
+	switch output := cfg.LogOutput(); output {
+	case LogOutputStdout, LogOutputFile, LogOutputSyslog, LogOutputJournald:
+	default:
+		errs = append(errs, fmt.Errorf("logging: unknown output %q: must be stdout, file, syslog or journald", cfg.Logging.Output))
+	}
+	syslog := cfg.Logging.Syslog
+	switch syslog.NetworkOrDefault() {
+	case "unix", "unixgram":
+	case "udp", "udp4", "udp6":
+		if _, _, err := net.SplitHostPort(strings.TrimSpace(syslog.Address)); err != nil {
+			errs = append(errs, fmt.Errorf("logging: syslog address %q must be host:port for %s", syslog.Address, syslog.NetworkOrDefault()))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("logging: unknown syslog network %q: must be unix, unixgram or udp", syslog.Network))
+	}
+	if facility := strings.TrimSpace(syslog.Facility); facility != "" {
+		if _, ok := syslogFacilities[strings.ToLower(facility)]; !ok {
+			errs = append(errs, fmt.Errorf("logging: unknown syslog facility %q", syslog.Facility))
+		}
+	}
+	return errs
+}
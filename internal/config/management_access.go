@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ManagementConfig restricts which sources may reach the management API.
+type ManagementConfig struct {
+	// AllowedIPs lists the addresses and CIDR ranges allowed to call management endpoints;
+	// "localhost" stands for the IPv4 and IPv6 loopback ranges. Empty allows every source,
+	// leaving the management key as the only check.
+	AllowedIPs []string `yaml:"allowed-ips,omitempty" json:"allowed-ips,omitempty"`
+
+	// DeniedIPs lists addresses and CIDR ranges refused even when AllowedIPs covers them.
+	DeniedIPs []string `yaml:"denied-ips,omitempty" json:"denied-ips,omitempty"`
+
+	// TrustedProxies lists the proxies whose X-Forwarded-For header is believed when
+	// matching AllowedIPs. Without it the header is ignored and the peer address is used.
+	TrustedProxies []string `yaml:"trusted-proxies,omitempty" json:"trusted-proxies,omitempty"`
+
+	// Listen is a host:port for a listener of its own serving the management API, the control
+	// panel and /metrics. The API listener then no longer serves them. Empty keeps everything
+	// on the API listener.
+	Listen string `yaml:"listen,omitempty" json:"listen,omitempty"`
+}
+
+// APIAccessConfig restricts which sources may call the client API routes. Requests from a
+// source it refuses get 403 before their API key is looked at.
+type APIAccessConfig struct {
+	// AllowedIPs lists the addresses and CIDR ranges allowed to call the API; empty allows
+	// every source not denied.
+	AllowedIPs []string `yaml:"allowed-ips,omitempty" json:"allowed-ips,omitempty"`
+
+	// DeniedIPs lists addresses and CIDR ranges refused even when AllowedIPs covers them.
+	DeniedIPs []string `yaml:"denied-ips,omitempty" json:"denied-ips,omitempty"`
+
+	// TrustedProxies lists the proxies whose X-Forwarded-For header gives the source for
+	// these lists and for the api-key-settings ones.
+	TrustedProxies []string `yaml:"trusted-proxies,omitempty" json:"trusted-proxies,omitempty"`
+}
+
+// ParseIPPrefixes parses addresses and CIDR ranges. A bare address matches only itself and
+// "localhost" expands to 127.0.0.0/8 and ::1. IPv4-mapped IPv6 input is stored as IPv4.
+func ParseIPPrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+			continue
+		case strings.EqualFold(entry, "localhost"):
+			prefixes = append(prefixes, netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128"))
+		case strings.Contains(entry, "/"):
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+			}
+			if addr := prefix.Addr(); addr.Is4In6() {
+				bits := prefix.Bits() - 96
+				if bits < 0 {
+					return nil, fmt.Errorf("invalid CIDR %q: IPv4-mapped range wider than IPv4", entry)
+				}
+				prefix = netip.PrefixFrom(addr.Unmap(), bits)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+		default:
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q: %w", entry, err)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes, nil
+}
+
+func (cfg *Config) validateManagement() []error {
+	var errs []error
+	if _, err := ParseIPPrefixes(cfg.Management.AllowedIPs); err != nil {
+		errs = append(errs, fmt.Errorf("management: allowed-ips: %w", err))
+	}
+	if _, err := ParseIPPrefixes(cfg.Management.DeniedIPs); err != nil {
+		errs = append(errs, fmt.Errorf("management: denied-ips: %w", err))
+	}
+	if _, err := ParseIPPrefixes(cfg.Management.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("management: trusted-proxies: %w", err))
+	}
+	if listen := strings.TrimSpace(cfg.Management.Listen); listen != "" {
+		host, rawPort, err := net.SplitHostPort(listen)
+		port, errPort := strconv.Atoi(rawPort)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("management: listen %q must be host:port: %w", listen, err))
+		case errPort != nil || port <= 0 || port > 65535:
+			errs = append(errs, fmt.Errorf("management: listen %q needs a port between 1 and 65535", listen))
+		case port == cfg.Port && (host == "" || cfg.Host == "" || host == cfg.Host):
+			errs = append(errs, fmt.Errorf("management: listen %q must not share the API listener's port %d", listen, cfg.Port))
+		}
+	}
+	return errs
+}
+
+func (cfg *Config) validateAPIAccess() []error {
+	var errs []error
+	for _, list := range []struct {
+		name    string
+		entries []string
+	}{
+		{"allowed-ips", cfg.APIAccess.AllowedIPs},
+		{"denied-ips", cfg.APIAccess.DeniedIPs},
+		{"trusted-proxies", cfg.APIAccess.TrustedProxies},
+	} {
+		if _, err := ParseIPPrefixes(list.entries); err != nil {
+			errs = append(errs, fmt.Errorf("api-access: %s: %w", list.name, err))
+		}
+	}
+	return errs
+}
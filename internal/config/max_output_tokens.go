@@ -0,0 +1,49 @@
+package config
+
+import "fmt"
+
+// MaxOutputTokensConfig caps and defaults completion lengths per model.
+type MaxOutputTokensConfig struct {
+	// ClampHeader adds an X-CPA-Max-Tokens-Clamped response header when a request is clamped.
+	ClampHeader bool `yaml:"clamp-header,omitempty" json:"clamp-header,omitempty"`
+
+	// Rules are evaluated in order; the first rule matching the model applies.
+	Rules []MaxOutputTokensRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// MaxOutputTokensRule limits the output tokens requested for the matching models.
+type MaxOutputTokensRule struct {
+	// Models lists model entries with name pattern and protocol constraint.
+	Models []PayloadModelRule `yaml:"models" json:"models"`
+
+	// Limit is the largest output token count forwarded upstream; larger requests are clamped.
+	Limit int64 `yaml:"limit" json:"limit"`
+
+	// Default fills in the output token count for providers that require one (Anthropic)
+	// when the client omits it. Zero uses Limit.
+	Default int64 `yaml:"default,omitempty" json:"default,omitempty"`
+}
+
+// DefaultTokens returns the value used when a client omits the output token count.
+func (r MaxOutputTokensRule) DefaultTokens() int64 {
+	if r.Default > 0 {
+		return r.Default
+	}
+	return r.Limit
+}
+
+func (cfg *Config) validateMaxOutputTokens() []error {
+	var errs []error
+	for i, rule := range cfg.MaxOutputTokens.Rules {
+		if len(rule.Models) == 0 {
+			errs = append(errs, fmt.Errorf("max-output-tokens.rules[%d]: models is required", i))
+		}
+		if rule.Limit <= 0 {
+			errs = append(errs, fmt.Errorf("max-output-tokens.rules[%d]: limit must be positive", i))
+		}
+		if rule.Default < 0 || (rule.Limit > 0 && rule.Default > rule.Limit) {
+			errs = append(errs, fmt.Errorf("max-output-tokens.rules[%d]: default must be between 1 and limit", i))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Defaults applied to Pushgateway settings when unset.
+const (
+	DefaultPushgatewayJob      = "cli-proxy-api"
+	DefaultPushgatewayInterval = 30 * time.Second
+)
+
+// MetricsConfig configures how usage metrics are exported to Prometheus.
+type MetricsConfig struct {
+	// Endpoint serves the metrics for scraping at GET /metrics on the API server.
+	Endpoint bool `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	// Token, when set, must be sent as a bearer token to read /metrics.
+	Token string `yaml:"token,omitempty" json:"token,omitempty"`
+
+	// Pushgateway pushes the metrics to a Prometheus Pushgateway, for instances that cannot
+	// be scraped.
+	Pushgateway PushgatewayConfig `yaml:"pushgateway,omitempty" json:"pushgateway,omitempty"`
+}
+
+// PushgatewayConfig periodically pushes usage metrics to a Pushgateway group.
+type PushgatewayConfig struct {
+	// URL is the Pushgateway base URL, e.g. "http://pushgateway:9091". Empty disables pushing.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+	// Job is the job grouping label (default "cli-proxy-api").
+	Job string `yaml:"job,omitempty" json:"job,omitempty"`
+	// Instance is the instance grouping label (default the host name).
+	Instance string `yaml:"instance,omitempty" json:"instance,omitempty"`
+	// Interval is a Go duration between pushes (default "30s").
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"`
+	// Headers are sent with every push, typically for authentication.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// DeleteOnShutdown deletes the group after the final push on a clean shutdown, so the
+	// series of a stopped instance do not linger.
+	DeleteOnShutdown bool `yaml:"delete-on-shutdown,omitempty" json:"delete-on-shutdown,omitempty"`
+}
+
+// Enabled reports whether metrics are pushed.
+func (p PushgatewayConfig) Enabled() bool {
+	return strings.TrimSpace(p.URL) != ""
+}
+
+// JobOrDefault returns the job grouping label.
+func (p PushgatewayConfig) JobOrDefault() string {
+	if job := strings.TrimSpace(p.Job); job != "" {
+		return job
+	}
+	return DefaultPushgatewayJob
+}
+
+// InstanceOrDefault returns the instance grouping label, falling back to the host name.
+func (p PushgatewayConfig) InstanceOrDefault() string {
+	if instance := strings.TrimSpace(p.Instance); instance != "" {
+		return instance
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "unknown"
+}
+
+// IntervalDuration returns the interval between pushes.
+func (p PushgatewayConfig) IntervalDuration() time.Duration {
+	return positiveDurationOr(p.Interval, DefaultPushgatewayInterval)
+}
+
+func (cfg *Config) validateMetrics() []error {
+	p := cfg.Metrics.Pushgateway
+	if !p.Enabled() {
+		return nil
+	}
+	var errs []error
+	if u, err := url.Parse(strings.TrimSpace(p.URL)); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("metrics.pushgateway: invalid url %q: must be an http or https URL", p.URL))
+	}
+	if raw := strings.TrimSpace(p.Interval); raw != "" {
+		if d, err := time.ParseDuration(raw); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("metrics.pushgateway: invalid interval %q: must be a positive duration", p.Interval))
+		}
+	}
+	return errs
+}
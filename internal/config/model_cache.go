@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultModelCacheTTL is how long model lists fetched from upstream providers are reused
+// when model-cache-ttl is unset.
+const DefaultModelCacheTTL = 10 * time.Minute
+
+// EffectiveModelCacheTTL returns the configured upstream model list cache TTL. An empty or
+// invalid value yields DefaultModelCacheTTL; "0" disables reuse.
+func (cfg *Config) EffectiveModelCacheTTL() time.Duration {
+	if cfg == nil {
+		return DefaultModelCacheTTL
+	}
+	ttl, err := parseModelCacheTTL(cfg.ModelCacheTTL)
+	if err != nil {
+		return DefaultModelCacheTTL
+	}
+	return ttl
+}
+
+func parseModelCacheTTL(raw string) (time.Duration, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return DefaultModelCacheTTL, nil
+	}
+	ttl, err := time.ParseDuration(trimmed)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("model-cache-ttl: invalid duration %q", raw)
+	}
+	return ttl, nil
+}
+
+func (cfg *Config) validateModelCache() []error {
+	if _, err := parseModelCacheTTL(cfg.ModelCacheTTL); err != nil {
+		return []error{err}
+	}
+	return nil
+}
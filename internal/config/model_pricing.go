@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ModelPrice is what a model's tokens cost, in US dollars per million tokens. Usage statistics
+// use it to estimate spend.
+type ModelPrice struct {
+	// Model is a model name or a path.Match pattern such as "gpt-5*".
+	Model string `yaml:"model" json:"model"`
+	// Input prices the prompt tokens.
+	Input float64 `yaml:"input" json:"input"`
+	// Output prices the completion tokens, reasoning included.
+	Output float64 `yaml:"output" json:"output"`
+	// CachedInput prices the prompt tokens served from the provider's prompt cache. Unset
+	// charges them at Input.
+	CachedInput *float64 `yaml:"cached-input,omitempty" json:"cached-input,omitempty"`
+}
+
+// Matches reports whether the price applies to model.
+func (p ModelPrice) Matches(model string) bool {
+	ok, _ := path.Match(strings.TrimSpace(p.Model), model)
+	return ok
+}
+
+// CachedInputPrice returns the price of a million cached prompt tokens.
+func (p ModelPrice) CachedInputPrice() float64 {
+	if p.CachedInput == nil {
+		return p.Input
+	}
+	return *p.CachedInput
+}
+
+// PriceFor returns the first model-pricing entry matching model.
+func (cfg *Config) PriceFor(model string) (ModelPrice, bool) {
+	if cfg == nil {
+		return ModelPrice{}, false
+	}
+	for _, price := range cfg.ModelPricing {
+		if price.Matches(model) {
+			return price, true
+		}
+	}
+	return ModelPrice{}, false
+}
+
+func (cfg *Config) validateModelPricing() []error {
+	var errs []error
+	for i, price := range cfg.ModelPricing {
+		pattern := strings.TrimSpace(price.Model)
+		if pattern == "" {
+			errs = append(errs, fmt.Errorf("model-pricing[%d]: model is required", i))
+		} else if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("model-pricing[%d]: model pattern %q: %w", i, pattern, err))
+		}
+		if price.Input < 0 || price.Output < 0 || (price.CachedInput != nil && *price.CachedInput < 0) {
+			errs = append(errs, fmt.Errorf("model-pricing[%d]: prices must not be negative", i))
+		}
+	}
+	return errs
+}
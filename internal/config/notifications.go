@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Notification event types that can be switched on or off under notifications.events.
+const (
+	NotifyAccountDisabled = "account-disabled"
+	NotifyRefreshFailed   = "refresh-failed"
+	NotifyUsageAlert      = "usage-alert"
+)
+
+// Webhook payload formats.
+const (
+	NotifyFormatSlack   = "slack"
+	NotifyFormatDiscord = "discord"
+)
+
+// DefaultNotificationRateLimit is how often the same event for the same account may be sent.
+const DefaultNotificationRateLimit = 15 * time.Minute
+
+// NotificationsConfig posts operational events to chat webhooks.
+type NotificationsConfig struct {
+	// Webhooks receive every enabled event.
+	Webhooks []NotificationWebhook `yaml:"webhooks,omitempty" json:"webhooks,omitempty"`
+	// Events switches individual event types off (or back on); unlisted types are sent.
+	Events map[string]bool `yaml:"events,omitempty" json:"events,omitempty"`
+	// RateLimit is the minimum gap between two notifications of one event type for one account
+	// (Go duration, default "15m"). Events in between are counted and mentioned in the next one.
+	RateLimit string `yaml:"rate-limit,omitempty" json:"rate-limit,omitempty"`
+}
+
+// NotificationWebhook is one incoming-webhook URL.
+type NotificationWebhook struct {
+	URL string `yaml:"url" json:"url"`
+	// Format is "slack" or "discord"; empty picks discord for discord.com URLs and slack otherwise.
+	Format string `yaml:"format,omitempty" json:"format,omitempty"`
+}
+
+// EventEnabled reports whether notifications of eventType are sent.
+func (n NotificationsConfig) EventEnabled(eventType string) bool {
+	if enabled, ok := n.Events[eventType]; ok {
+		return enabled
+	}
+	return true
+}
+
+// RateLimitDuration returns the minimum gap between repeated notifications.
+func (n NotificationsConfig) RateLimitDuration() time.Duration {
+	return positiveDurationOr(n.RateLimit, DefaultNotificationRateLimit)
+}
+
+// PayloadFormat returns the webhook's payload format.
+func (w NotificationWebhook) PayloadFormat() string {
+	if format := strings.ToLower(strings.TrimSpace(w.Format)); format != "" {
+		return format
+	}
+	if u, err := url.Parse(strings.TrimSpace(w.URL)); err == nil {
+		host := strings.ToLower(u.Hostname())
+		if host == "discord.com" || host == "discordapp.com" || strings.HasSuffix(host, ".discord.com") {
+			return NotifyFormatDiscord
+		}
+	}
+	return NotifyFormatSlack
+}
+
+func (cfg *Config) validateNotifications() []error {
+	var errs []error
+	for i, hook := range cfg.Notifications.Webhooks {
+		// Webhook URLs embed their secret token, so they are never quoted in errors.
+		if u, err := url.Parse(strings.TrimSpace(hook.URL)); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("notifications.webhooks[%d]: url must be an http or https URL", i))
+		}
+		if format := hook.PayloadFormat(); format != NotifyFormatSlack && format != NotifyFormatDiscord {
+			errs = append(errs, fmt.Errorf("notifications.webhooks[%d]: unknown format %q, want slack or discord", i, hook.Format))
+		}
+	}
+	for eventType := range cfg.Notifications.Events {
+		switch eventType {
+		case NotifyAccountDisabled, NotifyRefreshFailed, NotifyUsageAlert:
+		default:
+			errs = append(errs, fmt.Errorf("notifications.events: unknown event %q", eventType))
+		}
+	}
+	if raw := strings.TrimSpace(cfg.Notifications.RateLimit); raw != "" {
+		if d, err := time.ParseDuration(raw); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("notifications: invalid rate-limit %q: must be a positive duration", cfg.Notifications.RateLimit))
+		}
+	}
+	return errs
+}
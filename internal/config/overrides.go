@@ -0,0 +1,253 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Value sources reported for overridable settings, in increasing precedence.
+const (
+	ValueSourceFile = "file"
+	ValueSourceEnv  = "env"
+	ValueSourceFlag = "flag"
+)
+
+// EnvOverridePrefix prefixes environment variables that override config fields.
+const EnvOverridePrefix = "CLIPROXY_"
+
+// OverrideField describes a config field that can be pinned from the command line or environment.
+type OverrideField struct {
+	// Key is the flag name and the YAML key it overrides.
+	Key string
+	// Env is the environment variable consulted when the flag is not set. It is derived
+	// from Key as CLIPROXY_<KEY> with dashes replaced by underscores.
+	Env string
+	// Usage is the flag help text.
+	Usage string
+
+	apply   func(cfg *Config, raw string) error
+	copy    func(dst, src *Config)
+	current func(cfg *Config) string
+}
+
+// Override is a resolved value pinned for a config field.
+type Override struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+var overrideFields = []OverrideField{
+	{
+		Key:   "host",
+		Usage: "Override the listen host",
+		apply: func(cfg *Config, raw string) error {
+			cfg.Host = strings.TrimSpace(raw)
+			return nil
+		},
+		copy:    func(dst, src *Config) { dst.Host = src.Host },
+		current: func(cfg *Config) string { return cfg.Host },
+	},
+	{
+		Key:   "port",
+		Usage: "Override the listen port",
+		apply: func(cfg *Config, raw string) error {
+			port, err := strconv.Atoi(strings.TrimSpace(raw))
+			if err != nil || port <= 0 || port > 65535 {
+				return fmt.Errorf("invalid port %q", raw)
+			}
+			cfg.Port = port
+			return nil
+		},
+		copy:    func(dst, src *Config) { dst.Port = src.Port },
+		current: func(cfg *Config) string { return strconv.Itoa(cfg.Port) },
+	},
+	{
+		Key:   "auth-dir",
+		Usage: "Override the authentication directory",
+		apply: func(cfg *Config, raw string) error {
+			cfg.AuthDir = strings.TrimSpace(raw)
+			cfg.AuthDirs = AuthDirList{cfg.AuthDir}
+			return nil
+		},
+		copy: func(dst, src *Config) {
+			dst.AuthDir = src.AuthDir
+			dst.AuthDirs = append(AuthDirList(nil), src.AuthDirs...)
+		},
+		current: func(cfg *Config) string { return cfg.AuthDir },
+	},
+	{
+		Key:   "api-key",
+		Usage: "Override client API keys (comma-separated)",
+		apply: func(cfg *Config, raw string) error {
+			var keys []string
+			for _, part := range strings.Split(raw, ",") {
+				if key := strings.TrimSpace(part); key != "" {
+					keys = append(keys, key)
+				}
+			}
+			cfg.APIKeys = keys
+			return nil
+		},
+		copy: func(dst, src *Config) { dst.APIKeys = append([]string(nil), src.APIKeys...) },
+		current: func(cfg *Config) string {
+			return fmt.Sprintf("%d key(s)", len(cfg.APIKeys))
+		},
+	},
+	{
+		Key:   "proxy-url",
+		Usage: "Override the upstream proxy URL",
+		apply: func(cfg *Config, raw string) error {
+			cfg.ProxyURL = strings.TrimSpace(raw)
+			return nil
+		},
+		copy:    func(dst, src *Config) { dst.ProxyURL = src.ProxyURL },
+		current: func(cfg *Config) string { return redactProxyURL(cfg.ProxyURL) },
+	},
+	{
+		Key:   "debug",
+		Usage: "Override debug logging (true/false)",
+		apply: func(cfg *Config, raw string) error {
+			enabled, err := strconv.ParseBool(strings.TrimSpace(raw))
+			if err != nil {
+				return fmt.Errorf("invalid debug value %q", raw)
+			}
+			cfg.Debug = enabled
+			return nil
+		},
+		copy:    func(dst, src *Config) { dst.Debug = src.Debug },
+		current: func(cfg *Config) string { return strconv.FormatBool(cfg.Debug) },
+	},
+	{
+		Key:   "usage-persist-file",
+		Usage: "Override the usage statistics persistence file",
+		apply: func(cfg *Config, raw string) error {
+			cfg.UsagePersistence.File = strings.TrimSpace(raw)
+			return nil
+		},
+		copy:    func(dst, src *Config) { dst.UsagePersistence.File = src.UsagePersistence.File },
+		current: func(cfg *Config) string { return cfg.UsagePersistence.File },
+	},
+}
+
+func init() {
+	for i := range overrideFields {
+		if overrideFields[i].Env == "" {
+			overrideFields[i].Env = EnvOverridePrefix + strings.ToUpper(strings.ReplaceAll(overrideFields[i].Key, "-", "_"))
+		}
+	}
+}
+
+var (
+	overridesMu     sync.RWMutex
+	activeOverrides []Override
+)
+
+// OverrideFields returns the config fields that accept flag and environment overrides.
+func OverrideFields() []OverrideField {
+	return append([]OverrideField(nil), overrideFields...)
+}
+
+// ResolveOverrides merges flag and environment values using the precedence flags > env.
+// flagValues holds only flags explicitly set on the command line, keyed by flag name.
+func ResolveOverrides(flagValues map[string]string, lookupEnv func(string) (string, bool)) ([]Override, error) {
+	var out []Override
+	probe := &Config{}
+	for _, field := range overrideFields {
+		override := Override{Key: field.Key}
+		if value, ok := flagValues[field.Key]; ok {
+			override.Value, override.Source = value, ValueSourceFlag
+		} else if lookupEnv != nil {
+			if value, okEnv := lookupEnv(field.Env); okEnv && strings.TrimSpace(value) != "" {
+				override.Value, override.Source = value, ValueSourceEnv
+			}
+		}
+		if override.Source == "" {
+			continue
+		}
+		if err := field.apply(probe, override.Value); err != nil {
+			return nil, fmt.Errorf("%s override from %s: %w", field.Key, override.Source, err)
+		}
+		out = append(out, override)
+	}
+	return out, nil
+}
+
+// SetOverrides pins the given overrides so they are applied on every subsequent config load,
+// including hot reloads. Passing nil clears all overrides.
+func SetOverrides(overrides []Override) {
+	overridesMu.Lock()
+	activeOverrides = append([]Override(nil), overrides...)
+	overridesMu.Unlock()
+}
+
+// applyOverrides applies pinned overrides to cfg, remembering the file values so that
+// persisting the config does not write pinned values back to disk.
+func (cfg *Config) applyOverrides() {
+	overridesMu.RLock()
+	overrides := activeOverrides
+	overridesMu.RUnlock()
+	if cfg == nil || len(overrides) == 0 {
+		return
+	}
+	base := *cfg
+	cfg.fileValues = &base
+	cfg.valueSources = make(map[string]string, len(overrides))
+	for _, override := range overrides {
+		field, ok := lookupOverrideField(override.Key)
+		if !ok {
+			continue
+		}
+		// Values were validated by ResolveOverrides.
+		_ = field.apply(cfg, override.Value)
+		cfg.valueSources[override.Key] = override.Source
+	}
+}
+
+// restoreFileValues resets pinned fields on dst to the values read from the config file.
+func (cfg *Config) restoreFileValues(dst *Config) {
+	if cfg == nil || dst == nil || cfg.fileValues == nil {
+		return
+	}
+	for key := range cfg.valueSources {
+		if field, ok := lookupOverrideField(key); ok {
+			field.copy(dst, cfg.fileValues)
+		}
+	}
+}
+
+// ValueSources describes the effective value and its source for every overridable field.
+func (cfg *Config) ValueSources() []string {
+	if cfg == nil {
+		return nil
+	}
+	out := make([]string, 0, len(overrideFields))
+	for _, field := range overrideFields {
+		source := ValueSourceFile
+		if s, ok := cfg.valueSources[field.Key]; ok {
+			source = s
+		}
+		out = append(out, fmt.Sprintf("%s=%s (source: %s)", field.Key, field.current(cfg), source))
+	}
+	return out
+}
+
+func lookupOverrideField(key string) (OverrideField, bool) {
+	for _, field := range overrideFields {
+		if field.Key == key {
+			return field, true
+		}
+	}
+	return OverrideField{}, false
+}
+
+func redactProxyURL(raw string) string {
+	if at := strings.LastIndex(raw, "@"); at >= 0 {
+		if scheme := strings.Index(raw, "://"); scheme >= 0 && scheme < at {
+			return raw[:scheme+3] + "***" + raw[at:]
+		}
+	}
+	return raw
+}
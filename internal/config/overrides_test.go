@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveOverrides_Precedence(t *testing.T) {
+	env := map[string]string{
+		"CLIPROXY_PORT":    "9000",
+		"CLIPROXY_API_KEY": "env-key",
+	}
+	lookup := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+	overrides, err := ResolveOverrides(map[string]string{"port": "9100"}, lookup)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := make(map[string]Override, len(overrides))
+	for _, o := range overrides {
+		got[o.Key] = o
+	}
+	if o := got["port"]; o.Value != "9100" || o.Source != ValueSourceFlag {
+		t.Fatalf("expected flag to win for port, got %+v", o)
+	}
+	if o := got["api-key"]; o.Value != "env-key" || o.Source != ValueSourceEnv {
+		t.Fatalf("expected env value for api-key, got %+v", o)
+	}
+	if _, ok := got["host"]; ok {
+		t.Fatalf("host should not be overridden when neither flag nor env is set")
+	}
+}
+
+func TestResolveOverrides_RejectsInvalidValues(t *testing.T) {
+	if _, err := ResolveOverrides(map[string]string{"port": "not-a-port"}, nil); err == nil {
+		t.Fatalf("expected invalid port to be rejected")
+	}
+	if _, err := ResolveOverrides(map[string]string{"debug": "maybe"}, nil); err == nil {
+		t.Fatalf("expected invalid debug value to be rejected")
+	}
+}
+
+func TestLoadConfig_OverridesSurviveReloadAndPersist(t *testing.T) {
+	SetOverrides([]Override{
+		{Key: "port", Value: "9100", Source: ValueSourceFlag},
+		{Key: "api-key", Value: "pinned-a, pinned-b", Source: ValueSourceEnv},
+	})
+	t.Cleanup(func() { SetOverrides(nil) })
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("port: 8317\napi-keys:\n  - file-key\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("load config: %v", err)
+		}
+		if cfg.Port != 9100 {
+			t.Fatalf("load %d: expected pinned port 9100, got %d", i, cfg.Port)
+		}
+		if len(cfg.APIKeys) != 2 || cfg.APIKeys[0] != "pinned-a" || cfg.APIKeys[1] != "pinned-b" {
+			t.Fatalf("load %d: expected pinned api keys, got %v", i, cfg.APIKeys)
+		}
+		if err = os.WriteFile(path, []byte("port: 8400\napi-keys:\n  - file-key\n"), 0o644); err != nil {
+			t.Fatalf("rewrite config: %v", err)
+		}
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.Debug = true
+	if err = SaveConfigPreserveComments(path, cfg); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	saved := string(data)
+	if strings.Contains(saved, "9100") || strings.Contains(saved, "pinned-a") {
+		t.Fatalf("pinned values must not be persisted:\n%s", saved)
+	}
+	if !strings.Contains(saved, "port: 8400") || !strings.Contains(saved, "debug: true") {
+		t.Fatalf("expected file values and unpinned edits to be persisted:\n%s", saved)
+	}
+
+	sources := strings.Join(cfg.ValueSources(), "\n")
+	if !strings.Contains(sources, "port=9100 (source: flag)") || !strings.Contains(sources, "host= (source: file)") {
+		t.Fatalf("unexpected value sources:\n%s", sources)
+	}
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// ReasoningOutputExpose forwards upstream reasoning to clients: reasoning_content on
+	// OpenAI chat responses, thinking blocks for Claude clients and thought parts for Gemini.
+	ReasoningOutputExpose = "expose"
+	// ReasoningOutputStrip removes upstream reasoning before responses reach clients.
+	ReasoningOutputStrip = "strip"
+)
+
+// NormalizedReasoningOutput returns the configured reasoning-output mode, defaulting to expose.
+func (cfg *SDKConfig) NormalizedReasoningOutput() string {
+	if cfg == nil {
+		return ReasoningOutputExpose
+	}
+	if mode := strings.ToLower(strings.TrimSpace(cfg.ReasoningOutput)); mode == ReasoningOutputStrip {
+		return mode
+	}
+	return ReasoningOutputExpose
+}
+
+func (cfg *Config) validateReasoningOutput() []error {
+	switch strings.ToLower(strings.TrimSpace(cfg.ReasoningOutput)) {
+	case "", ReasoningOutputExpose, ReasoningOutputStrip:
+		return nil
+	}
+	return []error{fmt.Errorf("reasoning-output: unknown mode %q (want %q or %q)", cfg.ReasoningOutput, ReasoningOutputExpose, ReasoningOutputStrip)}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Defaults applied to response-cache settings when unset.
+const (
+	DefaultResponseCacheTTL           = 10 * time.Minute
+	DefaultResponseCacheMaxEntries    = 1000
+	DefaultResponseCacheMaxImageBytes = 64 << 10
+)
+
+// ResponseCacheConfig caches upstream responses to identical deterministic non-streaming
+// requests in memory.
+type ResponseCacheConfig struct {
+	// Enabled turns the cache on. Only requests with temperature 0, no tools, a single
+	// choice and no inline image above MaxImageBytes are cached.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// TTL is how long a response is served from the cache (Go duration, default "10m").
+	TTL string `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+	// MaxEntries bounds the number of cached responses (default 1000); the least recently
+	// used entry is evicted first.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+	// MaxBytes optionally bounds the total size of cached response bodies (0 is unbounded).
+	MaxBytes int64 `yaml:"max-bytes,omitempty" json:"max-bytes,omitempty"`
+	// MaxImageBytes is the largest inline image, in encoded bytes, a cached request may carry
+	// (default 65536).
+	MaxImageBytes int `yaml:"max-image-bytes,omitempty" json:"max-image-bytes,omitempty"`
+}
+
+// TTLDuration returns how long entries stay valid.
+func (c ResponseCacheConfig) TTLDuration() time.Duration {
+	return positiveDurationOr(c.TTL, DefaultResponseCacheTTL)
+}
+
+// Entries returns the entry limit.
+func (c ResponseCacheConfig) Entries() int {
+	if c.MaxEntries <= 0 {
+		return DefaultResponseCacheMaxEntries
+	}
+	return c.MaxEntries
+}
+
+// ImageBytes returns the inline image size above which requests bypass the cache.
+func (c ResponseCacheConfig) ImageBytes() int {
+	if c.MaxImageBytes <= 0 {
+		return DefaultResponseCacheMaxImageBytes
+	}
+	return c.MaxImageBytes
+}
+
+func (cfg *Config) validateResponseCache() []error {
+	var errs []error
+	rc := cfg.ResponseCache
+	if raw := strings.TrimSpace(rc.TTL); raw != "" {
+		if d, err := time.ParseDuration(raw); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("response-cache: invalid ttl %q: must be a positive duration", rc.TTL))
+		}
+	}
+	if rc.MaxEntries < 0 {
+		errs = append(errs, fmt.Errorf("response-cache: max-entries must not be negative"))
+	}
+	if rc.MaxBytes < 0 {
+		errs = append(errs, fmt.Errorf("response-cache: max-bytes must not be negative"))
+	}
+	if rc.MaxImageBytes < 0 {
+		errs = append(errs, fmt.Errorf("response-cache: max-image-bytes must not be negative"))
+	}
+	return errs
+}
@@ -4,6 +4,8 @@
 // debug settings, proxy configuration, and API keys.
 package config
 
+import "strings"
+
 // SDKConfig represents the application's configuration, loaded from a YAML file.
 type SDKConfig struct {
 	// ProxyURL is the URL of an optional proxy server to use for outbound requests.
@@ -29,6 +31,13 @@ type SDKConfig struct {
 	// NonStreamKeepAliveInterval controls how often blank lines are emitted for non-streaming responses.
 	// <= 0 disables keep-alives. Value is in seconds.
 	NonStreamKeepAliveInterval int `yaml:"nonstream-keepalive-interval,omitempty" json:"nonstream-keepalive-interval,omitempty"`
+
+	// ReasoningOutput controls whether upstream reasoning reaches clients: "expose" (default)
+	// or "strip".
+	ReasoningOutput string `yaml:"reasoning-output,omitempty" json:"reasoning-output,omitempty"`
+
+	// HTTPTransport tunes connection pooling for upstream requests.
+	HTTPTransport HTTPTransportConfig `yaml:"http-transport,omitempty" json:"http-transport,omitempty"`
 }
 
 // StreamingConfig holds server streaming behavior configuration.
@@ -41,6 +50,10 @@ type StreamingConfig struct {
 	// to allow auth rotation / transient recovery.
 	// <= 0 disables bootstrap retries. Default is 0.
 	BootstrapRetries int `yaml:"bootstrap-retries,omitempty" json:"bootstrap-retries,omitempty"`
+
+	// OmitMissingUsage drops the final stream_options.include_usage chunk when the upstream
+	// reported no usage. By default the proxy estimates the counts and marks them estimated.
+	OmitMissingUsage bool `yaml:"omit-missing-usage,omitempty" json:"omit-missing-usage,omitempty"`
 }
 
 // AccessConfig groups request authentication providers.
@@ -71,6 +84,10 @@ const (
 	// AccessProviderTypeConfigAPIKey is the built-in provider validating inline API keys.
 	AccessProviderTypeConfigAPIKey = "config-api-key"
 
+	// AccessProviderTypeJWT is the built-in provider validating JWT bearer tokens. It works
+	// alongside the top-level api-keys instead of replacing them.
+	AccessProviderTypeJWT = "jwt"
+
 	// DefaultAccessProviderName is applied when no provider name is supplied.
 	DefaultAccessProviderName = "config-inline"
 )
@@ -104,3 +121,28 @@ func MakeInlineAPIKeyProvider(keys []string) *AccessProvider {
 	}
 	return provider
 }
+
+// KeepsInlineAPIKeys reports whether the top-level api-keys stay in force next to providers:
+// true when none is configured or all are of a type that only adds another way to log in.
+func KeepsInlineAPIKeys(providers []AccessProvider) bool {
+	for i := range providers {
+		typ := strings.TrimSpace(providers[i].Type)
+		if typ != "" && !strings.EqualFold(typ, AccessProviderTypeJWT) {
+			return false
+		}
+	}
+	return true
+}
+
+// WithoutInlineAPIKeyProviders returns a copy of providers without the config-api-key entries,
+// whose keys are kept in the top-level api-keys instead.
+func WithoutInlineAPIKeyProviders(providers []AccessProvider) []AccessProvider {
+	var out []AccessProvider
+	for _, provider := range providers {
+		if strings.EqualFold(strings.TrimSpace(provider.Type), AccessProviderTypeConfigAPIKey) {
+			continue
+		}
+		out = append(out, provider)
+	}
+	return out
+}
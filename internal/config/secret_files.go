@@ -0,0 +1,250 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/secrets"
+)
+
+// SecretFilePrefix marks a secret value that is read from a file, such as a Docker or
+// Kubernetes secret mount: api-key: "file:/run/secrets/claude-key".
+const SecretFilePrefix = "file:"
+
+// DefaultSecretRefreshInterval is how often secrets read from files and secrets managers are
+// read again when secret-refresh-interval is unset.
+const DefaultSecretRefreshInterval = 5 * time.Minute
+
+// secretValue is one secret-bearing config value, named by its YAML path for error messages.
+type secretValue struct {
+	name  string
+	value *string
+}
+
+// secretValues returns every secret-bearing value in cfg.
+func (cfg *Config) secretValues() []secretValue {
+	var out []secretValue
+	add := func(name string, value *string) { out = append(out, secretValue{name: name, value: value}) }
+	for i := range cfg.APIKeys {
+		add(fmt.Sprintf("api-keys[%d]", i), &cfg.APIKeys[i])
+	}
+	for i := range cfg.APIKeySettings {
+		add(fmt.Sprintf("api-key-settings[%d].api-key", i), &cfg.APIKeySettings[i].APIKey)
+	}
+	add("remote-management.secret-key", &cfg.RemoteManagement.SecretKey)
+	for i := range cfg.GeminiKey {
+		add(fmt.Sprintf("gemini-api-key[%d].api-key", i), &cfg.GeminiKey[i].APIKey)
+	}
+	for i := range cfg.CodexKey {
+		add(fmt.Sprintf("codex-api-key[%d].api-key", i), &cfg.CodexKey[i].APIKey)
+	}
+	for i := range cfg.ClaudeKey {
+		add(fmt.Sprintf("claude-api-key[%d].api-key", i), &cfg.ClaudeKey[i].APIKey)
+	}
+	for i := range cfg.VertexCompatAPIKey {
+		add(fmt.Sprintf("vertex-api-key[%d].api-key", i), &cfg.VertexCompatAPIKey[i].APIKey)
+	}
+	for i := range cfg.OpenAICompatibility {
+		for j := range cfg.OpenAICompatibility[i].APIKeyEntries {
+			add(fmt.Sprintf("openai-compatibility[%d].api-key-entries[%d].api-key", i, j), &cfg.OpenAICompatibility[i].APIKeyEntries[j].APIKey)
+		}
+	}
+	add("ampcode.upstream-api-key", &cfg.AmpCode.UpstreamAPIKey)
+	for i := range cfg.AmpCode.UpstreamAPIKeys {
+		add(fmt.Sprintf("ampcode.upstream-api-keys[%d].upstream-api-key", i), &cfg.AmpCode.UpstreamAPIKeys[i].UpstreamAPIKey)
+	}
+	add("usage-redis.password", &cfg.UsageRedis.Password)
+	add("usage-webhook.url", &cfg.UsageWebhook.URL)
+	add("sentry.dsn", &cfg.Sentry.DSN)
+	add("metrics.token", &cfg.Metrics.Token)
+	for i := range cfg.Notifications.Webhooks {
+		add(fmt.Sprintf("notifications.webhooks[%d].url", i), &cfg.Notifications.Webhooks[i].URL)
+	}
+	return out
+}
+
+// resolveSecretReferences replaces every "file:" value with the trimmed contents of the file
+// and every secret:// value with the secret it names, and remembers where each came from, so
+// that the reference, not the secret, is written back.
+func (cfg *Config) resolveSecretReferences() error {
+	var errs []error
+	version := sha256.New()
+	for _, secret := range cfg.secretValues() {
+		raw := strings.TrimSpace(*secret.value)
+		var (
+			value string
+			err   error
+		)
+		if path, ok := strings.CutPrefix(raw, SecretFilePrefix); ok {
+			if value, err = ReadSecretFile(path); err == nil {
+				cfg.rememberSecretFile(value, path)
+			}
+		} else if secrets.IsReference(raw) {
+			if value, err = secrets.Resolve(context.Background(), raw); err == nil {
+				cfg.rememberSecretRef(value, raw)
+			}
+		} else {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", secret.name, err))
+			continue
+		}
+		*secret.value = value
+		_, _ = fmt.Fprintf(version, "%s\x00%s\x00", raw, value)
+	}
+	if cfg.secretFiles != nil || cfg.secretRefs != nil {
+		cfg.secretVersion = hex.EncodeToString(version.Sum(nil))
+	}
+	return errors.Join(errs...)
+}
+
+// SecretVersion changes whenever a secret read from a file or a secrets manager does; it is
+// empty when the config references none.
+func (cfg *Config) SecretVersion() string {
+	if cfg == nil {
+		return ""
+	}
+	return cfg.secretVersion
+}
+
+// ReadSecretFile returns the trimmed contents of the secret file at path.
+func ReadSecretFile(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "", errors.New("secret file path is empty")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read secret file %s: %w", path, err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return value, nil
+}
+
+func (cfg *Config) rememberSecretFile(value, path string) {
+	if cfg.secretFiles == nil {
+		cfg.secretFiles = make(map[string]string)
+	}
+	cfg.secretFiles[value] = strings.TrimSpace(path)
+}
+
+func (cfg *Config) rememberSecretRef(value, ref string) {
+	if cfg.secretRefs == nil {
+		cfg.secretRefs = make(map[string]string)
+	}
+	cfg.secretRefs[value] = ref
+}
+
+// secretFromFile reports whether value was loaded from a secret file.
+func (cfg *Config) secretFromFile(value string) (string, bool) {
+	path, ok := cfg.secretFiles[value]
+	return path, ok
+}
+
+// restoreSecretFiles puts the "file:", secret:// and ${NAME} references back in place of loaded secrets
+// on dst, a shallow copy of cfg. The slices holding secrets are copied first so cfg keeps its values.
+func (cfg *Config) restoreSecretFiles(dst *Config) {
+	if cfg == nil || dst == nil || (len(cfg.secretFiles) == 0 && len(cfg.secretRefs) == 0) {
+		return
+	}
+	dst.APIKeys = append([]string(nil), dst.APIKeys...)
+	dst.APIKeySettings = append([]APIKeySettings(nil), dst.APIKeySettings...)
+	dst.GeminiKey = append([]GeminiKey(nil), dst.GeminiKey...)
+	dst.CodexKey = append([]CodexKey(nil), dst.CodexKey...)
+	dst.ClaudeKey = append([]ClaudeKey(nil), dst.ClaudeKey...)
+	dst.VertexCompatAPIKey = append([]VertexCompatKey(nil), dst.VertexCompatAPIKey...)
+	dst.OpenAICompatibility = append([]OpenAICompatibility(nil), dst.OpenAICompatibility...)
+	for i := range dst.OpenAICompatibility {
+		dst.OpenAICompatibility[i].APIKeyEntries = append([]OpenAICompatibilityAPIKey(nil), dst.OpenAICompatibility[i].APIKeyEntries...)
+	}
+	dst.AmpCode.UpstreamAPIKeys = append([]AmpUpstreamAPIKeyEntry(nil), dst.AmpCode.UpstreamAPIKeys...)
+	dst.Notifications.Webhooks = append([]NotificationWebhook(nil), dst.Notifications.Webhooks...)
+	for _, secret := range dst.secretValues() {
+		if *secret.value == "" {
+			continue
+		}
+		if path, ok := cfg.secretFromFile(*secret.value); ok {
+			*secret.value = SecretFilePrefix + path
+		} else if ref, ok := cfg.secretRefs[*secret.value]; ok {
+			*secret.value = ref
+		}
+	}
+}
+
+// WithSecretReferences returns a copy of cfg for display in which every value loaded from a
+// secret file, a secrets manager or the environment shows its reference instead of the
+// secret.
+func (cfg *Config) WithSecretReferences() *Config {
+	if cfg == nil {
+		return nil
+	}
+	clone := *cfg
+	cfg.restoreSecretFiles(&clone)
+	return &clone
+}
+
+// EffectiveSecretRefreshInterval returns how often secrets are read again. An empty or
+// invalid value yields DefaultSecretRefreshInterval; "0" disables the refresh.
+func (cfg *Config) EffectiveSecretRefreshInterval() time.Duration {
+	if cfg == nil {
+		return DefaultSecretRefreshInterval
+	}
+	interval, err := parseSecretRefreshInterval(cfg.SecretRefreshInterval)
+	if err != nil {
+		return DefaultSecretRefreshInterval
+	}
+	return interval
+}
+
+func parseSecretRefreshInterval(raw string) (time.Duration, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return DefaultSecretRefreshInterval, nil
+	}
+	interval, err := time.ParseDuration(trimmed)
+	if err != nil || interval < 0 {
+		return 0, fmt.Errorf("secret-refresh-interval: invalid duration %q", raw)
+	}
+	return interval, nil
+}
+
+func (cfg *Config) validateSecretRefresh() []error {
+	if _, err := parseSecretRefreshInterval(cfg.SecretRefreshInterval); err != nil {
+		return []error{err}
+	}
+	return nil
+}
+
+// LookupSecretEnv reads the environment variable key, falling back to the file named by
+// key_FILE as Docker and Kubernetes secret conventions do. A secret:// value is resolved.
+func LookupSecretEnv(key string) (string, bool, error) {
+	if value, ok := os.LookupEnv(key); ok && strings.TrimSpace(value) != "" {
+		if secrets.IsReference(value) {
+			resolved, err := secrets.Resolve(context.Background(), value)
+			if err != nil {
+				return "", false, fmt.Errorf("%s: %w", key, err)
+			}
+			return resolved, true, nil
+		}
+		return strings.TrimSpace(value), true, nil
+	}
+	path, ok := os.LookupEnv(key + "_FILE")
+	if !ok || strings.TrimSpace(path) == "" {
+		return "", false, nil
+	}
+	value, err := ReadSecretFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s_FILE: %w", key, err)
+	}
+	return value, true, nil
+}
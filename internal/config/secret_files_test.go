@@ -0,0 +1,143 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+func writeTestFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestLoadConfigResolvesSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "client"), "client-secret\n")
+	writeTestFile(t, filepath.Join(dir, "claude"), "  sk-ant-secret  \n")
+	writeTestFile(t, filepath.Join(dir, "mgmt"), "admin-password\n")
+	path := filepath.Join(dir, "config.yaml")
+	writeTestFile(t, path, "port: 8317\n"+
+		"api-keys:\n  - \"file:"+filepath.Join(dir, "client")+"\"\n  - inline-key\n"+
+		"claude-api-key:\n  - api-key: \"file:"+filepath.Join(dir, "claude")+"\"\n"+
+		"remote-management:\n  secret-key: \"file:"+filepath.Join(dir, "mgmt")+"\"\n")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.APIKeys[0] != "client-secret" || cfg.APIKeys[1] != "inline-key" || cfg.ClaudeKey[0].APIKey != "sk-ant-secret" {
+		t.Fatalf("secrets not resolved: %v %q", cfg.APIKeys, cfg.ClaudeKey[0].APIKey)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(cfg.RemoteManagement.SecretKey), []byte("admin-password")) != nil {
+		t.Fatal("management secret from file should be hashed in memory")
+	}
+
+	// Neither saving nor printing may expose the secrets; the references stay in place.
+	if err = SaveConfigPreserveComments(path, cfg); err != nil {
+		t.Fatalf("SaveConfigPreserveComments: %v", err)
+	}
+	saved, _ := os.ReadFile(path)
+	printed, _ := yaml.Marshal(cfg.WithSecretReferences())
+	for name, out := range map[string]string{"saved": string(saved), "printed": string(printed)} {
+		if !strings.Contains(out, "file:"+filepath.Join(dir, "client")) || !strings.Contains(out, "file:"+filepath.Join(dir, "mgmt")) {
+			t.Fatalf("%s config lost the file reference:\n%s", name, out)
+		}
+		for _, secret := range []string{"client-secret", "sk-ant-secret", "$2a$"} {
+			if strings.Contains(out, secret) {
+				t.Fatalf("%s config contains %q:\n%s", name, secret, out)
+			}
+		}
+	}
+	if cfg.APIKeys[0] != "client-secret" {
+		t.Fatal("restoring references must not change the live config")
+	}
+}
+
+func TestLoadConfigFailsOnMissingSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "absent")
+	path := filepath.Join(dir, "config.yaml")
+	writeTestFile(t, path, "gemini-api-key:\n  - api-key: \"file:"+missing+"\"\n")
+
+	_, err := LoadConfig(path)
+	if err == nil {
+		t.Fatal("a missing secret file should fail the load")
+	}
+	if !strings.Contains(err.Error(), missing) || !strings.Contains(err.Error(), "gemini-api-key[0].api-key") {
+		t.Fatalf("error should name the field and the path: %v", err)
+	}
+}
+
+func TestLookupSecretEnvReadsFileCompanion(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "password")
+	writeTestFile(t, secret, "from-file\n")
+	t.Setenv("CLIPROXY_TEST_SECRET", "")
+	t.Setenv("CLIPROXY_TEST_SECRET_FILE", secret)
+	if value, ok, err := LookupSecretEnv("CLIPROXY_TEST_SECRET"); err != nil || !ok || value != "from-file" {
+		t.Fatalf("LookupSecretEnv = %q, %t, %v", value, ok, err)
+	}
+	t.Setenv("CLIPROXY_TEST_SECRET_FILE", secret+".missing")
+	if _, _, err := LookupSecretEnv("CLIPROXY_TEST_SECRET"); err == nil || !strings.Contains(err.Error(), secret+".missing") {
+		t.Fatalf("unreadable companion file should be reported with its path, got %v", err)
+	}
+}
+
+func TestLoadConfigResolvesSecretManagerReferences(t *testing.T) {
+	var version atomic.Int32
+	version.Store(1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/cliproxy" {
+			http.NotFound(w, r)
+			return
+		}
+		key := "sk-ant-v1"
+		if version.Load() == 2 {
+			key = "sk-ant-v2"
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"claude":"` + key + `","mgmt":"admin"},"metadata":{}}}`))
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeTestFile(t, path, "port: 8317\n"+
+		"claude-api-key:\n  - api-key: \"secret://vault/secret/data/cliproxy#claude\"\n"+
+		"remote-management:\n  secret-key: \"secret://vault/secret/data/cliproxy#mgmt\"\n")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.ClaudeKey[0].APIKey != "sk-ant-v1" || bcrypt.CompareHashAndPassword([]byte(cfg.RemoteManagement.SecretKey), []byte("admin")) != nil {
+		t.Fatalf("secrets not resolved: %q", cfg.ClaudeKey[0].APIKey)
+	}
+	if err = SaveConfigPreserveComments(path, cfg); err != nil {
+		t.Fatalf("SaveConfigPreserveComments: %v", err)
+	}
+	saved, _ := os.ReadFile(path)
+	if !strings.Contains(string(saved), "secret://vault/secret/data/cliproxy#claude") ||
+		!strings.Contains(string(saved), "secret://vault/secret/data/cliproxy#mgmt") || strings.Contains(string(saved), "sk-ant") {
+		t.Fatalf("saved config should keep the references:\n%s", saved)
+	}
+
+	// A rotated secret changes the version the refresh compares; an unchanged one does not.
+	again, err := LoadConfig(path)
+	if err != nil || again.SecretVersion() != cfg.SecretVersion() || cfg.SecretVersion() == "" {
+		t.Fatalf("unchanged secrets: version %q -> %q, %v", cfg.SecretVersion(), again.SecretVersion(), err)
+	}
+	version.Store(2)
+	rotated, err := LoadConfig(path)
+	if err != nil || rotated.SecretVersion() == cfg.SecretVersion() || rotated.ClaudeKey[0].APIKey != "sk-ant-v2" {
+		t.Fatalf("rotated secret not picked up: %v", err)
+	}
+}
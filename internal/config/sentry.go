@@ -0,0 +1,47 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DefaultSentryQueueSize bounds the events waiting to be sent to Sentry when queue-size is unset.
+const DefaultSentryQueueSize = 100
+
+// SentryConfig reports panics, upstream failures and persistence errors to Sentry. Events
+// carry request id, model and provider tags but never request or response content.
+type SentryConfig struct {
+	// DSN is the project DSN. Empty disables reporting.
+	DSN string `yaml:"dsn,omitempty" json:"dsn,omitempty"`
+	// Environment is reported with every event, e.g. "production".
+	Environment string `yaml:"environment,omitempty" json:"environment,omitempty"`
+	// QueueSize caps events waiting to be sent; further events are dropped (default 100).
+	QueueSize int `yaml:"queue-size,omitempty" json:"queue-size,omitempty"`
+}
+
+// Enabled reports whether a DSN is configured.
+func (s SentryConfig) Enabled() bool { return strings.TrimSpace(s.DSN) != "" }
+
+// QueueSizeOrDefault returns the number of events that may wait to be sent.
+func (s SentryConfig) QueueSizeOrDefault() int {
+	if s.QueueSize <= 0 {
+		return DefaultSentryQueueSize
+	}
+	return s.QueueSize
+}
+
+func (cfg *Config) validateSentry() []error {
+	var errs []error
+	if raw := strings.TrimSpace(cfg.Sentry.DSN); raw != "" {
+		// Only the shape is checked here, so the key in the DSN never ends up in an error message.
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User.Username() == "" {
+			errs = append(errs, errors.New("sentry: invalid dsn: want https://<key>@<host>/<project>"))
+		}
+	}
+	if cfg.Sentry.QueueSize < 0 {
+		errs = append(errs, fmt.Errorf("sentry: queue-size must not be negative, got %d", cfg.Sentry.QueueSize))
+	}
+	return errs
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultACMECacheDir is where issued certificates and the ACME account key are kept when
+// tls.acme.cache-dir is unset, relative to the directory of the config file.
+const DefaultACMECacheDir = "acme-certs"
+
+// TLSACMEConfig obtains certificates from an ACME certificate authority such as Let's Encrypt.
+// Challenges are answered on the TLS listener (TLS-ALPN-01), which the CA reaches on port
+// 443, and on http-challenge-addr (HTTP-01) when that is set.
+type TLSACMEConfig struct {
+	// Domains are the host names to obtain certificates for. Empty disables ACME.
+	Domains []string `yaml:"domains,omitempty" json:"domains,omitempty"`
+	// Email is the contact address registered with the CA for expiry notices.
+	Email string `yaml:"email,omitempty" json:"email,omitempty"`
+	// CacheDir keeps the certificates and account key across restarts (default acme-certs
+	// next to the config file).
+	CacheDir string `yaml:"cache-dir,omitempty" json:"cache-dir,omitempty"`
+	// DirectoryURL is the CA's directory; empty uses Let's Encrypt production.
+	DirectoryURL string `yaml:"directory-url,omitempty" json:"directory-url,omitempty"`
+	// HTTPChallengeAddr, e.g. ":80", serves HTTP-01 challenges and redirects other plain HTTP
+	// requests to HTTPS.
+	HTTPChallengeAddr string `yaml:"http-challenge-addr,omitempty" json:"http-challenge-addr,omitempty"`
+}
+
+// Enabled reports whether certificates are obtained through ACME.
+func (a TLSACMEConfig) Enabled() bool { return len(a.Domains) > 0 }
+
+// CacheDirFor returns the certificate cache directory for a config file at configPath.
+func (a TLSACMEConfig) CacheDirFor(configPath string) string {
+	dir := strings.TrimSpace(a.CacheDir)
+	if dir == "" {
+		dir = DefaultACMECacheDir
+	}
+	if filepath.IsAbs(dir) || configPath == "" {
+		return dir
+	}
+	return filepath.Join(filepath.Dir(configPath), dir)
+}
+
+// TLSClientAuthConfig turns on mutual TLS: clients present certificates signed by CA, and
+// the common name of a certificate stands in for an API key, so key scopes, quotas and
+// usage attribution apply to it as to the key.
+type TLSClientAuthConfig struct {
+	// CA is a PEM file with the certificate authorities that sign client certificates.
+	CA string `yaml:"ca,omitempty" json:"ca,omitempty"`
+	// Optional accepts connections without a client certificate; their requests then need an
+	// API key. By default the handshake fails without one.
+	Optional bool `yaml:"optional,omitempty" json:"optional,omitempty"`
+	// Identities maps certificate common names to the API keys they authenticate as.
+	Identities map[string]string `yaml:"identities,omitempty" json:"identities,omitempty"`
+}
+
+// Enabled reports whether client certificates are requested.
+func (c TLSClientAuthConfig) Enabled() bool { return strings.TrimSpace(c.CA) != "" }
+
+// APIKeyFor returns the API key a client certificate with commonName authenticates as.
+func (c TLSClientAuthConfig) APIKeyFor(commonName string) (string, bool) {
+	if commonName == "" {
+		return "", false
+	}
+	key, ok := c.Identities[commonName]
+	return key, ok && key != ""
+}
+
+func (cfg *Config) validateTLS() []error {
+	var errs []error
+	tlsCfg := cfg.TLS
+	hasFiles := strings.TrimSpace(tlsCfg.Cert) != "" || strings.TrimSpace(tlsCfg.Key) != ""
+	if tlsCfg.ACME.Enabled() {
+		if !tlsCfg.Enable {
+			errs = append(errs, fmt.Errorf("tls: acme needs enable: true"))
+		}
+		if hasFiles {
+			errs = append(errs, fmt.Errorf("tls: acme cannot be combined with cert and key"))
+		}
+		for i, domain := range tlsCfg.ACME.Domains {
+			if d := strings.TrimSpace(domain); d == "" || strings.ContainsAny(d, "/: ") || net.ParseIP(d) != nil {
+				errs = append(errs, fmt.Errorf("tls.acme.domains[%d]: %q is not a host name", i, domain))
+			}
+		}
+		if raw := strings.TrimSpace(tlsCfg.ACME.DirectoryURL); raw != "" {
+			if u, err := url.Parse(raw); err != nil || u.Scheme != "https" || u.Host == "" {
+				errs = append(errs, fmt.Errorf("tls: acme directory-url %q must be an https URL", raw))
+			}
+		}
+		if addr := strings.TrimSpace(tlsCfg.ACME.HTTPChallengeAddr); addr != "" {
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				errs = append(errs, fmt.Errorf("tls: acme http-challenge-addr %q must be host:port: %w", addr, err))
+			}
+		}
+	} else if tlsCfg.Enable && (strings.TrimSpace(tlsCfg.Cert) == "" || strings.TrimSpace(tlsCfg.Key) == "") {
+		errs = append(errs, fmt.Errorf("tls: enable needs both cert and key, or acme domains"))
+	}
+	clientAuth := tlsCfg.ClientAuth
+	if !clientAuth.Enabled() {
+		if len(clientAuth.Identities) > 0 || clientAuth.Optional {
+			errs = append(errs, fmt.Errorf("tls.client-auth: ca is required"))
+		}
+		return errs
+	}
+	if !tlsCfg.Enable {
+		errs = append(errs, fmt.Errorf("tls.client-auth: needs tls enable: true"))
+	}
+	keys := make(map[string]struct{}, len(cfg.APIKeys))
+	for _, key := range cfg.APIKeys {
+		keys[key] = struct{}{}
+	}
+	for _, provider := range cfg.Access.Providers {
+		for _, key := range provider.APIKeys {
+			keys[key] = struct{}{}
+		}
+	}
+	names := make([]string, 0, len(clientAuth.Identities))
+	for name := range clientAuth.Identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// The key itself is left out of the message; it is a credential.
+		if _, ok := keys[clientAuth.Identities[name]]; !ok {
+			errs = append(errs, fmt.Errorf("tls.client-auth.identities.%s: the API key is not one of api-keys", name))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Defaults applied to tracing settings when unset.
+const (
+	DefaultTracingServiceName = "cli-proxy-api"
+	DefaultTracingSampleRatio = 1.0
+)
+
+// TracingConfig exports OpenTelemetry spans for the request pipeline over OTLP/HTTP.
+type TracingConfig struct {
+	// Enabled turns span creation and export on. When false, tracing costs nothing per request.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Endpoint is the OTLP/HTTP collector URL, e.g. "http://localhost:4318". Empty uses the
+	// OTEL_EXPORTER_OTLP_ENDPOINT environment variable or the exporter default.
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	// Headers are sent with every export request, typically for collector authentication.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// ServiceName is reported as service.name (default "cli-proxy-api").
+	ServiceName string `yaml:"service-name,omitempty" json:"service-name,omitempty"`
+	// SampleRatio is the fraction of new traces recorded, between 0 and 1. Requests carrying a
+	// traceparent follow the caller's sampling decision. Nil means 1.
+	SampleRatio *float64 `yaml:"sample-ratio,omitempty" json:"sample-ratio,omitempty"`
+}
+
+// ServiceNameOrDefault returns the service.name resource attribute.
+func (t TracingConfig) ServiceNameOrDefault() string {
+	if name := strings.TrimSpace(t.ServiceName); name != "" {
+		return name
+	}
+	return DefaultTracingServiceName
+}
+
+// Ratio returns the fraction of new traces that are sampled.
+func (t TracingConfig) Ratio() float64 {
+	if t.SampleRatio == nil {
+		return DefaultTracingSampleRatio
+	}
+	return *t.SampleRatio
+}
+
+func (cfg *Config) validateTracing() []error {
+	var errs []error
+	if ratio := cfg.Tracing.Ratio(); ratio < 0 || ratio > 1 {
+		errs = append(errs, fmt.Errorf("tracing: sample-ratio must be between 0 and 1, got %v", ratio))
+	}
+	if raw := strings.TrimSpace(cfg.Tracing.Endpoint); raw != "" {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing: invalid endpoint %q: must be an http or https URL", cfg.Tracing.Endpoint))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultTransientRetryBackoff is the base delay between transient retries when unset.
+const DefaultTransientRetryBackoff = 250 * time.Millisecond
+
+// maxTransientRetries bounds max-retries; transient retries are meant to be few.
+const maxTransientRetries = 5
+
+// TransientRetryConfig retries upstream calls that failed at the transport level (connection
+// reset, GOAWAY, EOF before headers) or with 502, 503 or 529, before anything has been sent to
+// the client.
+type TransientRetryConfig struct {
+	// MaxRetries is how many transient failures a request may absorb (0 disables).
+	MaxRetries int `yaml:"max-retries,omitempty" json:"max-retries,omitempty"`
+	// Backoff is the base delay before retrying a credential that failed transiently (Go
+	// duration, default "250ms"). It doubles per retry and is jittered.
+	Backoff string `yaml:"backoff,omitempty" json:"backoff,omitempty"`
+}
+
+// Retries returns max-retries clamped to the supported range.
+func (c TransientRetryConfig) Retries() int {
+	return min(max(c.MaxRetries, 0), maxTransientRetries)
+}
+
+// BackoffDuration returns the base delay between retries.
+func (c TransientRetryConfig) BackoffDuration() time.Duration {
+	return positiveDurationOr(c.Backoff, DefaultTransientRetryBackoff)
+}
+
+func (cfg *Config) validateTransientRetry() []error {
+	var errs []error
+	if n := cfg.TransientRetry.MaxRetries; n < 0 || n > maxTransientRetries {
+		errs = append(errs, fmt.Errorf("transient-retry: max-retries must be between 0 and %d", maxTransientRetries))
+	}
+	if raw := strings.TrimSpace(cfg.TransientRetry.Backoff); raw != "" {
+		if d, err := time.ParseDuration(raw); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("transient-retry: invalid backoff %q: must be a positive duration", cfg.TransientRetry.Backoff))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// UpstreamHeaderRules adds headers to the upstream requests of one provider.
+type UpstreamHeaderRules struct {
+	// ForwardHeaders lists inbound client headers copied verbatim to the upstream request.
+	ForwardHeaders []string `yaml:"forward-headers,omitempty" json:"forward-headers,omitempty"`
+	// InjectHeaders sets static headers; values may reference environment variables as ${NAME}.
+	InjectHeaders map[string]string `yaml:"inject-headers,omitempty" json:"inject-headers,omitempty"`
+}
+
+// protectedUpstreamHeaders are never forwarded or injected: hop-by-hop headers belong to a
+// single connection, framing headers are computed by the transport, and credentials come
+// from the account serving the request.
+var protectedUpstreamHeaders = map[string]struct{}{
+	"Connection":          {},
+	"Keep-Alive":          {},
+	"Proxy-Authenticate":  {},
+	"Proxy-Authorization": {},
+	"Proxy-Connection":    {},
+	"Te":                  {},
+	"Trailer":             {},
+	"Transfer-Encoding":   {},
+	"Upgrade":             {},
+	"Host":                {},
+	"Content-Length":      {},
+	"Authorization":       {},
+	"X-Api-Key":           {},
+	"X-Goog-Api-Key":      {},
+	"Cookie":              {},
+	"Set-Cookie":          {},
+}
+
+// IsProtectedUpstreamHeader reports whether name may never be forwarded or injected.
+func IsProtectedUpstreamHeader(name string) bool {
+	_, ok := protectedUpstreamHeaders[http.CanonicalHeaderKey(strings.TrimSpace(name))]
+	return ok
+}
+
+var headerEnvReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ExpandHeaderValue substitutes ${NAME} references with the environment; unset variables
+// become empty. Other dollar signs are kept.
+func ExpandHeaderValue(value string) string {
+	if !strings.Contains(value, "${") {
+		return value
+	}
+	return headerEnvReference.ReplaceAllStringFunc(value, func(ref string) string {
+		return os.Getenv(ref[2 : len(ref)-1])
+	})
+}
+
+func (cfg *Config) validateUpstreamHeaders() []error {
+	var errs []error
+	for provider, rules := range cfg.UpstreamHeaders {
+		if strings.TrimSpace(provider) == "" {
+			errs = append(errs, fmt.Errorf("upstream-headers: provider name must not be empty"))
+		}
+		for i, name := range rules.ForwardHeaders {
+			switch {
+			case strings.TrimSpace(name) == "":
+				errs = append(errs, fmt.Errorf("upstream-headers.%s.forward-headers[%d]: name must not be empty", provider, i))
+			case IsProtectedUpstreamHeader(name):
+				errs = append(errs, fmt.Errorf("upstream-headers.%s.forward-headers[%d]: %s cannot be forwarded", provider, i, name))
+			}
+		}
+		for name := range rules.InjectHeaders {
+			switch {
+			case strings.TrimSpace(name) == "":
+				errs = append(errs, fmt.Errorf("upstream-headers.%s.inject-headers: name must not be empty", provider))
+			case IsProtectedUpstreamHeader(name):
+				errs = append(errs, fmt.Errorf("upstream-headers.%s.inject-headers: %s cannot be injected", provider, name))
+			}
+		}
+	}
+	return errs
+}
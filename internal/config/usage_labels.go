@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DefaultUsageLabelMaxValueLength is the longest label value kept when max-value-length is
+// unset. Longer values are dropped rather than cut, so a label is never attributed to a
+// shortened name that means something else.
+const DefaultUsageLabelMaxValueLength = 64
+
+// UsageLabelMaxNameLength is the longest label name usage-labels accepts.
+const UsageLabelMaxNameLength = 32
+
+// UsageLabels attaches labels taken from request headers to usage records, so that usage can
+// be attributed to groups the proxy does not know about, such as the team or project a
+// gateway in front of it resolved.
+type UsageLabels struct {
+	// Headers maps each label name to the request header carrying its value, for example
+	// team: X-Usage-Label-Team. Names are lower case letters, digits, '_', '-' and '.'.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// TrustedSources lists the peer addresses and CIDR ranges whose label headers are used;
+	// "localhost" stands for the loopback ranges. Headers from other peers are ignored.
+	// Empty trusts every peer.
+	TrustedSources []string `yaml:"trusted-sources,omitempty" json:"trusted-sources,omitempty"`
+
+	// MaxValueLength is the longest label value kept; longer ones are ignored. Zero uses
+	// DefaultUsageLabelMaxValueLength.
+	MaxValueLength int `yaml:"max-value-length,omitempty" json:"max-value-length,omitempty"`
+}
+
+// UsageLabelHeader is one configured label and the header it is read from.
+type UsageLabelHeader struct {
+	Name   string
+	Header string
+}
+
+// LabelHeaders returns the configured labels sorted by name, with canonical header names.
+func (l UsageLabels) LabelHeaders() []UsageLabelHeader {
+	headers := make([]UsageLabelHeader, 0, len(l.Headers))
+	for name, header := range l.Headers {
+		name, header = strings.TrimSpace(name), strings.TrimSpace(header)
+		if name == "" || header == "" {
+			continue
+		}
+		headers = append(headers, UsageLabelHeader{Name: name, Header: http.CanonicalHeaderKey(header)})
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Name < headers[j].Name })
+	return headers
+}
+
+// ValueLimit returns the longest label value kept.
+func (l UsageLabels) ValueLimit() int {
+	if l.MaxValueLength <= 0 {
+		return DefaultUsageLabelMaxValueLength
+	}
+	return l.MaxValueLength
+}
+
+// ValidUsageLabelName reports whether name may be used as a label name.
+func ValidUsageLabelName(name string) bool {
+	if name == "" || len(name) > UsageLabelMaxNameLength {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' && r != '-' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func (cfg *Config) validateUsageLabels() []error {
+	var errs []error
+	for name, header := range cfg.UsageLabels.Headers {
+		if !ValidUsageLabelName(strings.TrimSpace(name)) {
+			errs = append(errs, fmt.Errorf("usage-labels: invalid label name %q: use up to %d lower case letters, digits, '_', '-' or '.'", name, UsageLabelMaxNameLength))
+		}
+		if strings.TrimSpace(header) == "" {
+			errs = append(errs, fmt.Errorf("usage-labels: label %q has no header", name))
+		}
+	}
+	if cfg.UsageLabels.MaxValueLength < 0 {
+		errs = append(errs, fmt.Errorf("usage-labels: max-value-length must not be negative, got %d", cfg.UsageLabels.MaxValueLength))
+	}
+	if _, err := ParseIPPrefixes(cfg.UsageLabels.TrustedSources); err != nil {
+		errs = append(errs, fmt.Errorf("usage-labels: trusted-sources: %w", err))
+	}
+	return errs
+}
@@ -0,0 +1,23 @@
+package config
+
+import "testing"
+
+func TestValidateUsageLabels(t *testing.T) {
+	cfg := &Config{UsageLabels: UsageLabels{
+		Headers:        map[string]string{"team": "X-Usage-Label-Team", "Bad Name": "X-Bad", "empty": " "},
+		TrustedSources: []string{"not-an-ip"},
+		MaxValueLength: -1,
+	}}
+	if errs := cfg.validateUsageLabels(); len(errs) != 4 {
+		t.Fatalf("validateUsageLabels returned %d errors, want 4: %v", len(errs), errs)
+	}
+
+	cfg.UsageLabels = UsageLabels{Headers: map[string]string{"team": "x-usage-label-team", "project.id": "X-Project"}}
+	if errs := cfg.validateUsageLabels(); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	headers := cfg.UsageLabels.LabelHeaders()
+	if len(headers) != 2 || headers[0].Name != "project.id" || headers[1].Header != "X-Usage-Label-Team" {
+		t.Fatalf("LabelHeaders = %+v, want sorted names and canonical headers", headers)
+	}
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// DefaultUsageSaveInterval is the periodic save interval used when save-interval is unset,
+// or when it is invalid and strict-save-interval is disabled.
+const DefaultUsageSaveInterval = 5 * time.Minute
+
+// DefaultUsageCorruptBackups is how many backups of unreadable usage files are kept when
+// max-corrupt-backups is unset.
+const DefaultUsageCorruptBackups = 5
+
+// Key identifier modes accepted by UsagePersistence.KeyIdentifiers.
+const (
+	KeyIdentifiersRaw      = "raw"
+	KeyIdentifiersHash     = "hash"
+	KeyIdentifiersTruncate = "truncate"
+)
+
+// UsagePersistence configures on-disk persistence of usage statistics.
+type UsagePersistence struct {
+	// File is the path of the JSON file holding persisted statistics, or an s3://bucket/key or
+	// gs://bucket/object URL to keep it in object storage. Empty disables persistence.
+	File string `yaml:"file" json:"file"`
+
+	// SpoolDir holds the working copy of a remote File, along with backups of unreadable
+	// copies. Empty uses a directory in the system temporary directory.
+	SpoolDir string `yaml:"spool-dir,omitempty" json:"spool-dir,omitempty"`
+
+	// SaveInterval is a Go duration (e.g. "30s", "5m") between periodic saves.
+	// Empty uses DefaultUsageSaveInterval; "0" saves only on shutdown.
+	SaveInterval string `yaml:"save-interval" json:"save-interval"`
+
+	// StrictSaveInterval aborts startup on an unparseable save-interval instead of
+	// falling back to DefaultUsageSaveInterval.
+	StrictSaveInterval bool `yaml:"strict-save-interval" json:"strict-save-interval"`
+
+	// LeaderElection lets replicas share one file: only the replica holding the lock file next
+	// to it saves, and the others hand their statistics over through the same directory. It
+	// needs a positive save-interval, which is also the lease of the lock.
+	LeaderElection bool `yaml:"leader-election,omitempty" json:"leader-election,omitempty"`
+
+	// MaxCorruptBackups is how many copies of unreadable usage files are kept next to File;
+	// older ones are deleted on load. Zero uses DefaultUsageCorruptBackups.
+	MaxCorruptBackups int `yaml:"max-corrupt-backups,omitempty" json:"max-corrupt-backups,omitempty"`
+
+	// PersistFallback is where statistics are saved while File cannot be written, for example
+	// because its disk is full. A directory, or a path ending in a separator, holds a file
+	// named like File. Empty disables the fallback.
+	PersistFallback string `yaml:"persist-fallback,omitempty" json:"persist-fallback,omitempty"`
+
+	// KeepDays is how many days of request details are kept in memory and in File. Older
+	// ones are moved to monthly archives next to File and no longer counted. Zero keeps
+	// everything.
+	KeepDays int `yaml:"keep-days,omitempty" json:"keep-days,omitempty"`
+
+	// MaxFileSizeMB caps the size of File: once a save writes more, the oldest details are
+	// archived until the next save is expected to fit. Zero disables the cap.
+	MaxFileSizeMB int `yaml:"max-file-size-mb,omitempty" json:"max-file-size-mb,omitempty"`
+
+	// Journal appends every record to a journal next to File between saves and replays it on
+	// startup, so a crash or kill loses no statistics. It cannot be combined with
+	// LeaderElection, whose replicas share File.
+	Journal bool `yaml:"journal,omitempty" json:"journal,omitempty"`
+
+	// KeyIdentifiers is how client API keys are written to File, its archives and journal:
+	// KeyIdentifiersRaw (the default) as they are, KeyIdentifiersHash as their SHA-256, or
+	// KeyIdentifiersTruncate shortened as in the logs. Statistics in memory keep the keys.
+	KeyIdentifiers string `yaml:"key-identifiers,omitempty" json:"key-identifiers,omitempty"`
+}
+
+// Enabled reports whether usage persistence is configured.
+func (p UsagePersistence) Enabled() bool {
+	return strings.TrimSpace(p.File) != ""
+}
+
+// RemoteFile reports whether File is an object storage URL rather than a path.
+func (p UsagePersistence) RemoteFile() bool {
+	file := strings.TrimSpace(p.File)
+	return strings.HasPrefix(file, "s3://") || strings.HasPrefix(file, "gs://")
+}
+
+// LocalFile returns the file statistics are saved to on this machine: File, or for a remote
+// File its working copy in SpoolDir, named after the URL so that two URLs never share one.
+func (p UsagePersistence) LocalFile() string {
+	file := strings.TrimSpace(p.File)
+	if !p.RemoteFile() {
+		return file
+	}
+	dir := strings.TrimSpace(p.SpoolDir)
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "cli-proxy-api-usage")
+	}
+	name := "usage.json"
+	if location, err := url.Parse(file); err == nil && strings.Trim(location.Path, "/") != "" {
+		name = path.Base(location.Path)
+	}
+	sum := sha256.Sum256([]byte(file))
+	return filepath.Join(dir, hex.EncodeToString(sum[:4])+"-"+name)
+}
+
+// validateRemoteFile checks a remote File. Leader election needs a file the replicas share on
+// disk, which a remote File is not, and only the file itself is uploaded, so the archives
+// and journal kept next to it could not outlive the machine.
+func (p UsagePersistence) validateRemoteFile() []error {
+	if !p.RemoteFile() {
+		return nil
+	}
+	var errs []error
+	location, err := url.Parse(strings.TrimSpace(p.File))
+	if err != nil || location.Host == "" || strings.Trim(location.Path, "/") == "" {
+		errs = append(errs, fmt.Errorf("usage-persistence: file must be s3://bucket/key or gs://bucket/object"))
+	}
+	if p.LeaderElection {
+		errs = append(errs, fmt.Errorf("usage-persistence: leader-election cannot be used with an s3:// or gs:// file"))
+	}
+	if p.KeepDays > 0 || p.MaxFileSizeMB > 0 {
+		errs = append(errs, fmt.Errorf("usage-persistence: keep-days and max-file-size-mb cannot be used with an s3:// or gs:// file"))
+	}
+	if p.Journal {
+		errs = append(errs, fmt.Errorf("usage-persistence: journal cannot be used with an s3:// or gs:// file"))
+	}
+	return errs
+}
+
+// KeyIdentifierMode returns the normalised KeyIdentifiers, KeyIdentifiersRaw when unset.
+func (p UsagePersistence) KeyIdentifierMode() string {
+	mode := strings.ToLower(strings.TrimSpace(p.KeyIdentifiers))
+	if mode == "" {
+		return KeyIdentifiersRaw
+	}
+	return mode
+}
+
+// CorruptBackupLimit returns how many corrupt-file backups are kept.
+func (p UsagePersistence) CorruptBackupLimit() int {
+	if p.MaxCorruptBackups <= 0 {
+		return DefaultUsageCorruptBackups
+	}
+	return p.MaxCorruptBackups
+}
+
+// FallbackFile returns the file saves fall back to, or "" when persist-fallback is unset.
+func (p UsagePersistence) FallbackFile() string {
+	fallback := strings.TrimSpace(p.PersistFallback)
+	if fallback == "" {
+		return ""
+	}
+	if info, err := os.Stat(fallback); (err == nil && info.IsDir()) || os.IsPathSeparator(fallback[len(fallback)-1]) {
+		return filepath.Join(fallback, filepath.Base(strings.TrimSpace(p.File)))
+	}
+	return fallback
+}
+
+// ParseSaveInterval parses a save-interval value.
+// Empty input yields DefaultUsageSaveInterval, zero means save only on shutdown,
+// and unparseable or negative values are rejected.
+func ParseSaveInterval(raw string) (time.Duration, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return DefaultUsageSaveInterval, nil
+	}
+	interval, err := time.ParseDuration(trimmed)
+	if err != nil {
+		return 0, fmt.Errorf("invalid save-interval %q: %w", raw, err)
+	}
+	if interval < 0 {
+		return 0, fmt.Errorf("invalid save-interval %q: must not be negative", raw)
+	}
+	return interval, nil
+}
+
+// ResolveSaveInterval returns the effective periodic save interval.
+// An invalid value is returned as an error when StrictSaveInterval is set; otherwise a
+// warning is logged and DefaultUsageSaveInterval is used.
+func (p UsagePersistence) ResolveSaveInterval() (time.Duration, error) {
+	interval, err := ParseSaveInterval(p.SaveInterval)
+	if err == nil {
+		return interval, nil
+	}
+	if p.StrictSaveInterval {
+		return 0, err
+	}
+	log.Warnf("%v; falling back to default save interval %s", err, DefaultUsageSaveInterval)
+	return DefaultUsageSaveInterval, nil
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSaveInterval(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "empty uses default", raw: "", want: DefaultUsageSaveInterval},
+		{name: "zero means shutdown only", raw: "0", want: 0},
+		{name: "valid duration", raw: "30s", want: 30 * time.Second},
+		{name: "garbage", raw: "abc", wantErr: true},
+		{name: "negative", raw: "-5m", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseSaveInterval(tc.raw)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for %q, got interval %s", tc.raw, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error for %q: %v", tc.raw, err)
+			}
+			if got != tc.want {
+				t.Fatalf("interval for %q = %s, want %s", tc.raw, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolveSaveInterval_Strictness(t *testing.T) {
+	for _, raw := range []string{"abc", "-5m"} {
+		lenient := UsagePersistence{SaveInterval: raw}
+		got, err := lenient.ResolveSaveInterval()
+		if err != nil {
+			t.Fatalf("lenient resolve of %q returned error: %v", raw, err)
+		}
+		if got != DefaultUsageSaveInterval {
+			t.Fatalf("lenient resolve of %q = %s, want default %s", raw, got, DefaultUsageSaveInterval)
+		}
+
+		strict := UsagePersistence{SaveInterval: raw, StrictSaveInterval: true}
+		if _, err = strict.ResolveSaveInterval(); err == nil {
+			t.Fatalf("strict resolve of %q should fail", raw)
+		}
+	}
+
+	zero := UsagePersistence{SaveInterval: "0", StrictSaveInterval: true}
+	if got, err := zero.ResolveSaveInterval(); err != nil || got != 0 {
+		t.Fatalf("strict resolve of \"0\" = %s, %v; want 0, nil", got, err)
+	}
+}
+
+func TestValidate_RejectsInvalidSaveInterval(t *testing.T) {
+	cfg := &Config{UsagePersistence: UsagePersistence{File: "usage.json", SaveInterval: "-5m"}}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected negative save-interval to fail validation")
+	}
+	cfg.UsagePersistence.SaveInterval = "30s"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+}
+
+func TestValidate_KeyIdentifiers(t *testing.T) {
+	cfg := &Config{UsagePersistence: UsagePersistence{File: "usage.json", KeyIdentifiers: "Hash"}}
+	if err := cfg.Validate(); err != nil || cfg.UsagePersistence.KeyIdentifierMode() != KeyIdentifiersHash {
+		t.Fatalf("key-identifiers Hash: mode %q, %v; want hash accepted", cfg.UsagePersistence.KeyIdentifierMode(), err)
+	}
+	cfg.UsagePersistence.KeyIdentifiers = "encrypt"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected unknown key-identifiers to fail validation")
+	}
+}
+
+func TestFallbackFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct{ fallback, want string }{
+		{"", ""},
+		{filepath.Join(dir, "spare.json"), filepath.Join(dir, "spare.json")},
+		{dir, filepath.Join(dir, "usage.json")},
+		{filepath.Join(dir, "missing") + string(filepath.Separator), filepath.Join(dir, "missing", "usage.json")},
+	} {
+		p := UsagePersistence{File: "./data/usage.json", PersistFallback: tc.fallback}
+		if got := p.FallbackFile(); got != tc.want {
+			t.Fatalf("FallbackFile for %q = %q, want %q", tc.fallback, got, tc.want)
+		}
+	}
+}
+
+func TestRemoteUsageFile(t *testing.T) {
+	spool := t.TempDir()
+	p := UsagePersistence{File: "s3://bucket/stats/usage.json", SpoolDir: spool}
+	local := p.LocalFile()
+	if !p.RemoteFile() || filepath.Dir(local) != spool || !strings.HasSuffix(local, "-usage.json") {
+		t.Fatalf("LocalFile of a remote file = %q, want a usage.json working copy in %s", local, spool)
+	}
+	other := UsagePersistence{File: "gs://bucket/stats/usage.json", SpoolDir: spool}
+	if other.LocalFile() == local {
+		t.Fatalf("two remote files share the working copy %s", local)
+	}
+	if errs := p.validateRemoteFile(); len(errs) != 0 {
+		t.Fatalf("valid remote file rejected: %v", errs)
+	}
+	for _, bad := range []UsagePersistence{
+		{File: "s3://bucket"},
+		{File: "gs://bucket/usage.json", LeaderElection: true},
+		{File: "gs://bucket/usage.json", KeepDays: 30},
+		{File: "gs://bucket/usage.json", MaxFileSizeMB: 10},
+		{File: "s3://bucket/usage.json", Journal: true},
+	} {
+		if errs := bad.validateRemoteFile(); len(errs) == 0 {
+			t.Fatalf("remote file %+v should be rejected", bad)
+		}
+	}
+}
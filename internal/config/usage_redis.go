@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultUsageRedisKeyPrefix prefixes every key the shared usage store writes.
+const DefaultUsageRedisKeyPrefix = "cliproxy:usage:"
+
+// DefaultUsageRedisFlushInterval is how often recorded usage is pushed to Redis.
+const DefaultUsageRedisFlushInterval = time.Second
+
+// UsageRedis stores usage statistics in Redis so that several replicas share one set of totals.
+type UsageRedis struct {
+	// Address is the host:port of the Redis server. Empty disables the shared store.
+	Address  string `yaml:"address" json:"address"`
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+	DB       int    `yaml:"db,omitempty" json:"db,omitempty"`
+	// KeyPrefix prefixes every key, so replicas of one deployment must agree on it.
+	KeyPrefix string `yaml:"key-prefix,omitempty" json:"key-prefix,omitempty"`
+	// FlushInterval is a Go duration between pushes of recorded usage (default "1s").
+	FlushInterval string        `yaml:"flush-interval,omitempty" json:"flush-interval,omitempty"`
+	TLS           UsageRedisTLS `yaml:"tls,omitempty" json:"tls,omitempty"`
+}
+
+// UsageRedisTLS configures TLS for the Redis connection.
+type UsageRedisTLS struct {
+	Enable bool `yaml:"enable" json:"enable"`
+	// CAFile is a PEM bundle trusted instead of the system roots.
+	CAFile     string `yaml:"ca-file,omitempty" json:"ca-file,omitempty"`
+	ServerName string `yaml:"server-name,omitempty" json:"server-name,omitempty"`
+	// InsecureSkipVerify disables certificate verification; meant for testing only.
+	InsecureSkipVerify bool `yaml:"insecure-skip-verify,omitempty" json:"insecure-skip-verify,omitempty"`
+}
+
+// Enabled reports whether usage statistics are shared through Redis.
+func (r UsageRedis) Enabled() bool {
+	return strings.TrimSpace(r.Address) != ""
+}
+
+// KeyPrefixOrDefault returns the configured key prefix or DefaultUsageRedisKeyPrefix.
+func (r UsageRedis) KeyPrefixOrDefault() string {
+	if prefix := strings.TrimSpace(r.KeyPrefix); prefix != "" {
+		return prefix
+	}
+	return DefaultUsageRedisKeyPrefix
+}
+
+// FlushIntervalDuration returns the interval between pushes to Redis.
+func (r UsageRedis) FlushIntervalDuration() time.Duration {
+	return positiveDurationOr(r.FlushInterval, DefaultUsageRedisFlushInterval)
+}
+
+func (cfg *Config) validateUsageRedis() []error {
+	r := cfg.UsageRedis
+	if !r.Enabled() {
+		return nil
+	}
+	var errs []error
+	if !strings.Contains(strings.TrimSpace(r.Address), ":") {
+		errs = append(errs, fmt.Errorf("usage-redis: address %q must be host:port", r.Address))
+	}
+	if r.DB < 0 {
+		errs = append(errs, fmt.Errorf("usage-redis: db must not be negative, got %d", r.DB))
+	}
+	if raw := strings.TrimSpace(r.FlushInterval); raw != "" {
+		if d, err := time.ParseDuration(raw); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("usage-redis: invalid flush-interval %q: must be a positive duration", r.FlushInterval))
+		}
+	}
+	if caFile := strings.TrimSpace(r.TLS.CAFile); caFile != "" {
+		if _, err := os.Stat(caFile); err != nil {
+			errs = append(errs, fmt.Errorf("usage-redis: tls.ca-file: %w", err))
+		}
+	}
+	return errs
+}
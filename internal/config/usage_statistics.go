@@ -0,0 +1,22 @@
+package config
+
+import "fmt"
+
+// DefaultUsageStatisticsMaxKeys is the number of distinct API keys, and of distinct models per
+// key, kept in usage statistics when usage-statistics-max-keys is unset.
+const DefaultUsageStatisticsMaxKeys = 1000
+
+// EffectiveUsageStatisticsMaxKeys returns the configured per-dimension key cap or the default.
+func (cfg *Config) EffectiveUsageStatisticsMaxKeys() int {
+	if cfg == nil || cfg.UsageStatisticsMaxKeys <= 0 {
+		return DefaultUsageStatisticsMaxKeys
+	}
+	return cfg.UsageStatisticsMaxKeys
+}
+
+func (cfg *Config) validateUsageStatistics() []error {
+	if cfg.UsageStatisticsMaxKeys < 0 {
+		return []error{fmt.Errorf("usage-statistics-max-keys: must not be negative, got %d", cfg.UsageStatisticsMaxKeys)}
+	}
+	return nil
+}
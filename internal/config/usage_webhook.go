@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Defaults applied to usage-webhook settings when unset.
+const (
+	DefaultUsageWebhookBatchSize     = 100
+	DefaultUsageWebhookFlushInterval = 10 * time.Second
+	DefaultUsageWebhookMaxBuffer     = 10000
+)
+
+// UsageWebhook posts every usage record to an HTTP endpoint, in JSON batches, for billing
+// systems and SIEMs that ingest events rather than scrape the proxy.
+type UsageWebhook struct {
+	// URL receives the batches as POST requests. Empty disables the webhook.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+	// Headers are sent with every request, typically for authentication.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// BatchSize is the most records sent in one request (default 100). A full batch is sent
+	// without waiting for the flush interval.
+	BatchSize int `yaml:"batch-size,omitempty" json:"batch-size,omitempty"`
+	// FlushInterval is a Go duration after which a partial batch is sent (default "10s").
+	FlushInterval string `yaml:"flush-interval,omitempty" json:"flush-interval,omitempty"`
+	// MaxBuffer is how many records are held while the endpoint fails (default 10000); the
+	// oldest are dropped beyond it.
+	MaxBuffer int `yaml:"max-buffer,omitempty" json:"max-buffer,omitempty"`
+}
+
+// Enabled reports whether usage records are posted.
+func (w UsageWebhook) Enabled() bool {
+	return strings.TrimSpace(w.URL) != ""
+}
+
+// BatchLimit returns the most records sent in one request.
+func (w UsageWebhook) BatchLimit() int {
+	if w.BatchSize <= 0 {
+		return DefaultUsageWebhookBatchSize
+	}
+	return w.BatchSize
+}
+
+// FlushIntervalDuration returns how long a partial batch waits.
+func (w UsageWebhook) FlushIntervalDuration() time.Duration {
+	return positiveDurationOr(w.FlushInterval, DefaultUsageWebhookFlushInterval)
+}
+
+// BufferLimit returns how many records are held while the endpoint fails, never fewer
+// than one batch.
+func (w UsageWebhook) BufferLimit() int {
+	limit := w.MaxBuffer
+	if limit <= 0 {
+		limit = DefaultUsageWebhookMaxBuffer
+	}
+	return max(limit, w.BatchLimit())
+}
+
+func (cfg *Config) validateUsageWebhook() []error {
+	w := cfg.UsageWebhook
+	if !w.Enabled() {
+		return nil
+	}
+	var errs []error
+	// The URL may embed a secret token, so it is never quoted in errors.
+	if u, err := url.Parse(strings.TrimSpace(w.URL)); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("usage-webhook: url must be an http or https URL"))
+	}
+	if raw := strings.TrimSpace(w.FlushInterval); raw != "" {
+		if d, err := time.ParseDuration(raw); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("usage-webhook: invalid flush-interval %q: must be a positive duration", w.FlushInterval))
+		}
+	}
+	if w.BatchSize < 0 {
+		errs = append(errs, fmt.Errorf("usage-webhook: batch-size must not be negative"))
+	}
+	if w.MaxBuffer < 0 {
+		errs = append(errs, fmt.Errorf("usage-webhook: max-buffer must not be negative"))
+	}
+	return errs
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Validate reports configuration values that are syntactically valid YAML but
+// semantically invalid. All problems are returned together so they can be fixed at once.
+func (cfg *Config) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	var errs []error
+	if interval, err := ParseSaveInterval(cfg.UsagePersistence.SaveInterval); err != nil {
+		errs = append(errs, fmt.Errorf("usage-persistence: %w", err))
+	} else if cfg.UsagePersistence.LeaderElection && interval == 0 {
+		errs = append(errs, fmt.Errorf("usage-persistence: leader-election needs a positive save-interval"))
+	}
+	if cfg.UsagePersistence.MaxCorruptBackups < 0 {
+		errs = append(errs, fmt.Errorf("usage-persistence: max-corrupt-backups must not be negative"))
+	}
+	if cfg.UsagePersistence.KeepDays < 0 {
+		errs = append(errs, fmt.Errorf("usage-persistence: keep-days must not be negative"))
+	}
+	if cfg.UsagePersistence.MaxFileSizeMB < 0 {
+		errs = append(errs, fmt.Errorf("usage-persistence: max-file-size-mb must not be negative"))
+	}
+	if cfg.UsagePersistence.Journal && cfg.UsagePersistence.LeaderElection {
+		errs = append(errs, fmt.Errorf("usage-persistence: journal cannot be combined with leader-election"))
+	}
+	errs = append(errs, cfg.UsagePersistence.validateRemoteFile()...)
+	switch cfg.UsagePersistence.KeyIdentifierMode() {
+	case KeyIdentifiersRaw, KeyIdentifiersHash, KeyIdentifiersTruncate:
+	default:
+		errs = append(errs, fmt.Errorf("usage-persistence: key-identifiers %q must be raw, hash or truncate", cfg.UsagePersistence.KeyIdentifiers))
+	}
+	if _, err := parseAuthRefreshMargin(cfg.AuthRefreshMargin); err != nil {
+		errs = append(errs, err)
+	}
+	for _, entry := range []struct{ name, raw string }{
+		{"interval", cfg.HealthCheck.Interval},
+		{"recovery-interval", cfg.HealthCheck.RecoveryInterval},
+		{"timeout", cfg.HealthCheck.Timeout},
+	} {
+		name, raw := entry.name, entry.raw
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(strings.TrimSpace(raw)); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("health-check: invalid %s %q: must be a positive duration", name, raw))
+		}
+	}
+	if cfg.HealthCheck.FailureThreshold < 0 {
+		errs = append(errs, fmt.Errorf("health-check: failure-threshold must not be negative"))
+	}
+	errs = append(errs, cfg.validateAuthDirs()...)
+	errs = append(errs, cfg.validateDailyQuotas()...)
+	errs = append(errs, cfg.validateAPIKeySettings()...)
+	errs = append(errs, cfg.validateMaxOutputTokens()...)
+	errs = append(errs, cfg.validateReasoningOutput()...)
+	errs = append(errs, cfg.validateHTTPTransport()...)
+	errs = append(errs, cfg.validateUsageStatistics()...)
+	errs = append(errs, cfg.validateUsageLabels()...)
+	errs = append(errs, cfg.validateModelCache()...)
+	errs = append(errs, cfg.validateSecretRefresh()...)
+	errs = append(errs, cfg.validateLogging()...)
+	errs = append(errs, cfg.validateLogSampling()...)
+	errs = append(errs, cfg.validateTracing()...)
+	errs = append(errs, cfg.validateSentry()...)
+	errs = append(errs, cfg.validateNotifications()...)
+	errs = append(errs, cfg.validateUsageRedis()...)
+	errs = append(errs, cfg.validateUsageWebhook()...)
+	errs = append(errs, cfg.validateModelPricing()...)
+	errs = append(errs, cfg.validateMetrics()...)
+	errs = append(errs, cfg.validateAuditLog()...)
+	errs = append(errs, cfg.validateManagement()...)
+	errs = append(errs, cfg.validateAPIAccess()...)
+	errs = append(errs, cfg.validateJWTProviders()...)
+	errs = append(errs, cfg.validateTransientRetry()...)
+	errs = append(errs, cfg.validateUpstreamHeaders()...)
+	errs = append(errs, cfg.validateResponseCache()...)
+	errs = append(errs, cfg.validateTLS()...)
+	return errors.Join(errs...)
+}
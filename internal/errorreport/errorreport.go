@@ -0,0 +1,147 @@
+// Package errorreport sends operational failures to Sentry: panics recovered by the HTTP
+// middleware, upstream failures left after every retry, and usage persistence errors. Events
+// carry identifying tags only; request and response content is never attached. Without a DSN
+// every function returns immediately.
+package errorreport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+const (
+	// sendTimeout bounds a single delivery to Sentry.
+	sendTimeout = 10 * time.Second
+	// replacedFlushTimeout bounds flushing a client replaced by a config reload.
+	replacedFlushTimeout = 5 * time.Second
+)
+
+var (
+	client atomic.Pointer[sentry.Client]
+
+	mu      sync.Mutex
+	applied config.SentryConfig
+)
+
+// Configure applies the sentry settings. Events queued by a client being replaced are sent
+// in the background.
+func Configure(cfg config.SentryConfig) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if cfg == applied {
+		return nil
+	}
+	var next *sentry.Client
+	if cfg.Enabled() {
+		// HTTPTransport sends from its own goroutine and drops events once BufferSize are waiting.
+		transport := sentry.NewHTTPTransport()
+		transport.BufferSize = cfg.QueueSizeOrDefault()
+		transport.Timeout = sendTimeout
+		c, err := sentry.NewClient(sentry.ClientOptions{
+			Dsn:         strings.TrimSpace(cfg.DSN),
+			Environment: cfg.Environment,
+			Release:     buildinfo.Version,
+			Transport:   transport,
+			// Source context around stack frames is left out along with all other content.
+			Integrations: func(integrations []sentry.Integration) []sentry.Integration {
+				kept := integrations[:0]
+				for _, integration := range integrations {
+					if integration.Name() != "ContextifyFrames" {
+						kept = append(kept, integration)
+					}
+				}
+				return kept
+			},
+		})
+		if err != nil {
+			// The error may quote the DSN, which contains the project key.
+			return errors.New("sentry: invalid dsn")
+		}
+		next = c
+	}
+	applied = cfg
+	if previous := client.Swap(next); previous != nil {
+		go previous.Flush(replacedFlushTimeout)
+	}
+	return nil
+}
+
+// Enabled reports whether events are being sent.
+func Enabled() bool { return client.Load() != nil }
+
+// Flush waits until queued events are sent or ctx is done, and then stops reporting.
+func Flush(ctx context.Context) bool {
+	mu.Lock()
+	applied = config.SentryConfig{}
+	previous := client.Swap(nil)
+	mu.Unlock()
+	if previous == nil {
+		return true
+	}
+	return previous.FlushWithContext(ctx)
+}
+
+// CaptureUpstreamFailure reports a request that failed upstream after the auth manager ran
+// out of retries. Only the status and the error's type are sent: upstream error text can
+// quote the prompt.
+func CaptureUpstreamFailure(ctx context.Context, provider, model string, status int, err error) {
+	c := client.Load()
+	if c == nil || err == nil {
+		return
+	}
+	tags := requestTags(ctx)
+	tags["provider"] = provider
+	tags["model"] = model
+	tags["status"] = strconv.Itoa(status)
+	tags["error_type"] = fmt.Sprintf("%T", err)
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelError
+	event.Message = fmt.Sprintf("upstream request failed with status %d", status)
+	event.Tags = tags
+	event.Fingerprint = []string{"upstream", provider, strconv.Itoa(status)}
+	c.CaptureEvent(event, nil, nil)
+}
+
+// CaptureError reports an internal failure of component, such as usage persistence. err must
+// not contain request content.
+func CaptureError(component string, err error) {
+	c := client.Load()
+	if c == nil || err == nil {
+		return
+	}
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelError
+	event.Message = err.Error()
+	event.Tags = map[string]string{"component": component}
+	event.Fingerprint = []string{"component", component}
+	c.CaptureEvent(event, nil, nil)
+}
+
+// requestTags returns the request id carried by ctx or its gin context.
+func requestTags(ctx context.Context) map[string]string {
+	tags := make(map[string]string, 6)
+	if ctx == nil {
+		return tags
+	}
+	requestID := logging.GetRequestID(ctx)
+	if requestID == "" {
+		if c := ginContext(ctx); c != nil {
+			requestID = logging.GetGinRequestID(c)
+		}
+	}
+	if requestID != "" {
+		tags["request_id"] = requestID
+	}
+	return tags
+}
@@ -0,0 +1,109 @@
+package errorreport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+// fakeSentry collects the envelopes posted to a test Sentry endpoint.
+type fakeSentry struct {
+	mu        sync.Mutex
+	envelopes []string
+}
+
+func newFakeSentry(t *testing.T) *fakeSentry {
+	t.Helper()
+	fake := &fakeSentry{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fake.mu.Lock()
+		fake.envelopes = append(fake.envelopes, string(body))
+		fake.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	dsn := strings.Replace(server.URL, "http://", "http://public@", 1) + "/1"
+	if err := Configure(config.SentryConfig{DSN: dsn}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	t.Cleanup(func() { Flush(context.Background()) })
+	return fake
+}
+
+func (f *fakeSentry) received(t *testing.T) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if !Flush(ctx) {
+		t.Fatal("events were not sent before the timeout")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return strings.Join(f.envelopes, "\n")
+}
+
+func TestCaptureUpstreamFailureSendsTagsButNoContent(t *testing.T) {
+	fake := newFakeSentry(t)
+	ctx := logging.WithRequestID(context.Background(), "a1b2c3d4")
+	CaptureUpstreamFailure(ctx, "claude", "claude-sonnet-4", http.StatusBadGateway, errors.New(`upstream said: "my secret prompt"`))
+
+	got := fake.received(t)
+	for _, want := range []string{`"request_id":"a1b2c3d4"`, `"model":"claude-sonnet-4"`, `"provider":"claude"`, `"status":"502"`} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("event lacks %s:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "secret prompt") {
+		t.Fatalf("event leaks the upstream error text:\n%s", got)
+	}
+}
+
+func TestMiddlewareReportsPanicsAndKeepsRecovery(t *testing.T) {
+	fake := newFakeSentry(t)
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, _ any) { c.AbortWithStatus(http.StatusInternalServerError) }))
+	engine.Use(Middleware())
+	engine.POST("/v1/messages", func(c *gin.Context) {
+		logging.SetGinRequestModel(c, "gpt-5")
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"messages":"hello there"}`)))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want the recovery middleware's 500", rec.Code)
+	}
+	got := fake.received(t)
+	if !strings.Contains(got, "panic: boom") || !strings.Contains(got, `"model":"gpt-5"`) || !strings.Contains(got, `"route":"/v1/messages"`) {
+		t.Fatalf("panic event incomplete:\n%s", got)
+	}
+	if strings.Contains(got, "hello there") {
+		t.Fatalf("panic event leaks the request body:\n%s", got)
+	}
+}
+
+func TestWithoutDSNNothingIsReported(t *testing.T) {
+	if err := Configure(config.SentryConfig{}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if Enabled() {
+		t.Fatal("reporting enabled without a DSN")
+	}
+	CaptureError("usage-persistence", errors.New("disk full"))
+	CaptureUpstreamFailure(context.Background(), "codex", "gpt-5", http.StatusBadGateway, errors.New("bad gateway"))
+	if !Flush(context.Background()) {
+		t.Fatal("Flush reported pending events without a client")
+	}
+}
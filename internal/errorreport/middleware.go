@@ -0,0 +1,68 @@
+package errorreport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+// Middleware reports panics raised by later handlers and re-raises them, so the recovery
+// middleware in front of it still logs the panic and answers 500. Install it right after
+// logging.GinLogrusRecovery.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if client.Load() == nil {
+			c.Next()
+			return
+		}
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				if err, ok := recovered.(error); !ok || !errors.Is(err, http.ErrAbortHandler) {
+					capturePanic(c, recovered)
+				}
+				panic(recovered)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// capturePanic reports recovered with the stack of the panicking goroutine, which is still
+// intact while the deferred recover runs.
+func capturePanic(c *gin.Context, recovered any) {
+	sc := client.Load()
+	if sc == nil {
+		return
+	}
+	tags := map[string]string{
+		"method": c.Request.Method,
+		"route":  c.FullPath(),
+	}
+	if requestID := logging.GetGinRequestID(c); requestID != "" {
+		tags["request_id"] = requestID
+	}
+	if model := logging.GetGinRequestModel(c); model != "" {
+		tags["model"] = model
+	}
+	message := fmt.Sprintf("panic: %v", recovered)
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelFatal
+	event.Message = message
+	event.Tags = tags
+	event.Exception = []sentry.Exception{{
+		Type:       fmt.Sprintf("%T", recovered),
+		Value:      fmt.Sprint(recovered),
+		Stacktrace: sentry.NewStacktrace(),
+	}}
+	sc.CaptureEvent(event, nil, nil)
+}
+
+func ginContext(ctx context.Context) *gin.Context {
+	c, _ := ctx.Value("gin").(*gin.Context)
+	return c
+}
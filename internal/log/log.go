@@ -0,0 +1,154 @@
+// Package log is the logger every package of the proxy writes through. It mirrors the
+// package-level logrus functions but sends them to the logger installed with SetLogger,
+// falling back to the logrus standard logger, so an embedding application can keep the
+// proxy's output apart from its own.
+package log
+
+import (
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Aliases of the logrus types that appear in the proxy's signatures.
+type (
+	Entry     = logrus.Entry
+	Fields    = logrus.Fields
+	Formatter = logrus.Formatter
+	Level     = logrus.Level
+)
+
+// Log levels.
+const (
+	PanicLevel = logrus.PanicLevel
+	FatalLevel = logrus.FatalLevel
+	ErrorLevel = logrus.ErrorLevel
+	WarnLevel  = logrus.WarnLevel
+	InfoLevel  = logrus.InfoLevel
+	DebugLevel = logrus.DebugLevel
+	TraceLevel = logrus.TraceLevel
+)
+
+const (
+	facadePackage = "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	logrusPackage = "github.com/sirupsen/logrus"
+)
+
+var injected atomic.Pointer[logrus.Logger]
+
+// SetLogger sends all proxy logging to logger; nil restores the logrus standard logger.
+// The logger's level, formatter and output stay as the caller configured them, and while
+// it is installed nothing in the proxy changes the standard logger. When logger reports
+// callers, a CallerHook is put ahead of its hooks so they see the code that logged.
+func SetLogger(logger *logrus.Logger) {
+	if logger != nil && logger.ReportCaller {
+		AddCallerHook(logger)
+	}
+	injected.Store(logger)
+}
+
+// AddCallerHook installs a CallerHook on logger, ahead of its other hooks, unless it has one.
+func AddCallerHook(logger *logrus.Logger) {
+	hooks := make(logrus.LevelHooks)
+	for _, level := range logrus.AllLevels {
+		hooks[level] = []logrus.Hook{CallerHook{}}
+	}
+	for level, existing := range logger.ReplaceHooks(hooks) {
+		for _, hook := range existing {
+			if _, ok := hook.(CallerHook); !ok {
+				hooks[level] = append(hooks[level], hook)
+			}
+		}
+	}
+}
+
+// Injected reports whether SetLogger installed a logger. Code that configures the global
+// logrus logger, such as its level or output, leaves it alone when this is true.
+func Injected() bool { return injected.Load() != nil }
+
+// Logger returns the logger proxy code writes to.
+func Logger() *logrus.Logger {
+	if logger := injected.Load(); logger != nil {
+		return logger
+	}
+	return logrus.StandardLogger()
+}
+
+// StandardLogger is Logger under its logrus name, so call sites read the same.
+func StandardLogger() *logrus.Logger { return Logger() }
+
+// NewEntry returns an empty entry for logger.
+func NewEntry(logger *logrus.Logger) *Entry { return logrus.NewEntry(logger) }
+
+// IsLevelEnabled reports whether the active logger logs at level.
+func IsLevelEnabled(level Level) bool { return Logger().IsLevelEnabled(level) }
+
+// GetLevel returns the active logger's level.
+func GetLevel() Level { return Logger().GetLevel() }
+
+// WithError returns an entry of the active logger carrying err.
+func WithError(err error) *Entry { return Logger().WithError(err) }
+
+// WithField returns an entry of the active logger carrying one field.
+func WithField(key string, value any) *Entry { return Logger().WithField(key, value) }
+
+// WithFields returns an entry of the active logger carrying fields.
+func WithFields(fields Fields) *Entry { return Logger().WithFields(fields) }
+
+// The functions below log to the active logger like their logrus namesakes.
+
+func Trace(args ...any)                 { Logger().Trace(args...) }
+func Debug(args ...any)                 { Logger().Debug(args...) }
+func Info(args ...any)                  { Logger().Info(args...) }
+func Warn(args ...any)                  { Logger().Warn(args...) }
+func Error(args ...any)                 { Logger().Error(args...) }
+func Fatal(args ...any)                 { Logger().Fatal(args...) }
+func Tracef(format string, args ...any) { Logger().Tracef(format, args...) }
+func Debugf(format string, args ...any) { Logger().Debugf(format, args...) }
+func Infof(format string, args ...any)  { Logger().Infof(format, args...) }
+func Warnf(format string, args ...any)  { Logger().Warnf(format, args...) }
+func Errorf(format string, args ...any) { Logger().Errorf(format, args...) }
+func Fatalf(format string, args ...any) { Logger().Fatalf(format, args...) }
+func Printf(format string, args ...any) { Logger().Printf(format, args...) }
+func Debugln(args ...any)               { Logger().Debugln(args...) }
+func Infoln(args ...any)                { Logger().Infoln(args...) }
+func Warnln(args ...any)                { Logger().Warnln(args...) }
+func Errorln(args ...any)               { Logger().Errorln(args...) }
+func Println(args ...any)               { Logger().Println(args...) }
+
+// CallerHook points the caller logrus records for an entry logged through this package at
+// the function that called it; otherwise every entry would name this file.
+type CallerHook struct{}
+
+// Levels implements logrus.Hook.
+func (CallerHook) Levels() []Level { return logrus.AllLevels }
+
+// Fire implements logrus.Hook.
+func (CallerHook) Fire(entry *Entry) error {
+	if entry.Caller == nil || packageOf(entry.Caller.Function) != facadePackage {
+		return nil
+	}
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if pkg := packageOf(frame.Function); pkg != facadePackage && pkg != logrusPackage {
+			entry.Caller = &frame
+			return nil
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// packageOf returns the package path of a fully qualified function name.
+func packageOf(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
@@ -0,0 +1,502 @@
+package logging
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// AuditUpstreamRequestKey is the Gin context key under which executors store the translated
+// request body of the last upstream attempt while audit logging is enabled.
+const AuditUpstreamRequestKey = "AUDIT_UPSTREAM_REQUEST"
+
+// AuditUsageKey is the Gin context key under which executors store the AuditUsage of the
+// request while audit logging is enabled.
+const AuditUsageKey = "AUDIT_USAGE"
+
+// auditMaxCaptureLine bounds the one incomplete line a capture holds past its limit, so a
+// large inline image can still be recognised and replaced before the capture is cut.
+const auditMaxCaptureLine = 16 << 20
+
+// auditRedacted replaces every match of a redact pattern.
+const auditRedacted = "[REDACTED]"
+
+// auditImagePattern finds inline image data in bodies that are not valid JSON, such as
+// streams and truncated captures.
+var auditImagePattern = regexp.MustCompile(`data:image/[A-Za-z0-9.+-]+;base64,[A-Za-z0-9+/=]+|"(?:data|b64_json)"\s*:\s*"[A-Za-z0-9+/=]{256,}`)
+
+// AuditUsage is the provider account that served a request, by its auth index, and the tokens
+// it reported.
+type AuditUsage struct {
+	Provider        string
+	Account         string
+	InputTokens     int64
+	OutputTokens    int64
+	ReasoningTokens int64
+	CachedTokens    int64
+	TotalTokens     int64
+}
+
+// AuditRecord is one proxied request as captured by the audit middleware. Bodies are raw;
+// the logger redacts them before writing.
+type AuditRecord struct {
+	RequestID string
+	Method    string
+	Path      string
+	Status    int
+	APIKey    string
+	// Model is the model the request resolved to; empty falls back to the request body's.
+	Model           string
+	Usage           *AuditUsage
+	Streaming       bool
+	StartedAt       time.Time
+	FirstByteAt     time.Time
+	FinishedAt      time.Time
+	Request         []byte
+	UpstreamRequest []byte
+	Response        []byte
+	// ResponseRedacted reports that Response was already redacted by an AuditCapture.
+	ResponseRedacted bool
+	// ResponseTruncated reports that the response outgrew the capture buffer.
+	ResponseTruncated bool
+}
+
+// auditDocument is the JSON written for each request.
+type auditDocument struct {
+	RequestID       string         `json:"request_id"`
+	Timestamp       time.Time      `json:"timestamp"`
+	Method          string         `json:"method"`
+	Path            string         `json:"path"`
+	Status          int            `json:"status"`
+	APIKeyHash      string         `json:"api_key_hash,omitempty"`
+	Model           string         `json:"model,omitempty"`
+	Provider        string         `json:"provider,omitempty"`
+	Account         string         `json:"account,omitempty"`
+	Usage           *auditTokens   `json:"usage,omitempty"`
+	PromptHash      string         `json:"prompt_hash,omitempty"`
+	Streaming       bool           `json:"streaming"`
+	DurationMs      int64          `json:"duration_ms"`
+	FirstByteMs     *int64         `json:"first_byte_ms,omitempty"`
+	Request         any            `json:"request,omitempty"`
+	UpstreamRequest any            `json:"upstream_request,omitempty"`
+	Response        any            `json:"response,omitempty"`
+	Truncated       map[string]any `json:"truncated,omitempty"`
+}
+
+type auditTokens struct {
+	InputTokens     int64 `json:"input_tokens"`
+	OutputTokens    int64 `json:"output_tokens"`
+	ReasoningTokens int64 `json:"reasoning_tokens,omitempty"`
+	CachedTokens    int64 `json:"cached_tokens,omitempty"`
+	TotalTokens     int64 `json:"total_tokens"`
+}
+
+// AuditLogger writes audit records as JSON lines to a dedicated rotated file.
+type AuditLogger struct {
+	mu       sync.RWMutex
+	enabled  bool
+	writer   *lumberjack.Logger
+	patterns []*regexp.Regexp
+	images   bool
+	limit    int
+	fields   map[string]struct{}
+	omit     bool
+	hash     bool
+}
+
+var defaultAuditLogger = &AuditLogger{}
+
+// DefaultAuditLogger returns the audit logger the server middleware writes to.
+func DefaultAuditLogger() *AuditLogger { return defaultAuditLogger }
+
+// ConfigureAuditLog applies the audit-log settings to the default audit logger.
+func ConfigureAuditLog(cfg *config.Config) error {
+	return defaultAuditLogger.Configure(cfg)
+}
+
+// Configure applies cfg.AuditLog. Invalid redact patterns leave audit logging disabled, so
+// bodies are never written unredacted.
+func (l *AuditLogger) Configure(cfg *config.Config) error {
+	var settings config.AuditLogConfig
+	if cfg != nil {
+		settings = cfg.AuditLog
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.writer != nil {
+		_ = l.writer.Close()
+		l.writer = nil
+	}
+	l.enabled = false
+	if !settings.Enabled {
+		return nil
+	}
+	patterns, err := settings.CompileRedactPatterns()
+	if err != nil {
+		return err
+	}
+	path := strings.TrimSpace(settings.File)
+	if path == "" {
+		path = filepath.Join(ResolveLogDirectory(cfg), "audit", "audit.jsonl")
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("audit-log: create directory: %w", err)
+	}
+	l.writer = &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    settings.MaxSize(),
+		MaxBackups: settings.Backups(),
+	}
+	l.patterns, l.images, l.limit = patterns, settings.StripImages, settings.BodyLimit()
+	l.fields = make(map[string]struct{}, len(settings.RedactFields))
+	for _, field := range settings.RedactFields {
+		if field = strings.TrimSpace(field); field != "" {
+			l.fields[field] = struct{}{}
+		}
+	}
+	l.omit, l.hash = settings.OmitBodies, settings.PromptHash
+	l.enabled = true
+	return nil
+}
+
+// Enabled reports whether requests are audited.
+func (l *AuditLogger) Enabled() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.enabled
+}
+
+// CaptureLimit is how many response bytes an AuditCapture keeps for one record. It leaves
+// room above the body limit for data that redaction removes, such as images, and is zero
+// when bodies are omitted.
+func (l *AuditLogger) CaptureLimit() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.omit {
+		return 0
+	}
+	return 4 * l.limit
+}
+
+// AuditCapture collects a response for the audit log as it is written. While the response
+// fits the capture limit it is kept raw, so Write redacts it as one document. Past that, each
+// complete line is redacted as it arrives and kept while the redacted text fits, so the cut
+// never falls inside data that redaction would have removed. A line is held whole up to
+// auditMaxCaptureLine; one that does not fit ends the capture rather than being kept in part.
+type AuditCapture struct {
+	logger    *AuditLogger
+	limit     int
+	raw       []byte
+	redacted  []byte
+	lines     bool
+	truncated bool
+}
+
+// NewCapture returns a capture sized by CaptureLimit. It keeps nothing when bodies are omitted.
+func (l *AuditLogger) NewCapture() *AuditCapture {
+	return &AuditCapture{logger: l, limit: l.CaptureLimit()}
+}
+
+// Write adds data written to the client.
+func (c *AuditCapture) Write(data []byte) {
+	if c.limit <= 0 || c.truncated || len(data) == 0 {
+		return
+	}
+	c.raw = append(c.raw, data...)
+	if !c.lines && len(c.raw) <= c.limit {
+		return
+	}
+	c.lines = true
+	c.drain(false)
+}
+
+// Result returns the captured response, whether it is already redacted, and whether part of
+// it was dropped.
+func (c *AuditCapture) Result() (body []byte, redacted, truncated bool) {
+	if !c.lines {
+		return c.raw, false, c.truncated
+	}
+	c.drain(true)
+	return c.redacted, true, c.truncated
+}
+
+// drain redacts the complete lines held in raw, and the partial last one when final.
+func (c *AuditCapture) drain(final bool) {
+	for !c.truncated {
+		i := bytes.IndexByte(c.raw, '\n')
+		if i < 0 {
+			break
+		}
+		c.keep(c.raw[:i+1])
+		c.raw = c.raw[i+1:]
+	}
+	switch {
+	case c.truncated:
+	case final && len(c.raw) > 0:
+		c.keep(c.raw)
+	case len(c.raw) > auditMaxCaptureLine:
+		c.truncated = true
+	default:
+		return
+	}
+	c.raw = nil
+}
+
+func (c *AuditCapture) keep(line []byte) {
+	c.logger.mu.RLock()
+	redacted := c.logger.redactText(line)
+	c.logger.mu.RUnlock()
+	if len(c.redacted)+len(redacted) > c.limit {
+		c.truncated = true
+		return
+	}
+	c.redacted = append(c.redacted, redacted...)
+}
+
+// Write redacts record and appends it to the audit log.
+func (l *AuditLogger) Write(record AuditRecord) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if !l.enabled || l.writer == nil {
+		return nil
+	}
+	doc := auditDocument{
+		RequestID:  record.RequestID,
+		Timestamp:  record.StartedAt.UTC(),
+		Method:     record.Method,
+		Path:       record.Path,
+		Status:     record.Status,
+		Model:      record.Model,
+		Streaming:  record.Streaming,
+		DurationMs: record.FinishedAt.Sub(record.StartedAt).Milliseconds(),
+	}
+	if doc.Model == "" {
+		doc.Model = gjson.GetBytes(record.Request, "model").String()
+	}
+	if usage := record.Usage; usage != nil {
+		doc.Provider, doc.Account = usage.Provider, usage.Account
+		doc.Usage = &auditTokens{
+			InputTokens:     usage.InputTokens,
+			OutputTokens:    usage.OutputTokens,
+			ReasoningTokens: usage.ReasoningTokens,
+			CachedTokens:    usage.CachedTokens,
+			TotalTokens:     usage.TotalTokens,
+		}
+	}
+	if l.hash && len(record.Request) > 0 {
+		sum := sha256.Sum256(record.Request)
+		doc.PromptHash = hex.EncodeToString(sum[:])
+	}
+	if record.APIKey != "" {
+		sum := sha256.Sum256([]byte(record.APIKey))
+		doc.APIKeyHash = hex.EncodeToString(sum[:])
+	}
+	if !record.FirstByteAt.IsZero() {
+		firstByte := record.FirstByteAt.Sub(record.StartedAt).Milliseconds()
+		doc.FirstByteMs = &firstByte
+	}
+	if !l.omit {
+		truncated := make(map[string]any)
+		var cut bool
+		if doc.Request, cut = l.body(record.Request, false); cut {
+			truncated["request"] = true
+		}
+		if doc.UpstreamRequest, cut = l.body(record.UpstreamRequest, false); cut {
+			truncated["upstream_request"] = true
+		}
+		if doc.Response, cut = l.body(record.Response, record.ResponseRedacted); cut || record.ResponseTruncated {
+			truncated["response"] = true
+		}
+		if len(truncated) > 0 {
+			doc.Truncated = truncated
+		}
+	}
+
+	line, err := l.encode(doc)
+	if err != nil {
+		return fmt.Errorf("audit-log: encode record: %w", err)
+	}
+	if _, err = l.writer.Write(line); err != nil {
+		return fmt.Errorf("audit-log: write record: %w", err)
+	}
+	return nil
+}
+
+// encode returns doc as one JSON line with the fields named by redact-fields redacted.
+func (l *AuditLogger) encode(doc auditDocument) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if len(l.fields) == 0 {
+		return buf.Bytes(), nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		return nil, err
+	}
+	redacted, _ := json.Marshal(auditRedacted)
+	for name := range fields {
+		if _, ok := l.fields[name]; ok {
+			fields[name] = redacted
+		}
+	}
+	buf.Reset()
+	if err := enc.Encode(fields); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Close closes the audit log file.
+func (l *AuditLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enabled = false
+	if l.writer == nil {
+		return nil
+	}
+	err := l.writer.Close()
+	l.writer = nil
+	return err
+}
+
+// body redacts data, unless it already is, and caps it at the body limit. A JSON body that
+// fits is embedded as JSON; anything else, including streams, is recorded as a string.
+func (l *AuditLogger) body(data []byte, redactedAlready bool) (any, bool) {
+	if len(data) == 0 {
+		return nil, false
+	}
+	var redacted []byte
+	trimmed := bytes.TrimSpace(data)
+	if redactedAlready {
+		redacted = data
+	} else if json.Valid(trimmed) {
+		redacted = l.redactJSON(trimmed)
+	} else {
+		redacted = l.redactText(data)
+	}
+	if len(redacted) <= l.limit {
+		if json.Valid(redacted) {
+			return json.RawMessage(redacted), false
+		}
+		return string(redacted), false
+	}
+	cut := redacted[:l.limit]
+	for len(cut) > 0 && !utf8.Valid(cut) {
+		cut = cut[:len(cut)-1]
+	}
+	return string(cut), true
+}
+
+// redactText handles bodies that are not one JSON document. Server-sent event data lines
+// holding JSON are redacted field by field; everything else by pattern.
+func (l *AuditLogger) redactText(data []byte) []byte {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for i, line := range lines {
+		content := bytes.TrimRight(line, "\r\n")
+		ending := line[len(content):]
+		if payload, ok := bytes.CutPrefix(content, []byte("data:")); ok {
+			if trimmed := bytes.TrimSpace(payload); json.Valid(trimmed) && len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+				lines[i] = append(append([]byte("data: "), l.redactJSON(trimmed)...), ending...)
+				continue
+			}
+		}
+		lines[i] = append([]byte(l.redactString(string(content))), ending...)
+	}
+	return bytes.Join(lines, nil)
+}
+
+func (l *AuditLogger) redactJSON(data []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return []byte(l.redactString(string(data)))
+	}
+	value = l.redactValue(value)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return []byte(l.redactString(string(data)))
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n")
+}
+
+func (l *AuditLogger) redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		inlineImage := hasAnyKey(v, "mime_type", "mimeType", "media_type")
+		for key, item := range v {
+			if _, ok := l.fields[key]; ok {
+				v[key] = auditRedacted
+				continue
+			}
+			if s, ok := item.(string); ok && l.images && (key == "b64_json" || (key == "data" && inlineImage)) {
+				v[key] = imagePlaceholder(len(s))
+				continue
+			}
+			v[key] = l.redactValue(item)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = l.redactValue(item)
+		}
+		return v
+	case string:
+		return l.redactString(v)
+	default:
+		return value
+	}
+}
+
+func (l *AuditLogger) redactString(s string) string {
+	if l.images {
+		s = auditImagePattern.ReplaceAllStringFunc(s, func(match string) string {
+			if strings.HasPrefix(match, "data:") {
+				prefix, payload, _ := strings.Cut(match, ",")
+				return prefix + "," + imagePlaceholder(len(payload))
+			}
+			name, payload, _ := strings.Cut(match, ":")
+			payload = strings.TrimLeft(payload, " \t\"")
+			return name + `:"` + imagePlaceholder(len(payload))
+		})
+	}
+	for _, re := range l.patterns {
+		s = re.ReplaceAllString(s, auditRedacted)
+	}
+	return s
+}
+
+func imagePlaceholder(size int) string {
+	return fmt.Sprintf("[image data redacted: %d bytes]", size)
+}
+
+func hasAnyKey(m map[string]any, keys ...string) bool {
+	for _, key := range keys {
+		if _, ok := m[key]; ok {
+			return true
+		}
+	}
+	return false
+}
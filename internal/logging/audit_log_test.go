@@ -0,0 +1,196 @@
+package logging
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestAuditLoggerRedactsBodies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logger := &AuditLogger{}
+	err := logger.Configure(&config.Config{AuditLog: config.AuditLogConfig{
+		Enabled:        true,
+		File:           path,
+		MaxBodyBytes:   4096,
+		StripImages:    true,
+		RedactPatterns: []string{`\b(?:\d[ -]?){13,16}\b`},
+	}})
+	if err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	defer func() { _ = logger.Close() }()
+
+	image := strings.Repeat("QUJD", 100)
+	started := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	err = logger.Write(AuditRecord{
+		RequestID:  "a1b2c3d4",
+		Method:     "POST",
+		Path:       "/v1/chat/completions",
+		Status:     200,
+		APIKey:     "sk-client",
+		Streaming:  true,
+		StartedAt:  started,
+		FinishedAt: started.Add(1500 * time.Millisecond),
+		Request: []byte(`{"model":"gpt-5","messages":[{"role":"user","content":[` +
+			`{"type":"text","text":"card 4111 1111 1111 1111 <b>"},` +
+			`{"type":"image_url","image_url":{"url":"data:image/png;base64,` + image + `"}}]}]}`),
+		UpstreamRequest: []byte(`{"contents":[{"parts":[{"inlineData":{"mimeType":"image/png","data":"` + image + `"}}]}]}`),
+		Response:        []byte("data: {\"choices\":[{\"delta\":{\"content\":\"4111111111111111\"}}]}\n\ndata: [DONE]\n\n"),
+	})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	out := string(data)
+	for _, secret := range []string{"4111", image, "sk-client"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("audit record leaks %q:\n%s", secret, out)
+		}
+	}
+	var doc map[string]any
+	if err = json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("one JSON document per line expected: %v\n%s", err, out)
+	}
+	if doc["model"] != "gpt-5" || doc["duration_ms"] != float64(1500) || doc["api_key_hash"] == "" {
+		t.Fatalf("unexpected metadata: %v", doc)
+	}
+	if _, ok := doc["request"].(map[string]any); !ok {
+		t.Fatalf("JSON request should be embedded as JSON, got %T", doc["request"])
+	}
+	if !strings.Contains(out, "[image data redacted: 400 bytes]") || !strings.Contains(out, "<b>") {
+		t.Fatalf("images should be replaced by their size and text kept verbatim:\n%s", out)
+	}
+	if response, _ := doc["response"].(string); !strings.Contains(response, `data: {"choices":[{"delta":{"content":"[REDACTED]"}}]}`) {
+		t.Fatalf("stream events should be redacted in place: %q", response)
+	}
+}
+
+func TestAuditLoggerCapsBodies(t *testing.T) {
+	logger := &AuditLogger{}
+	if err := logger.Configure(&config.Config{AuditLog: config.AuditLogConfig{Enabled: true, File: filepath.Join(t.TempDir(), "audit.jsonl"), MaxBodyBytes: 16}}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	defer func() { _ = logger.Close() }()
+	body, truncated := logger.body([]byte(`{"prompt":"`+strings.Repeat("é", 20)+`"}`), false)
+	if s, ok := body.(string); !ok || !truncated || len(s) > 16 || !strings.HasPrefix(s, `{"prompt":"`) {
+		t.Fatalf("body = %q, truncated %t", body, truncated)
+	}
+}
+
+func TestAuditCaptureRedactsBeforeCapping(t *testing.T) {
+	logger := &AuditLogger{}
+	err := logger.Configure(&config.Config{AuditLog: config.AuditLogConfig{
+		Enabled:        true,
+		File:           filepath.Join(t.TempDir(), "audit.jsonl"),
+		MaxBodyBytes:   64,
+		StripImages:    true,
+		RedactPatterns: []string{`\b\d{16}\b`},
+	}})
+	if err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	defer func() { _ = logger.Close() }()
+
+	// An image that outgrows the capture buffer is still recognised and replaced.
+	image := `data: {"data":[{"b64_json":"` + strings.Repeat("QUJD", 200) + `"}]}` + "\n\n"
+	capture := logger.NewCapture()
+	capture.Write([]byte(image[:300]))
+	capture.Write([]byte(image[300:]))
+	body, redacted, truncated := capture.Result()
+	if !redacted || truncated || !strings.Contains(string(body), "[image data redacted: 800 bytes]") {
+		t.Fatalf("capture = %q, redacted %t, truncated %t", body, redacted, truncated)
+	}
+
+	// The cut falls between lines, never inside a secret.
+	capture = logger.NewCapture()
+	for i := 0; i < 20; i++ {
+		capture.Write([]byte("data: {\"content\":\"4111111111111111\"}\n"))
+	}
+	body, redacted, truncated = capture.Result()
+	if !redacted || !truncated || strings.Contains(string(body), "4111") || !strings.HasSuffix(string(body), "\n") {
+		t.Fatalf("capture = %q, redacted %t, truncated %t", body, redacted, truncated)
+	}
+
+	// A response that fits is left for Write to redact as one document.
+	capture = logger.NewCapture()
+	capture.Write([]byte(`{"id":"1"}`))
+	if body, redacted, truncated = capture.Result(); string(body) != `{"id":"1"}` || redacted || truncated {
+		t.Fatalf("capture = %q, redacted %t, truncated %t", body, redacted, truncated)
+	}
+}
+
+func TestAuditLoggerRejectsInvalidPatterns(t *testing.T) {
+	logger := &AuditLogger{}
+	err := logger.Configure(&config.Config{AuditLog: config.AuditLogConfig{Enabled: true, File: filepath.Join(t.TempDir(), "audit.jsonl"), RedactPatterns: []string{"("}}})
+	if err == nil || logger.Enabled() {
+		t.Fatalf("an invalid pattern must keep audit logging off, err = %v", err)
+	}
+}
+
+func TestAuditLoggerRecordsMetadataWithRedactedFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logger := &AuditLogger{}
+	err := logger.Configure(&config.Config{AuditLog: config.AuditLogConfig{
+		Enabled:      true,
+		File:         path,
+		OmitBodies:   true,
+		PromptHash:   true,
+		RedactFields: []string{"account"},
+	}})
+	if err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	defer func() { _ = logger.Close() }()
+	if logger.CaptureLimit() != 0 {
+		t.Fatalf("responses are captured although bodies are omitted")
+	}
+
+	request := []byte(`{"model":"gpt-5","messages":[{"role":"user","content":"secret prompt"}]}`)
+	started := time.Now()
+	err = logger.Write(AuditRecord{
+		Method:     "POST",
+		Path:       "/v1/chat/completions",
+		Status:     200,
+		Model:      "gpt-5-mini",
+		Usage:      &AuditUsage{Provider: "codex", Account: "a1b2c3", InputTokens: 12, OutputTokens: 30, TotalTokens: 42},
+		StartedAt:  started,
+		FinishedAt: started.Add(time.Second),
+		Request:    request,
+		Response:   []byte(`{"choices":[]}`),
+	})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	if strings.Contains(string(data), "secret prompt") || strings.Contains(string(data), "a1b2c3") {
+		t.Fatalf("audit record leaks a body or a redacted field:\n%s", data)
+	}
+	var doc struct {
+		Model      string         `json:"model"`
+		Provider   string         `json:"provider"`
+		Account    string         `json:"account"`
+		Usage      map[string]int `json:"usage"`
+		PromptHash string         `json:"prompt_hash"`
+		Request    any            `json:"request"`
+	}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decode: %v\n%s", err, data)
+	}
+	if doc.Model != "gpt-5-mini" || doc.Provider != "codex" || doc.Account != "[REDACTED]" || doc.Usage["total_tokens"] != 42 || len(doc.PromptHash) != 64 || doc.Request != nil {
+		t.Fatalf("unexpected record: %+v", doc)
+	}
+}
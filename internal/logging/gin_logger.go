@@ -12,8 +12,8 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
-	log "github.com/sirupsen/logrus"
 )
 
 // aiAPIPrefixes defines path prefixes for AI API requests that should have request ID tracking.
@@ -83,6 +83,12 @@ func GinLogrusLogger() gin.HandlerFunc {
 		}
 
 		entry := log.WithField("request_id", requestID)
+		if statusCode >= http.StatusBadRequest {
+			// Successful lines may be sampled away, so failures carry the model themselves.
+			if model := GetGinRequestModel(c); model != "" {
+				entry = entry.WithField("model", model)
+			}
+		}
 
 		switch {
 		case statusCode >= http.StatusInternalServerError:
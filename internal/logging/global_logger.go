@@ -11,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	applog "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -20,6 +21,7 @@ var (
 	setupOnce      sync.Once
 	writerMu       sync.Mutex
 	logWriter      *lumberjack.Logger
+	logConn        io.Closer
 	ginInfoWriter  *io.PipeWriter
 	ginErrorWriter *io.PipeWriter
 )
@@ -82,25 +84,36 @@ func (m *LogFormatter) Format(entry *log.Entry) ([]byte, error) {
 
 // SetupBaseLogger configures the shared logrus instance and Gin writers.
 // It is safe to call multiple times; initialization happens only once.
+// When an embedder installed its own logger, Gin writes to that logger and the shared
+// instance is left untouched.
 func SetupBaseLogger() {
 	setupOnce.Do(func() {
+		if applog.Injected() {
+			setupGinWriters(applog.Logger())
+			return
+		}
 		log.SetOutput(os.Stdout)
 		log.SetReportCaller(true)
-		log.SetFormatter(&LogFormatter{})
-
-		ginInfoWriter = log.StandardLogger().Writer()
-		gin.DefaultWriter = ginInfoWriter
-		ginErrorWriter = log.StandardLogger().WriterLevel(log.ErrorLevel)
-		gin.DefaultErrorWriter = ginErrorWriter
-		gin.DebugPrintFunc = func(format string, values ...interface{}) {
-			format = strings.TrimRight(format, "\r\n")
-			log.StandardLogger().Infof(format, values...)
-		}
+		log.SetFormatter(logSampler)
+		applog.AddCallerHook(log.StandardLogger())
+		setupGinWriters(log.StandardLogger())
 
 		log.RegisterExitHandler(closeLogOutputs)
 	})
 }
 
+// setupGinWriters sends Gin's output to logger.
+func setupGinWriters(logger *log.Logger) {
+	ginInfoWriter = logger.Writer()
+	gin.DefaultWriter = ginInfoWriter
+	ginErrorWriter = logger.WriterLevel(log.ErrorLevel)
+	gin.DefaultErrorWriter = ginErrorWriter
+	gin.DebugPrintFunc = func(format string, values ...interface{}) {
+		format = strings.TrimRight(format, "\r\n")
+		logger.Infof(format, values...)
+	}
+}
+
 // isDirWritable checks if the specified directory exists and is writable by attempting to create and remove a test file.
 func isDirWritable(dir string) bool {
 	info, err := os.Stat(dir)
@@ -139,25 +152,36 @@ func ResolveLogDirectory(cfg *config.Config) string {
 	return logDir
 }
 
-// ConfigureLogOutput switches the global log destination between rotating files and stdout.
+// ConfigureLogOutput switches the global log destination between stdout, rotating files,
+// syslog and journald. When the syslog or journald socket cannot be reached, logs go to stderr
+// with a warning instead.
 // When logsMaxTotalSizeMB > 0, a background cleaner removes the oldest log files in the logs directory
 // until the total size is within the limit.
+// It does nothing when an embedder installed its own logger, whose output is its own.
 func ConfigureLogOutput(cfg *config.Config) error {
 	SetupBaseLogger()
+	if applog.Injected() {
+		return nil
+	}
 
 	writerMu.Lock()
 	defer writerMu.Unlock()
 
 	logDir := ResolveLogDirectory(cfg)
-
-	protectedPath := ""
-	if cfg.LoggingToFile {
+	output := cfg.LogOutput()
+	if output == config.LogOutputFile {
 		if err := os.MkdirAll(logDir, 0o755); err != nil {
 			return fmt.Errorf("logging: failed to create log directory: %w", err)
 		}
-		if logWriter != nil {
-			_ = logWriter.Close()
-		}
+	}
+	// The previous outputs are closed only once the logger writes elsewhere.
+	previousFile, previousConn := logWriter, logConn
+	logWriter, logConn = nil, nil
+
+	var fallback error
+	protectedPath := ""
+	switch output {
+	case config.LogOutputFile:
 		protectedPath = filepath.Join(logDir, "main.log")
 		logWriter = &lumberjack.Logger{
 			Filename:   protectedPath,
@@ -166,29 +190,67 @@ func ConfigureLogOutput(cfg *config.Config) error {
 			MaxAge:     0,
 			Compress:   false,
 		}
+		logSampler.setFormatter(&LogFormatter{})
 		log.SetOutput(logWriter)
-	} else {
-		if logWriter != nil {
-			_ = logWriter.Close()
-			logWriter = nil
+	case config.LogOutputSyslog:
+		writer, err := dialSyslog(cfg.Logging.Syslog)
+		if err != nil {
+			fallback = err
+			break
+		}
+		logConn = writer
+		logSampler.setFormatter(newSyslogFormatter(cfg.Logging.Syslog))
+		log.SetOutput(writer)
+	case config.LogOutputJournald:
+		writer, err := dialJournald()
+		if err != nil {
+			fallback = err
+			break
 		}
+		logConn = writer
+		logSampler.setFormatter(&journaldFormatter{identifier: cfg.Logging.Syslog.TagOrDefault()})
+		log.SetOutput(writer)
+	case config.LogOutputStdout:
+		logSampler.setFormatter(&LogFormatter{})
 		log.SetOutput(os.Stdout)
+	default:
+		fallback = fmt.Errorf("unknown output %q", output)
+	}
+	if fallback != nil {
+		logSampler.setFormatter(&LogFormatter{})
+		log.SetOutput(os.Stderr)
+		log.Warnf("logging: %s output unavailable, writing logs to stderr: %v", output, fallback)
+	}
+	if previousFile != nil {
+		_ = previousFile.Close()
+	}
+	if previousConn != nil {
+		_ = previousConn.Close()
 	}
 
 	configureLogDirCleanerLocked(logDir, cfg.LogsMaxTotalSizeMB, protectedPath)
 	return nil
 }
 
+// closeLogWritersLocked closes the current file or socket output.
+func closeLogWritersLocked() {
+	if logWriter != nil {
+		_ = logWriter.Close()
+		logWriter = nil
+	}
+	if logConn != nil {
+		_ = logConn.Close()
+		logConn = nil
+	}
+}
+
 func closeLogOutputs() {
 	writerMu.Lock()
 	defer writerMu.Unlock()
 
 	stopLogDirCleanerLocked()
-
-	if logWriter != nil {
-		_ = logWriter.Close()
-		logWriter = nil
-	}
+	closeLogWritersLocked()
+	_ = defaultAuditLogger.Close()
 	if ginInfoWriter != nil {
 		_ = ginInfoWriter.Close()
 		ginInfoWriter = nil
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// journaldFormatter renders entries in the journal's native protocol: one FIELD=value pair per
+// line, with log fields as their own upper-case journal fields.
+type journaldFormatter struct {
+	identifier string
+}
+
+func (f *journaldFormatter) Format(entry *log.Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	journalField(&buf, "MESSAGE", strings.TrimRight(entry.Message, "\r\n"))
+	journalField(&buf, "PRIORITY", strconv.Itoa(syslogSeverity(entry.Level)))
+	journalField(&buf, "SYSLOG_IDENTIFIER", f.identifier)
+	if entry.Caller != nil {
+		journalField(&buf, "CODE_FILE", filepath.Base(entry.Caller.File))
+		journalField(&buf, "CODE_LINE", strconv.Itoa(entry.Caller.Line))
+		journalField(&buf, "CODE_FUNC", entry.Caller.Function)
+	}
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		journalField(&buf, journalFieldName(key), fmt.Sprint(entry.Data[key]))
+	}
+	return buf.Bytes(), nil
+}
+
+// journalField appends one field. Values containing a newline use the binary form, a
+// little-endian 64-bit length followed by the raw value.
+func journalField(buf *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		buf.WriteString(name + "=" + value + "\n")
+		return
+	}
+	buf.WriteString(name + "\n")
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value + "\n")
+}
+
+// journalFieldName maps a log field key to a journal field name: upper-case letters, digits
+// and underscores, not starting with an underscore or digit, since those are reserved.
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	if name == "" || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		name = "F" + name
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
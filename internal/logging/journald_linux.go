@@ -0,0 +1,49 @@
+//go:build linux
+
+package logging
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+// journalSocket is where journald accepts native protocol datagrams.
+const journalSocket = "/run/systemd/journal/socket"
+
+// journaldWriter sends each formatted entry to journald as one datagram.
+type journaldWriter struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func dialJournald() (*journaldWriter, error) {
+	conn, err := net.Dial("unixgram", journalSocket)
+	if err != nil {
+		return nil, fmt.Errorf("journald: %w", err)
+	}
+	return &journaldWriter{conn: conn}, nil
+}
+
+func (w *journaldWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return 0, net.ErrClosed
+	}
+	return w.conn.Write(p)
+}
+
+func (w *journaldWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
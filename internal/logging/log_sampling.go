@@ -0,0 +1,157 @@
+package logging
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// samplingSummaryField marks the summary lines the sampler writes itself, which are never sampled.
+const samplingSummaryField = "log_sampling_summary"
+
+// logSampler is the formatter installed on the standard logger by SetupBaseLogger.
+var logSampler = newSamplingFormatter(&LogFormatter{})
+
+// samplingFormatter drops routine lines before they are formatted. Decisions are made per log
+// site, the file and line of the logging call, so a busy site such as the request logger is
+// thinned out without hiding rare lines elsewhere. Warnings and errors always pass.
+type samplingFormatter struct {
+	next log.Formatter
+
+	mu       sync.Mutex
+	rate     int
+	burst    int
+	interval time.Duration
+	sites    map[string]*sampledSite
+	stop     chan struct{}
+}
+
+// sampledSite counts the lines one log site produced in the current interval.
+type sampledSite struct {
+	seen    int
+	skipped int
+}
+
+func newSamplingFormatter(next log.Formatter) *samplingFormatter {
+	return &samplingFormatter{next: next, sites: make(map[string]*sampledSite)}
+}
+
+// ConfigureLogSampling applies the log-sampling settings to the standard logger.
+func ConfigureLogSampling(cfg config.LogSamplingConfig) {
+	rate := cfg.Rate
+	if !cfg.Enabled() {
+		rate = 0
+	}
+	logSampler.configure(rate, cfg.BurstSize(), cfg.IntervalDuration())
+}
+
+// configure replaces the sampling settings. Lines skipped so far are summarized first, and a
+// rate of 1 or less stops sampling.
+func (s *samplingFormatter) configure(rate, burst int, interval time.Duration) {
+	s.mu.Lock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	previous := s.interval
+	skipped := s.resetLocked()
+	s.rate, s.burst, s.interval = rate, burst, interval
+	if rate > 1 {
+		s.stop = make(chan struct{})
+		go s.run(interval, s.stop)
+	}
+	s.mu.Unlock()
+	writeSamplingSummaries(skipped, previous)
+}
+
+// Format writes entry through the wrapped formatter unless it is sampled out, in which case it
+// returns no bytes and the logger writes nothing.
+func (s *samplingFormatter) Format(entry *log.Entry) ([]byte, error) {
+	if s.keep(entry) {
+		return s.formatter().Format(entry)
+	}
+	return nil, nil
+}
+
+// setFormatter replaces the formatter that renders the lines which are kept.
+func (s *samplingFormatter) setFormatter(next log.Formatter) {
+	s.mu.Lock()
+	s.next = next
+	s.mu.Unlock()
+}
+
+func (s *samplingFormatter) formatter() log.Formatter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next
+}
+
+func (s *samplingFormatter) keep(entry *log.Entry) bool {
+	if entry.Level < log.InfoLevel || entry.Caller == nil {
+		return true
+	}
+	if _, ok := entry.Data[samplingSummaryField]; ok {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rate <= 1 {
+		return true
+	}
+	key := fmt.Sprintf("%s:%d", filepath.Base(entry.Caller.File), entry.Caller.Line)
+	site, ok := s.sites[key]
+	if !ok {
+		site = &sampledSite{}
+		s.sites[key] = site
+	}
+	site.seen++
+	if site.seen <= s.burst || (site.seen-s.burst)%s.rate == 0 {
+		return true
+	}
+	site.skipped++
+	return false
+}
+
+func (s *samplingFormatter) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			skipped := s.resetLocked()
+			s.mu.Unlock()
+			writeSamplingSummaries(skipped, interval)
+		}
+	}
+}
+
+// resetLocked starts a new interval and returns how many lines each site skipped in the last one.
+func (s *samplingFormatter) resetLocked() map[string]int {
+	skipped := make(map[string]int)
+	for key, site := range s.sites {
+		if site.skipped > 0 {
+			skipped[key] = site.skipped
+		}
+	}
+	s.sites = make(map[string]*sampledSite)
+	return skipped
+}
+
+func writeSamplingSummaries(skipped map[string]int, interval time.Duration) {
+	keys := make([]string, 0, len(skipped))
+	for key := range skipped {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		log.WithField(samplingSummaryField, true).Infof("skipped %d similar entries from %s in the last %s", skipped[key], key, interval)
+	}
+}
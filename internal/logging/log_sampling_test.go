@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestSamplingFormatterKeepsWarningsAndSamplesInfo(t *testing.T) {
+	sampler := newSamplingFormatter(&LogFormatter{})
+	sampler.configure(100, 10, time.Hour)
+	defer sampler.configure(0, 10, time.Hour)
+
+	var out bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	logger.SetReportCaller(true)
+	logger.SetFormatter(sampler)
+	for i := 0; i < 1000; i++ {
+		logger.WithField("request_id", "a1b2c3d4").Info("200 | ok")
+		logger.WithField("request_id", "a1b2c3d4").Warn("429 | limited")
+	}
+	logger.Info("elsewhere")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	var infos, warns, elsewhere int
+	for _, line := range lines {
+		switch {
+		case strings.Contains(line, "200 | ok"):
+			infos++
+		case strings.Contains(line, "429 | limited"):
+			warns++
+		case strings.Contains(line, "elsewhere"):
+			elsewhere++
+		}
+	}
+	// The burst of 10, then every 100th of the remaining 990.
+	if infos != 19 {
+		t.Fatalf("kept %d info lines, want 19", infos)
+	}
+	if warns != 1000 || elsewhere != 1 {
+		t.Fatalf("kept %d warnings and %d lines from another site, want 1000 and 1", warns, elsewhere)
+	}
+
+	sampler.mu.Lock()
+	skipped := sampler.resetLocked()
+	sampler.mu.Unlock()
+	if len(skipped) != 1 {
+		t.Fatalf("skipped lines from %d sites, want 1: %v", len(skipped), skipped)
+	}
+	for site, n := range skipped {
+		if !strings.HasPrefix(site, "log_sampling_test.go:") || n != 981 {
+			t.Fatalf("skipped %d lines from %s, want 981 from log_sampling_test.go", n, site)
+		}
+	}
+}
+
+func TestSamplingFormatterDisabledKeepsEverything(t *testing.T) {
+	sampler := newSamplingFormatter(&LogFormatter{})
+	var out bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	logger.SetReportCaller(true)
+	logger.SetFormatter(sampler)
+	for i := 0; i < 50; i++ {
+		logger.Info("line")
+	}
+	if got := strings.Count(out.String(), "\n"); got != 50 {
+		t.Fatalf("wrote %d lines with sampling off, want 50", got)
+	}
+}
+
+func TestGinLogrusLoggerAddsModelToFailedRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var out bytes.Buffer
+	previous := log.StandardLogger().Out
+	log.SetOutput(&out)
+	previousFormatter := log.StandardLogger().Formatter
+	defer func() {
+		log.SetOutput(previous)
+		log.SetFormatter(previousFormatter)
+	}()
+	log.SetFormatter(&LogFormatter{})
+
+	engine := gin.New()
+	engine.Use(GinLogrusLogger())
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		SetGinRequestModel(c, "gpt-5")
+		if c.Query("fail") != "" {
+			c.Status(http.StatusBadGateway)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	for _, target := range []string{"/v1/chat/completions", "/v1/chat/completions?fail=1"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, target, nil))
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2:\n%s", len(lines), out.String())
+	}
+	if strings.Contains(lines[0], "model=") {
+		t.Fatalf("successful request line should stay unchanged: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "model=gpt-5") || strings.Contains(lines[1], "[--------]") {
+		t.Fatalf("failed request line should carry request id and model: %s", lines[1])
+	}
+}
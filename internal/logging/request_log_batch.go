@@ -0,0 +1,186 @@
+package logging
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+const (
+	// requestLogFlushInterval bounds how long a rendered request log waits in memory.
+	requestLogFlushInterval = 200 * time.Millisecond
+	// requestLogFlushBytes starts a flush early once this much log data is buffered.
+	requestLogFlushBytes = 4 << 20
+	// requestLogMaxPendingBytes caps buffered log data while the disk falls behind; logs that
+	// would exceed it are dropped instead of holding up requests.
+	requestLogMaxPendingBytes = 64 << 20
+)
+
+// requestLogEntry is one rendered request log waiting to be written to its own file.
+type requestLogEntry struct {
+	path string
+	data []byte
+	// forcedError marks error logs written while request logging is off, whose count is capped.
+	forcedError bool
+}
+
+// requestLogBatch collects rendered request logs and writes them from a single worker, either
+// every interval or as soon as flushBytes are pending. Request handling only appends to the
+// batch and never waits on the disk.
+type requestLogBatch struct {
+	interval   time.Duration
+	flushBytes int
+	maxPending int
+
+	mu           sync.Mutex
+	pending      []requestLogEntry
+	pendingBytes int
+	started      bool
+	stopped      bool
+
+	// writeMu serialises flushes, so entries reach the disk in the order they were logged.
+	writeMu sync.Mutex
+
+	flushRequested chan struct{}
+	stopCh         chan struct{}
+	doneCh         chan struct{}
+
+	dropped atomic.Int64
+
+	// prepare runs once before each flush that has entries, afterForced after flushes that
+	// wrote forced error logs.
+	prepare     func() error
+	afterForced func()
+	writeFile   func(path string, data []byte) error
+}
+
+func newRequestLogBatch(interval time.Duration, flushBytes, maxPending int) *requestLogBatch {
+	return &requestLogBatch{
+		interval:       interval,
+		flushBytes:     flushBytes,
+		maxPending:     maxPending,
+		flushRequested: make(chan struct{}, 1),
+		stopCh:         make(chan struct{}),
+		doneCh:         make(chan struct{}),
+		writeFile:      func(path string, data []byte) error { return os.WriteFile(path, data, 0o644) },
+	}
+}
+
+// add queues entry for the next flush, starting the worker on first use. Once the batch is
+// stopped, entries are written immediately.
+func (b *requestLogBatch) add(entry requestLogEntry) {
+	b.mu.Lock()
+	if b.stopped {
+		b.mu.Unlock()
+		b.write([]requestLogEntry{entry})
+		return
+	}
+	if b.pendingBytes+len(entry.data) > b.maxPending {
+		b.mu.Unlock()
+		b.drop(entry.path, "request log buffer full")
+		return
+	}
+	if !b.started {
+		b.started = true
+		go b.run()
+	}
+	b.pending = append(b.pending, entry)
+	b.pendingBytes += len(entry.data)
+	full := b.pendingBytes >= b.flushBytes
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.flushRequested <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *requestLogBatch) run() {
+	defer close(b.doneCh)
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stopCh:
+			return
+		case <-ticker.C:
+		case <-b.flushRequested:
+		}
+		b.flush()
+	}
+}
+
+// flush writes every pending entry before returning.
+func (b *requestLogBatch) flush() {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	b.mu.Lock()
+	entries := b.pending
+	b.pending = nil
+	b.pendingBytes = 0
+	b.mu.Unlock()
+	b.writeLocked(entries)
+}
+
+func (b *requestLogBatch) write(entries []requestLogEntry) {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	b.writeLocked(entries)
+}
+
+func (b *requestLogBatch) writeLocked(entries []requestLogEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	if b.prepare != nil {
+		if err := b.prepare(); err != nil {
+			for _, entry := range entries {
+				b.drop(entry.path, err.Error())
+			}
+			return
+		}
+	}
+	forced := false
+	for _, entry := range entries {
+		err := b.writeFile(entry.path, entry.data)
+		if err != nil {
+			err = b.writeFile(entry.path, entry.data)
+		}
+		if err != nil {
+			b.drop(entry.path, err.Error())
+			continue
+		}
+		forced = forced || entry.forcedError
+	}
+	if forced && b.afterForced != nil {
+		b.afterForced()
+	}
+}
+
+func (b *requestLogBatch) drop(path, reason string) {
+	total := b.dropped.Add(1)
+	log.Warnf("request log %s dropped: %s (%d dropped so far)", path, reason, total)
+}
+
+// stop writes the remaining entries and stops the worker. Entries added afterwards are
+// written directly.
+func (b *requestLogBatch) stop() {
+	b.mu.Lock()
+	if b.stopped {
+		b.mu.Unlock()
+		return
+	}
+	b.stopped = true
+	started := b.started
+	b.mu.Unlock()
+	if started {
+		close(b.stopCh)
+		<-b.doneCh
+	}
+	b.flush()
+}
@@ -0,0 +1,155 @@
+package logging
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func logTestRequest(t testing.TB, l *FileRequestLogger, requestID string) {
+	t.Helper()
+	err := l.LogRequest("/v1/chat/completions", "POST", map[string][]string{"Content-Type": {"application/json"}},
+		[]byte(`{"model":"gpt-5"}`), 200, nil, []byte(`{"id":"`+requestID+`"}`), nil, nil, nil, requestID, time.Now(), time.Now())
+	if err != nil {
+		t.Fatalf("LogRequest: %v", err)
+	}
+}
+
+func TestFileRequestLoggerWritesBufferedLogsOnFlush(t *testing.T) {
+	dir := t.TempDir()
+	l := NewFileRequestLogger(true, dir, "", 0)
+	l.batch.interval = time.Hour
+	for i := 0; i < 5; i++ {
+		logTestRequest(t, l, fmt.Sprintf("req%05d", i))
+	}
+	l.Flush()
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.log"))
+	if len(matches) != 5 {
+		t.Fatalf("found %d log files after Flush, want 5", len(matches))
+	}
+	data, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "=== REQUEST INFO ===") || !strings.Contains(string(data), "=== RESPONSE ===") {
+		t.Fatalf("log file is incomplete:\n%s", data)
+	}
+	tmp, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if len(tmp) != 0 {
+		t.Fatalf("temporary files left behind: %v", tmp)
+	}
+}
+
+func TestFileRequestLoggerStopWritesPendingLogs(t *testing.T) {
+	dir := t.TempDir()
+	l := NewFileRequestLogger(true, dir, "", 0)
+	l.batch.interval = time.Hour
+	logTestRequest(t, l, "before01")
+	if err := l.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	logTestRequest(t, l, "after001")
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.log"))
+	if len(matches) != 2 {
+		t.Fatalf("found %d log files, want the pending one and the one logged after Stop", len(matches))
+	}
+}
+
+func TestRequestLogBatchRetriesOnceThenDrops(t *testing.T) {
+	batch := newRequestLogBatch(time.Hour, 1<<20, 1<<20)
+	var attempts atomic.Int32
+	batch.writeFile = func(path string, data []byte) error {
+		attempts.Add(1)
+		if strings.HasSuffix(path, "flaky") && attempts.Load() == 1 {
+			return errors.New("disk busy")
+		}
+		if strings.HasSuffix(path, "broken") {
+			return errors.New("disk full")
+		}
+		return nil
+	}
+	batch.add(requestLogEntry{path: "flaky", data: []byte("a")})
+	batch.add(requestLogEntry{path: "broken", data: []byte("b")})
+	batch.stop()
+
+	// flaky: failure plus the retry; broken: two failures.
+	if got := attempts.Load(); got != 4 {
+		t.Fatalf("%d write attempts, want 4", got)
+	}
+	if got := batch.dropped.Load(); got != 1 {
+		t.Fatalf("%d logs dropped, want 1", got)
+	}
+}
+
+func TestRequestLogBatchDropsWhenBufferIsFull(t *testing.T) {
+	batch := newRequestLogBatch(time.Hour, 1<<20, 10)
+	block := make(chan struct{})
+	batch.writeFile = func(string, []byte) error {
+		<-block
+		return nil
+	}
+	batch.add(requestLogEntry{path: "a", data: []byte("12345678")})
+	done := make(chan struct{})
+	go func() {
+		// Would exceed the 10-byte cap and must be dropped rather than wait.
+		batch.add(requestLogEntry{path: "b", data: []byte("12345678")})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("add blocked on a full buffer")
+	}
+	close(block)
+	batch.stop()
+	if got := batch.dropped.Load(); got != 1 {
+		t.Fatalf("%d logs dropped, want 1", got)
+	}
+}
+
+// writeSyscalls returns the number of write system calls this process has made, or false
+// where /proc/self/io is unavailable.
+func writeSyscalls() (int64, bool) {
+	f, err := os.Open("/proc/self/io")
+	if err != nil {
+		return 0, false
+	}
+	defer func() { _ = f.Close() }()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "syscw: "); ok {
+			n, errParse := strconv.ParseInt(value, 10, 64)
+			return n, errParse == nil
+		}
+	}
+	return 0, false
+}
+
+// BenchmarkFileRequestLogger logs requests 1ms apart, the pace of 1k requests/sec, and
+// reports the write system calls each request costs once its log reaches the disk. Writing
+// each section straight to the file, with the body copied through a temporary file, took 18
+// writes per request on Linux; a log rendered in memory and flushed in a batch takes one.
+func BenchmarkFileRequestLogger(b *testing.B) {
+	l := NewFileRequestLogger(true, b.TempDir(), "", 0)
+	before, ok := writeSyscalls()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logTestRequest(b, l, fmt.Sprintf("%08x", i))
+		time.Sleep(time.Millisecond)
+	}
+	_ = l.Stop()
+	b.StopTimer()
+	if after, okAfter := writeSyscalls(); ok && okAfter {
+		b.ReportMetric(float64(after-before)/float64(b.N), "write-syscalls/op")
+	}
+}
@@ -19,7 +19,7 @@ import (
 
 	"github.com/andybalholm/brotli"
 	"github.com/klauspost/compress/zstd"
-	log "github.com/sirupsen/logrus"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
@@ -135,6 +135,9 @@ type FileRequestLogger struct {
 
 	// errorLogsMaxFiles limits the number of error log files retained.
 	errorLogsMaxFiles int
+
+	// batch buffers rendered non-streaming logs and writes them in the background.
+	batch *requestLogBatch
 }
 
 // NewFileRequestLogger creates a new file-based request logger.
@@ -156,11 +159,36 @@ func NewFileRequestLogger(enabled bool, logsDir string, configDir string, errorL
 			logsDir = filepath.Join(configDir, logsDir)
 		}
 	}
-	return &FileRequestLogger{
+	l := &FileRequestLogger{
 		enabled:           enabled,
 		logsDir:           logsDir,
 		errorLogsMaxFiles: errorLogsMaxFiles,
+		batch:             newRequestLogBatch(requestLogFlushInterval, requestLogFlushBytes, requestLogMaxPendingBytes),
+	}
+	l.batch.prepare = l.ensureLogsDir
+	l.batch.afterForced = func() {
+		if errCleanup := l.cleanupOldErrorLogs(); errCleanup != nil {
+			log.WithError(errCleanup).Warn("failed to clean up old error logs")
+		}
 	}
+	return l
+}
+
+// Flush writes every request log buffered so far before returning.
+func (l *FileRequestLogger) Flush() {
+	l.batch.flush()
+}
+
+// Stop writes the buffered request logs and stops the background writer. Logs recorded
+// afterwards are written directly.
+func (l *FileRequestLogger) Stop() error {
+	l.batch.stop()
+	return nil
+}
+
+// DroppedLogs returns how many request logs could not be written and were discarded.
+func (l *FileRequestLogger) DroppedLogs() int64 {
+	return l.batch.dropped.Load()
 }
 
 // IsEnabled returns whether request logging is currently enabled.
@@ -218,29 +246,12 @@ func (l *FileRequestLogger) logRequest(url, method string, requestHeaders map[st
 		return nil
 	}
 
-	// Ensure logs directory exists
-	if errEnsure := l.ensureLogsDir(); errEnsure != nil {
-		return fmt.Errorf("failed to create logs directory: %w", errEnsure)
-	}
-
 	// Generate filename with request ID
+	forcedError := force && !l.enabled
 	filename := l.generateFilename(url, requestID)
-	if force && !l.enabled {
+	if forcedError {
 		filename = l.generateErrorFilename(url, requestID)
 	}
-	filePath := filepath.Join(l.logsDir, filename)
-
-	requestBodyPath, errTemp := l.writeRequestBodyTempFile(body)
-	if errTemp != nil {
-		log.WithError(errTemp).Warn("failed to create request body temp file, falling back to direct write")
-	}
-	if requestBodyPath != "" {
-		defer func() {
-			if errRemove := os.Remove(requestBodyPath); errRemove != nil {
-				log.WithError(errRemove).Warn("failed to remove request body temp file")
-			}
-		}()
-	}
 
 	responseToWrite, decompressErr := l.decompressResponse(responseHeaders, response)
 	if decompressErr != nil {
@@ -248,18 +259,15 @@ func (l *FileRequestLogger) logRequest(url, method string, requestHeaders map[st
 		responseToWrite = response
 	}
 
-	logFile, errOpen := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-	if errOpen != nil {
-		return fmt.Errorf("failed to create log file: %w", errOpen)
-	}
-
+	// Render in memory; the file itself is written by the batch worker.
+	var rendered bytes.Buffer
 	writeErr := l.writeNonStreamingLog(
-		logFile,
+		&rendered,
 		url,
 		method,
 		requestHeaders,
 		body,
-		requestBodyPath,
+		"",
 		apiRequest,
 		apiResponse,
 		apiResponseErrors,
@@ -270,22 +278,11 @@ func (l *FileRequestLogger) logRequest(url, method string, requestHeaders map[st
 		requestTimestamp,
 		apiResponseTimestamp,
 	)
-	if errClose := logFile.Close(); errClose != nil {
-		log.WithError(errClose).Warn("failed to close request log file")
-		if writeErr == nil {
-			return errClose
-		}
-	}
 	if writeErr != nil {
 		return fmt.Errorf("failed to write log file: %w", writeErr)
 	}
 
-	if force && !l.enabled {
-		if errCleanup := l.cleanupOldErrorLogs(); errCleanup != nil {
-			log.WithError(errCleanup).Warn("failed to clean up old error logs")
-		}
-	}
-
+	l.batch.add(requestLogEntry{path: filepath.Join(l.logsDir, filename), data: rendered.Bytes(), forcedError: forcedError})
 	return nil
 }
 
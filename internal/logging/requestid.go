@@ -59,3 +59,23 @@ func GetGinRequestID(c *gin.Context) string {
 	}
 	return ""
 }
+
+// ginRequestModelKey is the Gin context key for the model a request asked for.
+const ginRequestModelKey = "__request_model__"
+
+// SetGinRequestModel records the requested model so the request's log line can name it.
+func SetGinRequestModel(c *gin.Context, model string) {
+	if c != nil && model != "" {
+		c.Set(ginRequestModelKey, model)
+	}
+}
+
+// GetGinRequestModel retrieves the requested model from the Gin context.
+func GetGinRequestModel(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	model, _ := c.Get(ginRequestModelKey)
+	s, _ := model.(string)
+	return s
+}
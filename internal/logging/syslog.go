@@ -0,0 +1,242 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// syslogFieldsID is the SD-ID carrying log fields. 32473 is the enterprise number RFC 5612
+// reserves for documentation and private use.
+const syslogFieldsID = "fields@32473"
+
+// syslogSocketPaths are tried in order when no syslog address is configured.
+var syslogSocketPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// syslogFormatter renders entries as RFC 5424 messages. Log fields and the call site go into
+// a structured data element instead of the message text.
+type syslogFormatter struct {
+	facility int
+	tag      string
+	hostname string
+	pid      string
+}
+
+func newSyslogFormatter(cfg config.SyslogConfig) *syslogFormatter {
+	hostname, _ := os.Hostname()
+	return &syslogFormatter{
+		facility: cfg.FacilityCode(),
+		tag:      syslogHeaderValue(cfg.TagOrDefault(), 48),
+		hostname: syslogHeaderValue(hostname, 255),
+		pid:      strconv.Itoa(os.Getpid()),
+	}
+}
+
+// Format renders <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG without a trailing
+// newline; framing, where the transport needs it, is left to the writer.
+func (f *syslogFormatter) Format(entry *log.Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s - ",
+		f.facility*8+syslogSeverity(entry.Level),
+		entry.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		f.hostname, f.tag, f.pid)
+
+	fields := make(map[string]string, len(entry.Data)+1)
+	for key, value := range entry.Data {
+		fields[syslogParamName(key)] = fmt.Sprint(value)
+	}
+	if entry.Caller != nil {
+		fields["caller"] = fmt.Sprintf("%s:%d", filepath.Base(entry.Caller.File), entry.Caller.Line)
+	}
+	if len(fields) == 0 {
+		buf.WriteByte('-')
+	} else {
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		buf.WriteString("[" + syslogFieldsID)
+		for _, name := range names {
+			buf.WriteString(" " + name + `="`)
+			syslogEscapeParam(&buf, fields[name])
+			buf.WriteByte('"')
+		}
+		buf.WriteByte(']')
+	}
+	if message := strings.TrimRight(entry.Message, "\r\n"); message != "" {
+		buf.WriteString(" " + message)
+	}
+	return buf.Bytes(), nil
+}
+
+// syslogSeverity maps logrus levels to RFC 5424 severities.
+func syslogSeverity(level log.Level) int {
+	switch level {
+	case log.PanicLevel, log.FatalLevel:
+		return 2
+	case log.ErrorLevel:
+		return 3
+	case log.WarnLevel:
+		return 4
+	case log.InfoLevel:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// syslogHeaderValue makes value a valid header field: printable ASCII without spaces, at most
+// limit bytes, and "-" when empty.
+func syslogHeaderValue(value string, limit int) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(value) > limit {
+		value = value[:limit]
+	}
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// syslogParamName makes key a valid PARAM-NAME: at most 32 printable ASCII characters other
+// than '=', ' ', ']' and '"'.
+func syslogParamName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// syslogEscapeParam writes value as a PARAM-VALUE, escaping '"', '\' and ']'.
+func syslogEscapeParam(buf *bytes.Buffer, value string) {
+	for _, r := range value {
+		if r == '"' || r == '\\' || r == ']' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteRune(r)
+	}
+}
+
+// syslogWriter sends each formatted entry as one syslog message. Datagram transports carry
+// one message per packet; stream sockets use octet-counting framing (RFC 6587). A failed
+// write redials once, so a restarted syslog daemon does not lose the log for good.
+type syslogWriter struct {
+	cfg config.SyslogConfig
+
+	mu     sync.Mutex
+	conn   net.Conn
+	stream bool
+}
+
+// dialSyslog connects to the configured syslog daemon.
+func dialSyslog(cfg config.SyslogConfig) (*syslogWriter, error) {
+	w := &syslogWriter{cfg: cfg}
+	if err := w.dial(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *syslogWriter) dial() error {
+	network := w.cfg.NetworkOrDefault()
+	address := strings.TrimSpace(w.cfg.Address)
+	if network == "udp" || network == "udp4" || network == "udp6" {
+		conn, err := net.Dial(network, address)
+		if err != nil {
+			return fmt.Errorf("syslog: dial %s %s: %w", network, address, err)
+		}
+		w.conn, w.stream = conn, false
+		return nil
+	}
+	paths := syslogSocketPaths
+	if address != "" {
+		paths = []string{address}
+	}
+	networks := []string{"unixgram", "unix"}
+	if network == "unixgram" {
+		networks = networks[:1]
+	}
+	var errs []error
+	for _, path := range paths {
+		for _, n := range networks {
+			conn, err := net.Dial(n, path)
+			if err == nil {
+				w.conn, w.stream = conn, n == "unix"
+				return nil
+			}
+			errs = append(errs, err)
+		}
+	}
+	return fmt.Errorf("syslog: no reachable socket: %w", errors.Join(errs...))
+}
+
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		// Sampled-out entries format to nothing; they must not become empty datagrams.
+		return 0, nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.send(p); err != nil {
+		if w.conn != nil {
+			_ = w.conn.Close()
+			w.conn = nil
+		}
+		if errDial := w.dial(); errDial != nil {
+			return 0, err
+		}
+		if err = w.send(p); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *syslogWriter) send(p []byte) error {
+	if w.conn == nil {
+		return net.ErrClosed
+	}
+	var err error
+	if w.stream {
+		_, err = w.conn.Write(append([]byte(strconv.Itoa(len(p))+" "), p...))
+	} else {
+		_, err = w.conn.Write(p)
+	}
+	return err
+}
+
+func (w *syslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestSyslogOutputSendsStructuredData(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	cfg := config.SyslogConfig{Address: listener.LocalAddr().String(), Facility: "local0", Tag: "proxy"}
+	writer, err := dialSyslog(cfg)
+	if err != nil {
+		t.Fatalf("dialSyslog: %v", err)
+	}
+	defer func() { _ = writer.Close() }()
+
+	logger := log.New()
+	logger.SetOutput(writer)
+	logger.SetFormatter(newSyslogFormatter(cfg))
+	logger.WithFields(log.Fields{"model": "gpt-5", "error": `bad "quote"]`}).Warn("upstream failed\n")
+
+	buf := make([]byte, 2048)
+	_ = listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := listener.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("read datagram: %v", err)
+	}
+	msg := string(buf[:n])
+	// local0 (16) * 8 + warning (4)
+	if !strings.HasPrefix(msg, "<132>1 ") || !strings.Contains(msg, " proxy ") {
+		t.Fatalf("unexpected header: %q", msg)
+	}
+	if !strings.HasSuffix(msg, `[fields@32473 error="bad \"quote\"\]" model="gpt-5"] upstream failed`) {
+		t.Fatalf("fields should be structured data, not part of the message: %q", msg)
+	}
+}
+
+func TestJournaldFormatterUsesBinaryFieldsForMultiline(t *testing.T) {
+	entry := &log.Entry{Level: log.ErrorLevel, Message: "line one\nline two", Data: log.Fields{"request_id": "a1b2", "1st": 1}}
+	out, err := (&journaldFormatter{identifier: "proxy"}).Format(entry)
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	var length [8]byte
+	binary.LittleEndian.PutUint64(length[:], uint64(len("line one\nline two")))
+	if !bytes.HasPrefix(out, append([]byte("MESSAGE\n"), append(length[:], "line one\nline two\n"...)...)) {
+		t.Fatalf("multi-line message not length-prefixed: %q", out)
+	}
+	for _, field := range []string{"PRIORITY=3\n", "SYSLOG_IDENTIFIER=proxy\n", "REQUEST_ID=a1b2\n", "F1ST=1\n"} {
+		if !bytes.Contains(out, []byte(field)) {
+			t.Fatalf("missing %q in %q", field, out)
+		}
+	}
+}
+
+func TestConfigureLogOutputFallsBackToStderr(t *testing.T) {
+	cfg := &config.Config{Logging: config.LoggingConfig{
+		Output: config.LogOutputSyslog,
+		Syslog: config.SyslogConfig{Address: filepath.Join(t.TempDir(), "missing.sock")},
+	}}
+	if err := ConfigureLogOutput(cfg); err != nil {
+		t.Fatalf("an unreachable syslog daemon must not fail startup: %v", err)
+	}
+	defer func() { _ = ConfigureLogOutput(&config.Config{}) }()
+	if log.StandardLogger().Out != os.Stderr {
+		t.Fatal("logs should go to stderr while syslog is unreachable")
+	}
+}
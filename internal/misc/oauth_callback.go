@@ -0,0 +1,43 @@
+package misc
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultOAuthCallbackTimeout bounds how long interactive logins wait for the browser redirect.
+const DefaultOAuthCallbackTimeout = 5 * time.Minute
+
+// OAuthCallbackListenAddr returns the listen address for a login callback server.
+// An empty host keeps the historical behavior of listening on all interfaces.
+func OAuthCallbackListenAddr(host string, port int) string {
+	return net.JoinHostPort(strings.TrimSpace(host), strconv.Itoa(port))
+}
+
+// FreeOAuthCallbackPort asks the OS for an unused TCP port on host.
+func FreeOAuthCallbackPort(host string) (int, error) {
+	listener, err := net.Listen("tcp", OAuthCallbackListenAddr(host, 0))
+	if err != nil {
+		return 0, fmt.Errorf("pick free callback port: %w", err)
+	}
+	defer func() { _ = listener.Close() }()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// PrintOAuthCallbackListening prints the exact address the callback server listens on.
+func PrintOAuthCallbackListening(host string, port int, path string) {
+	display := strings.TrimSpace(host)
+	if display == "" {
+		display = "0.0.0.0"
+	}
+	fmt.Printf("Listening for the OAuth callback on http://%s%s\n", OAuthCallbackListenAddr(display, port), path)
+}
+
+// OAuthCallbackTimeoutError explains what to do when no callback arrived in time.
+func OAuthCallbackTimeoutError(provider string, timeout time.Duration, port int) error {
+	return fmt.Errorf("%s login: no OAuth callback received within %s on port %d; if the browser runs on another machine, forward the port (ssh -L %d:127.0.0.1:%d <server>) or paste the callback URL when prompted, and retry with a longer -callback-timeout if needed",
+		provider, timeout, port, port, port)
+}
@@ -0,0 +1,317 @@
+// Package multimodal validates image inputs in inbound requests and inlines remote image
+// URLs for upstreams that only accept base64 image data (the Gemini family).
+package multimodal
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// MaxImageBytes caps a single decoded image; it matches Gemini's inline data limit.
+	MaxImageBytes = 20 << 20
+	// FetchTimeout bounds the download of one remote image.
+	FetchTimeout = 15 * time.Second
+)
+
+// ImageTooLargeError reports an image above MaxImageBytes.
+type ImageTooLargeError struct {
+	Limit int64
+}
+
+func (e *ImageTooLargeError) Error() string {
+	return fmt.Sprintf("image exceeds the %d MiB size limit", e.Limit>>20)
+}
+
+// InputError reports an image part the proxy cannot use. It maps to HTTP 400.
+type InputError struct {
+	Reason string
+}
+
+func (e *InputError) Error() string { return e.Reason }
+
+// RequiresInlineImages reports whether any of the providers rejects image URLs.
+func RequiresInlineImages(providers []string) bool {
+	for _, provider := range providers {
+		switch strings.ToLower(provider) {
+		case "gemini", "gemini-cli", "vertex", "aistudio", "antigravity":
+			return true
+		}
+	}
+	return false
+}
+
+// Prepare checks every image in payload against MaxImageBytes and, when inline is set,
+// replaces remote image URLs with base64 data downloaded through proxyURL.
+// format is the inbound request dialect ("openai", "openai-response" or "claude");
+// other dialects are returned unchanged.
+func Prepare(ctx context.Context, format string, payload []byte, inline bool, proxyURL string) ([]byte, error) {
+	f := &fetcher{proxyURL: proxyURL}
+	switch format {
+	case "openai":
+		return f.prepareOpenAI(ctx, payload, inline)
+	case "openai-response":
+		return f.prepareResponses(ctx, payload, inline)
+	case "claude":
+		return f.prepareClaude(ctx, payload, inline)
+	default:
+		return payload, nil
+	}
+}
+
+func (f *fetcher) prepareOpenAI(ctx context.Context, payload []byte, inline bool) ([]byte, error) {
+	var errOut error
+	gjson.GetBytes(payload, "messages").ForEach(func(msgIdx, message gjson.Result) bool {
+		message.Get("content").ForEach(func(partIdx, part gjson.Result) bool {
+			if part.Get("type").String() != "image_url" {
+				return true
+			}
+			path := fmt.Sprintf("messages.%d.content.%d.image_url", msgIdx.Int(), partIdx.Int())
+			url := part.Get("image_url.url").String()
+			bare := part.Get("image_url").Type == gjson.String
+			if bare {
+				url = part.Get("image_url").String()
+			}
+			dataURL, changed, err := f.resolveURL(ctx, url, inline)
+			if err != nil {
+				errOut = err
+				return false
+			}
+			if bare {
+				// Normalise the shorthand string form to the object form the translators read.
+				payload, _ = sjson.SetRawBytes(payload, path, []byte(`{}`))
+				if !changed {
+					dataURL = url
+				}
+				changed = true
+			}
+			if changed {
+				payload, _ = sjson.SetBytes(payload, path+".url", dataURL)
+			}
+			return true
+		})
+		return errOut == nil
+	})
+	return payload, errOut
+}
+
+func (f *fetcher) prepareResponses(ctx context.Context, payload []byte, inline bool) ([]byte, error) {
+	var errOut error
+	gjson.GetBytes(payload, "input").ForEach(func(itemIdx, item gjson.Result) bool {
+		item.Get("content").ForEach(func(partIdx, part gjson.Result) bool {
+			if part.Get("type").String() != "input_image" {
+				return true
+			}
+			dataURL, changed, err := f.resolveURL(ctx, part.Get("image_url").String(), inline)
+			if err != nil {
+				errOut = err
+				return false
+			}
+			if changed {
+				payload, _ = sjson.SetBytes(payload, fmt.Sprintf("input.%d.content.%d.image_url", itemIdx.Int(), partIdx.Int()), dataURL)
+			}
+			return true
+		})
+		return errOut == nil
+	})
+	return payload, errOut
+}
+
+func (f *fetcher) prepareClaude(ctx context.Context, payload []byte, inline bool) ([]byte, error) {
+	var errOut error
+	gjson.GetBytes(payload, "messages").ForEach(func(msgIdx, message gjson.Result) bool {
+		message.Get("content").ForEach(func(partIdx, part gjson.Result) bool {
+			if part.Get("type").String() != "image" {
+				return true
+			}
+			source := part.Get("source")
+			switch source.Get("type").String() {
+			case "base64":
+				errOut = checkBase64Size(source.Get("data").String())
+			case "url":
+				if !inline {
+					return true
+				}
+				mime, data, err := f.fetch(ctx, source.Get("url").String())
+				if err != nil {
+					errOut = err
+					return false
+				}
+				block := `{"type":"base64","media_type":"","data":""}`
+				block, _ = sjson.Set(block, "media_type", mime)
+				block, _ = sjson.Set(block, "data", data)
+				payload, _ = sjson.SetRawBytes(payload, fmt.Sprintf("messages.%d.content.%d.source", msgIdx.Int(), partIdx.Int()), []byte(block))
+			}
+			return errOut == nil
+		})
+		return errOut == nil
+	})
+	return payload, errOut
+}
+
+// resolveURL validates a data URL, or downloads a remote URL into one when inline is set.
+func (f *fetcher) resolveURL(ctx context.Context, url string, inline bool) (string, bool, error) {
+	url = strings.TrimSpace(url)
+	lower := strings.ToLower(url)
+	switch {
+	case strings.HasPrefix(lower, "data:"):
+		meta, data, ok := strings.Cut(url[len("data:"):], ",")
+		if !ok || !strings.HasSuffix(strings.ToLower(meta), ";base64") {
+			return "", false, &InputError{Reason: "image data URLs must be base64-encoded (data:<mime>;base64,<data>)"}
+		}
+		return "", false, checkBase64Size(data)
+	case strings.HasPrefix(lower, "http://"), strings.HasPrefix(lower, "https://"):
+		if !inline {
+			return "", false, nil
+		}
+		mime, data, err := f.fetch(ctx, url)
+		if err != nil {
+			return "", false, err
+		}
+		return "data:" + mime + ";base64," + data, true, nil
+	case url == "":
+		return "", false, &InputError{Reason: "image_url part has no url"}
+	default:
+		return "", false, &InputError{Reason: "image URLs must be data:, http:// or https:// URLs"}
+	}
+}
+
+func checkBase64Size(data string) error {
+	if int64(base64.StdEncoding.DecodedLen(len(strings.TrimSpace(data)))) > MaxImageBytes+2 {
+		return &ImageTooLargeError{Limit: MaxImageBytes}
+	}
+	return nil
+}
+
+type fetcher struct {
+	proxyURL string
+	client   *http.Client
+	// proxied is set when fetches go through proxyURL, where the dialer only sees the
+	// proxy's address and the image host has to be checked before each request.
+	proxied bool
+}
+
+// fetch downloads an image and returns its mime type and base64 payload.
+func (f *fetcher) fetch(ctx context.Context, url string) (string, string, error) {
+	client, err := f.httpClient()
+	if err != nil {
+		return "", "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, FetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", "", &InputError{Reason: fmt.Sprintf("invalid image URL: %v", err)}
+	}
+	if f.proxied {
+		if err = checkTarget(ctx, req.URL); err != nil {
+			return "", "", &InputError{Reason: fmt.Sprintf("failed to fetch image %s: %v", url, err)}
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", &InputError{Reason: fmt.Sprintf("failed to fetch image %s: %v", url, err)}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", &InputError{Reason: fmt.Sprintf("failed to fetch image %s: upstream returned %s", url, resp.Status)}
+	}
+	if resp.ContentLength > MaxImageBytes {
+		return "", "", &ImageTooLargeError{Limit: MaxImageBytes}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxImageBytes+1))
+	if err != nil {
+		return "", "", &InputError{Reason: fmt.Sprintf("failed to read image %s: %v", url, err)}
+	}
+	if len(body) > MaxImageBytes {
+		return "", "", &ImageTooLargeError{Limit: MaxImageBytes}
+	}
+	mime := strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+	if !strings.HasPrefix(mime, "image/") {
+		mime = http.DetectContentType(body)
+	}
+	if !strings.HasPrefix(mime, "image/") {
+		return "", "", &InputError{Reason: fmt.Sprintf("%s is not an image (content type %s)", url, mime)}
+	}
+	return mime, base64.StdEncoding.EncodeToString(body), nil
+}
+
+func (f *fetcher) httpClient() (*http.Client, error) {
+	if f.client != nil {
+		return f.client, nil
+	}
+	if strings.TrimSpace(f.proxyURL) != "" {
+		transport, err := util.ProxyTransport(f.proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		f.client = &http.Client{Transport: transport, CheckRedirect: checkRedirectTarget}
+		f.proxied = true
+		return f.client, nil
+	}
+	// Direct fetches refuse internal addresses so clients cannot probe the proxy's network.
+	dialer := &net.Dialer{Timeout: FetchTimeout, Control: rejectInternalAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	f.client = &http.Client{Transport: transport}
+	return f.client, nil
+}
+
+var errInternalAddress = errors.New("image URL resolves to a private or loopback address")
+
+func rejectInternalAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || isInternalIP(ip) {
+		return errInternalAddress
+	}
+	return nil
+}
+
+// checkTarget refuses a URL whose host is, or resolves to, an internal address.
+func checkTarget(ctx context.Context, target *neturl.URL) error {
+	host := target.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if isInternalIP(ip) {
+			return errInternalAddress
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if isInternalIP(addr.IP) {
+			return errInternalAddress
+		}
+	}
+	return nil
+}
+
+// checkRedirectTarget applies checkTarget to every redirect of a proxied fetch.
+func checkRedirectTarget(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return checkTarget(req.Context(), req.URL)
+}
+
+func isInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
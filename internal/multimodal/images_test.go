@@ -0,0 +1,107 @@
+package multimodal
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n0000")
+
+func TestPrepareOpenAIInlinesRemoteImages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(pngHeader)
+	}))
+	defer srv.Close()
+
+	payload := []byte(`{"messages":[{"role":"user","content":[{"type":"text","text":"what is this"},{"type":"image_url","image_url":{"url":"` + srv.URL + `/cat.png"}}]}]}`)
+	f := &fetcher{client: srv.Client()}
+	out, err := f.prepareOpenAI(context.Background(), payload, true)
+	if err != nil {
+		t.Fatalf("prepareOpenAI: %v", err)
+	}
+	want := "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngHeader)
+	if got := gjson.GetBytes(out, "messages.0.content.1.image_url.url").String(); got != want {
+		t.Fatalf("url = %q, want %q", got, want)
+	}
+
+	untouched, err := f.prepareOpenAI(context.Background(), payload, false)
+	if err != nil || string(untouched) != string(payload) {
+		t.Fatalf("remote URLs must pass through when the provider fetches them: %v", err)
+	}
+}
+
+func TestPrepareClaudeURLSourceBecomesBase64(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(pngHeader)
+	}))
+	defer srv.Close()
+
+	payload := []byte(`{"messages":[{"role":"user","content":[{"type":"image","source":{"type":"url","url":"` + srv.URL + `"}}]}]}`)
+	f := &fetcher{client: srv.Client()}
+	out, err := f.prepareClaude(context.Background(), payload, true)
+	if err != nil {
+		t.Fatalf("prepareClaude: %v", err)
+	}
+	source := gjson.GetBytes(out, "messages.0.content.0.source")
+	if source.Get("type").String() != "base64" || source.Get("media_type").String() != "image/png" || source.Get("data").String() == "" {
+		t.Fatalf("unexpected source: %s", source.Raw)
+	}
+}
+
+func TestPrepareRejectsOversizedImages(t *testing.T) {
+	big := strings.Repeat("A", (MaxImageBytes/3)*4+8)
+	payload := []byte(`{"messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"data:image/png;base64,` + big + `"}}]}]}`)
+	_, err := Prepare(context.Background(), "openai", payload, false, "")
+	var tooLarge *ImageTooLargeError
+	if !errors.As(err, &tooLarge) || !strings.Contains(err.Error(), "20 MiB") {
+		t.Fatalf("expected ImageTooLargeError naming the limit, got %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(make([]byte, MaxImageBytes+1))
+	}))
+	defer srv.Close()
+	f := &fetcher{client: srv.Client()}
+	if _, _, err = f.fetch(context.Background(), srv.URL); !errors.As(err, &tooLarge) {
+		t.Fatalf("expected oversized download to fail, got %v", err)
+	}
+}
+
+func TestPrepareRefusesInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(pngHeader)
+	}))
+	defer srv.Close()
+
+	payload := []byte(`{"messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"` + srv.URL + `"}}]}]}`)
+	_, err := Prepare(context.Background(), "openai", payload, true, "")
+	var invalid *InputError
+	if !errors.As(err, &invalid) || !strings.Contains(err.Error(), "private or loopback") {
+		t.Fatalf("expected loopback fetch to be refused, got %v", err)
+	}
+}
+
+func TestPrepareRefusesInternalAddressesThroughProxy(t *testing.T) {
+	proxied := false
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = true
+		_, _ = w.Write(pngHeader)
+	}))
+	defer proxy.Close()
+
+	payload := []byte(`{"messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"http://127.0.0.1:9/cat.png"}}]}]}`)
+	_, err := Prepare(context.Background(), "openai", payload, true, proxy.URL)
+	var invalid *InputError
+	if !errors.As(err, &invalid) || !strings.Contains(err.Error(), "private or loopback") || proxied {
+		t.Fatalf("expected the proxied loopback fetch to be refused before it was sent, got %v (proxied %v)", err, proxied)
+	}
+}
@@ -0,0 +1,245 @@
+// Package notify posts operational events, such as an account being disabled, to Slack and
+// Discord webhooks. Publishing never blocks: events go through a small queue to one sender,
+// repeats of an event for the same account are rate limited, and failed deliveries are retried
+// with backoff before being dropped.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+const (
+	queueSize      = 64
+	requestTimeout = 10 * time.Second
+)
+
+// defaultRetryDelays are the waits before each retry of a failed delivery.
+var defaultRetryDelays = []time.Duration{2 * time.Second, 10 * time.Second, 30 * time.Second}
+
+// Event is one operational event. Account names the credential by label or file name, never
+// by token.
+type Event struct {
+	Type     string
+	Account  string
+	Provider string
+	Message  string
+	Time     time.Time
+	// Suppressed counts events of the same type and account held back since the last one sent.
+	Suppressed int
+}
+
+// Notifier rate limits events and delivers them to the configured webhooks.
+type Notifier struct {
+	mu         sync.Mutex
+	cfg        config.NotificationsConfig
+	lastSent   map[string]time.Time
+	suppressed map[string]int
+	started    bool
+
+	queue       chan Event
+	client      *http.Client
+	retryDelays []time.Duration
+	now         func() time.Time
+}
+
+// NewNotifier returns a notifier with no webhooks configured.
+func NewNotifier() *Notifier {
+	return &Notifier{
+		lastSent:    make(map[string]time.Time),
+		suppressed:  make(map[string]int),
+		queue:       make(chan Event, queueSize),
+		client:      &http.Client{Timeout: requestTimeout},
+		retryDelays: defaultRetryDelays,
+		now:         time.Now,
+	}
+}
+
+var defaultNotifier = NewNotifier()
+
+// Default returns the notifier the auth manager and usage hooks publish into.
+func Default() *Notifier { return defaultNotifier }
+
+// Configure applies the notification settings to the default notifier.
+func Configure(cfg config.NotificationsConfig) { defaultNotifier.Configure(cfg) }
+
+// Publish hands an event to the default notifier.
+func Publish(event Event) { defaultNotifier.Publish(event) }
+
+// Configure replaces the webhooks, enabled events and rate limit.
+func (n *Notifier) Configure(cfg config.NotificationsConfig) {
+	n.mu.Lock()
+	n.cfg = cfg
+	n.mu.Unlock()
+}
+
+// Publish queues event for delivery unless its type is disabled, no webhook is configured, or
+// the same event for the same account was sent within the rate limit. A full queue drops it.
+func (n *Notifier) Publish(event Event) {
+	if n == nil || event.Type == "" {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = n.now()
+	}
+	key := event.Type + "\x00" + event.Account
+
+	n.mu.Lock()
+	if len(n.cfg.Webhooks) == 0 || !n.cfg.EventEnabled(event.Type) {
+		n.mu.Unlock()
+		return
+	}
+	if last, ok := n.lastSent[key]; ok && event.Time.Sub(last) < n.cfg.RateLimitDuration() {
+		n.suppressed[key]++
+		n.mu.Unlock()
+		return
+	}
+	n.lastSent[key] = event.Time
+	event.Suppressed = n.suppressed[key]
+	delete(n.suppressed, key)
+	if !n.started {
+		n.started = true
+		go n.run()
+	}
+	n.mu.Unlock()
+
+	select {
+	case n.queue <- event:
+	default:
+		log.Warnf("notifications: queue full, dropped %s event for %s", event.Type, event.Account)
+	}
+}
+
+func (n *Notifier) run() {
+	for event := range n.queue {
+		n.mu.Lock()
+		webhooks := append([]config.NotificationWebhook(nil), n.cfg.Webhooks...)
+		n.mu.Unlock()
+		for i, hook := range webhooks {
+			if err := n.deliverWithRetry(hook, event); err != nil {
+				log.Debugf("notifications: giving up on %s event for webhook %d: %v", event.Type, i, err)
+			}
+		}
+	}
+}
+
+// deliverWithRetry retries transient failures, 429 and 5xx answers and network errors, after
+// each of the retry delays.
+func (n *Notifier) deliverWithRetry(hook config.NotificationWebhook, event Event) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = n.deliver(context.Background(), hook, event)
+		if err == nil || !retry || attempt >= len(n.retryDelays) {
+			return err
+		}
+		time.Sleep(n.retryDelays[attempt])
+	}
+}
+
+// deliver posts event once and reports whether a failure is worth retrying.
+func (n *Notifier) deliver(ctx context.Context, hook config.NotificationWebhook, event Event) (bool, error) {
+	body, err := payload(hook.PayloadFormat(), event)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSpace(hook.URL), bytes.NewReader(body))
+	if err != nil {
+		return false, errors.New("invalid webhook url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The error quotes the URL, which carries the webhook token.
+		return true, fmt.Errorf("post webhook: %s", errorKind(err))
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+	return retry, fmt.Errorf("webhook answered %d", resp.StatusCode)
+}
+
+// errorKind describes a transport error without the request URL.
+func errorKind(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Err != nil {
+		return urlErr.Err.Error()
+	}
+	return "request failed"
+}
+
+// payload renders event in the webhook's format: Slack reads "text", Discord "content".
+func payload(format string, event Event) ([]byte, error) {
+	text := formatText(event)
+	switch format {
+	case config.NotifyFormatDiscord:
+		return json.Marshal(map[string]string{"content": text})
+	case config.NotifyFormatSlack:
+		return json.Marshal(map[string]string{"text": text})
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", format)
+	}
+}
+
+func formatText(event Event) string {
+	var b strings.Builder
+	b.WriteString("[CLIProxyAPI] ")
+	b.WriteString(strings.ReplaceAll(event.Type, "-", " "))
+	if event.Account != "" {
+		b.WriteString(": ")
+		b.WriteString(event.Account)
+		if event.Provider != "" {
+			fmt.Fprintf(&b, " (%s)", event.Provider)
+		}
+	}
+	if event.Message != "" {
+		b.WriteString(" - ")
+		b.WriteString(event.Message)
+	}
+	if event.Suppressed > 0 {
+		fmt.Fprintf(&b, " [%d similar events suppressed]", event.Suppressed)
+	}
+	return b.String()
+}
+
+// TestResult is the outcome of a test notification for one webhook.
+type TestResult struct {
+	Index  int    `json:"index"`
+	Format string `json:"format"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
+// SendTest posts a test event to every configured webhook once, bypassing the queue and the
+// rate limit, and reports how each delivery went.
+func (n *Notifier) SendTest(ctx context.Context) []TestResult {
+	n.mu.Lock()
+	webhooks := append([]config.NotificationWebhook(nil), n.cfg.Webhooks...)
+	n.mu.Unlock()
+	event := Event{Type: "test", Message: "webhook is wired correctly", Time: n.now()}
+	results := make([]TestResult, 0, len(webhooks))
+	for i, hook := range webhooks {
+		result := TestResult{Index: i, Format: hook.PayloadFormat(), OK: true}
+		if _, err := n.deliver(ctx, hook, event); err != nil {
+			result.OK = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
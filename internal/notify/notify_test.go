@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// webhookRecorder answers with the queued status codes, then 200, and keeps every body.
+type webhookRecorder struct {
+	mu       sync.Mutex
+	bodies   []string
+	statuses []int
+}
+
+func newWebhookRecorder(t *testing.T, statuses ...int) (*webhookRecorder, string) {
+	t.Helper()
+	rec := &webhookRecorder{statuses: statuses}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		rec.bodies = append(rec.bodies, string(body))
+		status := http.StatusOK
+		if len(rec.statuses) > 0 {
+			status, rec.statuses = rec.statuses[0], rec.statuses[1:]
+		}
+		rec.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return rec, server.URL
+}
+
+func (r *webhookRecorder) waitFor(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r.mu.Lock()
+		bodies := append([]string(nil), r.bodies...)
+		r.mu.Unlock()
+		if len(bodies) >= n || time.Now().After(deadline) {
+			return bodies
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newTestNotifier(cfg config.NotificationsConfig) *Notifier {
+	n := NewNotifier()
+	n.retryDelays = []time.Duration{0, 0, 0}
+	n.Configure(cfg)
+	return n
+}
+
+func TestPublishRateLimitsRepeatsPerAccount(t *testing.T) {
+	rec, url := newWebhookRecorder(t)
+	n := newTestNotifier(config.NotificationsConfig{Webhooks: []config.NotificationWebhook{{URL: url}}, RateLimit: "10m"})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		n.Publish(Event{Type: config.NotifyAccountDisabled, Account: "flappy.json", Time: start.Add(time.Duration(i) * time.Minute)})
+	}
+	n.Publish(Event{Type: config.NotifyAccountDisabled, Account: "other.json", Time: start})
+	n.Publish(Event{Type: config.NotifyAccountDisabled, Account: "flappy.json", Time: start.Add(11 * time.Minute)})
+
+	bodies := rec.waitFor(t, 3)
+	if len(bodies) != 3 {
+		t.Fatalf("delivered %d notifications, want 3: %v", len(bodies), bodies)
+	}
+	if !strings.Contains(bodies[2], "flappy.json") || !strings.Contains(bodies[2], "[2 similar events suppressed]") {
+		t.Fatalf("notification after the rate limit should count the suppressed ones: %s", bodies[2])
+	}
+}
+
+func TestPublishSkipsDisabledEventTypes(t *testing.T) {
+	rec, url := newWebhookRecorder(t)
+	n := newTestNotifier(config.NotificationsConfig{
+		Webhooks: []config.NotificationWebhook{{URL: url}},
+		Events:   map[string]bool{config.NotifyUsageAlert: false},
+	})
+	n.Publish(Event{Type: config.NotifyUsageAlert, Account: "a"})
+	n.Publish(Event{Type: config.NotifyRefreshFailed, Account: "a"})
+	// One worker delivers in order, so a usage alert would arrive before the refresh failure.
+	bodies := rec.waitFor(t, 1)
+	if len(bodies) != 1 || !strings.Contains(bodies[0], "refresh failed") {
+		t.Fatalf("want only the refresh-failed notification, got %v", bodies)
+	}
+}
+
+func TestDeliveryRetriesTransientFailures(t *testing.T) {
+	rec, url := newWebhookRecorder(t, http.StatusInternalServerError, http.StatusTooManyRequests)
+	n := newTestNotifier(config.NotificationsConfig{})
+	if err := n.deliverWithRetry(config.NotificationWebhook{URL: url}, Event{Type: config.NotifyRefreshFailed}); err != nil {
+		t.Fatalf("delivery failed after retries: %v", err)
+	}
+	if got := len(rec.waitFor(t, 3)); got != 3 {
+		t.Fatalf("made %d attempts, want 3", got)
+	}
+
+	rejected, rejectURL := newWebhookRecorder(t, http.StatusNotFound)
+	if err := n.deliverWithRetry(config.NotificationWebhook{URL: rejectURL}, Event{Type: config.NotifyRefreshFailed}); err == nil {
+		t.Fatal("a 404 should be reported as a failure")
+	}
+	if got := len(rejected.waitFor(t, 1)); got != 1 {
+		t.Fatalf("a 404 was attempted %d times, want no retry", got)
+	}
+}
+
+func TestPayloadFormats(t *testing.T) {
+	event := Event{Type: config.NotifyUsageAlert, Account: "me@example.com", Provider: "gemini-cli", Message: "used 990 of 1000"}
+	slack, _ := payload(config.NotifyFormatSlack, event)
+	discord, _ := payload(config.NotifyFormatDiscord, event)
+	want := "[CLIProxyAPI] usage alert: me@example.com (gemini-cli) - used 990 of 1000"
+	if string(slack) != `{"text":"`+want+`"}` || string(discord) != `{"content":"`+want+`"}` {
+		t.Fatalf("unexpected payloads:\n%s\n%s", slack, discord)
+	}
+	if got := (config.NotificationWebhook{URL: "https://discord.com/api/webhooks/1/x"}).PayloadFormat(); got != config.NotifyFormatDiscord {
+		t.Fatalf("discord.com URL detected as %s", got)
+	}
+}
+
+func TestSendTestReportsEachWebhook(t *testing.T) {
+	_, okURL := newWebhookRecorder(t)
+	_, badURL := newWebhookRecorder(t, http.StatusForbidden)
+	n := newTestNotifier(config.NotificationsConfig{Webhooks: []config.NotificationWebhook{{URL: okURL}, {URL: badURL + "/secret-token", Format: "discord"}}})
+	results := n.SendTest(context.Background())
+	if len(results) != 2 || !results[0].OK || results[1].OK {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if strings.Contains(results[1].Error, "secret-token") || results[1].Format != config.NotifyFormatDiscord {
+		t.Fatalf("result should name the format and hide the URL: %+v", results[1])
+	}
+}
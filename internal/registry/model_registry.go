@@ -11,8 +11,8 @@ import (
 	"sync"
 	"time"
 
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	misc "github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
-	log "github.com/sirupsen/logrus"
 )
 
 // ModelInfo represents information about an available model
@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"golang.org/x/sync/singleflight"
+)
+
+// staleRetryInterval spaces out refresh attempts after a failed fetch, so an upstream that is
+// rate limiting model list requests is not asked again on every lookup.
+const staleRetryInterval = 30 * time.Second
+
+// UpstreamModelListCache holds model lists fetched from upstream providers, keyed by
+// credential. Concurrent lookups of a missing or expired list share one fetch, and when a
+// refresh fails the previous list keeps being served and is reported as stale.
+type UpstreamModelListCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	entries    map[string]*upstreamModelList
+	generation uint64
+	// keyGenerations counts the invalidations of each key since the last InvalidateAll.
+	keyGenerations map[string]uint64
+	group          singleflight.Group
+}
+
+// cacheGeneration identifies the invalidations a fetch started after.
+type cacheGeneration struct {
+	all, key uint64
+}
+
+type upstreamModelList struct {
+	models    []*ModelInfo
+	expiresAt time.Time
+	stale     bool
+}
+
+var upstreamModelCache = NewUpstreamModelListCache(config.DefaultModelCacheTTL)
+
+// UpstreamModelCache returns the shared upstream model list cache.
+func UpstreamModelCache() *UpstreamModelListCache { return upstreamModelCache }
+
+// NewUpstreamModelListCache creates an empty cache keeping lists for ttl.
+func NewUpstreamModelListCache(ttl time.Duration) *UpstreamModelListCache {
+	return &UpstreamModelListCache{ttl: ttl, entries: make(map[string]*upstreamModelList), keyGenerations: make(map[string]uint64)}
+}
+
+// SetTTL changes how long fetched lists are reused; zero or negative refetches on every
+// lookup while still sharing concurrent fetches. Cached lists keep their current expiry.
+func (c *UpstreamModelListCache) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	c.ttl = ttl
+	c.mu.Unlock()
+}
+
+// Get returns the model list cached under key, calling fetch when it is missing or expired.
+// stale is true when fetch failed and an older list is returned instead; err is only set
+// when there is no list to fall back to.
+func (c *UpstreamModelListCache) Get(ctx context.Context, key string, fetch func(context.Context) ([]*ModelInfo, error)) (models []*ModelInfo, stale bool, err error) {
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok && time.Now().Before(entry.expiresAt) {
+		models, stale = slices.Clone(entry.models), entry.stale
+		c.mu.Unlock()
+		return models, stale, nil
+	}
+	generation := cacheGeneration{all: c.generation, key: c.keyGenerations[key]}
+	c.mu.Unlock()
+
+	// Lookups after an invalidation must not join a fetch that started before it.
+	flightKey := fmt.Sprintf("%d/%d/%s", generation.all, generation.key, key)
+	value, err, _ := c.group.Do(flightKey, func() (any, error) {
+		fetched, errFetch := fetch(ctx)
+		return c.store(key, generation, fetched, errFetch)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	result := value.(upstreamModelList)
+	return slices.Clone(result.models), result.stale, nil
+}
+
+// store records the outcome of a fetch started at generation and returns the list to serve.
+// A failed fetch falls back to the cached list, marked stale, and is retried after a pause.
+// Results of fetches overtaken by an invalidation are returned but not cached.
+func (c *UpstreamModelListCache) store(key string, generation cacheGeneration, models []*ModelInfo, errFetch error) (upstreamModelList, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	current := generation == cacheGeneration{all: c.generation, key: c.keyGenerations[key]}
+	if errFetch == nil {
+		if current {
+			c.entries[key] = &upstreamModelList{models: models, expiresAt: now.Add(c.ttl)}
+		}
+		return upstreamModelList{models: models}, nil
+	}
+	entry, exists := c.entries[key]
+	if !exists || !current {
+		return upstreamModelList{}, errFetch
+	}
+	retry := staleRetryInterval
+	if c.ttl > 0 && c.ttl < retry {
+		retry = c.ttl
+	}
+	entry.stale = true
+	entry.expiresAt = now.Add(retry)
+	return upstreamModelList{models: entry.models, stale: true}, nil
+}
+
+// Stale reports whether any cached list is being served after a failed refresh.
+func (c *UpstreamModelListCache) Stale() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range c.entries {
+		if entry.stale {
+			return true
+		}
+	}
+	return false
+}
+
+// Invalidate drops the list cached under key; the next lookup fetches it again. Fetches of
+// other keys in flight are not affected.
+func (c *UpstreamModelListCache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	c.keyGenerations[key]++
+}
+
+// InvalidateAll drops every cached list and returns how many there were.
+func (c *UpstreamModelListCache) InvalidateAll() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cleared := len(c.entries)
+	c.entries = make(map[string]*upstreamModelList)
+	c.keyGenerations = make(map[string]uint64)
+	c.generation++
+	return cleared
+}
@@ -0,0 +1,144 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUpstreamModelCacheCoalescesStampede(t *testing.T) {
+	cache := NewUpstreamModelListCache(time.Minute)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fetch := func(context.Context) ([]*ModelInfo, error) {
+		calls.Add(1)
+		<-release
+		return []*ModelInfo{{ID: "model-a"}}, nil
+	}
+
+	var wg sync.WaitGroup
+	var started sync.WaitGroup
+	results := make(chan []*ModelInfo, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		started.Add(1)
+		go func() {
+			defer wg.Done()
+			started.Done()
+			models, stale, err := cache.Get(context.Background(), "auth-1", fetch)
+			if err != nil || stale {
+				t.Errorf("Get: stale=%t err=%v", stale, err)
+			}
+			results <- models
+		}()
+	}
+	started.Wait()
+	// Let every caller reach the shared fetch before it completes.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("50 concurrent misses made %d upstream calls, want 1", got)
+	}
+	for models := range results {
+		if len(models) != 1 || models[0].ID != "model-a" {
+			t.Fatalf("unexpected models %v", models)
+		}
+	}
+	if _, _, err := cache.Get(context.Background(), "auth-1", fetch); err != nil || calls.Load() != 1 {
+		t.Fatalf("cached lookup fetched again: calls=%d err=%v", calls.Load(), err)
+	}
+}
+
+func TestUpstreamModelCacheServesStaleOnFailure(t *testing.T) {
+	cache := NewUpstreamModelListCache(0)
+	ok := func(context.Context) ([]*ModelInfo, error) { return []*ModelInfo{{ID: "model-a"}}, nil }
+	failing := func(context.Context) ([]*ModelInfo, error) { return nil, errors.New("429 rate limited") }
+
+	if _, _, err := cache.Get(context.Background(), "auth-1", ok); err != nil {
+		t.Fatalf("initial fetch: %v", err)
+	}
+	models, stale, err := cache.Get(context.Background(), "auth-1", failing)
+	if err != nil || !stale || len(models) != 1 || models[0].ID != "model-a" {
+		t.Fatalf("after failed refresh: models=%v stale=%t err=%v", models, stale, err)
+	}
+	if !cache.Stale() {
+		t.Fatal("cache does not report the stale list")
+	}
+	if _, _, err = cache.Get(context.Background(), "auth-2", failing); err == nil {
+		t.Fatal("failed fetch without a cached list returned no error")
+	}
+}
+
+func TestUpstreamModelCacheInvalidation(t *testing.T) {
+	cache := NewUpstreamModelListCache(time.Minute)
+	var calls atomic.Int32
+	fetch := func(context.Context) ([]*ModelInfo, error) {
+		calls.Add(1)
+		return []*ModelInfo{{ID: "model-a"}}, nil
+	}
+	_, _, _ = cache.Get(context.Background(), "auth-1", fetch)
+	cache.Invalidate("auth-1")
+	_, _, _ = cache.Get(context.Background(), "auth-1", fetch)
+	if calls.Load() != 2 {
+		t.Fatalf("Invalidate did not force a fetch: %d calls", calls.Load())
+	}
+
+	// A fetch that started before an invalidation must not repopulate the cache.
+	inFlight := make(chan struct{})
+	finish := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _, _ = cache.Get(context.Background(), "auth-2", func(context.Context) ([]*ModelInfo, error) {
+			close(inFlight)
+			<-finish
+			return []*ModelInfo{{ID: "old"}}, nil
+		})
+	}()
+	<-inFlight
+	if cleared := cache.InvalidateAll(); cleared != 1 {
+		t.Fatalf("InvalidateAll cleared %d lists, want 1", cleared)
+	}
+	close(finish)
+	<-done
+	models, _, _ := cache.Get(context.Background(), "auth-2", fetch)
+	if len(models) != 1 || models[0].ID != "model-a" {
+		t.Fatalf("list fetched before invalidation was cached: %v", models)
+	}
+}
+
+func TestUpstreamModelCacheInvalidateIsPerKey(t *testing.T) {
+	cache := NewUpstreamModelListCache(time.Minute)
+	var calls atomic.Int32
+	fetch := func(context.Context) ([]*ModelInfo, error) {
+		calls.Add(1)
+		return []*ModelInfo{{ID: "model-a"}}, nil
+	}
+
+	// Invalidating another key must not discard a fetch in flight.
+	inFlight := make(chan struct{})
+	finish := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _, _ = cache.Get(context.Background(), "auth-1", func(ctx context.Context) ([]*ModelInfo, error) {
+			close(inFlight)
+			<-finish
+			return fetch(ctx)
+		})
+	}()
+	<-inFlight
+	cache.Invalidate("auth-2")
+	close(finish)
+	<-done
+	_, _, _ = cache.Get(context.Background(), "auth-1", fetch)
+	if calls.Load() != 1 {
+		t.Fatalf("invalidating auth-2 dropped the list of auth-1: %d fetches", calls.Load())
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
@@ -30,7 +31,6 @@ import (
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
-	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -147,6 +147,8 @@ func (e *AntigravityExecutor) Execute(ctx context.Context, auth *cliproxyauth.Au
 
 	requestedModel := payloadRequestedModel(opts, req.Model)
 	translated = applyPayloadConfigWithRoot(e.cfg, baseModel, "antigravity", "request", translated, originalTranslated, requestedModel)
+	translated = applyMaxOutputTokens(ctx, e.cfg, baseModel, "antigravity", "request", translated, originalPayload, requestedModel)
+	translated = applySystemPromptPolicy(ctx, e.cfg, "antigravity", "request", translated)
 
 	baseURLs := antigravityBaseURLFallbackOrder(auth)
 	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
@@ -230,7 +232,7 @@ attemptLoop:
 
 			reporter.publish(ctx, parseAntigravityUsage(bodyBytes))
 			var param any
-			converted := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, bodyBytes, &param)
+			converted := translateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, bodyBytes, &param)
 			resp = cliproxyexecutor.Response{Payload: []byte(converted)}
 			reporter.ensurePublished(ctx)
 			return resp, nil
@@ -288,6 +290,8 @@ func (e *AntigravityExecutor) executeClaudeNonStream(ctx context.Context, auth *
 
 	requestedModel := payloadRequestedModel(opts, req.Model)
 	translated = applyPayloadConfigWithRoot(e.cfg, baseModel, "antigravity", "request", translated, originalTranslated, requestedModel)
+	translated = applyMaxOutputTokens(ctx, e.cfg, baseModel, "antigravity", "request", translated, originalPayload, requestedModel)
+	translated = applySystemPromptPolicy(ctx, e.cfg, "antigravity", "request", translated)
 
 	baseURLs := antigravityBaseURLFallbackOrder(auth)
 	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
@@ -384,6 +388,7 @@ attemptLoop:
 			out := make(chan cliproxyexecutor.StreamChunk)
 			go func(resp *http.Response) {
 				defer close(out)
+				defer reporter.ensurePublished(ctx)
 				defer func() {
 					if errClose := resp.Body.Close(); errClose != nil {
 						log.Errorf("antigravity executor: close response body error: %v", errClose)
@@ -405,7 +410,7 @@ attemptLoop:
 					}
 
 					if detail, ok := parseAntigravityStreamUsage(payload); ok {
-						reporter.publish(ctx, detail)
+						reporter.observe(detail)
 					}
 
 					out <- cliproxyexecutor.StreamChunk{Payload: payload}
@@ -433,7 +438,7 @@ attemptLoop:
 
 			reporter.publish(ctx, parseAntigravityUsage(resp.Payload))
 			var param any
-			converted := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, resp.Payload, &param)
+			converted := translateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, resp.Payload, &param)
 			resp = cliproxyexecutor.Response{Payload: []byte(converted)}
 			reporter.ensurePublished(ctx)
 
@@ -679,6 +684,8 @@ func (e *AntigravityExecutor) ExecuteStream(ctx context.Context, auth *cliproxya
 
 	requestedModel := payloadRequestedModel(opts, req.Model)
 	translated = applyPayloadConfigWithRoot(e.cfg, baseModel, "antigravity", "request", translated, originalTranslated, requestedModel)
+	translated = applyMaxOutputTokens(ctx, e.cfg, baseModel, "antigravity", "request", translated, originalPayload, requestedModel)
+	translated = applySystemPromptPolicy(ctx, e.cfg, "antigravity", "request", translated)
 
 	baseURLs := antigravityBaseURLFallbackOrder(auth)
 	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
@@ -775,6 +782,7 @@ attemptLoop:
 			stream = out
 			go func(resp *http.Response) {
 				defer close(out)
+				defer reporter.ensurePublished(ctx)
 				defer func() {
 					if errClose := resp.Body.Close(); errClose != nil {
 						log.Errorf("antigravity executor: close response body error: %v", errClose)
@@ -782,7 +790,7 @@ attemptLoop:
 				}()
 				scanner := bufio.NewScanner(resp.Body)
 				scanner.Buffer(nil, streamScannerBuffer)
-				var param any
+				translator := newStreamTranslator(ctx, to, from, req.Model, opts.OriginalRequest, translated)
 				for scanner.Scan() {
 					line := scanner.Bytes()
 					appendAPIResponseChunk(ctx, e.cfg, line)
@@ -797,15 +805,15 @@ attemptLoop:
 					}
 
 					if detail, ok := parseAntigravityStreamUsage(payload); ok {
-						reporter.publish(ctx, detail)
+						reporter.observe(detail)
 					}
 
-					chunks := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, bytes.Clone(payload), &param)
+					chunks := translator.translate(payload)
 					for i := range chunks {
 						out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunks[i])}
 					}
 				}
-				tail := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, []byte("[DONE]"), &param)
+				tail := translator.translateDone()
 				for i := range tail {
 					out <- cliproxyexecutor.StreamChunk{Payload: []byte(tail[i])}
 				}
@@ -964,6 +972,7 @@ func (e *AntigravityExecutor) CountTokens(ctx context.Context, auth *cliproxyaut
 
 		if httpResp.StatusCode >= http.StatusOK && httpResp.StatusCode < http.StatusMultipleChoices {
 			count := gjson.GetBytes(bodyBytes, "totalTokens").Int()
+			newUsageReporter(respCtx, e.Identifier(), baseModel, auth).publishCount(respCtx, count, false)
 			translated := sdktranslator.TranslateTokenCount(respCtx, to, from, count, bodyBytes)
 			return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
 		}
@@ -1000,12 +1009,31 @@ func (e *AntigravityExecutor) CountTokens(ctx context.Context, auth *cliproxyaut
 	}
 }
 
-// FetchAntigravityModels retrieves available models using the supplied auth.
+// FetchAntigravityModels retrieves available models using the supplied auth. Lists are
+// cached per credential in registry.UpstreamModelCache: concurrent callers share one
+// upstream request, and the last good list is returned when a refresh fails.
 func FetchAntigravityModels(ctx context.Context, auth *cliproxyauth.Auth, cfg *config.Config) []*registry.ModelInfo {
+	if auth == nil {
+		return nil
+	}
+	models, _, err := registry.UpstreamModelCache().Get(ctx, auth.ID, func(ctx context.Context) ([]*registry.ModelInfo, error) {
+		return fetchAntigravityModels(ctx, auth, cfg)
+	})
+	if err != nil {
+		log.Debugf("antigravity executor: fetch models for %s: %v", auth.ID, err)
+		return nil
+	}
+	return models
+}
+
+func fetchAntigravityModels(ctx context.Context, auth *cliproxyauth.Auth, cfg *config.Config) ([]*registry.ModelInfo, error) {
 	exec := &AntigravityExecutor{cfg: cfg}
 	token, updatedAuth, errToken := exec.ensureAccessToken(ctx, auth)
-	if errToken != nil || token == "" {
-		return nil
+	if errToken != nil {
+		return nil, errToken
+	}
+	if token == "" {
+		return nil, statusErr{code: http.StatusUnauthorized, msg: "antigravity executor: missing access token"}
 	}
 	if updatedAuth != nil {
 		auth = updatedAuth
@@ -1018,7 +1046,7 @@ func FetchAntigravityModels(ctx context.Context, auth *cliproxyauth.Auth, cfg *c
 		modelsURL := baseURL + antigravityModelsPath
 		httpReq, errReq := http.NewRequestWithContext(ctx, http.MethodPost, modelsURL, bytes.NewReader([]byte(`{}`)))
 		if errReq != nil {
-			return nil
+			return nil, errReq
 		}
 		httpReq.Header.Set("Content-Type", "application/json")
 		httpReq.Header.Set("Authorization", "Bearer "+token)
@@ -1030,13 +1058,13 @@ func FetchAntigravityModels(ctx context.Context, auth *cliproxyauth.Auth, cfg *c
 		httpResp, errDo := httpClient.Do(httpReq)
 		if errDo != nil {
 			if errors.Is(errDo, context.Canceled) || errors.Is(errDo, context.DeadlineExceeded) {
-				return nil
+				return nil, errDo
 			}
 			if idx+1 < len(baseURLs) {
 				log.Debugf("antigravity executor: models request error on base url %s, retrying with fallback base url: %s", baseURL, baseURLs[idx+1])
 				continue
 			}
-			return nil
+			return nil, errDo
 		}
 
 		bodyBytes, errRead := io.ReadAll(httpResp.Body)
@@ -1048,19 +1076,19 @@ func FetchAntigravityModels(ctx context.Context, auth *cliproxyauth.Auth, cfg *c
 				log.Debugf("antigravity executor: models read error on base url %s, retrying with fallback base url: %s", baseURL, baseURLs[idx+1])
 				continue
 			}
-			return nil
+			return nil, errRead
 		}
 		if httpResp.StatusCode < http.StatusOK || httpResp.StatusCode >= http.StatusMultipleChoices {
 			if httpResp.StatusCode == http.StatusTooManyRequests && idx+1 < len(baseURLs) {
 				log.Debugf("antigravity executor: models request rate limited on base url %s, retrying with fallback base url: %s", baseURL, baseURLs[idx+1])
 				continue
 			}
-			return nil
+			return nil, statusErr{code: httpResp.StatusCode, msg: string(bodyBytes)}
 		}
 
 		result := gjson.GetBytes(bodyBytes, "models")
 		if !result.Exists() {
-			return nil
+			return nil, statusErr{code: http.StatusBadGateway, msg: "antigravity executor: models response has no models"}
 		}
 
 		now := time.Now().Unix()
@@ -1105,9 +1133,9 @@ func FetchAntigravityModels(ctx context.Context, auth *cliproxyauth.Auth, cfg *c
 			}
 			models = append(models, modelInfo)
 		}
-		return models
+		return models, nil
 	}
-	return nil
+	return nil, statusErr{code: http.StatusServiceUnavailable, msg: "antigravity executor: no base url available"}
 }
 
 func (e *AntigravityExecutor) ensureAccessToken(ctx context.Context, auth *cliproxyauth.Auth) (string, *cliproxyauth.Auth, error) {
@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// usageRecordsFor forwards the usage records published for model.
+type usageRecordsFor struct {
+	model   string
+	records chan usage.Record
+}
+
+func (p *usageRecordsFor) HandleUsage(_ context.Context, record usage.Record) {
+	if record.Model != p.model {
+		return
+	}
+	select {
+	case p.records <- record:
+	default:
+	}
+}
+
+// TestClientDisconnectCancelsUpstreamStream puts the executor behind a real HTTP front end,
+// drops the client after the first event, and expects the upstream request to be cancelled
+// and the usage to be recorded as cancelled with the tokens reported so far.
+func TestClientDisconnectCancelsUpstreamStream(t *testing.T) {
+	const model = "client-cancel-model"
+	plugin := &usageRecordsFor{model: model, records: make(chan usage.Record, 1)}
+	usage.RegisterPlugin(plugin)
+
+	upstreamCancelled := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", `{"id":"c1","model":"`+model+`","choices":[{"index":0,"delta":{"role":"assistant","content":"hello"}}],"usage":{"prompt_tokens":7,"completion_tokens":3,"total_tokens":10}}`)
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+			close(upstreamCancelled)
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer upstream.Close()
+
+	executor := NewOpenAICompatExecutor("openai-compatibility", &config.Config{})
+	auth := &cliproxyauth.Auth{Attributes: map[string]string{"base_url": upstream.URL + "/v1", "api_key": "test"}}
+	payload := []byte(`{"model":"` + model + `","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	streamDone := make(chan struct{})
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(streamDone)
+		stream, err := executor.ExecuteStream(r.Context(), auth, cliproxyexecutor.Request{Model: model, Payload: payload},
+			cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai"), OriginalRequest: payload, Stream: true})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		for chunk := range stream {
+			_, _ = w.Write(append(chunk.Payload, '\n'))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer front.Close()
+
+	resp, err := http.Post(front.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if _, err = bufio.NewReader(resp.Body).ReadString('\n'); err != nil {
+		t.Fatalf("read first event: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	select {
+	case <-upstreamCancelled:
+	case <-time.After(3 * time.Second):
+		t.Fatal("upstream request kept running after the client disconnected")
+	}
+	select {
+	case <-streamDone:
+	case <-time.After(3 * time.Second):
+		t.Fatal("executor stream did not end after the client disconnected")
+	}
+	select {
+	case record := <-plugin.records:
+		if !record.Cancelled || record.Failed || record.Detail.TotalTokens != 10 {
+			t.Fatalf("usage record = %+v, want cancelled with 10 partial tokens", record)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no usage record published for the cancelled request")
+	}
+}
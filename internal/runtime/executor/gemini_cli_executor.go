@@ -18,6 +18,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/geminicli"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
@@ -25,7 +26,6 @@ import (
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
-	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 	"golang.org/x/oauth2"
@@ -134,6 +134,8 @@ func (e *GeminiCLIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 	basePayload = fixGeminiCLIImageAspectRatio(baseModel, basePayload)
 	requestedModel := payloadRequestedModel(opts, req.Model)
 	basePayload = applyPayloadConfigWithRoot(e.cfg, baseModel, "gemini", "request", basePayload, originalTranslated, requestedModel)
+	basePayload = applyMaxOutputTokens(ctx, e.cfg, baseModel, "gemini", "request", basePayload, originalPayload, requestedModel)
+	basePayload = applySystemPromptPolicy(ctx, e.cfg, "gemini", "request", basePayload)
 
 	action := "generateContent"
 	if req.Metadata != nil {
@@ -223,7 +225,7 @@ func (e *GeminiCLIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 		if httpResp.StatusCode >= 200 && httpResp.StatusCode < 300 {
 			reporter.publish(ctx, parseGeminiCLIUsage(data))
 			var param any
-			out := sdktranslator.TranslateNonStream(respCtx, to, from, attemptModel, bytes.Clone(opts.OriginalRequest), payload, data, &param)
+			out := translateNonStream(respCtx, to, from, attemptModel, bytes.Clone(opts.OriginalRequest), payload, data, &param)
 			resp = cliproxyexecutor.Response{Payload: []byte(out)}
 			return resp, nil
 		}
@@ -240,7 +242,7 @@ func (e *GeminiCLIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 			continue
 		}
 
-		err = newGeminiStatusErr(httpResp.StatusCode, data)
+		err = withGeminiProjectHint(newGeminiStatusErr(httpResp.StatusCode, data), projectID)
 		return resp, err
 	}
 
@@ -287,6 +289,8 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 	basePayload = fixGeminiCLIImageAspectRatio(baseModel, basePayload)
 	requestedModel := payloadRequestedModel(opts, req.Model)
 	basePayload = applyPayloadConfigWithRoot(e.cfg, baseModel, "gemini", "request", basePayload, originalTranslated, requestedModel)
+	basePayload = applyMaxOutputTokens(ctx, e.cfg, baseModel, "gemini", "request", basePayload, originalPayload, requestedModel)
+	basePayload = applySystemPromptPolicy(ctx, e.cfg, "gemini", "request", basePayload)
 
 	projectID := resolveGeminiProjectID(auth)
 
@@ -375,7 +379,7 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 				}
 				continue
 			}
-			err = newGeminiStatusErr(httpResp.StatusCode, data)
+			err = withGeminiProjectHint(newGeminiStatusErr(httpResp.StatusCode, data), projectID)
 			return nil, err
 		}
 
@@ -383,6 +387,7 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 		stream = out
 		go func(resp *http.Response, reqBody []byte, attemptModel string) {
 			defer close(out)
+			defer reporter.ensurePublished(ctx)
 			defer func() {
 				if errClose := resp.Body.Close(); errClose != nil {
 					log.Errorf("gemini cli executor: close response body error: %v", errClose)
@@ -391,22 +396,22 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 			if opts.Alt == "" {
 				scanner := bufio.NewScanner(resp.Body)
 				scanner.Buffer(nil, streamScannerBuffer)
-				var param any
+				translator := newStreamTranslator(respCtx, to, from, attemptModel, opts.OriginalRequest, reqBody)
 				for scanner.Scan() {
 					line := scanner.Bytes()
 					appendAPIResponseChunk(ctx, e.cfg, line)
 					if detail, ok := parseGeminiCLIStreamUsage(line); ok {
-						reporter.publish(ctx, detail)
+						reporter.observe(detail)
 					}
 					if bytes.HasPrefix(line, dataTag) {
-						segments := sdktranslator.TranslateStream(respCtx, to, from, attemptModel, bytes.Clone(opts.OriginalRequest), reqBody, bytes.Clone(line), &param)
+						segments := translator.translate(line)
 						for i := range segments {
 							out <- cliproxyexecutor.StreamChunk{Payload: []byte(segments[i])}
 						}
 					}
 				}
 
-				segments := sdktranslator.TranslateStream(respCtx, to, from, attemptModel, bytes.Clone(opts.OriginalRequest), reqBody, bytes.Clone([]byte("[DONE]")), &param)
+				segments := translator.translateDone()
 				for i := range segments {
 					out <- cliproxyexecutor.StreamChunk{Payload: []byte(segments[i])}
 				}
@@ -427,13 +432,13 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 			}
 			appendAPIResponseChunk(ctx, e.cfg, data)
 			reporter.publish(ctx, parseGeminiCLIUsage(data))
-			var param any
-			segments := sdktranslator.TranslateStream(respCtx, to, from, attemptModel, bytes.Clone(opts.OriginalRequest), reqBody, data, &param)
+			translator := newStreamTranslator(respCtx, to, from, attemptModel, opts.OriginalRequest, reqBody)
+			segments := translator.translate(data)
 			for i := range segments {
 				out <- cliproxyexecutor.StreamChunk{Payload: []byte(segments[i])}
 			}
 
-			segments = sdktranslator.TranslateStream(respCtx, to, from, attemptModel, bytes.Clone(opts.OriginalRequest), reqBody, bytes.Clone([]byte("[DONE]")), &param)
+			segments = translator.translateDone()
 			for i := range segments {
 				out <- cliproxyexecutor.StreamChunk{Payload: []byte(segments[i])}
 			}
@@ -543,6 +548,7 @@ func (e *GeminiCLIExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.
 		appendAPIResponseChunk(ctx, e.cfg, data)
 		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 			count := gjson.GetBytes(data, "totalTokens").Int()
+			newUsageReporter(respCtx, e.Identifier(), baseModel, auth).publishCount(respCtx, count, false)
 			translated := sdktranslator.TranslateTokenCount(respCtx, to, from, count, data)
 			return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
 		}
@@ -852,6 +858,20 @@ func newGeminiStatusErr(statusCode int, body []byte) statusErr {
 	return err
 }
 
+// withGeminiProjectHint replaces a bare 403 from Cloud Code with a message that names the
+// configured project and how to fix it, keeping the upstream reason for reference.
+func withGeminiProjectHint(err statusErr, projectID string) statusErr {
+	if err.code != http.StatusForbidden || strings.TrimSpace(projectID) == "" {
+		return err
+	}
+	reason := strings.TrimSpace(gjson.Get(err.msg, "error.message").String())
+	if reason == "" {
+		reason = strings.TrimSpace(err.msg)
+	}
+	err.msg = fmt.Sprintf("Google Cloud project %q denied the request. Make sure the Gemini for Google Cloud API (cloudaicompanion.googleapis.com) is enabled for this project and the account has access, or switch the credential to another project via the management API (PATCH /v0/management/auth-files/project) or -set-project. Upstream error: %s", projectID, reason)
+	return err
+}
+
 // parseRetryDelay extracts the retry delay from a Google API 429 error response.
 // The error response contains a RetryInfo.retryDelay field in the format "0.847655010s".
 // Returns the parsed duration or an error if it cannot be determined.
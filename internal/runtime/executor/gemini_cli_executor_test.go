@@ -0,0 +1,31 @@
+package executor
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWithGeminiProjectHintRewritesForbidden(t *testing.T) {
+	err := statusErr{code: http.StatusForbidden, msg: `{"error":{"code":403,"message":"Permission denied on resource project demo"}}`}
+	got := withGeminiProjectHint(err, "demo")
+	if got.code != http.StatusForbidden {
+		t.Fatalf("code = %d, want 403", got.code)
+	}
+	for _, want := range []string{`"demo"`, "cloudaicompanion.googleapis.com", "/v0/management/auth-files/project", "Permission denied on resource project demo"} {
+		if !strings.Contains(got.msg, want) {
+			t.Fatalf("hint %q missing %q", got.msg, want)
+		}
+	}
+}
+
+func TestWithGeminiProjectHintLeavesOtherErrors(t *testing.T) {
+	err := statusErr{code: http.StatusBadRequest, msg: "bad request"}
+	if got := withGeminiProjectHint(err, "demo"); got.msg != "bad request" {
+		t.Fatalf("msg = %q, want unchanged", got.msg)
+	}
+	forbidden := statusErr{code: http.StatusForbidden, msg: "denied"}
+	if got := withGeminiProjectHint(forbidden, ""); got.msg != "denied" {
+		t.Fatalf("msg = %q, want unchanged without project", got.msg)
+	}
+}
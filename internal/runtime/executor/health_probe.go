@@ -0,0 +1,147 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	iflowauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/iflow"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// ProbeHealth lists models on the OpenAI-compatible upstream to verify the credential.
+func (e *OpenAICompatExecutor) ProbeHealth(ctx context.Context, auth *cliproxyauth.Auth) error {
+	baseURL, _ := e.resolveCredentials(auth)
+	if baseURL == "" {
+		return cliproxyauth.ErrHealthProbeUnsupported
+	}
+	return probeModelsEndpoint(ctx, strings.TrimSuffix(baseURL, "/")+"/models", nil, func(req *http.Request) (*http.Response, error) {
+		return e.HttpRequest(ctx, auth, req)
+	})
+}
+
+// ProbeHealth lists a single model with the Gemini API key or OAuth token.
+func (e *GeminiExecutor) ProbeHealth(ctx context.Context, auth *cliproxyauth.Auth) error {
+	if apiKey, bearer := geminiCreds(auth); apiKey == "" && bearer == "" {
+		return cliproxyauth.ErrHealthProbeUnsupported
+	}
+	target := resolveGeminiBaseURL(auth) + "/v1beta/models?pageSize=1"
+	return probeModelsEndpoint(ctx, target, nil, func(req *http.Request) (*http.Response, error) {
+		return e.HttpRequest(ctx, auth, req)
+	})
+}
+
+// ProbeHealth lists models with the Claude API key or OAuth token.
+func (e *ClaudeExecutor) ProbeHealth(ctx context.Context, auth *cliproxyauth.Auth) error {
+	apiKey, baseURL := claudeCreds(auth)
+	if strings.TrimSpace(apiKey) == "" {
+		return cliproxyauth.ErrHealthProbeUnsupported
+	}
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com"
+	}
+	headers := http.Header{"Anthropic-Version": []string{"2023-06-01"}}
+	if auth.Attributes == nil || strings.TrimSpace(auth.Attributes["api_key"]) == "" {
+		headers.Set("Anthropic-Beta", "oauth-2025-04-20")
+	}
+	return probeModelsEndpoint(ctx, strings.TrimSuffix(baseURL, "/")+"/v1/models", headers, func(req *http.Request) (*http.Response, error) {
+		return e.HttpRequest(ctx, auth, req)
+	})
+}
+
+// ProbeHealth lists models with the Codex API key or ChatGPT OAuth token.
+func (e *CodexExecutor) ProbeHealth(ctx context.Context, auth *cliproxyauth.Auth) error {
+	token, baseURL := codexCreds(auth)
+	if strings.TrimSpace(token) == "" {
+		return cliproxyauth.ErrHealthProbeUnsupported
+	}
+	if baseURL == "" {
+		baseURL = "https://chatgpt.com/backend-api/codex"
+	}
+	return probeModelsEndpoint(ctx, strings.TrimSuffix(baseURL, "/")+"/models?client_version=0.50.0", nil, func(req *http.Request) (*http.Response, error) {
+		applyCodexHeaders(req, auth, token, false)
+		return e.HttpRequest(ctx, auth, req)
+	})
+}
+
+// ProbeHealth loads the Code Assist settings of the Gemini CLI account, which needs a valid
+// (and, when expired, refreshable) OAuth token.
+func (e *GeminiCLIExecutor) ProbeHealth(ctx context.Context, auth *cliproxyauth.Auth) error {
+	target := fmt.Sprintf("%s/%s:loadCodeAssist", codeAssistEndpoint, codeAssistVersion)
+	body := `{"metadata":{"ideType":"IDE_UNSPECIFIED","platform":"PLATFORM_UNSPECIFIED","pluginType":"GEMINI"}}`
+	return probeEndpoint(ctx, http.MethodPost, target, body, http.Header{"Content-Type": []string{"application/json"}}, func(req *http.Request) (*http.Response, error) {
+		return e.HttpRequest(ctx, auth, req)
+	})
+}
+
+// ProbeHealth lists the models available to the Antigravity account.
+func (e *AntigravityExecutor) ProbeHealth(ctx context.Context, auth *cliproxyauth.Auth) error {
+	_, err := fetchAntigravityModels(ctx, auth, e.cfg)
+	if se, ok := err.(statusErr); ok && se.code == http.StatusTooManyRequests {
+		return nil
+	}
+	return err
+}
+
+// ProbeHealth lists models with the Qwen OAuth token.
+func (e *QwenExecutor) ProbeHealth(ctx context.Context, auth *cliproxyauth.Auth) error {
+	token, baseURL := qwenCreds(auth)
+	if strings.TrimSpace(token) == "" {
+		return cliproxyauth.ErrHealthProbeUnsupported
+	}
+	if baseURL == "" {
+		baseURL = "https://portal.qwen.ai/v1"
+	}
+	return probeModelsEndpoint(ctx, strings.TrimSuffix(baseURL, "/")+"/models", nil, func(req *http.Request) (*http.Response, error) {
+		return e.HttpRequest(ctx, auth, req)
+	})
+}
+
+// ProbeHealth lists models with the iFlow API key obtained at login.
+func (e *IFlowExecutor) ProbeHealth(ctx context.Context, auth *cliproxyauth.Auth) error {
+	apiKey, baseURL := iflowCreds(auth)
+	if apiKey == "" {
+		return cliproxyauth.ErrHealthProbeUnsupported
+	}
+	if baseURL == "" {
+		baseURL = iflowauth.DefaultAPIBaseURL
+	}
+	return probeModelsEndpoint(ctx, strings.TrimSuffix(baseURL, "/")+"/models", nil, func(req *http.Request) (*http.Response, error) {
+		return e.HttpRequest(ctx, auth, req)
+	})
+}
+
+// probeModelsEndpoint issues a GET and treats 2xx and 429 as healthy; a rate limited
+// credential is still valid and is handled by the cooldown logic instead.
+func probeModelsEndpoint(ctx context.Context, target string, headers http.Header, do func(*http.Request) (*http.Response, error)) error {
+	return probeEndpoint(ctx, http.MethodGet, target, "", headers, do)
+}
+
+// probeEndpoint is probeModelsEndpoint for any method and request body.
+func probeEndpoint(ctx context.Context, method, target, body string, headers http.Header, do func(*http.Request) (*http.Response, error)) error {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	for key, values := range headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	resp, err := do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 || resp.StatusCode == http.StatusTooManyRequests {
+		return nil
+	}
+	return statusErr{code: resp.StatusCode, msg: fmt.Sprintf("health probe status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))}
+}
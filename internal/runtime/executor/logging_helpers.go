@@ -12,9 +12,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
-	log "github.com/sirupsen/logrus"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	"github.com/tidwall/gjson"
 )
 
@@ -49,8 +50,32 @@ type upstreamAttempt struct {
 	errorWritten         bool
 }
 
+// recordAuditUsage stores the account and tokens of the request in Gin context for the audit
+// log.
+func recordAuditUsage(ctx context.Context, provider, authIndex string, detail usage.Detail) {
+	if !logging.DefaultAuditLogger().Enabled() {
+		return
+	}
+	if ginCtx := ginContextFrom(ctx); ginCtx != nil {
+		ginCtx.Set(logging.AuditUsageKey, &logging.AuditUsage{
+			Provider:        provider,
+			Account:         authIndex,
+			InputTokens:     detail.InputTokens,
+			OutputTokens:    detail.OutputTokens,
+			ReasoningTokens: detail.ReasoningTokens,
+			CachedTokens:    detail.CachedTokens,
+			TotalTokens:     detail.TotalTokens,
+		})
+	}
+}
+
 // recordAPIRequest stores the upstream request metadata in Gin context for request logging.
 func recordAPIRequest(ctx context.Context, cfg *config.Config, info upstreamRequestLog) {
+	if logging.DefaultAuditLogger().Enabled() {
+		if ginCtx := ginContextFrom(ctx); ginCtx != nil {
+			ginCtx.Set(logging.AuditUpstreamRequestKey, bytes.Clone(info.Body))
+		}
+	}
 	if cfg == nil || !cfg.RequestLog {
 		return
 	}
@@ -0,0 +1,114 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// clampedTokensHeader reports "<requested>-><limit>" when max-output-tokens clamps a request.
+const clampedTokensHeader = "X-CPA-Max-Tokens-Clamped"
+
+// applyMaxOutputTokens enforces the first matching max-output-tokens rule on a translated
+// payload. The value is read from and written to the provider's own field, so it applies
+// regardless of which field name the client used. Whether the client set a limit at all is
+// read from its original request, since translators fill in one of their own.
+func applyMaxOutputTokens(ctx context.Context, cfg *config.Config, model, protocol, root string, payload, original []byte, requestedModel string) []byte {
+	if cfg == nil || len(cfg.MaxOutputTokens.Rules) == 0 || len(payload) == 0 {
+		return payload
+	}
+	candidates := payloadModelCandidates(model, requestedModel)
+	var rule *config.MaxOutputTokensRule
+	for i := range cfg.MaxOutputTokens.Rules {
+		if payloadModelRulesMatch(cfg.MaxOutputTokens.Rules[i].Models, protocol, candidates) {
+			rule = &cfg.MaxOutputTokens.Rules[i]
+			break
+		}
+	}
+	if rule == nil || rule.Limit <= 0 {
+		return payload
+	}
+
+	var path string
+	required := false
+	switch protocol {
+	case "claude":
+		path, required = "max_tokens", true
+	case "gemini", "gemini-cli", "antigravity":
+		path = buildPayloadPath(root, "generationConfig.maxOutputTokens")
+	case "codex":
+		// The Codex backend rejects explicit output limits; the translators strip them.
+		return payload
+	default:
+		path = "max_tokens"
+		if gjson.GetBytes(payload, "max_completion_tokens").Exists() {
+			path = "max_completion_tokens"
+		} else if usesMaxCompletionTokens(model) {
+			if value := gjson.GetBytes(payload, "max_tokens"); value.Exists() {
+				payload, _ = sjson.SetRawBytes(payload, "max_completion_tokens", []byte(value.Raw))
+				payload, _ = sjson.DeleteBytes(payload, "max_tokens")
+			}
+			path = "max_completion_tokens"
+		}
+	}
+
+	value := gjson.GetBytes(payload, path)
+	omitted := !value.Exists() || value.Type == gjson.Null
+	if len(original) > 0 {
+		omitted = !hasOutputTokenLimit(original)
+	}
+	if omitted && required {
+		payload, _ = sjson.SetBytes(payload, path, rule.DefaultTokens())
+		return payload
+	}
+	if !value.Exists() || value.Type == gjson.Null {
+		return payload
+	}
+	requested := value.Int()
+	if requested <= rule.Limit {
+		return payload
+	}
+	payload, _ = sjson.SetBytes(payload, path, rule.Limit)
+	log.Debugf("max-output-tokens: clamped %s from %d to %d for model %s", path, requested, rule.Limit, model)
+	if cfg.MaxOutputTokens.ClampHeader {
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+			ginCtx.Header(clampedTokensHeader, fmt.Sprintf("%d->%d", requested, rule.Limit))
+		}
+	}
+	return payload
+}
+
+// hasOutputTokenLimit reports whether a client request, in any supported format, sets an
+// output token limit.
+func hasOutputTokenLimit(request []byte) bool {
+	for _, path := range []string{
+		"max_tokens",
+		"max_completion_tokens",
+		"max_output_tokens",
+		"generationConfig.maxOutputTokens",
+		"request.generationConfig.maxOutputTokens",
+	} {
+		if value := gjson.GetBytes(request, path); value.Exists() && value.Type != gjson.Null {
+			return true
+		}
+	}
+	return false
+}
+
+// usesMaxCompletionTokens reports whether an OpenAI model only accepts max_completion_tokens
+// (the o-series and gpt-5 reasoning models).
+func usesMaxCompletionTokens(model string) bool {
+	lower := strings.ToLower(strings.TrimSpace(model))
+	for _, prefix := range []string{"o1", "o3", "o4", "gpt-5"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
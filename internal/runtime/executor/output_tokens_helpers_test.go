@@ -0,0 +1,69 @@
+package executor
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func maxOutputTokensConfig(name string) *config.Config {
+	return &config.Config{MaxOutputTokens: config.MaxOutputTokensConfig{
+		ClampHeader: true,
+		Rules: []config.MaxOutputTokensRule{{
+			Models:  []config.PayloadModelRule{{Name: name}},
+			Limit:   1000,
+			Default: 256,
+		}},
+	}}
+}
+
+func TestApplyMaxOutputTokensClampsWithHeader(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+
+	out := applyMaxOutputTokens(ctx, maxOutputTokensConfig("gemini-*"), "gemini-2.5-pro", "gemini", "request", []byte(`{"request":{"generationConfig":{"maxOutputTokens":128000}}}`), nil, "")
+	if got := gjson.GetBytes(out, "request.generationConfig.maxOutputTokens").Int(); got != 1000 {
+		t.Fatalf("maxOutputTokens = %d, want 1000", got)
+	}
+	if got := recorder.Header().Get(clampedTokensHeader); got != "128000->1000" {
+		t.Fatalf("%s = %q", clampedTokensHeader, got)
+	}
+}
+
+func TestApplyMaxOutputTokensDefaultsOnlyWhenRequired(t *testing.T) {
+	ctx := context.Background()
+	out := applyMaxOutputTokens(ctx, maxOutputTokensConfig("claude-*"), "claude-sonnet-4", "claude", "", []byte(`{"messages":[]}`), nil, "")
+	if got := gjson.GetBytes(out, "max_tokens").Int(); got != 256 {
+		t.Fatalf("claude max_tokens = %d, want default 256", got)
+	}
+	out = applyMaxOutputTokens(ctx, maxOutputTokensConfig("*"), "gpt-4o", "openai", "", []byte(`{"messages":[]}`), nil, "")
+	if gjson.GetBytes(out, "max_tokens").Exists() {
+		t.Fatalf("openai payloads must not get a default: %s", out)
+	}
+}
+
+func TestApplyMaxOutputTokensUsesCompletionFieldForReasoningModels(t *testing.T) {
+	out := applyMaxOutputTokens(context.Background(), maxOutputTokensConfig("o3*"), "o3-mini", "openai", "", []byte(`{"max_tokens":5000}`), nil, "")
+	if gjson.GetBytes(out, "max_tokens").Exists() || gjson.GetBytes(out, "max_completion_tokens").Int() != 1000 {
+		t.Fatalf("unexpected payload: %s", out)
+	}
+}
+
+func TestApplyMaxOutputTokensDefaultsOverTranslatorLimit(t *testing.T) {
+	ctx := context.Background()
+	// The OpenAI to Claude translator fills in max_tokens when the client sets none.
+	translated := []byte(`{"max_tokens":32000,"messages":[]}`)
+	out := applyMaxOutputTokens(ctx, maxOutputTokensConfig("claude-*"), "claude-sonnet-4", "claude", "", translated, []byte(`{"messages":[]}`), "")
+	if got := gjson.GetBytes(out, "max_tokens").Int(); got != 256 {
+		t.Fatalf("max_tokens = %d, want the default 256 for a client that set no limit", got)
+	}
+	out = applyMaxOutputTokens(ctx, maxOutputTokensConfig("claude-*"), "claude-sonnet-4", "claude", "", []byte(`{"max_tokens":800,"messages":[]}`), []byte(`{"max_completion_tokens":800}`), "")
+	if got := gjson.GetBytes(out, "max_tokens").Int(); got != 800 {
+		t.Fatalf("max_tokens = %d, want the client's 800 kept", got)
+	}
+}
@@ -0,0 +1,267 @@
+package executor
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+// responseCacheHitKey marks, on the gin context, a request answered from the response cache.
+const responseCacheHitKey = "RESPONSE_CACHE_HIT"
+
+// responseCache is an LRU of upstream response bodies keyed by request hash. Limits are
+// re-read from the configuration on every use, so reloads resize it in place.
+type responseCache struct {
+	mu         sync.Mutex
+	order      *list.List
+	entries    map[string]*list.Element
+	bytes      int64
+	maxEntries int
+	maxBytes   int64
+}
+
+type responseCacheEntry struct {
+	key     string
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+var defaultResponseCache = newResponseCache()
+
+func newResponseCache() *responseCache {
+	return &responseCache{order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *responseCache) get(key string, now time.Time) (*responseCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*responseCacheEntry)
+	if !now.Before(entry.expires) {
+		c.removeLocked(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry, true
+}
+
+func (c *responseCache) put(entry *responseCacheEntry, maxEntries int, maxBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxEntries, c.maxBytes = maxEntries, maxBytes
+	if maxBytes > 0 && int64(len(entry.body)) > maxBytes {
+		return
+	}
+	if elem, ok := c.entries[entry.key]; ok {
+		c.removeLocked(elem)
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	c.bytes += int64(len(entry.body))
+	c.evictLocked()
+}
+
+func (c *responseCache) evictLocked() {
+	for c.order.Len() > 0 && (c.order.Len() > c.maxEntries || (c.maxBytes > 0 && c.bytes > c.maxBytes)) {
+		c.removeLocked(c.order.Back())
+	}
+}
+
+func (c *responseCache) removeLocked(elem *list.Element) {
+	entry := c.order.Remove(elem).(*responseCacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= int64(len(entry.body))
+}
+
+// responseCacheTransport answers cacheable requests from the cache and stores successful
+// upstream answers to them.
+type responseCacheTransport struct {
+	base     http.RoundTripper
+	cache    *responseCache
+	provider string
+	settings config.ResponseCacheConfig
+}
+
+// withResponseCache wraps base when the response cache is enabled.
+func withResponseCache(cfg *config.Config, provider string, base http.RoundTripper) http.RoundTripper {
+	if cfg == nil || !cfg.ResponseCache.Enabled {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &responseCacheTransport{base: base, cache: defaultResponseCache, provider: provider, settings: cfg.ResponseCache}
+}
+
+func (t *responseCacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || req.Body == nil || streamingUpstreamRequest(req) {
+		return t.base.RoundTrip(req)
+	}
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	outbound := req.Clone(req.Context())
+	outbound.Body = io.NopCloser(bytes.NewReader(body))
+	if !cacheableUpstreamBody(body, t.settings.ImageBytes()) {
+		return t.base.RoundTrip(outbound)
+	}
+
+	key := responseCacheKey(t.provider, req, body)
+	ginCtx, _ := req.Context().Value("gin").(*gin.Context)
+	if entry, ok := t.cache.get(key, time.Now()); ok {
+		if ginCtx != nil {
+			ginCtx.Set(responseCacheHitKey, true)
+			ginCtx.Header("X-Cache", "HIT")
+		}
+		return &http.Response{
+			Status:        http.StatusText(entry.status),
+			StatusCode:    entry.status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        entry.header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(entry.body)),
+			ContentLength: int64(len(entry.body)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := t.base.RoundTrip(outbound)
+	if err != nil || resp.StatusCode != http.StatusOK || strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return resp, err
+	}
+	data, errRead := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if errRead != nil {
+		// Hand the executor what arrived together with the read error it would have seen.
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), errorReader{errRead}))
+		return resp, nil
+	}
+	t.cache.put(&responseCacheEntry{
+		key:     key,
+		status:  resp.StatusCode,
+		header:  resp.Header.Clone(),
+		body:    data,
+		expires: time.Now().Add(t.settings.TTLDuration()),
+	}, t.settings.Entries(), t.settings.MaxBytes)
+	if ginCtx != nil {
+		ginCtx.Header("X-Cache", "MISS")
+	}
+	return resp, nil
+}
+
+type errorReader struct{ err error }
+
+func (r errorReader) Read([]byte) (int, error) { return 0, r.err }
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = rc.Close() }()
+		return io.ReadAll(rc)
+	}
+	defer func() { _ = req.Body.Close() }()
+	return io.ReadAll(req.Body)
+}
+
+// streamingUpstreamRequest reports whether req asks the upstream for a stream.
+func streamingUpstreamRequest(req *http.Request) bool {
+	if strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+		return true
+	}
+	return strings.Contains(req.URL.Path, "stream") || req.URL.Query().Get("alt") == "sse"
+}
+
+// responseCacheKey hashes the provider, the endpoint and the translated body. A "key" query
+// parameter carries a credential and is left out, so any account can serve a hit.
+func responseCacheKey(provider string, req *http.Request, body []byte) string {
+	query := req.URL.Query()
+	query.Del("key")
+	h := sha256.New()
+	for _, part := range []string{provider, req.URL.Host, req.URL.Path, query.Encode()} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cacheableUpstreamBody reports whether a translated request is deterministic enough to
+// cache: temperature explicitly 0, no tools, one choice, not streamed, and no inline image or
+// file larger than maxImageBytes. Gemini CLI style bodies nest the request under "request".
+func cacheableUpstreamBody(body []byte, maxImageBytes int) bool {
+	if !gjson.ValidBytes(body) {
+		return false
+	}
+	root := gjson.ParseBytes(body)
+	if nested := root.Get("request"); nested.IsObject() {
+		root = nested
+	}
+	if root.Get("stream").Bool() {
+		return false
+	}
+	temperature := root.Get("temperature")
+	if !temperature.Exists() {
+		temperature = root.Get("generationConfig.temperature")
+	}
+	if !temperature.Exists() || temperature.Float() != 0 {
+		return false
+	}
+	for _, path := range []string{"tools", "functions", "tool_choice"} {
+		if v := root.Get(path); v.Exists() && !(v.IsArray() && len(v.Array()) == 0) {
+			return false
+		}
+	}
+	if root.Get("n").Int() > 1 || root.Get("generationConfig.candidateCount").Int() > 1 {
+		return false
+	}
+	return !hasLargeInlineData(root, maxImageBytes)
+}
+
+// inlineDataFields name the JSON fields the supported formats carry inline images and files in.
+var inlineDataFields = map[string]struct{}{
+	"url": {}, "image_url": {}, "data": {}, "file_data": {},
+}
+
+func hasLargeInlineData(node gjson.Result, maxBytes int) bool {
+	found := false
+	node.ForEach(func(key, value gjson.Result) bool {
+		switch {
+		case value.IsObject() || value.IsArray():
+			found = hasLargeInlineData(value, maxBytes)
+		case value.Type == gjson.String && len(value.Raw) > maxBytes:
+			_, found = inlineDataFields[key.String()]
+		}
+		return !found
+	})
+	return found
+}
+
+// responseCacheHit reports whether the request behind ginCtx was answered from the cache.
+func responseCacheHit(ginCtx *gin.Context) bool {
+	if ginCtx == nil {
+		return false
+	}
+	hit, _ := ginCtx.Get(responseCacheHitKey)
+	ok, _ := hit.(bool)
+	return ok
+}
@@ -0,0 +1,87 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+func TestResponseCacheServesIdenticalDeterministicRequests(t *testing.T) {
+	const model = "response-cache-model"
+	plugin := &usageRecordsFor{model: model, records: make(chan usage.Record, 4)}
+	usage.RegisterPlugin(plugin)
+
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"c1","model":"` + model + `","choices":[{"index":0,"message":{"role":"assistant","content":"4"},"finish_reason":"stop"}],"usage":{"prompt_tokens":7,"completion_tokens":1,"total_tokens":8}}`))
+	}))
+	defer upstream.Close()
+
+	executor := NewOpenAICompatExecutor("openai-compatibility", &config.Config{ResponseCache: config.ResponseCacheConfig{Enabled: true}})
+	auth := &cliproxyauth.Auth{Provider: "openai-compatibility", Attributes: map[string]string{"base_url": upstream.URL + "/v1", "api_key": "test"}}
+	execute := func(payload string) string {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		ginCtx, _ := gin.CreateTestContext(recorder)
+		ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		ctx := context.WithValue(context.Background(), "gin", ginCtx)
+		_, err := executor.Execute(ctx, auth, cliproxyexecutor.Request{Model: model, Payload: []byte(payload)},
+			cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai"), OriginalRequest: []byte(payload)})
+		if err != nil {
+			t.Fatalf("execute: %v", err)
+		}
+		return recorder.Header().Get("X-Cache")
+	}
+	nextRecord := func() usage.Record {
+		t.Helper()
+		select {
+		case record := <-plugin.records:
+			return record
+		case <-time.After(3 * time.Second):
+			t.Fatal("no usage record published")
+			return usage.Record{}
+		}
+	}
+
+	deterministic := `{"model":"` + model + `","temperature":0,"messages":[{"role":"user","content":"2+2?"}]}`
+	if got := execute(deterministic); got != "MISS" {
+		t.Fatalf("first request X-Cache = %q, want MISS", got)
+	}
+	if record := nextRecord(); record.CacheHit || record.Detail.TotalTokens != 8 {
+		t.Fatalf("miss usage record = %+v", record)
+	}
+	if got := execute(deterministic); got != "HIT" {
+		t.Fatalf("second request X-Cache = %q, want HIT", got)
+	}
+	if record := nextRecord(); !record.CacheHit || record.Failed || record.Detail.TotalTokens != 0 {
+		t.Fatalf("hit usage record = %+v, want a cache hit with no tokens", record)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("upstream called %d times, want 1", n)
+	}
+
+	for _, payload := range []string{
+		`{"model":"` + model + `","temperature":0,"tools":[{"type":"function","function":{"name":"f"}}],"messages":[{"role":"user","content":"2+2?"}]}`,
+		`{"model":"` + model + `","temperature":0.7,"messages":[{"role":"user","content":"2+2?"}]}`,
+		`{"model":"` + model + `","messages":[{"role":"user","content":"2+2?"}]}`,
+	} {
+		before := calls.Load()
+		execute(payload)
+		execute(payload)
+		if calls.Load()-before != 2 {
+			t.Fatalf("request %s was served from the cache", payload)
+		}
+	}
+}
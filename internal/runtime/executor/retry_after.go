@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rateLimitResetHeaders lists provider-specific headers that announce when a rate limit resets,
+// checked in order after the standard Retry-After header.
+var rateLimitResetHeaders = []string{
+	"anthropic-ratelimit-requests-reset",
+	"anthropic-ratelimit-tokens-reset",
+	"x-ratelimit-reset-requests",
+	"x-ratelimit-reset-tokens",
+	"x-ratelimit-reset",
+}
+
+// unixTimestampThreshold separates delta seconds from absolute Unix timestamps (~2001-09-09).
+const unixTimestampThreshold = 1e9
+
+// newHTTPStatusErr builds a statusErr for an upstream error response. For 429 responses it
+// records how long the credential should cool down, preferring response headers over hints
+// embedded in the body.
+func newHTTPStatusErr(statusCode int, header http.Header, body []byte) statusErr {
+	err := statusErr{code: statusCode, msg: string(body)}
+	if statusCode != http.StatusTooManyRequests {
+		return err
+	}
+	if retryAfter := parseRetryAfterHeaders(header, time.Now()); retryAfter != nil {
+		err.retryAfter = retryAfter
+	} else if retryAfter, parseErr := parseRetryDelay(body); parseErr == nil && retryAfter != nil {
+		err.retryAfter = retryAfter
+	}
+	return err
+}
+
+// parseRetryAfterHeaders returns the wait announced by Retry-After or a provider reset header.
+func parseRetryAfterHeaders(header http.Header, now time.Time) *time.Duration {
+	if header == nil {
+		return nil
+	}
+	if wait, ok := parseResetValue(header.Get("Retry-After"), now); ok {
+		return &wait
+	}
+	for _, name := range rateLimitResetHeaders {
+		if wait, ok := parseResetValue(header.Get(name), now); ok {
+			return &wait
+		}
+	}
+	return nil
+}
+
+// parseResetValue accepts delta seconds, Go-style durations ("6m0s", "1.5s"), RFC 3339
+// timestamps and HTTP dates.
+func parseResetValue(raw string, now time.Time) (time.Duration, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(raw, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		// Large values are Unix timestamps rather than delta seconds.
+		if seconds > unixTimestampThreshold {
+			wait := time.Unix(int64(seconds), 0).Sub(now)
+			if wait < 0 {
+				wait = 0
+			}
+			return wait, true
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if d, err := time.ParseDuration(raw); err == nil {
+		if d < 0 {
+			return 0, false
+		}
+		return d, true
+	}
+	for _, layout := range []string{time.RFC3339Nano, http.TimeFormat} {
+		if at, err := time.Parse(layout, raw); err == nil {
+			wait := at.Sub(now)
+			if wait < 0 {
+				wait = 0
+			}
+			return wait, true
+		}
+	}
+	return 0, false
+}
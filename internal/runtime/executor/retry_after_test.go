@@ -0,0 +1,54 @@
+package executor
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfterHeaders(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+		ok     bool
+	}{
+		{name: "delta seconds", header: http.Header{"Retry-After": {"30"}}, want: 30 * time.Second, ok: true},
+		{name: "http date", header: http.Header{"Retry-After": {now.Add(2 * time.Minute).Format(http.TimeFormat)}}, want: 2 * time.Minute, ok: true},
+		{name: "anthropic reset", header: http.Header{"Anthropic-Ratelimit-Requests-Reset": {now.Add(90 * time.Second).Format(time.RFC3339)}}, want: 90 * time.Second, ok: true},
+		{name: "openai reset duration", header: http.Header{"X-Ratelimit-Reset-Tokens": {"6m0s"}}, want: 6 * time.Minute, ok: true},
+		{name: "unix timestamp", header: http.Header{"X-Ratelimit-Reset": {"1735732860"}}, want: time.Minute, ok: true},
+		{name: "retry-after wins", header: http.Header{"Retry-After": {"5"}, "X-Ratelimit-Reset-Tokens": {"1m"}}, want: 5 * time.Second, ok: true},
+		{name: "garbage", header: http.Header{"Retry-After": {"soon"}}, ok: false},
+		{name: "missing", header: http.Header{}, ok: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parseRetryAfterHeaders(tc.header, now)
+			if !tc.ok {
+				if got != nil {
+					t.Fatalf("expected no retry-after, got %s", *got)
+				}
+				return
+			}
+			if got == nil || *got != tc.want {
+				t.Fatalf("retry-after = %v, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewHTTPStatusErr_OnlyRateLimitCarriesRetryAfter(t *testing.T) {
+	header := http.Header{"Retry-After": {"10"}}
+	if err := newHTTPStatusErr(http.StatusServiceUnavailable, header, nil); err.RetryAfter() != nil {
+		t.Fatalf("non-429 errors should not carry retry-after")
+	}
+	err := newHTTPStatusErr(http.StatusTooManyRequests, header, []byte("slow down"))
+	if err.RetryAfter() == nil || *err.RetryAfter() != 10*time.Second {
+		t.Fatalf("retry-after = %v, want 10s", err.RetryAfter())
+	}
+	if err.StatusCode() != http.StatusTooManyRequests || err.Error() != "slow down" {
+		t.Fatalf("unexpected status error %d %q", err.StatusCode(), err.Error())
+	}
+}
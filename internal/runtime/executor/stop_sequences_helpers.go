@@ -0,0 +1,206 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// requestStopSequences reads the stop sequences from a client request in its own format.
+func requestStopSequences(format string, payload []byte) []string {
+	switch format {
+	case "claude":
+		return util.StopSequences(gjson.GetBytes(payload, "stop_sequences"))
+	case "gemini":
+		return util.StopSequences(gjson.GetBytes(payload, "generationConfig.stopSequences"))
+	case "gemini-cli":
+		return util.StopSequences(gjson.GetBytes(payload, "request.generationConfig.stopSequences"))
+	default:
+		return util.StopSequences(gjson.GetBytes(payload, "stop"))
+	}
+}
+
+// stopSequenceMatcher enforces stop sequences on streamed text for upstreams that do not
+// support them. Text that could be the start of a stop sequence is held back until the next
+// delta shows whether it completes one, so matches spanning two chunks are still cut.
+type stopSequenceMatcher struct {
+	stops   []string
+	held    string
+	emitted strings.Builder
+	matched string
+}
+
+// newStopSequenceMatcher returns nil when there is nothing to enforce.
+func newStopSequenceMatcher(stops []string) *stopSequenceMatcher {
+	if len(stops) == 0 {
+		return nil
+	}
+	return &stopSequenceMatcher{stops: stops}
+}
+
+// feed returns the part of delta that can be sent now and whether a stop sequence was hit.
+// After a hit the returned text ends right before the stop sequence.
+func (m *stopSequenceMatcher) feed(delta string) (string, bool) {
+	buf := m.held + delta
+	m.held = ""
+	cut := -1
+	for _, stop := range m.stops {
+		if i := strings.Index(buf, stop); i >= 0 && (cut < 0 || i < cut) {
+			cut, m.matched = i, stop
+		}
+	}
+	if cut >= 0 {
+		m.emitted.WriteString(buf[:cut])
+		return buf[:cut], true
+	}
+	hold := m.partialSuffix(buf)
+	m.held = buf[len(buf)-hold:]
+	m.emitted.WriteString(buf[:len(buf)-hold])
+	return buf[:len(buf)-hold], false
+}
+
+// flush releases held-back text once the text stream ends without completing a match.
+func (m *stopSequenceMatcher) flush() string {
+	held := m.held
+	m.held = ""
+	m.emitted.WriteString(held)
+	return held
+}
+
+// partialSuffix returns the length of the longest suffix of buf that is a proper prefix of a
+// stop sequence.
+func (m *stopSequenceMatcher) partialSuffix(buf string) int {
+	longest := 0
+	for _, stop := range m.stops {
+		for k := min(len(stop)-1, len(buf)); k > longest; k-- {
+			if strings.HasSuffix(buf, stop[:k]) {
+				longest = k
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// codexStopFilter applies a stopSequenceMatcher to a Codex SSE stream. When a stop sequence
+// appears the text is cut before it and the stream is closed with a synthesized
+// response.completed, so every translator reports a normal stop.
+type codexStopFilter struct {
+	matcher   *stopSequenceMatcher
+	response  string
+	lastDelta string
+}
+
+func newCodexStopFilter(stops []string) *codexStopFilter {
+	matcher := newStopSequenceMatcher(stops)
+	if matcher == nil {
+		return nil
+	}
+	return &codexStopFilter{matcher: matcher}
+}
+
+// process returns the lines to translate in place of line and whether the stream is done.
+func (f *codexStopFilter) process(line []byte) ([][]byte, bool) {
+	if !strings.HasPrefix(string(line), string(dataTag)) {
+		return [][]byte{line}, false
+	}
+	data := strings.TrimSpace(string(line[len(dataTag):]))
+	event := gjson.Parse(data)
+	switch event.Get("type").String() {
+	case "response.created":
+		f.response = event.Get("response").Raw
+	case "response.output_text.delta":
+		f.lastDelta = data
+		text, stopped := f.matcher.feed(event.Get("delta").String())
+		var out [][]byte
+		if text != "" {
+			out = append(out, f.deltaLine(text))
+		}
+		if stopped {
+			return append(out, f.closingLines()...), true
+		}
+		return out, false
+	}
+	if held := f.matcher.flush(); held != "" && f.lastDelta != "" {
+		return [][]byte{f.deltaLine(held), line}, false
+	}
+	return [][]byte{line}, false
+}
+
+func (f *codexStopFilter) deltaLine(text string) []byte {
+	data, _ := sjson.Set(f.lastDelta, "delta", text)
+	return []byte("data: " + data)
+}
+
+// closingLines ends the open text part and the response after a stop sequence hit.
+func (f *codexStopFilter) closingLines() [][]byte {
+	delta := gjson.Parse(f.lastDelta)
+	text := f.matcher.emitted.String()
+
+	partDone := `{"type":"response.content_part.done","part":{"type":"output_text","text":""}}`
+	partDone, _ = sjson.Set(partDone, "item_id", delta.Get("item_id").String())
+	partDone, _ = sjson.Set(partDone, "output_index", delta.Get("output_index").Int())
+	partDone, _ = sjson.Set(partDone, "content_index", delta.Get("content_index").Int())
+	partDone, _ = sjson.Set(partDone, "part.text", text)
+
+	response := f.response
+	if response == "" {
+		response = `{}`
+	}
+	response, _ = sjson.Set(response, "status", "completed")
+	response, _ = sjson.SetRaw(response, "output", `[{"type":"message","role":"assistant","status":"completed","content":[{"type":"output_text","text":""}]}]`)
+	response, _ = sjson.Set(response, "output.0.id", delta.Get("item_id").String())
+	response, _ = sjson.Set(response, "output.0.content.0.text", text)
+	response, _ = sjson.Set(response, "stop_reason", "stop_sequence")
+	response, _ = sjson.Set(response, "stop_sequence", f.matcher.matched)
+	completed, _ := sjson.SetRaw(`{"type":"response.completed"}`, "response", response)
+
+	return [][]byte{[]byte("data: " + partDone), []byte("data: " + completed)}
+}
+
+// truncateCodexCompletedAtStop cuts the output of a non-streamed Codex response at the first
+// stop sequence, dropping any output that follows it.
+func truncateCodexCompletedAtStop(completed []byte, stops []string) []byte {
+	matcher := newStopSequenceMatcher(stops)
+	if matcher == nil {
+		return completed
+	}
+	output := gjson.GetBytes(completed, "response.output").Array()
+	for i, item := range output {
+		if item.Get("type").String() != "message" {
+			continue
+		}
+		for j, part := range item.Get("content").Array() {
+			if part.Get("type").String() != "output_text" {
+				continue
+			}
+			matcher.held = ""
+			text, stopped := matcher.feed(part.Get("text").String())
+			if !stopped {
+				continue
+			}
+			kept := make([]string, 0, i+1)
+			for _, earlier := range output[:i] {
+				kept = append(kept, earlier.Raw)
+			}
+			message := item.Raw
+			parts := item.Get("content").Array()
+			partsRaw := make([]string, 0, j+1)
+			for _, earlier := range parts[:j] {
+				partsRaw = append(partsRaw, earlier.Raw)
+			}
+			cutPart, _ := sjson.Set(part.Raw, "text", text)
+			partsRaw = append(partsRaw, cutPart)
+			message, _ = sjson.SetRaw(message, "content", "["+strings.Join(partsRaw, ",")+"]")
+			kept = append(kept, message)
+
+			completed, _ = sjson.SetRawBytes(completed, "response.output", []byte("["+strings.Join(kept, ",")+"]"))
+			completed, _ = sjson.SetBytes(completed, "response.stop_reason", "stop_sequence")
+			completed, _ = sjson.SetBytes(completed, "response.stop_sequence", matcher.matched)
+			return completed
+		}
+	}
+	return completed
+}
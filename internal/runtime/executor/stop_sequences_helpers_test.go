@@ -0,0 +1,140 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func TestStopSequenceMatcherCutsAcrossChunks(t *testing.T) {
+	m := newStopSequenceMatcher([]string{"END", "\n\nUser:"})
+	var sent strings.Builder
+	stopped := false
+	for _, delta := range []string{"Hello wor", "ld\n", "\nUs", "er: extra"} {
+		text, hit := m.feed(delta)
+		sent.WriteString(text)
+		if hit {
+			stopped = true
+			break
+		}
+	}
+	if !stopped {
+		t.Fatal("expected stop sequence split across chunks to be detected")
+	}
+	if sent.String() != "Hello world" || m.matched != "\n\nUser:" {
+		t.Fatalf("sent = %q, matched = %q", sent.String(), m.matched)
+	}
+
+	m = newStopSequenceMatcher([]string{"END"})
+	text, hit := m.feed("the EN")
+	if hit || text != "the " {
+		t.Fatalf("partial match must be held back, got %q hit=%v", text, hit)
+	}
+	if text, hit = m.feed("ding"); hit || text != "ENding" {
+		t.Fatalf("held text must be released when the match fails, got %q hit=%v", text, hit)
+	}
+}
+
+func TestCodexStopFilterSynthesizesCompletion(t *testing.T) {
+	f := newCodexStopFilter([]string{"STOP"})
+	var lines [][]byte
+	done := false
+	for _, raw := range []string{
+		`data: {"type":"response.created","response":{"id":"resp_1","model":"gpt-5"}}`,
+		`data: {"type":"response.output_text.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":"one ST"}`,
+		`data: {"type":"response.output_text.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":"OP two"}`,
+	} {
+		out, stopped := f.process([]byte(raw))
+		lines = append(lines, out...)
+		if stopped {
+			done = true
+			break
+		}
+	}
+	if !done {
+		t.Fatal("expected the filter to end the stream")
+	}
+	var text strings.Builder
+	for _, line := range lines {
+		event := gjson.ParseBytes(line[len(dataTag):])
+		if event.Get("type").String() == "response.output_text.delta" {
+			text.WriteString(event.Get("delta").String())
+		}
+	}
+	if text.String() != "one " {
+		t.Fatalf("streamed text = %q", text.String())
+	}
+	completed := gjson.ParseBytes(lines[len(lines)-1][len(dataTag):])
+	if completed.Get("type").String() != "response.completed" || completed.Get("response.id").String() != "resp_1" ||
+		completed.Get("response.output.0.content.0.text").String() != "one " || completed.Get("response.stop_sequence").String() != "STOP" {
+		t.Fatalf("unexpected completion: %s", completed.Raw)
+	}
+}
+
+func TestCodexStreamStoppedEarlyPublishesUsage(t *testing.T) {
+	const model = "codex-stop-usage-model"
+	plugin := &usageRecordsFor{model: model, records: make(chan usage.Record, 1)}
+	usage.RegisterPlugin(plugin)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, data := range []string{
+			`{"type":"response.created","response":{"id":"resp_1","model":"` + model + `"}}`,
+			`{"type":"response.output_text.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":"one STOP two"}`,
+			`{"type":"response.output_text.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":" three"}`,
+			`{"type":"response.completed","response":{"id":"resp_1","usage":{"input_tokens":7,"output_tokens":5,"total_tokens":12}}}`,
+		} {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+	}))
+	defer upstream.Close()
+
+	executor := NewCodexExecutor(&config.Config{})
+	auth := &cliproxyauth.Auth{Attributes: map[string]string{"base_url": upstream.URL, "api_key": "test"}}
+	payload := []byte(`{"model":"` + model + `","stream":true,"stop":["STOP"],"messages":[{"role":"user","content":"hi"}]}`)
+	stream, err := executor.ExecuteStream(context.Background(), auth, cliproxyexecutor.Request{Model: model, Payload: payload},
+		cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai"), OriginalRequest: payload, Stream: true})
+	if err != nil {
+		t.Fatalf("ExecuteStream: %v", err)
+	}
+	var streamed strings.Builder
+	for chunk := range stream {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		streamed.Write(chunk.Payload)
+	}
+	if strings.Contains(streamed.String(), "two") || strings.Contains(streamed.String(), "three") {
+		t.Fatalf("stream continued past the stop sequence: %s", streamed.String())
+	}
+	select {
+	case record := <-plugin.records:
+		if record.Failed || record.Detail.TotalTokens != 12 {
+			t.Fatalf("usage record = %+v, want the 12 tokens the upstream reported", record)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no usage record published for the stopped stream")
+	}
+}
+
+func TestTruncateCodexCompletedAtStop(t *testing.T) {
+	completed := []byte(`{"type":"response.completed","response":{"output":[{"type":"message","content":[{"type":"output_text","text":"keep###drop"}]},{"type":"function_call","name":"x"}]}}`)
+	out := truncateCodexCompletedAtStop(completed, []string{"###"})
+	if got := gjson.GetBytes(out, "response.output.0.content.0.text").String(); got != "keep" {
+		t.Fatalf("text = %q", got)
+	}
+	if n := gjson.GetBytes(out, "response.output.#").Int(); n != 1 {
+		t.Fatalf("output after the stop must be dropped, got %d items", n)
+	}
+}
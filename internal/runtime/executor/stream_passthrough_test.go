@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// liveHeap returns the bytes still reachable after full collections. The second collection
+// frees what finalizers released during the first.
+func liveHeap() uint64 {
+	runtime.GC()
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// TestOpenAICompatStreamToClaudeIsChunkByChunk streams about 5 MB of OpenAI text and tool
+// call deltas to a Claude client. The upstream holds the rest of the stream until the first
+// translated event arrives, so buffering anywhere on the way stalls the test, and the live
+// heap must stay far below the size of the response.
+func TestOpenAICompatStreamToClaudeIsChunkByChunk(t *testing.T) {
+	// A short stream first, so one-time initialisation is not counted as growth.
+	streamOpenAIToClaude(t, 20)
+	firstByte, growth, forwarded := streamOpenAIToClaude(t, 5000)
+	if firstByte > 2*time.Second {
+		t.Fatalf("first event after %s, want it before the upstream continues", firstByte)
+	}
+	// A translator holding the response would keep at least 2.5 MB of text or arguments live.
+	if growth > 1<<20 {
+		t.Fatalf("live heap grew by %d bytes while streaming %d bytes, want O(chunk)", growth, forwarded)
+	}
+	t.Logf("first event after %s, live heap growth %d bytes for %d bytes streamed", firstByte, growth, forwarded)
+}
+
+// streamOpenAIToClaude streams chunks deltas of 1000 bytes, half text and half tool call
+// arguments, and returns the time to the first event, the peak live heap growth and the
+// number of content bytes forwarded.
+func streamOpenAIToClaude(t *testing.T, chunks int) (time.Duration, uint64, int) {
+	t.Helper()
+	text := strings.Repeat("x", 1000)
+	firstSeen := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		write := func(event string) {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", event)
+			flusher.Flush()
+		}
+		write(`{"id":"chatcmpl-1","model":"gpt-5","created":1700000000,"choices":[{"index":0,"delta":{"role":"assistant","content":"hello"}}]}`)
+		select {
+		case <-firstSeen:
+		case <-time.After(5 * time.Second):
+			return
+		}
+		for i := 0; i < chunks/2; i++ {
+			write(`{"id":"chatcmpl-1","model":"gpt-5","created":1700000000,"choices":[{"index":0,"delta":{"content":"` + text + `"}}]}`)
+		}
+		write(`{"id":"chatcmpl-1","model":"gpt-5","created":1700000000,"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"write_file","arguments":"{\"content\":\""}}]}}]}`)
+		for i := 0; i < chunks/2; i++ {
+			write(`{"id":"chatcmpl-1","model":"gpt-5","created":1700000000,"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"` + text + `"}}]}}]}`)
+		}
+		write(`{"id":"chatcmpl-1","model":"gpt-5","created":1700000000,"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"}"}}]},"finish_reason":"tool_calls"}]}`)
+		write(`[DONE]`)
+	}))
+	defer server.Close()
+
+	executor := NewOpenAICompatExecutor("openai-compatibility", &config.Config{})
+	auth := &cliproxyauth.Auth{Attributes: map[string]string{"base_url": server.URL + "/v1", "api_key": "test"}}
+	payload := []byte(`{"model":"gpt-5","max_tokens":1024,"stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	baseline := liveHeap()
+	start := time.Now()
+	stream, err := executor.ExecuteStream(context.Background(), auth, cliproxyexecutor.Request{Model: "gpt-5", Payload: payload},
+		cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("claude"), OriginalRequest: payload, Stream: true})
+	if err != nil {
+		t.Fatalf("ExecuteStream: %v", err)
+	}
+
+	var firstByte time.Duration
+	var peak uint64
+	var events, textBytes, argBytes int
+	for chunk := range stream {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		if events == 0 {
+			firstByte = time.Since(start)
+			close(firstSeen)
+		}
+		events++
+		for _, line := range strings.Split(string(chunk.Payload), "\n") {
+			data, ok := strings.CutPrefix(line, "data: ")
+			if !ok {
+				continue
+			}
+			textBytes += len(gjson.Get(data, "delta.text").String())
+			argBytes += len(gjson.Get(data, "delta.partial_json").String())
+		}
+		if events%500 == 0 {
+			if live := liveHeap(); live > peak {
+				peak = live
+			}
+		}
+	}
+	if events == 0 {
+		t.Fatal("stream produced no events")
+	}
+	if want := chunks / 2 * len(text); textBytes < want || argBytes < want {
+		t.Fatalf("forwarded %d text and %d argument bytes, want at least %d of each", textBytes, argBytes, want)
+	}
+	var growth uint64
+	if peak > baseline {
+		growth = peak - baseline
+	}
+	return firstByte, growth, textBytes + argBytes
+}
@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxPooledLineSize keeps unusually large lines (inline images, huge tool arguments) from
+// pinning memory in the pool after the stream that produced them ends.
+const maxPooledLineSize = 64 << 10
+
+// streamLinePool recycles the buffers upstream lines are copied into for translation. The
+// copy keeps translators away from the scanner's buffer; it goes back to the pool as soon as
+// TranslateStream returns, since translators return new strings and keep no reference to
+// their input.
+var streamLinePool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 4<<10)
+		return &buf
+	},
+}
+
+// streamTranslator translates the lines of one upstream stream into the client format. The
+// original request is copied once per stream rather than once per chunk.
+type streamTranslator struct {
+	ctx      context.Context
+	from     sdktranslator.Format
+	to       sdktranslator.Format
+	model    string
+	original []byte
+	request  []byte
+	param    any
+	// produced is set once a line translated into output, whose time is the first token's.
+	produced bool
+}
+
+// newStreamTranslator prepares translation from the upstream format from to the client
+// format to. request is the translated upstream request and is not modified.
+func newStreamTranslator(ctx context.Context, from, to sdktranslator.Format, model string, original, request []byte) *streamTranslator {
+	return &streamTranslator{
+		ctx:      ctx,
+		from:     from,
+		to:       to,
+		model:    model,
+		original: bytes.Clone(original),
+		request:  request,
+	}
+}
+
+// translate converts one upstream line. The caller may reuse line once translate returns.
+func (t *streamTranslator) translate(line []byte) []string {
+	buf := streamLinePool.Get().(*[]byte)
+	*buf = append((*buf)[:0], line...)
+	chunks := sdktranslator.TranslateStream(t.ctx, t.from, t.to, t.model, t.original, t.request, *buf, &t.param)
+	if cap(*buf) <= maxPooledLineSize {
+		streamLinePool.Put(buf)
+	}
+	if len(chunks) > 0 && !t.produced {
+		t.produced = true
+		usage.MarkFirstToken(t.ctx)
+		tracing.AddEvent(t.ctx, "first_token")
+	}
+	return chunks
+}
+
+// translateDone signals the end of the upstream stream to the translator.
+func (t *streamTranslator) translateDone() []string {
+	return t.translate([]byte("[DONE]"))
+}
+
+// translateNonStream converts a complete upstream response into the client format, inside a
+// translate.response span while tracing is enabled.
+func translateNonStream(ctx context.Context, from, to sdktranslator.Format, model string, original, request, body []byte, param *any) string {
+	spanCtx, span := tracing.Start(ctx, "translate.response", trace.SpanKindInternal)
+	if span.IsRecording() {
+		span.SetAttributes(attribute.String("cliproxy.translate.from", from.String()), attribute.String("cliproxy.translate.to", to.String()))
+	}
+	out := sdktranslator.TranslateNonStream(spanCtx, from, to, model, original, request, body, param)
+	span.End()
+	return out
+}
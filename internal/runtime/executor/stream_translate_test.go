@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// codexStreamLines returns a Codex stream of n text deltas tagged with id.
+func codexStreamLines(id string, n int) [][]byte {
+	lines := [][]byte{[]byte(`data: {"type":"response.created","response":{"id":"resp_` + id + `","created_at":1700000000,"model":"gpt-5"}}`)}
+	for i := 0; i < n; i++ {
+		lines = append(lines, []byte(fmt.Sprintf(`data: {"type":"response.output_text.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":"%s-%d "}`, id, i)))
+	}
+	return append(lines, []byte(`data: {"type":"response.completed","response":{"id":"resp_`+id+`","status":"completed","usage":{"input_tokens":10,"output_tokens":500,"total_tokens":510}}}`))
+}
+
+// benchmarkOriginalRequest is a chat request with enough history to make per-chunk copies visible.
+func benchmarkOriginalRequest() []byte {
+	var messages []string
+	for i := 0; i < 40; i++ {
+		messages = append(messages, fmt.Sprintf(`{"role":"user","content":"%s"}`, strings.Repeat("history ", 50)))
+	}
+	return []byte(`{"model":"gpt-5","stream":true,"messages":[` + strings.Join(messages, ",") + `]}`)
+}
+
+func TestStreamTranslatorConcurrentStreams(t *testing.T) {
+	from, to := sdktranslator.FromString("codex"), sdktranslator.FromString("openai")
+	original := []byte(`{"model":"gpt-5","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for s := 0; s < 64; s++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			translator := newStreamTranslator(context.Background(), from, to, "gpt-5", original, nil)
+			var got, want strings.Builder
+			// Reuse one line buffer the way bufio.Scanner does, so a retained input would show up.
+			var line []byte
+			for i, upstream := range codexStreamLines(id, 200) {
+				line = append(line[:0], upstream...)
+				if i > 0 && i <= 200 {
+					fmt.Fprintf(&want, "%s-%d ", id, i-1)
+				}
+				for _, chunk := range translator.translate(line) {
+					got.WriteString(gjson.Get(chunk, "choices.0.delta.content").String())
+				}
+			}
+			if got.String() != want.String() {
+				errs <- fmt.Errorf("stream %s: content diverged", id)
+			}
+		}(fmt.Sprintf("s%d", s))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// BenchmarkStreamTranslation translates a 500-chunk Codex stream for an OpenAI client,
+// comparing the former per-chunk copies of the request and line with the pooled translator.
+func BenchmarkStreamTranslation(b *testing.B) {
+	from, to := sdktranslator.FromString("codex"), sdktranslator.FromString("openai")
+	original := benchmarkOriginalRequest()
+	lines := codexStreamLines("bench", 500)
+	ctx := context.Background()
+
+	b.Run("clone-per-chunk", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var param any
+			for _, line := range lines {
+				_ = sdktranslator.TranslateStream(ctx, from, to, "gpt-5", bytes.Clone(original), nil, bytes.Clone(line), &param)
+			}
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			translator := newStreamTranslator(ctx, from, to, "gpt-5", original, nil)
+			for _, line := range lines {
+				_ = translator.translate(line)
+			}
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package executor
+
+import (
+	"context"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// applySystemPromptPolicy applies the system prompt configured for the calling client key
+// to an already translated payload. protocol and root follow applyPayloadConfigWithRoot.
+// The prompt only reaches the upstream request; response translators echo fields from the
+// client's original request, so it is never returned to the client.
+func applySystemPromptPolicy(ctx context.Context, cfg *config.Config, protocol, root string, payload []byte) []byte {
+	if cfg == nil || len(cfg.APIKeySettings) == 0 || len(payload) == 0 {
+		return payload
+	}
+	settings := cfg.APIKeySettingsFor(apiKeyFromContext(ctx))
+	if settings == nil || settings.SystemPrompt == nil || strings.TrimSpace(settings.SystemPrompt.Text) == "" {
+		return payload
+	}
+	mode := settings.SystemPrompt.NormalizedMode()
+	text := settings.SystemPrompt.Text
+	switch protocol {
+	case "claude":
+		return applyClaudeSystemPrompt(payload, mode, text)
+	case "gemini", "gemini-cli", "antigravity":
+		return applyGeminiSystemPrompt(payload, root, mode, text)
+	case "codex":
+		return applyCodexSystemPrompt(payload, mode, text)
+	default:
+		return applyOpenAISystemPrompt(payload, mode, text)
+	}
+}
+
+func isSystemRole(role string) bool {
+	return role == "system" || role == "developer"
+}
+
+// applyOpenAISystemPrompt inserts a system message into a chat completions payload.
+// Appended prompts go after the leading system messages so the conversation stays intact.
+func applyOpenAISystemPrompt(payload []byte, mode, text string) []byte {
+	messages := gjson.GetBytes(payload, "messages")
+	if !messages.IsArray() {
+		return payload
+	}
+	kept := make([]string, 0, len(messages.Array())+1)
+	leading := 0
+	for _, message := range messages.Array() {
+		system := isSystemRole(message.Get("role").String())
+		if system && mode == config.SystemPromptOverride {
+			continue
+		}
+		if system && leading == len(kept) {
+			leading++
+		}
+		kept = append(kept, message.Raw)
+	}
+	injected, _ := sjson.Set(`{"role":"system","content":""}`, "content", text)
+	at := 0
+	if mode == config.SystemPromptAppend {
+		at = leading
+	}
+	kept = append(kept[:at], append([]string{injected}, kept[at:]...)...)
+	payload, _ = sjson.SetRawBytes(payload, "messages", []byte("["+strings.Join(kept, ",")+"]"))
+	return payload
+}
+
+// applyClaudeSystemPrompt normalises the system field to text blocks and adds the prompt.
+func applyClaudeSystemPrompt(payload []byte, mode, text string) []byte {
+	var blocks []string
+	if mode != config.SystemPromptOverride {
+		system := gjson.GetBytes(payload, "system")
+		switch {
+		case system.Type == gjson.String && system.String() != "":
+			block, _ := sjson.Set(`{"type":"text","text":""}`, "text", system.String())
+			blocks = append(blocks, block)
+		case system.IsArray():
+			for _, block := range system.Array() {
+				blocks = append(blocks, block.Raw)
+			}
+		}
+	}
+	injected, _ := sjson.Set(`{"type":"text","text":""}`, "text", text)
+	if mode == config.SystemPromptAppend {
+		blocks = append(blocks, injected)
+	} else {
+		blocks = append([]string{injected}, blocks...)
+	}
+	payload, _ = sjson.SetRawBytes(payload, "system", []byte("["+strings.Join(blocks, ",")+"]"))
+	return payload
+}
+
+// applyGeminiSystemPrompt adds the prompt to systemInstruction (or system_instruction when
+// the payload already uses the snake_case field) under root.
+func applyGeminiSystemPrompt(payload []byte, root, mode, text string) []byte {
+	prefix := ""
+	if root != "" {
+		prefix = root + "."
+	}
+	path := prefix + "systemInstruction"
+	if !gjson.GetBytes(payload, path).Exists() && gjson.GetBytes(payload, prefix+"system_instruction").Exists() {
+		path = prefix + "system_instruction"
+	}
+	var parts []string
+	if mode != config.SystemPromptOverride {
+		for _, part := range gjson.GetBytes(payload, path+".parts").Array() {
+			parts = append(parts, part.Raw)
+		}
+	}
+	injected, _ := sjson.Set(`{"text":""}`, "text", text)
+	if mode == config.SystemPromptAppend {
+		parts = append(parts, injected)
+	} else {
+		parts = append([]string{injected}, parts...)
+	}
+	payload, _ = sjson.SetRawBytes(payload, path+".parts", []byte("["+strings.Join(parts, ",")+"]"))
+	return payload
+}
+
+// applyCodexSystemPrompt edits the Responses instructions field. Override also drops
+// system and developer items from input so the client cannot reintroduce a prompt there.
+func applyCodexSystemPrompt(payload []byte, mode, text string) []byte {
+	existing := strings.TrimSpace(gjson.GetBytes(payload, "instructions").String())
+	instructions := text
+	switch {
+	case mode == config.SystemPromptOverride || existing == "":
+	case mode == config.SystemPromptAppend:
+		instructions = existing + "\n\n" + text
+	default:
+		instructions = text + "\n\n" + existing
+	}
+	payload, _ = sjson.SetBytes(payload, "instructions", instructions)
+	if mode != config.SystemPromptOverride {
+		return payload
+	}
+	input := gjson.GetBytes(payload, "input")
+	if !input.IsArray() {
+		return payload
+	}
+	kept := make([]string, 0, len(input.Array()))
+	for _, item := range input.Array() {
+		if isSystemRole(item.Get("role").String()) {
+			continue
+		}
+		kept = append(kept, item.Raw)
+	}
+	payload, _ = sjson.SetRawBytes(payload, "input", []byte("["+strings.Join(kept, ",")+"]"))
+	return payload
+}
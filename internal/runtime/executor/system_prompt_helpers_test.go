@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func systemPromptContext(apiKey string) context.Context {
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Set("apiKey", apiKey)
+	return context.WithValue(context.Background(), "gin", ginCtx)
+}
+
+func systemPromptConfig(mode string) *config.Config {
+	return &config.Config{APIKeySettings: []config.APIKeySettings{{
+		APIKey:       "kids",
+		SystemPrompt: &config.SystemPrompt{Mode: mode, Text: "be kind"},
+	}}}
+}
+
+func TestApplySystemPromptPolicyOpenAI(t *testing.T) {
+	payload := []byte(`{"messages":[{"role":"system","content":"client"},{"role":"user","content":"hi"}]}`)
+	ctx := systemPromptContext("kids")
+
+	out := applySystemPromptPolicy(ctx, systemPromptConfig("prepend"), "openai", "", payload)
+	if got := gjson.GetBytes(out, "messages.#.content").Raw; got != `["be kind","client","hi"]` {
+		t.Fatalf("prepend: messages = %s", got)
+	}
+	out = applySystemPromptPolicy(ctx, systemPromptConfig("append"), "openai", "", payload)
+	if got := gjson.GetBytes(out, "messages.#.content").Raw; got != `["client","be kind","hi"]` {
+		t.Fatalf("append: messages = %s", got)
+	}
+	out = applySystemPromptPolicy(ctx, systemPromptConfig("override"), "openai", "", payload)
+	if got := gjson.GetBytes(out, "messages.#.content").Raw; got != `["be kind","hi"]` {
+		t.Fatalf("override: messages = %s", got)
+	}
+
+	other := applySystemPromptPolicy(systemPromptContext("adult"), systemPromptConfig("override"), "openai", "", payload)
+	if string(other) != string(payload) {
+		t.Fatalf("keys without settings must pass through, got %s", other)
+	}
+}
+
+func TestApplySystemPromptPolicyClaude(t *testing.T) {
+	ctx := systemPromptContext("kids")
+	out := applySystemPromptPolicy(ctx, systemPromptConfig("prepend"), "claude", "", []byte(`{"system":"client","messages":[]}`))
+	if got := gjson.GetBytes(out, "system.#.text").Raw; got != `["be kind","client"]` {
+		t.Fatalf("prepend: system = %s", got)
+	}
+	out = applySystemPromptPolicy(ctx, systemPromptConfig("override"), "claude", "", []byte(`{"system":[{"type":"text","text":"client"}]}`))
+	if got := gjson.GetBytes(out, "system.#.text").Raw; got != `["be kind"]` {
+		t.Fatalf("override: system = %s", got)
+	}
+}
+
+func TestApplySystemPromptPolicyGeminiAndCodex(t *testing.T) {
+	ctx := systemPromptContext("kids")
+	out := applySystemPromptPolicy(ctx, systemPromptConfig("append"), "gemini", "request", []byte(`{"request":{"systemInstruction":{"parts":[{"text":"client"}]}}}`))
+	if got := gjson.GetBytes(out, "request.systemInstruction.parts.#.text").Raw; got != `["client","be kind"]` {
+		t.Fatalf("append: parts = %s", got)
+	}
+	out = applySystemPromptPolicy(ctx, systemPromptConfig("prepend"), "gemini", "", []byte(`{"contents":[]}`))
+	if got := gjson.GetBytes(out, "systemInstruction.parts.0.text").String(); got != "be kind" {
+		t.Fatalf("missing systemInstruction should be created, got %s", out)
+	}
+
+	codex := []byte(`{"instructions":"client","input":[{"type":"message","role":"developer","content":"dev"},{"type":"message","role":"user","content":"hi"}]}`)
+	out = applySystemPromptPolicy(ctx, systemPromptConfig("override"), "codex", "", codex)
+	if gjson.GetBytes(out, "instructions").String() != "be kind" || gjson.GetBytes(out, "input.#").Int() != 1 {
+		t.Fatalf("override: %s", out)
+	}
+}
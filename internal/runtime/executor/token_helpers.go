@@ -234,3 +234,21 @@ func addIfNotEmpty(segments *[]string, value string) {
 		*segments = append(*segments, trimmed)
 	}
 }
+
+// EstimateOpenAIChatUsage approximates the prompt tokens of a chat completions request and
+// the tokens of the completion text generated for it. It backs usage reporting for
+// upstreams that return no usage; errors count as zero.
+func EstimateOpenAIChatUsage(model string, request []byte, completion string) (int64, int64) {
+	enc, err := tokenizerForModel(model)
+	if err != nil {
+		return 0, 0
+	}
+	prompt, _ := countOpenAIChatTokens(enc, request)
+	var output int64
+	if completion = strings.TrimSpace(completion); completion != "" {
+		if count, errCount := enc.Count(completion); errCount == nil {
+			output = int64(count)
+		}
+	}
+	return prompt, output
+}
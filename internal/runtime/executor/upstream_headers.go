@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"golang.org/x/net/http/httpguts"
+)
+
+// upstreamHeaderTransport applies a provider's upstream-headers rules to every request it
+// sends, after the executor has built the request.
+type upstreamHeaderTransport struct {
+	base  http.RoundTripper
+	rules config.UpstreamHeaderRules
+}
+
+// withUpstreamHeaders wraps base when cfg has header rules for provider.
+func withUpstreamHeaders(cfg *config.Config, provider string, base http.RoundTripper) http.RoundTripper {
+	if cfg == nil || len(cfg.UpstreamHeaders) == 0 {
+		return base
+	}
+	rules, ok := cfg.UpstreamHeaders[provider]
+	if !ok || (len(rules.ForwardHeaders) == 0 && len(rules.InjectHeaders) == 0) {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &upstreamHeaderTransport{base: base, rules: rules}
+}
+
+func (t *upstreamHeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	outbound := req.Clone(req.Context())
+	if ginCtx, ok := req.Context().Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+		inbound := ginCtx.Request.Header
+		for _, name := range t.rules.ForwardHeaders {
+			name = strings.TrimSpace(name)
+			if config.IsProtectedUpstreamHeader(name) || connectionScoped(inbound, name) {
+				continue
+			}
+			if values := inbound.Values(name); len(values) > 0 {
+				outbound.Header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+			}
+		}
+	}
+	for name, value := range t.rules.InjectHeaders {
+		name = strings.TrimSpace(name)
+		if name == "" || config.IsProtectedUpstreamHeader(name) {
+			continue
+		}
+		outbound.Header.Set(name, config.ExpandHeaderValue(value))
+	}
+	return t.base.RoundTrip(outbound)
+}
+
+// connectionScoped reports whether the client's Connection header names name as hop-by-hop.
+func connectionScoped(h http.Header, name string) bool {
+	return httpguts.HeaderValuesContainsToken(h.Values("Connection"), name)
+}
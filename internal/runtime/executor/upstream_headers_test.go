@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestUpstreamHeadersForwardAndInject(t *testing.T) {
+	t.Setenv("CPA_TEST_PROJECT", "my-project")
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{UpstreamHeaders: map[string]config.UpstreamHeaderRules{
+		"claude": {
+			// Authorization is listed to show that the runtime drops it even when validation
+			// was skipped.
+			ForwardHeaders: []string{"anthropic-beta", "Authorization", "X-Hop"},
+			InjectHeaders:  map[string]string{"x-goog-user-project": "${CPA_TEST_PROJECT}", "x-price": "$5"},
+		},
+	}}
+	inbound := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	inbound.Header.Add("Anthropic-Beta", "feature-a")
+	inbound.Header.Add("Anthropic-Beta", "feature-b")
+	inbound.Header.Set("Authorization", "Bearer client-key")
+	inbound.Header.Set("Connection", "X-Hop")
+	inbound.Header.Set("X-Hop", "1")
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = inbound
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, upstream.URL, nil)
+	req.Header.Set("Authorization", "Bearer upstream-key")
+	resp, err := newProxyAwareHTTPClient(ctx, cfg, &cliproxyauth.Auth{Provider: "claude"}, 0).Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if v := got.Values("Anthropic-Beta"); strings.Join(v, ",") != "feature-a,feature-b" {
+		t.Fatalf("anthropic-beta = %v", v)
+	}
+	if got.Get("Authorization") != "Bearer upstream-key" || got.Get("X-Hop") != "" {
+		t.Fatalf("protected or hop-by-hop header forwarded: %v", got)
+	}
+	if got.Get("X-Goog-User-Project") != "my-project" || got.Get("X-Price") != "$5" {
+		t.Fatalf("injected headers = %q, %q", got.Get("X-Goog-User-Project"), got.Get("X-Price"))
+	}
+	if req.Header.Get("X-Goog-User-Project") != "" {
+		t.Fatal("caller's request was modified")
+	}
+
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "Authorization cannot be forwarded") {
+		t.Fatalf("Validate() = %v, want forwarding Authorization rejected", err)
+	}
+}
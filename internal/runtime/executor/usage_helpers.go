@@ -3,14 +3,17 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -22,8 +25,13 @@ type usageReporter struct {
 	authIndex   string
 	apiKey      string
 	source      string
+	labels      map[string]string
 	requestedAt time.Time
 	once        sync.Once
+
+	mu       sync.Mutex
+	observed usage.Detail
+	seen     bool
 }
 
 func newUsageReporter(ctx context.Context, provider, model string, auth *cliproxyauth.Auth) *usageReporter {
@@ -34,6 +42,7 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 		requestedAt: time.Now(),
 		apiKey:      apiKey,
 		source:      resolveUsageSource(auth, apiKey),
+		labels:      UsageLabelsFromContext(ctx),
 	}
 	if auth != nil {
 		reporter.authID = auth.ID
@@ -46,7 +55,13 @@ func (r *usageReporter) publish(ctx context.Context, detail usage.Detail) {
 	r.publishWithOutcome(ctx, detail, false)
 }
 
+// publishFailure records a failed request. When the failure is the client disconnecting,
+// the request is recorded as cancelled with the usage observed so far instead.
 func (r *usageReporter) publishFailure(ctx context.Context) {
+	if clientCancelled(ctx) {
+		r.publishWithOutcome(ctx, r.observedDetail(), false)
+		return
+	}
 	r.publishWithOutcome(ctx, usage.Detail{}, true)
 }
 
@@ -69,10 +84,18 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 			detail.TotalTokens = total
 		}
 	}
-	if detail.InputTokens == 0 && detail.OutputTokens == 0 && detail.ReasoningTokens == 0 && detail.CachedTokens == 0 && detail.TotalTokens == 0 && !failed {
+	cancelled := clientCancelled(ctx)
+	cacheHit := cacheHitFromContext(ctx)
+	if cacheHit {
+		// The tokens parsed from a cached body were spent by the request that filled the cache.
+		detail = usage.Detail{}
+	}
+	if detail.InputTokens == 0 && detail.OutputTokens == 0 && detail.ReasoningTokens == 0 && detail.CachedTokens == 0 && detail.TotalTokens == 0 && !failed && !cancelled && !cacheHit {
 		return
 	}
 	r.once.Do(func() {
+		tracing.RecordUsage(ctx, detail.InputTokens, detail.OutputTokens, detail.ReasoningTokens, detail.CachedTokens, detail.TotalTokens)
+		recordAuditUsage(ctx, r.provider, r.authIndex, detail)
 		usage.PublishRecord(ctx, usage.Record{
 			Provider:    r.provider,
 			Model:       r.model,
@@ -80,21 +103,71 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 			APIKey:      r.apiKey,
 			AuthID:      r.authID,
 			AuthIndex:   r.authIndex,
+			Labels:      r.labels,
 			RequestedAt: r.requestedAt,
-			Failed:      failed,
+			Failed:      failed && !cancelled,
+			Cancelled:   cancelled,
+			CacheHit:    cacheHit,
 			Detail:      detail,
 		})
 	})
 }
 
+// publishCount records a token counting call. The counted tokens are stored as input
+// tokens under RequestTypeCountTokens so statistics can keep them apart from generation.
+func (r *usageReporter) publishCount(ctx context.Context, count int64, estimated bool) {
+	if r == nil {
+		return
+	}
+	r.once.Do(func() {
+		usage.PublishRecord(ctx, usage.Record{
+			Provider:    r.provider,
+			Model:       r.model,
+			Source:      r.source,
+			APIKey:      r.apiKey,
+			AuthID:      r.authID,
+			AuthIndex:   r.authIndex,
+			Labels:      r.labels,
+			RequestedAt: r.requestedAt,
+			Estimated:   estimated,
+			RequestType: usage.RequestTypeCountTokens,
+			Detail:      usage.Detail{InputTokens: count, TotalTokens: count},
+		})
+	})
+}
+
+// observe merges usage reported mid-stream. Streams report usage in several events
+// (Claude splits input and output across message_start and message_delta, Gemini repeats
+// cumulative usageMetadata), so the largest value seen for each field is kept and
+// published by ensurePublished once the stream ends.
+func (r *usageReporter) observe(detail usage.Detail) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observed.InputTokens = max(r.observed.InputTokens, detail.InputTokens)
+	r.observed.OutputTokens = max(r.observed.OutputTokens, detail.OutputTokens)
+	r.observed.ReasoningTokens = max(r.observed.ReasoningTokens, detail.ReasoningTokens)
+	r.observed.CachedTokens = max(r.observed.CachedTokens, detail.CachedTokens)
+	r.observed.TotalTokens = max(r.observed.TotalTokens, detail.TotalTokens)
+	r.seen = true
+}
+
 // ensurePublished guarantees that a usage record is emitted exactly once.
 // It is safe to call multiple times; only the first call wins due to once.Do.
-// This is used to ensure request counting even when upstream responses do not
-// include any usage fields (tokens), especially for streaming paths.
+// Usage gathered by observe is published first; otherwise an empty record is
+// emitted so requests are counted even when upstream responses carry no usage.
 func (r *usageReporter) ensurePublished(ctx context.Context) {
 	if r == nil {
 		return
 	}
+	r.mu.Lock()
+	seen := r.seen
+	r.mu.Unlock()
+	if seen {
+		r.publish(ctx, r.observedDetail())
+	}
 	r.once.Do(func() {
 		usage.PublishRecord(ctx, usage.Record{
 			Provider:    r.provider,
@@ -103,13 +176,58 @@ func (r *usageReporter) ensurePublished(ctx context.Context) {
 			APIKey:      r.apiKey,
 			AuthID:      r.authID,
 			AuthIndex:   r.authIndex,
+			Labels:      r.labels,
 			RequestedAt: r.requestedAt,
 			Failed:      false,
+			Cancelled:   clientCancelled(ctx),
+			CacheHit:    cacheHitFromContext(ctx),
 			Detail:      usage.Detail{},
 		})
 	})
 }
 
+// observedDetail returns the usage merged by observe. A total smaller than its parts comes
+// from mixing events and is dropped so publish recomputes it.
+func (r *usageReporter) observedDetail() usage.Detail {
+	r.mu.Lock()
+	observed := r.observed
+	r.mu.Unlock()
+	if observed.InputTokens+observed.OutputTokens > observed.TotalTokens {
+		observed.TotalTokens = 0
+	}
+	return observed
+}
+
+// clientCancelled reports whether ctx ended because the client went away. Executors run on
+// the handler's request context, which is cancelled when the client connection closes.
+func clientCancelled(ctx context.Context) bool {
+	return ctx != nil && errors.Is(ctx.Err(), context.Canceled)
+}
+
+// cacheHitFromContext reports whether the response cache answered the request behind ctx.
+func cacheHitFromContext(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	return responseCacheHit(ginCtx)
+}
+
+// UsageLabelsFromContext returns the usage labels the client middleware attached to the
+// request behind ctx, or nil.
+func UsageLabelsFromContext(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	if ginCtx == nil {
+		return nil
+	}
+	labels, _ := ginCtx.Get("usageLabels")
+	value, _ := labels.(map[string]string)
+	return value
+}
+
 func apiKeyFromContext(ctx context.Context) string {
 	if ctx == nil {
 		return ""
@@ -252,6 +370,29 @@ func parseOpenAIStreamUsage(line []byte) (usage.Detail, bool) {
 	return detail, true
 }
 
+// requestStreamUsage asks an OpenAI-compatible upstream to end the stream with its usage
+// chunk, without which the request would be recorded with no tokens. It reports whether that
+// chunk must be dropped before it reaches the client: an OpenAI client that did not ask for
+// it does not expect a chunk without choices. Clients of other formats get the usage through
+// the translator.
+func requestStreamUsage(from sdktranslator.Format, body []byte) ([]byte, bool) {
+	if gjson.GetBytes(body, "stream_options.include_usage").Bool() {
+		return body, false
+	}
+	body, _ = sjson.SetBytes(body, "stream_options.include_usage", true)
+	return body, from == sdktranslator.FormatOpenAI
+}
+
+// isOpenAIUsageChunk reports whether line is the trailing usage chunk of an OpenAI stream.
+func isOpenAIUsageChunk(line []byte) bool {
+	payload := jsonPayload(line)
+	if len(payload) == 0 {
+		return false
+	}
+	choices := gjson.GetBytes(payload, "choices")
+	return gjson.GetBytes(payload, "usage").IsObject() && (!choices.Exists() || (choices.IsArray() && len(choices.Array()) == 0))
+}
+
 func parseClaudeUsage(data []byte) usage.Detail {
 	usageNode := gjson.ParseBytes(data).Get("usage")
 	if !usageNode.Exists() {
@@ -276,6 +417,10 @@ func parseClaudeStreamUsage(line []byte) (usage.Detail, bool) {
 		return usage.Detail{}, false
 	}
 	usageNode := gjson.GetBytes(payload, "usage")
+	if !usageNode.Exists() {
+		// message_start nests the input token counts under message.usage.
+		usageNode = gjson.GetBytes(payload, "message.usage")
+	}
 	if !usageNode.Exists() {
 		return usage.Detail{}, false
 	}
@@ -1,6 +1,21 @@
 package executor
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
 
 func TestParseOpenAIUsageChatCompletions(t *testing.T) {
 	data := []byte(`{"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3,"prompt_tokens_details":{"cached_tokens":4},"completion_tokens_details":{"reasoning_tokens":5}}}`)
@@ -41,3 +56,59 @@ func TestParseOpenAIUsageResponses(t *testing.T) {
 		t.Fatalf("reasoning tokens = %d, want %d", detail.ReasoningTokens, 9)
 	}
 }
+
+func TestUsageReporterObserveMergesStreamEvents(t *testing.T) {
+	reporter := &usageReporter{}
+	start, _ := parseClaudeStreamUsage([]byte(`data: {"type":"message_start","message":{"usage":{"input_tokens":12,"output_tokens":1}}}`))
+	reporter.observe(start)
+	delta, _ := parseClaudeStreamUsage([]byte(`data: {"type":"message_delta","usage":{"output_tokens":40}}`))
+	reporter.observe(delta)
+	if reporter.observed.InputTokens != 12 || reporter.observed.OutputTokens != 40 {
+		t.Fatalf("observed = %+v, want input 12 and output 40", reporter.observed)
+	}
+}
+
+func TestNewUsageReporterTakesLabelsFromGinContext(t *testing.T) {
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	labels := map[string]string{"team": "infra"}
+	ginCtx.Set("usageLabels", labels)
+	reporter := newUsageReporter(context.WithValue(context.Background(), "gin", ginCtx), "openai", "gpt-5", nil)
+	if reporter.labels["team"] != "infra" {
+		t.Fatalf("reporter labels = %v, want team=infra", reporter.labels)
+	}
+	if got := UsageLabelsFromContext(context.Background()); got != nil {
+		t.Fatalf("labels without a Gin context = %v", got)
+	}
+}
+
+func TestOpenAICompatStreamCountsUsageTheClientDidNotAskFor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !gjson.GetBytes(body, "stream_options.include_usage").Bool() {
+			t.Errorf("upstream request does not ask for usage: %s", body)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"},\"finish_reason\":\"stop\"}]}\n\n")
+		_, _ = io.WriteString(w, "data: {\"id\":\"c1\",\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":3,\"total_tokens\":10}}\n\n")
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	executor := NewOpenAICompatExecutor("openai-compatibility", &config.Config{})
+	auth := &cliproxyauth.Auth{Attributes: map[string]string{"base_url": server.URL + "/v1", "api_key": "test"}}
+	payload := []byte(`{"model":"gpt-5","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	ctx, capture := usage.WithCapture(context.Background(), false)
+	stream, err := executor.ExecuteStream(ctx, auth, cliproxyexecutor.Request{Model: "gpt-5", Payload: payload},
+		cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai"), OriginalRequest: payload, Stream: true})
+	if err != nil {
+		t.Fatalf("ExecuteStream: %v", err)
+	}
+	for chunk := range stream {
+		if bytes.Contains(chunk.Payload, []byte(`"usage"`)) {
+			t.Fatalf("client got the usage chunk it did not ask for: %s", chunk.Payload)
+		}
+	}
+	if detail, ok := capture.Detail(); !ok || detail.InputTokens != 7 || detail.OutputTokens != 3 || detail.TotalTokens != 10 {
+		t.Fatalf("recorded usage = %+v, %v; want the upstream's 7+3", detail, ok)
+	}
+}
@@ -0,0 +1,376 @@
+// Package secrets resolves secret:// references in the configuration against HashiCorp
+// Vault, AWS Secrets Manager and GCP Secret Manager:
+//
+//	secret://vault/<path>[#field]                   read <path> through $VAULT_ADDR/v1/ (KV v1 or v2)
+//	secret://aws/<secret-id or ARN>[#field]         GetSecretValue in $AWS_REGION
+//	secret://gcp/<project>/<secret>[/<version>][#field]
+//	secret://gcp/projects/<project>/secrets/<secret>[/versions/<version>][#field]
+//
+// #field picks one key of a secret holding a JSON object. Credentials come from the
+// environment: VAULT_TOKEN (or VAULT_TOKEN_FILE) and VAULT_NAMESPACE for Vault, the AWS
+// environment, shared credentials file or instance role for AWS, and Google application
+// default credentials for GCP.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// Prefix marks a config value that is read from a secrets manager.
+const Prefix = "secret://"
+
+// fetchTimeout bounds a single secret fetch.
+const fetchTimeout = 15 * time.Second
+
+// maxSecretSize caps the size of a secrets manager response.
+const maxSecretSize = 1 << 20
+
+var httpClient = &http.Client{Timeout: fetchTimeout}
+
+// gcpEndpoint and gcpTokenSource are variables so tests can point GCP at a local server.
+var (
+	gcpEndpoint    = "https://secretmanager.googleapis.com"
+	gcpTokenSource = func(ctx context.Context) (oauth2.TokenSource, error) {
+		return google.DefaultTokenSource(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	}
+)
+
+// IsReference reports whether value is a secret:// reference.
+func IsReference(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), Prefix)
+}
+
+// Resolve fetches the secret ref points to and returns it trimmed. An empty secret is an
+// error, so that a missing value never leaves a key blank.
+func Resolve(ctx context.Context, ref string) (string, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(ref), Prefix)
+	if !ok {
+		return "", fmt.Errorf("%q is not a %s reference", ref, Prefix)
+	}
+	rest, field, _ := strings.Cut(rest, "#")
+	provider, path, _ := strings.Cut(rest, "/")
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "", fmt.Errorf("%s: missing secret path", ref)
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	var (
+		value string
+		err   error
+	)
+	switch provider {
+	case "vault":
+		value, err = fetchVault(ctx, path, field)
+	case "aws":
+		value, err = fetchAWS(ctx, path)
+	case "gcp":
+		value, err = fetchGCP(ctx, path)
+	default:
+		return "", fmt.Errorf("%s: unknown secrets manager %q: use vault, aws or gcp", ref, provider)
+	}
+	if err == nil && field != "" && provider != "vault" {
+		value, err = jsonField([]byte(value), field)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ref, err)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("%s: secret is empty", ref)
+	}
+	return value, nil
+}
+
+func fetchVault(ctx context.Context, path, field string) (string, error) {
+	addr := strings.TrimRight(strings.TrimSpace(os.Getenv("VAULT_ADDR")), "/")
+	if addr == "" {
+		return "", errors.New("VAULT_ADDR is not set")
+	}
+	token, err := envOrFile("VAULT_TOKEN")
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr+"/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if namespace := strings.TrimSpace(os.Getenv("VAULT_NAMESPACE")); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	body, err := do(req)
+	if err != nil {
+		return "", err
+	}
+	var payload struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err = json.Unmarshal(body, &payload); err != nil {
+		return "", fmt.Errorf("decode vault response: %w", err)
+	}
+	data := payload.Data
+	// KV v2 nests the secret under data.data next to data.metadata.
+	if inner, ok := data["data"]; ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nil
+			if err = json.Unmarshal(inner, &data); err != nil {
+				return "", fmt.Errorf("decode vault response: %w", err)
+			}
+		}
+	}
+	if field == "" {
+		if len(data) != 1 {
+			return "", fmt.Errorf("secret has %d fields; name one as #field", len(data))
+		}
+		for name := range data {
+			field = name
+		}
+	}
+	raw, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("secret has no field %q", field)
+	}
+	return rawString(raw), nil
+}
+
+func fetchAWS(ctx context.Context, secretID string) (string, error) {
+	region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	endpoint := firstEnv("AWS_ENDPOINT_URL_SECRETS_MANAGER", "AWS_ENDPOINT_URL")
+	switch {
+	case endpoint == "" && region == "":
+		return "", errors.New("AWS_REGION is not set")
+	case endpoint == "":
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com"
+	case region == "":
+		region = "us-east-1"
+	}
+	creds, err := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{Client: httpClient},
+	}).Get()
+	if err != nil {
+		return "", fmt.Errorf("aws credentials: %w", err)
+	}
+	body, _ := json.Marshal(map[string]string{"SecretId": secretID})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signV4(req, body, creds, region, "secretsmanager", time.Now().UTC())
+	resp, err := do(req)
+	if err != nil {
+		return "", err
+	}
+	var payload struct {
+		SecretString string `json:"SecretString"`
+		SecretBinary []byte `json:"SecretBinary"`
+	}
+	if err = json.Unmarshal(resp, &payload); err != nil {
+		return "", fmt.Errorf("decode secrets manager response: %w", err)
+	}
+	if payload.SecretString != "" {
+		return payload.SecretString, nil
+	}
+	return string(payload.SecretBinary), nil
+}
+
+func fetchGCP(ctx context.Context, path string) (string, error) {
+	name := path
+	if !strings.HasPrefix(name, "projects/") {
+		parts := strings.Split(path, "/")
+		if len(parts) < 2 || len(parts) > 3 {
+			return "", fmt.Errorf("want <project>/<secret>[/<version>], got %q", path)
+		}
+		version := "latest"
+		if len(parts) == 3 {
+			version = parts[2]
+		}
+		name = "projects/" + parts[0] + "/secrets/" + parts[1] + "/versions/" + version
+	} else if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	tokens, err := gcpTokenSource(ctx)
+	if err != nil {
+		return "", fmt.Errorf("google credentials: %w", err)
+	}
+	token, err := tokens.Token()
+	if err != nil {
+		return "", fmt.Errorf("google credentials: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(gcpEndpoint, "/")+"/v1/"+name+":access", nil)
+	if err != nil {
+		return "", err
+	}
+	token.SetAuthHeader(req)
+	body, err := do(req)
+	if err != nil {
+		return "", err
+	}
+	var payload struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err = json.Unmarshal(body, &payload); err != nil {
+		return "", fmt.Errorf("decode secret manager response: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(payload.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("decode secret payload: %w", err)
+	}
+	return string(data), nil
+}
+
+func do(req *http.Request) ([]byte, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSecretSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxSecretSize {
+		return nil, fmt.Errorf("response larger than %d bytes", maxSecretSize)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Error bodies describe the failure; they never carry the secret.
+		detail := strings.TrimSpace(string(body))
+		if len(detail) > 512 {
+			detail = detail[:512]
+		}
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, detail)
+	}
+	return body, nil
+}
+
+// jsonField returns field of the JSON object data.
+func jsonField(data []byte, field string) (string, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return "", fmt.Errorf("#%s needs a JSON object secret: %w", field, err)
+	}
+	raw, ok := object[field]
+	if !ok {
+		return "", fmt.Errorf("secret has no field %q", field)
+	}
+	return rawString(raw), nil
+}
+
+// rawString returns a JSON string unquoted and any other JSON value as written.
+func rawString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func envOrFile(key string) (string, error) {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value, nil
+	}
+	path := strings.TrimSpace(os.Getenv(key + "_FILE"))
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s_FILE: %w", key, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// signV4 signs req, whose payload is body, with AWS Signature Version 4.
+func signV4(req *http.Request, body []byte, creds credentials.Value, region, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	payloadHash := sha256Hex(body)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, canonicalQuery(req.URL.Query()), canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func canonicalQuery(values url.Values) string {
+	return strings.ReplaceAll(values.Encode(), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,96 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"golang.org/x/oauth2"
+)
+
+func TestResolveVaultKV2Field(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/cliproxy" || r.Header.Get("X-Vault-Token") != "root" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"claude":"sk-ant-1","client":"key-1"},"metadata":{"version":3}}}`))
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+
+	if got, err := Resolve(context.Background(), "secret://vault/secret/data/cliproxy#claude"); err != nil || got != "sk-ant-1" {
+		t.Fatalf("Resolve = %q, %v", got, err)
+	}
+	if _, err := Resolve(context.Background(), "secret://vault/secret/data/cliproxy"); err == nil || !strings.Contains(err.Error(), "#field") {
+		t.Fatalf("a secret with several fields needs #field, got %v", err)
+	}
+	t.Setenv("VAULT_TOKEN", "wrong")
+	if _, err := Resolve(context.Background(), "secret://vault/secret/data/cliproxy#claude"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("denied read = %v", err)
+	}
+}
+
+func TestResolveAWSSecretJSONField(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ SecretId string }
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" || body.SecretId != "prod/cliproxy" ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"SecretString":"{\"management\":\"admin\"}"}`))
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	if got, err := Resolve(context.Background(), "secret://aws/prod/cliproxy#management"); err != nil || got != "admin" {
+		t.Fatalf("Resolve = %q, %v", got, err)
+	}
+}
+
+func TestResolveGCPLatestVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/projects/my-project/secrets/claude-key/versions/latest:access" || r.Header.Get("Authorization") != "Bearer token" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"payload":{"data":"` + base64.StdEncoding.EncodeToString([]byte("sk-ant-2\n")) + `"}}`))
+	}))
+	defer server.Close()
+	endpoint, tokens := gcpEndpoint, gcpTokenSource
+	defer func() { gcpEndpoint, gcpTokenSource = endpoint, tokens }()
+	gcpEndpoint = server.URL
+	gcpTokenSource = func(context.Context) (oauth2.TokenSource, error) {
+		return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}), nil
+	}
+
+	for _, ref := range []string{"secret://gcp/my-project/claude-key", "secret://gcp/projects/my-project/secrets/claude-key"} {
+		if got, err := Resolve(context.Background(), ref); err != nil || got != "sk-ant-2" {
+			t.Fatalf("Resolve(%q) = %q, %v", ref, got, err)
+		}
+	}
+}
+
+// The example request from the AWS Signature Version 4 documentation.
+func TestSignV4MatchesReferenceSignature(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := credentials.Value{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signV4(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("Authorization =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/transport"
 	"github.com/go-git/go-git/v6/plumbing/transport/http"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 )
 
@@ -442,6 +443,9 @@ func (s *GitTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth,
 	if email, ok := metadata["email"].(string); ok && email != "" {
 		auth.Attributes["email"] = email
 	}
+	if errProxy := auth.ApplyMetadataProxyURL(); errProxy != nil {
+		log.Errorf("git store: auth %s disabled until proxy_url is fixed: %v", id, errProxy)
+	}
 	return auth, nil
 }
 
@@ -14,9 +14,9 @@ import (
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
-	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -310,6 +310,9 @@ func (s *PostgresStore) List(ctx context.Context) ([]*cliproxyauth.Auth, error)
 			LastRefreshedAt:  time.Time{},
 			NextRefreshAfter: time.Time{},
 		}
+		if errProxy := auth.ApplyMetadataProxyURL(); errProxy != nil {
+			log.Errorf("postgres store: auth %s disabled until proxy_url is fixed: %v", auth.ID, errProxy)
+		}
 		auths = append(auths, auth)
 	}
 	if err = rows.Err(); err != nil {
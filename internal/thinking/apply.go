@@ -4,8 +4,8 @@ package thinking
 import (
 	"strings"
 
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
-	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 )
 
@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"strings"
 
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
-	log "github.com/sirupsen/logrus"
 )
 
 // ValidateConfig validates a thinking configuration against model capabilities.
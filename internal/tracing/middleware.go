@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts the server span of each request, continuing the trace of an incoming
+// traceparent header, and stores it in the request context for the handlers below.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !enabled.Load() {
+			c.Next()
+			return
+		}
+		t, _ := tracer.Load().(trace.Tracer)
+		if t == nil {
+			c.Next()
+			return
+		}
+		route := c.FullPath()
+		name := c.Request.Method
+		if route != "" {
+			name += " " + route
+		}
+		ctx := propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := t.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", c.Request.URL.Path),
+		))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(AttrStatusCode.Int(status))
+		if model := logging.GetGinRequestModel(c); model != "" {
+			span.SetAttributes(AttrModel.String(model))
+		}
+		if requestID := logging.GetGinRequestID(c); requestID != "" {
+			span.SetAttributes(attribute.String("cliproxy.request_id", requestID))
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
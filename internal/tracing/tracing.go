@@ -0,0 +1,211 @@
+// Package tracing exports OpenTelemetry spans for the request pipeline: one server span per
+// inbound request, with children for account selection, each upstream attempt, its HTTP
+// calls, response translation and stream completion. While tracing is disabled every helper returns immediately without creating
+// spans or touching the context.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/router-for-me/CLIProxyAPI/v6"
+	shutdownTimeout     = 5 * time.Second
+)
+
+// Attribute keys shared by the spans of the pipeline.
+const (
+	AttrModel           = attribute.Key("gen_ai.request.model")
+	AttrUpstreamModel   = attribute.Key("cliproxy.upstream.model")
+	AttrProvider        = attribute.Key("cliproxy.provider")
+	AttrAccountHash     = attribute.Key("cliproxy.account.hash")
+	AttrAttempt         = attribute.Key("cliproxy.attempt")
+	AttrStatusCode      = attribute.Key("http.response.status_code")
+	AttrInputTokens     = attribute.Key("gen_ai.usage.input_tokens")
+	AttrOutputTokens    = attribute.Key("gen_ai.usage.output_tokens")
+	AttrReasoningTokens = attribute.Key("cliproxy.usage.reasoning_tokens")
+	AttrCachedTokens    = attribute.Key("cliproxy.usage.cached_tokens")
+	AttrTotalTokens     = attribute.Key("cliproxy.usage.total_tokens")
+)
+
+var (
+	// enabled is checked first by every helper, so the disabled path is a single atomic load.
+	enabled atomic.Bool
+	tracer  atomic.Value // trace.Tracer
+
+	mu       sync.Mutex
+	applied  config.TracingConfig
+	provider *sdktrace.TracerProvider
+
+	propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+	// noopSpan is returned while tracing is disabled; ending it does nothing.
+	noopSpan = trace.SpanFromContext(context.Background())
+)
+
+// Configure applies the tracing settings, replacing the exporter when they changed. Spans
+// already buffered by a previous exporter are flushed in the background.
+func Configure(cfg config.TracingConfig) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if provider != nil && reflect.DeepEqual(applied, cfg) {
+		return nil
+	}
+	if !cfg.Enabled {
+		applied = cfg
+		swapProviderLocked(nil)
+		return nil
+	}
+
+	opts := make([]otlptracehttp.Option, 0, 2)
+	if endpoint := strings.TrimSpace(cfg.Endpoint); endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return fmt.Errorf("tracing: create OTLP exporter: %w", err)
+	}
+	res := resource.NewSchemaless(
+		attribute.String("service.name", cfg.ServiceNameOrDefault()),
+		attribute.String("service.version", buildinfo.Version),
+	)
+	applied = cfg
+	swapProviderLocked(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Ratio()))),
+	))
+	return nil
+}
+
+// UseTracerProvider installs tp directly, bypassing the OTLP exporter. Tests use it with an
+// in-memory span recorder; nil disables tracing.
+func UseTracerProvider(tp *sdktrace.TracerProvider) {
+	mu.Lock()
+	defer mu.Unlock()
+	applied = config.TracingConfig{}
+	swapProviderLocked(tp)
+}
+
+// Shutdown disables tracing and flushes spans that have not been exported yet.
+func Shutdown(ctx context.Context) error {
+	mu.Lock()
+	previous := provider
+	provider = nil
+	enabled.Store(false)
+	mu.Unlock()
+	if previous == nil {
+		return nil
+	}
+	return previous.Shutdown(ctx)
+}
+
+func swapProviderLocked(tp *sdktrace.TracerProvider) {
+	previous := provider
+	provider = tp
+	if tp != nil {
+		tracer.Store(tp.Tracer(instrumentationName))
+	}
+	enabled.Store(tp != nil)
+	if previous != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := previous.Shutdown(ctx); err != nil {
+				log.Warnf("tracing: flush previous exporter: %v", err)
+			}
+		}()
+	}
+}
+
+// Enabled reports whether spans are being created.
+func Enabled() bool { return enabled.Load() }
+
+// Start begins a span named name as a child of the span in ctx. While tracing is disabled it
+// returns ctx unchanged and a span that does nothing. Callers set attributes only when the
+// span is recording, so the disabled and unsampled paths build none.
+func Start(ctx context.Context, name string, kind trace.SpanKind) (context.Context, trace.Span) {
+	if !enabled.Load() {
+		return ctx, noopSpan
+	}
+	t, _ := tracer.Load().(trace.Tracer)
+	if t == nil {
+		return ctx, noopSpan
+	}
+	return t.Start(ctx, name, trace.WithSpanKind(kind))
+}
+
+// End marks span as failed when err is set and ends it.
+func End(span trace.Span, err error) {
+	if err != nil && span.IsRecording() {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inherit returns dst carrying the span of src when dst has none, so work started from a
+// detached context still joins the request's trace.
+func Inherit(dst, src context.Context) context.Context {
+	if !enabled.Load() || src == nil {
+		return dst
+	}
+	if trace.SpanContextFromContext(dst).IsValid() {
+		return dst
+	}
+	span := trace.SpanFromContext(src)
+	if !span.SpanContext().IsValid() {
+		return dst
+	}
+	return trace.ContextWithSpan(dst, span)
+}
+
+// AddEvent records a named point in time, such as the first token of a stream, on the span
+// in ctx.
+func AddEvent(ctx context.Context, name string) {
+	if !enabled.Load() || ctx == nil {
+		return
+	}
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		span.AddEvent(name)
+	}
+}
+
+// RecordUsage adds token counts to the span in ctx, normally the upstream attempt that
+// produced them.
+func RecordUsage(ctx context.Context, input, output, reasoning, cached, total int64) {
+	if !enabled.Load() || ctx == nil {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		AttrInputTokens.Int64(input),
+		AttrOutputTokens.Int64(output),
+		AttrReasoningTokens.Int64(reasoning),
+		AttrCachedTokens.Int64(cached),
+		AttrTotalTokens.Int64(total),
+	)
+}
@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	UseTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer UseTracerProvider(nil)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(Middleware())
+	var handlerSpan trace.SpanContext
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		handlerSpan = trace.SpanContextFromContext(Inherit(context.Background(), c.Request.Context()))
+		logging.SetGinRequestModel(c, "gpt-5")
+		c.Status(http.StatusBadGateway)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("recorded %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "POST /v1/chat/completions" || span.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("span %q of kind %v, want a server span named after the route", span.Name(), span.SpanKind())
+	}
+	if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("trace id %s, want the incoming one", got)
+	}
+	if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Fatalf("parent span %s, want the caller's", got)
+	}
+	if handlerSpan.SpanID() != span.SpanContext().SpanID() {
+		t.Fatal("handlers do not see the server span")
+	}
+	var status int64
+	var model string
+	for _, kv := range span.Attributes() {
+		switch kv.Key {
+		case AttrStatusCode:
+			status = kv.Value.AsInt64()
+		case AttrModel:
+			model = kv.Value.AsString()
+		}
+	}
+	if status != http.StatusBadGateway || model != "gpt-5" {
+		t.Fatalf("span records status %d and model %q, want 502 and gpt-5", status, model)
+	}
+}
+
+func TestDisabledTracingLeavesContextUntouched(t *testing.T) {
+	if err := Configure(config.TracingConfig{}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	ctx := context.Background()
+	got, span := Start(ctx, "unused", trace.SpanKindInternal)
+	if got != ctx || span.IsRecording() {
+		t.Fatal("Start created a span while tracing is disabled")
+	}
+	End(span, nil)
+}
+
+func TestConfigureEnablesAndDisablesExport(t *testing.T) {
+	ratio := 0.5
+	cfg := config.TracingConfig{Enabled: true, Endpoint: "http://127.0.0.1:1", SampleRatio: &ratio}
+	if err := Configure(cfg); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if !Enabled() {
+		t.Fatal("tracing not enabled after Configure")
+	}
+	if err := Configure(config.TracingConfig{}); err != nil {
+		t.Fatalf("Configure disabled: %v", err)
+	}
+	if Enabled() {
+		t.Fatal("tracing still enabled after disabling it")
+	}
+}
+
+func TestTransportTracesUpstreamCallsWithoutPropagating(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	UseTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer UseTracerProvider(nil)
+
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: Transport(nil)}
+
+	ctx, attempt := Start(context.Background(), "upstream.attempt", trace.SpanKindClient)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, upstream.URL+"/v1/messages", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	_ = resp.Body.Close()
+	attempt.End()
+	req, _ = http.NewRequest(http.MethodGet, upstream.URL+"/token", nil)
+	if resp, err = client.Do(req); err != nil {
+		t.Fatalf("Do without a trace: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "upstream.http" || spans[0].SpanKind() != trace.SpanKindClient {
+		t.Fatalf("recorded %d spans, want an upstream.http client span then the attempt", len(spans))
+	}
+	if spans[0].Parent().SpanID() != attempt.SpanContext().SpanID() {
+		t.Fatal("upstream.http span is not a child of the attempt")
+	}
+	var status int64
+	for _, kv := range spans[0].Attributes() {
+		if kv.Key == AttrStatusCode {
+			status = kv.Value.AsInt64()
+		}
+	}
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("span records status %d, want 429", status)
+	}
+	if traceparent != "" {
+		t.Fatalf("upstream received traceparent %q", traceparent)
+	}
+}
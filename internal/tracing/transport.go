@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transport wraps base so that every upstream HTTP call gets an "upstream.http" client span
+// under the span of its request context, normally the upstream attempt; calls made outside
+// a trace, such as token refreshes, get none. The span ends once the response headers
+// arrive, so it measures the provider's time to respond apart from the time spent reading
+// and translating the body. No trace headers are sent: providers never see the proxy's
+// trace ids.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if _, ok := base.(*transport); ok {
+		return base
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !enabled.Load() || !trace.SpanContextFromContext(req.Context()).IsValid() {
+		return t.base.RoundTrip(req)
+	}
+	_, span := Start(req.Context(), "upstream.http", trace.SpanKindClient)
+	if span.IsRecording() {
+		span.SetAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Hostname()),
+			attribute.String("url.path", req.URL.Path),
+		)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && span.IsRecording() {
+		span.SetAttributes(AttrStatusCode.Int(resp.StatusCode))
+		if resp.StatusCode >= http.StatusBadRequest {
+			span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+		}
+	}
+	End(span, err)
+	return resp, err
+}
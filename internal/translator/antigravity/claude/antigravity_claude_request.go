@@ -400,6 +400,7 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 	}
 
 	outBytes := []byte(out)
+	outBytes = common.ApplyStopSequences(outBytes, gjson.GetBytes(rawJSON, "stop_sequences"), "request.generationConfig.stopSequences")
 	outBytes = common.AttachDefaultSafetySettings(outBytes, "request.safetySettings")
 
 	return outBytes
@@ -15,7 +15,7 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
-	log "github.com/sirupsen/logrus"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -519,5 +519,5 @@ func ConvertAntigravityResponseToClaudeNonStream(_ context.Context, _ string, or
 }
 
 func ClaudeTokenCount(ctx context.Context, count int64) string {
-	return fmt.Sprintf(`{"input_tokens":%d}`, count)
+	return fmt.Sprintf(`{"input_tokens":%d,"estimated":false}`, count)
 }
@@ -7,10 +7,10 @@ import (
 	"fmt"
 	"strings"
 
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
-	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -62,6 +62,9 @@ func ConvertOpenAIRequestToAntigravity(modelName string, inputRawJSON []byte, _
 	if tkr := gjson.GetBytes(rawJSON, "top_k"); tkr.Exists() && tkr.Type == gjson.Number {
 		out, _ = sjson.SetBytes(out, "request.generationConfig.topK", tkr.Num)
 	}
+
+	// Stop sequences
+	out = common.ApplyStopSequences(out, gjson.GetBytes(rawJSON, "stop"), "request.generationConfig.stopSequences")
 	if maxTok := gjson.GetBytes(rawJSON, "max_tokens"); maxTok.Exists() && maxTok.Type == gjson.Number {
 		out, _ = sjson.SetBytes(out, "request.generationConfig.maxOutputTokens", maxTok.Num)
 	}
@@ -411,6 +414,9 @@ func ConvertOpenAIRequestToAntigravity(modelName string, inputRawJSON []byte, _
 		}
 	}
 
+	out = common.ApplyOpenAIToolChoice(out, rawJSON, "request.toolConfig")
+	out = common.ApplyOpenAIResponseFormat(out, rawJSON, "request.generationConfig")
+
 	return common.AttachDefaultSafetySettings(out, "request.safetySettings")
 }
 
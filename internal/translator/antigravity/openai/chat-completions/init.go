@@ -3,6 +3,7 @@ package chat_completions
 import (
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	geminichat "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/chat-completions"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translator"
 )
 
@@ -12,8 +13,9 @@ func init() {
 		Antigravity,
 		ConvertOpenAIRequestToAntigravity,
 		interfaces.TranslateResponse{
-			Stream:    ConvertAntigravityResponseToOpenAI,
-			NonStream: ConvertAntigravityResponseToOpenAINonStream,
+			Stream:     ConvertAntigravityResponseToOpenAI,
+			NonStream:  ConvertAntigravityResponseToOpenAINonStream,
+			TokenCount: geminichat.OpenAITokenCount,
 		},
 	)
 }
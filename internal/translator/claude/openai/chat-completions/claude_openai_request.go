@@ -16,6 +16,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -138,6 +139,7 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 	if messages := root.Get("messages"); messages.Exists() && messages.IsArray() {
 		messageIndex := 0
 		systemMessageIndex := -1
+		toolResultMessageIndex := -1
 		messages.ForEach(func(_, message gjson.Result) bool {
 			role := message.Get("role").String()
 			contentResult := message.Get("content")
@@ -199,6 +201,11 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 									imagePart, _ = sjson.Set(imagePart, "source.data", data)
 									msg, _ = sjson.SetRaw(msg, "content.-1", imagePart)
 								}
+							} else if strings.HasPrefix(imageURL, "https://") || strings.HasPrefix(imageURL, "http://") {
+								// Claude fetches remote images itself.
+								imagePart := `{"type":"image","source":{"type":"url","url":""}}`
+								imagePart, _ = sjson.Set(imagePart, "source.url", imageURL)
+								msg, _ = sjson.SetRaw(msg, "content.-1", imagePart)
 							}
 						}
 						return true
@@ -248,12 +255,19 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 			case "tool":
 				// Handle tool result messages conversion
 				toolCallID := message.Get("tool_call_id").String()
-				content := message.Get("content").String()
-
-				msg := `{"role":"user","content":[{"type":"tool_result","tool_use_id":"","content":""}]}`
-				msg, _ = sjson.Set(msg, "content.0.tool_use_id", toolCallID)
-				msg, _ = sjson.Set(msg, "content.0.content", content)
+				toolResult := `{"type":"tool_result","tool_use_id":"","content":""}`
+				toolResult, _ = sjson.Set(toolResult, "tool_use_id", toolCallID)
+				toolResult, _ = sjson.Set(toolResult, "content", common.ToolResultText(contentResult))
+
+				// Results of parallel tool calls must share the user turn that follows the tool_use blocks.
+				if toolResultMessageIndex >= 0 && toolResultMessageIndex == messageIndex-1 {
+					out, _ = sjson.SetRaw(out, fmt.Sprintf("messages.%d.content.-1", toolResultMessageIndex), toolResult)
+					return true
+				}
+				msg := `{"role":"user","content":[]}`
+				msg, _ = sjson.SetRaw(msg, "content.-1", toolResult)
 				out, _ = sjson.SetRaw(out, "messages.-1", msg)
+				toolResultMessageIndex = messageIndex
 				messageIndex++
 			}
 			return true
@@ -289,13 +303,13 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 	}
 
 	// Tool choice mapping from OpenAI format to Claude Code format
-	if toolChoice := root.Get("tool_choice"); toolChoice.Exists() {
+	if toolChoice := root.Get("tool_choice"); toolChoice.Exists() && gjson.Get(out, "tools").Exists() {
 		switch toolChoice.Type {
 		case gjson.String:
 			choice := toolChoice.String()
 			switch choice {
 			case "none":
-				// Don't set tool_choice, Claude Code will not use tools
+				out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"none"}`)
 			case "auto":
 				out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"auto"}`)
 			case "required":
@@ -313,5 +327,21 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 		}
 	}
 
+	// OpenAI parallel_tool_calls=false maps to Claude's disable_parallel_tool_use on the tool choice.
+	if parallel := root.Get("parallel_tool_calls"); parallel.Exists() && !parallel.Bool() && gjson.Get(out, "tools").Exists() {
+		switch gjson.Get(out, "tool_choice.type").String() {
+		case "none":
+		case "":
+			out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"auto","disable_parallel_tool_use":true}`)
+		default:
+			out, _ = sjson.Set(out, "tool_choice.disable_parallel_tool_use", true)
+		}
+	}
+
+	if jsonModeRequested(rawJSON) {
+		instruction, _ := sjson.Set(`{"type":"text","text":""}`, "text", jsonModeInstruction(rawJSON))
+		out, _ = sjson.SetRaw(out, "system", "["+instruction+"]")
+	}
+
 	return []byte(out)
 }
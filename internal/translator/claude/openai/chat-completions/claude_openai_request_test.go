@@ -0,0 +1,62 @@
+package chat_completions
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertOpenAIRequestToClaude_ParallelToolResultsShareUserTurn(t *testing.T) {
+	input := []byte(`{
+		"model":"gpt-4o",
+		"messages":[
+			{"role":"user","content":"weather in Paris and Rome?"},
+			{"role":"assistant","content":null,"tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}},
+				{"id":"call_2","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Rome\"}"}}
+			]},
+			{"role":"tool","tool_call_id":"call_1","content":"sunny"},
+			{"role":"tool","tool_call_id":"call_2","content":[{"type":"text","text":"rain"}]}
+		],
+		"tools":[{"type":"function","function":{"name":"weather","parameters":{"type":"object"}}}],
+		"tool_choice":"required",
+		"parallel_tool_calls":false
+	}`)
+
+	out := gjson.ParseBytes(ConvertOpenAIRequestToClaude("claude-sonnet-4", input, false))
+
+	messages := out.Get("messages").Array()
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d: %s", len(messages), out.Get("messages").Raw)
+	}
+	if got := messages[1].Get("content.#(type==\"tool_use\")#.input.city").Raw; got != `["Paris","Rome"]` {
+		t.Fatalf("unexpected tool_use inputs: %s", got)
+	}
+	results := messages[2].Get("content").Array()
+	if len(results) != 2 || results[0].Get("tool_use_id").String() != "call_1" || results[1].Get("content").String() != "rain" {
+		t.Fatalf("tool results were not merged into one user turn: %s", messages[2].Raw)
+	}
+	if out.Get("tool_choice.type").String() != "any" || !out.Get("tool_choice.disable_parallel_tool_use").Bool() {
+		t.Fatalf("unexpected tool_choice: %s", out.Get("tool_choice").Raw)
+	}
+}
+
+func TestConvertOpenAIRequestToClaude_ToolChoice(t *testing.T) {
+	cases := map[string]string{
+		`"none"`: `{"type":"none"}`,
+		`"auto"`: `{"type":"auto"}`,
+		`{"type":"function","function":{"name":"weather"}}`: `{"type":"tool","name":"weather"}`,
+	}
+	for choice, want := range cases {
+		input := []byte(`{"messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function","function":{"name":"weather"}}],"tool_choice":` + choice + `}`)
+		out := ConvertOpenAIRequestToClaude("claude-sonnet-4", input, false)
+		if got := gjson.GetBytes(out, "tool_choice").Raw; got != want {
+			t.Fatalf("tool_choice %s: got %s, want %s", choice, got, want)
+		}
+	}
+
+	noTools := []byte(`{"messages":[{"role":"user","content":"hi"}],"tool_choice":"auto"}`)
+	if gjson.GetBytes(ConvertOpenAIRequestToClaude("claude-sonnet-4", noTools, false), "tool_choice").Exists() {
+		t.Fatal("tool_choice must be dropped when no tools are sent")
+	}
+}
@@ -27,6 +27,10 @@ type ConvertAnthropicResponseToOpenAIParams struct {
 	FinishReason string
 	// Tool calls accumulator for streaming
 	ToolCallsAccumulator map[int]*ToolCallAccumulator
+	// ToolCallCount is the number of tool calls started so far in this response.
+	ToolCallCount int
+	// JSONFence strips code fences from text when the client asked for JSON output.
+	JSONFence *jsonFenceStripper
 }
 
 // ToolCallAccumulator holds the state for accumulating tool call data
@@ -34,6 +38,9 @@ type ToolCallAccumulator struct {
 	ID        string
 	Name      string
 	Arguments strings.Builder
+	// Index is the position of the call in the OpenAI tool_calls array, independent of
+	// the Claude content block index (text and thinking blocks also consume block indices).
+	Index int
 }
 
 // ConvertClaudeResponseToOpenAI converts Claude Code streaming response format to OpenAI Chat Completions format.
@@ -51,11 +58,15 @@ type ToolCallAccumulator struct {
 //   - []string: A slice of strings, each containing an OpenAI-compatible JSON response
 func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	if *param == nil {
-		*param = &ConvertAnthropicResponseToOpenAIParams{
+		p := &ConvertAnthropicResponseToOpenAIParams{
 			CreatedAt:    0,
 			ResponseID:   "",
 			FinishReason: "",
 		}
+		if jsonModeRequested(originalRequestRawJSON) {
+			p.JSONFence = &jsonFenceStripper{}
+		}
+		*param = p
 	}
 
 	if !bytes.HasPrefix(rawJSON, dataTag) {
@@ -118,13 +129,22 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 					(*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator = make(map[int]*ToolCallAccumulator)
 				}
 
-				(*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index] = &ToolCallAccumulator{
-					ID:   toolCallID,
-					Name: toolName,
+				p := (*param).(*ConvertAnthropicResponseToOpenAIParams)
+				accumulator := &ToolCallAccumulator{
+					ID:    toolCallID,
+					Name:  toolName,
+					Index: p.ToolCallCount,
 				}
+				p.ToolCallsAccumulator[index] = accumulator
+				p.ToolCallCount++
 
-				// Don't output anything yet - wait for complete tool call
-				return []string{}
+				// Announce the call right away; arguments follow as incremental deltas.
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.index", accumulator.Index)
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.id", accumulator.ID)
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.type", "function")
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.name", accumulator.Name)
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.arguments", "")
+				return []string{template}
 			}
 		}
 		return []string{}
@@ -139,8 +159,14 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 			case "text_delta":
 				// Text content delta - send incremental text updates
 				if text := delta.Get("text"); text.Exists() {
-					template, _ = sjson.Set(template, "choices.0.delta.content", text.String())
-					hasContent = true
+					content := text.String()
+					if fence := (*param).(*ConvertAnthropicResponseToOpenAIParams).JSONFence; fence != nil {
+						content = fence.feed(content)
+					}
+					if content != "" {
+						template, _ = sjson.Set(template, "choices.0.delta.content", content)
+						hasContent = true
+					}
 				}
 			case "thinking_delta":
 				// Accumulate reasoning/thinking content
@@ -150,15 +176,17 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 				}
 			case "input_json_delta":
 				// Tool use input delta - accumulate arguments for tool calls
-				if partialJSON := delta.Get("partial_json"); partialJSON.Exists() {
+				if partialJSON := delta.Get("partial_json"); partialJSON.Exists() && partialJSON.String() != "" {
 					index := int(root.Get("index").Int())
 					if (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator != nil {
 						if accumulator, exists := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index]; exists {
 							accumulator.Arguments.WriteString(partialJSON.String())
+							template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.index", accumulator.Index)
+							template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.arguments", partialJSON.String())
+							return []string{template}
 						}
 					}
 				}
-				// Don't output anything yet - wait for complete tool call
 				return []string{}
 			}
 		}
@@ -169,30 +197,30 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 		}
 
 	case "content_block_stop":
-		// End of content block - output complete tool call if it's a tool_use block
+		// End of content block - close out a tool call that streamed no arguments
 		index := int(root.Get("index").Int())
 		if (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator != nil {
 			if accumulator, exists := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index]; exists {
-				// Build complete tool call with accumulated arguments
-				arguments := accumulator.Arguments.String()
-				if arguments == "" {
-					arguments = "{}"
-				}
-				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.index", index)
-				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.id", accumulator.ID)
-				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.type", "function")
-				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.name", accumulator.Name)
-				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.arguments", arguments)
-
-				// Clean up the accumulator for this index
 				delete((*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator, index)
-
-				return []string{template}
+				if accumulator.Arguments.Len() == 0 {
+					template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.index", accumulator.Index)
+					template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.arguments", "{}")
+					return []string{template}
+				}
 			}
 		}
 		return []string{}
 
 	case "message_delta":
+		// Release text held back by the JSON fence stripper before the final chunk.
+		var results []string
+		if fence := (*param).(*ConvertAnthropicResponseToOpenAIParams).JSONFence; fence != nil {
+			if rest := fence.flush(); rest != "" {
+				contentChunk, _ := sjson.Set(template, "choices.0.delta.content", rest)
+				results = append(results, contentChunk)
+			}
+		}
+
 		// Handle message-level changes including stop reason and usage
 		if delta := root.Get("delta"); delta.Exists() {
 			if stopReason := delta.Get("stop_reason"); stopReason.Exists() {
@@ -212,7 +240,7 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 			template, _ = sjson.Set(template, "usage.total_tokens", inputTokens+outputTokens)
 			template, _ = sjson.Set(template, "usage.prompt_tokens_details.cached_tokens", cacheReadInputTokens)
 		}
-		return []string{template}
+		return append(results, template)
 
 	case "message_stop":
 		// Final message event - no additional output needed
@@ -381,6 +409,9 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 
 	// Set message content by combining all text parts
 	messageContent := strings.Join(contentParts, "")
+	if jsonModeRequested(originalRequestRawJSON) {
+		messageContent = stripJSONFence(messageContent)
+	}
 	out, _ = sjson.Set(out, "choices.0.message.content", messageContent)
 
 	// Add reasoning content if available (following OpenAI reasoning format)
@@ -430,3 +461,8 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 
 	return out
 }
+
+// OpenAITokenCount reports a count from the upstream's native token counting API.
+func OpenAITokenCount(ctx context.Context, count int64) string {
+	return fmt.Sprintf(`{"input_tokens":%d,"estimated":false}`, count)
+}
@@ -0,0 +1,95 @@
+package chat_completions
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertClaudeResponseToOpenAI_StreamsParallelToolCallDeltas(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1"}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking."}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_a","name":"weather"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"ci"}}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_b","name":"time"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"ty\":\"Paris\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":5}}`,
+	}
+
+	var param any
+	ids := map[int64]string{}
+	args := map[int64]*strings.Builder{}
+	finish := ""
+	for _, event := range events {
+		for _, chunk := range ConvertClaudeResponseToOpenAI(context.Background(), "claude-sonnet-4", nil, nil, []byte("data: "+event), &param) {
+			root := gjson.Parse(chunk)
+			if reason := root.Get("choices.0.finish_reason").String(); reason != "" {
+				finish = reason
+			}
+			for _, call := range root.Get("choices.0.delta.tool_calls").Array() {
+				index := call.Get("index").Int()
+				if id := call.Get("id").String(); id != "" {
+					ids[index] = id
+				}
+				if args[index] == nil {
+					args[index] = &strings.Builder{}
+				}
+				args[index].WriteString(call.Get("function.arguments").String())
+			}
+		}
+	}
+
+	if ids[0] != "toolu_a" || ids[1] != "toolu_b" {
+		t.Fatalf("tool calls should be numbered from zero in start order, got %v", ids)
+	}
+	if got := args[0].String(); got != `{"city":"Paris"}` {
+		t.Fatalf("reassembled arguments = %q", got)
+	}
+	if got := args[1].String(); got != `{}` {
+		t.Fatalf("a call without arguments should stream {}, got %q", got)
+	}
+	if finish != "tool_calls" {
+		t.Fatalf("finish_reason = %q", finish)
+	}
+}
+
+func TestConvertClaudeResponseToOpenAI_StripsJSONFenceWhileStreaming(t *testing.T) {
+	request := []byte(`{"response_format":{"type":"json_object"}}`)
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"` + "``" + `"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"` + "`json\\n{\\\"a\\\":" + `"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"1}\n` + "``" + `"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"` + "`" + `"}}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"}}`,
+	}
+	var param any
+	var content strings.Builder
+	deltas := 0
+	for _, event := range events {
+		for _, chunk := range ConvertClaudeResponseToOpenAI(context.Background(), "claude-sonnet-4", request, nil, []byte("data: "+event), &param) {
+			if text := gjson.Get(chunk, "choices.0.delta.content"); text.Exists() {
+				content.WriteString(text.String())
+				deltas++
+			}
+		}
+	}
+	if content.String() != `{"a":1}` {
+		t.Fatalf("content = %q, want the JSON without fences", content.String())
+	}
+	if deltas < 2 {
+		t.Fatalf("JSON mode must keep streaming deltas, got %d", deltas)
+	}
+
+	nonStream := ConvertClaudeResponseToOpenAINonStream(context.Background(), "", request, nil, []byte("data: "+`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"`+"```json\\n{\\\"a\\\":1}\\n```"+`"}}`), nil)
+	if got := gjson.Get(nonStream, "choices.0.message.content").String(); got != `{"a":1}` {
+		t.Fatalf("non-stream content = %q", got)
+	}
+}
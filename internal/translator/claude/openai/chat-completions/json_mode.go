@@ -0,0 +1,109 @@
+package chat_completions
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Claude has no native response_format, so JSON mode is emulated: the request gains a system
+// instruction and the response has any Markdown code fence around the JSON removed.
+
+// jsonModeRequested reports whether the OpenAI request asked for JSON output.
+func jsonModeRequested(rawJSON []byte) bool {
+	switch gjson.GetBytes(rawJSON, "response_format.type").String() {
+	case "json_object", "json_schema":
+		return true
+	}
+	return false
+}
+
+// jsonModeInstruction returns the system instruction that stands in for response_format.
+func jsonModeInstruction(rawJSON []byte) string {
+	format := gjson.GetBytes(rawJSON, "response_format")
+	instruction := "Respond with a single valid JSON value and nothing else: no prose, no Markdown, no code fences."
+	if format.Get("type").String() == "json_schema" {
+		if schema := format.Get("json_schema.schema"); schema.Exists() {
+			instruction += " The JSON must validate against this JSON Schema:\n" + schema.Raw
+		}
+	}
+	return instruction
+}
+
+// stripJSONFence removes a ```json fence around text when the fenced content is valid JSON.
+// Anything else is returned unchanged.
+func stripJSONFence(text string) string {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "```") || !strings.HasSuffix(trimmed, "```") || len(trimmed) < 6 {
+		return text
+	}
+	_, body, ok := strings.Cut(trimmed, "\n")
+	if !ok {
+		return text
+	}
+	body = strings.TrimSpace(strings.TrimSuffix(body, "```"))
+	if !gjson.Valid(body) {
+		return text
+	}
+	return body
+}
+
+// jsonFenceStripper removes a leading ```lang line and a trailing ``` from streamed text
+// while still forwarding deltas as they arrive. Only text that could be part of a fence
+// is held back.
+type jsonFenceStripper struct {
+	decided bool
+	fenced  bool
+	lead    strings.Builder
+	tail    string
+}
+
+// feed returns the portion of text that can be sent now.
+func (s *jsonFenceStripper) feed(text string) string {
+	if !s.decided {
+		s.lead.WriteString(text)
+		head := strings.TrimLeft(s.lead.String(), " \t\r\n")
+		if len(head) < 3 && strings.HasPrefix("```", head) {
+			return ""
+		}
+		if !strings.HasPrefix(head, "```") {
+			s.decided = true
+			return s.lead.String()
+		}
+		_, rest, ok := strings.Cut(head, "\n")
+		if !ok {
+			return ""
+		}
+		s.decided, s.fenced = true, true
+		text = rest
+	}
+	if !s.fenced {
+		return text
+	}
+	combined := s.tail + text
+	s.tail = ""
+	if cut := strings.LastIndex(combined, "\n"); cut >= 0 {
+		if last := strings.TrimSpace(combined[cut:]); strings.HasPrefix("```", last) {
+			s.tail = combined[cut:]
+			return combined[:cut]
+		}
+	} else if strings.HasPrefix("```", strings.TrimSpace(combined)) {
+		s.tail = combined
+		return ""
+	}
+	return combined
+}
+
+// flush returns held-back text that turned out not to be a closing fence.
+func (s *jsonFenceStripper) flush() string {
+	if !s.decided {
+		s.decided = true
+		return s.lead.String()
+	}
+	tail := s.tail
+	s.tail = ""
+	if strings.TrimSpace(tail) == "```" {
+		return ""
+	}
+	return tail
+}
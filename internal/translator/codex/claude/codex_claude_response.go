@@ -120,6 +120,9 @@ func ConvertCodexResponseToClaude(_ context.Context, _ string, originalRequestRa
 		} else {
 			template, _ = sjson.Set(template, "delta.stop_reason", "end_turn")
 		}
+		if stopSequence := rootResult.Get("response.stop_sequence"); stopSequence.String() != "" {
+			template, _ = sjson.Set(template, "delta.stop_sequence", stopSequence.String())
+		}
 		inputTokens, outputTokens, cachedTokens := extractResponsesUsage(rootResult.Get("response.usage"))
 		template, _ = sjson.Set(template, "usage.input_tokens", inputTokens)
 		template, _ = sjson.Set(template, "usage.output_tokens", outputTokens)
@@ -367,5 +370,5 @@ func buildReverseMapFromClaudeOriginalShortToOriginal(original []byte) map[strin
 }
 
 func ClaudeTokenCount(ctx context.Context, count int64) string {
-	return fmt.Sprintf(`{"input_tokens":%d}`, count)
+	return fmt.Sprintf(`{"input_tokens":%d,"estimated":true}`, count)
 }
@@ -8,6 +8,7 @@ package chat_completions
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/tidwall/gjson"
@@ -332,3 +333,9 @@ func buildReverseMapFromOriginalOpenAI(original []byte) map[string]string {
 	}
 	return rev
 }
+
+// OpenAITokenCount reports a count made with a local tokenizer, as this upstream has no
+// token counting API.
+func OpenAITokenCount(ctx context.Context, count int64) string {
+	return fmt.Sprintf(`{"input_tokens":%d,"estimated":true}`, count)
+}
@@ -12,8 +12,9 @@ func init() {
 		Codex,
 		ConvertOpenAIRequestToCodex,
 		interfaces.TranslateResponse{
-			Stream:    ConvertCodexResponseToOpenAI,
-			NonStream: ConvertCodexResponseToOpenAINonStream,
+			Stream:     ConvertCodexResponseToOpenAI,
+			NonStream:  ConvertCodexResponseToOpenAINonStream,
+			TokenCount: OpenAITokenCount,
 		},
 	)
 }
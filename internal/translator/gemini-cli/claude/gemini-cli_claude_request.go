@@ -89,6 +89,15 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 						part, _ = sjson.Set(part, "text", contentResult.Get("text").String())
 						contentJSON, _ = sjson.SetRaw(contentJSON, "parts.-1", part)
 
+					case "image":
+						source := contentResult.Get("source")
+						if source.Get("type").String() == "base64" && source.Get("data").String() != "" {
+							part := `{"inlineData":{"mime_type":"","data":""}}`
+							part, _ = sjson.Set(part, "inlineData.mime_type", source.Get("media_type").String())
+							part, _ = sjson.Set(part, "inlineData.data", source.Get("data").String())
+							contentJSON, _ = sjson.SetRaw(contentJSON, "parts.-1", part)
+						}
+
 					case "tool_use":
 						functionName := contentResult.Get("name").String()
 						functionArgs := contentResult.Get("input").String()
@@ -179,6 +188,7 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 	}
 
 	outBytes := []byte(out)
+	outBytes = common.ApplyStopSequences(outBytes, gjson.GetBytes(rawJSON, "stop_sequences"), "request.generationConfig.stopSequences")
 	outBytes = common.AttachDefaultSafetySettings(outBytes, "request.safetySettings")
 
 	return outBytes
@@ -374,5 +374,5 @@ func ConvertGeminiCLIResponseToClaudeNonStream(_ context.Context, _ string, orig
 }
 
 func ClaudeTokenCount(ctx context.Context, count int64) string {
-	return fmt.Sprintf(`{"input_tokens":%d}`, count)
+	return fmt.Sprintf(`{"input_tokens":%d,"estimated":false}`, count)
 }
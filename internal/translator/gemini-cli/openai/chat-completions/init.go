@@ -3,6 +3,7 @@ package chat_completions
 import (
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	geminichat "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/chat-completions"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translator"
 )
 
@@ -12,8 +13,9 @@ func init() {
 		GeminiCLI,
 		ConvertOpenAIRequestToGeminiCLI,
 		interfaces.TranslateResponse{
-			Stream:    ConvertCliResponseToOpenAI,
-			NonStream: ConvertCliResponseToOpenAINonStream,
+			Stream:     ConvertCliResponseToOpenAI,
+			NonStream:  ConvertCliResponseToOpenAINonStream,
+			TokenCount: geminichat.OpenAITokenCount,
 		},
 	)
 }
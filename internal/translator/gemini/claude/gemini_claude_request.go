@@ -82,6 +82,15 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 						part, _ = sjson.Set(part, "text", contentResult.Get("text").String())
 						contentJSON, _ = sjson.SetRaw(contentJSON, "parts.-1", part)
 
+					case "image":
+						source := contentResult.Get("source")
+						if source.Get("type").String() == "base64" && source.Get("data").String() != "" {
+							part := `{"inlineData":{"mime_type":"","data":""}}`
+							part, _ = sjson.Set(part, "inlineData.mime_type", source.Get("media_type").String())
+							part, _ = sjson.Set(part, "inlineData.data", source.Get("data").String())
+							contentJSON, _ = sjson.SetRaw(contentJSON, "parts.-1", part)
+						}
+
 					case "tool_use":
 						functionName := contentResult.Get("name").String()
 						functionArgs := contentResult.Get("input").String()
@@ -173,6 +182,7 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 	}
 
 	result := []byte(out)
+	result = common.ApplyStopSequences(result, gjson.GetBytes(rawJSON, "stop_sequences"), "generationConfig.stopSequences")
 	result = common.AttachDefaultSafetySettings(result, "safetySettings")
 
 	return result
@@ -380,5 +380,5 @@ func ConvertGeminiResponseToClaudeNonStream(_ context.Context, _ string, origina
 }
 
 func ClaudeTokenCount(ctx context.Context, count int64) string {
-	return fmt.Sprintf(`{"input_tokens":%d}`, count)
+	return fmt.Sprintf(`{"input_tokens":%d,"estimated":false}`, count)
 }
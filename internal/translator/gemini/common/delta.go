@@ -0,0 +1,29 @@
+package common
+
+import (
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// AppendOpenAITextDelta adds the text of a Gemini part to an OpenAI chat completion chunk,
+// as content or, for thought parts, as reasoning_content. Parts of the same kind are
+// concatenated. When a part follows text of the other kind, the chunk built so far is
+// returned as flushed and next starts a fresh delta, so interleaved reasoning and content
+// reach the client in the order Gemini produced them.
+func AppendOpenAITextDelta(template, text string, thought bool) (flushed, next string) {
+	field, other := "choices.0.delta.content", "choices.0.delta.reasoning_content"
+	if thought {
+		field, other = other, field
+	}
+	if gjson.Get(template, other).Type == gjson.String {
+		flushed, _ = sjson.Set(template, "choices.0.finish_reason", nil)
+		flushed, _ = sjson.Set(flushed, "choices.0.native_finish_reason", nil)
+		flushed, _ = sjson.Delete(flushed, "usage")
+		template, _ = sjson.Set(template, "choices.0.delta.content", nil)
+		template, _ = sjson.Set(template, "choices.0.delta.reasoning_content", nil)
+		template, _ = sjson.Set(template, "choices.0.delta.tool_calls", nil)
+		template, _ = sjson.Delete(template, "choices.0.delta.images")
+	}
+	next, _ = sjson.Set(template, field, gjson.Get(template, field).String()+text)
+	return flushed, next
+}
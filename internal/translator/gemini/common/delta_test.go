@@ -0,0 +1,38 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestAppendOpenAITextDeltaKeepsOrder(t *testing.T) {
+	template := `{"choices":[{"index":0,"delta":{"role":null,"content":null,"reasoning_content":null,"tool_calls":null},"finish_reason":"stop"}],"usage":{"total_tokens":3}}`
+	var chunks []string
+	for _, part := range []struct {
+		text    string
+		thought bool
+	}{{"think ", true}, {"more", true}, {"answer", false}, {"again", true}} {
+		var flushed string
+		flushed, template = AppendOpenAITextDelta(template, part.text, part.thought)
+		if flushed != "" {
+			chunks = append(chunks, flushed)
+		}
+	}
+	chunks = append(chunks, template)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d: %v", len(chunks), chunks)
+	}
+	if got := gjson.Get(chunks[0], "choices.0.delta.reasoning_content").String(); got != "think more" {
+		t.Fatalf("first chunk reasoning = %q", got)
+	}
+	if gjson.Get(chunks[0], "usage").Exists() || gjson.Get(chunks[0], "choices.0.finish_reason").Type != gjson.Null {
+		t.Fatalf("flushed chunks must not carry usage or finish_reason: %s", chunks[0])
+	}
+	if gjson.Get(chunks[1], "choices.0.delta.content").String() != "answer" || gjson.Get(chunks[1], "choices.0.delta.reasoning_content").Type != gjson.Null {
+		t.Fatalf("second chunk = %s", chunks[1])
+	}
+	if gjson.Get(chunks[2], "choices.0.delta.reasoning_content").String() != "again" || gjson.Get(chunks[2], "choices.0.finish_reason").String() != "stop" {
+		t.Fatalf("last chunk = %s", chunks[2])
+	}
+}
@@ -0,0 +1,148 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// geminiSchemaKeys are the JSON Schema keywords Gemini's responseSchema understands.
+var geminiSchemaKeys = map[string]struct{}{
+	"type": {}, "format": {}, "title": {}, "description": {}, "nullable": {}, "enum": {},
+	"items": {}, "minItems": {}, "maxItems": {}, "properties": {}, "required": {},
+	"minProperties": {}, "maxProperties": {}, "minLength": {}, "maxLength": {}, "pattern": {},
+	"minimum": {}, "maximum": {}, "anyOf": {}, "propertyOrdering": {}, "default": {}, "example": {},
+}
+
+// ignoredSchemaKeys carry no meaning for generation and are dropped silently.
+var ignoredSchemaKeys = map[string]struct{}{
+	"$schema": {}, "$id": {}, "$comment": {}, "additionalProperties": {}, "examples": {}, "strict": {},
+}
+
+// ApplyOpenAIResponseFormat maps an OpenAI response_format onto the Gemini generationConfig
+// at path: json_object becomes responseMimeType application/json, and json_schema also sets
+// responseSchema. Schemas that fail ConvertJSONSchema are left out; handlers reject them
+// before translation.
+func ApplyOpenAIResponseFormat(out, rawJSON []byte, path string) []byte {
+	format := gjson.GetBytes(rawJSON, "response_format")
+	switch format.Get("type").String() {
+	case "json_object":
+		out, _ = sjson.SetBytes(out, path+".responseMimeType", "application/json")
+	case "json_schema":
+		out, _ = sjson.SetBytes(out, path+".responseMimeType", "application/json")
+		if schema, err := ConvertJSONSchema(format.Get("json_schema.schema")); err == nil && schema != "" {
+			out, _ = sjson.SetRawBytes(out, path+".responseSchema", []byte(schema))
+		}
+	}
+	return out
+}
+
+// ConvertJSONSchema converts a JSON Schema into the subset accepted by Gemini's responseSchema.
+// It returns an error naming the first unsupported keyword and where it occurs.
+func ConvertJSONSchema(schema gjson.Result) (string, error) {
+	if !schema.Exists() {
+		return "", nil
+	}
+	return convertSchemaNode(schema, "schema")
+}
+
+func convertSchemaNode(node gjson.Result, at string) (string, error) {
+	if !node.IsObject() {
+		return "", fmt.Errorf("%s: expected a schema object", at)
+	}
+	out := `{}`
+	var errOut error
+	var keys []string
+	node.ForEach(func(key, _ gjson.Result) bool {
+		keys = append(keys, key.String())
+		return true
+	})
+	for _, key := range keys {
+		value := node.Get(gjson.Escape(key))
+		if _, ignored := ignoredSchemaKeys[key]; ignored {
+			continue
+		}
+		switch key {
+		case "const":
+			out, _ = sjson.SetRaw(out, "enum", "["+value.Raw+"]")
+			continue
+		case "type":
+			converted, err := convertSchemaType(value, at)
+			if err != nil {
+				return "", err
+			}
+			for path, raw := range converted {
+				out, _ = sjson.SetRaw(out, path, raw)
+			}
+			continue
+		case "properties":
+			props := `{}`
+			value.ForEach(func(name, prop gjson.Result) bool {
+				converted, err := convertSchemaNode(prop, at+".properties."+name.String())
+				if err != nil {
+					errOut = err
+					return false
+				}
+				props, _ = sjson.SetRaw(props, gjson.Escape(name.String()), converted)
+				return true
+			})
+			if errOut != nil {
+				return "", errOut
+			}
+			out, _ = sjson.SetRaw(out, "properties", props)
+			continue
+		case "items":
+			converted, err := convertSchemaNode(value, at+".items")
+			if err != nil {
+				return "", err
+			}
+			out, _ = sjson.SetRaw(out, "items", converted)
+			continue
+		case "anyOf":
+			variants := make([]string, 0, len(value.Array()))
+			for i, variant := range value.Array() {
+				converted, err := convertSchemaNode(variant, fmt.Sprintf("%s.anyOf[%d]", at, i))
+				if err != nil {
+					return "", err
+				}
+				variants = append(variants, converted)
+			}
+			out, _ = sjson.SetRaw(out, "anyOf", "["+strings.Join(variants, ",")+"]")
+			continue
+		}
+		if _, ok := geminiSchemaKeys[key]; !ok {
+			return "", fmt.Errorf("%s: JSON Schema keyword %q is not supported by Gemini", at, key)
+		}
+		out, _ = sjson.SetRaw(out, gjson.Escape(key), value.Raw)
+	}
+	return out, nil
+}
+
+// convertSchemaType upper-cases the type name and turns ["x","null"] into a nullable type.
+func convertSchemaType(value gjson.Result, at string) (map[string]string, error) {
+	if value.Type == gjson.String {
+		return map[string]string{"type": fmt.Sprintf("%q", strings.ToUpper(value.String()))}, nil
+	}
+	if !value.IsArray() {
+		return nil, fmt.Errorf("%s: type must be a string or an array of strings", at)
+	}
+	var types []string
+	nullable := false
+	for _, t := range value.Array() {
+		if strings.EqualFold(t.String(), "null") {
+			nullable = true
+			continue
+		}
+		types = append(types, strings.ToUpper(t.String()))
+	}
+	if len(types) != 1 {
+		return nil, fmt.Errorf("%s: a type list may only combine one type with \"null\" for Gemini", at)
+	}
+	out := map[string]string{"type": fmt.Sprintf("%q", types[0])}
+	if nullable {
+		out["nullable"] = "true"
+	}
+	return out, nil
+}
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyOpenAIResponseFormatConvertsSchema(t *testing.T) {
+	raw := []byte(`{"response_format":{"type":"json_schema","json_schema":{"name":"answer","strict":true,"schema":{"type":"object","additionalProperties":false,"properties":{"city":{"type":"string"},"score":{"type":["number","null"]},"kind":{"const":"a"}},"required":["city"]}}}}`)
+	out := ApplyOpenAIResponseFormat([]byte(`{}`), raw, "request.generationConfig")
+
+	config := gjson.GetBytes(out, "request.generationConfig")
+	if config.Get("responseMimeType").String() != "application/json" {
+		t.Fatalf("responseMimeType missing: %s", out)
+	}
+	schema := config.Get("responseSchema")
+	if schema.Get("type").String() != "OBJECT" || schema.Get("additionalProperties").Exists() {
+		t.Fatalf("unexpected schema: %s", schema.Raw)
+	}
+	if schema.Get("properties.score.type").String() != "NUMBER" || !schema.Get("properties.score.nullable").Bool() {
+		t.Fatalf("nullable type not converted: %s", schema.Raw)
+	}
+	if schema.Get("properties.kind.enum.0").String() != "a" {
+		t.Fatalf("const not converted to enum: %s", schema.Raw)
+	}
+
+	jsonObject := ApplyOpenAIResponseFormat([]byte(`{}`), []byte(`{"response_format":{"type":"json_object"}}`), "generationConfig")
+	if gjson.GetBytes(jsonObject, "generationConfig.responseMimeType").String() != "application/json" || gjson.GetBytes(jsonObject, "generationConfig.responseSchema").Exists() {
+		t.Fatalf("json_object: %s", jsonObject)
+	}
+}
+
+func TestConvertJSONSchemaRejectsUnsupportedKeywords(t *testing.T) {
+	_, err := ConvertJSONSchema(gjson.Parse(`{"type":"object","properties":{"node":{"$ref":"#/$defs/node"}}}`))
+	if err == nil || !strings.Contains(err.Error(), `"$ref"`) || !strings.Contains(err.Error(), "properties.node") {
+		t.Fatalf("expected an error naming $ref and its location, got %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+package common
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ApplyStopSequences writes an OpenAI stop or Claude stop_sequences value to the Gemini
+// stopSequences field at path, trimmed to the number Gemini accepts.
+func ApplyStopSequences(out []byte, value gjson.Result, path string) []byte {
+	stops := util.LimitStopSequences(util.StopSequences(value), util.GeminiMaxStopSequences, "gemini")
+	if len(stops) == 0 {
+		return out
+	}
+	out, _ = sjson.SetBytes(out, path, stops)
+	return out
+}
@@ -0,0 +1,105 @@
+package common
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ApplyOpenAIToolChoice maps an OpenAI tool_choice onto Gemini's functionCallingConfig.
+// The caller must provide the target toolConfig path (e.g. "toolConfig" or "request.toolConfig").
+//
+//	"none"     -> NONE
+//	"auto"     -> AUTO
+//	"required" -> ANY
+//	{"type":"function","function":{"name":"x"}} -> ANY restricted to x
+func ApplyOpenAIToolChoice(out, rawJSON []byte, path string) []byte {
+	choice := gjson.GetBytes(rawJSON, "tool_choice")
+	if !choice.Exists() {
+		return out
+	}
+	mode := ""
+	var allowed []string
+	switch {
+	case choice.Type == gjson.String:
+		switch strings.ToLower(strings.TrimSpace(choice.String())) {
+		case "none":
+			mode = "NONE"
+		case "auto":
+			mode = "AUTO"
+		case "required", "any":
+			mode = "ANY"
+		}
+	case choice.IsObject():
+		name := choice.Get("function.name").String()
+		if name == "" {
+			name = choice.Get("name").String()
+		}
+		if name != "" {
+			mode = "ANY"
+			allowed = []string{name}
+		}
+	}
+	if mode == "" {
+		return out
+	}
+	out, _ = sjson.SetBytes(out, path+".functionCallingConfig.mode", mode)
+	if len(allowed) > 0 {
+		out, _ = sjson.SetBytes(out, path+".functionCallingConfig.allowedFunctionNames", allowed)
+	}
+	return out
+}
+
+// FunctionCallArgs converts OpenAI tool call arguments into a Gemini args object.
+// Empty arguments become {}; arguments that are not a JSON object are wrapped as {"params": ...}.
+func FunctionCallArgs(arguments string) []byte {
+	trimmed := strings.TrimSpace(arguments)
+	if trimmed == "" {
+		return []byte(`{}`)
+	}
+	if gjson.Valid(trimmed) && gjson.Parse(trimmed).IsObject() {
+		return []byte(trimmed)
+	}
+	wrapped, _ := sjson.SetBytes([]byte(`{}`), "params", arguments)
+	return wrapped
+}
+
+// ToolResultText flattens an OpenAI tool message content (string or content parts) into text.
+func ToolResultText(content gjson.Result) string {
+	switch {
+	case !content.Exists() || content.Type == gjson.Null:
+		return ""
+	case content.Type == gjson.String:
+		return content.String()
+	case content.IsArray():
+		var sb strings.Builder
+		for _, part := range content.Array() {
+			if part.Type == gjson.String {
+				sb.WriteString(part.String())
+			} else if text := part.Get("text"); text.Exists() {
+				sb.WriteString(text.String())
+			}
+		}
+		return sb.String()
+	default:
+		return content.Raw
+	}
+}
+
+// SetFunctionResponseResult stores a tool result at path, keeping JSON objects and arrays
+// structured and passing anything else through as a string.
+func SetFunctionResponseResult(node []byte, path, result string) []byte {
+	trimmed := strings.TrimSpace(result)
+	if trimmed == "" {
+		trimmed = "{}"
+	}
+	if gjson.Valid(trimmed) {
+		if parsed := gjson.Parse(trimmed); parsed.IsObject() || parsed.IsArray() {
+			node, _ = sjson.SetRawBytes(node, path, []byte(trimmed))
+			return node
+		}
+	}
+	node, _ = sjson.SetBytes(node, path, result)
+	return node
+}
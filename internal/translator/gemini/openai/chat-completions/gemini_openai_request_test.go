@@ -0,0 +1,51 @@
+package chat_completions
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertOpenAIRequestToGemini_ToolCallsAndResults(t *testing.T) {
+	input := []byte(`{
+		"messages":[
+			{"role":"user","content":"weather?"},
+			{"role":"assistant","tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}},
+				{"id":"call_2","type":"function","function":{"name":"clock","arguments":""}}
+			]},
+			{"role":"tool","tool_call_id":"call_1","content":"{\"temp\":21}"},
+			{"role":"tool","tool_call_id":"call_2","content":"noon"}
+		],
+		"tools":[{"type":"function","function":{"name":"weather","parameters":{"type":"object"}}},{"type":"function","function":{"name":"clock"}}],
+		"tool_choice":{"type":"function","function":{"name":"weather"}}
+	}`)
+
+	out := gjson.ParseBytes(ConvertOpenAIRequestToGemini("gemini-2.5-pro", input, false))
+
+	calls := out.Get("contents.1.parts").Array()
+	if len(calls) != 2 || calls[0].Get("functionCall.args.city").String() != "Paris" || calls[1].Get("functionCall.args").Raw != "{}" {
+		t.Fatalf("unexpected functionCall parts: %s", out.Get("contents.1").Raw)
+	}
+	responses := out.Get("contents.2.parts").Array()
+	if len(responses) != 2 {
+		t.Fatalf("expected both results in one turn: %s", out.Get("contents.2").Raw)
+	}
+	if responses[0].Get("functionResponse.response.result.temp").Int() != 21 || responses[1].Get("functionResponse.response.result").String() != "noon" {
+		t.Fatalf("unexpected functionResponse parts: %s", out.Get("contents.2").Raw)
+	}
+	if out.Get("toolConfig.functionCallingConfig.mode").String() != "ANY" || out.Get("toolConfig.functionCallingConfig.allowedFunctionNames.0").String() != "weather" {
+		t.Fatalf("unexpected toolConfig: %s", out.Get("toolConfig").Raw)
+	}
+}
+
+func TestConvertOpenAIRequestToGeminiStopSequences(t *testing.T) {
+	out := ConvertOpenAIRequestToGemini("gemini-2.5-pro", []byte(`{"messages":[{"role":"user","content":"hi"}],"stop":["a","b","c","d","e","f"]}`), false)
+	if got := gjson.GetBytes(out, "generationConfig.stopSequences").Raw; got != `["a","b","c","d","e"]` {
+		t.Fatalf("stopSequences = %s", got)
+	}
+	out = ConvertOpenAIRequestToGemini("gemini-2.5-pro", []byte(`{"messages":[{"role":"user","content":"hi"}],"stop":"END"}`), false)
+	if got := gjson.GetBytes(out, "generationConfig.stopSequences").Raw; got != `["END"]` {
+		t.Fatalf("stopSequences = %s", got)
+	}
+}
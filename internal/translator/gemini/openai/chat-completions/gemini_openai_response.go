@@ -13,7 +13,8 @@ import (
 	"sync/atomic"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -162,12 +163,11 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 					}
 
 					if partTextResult.Exists() {
-						text := partTextResult.String()
 						// Handle text content, distinguishing between regular content and reasoning/thoughts.
-						if partResult.Get("thought").Bool() {
-							template, _ = sjson.Set(template, "choices.0.delta.reasoning_content", text)
-						} else {
-							template, _ = sjson.Set(template, "choices.0.delta.content", text)
+						var flushed string
+						flushed, template = common.AppendOpenAITextDelta(template, partTextResult.String(), partResult.Get("thought").Bool())
+						if flushed != "" {
+							responseStrings = append(responseStrings, flushed)
 						}
 						template, _ = sjson.Set(template, "choices.0.delta.role", "assistant")
 					} else if functionCallResult.Exists() {
@@ -394,3 +394,8 @@ func ConvertGeminiResponseToOpenAINonStream(_ context.Context, _ string, origina
 
 	return template
 }
+
+// OpenAITokenCount reports a count from the upstream's native token counting API.
+func OpenAITokenCount(ctx context.Context, count int64) string {
+	return fmt.Sprintf(`{"input_tokens":%d,"estimated":false}`, count)
+}
@@ -12,8 +12,9 @@ func init() {
 		Gemini,
 		ConvertOpenAIRequestToGemini,
 		interfaces.TranslateResponse{
-			Stream:    ConvertGeminiResponseToOpenAI,
-			NonStream: ConvertGeminiResponseToOpenAINonStream,
+			Stream:     ConvertGeminiResponseToOpenAI,
+			NonStream:  ConvertGeminiResponseToOpenAINonStream,
+			TokenCount: OpenAITokenCount,
 		},
 	)
 }
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -40,21 +41,10 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 	}
 
 	// Stop sequences -> stop
-	if stopSequences := root.Get("stop_sequences"); stopSequences.Exists() {
-		if stopSequences.IsArray() {
-			var stops []string
-			stopSequences.ForEach(func(_, value gjson.Result) bool {
-				stops = append(stops, value.String())
-				return true
-			})
-			if len(stops) > 0 {
-				if len(stops) == 1 {
-					out, _ = sjson.Set(out, "stop", stops[0])
-				} else {
-					out, _ = sjson.Set(out, "stop", stops)
-				}
-			}
-		}
+	if stops := util.LimitStopSequences(util.StopSequences(root.Get("stop_sequences")), util.OpenAIMaxStopSequences, "openai"); len(stops) == 1 {
+		out, _ = sjson.Set(out, "stop", stops[0])
+	} else if len(stops) > 1 {
+		out, _ = sjson.Set(out, "stop", stops)
 	}
 
 	// Stream
@@ -25,8 +25,6 @@ type ConvertOpenAIResponseToAnthropicParams struct {
 	MessageID string
 	Model     string
 	CreatedAt int64
-	// Content accumulator for streaming
-	ContentAccumulator strings.Builder
 	// Tool calls accumulator for streaming
 	ToolCallsAccumulator map[int]*ToolCallAccumulator
 	// Track if text content block has been started
@@ -53,11 +51,16 @@ type ConvertOpenAIResponseToAnthropicParams struct {
 	NextContentBlockIndex int
 }
 
-// ToolCallAccumulator holds the state for accumulating tool call data
+// ToolCallAccumulator holds the state of one streamed tool call. Arguments are forwarded as
+// they arrive; only those received before the tool's content block starts are held.
 type ToolCallAccumulator struct {
-	ID        string
-	Name      string
-	Arguments strings.Builder
+	ID      string
+	Name    string
+	Started bool
+	// Pending holds arguments received before the tool name opened the content block.
+	Pending strings.Builder
+	// Fixer carries the quote repair applied to the arguments across fragments.
+	Fixer util.JSONQuoteFixer
 }
 
 // ConvertOpenAIResponseToClaude converts OpenAI streaming response format to Anthropic API format.
@@ -78,7 +81,6 @@ func ConvertOpenAIResponseToClaude(_ context.Context, _ string, originalRequestR
 			MessageID:                   "",
 			Model:                       "",
 			CreatedAt:                   0,
-			ContentAccumulator:          strings.Builder{},
 			ToolCallsAccumulator:        nil,
 			TextContentBlockStarted:     false,
 			ThinkingContentBlockStarted: false,
@@ -185,9 +187,6 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 			contentDeltaJSON, _ = sjson.Set(contentDeltaJSON, "index", param.TextContentBlockIndex)
 			contentDeltaJSON, _ = sjson.Set(contentDeltaJSON, "delta.text", content.String())
 			results = append(results, "event: content_block_delta\ndata: "+contentDeltaJSON+"\n\n")
-
-			// Accumulate content
-			param.ContentAccumulator.WriteString(content.String())
 		}
 
 		// Handle tool calls
@@ -227,13 +226,21 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 						contentBlockStartJSON, _ = sjson.Set(contentBlockStartJSON, "content_block.id", accumulator.ID)
 						contentBlockStartJSON, _ = sjson.Set(contentBlockStartJSON, "content_block.name", accumulator.Name)
 						results = append(results, "event: content_block_start\ndata: "+contentBlockStartJSON+"\n\n")
+						accumulator.Started = true
+						if accumulator.Pending.Len() > 0 {
+							appendInputJSONDelta(&results, blockIndex, accumulator.Fixer.Write(accumulator.Pending.String()))
+							accumulator.Pending.Reset()
+						}
 					}
 
 					// Handle function arguments
 					if args := function.Get("arguments"); args.Exists() {
-						argsText := args.String()
-						if argsText != "" {
-							accumulator.Arguments.WriteString(argsText)
+						if argsText := args.String(); argsText != "" {
+							if accumulator.Started {
+								appendInputJSONDelta(&results, blockIndex, accumulator.Fixer.Write(argsText))
+							} else {
+								accumulator.Pending.WriteString(argsText)
+							}
 						}
 					}
 				}
@@ -263,21 +270,7 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 		// Send content_block_stop for any tool calls
 		if !param.ContentBlocksStopped {
 			for index := range param.ToolCallsAccumulator {
-				accumulator := param.ToolCallsAccumulator[index]
-				blockIndex := param.toolContentBlockIndex(index)
-
-				// Send complete input_json_delta with all accumulated arguments
-				if accumulator.Arguments.Len() > 0 {
-					inputDeltaJSON := `{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":""}}`
-					inputDeltaJSON, _ = sjson.Set(inputDeltaJSON, "index", blockIndex)
-					inputDeltaJSON, _ = sjson.Set(inputDeltaJSON, "delta.partial_json", util.FixJSON(accumulator.Arguments.String()))
-					results = append(results, "event: content_block_delta\ndata: "+inputDeltaJSON+"\n\n")
-				}
-
-				contentBlockStopJSON := `{"type":"content_block_stop","index":0}`
-				contentBlockStopJSON, _ = sjson.Set(contentBlockStopJSON, "index", blockIndex)
-				results = append(results, "event: content_block_stop\ndata: "+contentBlockStopJSON+"\n\n")
-				delete(param.ToolCallBlockIndexes, index)
+				stopToolCallBlock(param, index, &results)
 			}
 			param.ContentBlocksStopped = true
 		}
@@ -327,20 +320,7 @@ func convertOpenAIDoneToAnthropic(param *ConvertOpenAIResponseToAnthropicParams)
 
 	if !param.ContentBlocksStopped {
 		for index := range param.ToolCallsAccumulator {
-			accumulator := param.ToolCallsAccumulator[index]
-			blockIndex := param.toolContentBlockIndex(index)
-
-			if accumulator.Arguments.Len() > 0 {
-				inputDeltaJSON := `{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":""}}`
-				inputDeltaJSON, _ = sjson.Set(inputDeltaJSON, "index", blockIndex)
-				inputDeltaJSON, _ = sjson.Set(inputDeltaJSON, "delta.partial_json", util.FixJSON(accumulator.Arguments.String()))
-				results = append(results, "event: content_block_delta\ndata: "+inputDeltaJSON+"\n\n")
-			}
-
-			contentBlockStopJSON := `{"type":"content_block_stop","index":0}`
-			contentBlockStopJSON, _ = sjson.Set(contentBlockStopJSON, "index", blockIndex)
-			results = append(results, "event: content_block_stop\ndata: "+contentBlockStopJSON+"\n\n")
-			delete(param.ToolCallBlockIndexes, index)
+			stopToolCallBlock(param, index, &results)
 		}
 		param.ContentBlocksStopped = true
 	}
@@ -509,6 +489,31 @@ func emitMessageStopIfNeeded(param *ConvertOpenAIResponseToAnthropicParams, resu
 	param.MessageStopSent = true
 }
 
+// appendInputJSONDelta forwards a fragment of tool call arguments.
+func appendInputJSONDelta(results *[]string, blockIndex int, partialJSON string) {
+	if partialJSON == "" {
+		return
+	}
+	inputDeltaJSON := `{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":""}}`
+	inputDeltaJSON, _ = sjson.Set(inputDeltaJSON, "index", blockIndex)
+	inputDeltaJSON, _ = sjson.Set(inputDeltaJSON, "delta.partial_json", partialJSON)
+	*results = append(*results, "event: content_block_delta\ndata: "+inputDeltaJSON+"\n\n")
+}
+
+// stopToolCallBlock forwards whatever is left of a tool call's arguments and closes its block.
+func stopToolCallBlock(param *ConvertOpenAIResponseToAnthropicParams, index int, results *[]string) {
+	accumulator := param.ToolCallsAccumulator[index]
+	blockIndex := param.toolContentBlockIndex(index)
+	rest := accumulator.Fixer.Write(accumulator.Pending.String()) + accumulator.Fixer.Close()
+	accumulator.Pending.Reset()
+	appendInputJSONDelta(results, blockIndex, rest)
+
+	contentBlockStopJSON := `{"type":"content_block_stop","index":0}`
+	contentBlockStopJSON, _ = sjson.Set(contentBlockStopJSON, "index", blockIndex)
+	*results = append(*results, "event: content_block_stop\ndata: "+contentBlockStopJSON+"\n\n")
+	delete(param.ToolCallBlockIndexes, index)
+}
+
 func stopTextContentBlock(param *ConvertOpenAIResponseToAnthropicParams, results *[]string) {
 	if !param.TextContentBlockStarted {
 		return
@@ -689,7 +694,7 @@ func ConvertOpenAIResponseToClaudeNonStream(_ context.Context, _ string, origina
 }
 
 func ClaudeTokenCount(ctx context.Context, count int64) string {
-	return fmt.Sprintf(`{"input_tokens":%d}`, count)
+	return fmt.Sprintf(`{"input_tokens":%d,"estimated":true}`, count)
 }
 
 func extractOpenAIUsage(usage gjson.Result) (int64, int64, int64) {
@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -66,15 +67,8 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 		}
 
 		// Stop sequences
-		if stopSequences := genConfig.Get("stopSequences"); stopSequences.Exists() && stopSequences.IsArray() {
-			var stops []string
-			stopSequences.ForEach(func(_, value gjson.Result) bool {
-				stops = append(stops, value.String())
-				return true
-			})
-			if len(stops) > 0 {
-				out, _ = sjson.Set(out, "stop", stops)
-			}
+		if stops := util.LimitStopSequences(util.StopSequences(genConfig.Get("stopSequences")), util.OpenAIMaxStopSequences, "openai"); len(stops) > 0 {
+			out, _ = sjson.Set(out, "stop", stops)
 		}
 
 		// Candidate count (OpenAI 'n' parameter)
@@ -20,8 +20,6 @@ import (
 type ConvertOpenAIResponseToGeminiParams struct {
 	// Tool calls accumulator for streaming
 	ToolCallsAccumulator map[int]*ToolCallAccumulator
-	// Content accumulator for streaming
-	ContentAccumulator strings.Builder
 	// Track if this is the first chunk
 	IsFirstChunk bool
 }
@@ -49,7 +47,6 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 	if *param == nil {
 		*param = &ConvertOpenAIResponseToGeminiParams{
 			ToolCallsAccumulator: nil,
-			ContentAccumulator:   strings.Builder{},
 			IsFirstChunk:         false,
 		}
 	}
@@ -138,7 +135,6 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 			// Handle content delta
 			if content := delta.Get("content"); content.Exists() && content.String() != "" {
 				contentText := content.String()
-				(*param).(*ConvertOpenAIResponseToGeminiParams).ContentAccumulator.WriteString(contentText)
 
 				// Create text part for this delta
 				contentTemplate := baseTemplate
@@ -12,8 +12,9 @@ func init() {
 		OpenAI,
 		ConvertOpenAIRequestToOpenAI,
 		interfaces.TranslateResponse{
-			Stream:    ConvertOpenAIResponseToOpenAI,
-			NonStream: ConvertOpenAIResponseToOpenAINonStream,
+			Stream:     ConvertOpenAIResponseToOpenAI,
+			NonStream:  ConvertOpenAIResponseToOpenAINonStream,
+			TokenCount: OpenAITokenCount,
 		},
 	)
 }
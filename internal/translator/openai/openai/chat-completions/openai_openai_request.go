@@ -4,6 +4,9 @@ package chat_completions
 
 import (
 	"bytes"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
 
@@ -27,5 +30,10 @@ func ConvertOpenAIRequestToOpenAI(modelName string, inputRawJSON []byte, _ bool)
 		// handling mechanism would be needed.
 		return bytes.Clone(inputRawJSON)
 	}
+	// OpenAI rejects more than four stop sequences; trim rather than fail the request.
+	if stop := gjson.GetBytes(updatedJSON, "stop"); stop.IsArray() && len(stop.Array()) > util.OpenAIMaxStopSequences {
+		stops := util.LimitStopSequences(util.StopSequences(stop), util.OpenAIMaxStopSequences, "openai")
+		updatedJSON, _ = sjson.SetBytes(updatedJSON, "stop", stops)
+	}
 	return updatedJSON
 }
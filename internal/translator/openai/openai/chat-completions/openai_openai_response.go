@@ -8,6 +8,7 @@ package chat_completions
 import (
 	"bytes"
 	"context"
+	"fmt"
 )
 
 // ConvertOpenAIResponseToOpenAI translates a single chunk of a streaming response from the
@@ -50,3 +51,9 @@ func ConvertOpenAIResponseToOpenAI(_ context.Context, _ string, originalRequestR
 func ConvertOpenAIResponseToOpenAINonStream(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) string {
 	return string(rawJSON)
 }
+
+// OpenAITokenCount reports a count made with a local tokenizer, as this upstream has no
+// token counting API.
+func OpenAITokenCount(ctx context.Context, count int64) string {
+	return fmt.Sprintf(`{"input_tokens":%d,"estimated":true}`, count)
+}
@@ -0,0 +1,109 @@
+package usage
+
+import "time"
+
+// AccountSnapshot summarises the requests one upstream credential served, so that an account
+// nearing its provider-side quota shows before the provider starts refusing it.
+type AccountSnapshot struct {
+	// Provider is the provider of the account's last request.
+	Provider      string    `json:"provider,omitempty"`
+	TotalRequests int64     `json:"total_requests"`
+	FailureCount  int64     `json:"failure_count"`
+	TotalTokens   int64     `json:"total_tokens"`
+	LastUsedAt    time.Time `json:"last_used_at,omitempty"`
+	// Last24Hours and Today count the requests of the rolling day and of the local calendar
+	// day, the periods provider quotas are usually measured over.
+	Last24Hours UsageBucket `json:"last_24_hours"`
+	Today       UsageBucket `json:"today"`
+	// Models breaks the account's requests down by model.
+	Models map[string]AccountModelSnapshot `json:"models,omitempty"`
+}
+
+// AccountModelSnapshot counts the requests of one model on an account.
+type AccountModelSnapshot struct {
+	TotalRequests int64 `json:"total_requests"`
+	FailureCount  int64 `json:"failure_count"`
+	TotalTokens   int64 `json:"total_tokens"`
+}
+
+// accountStats aggregates the requests of one credential, keyed by its auth index. Accounts
+// are bounded by the configured credentials and models by the model cap, so neither is capped
+// here.
+type accountStats struct {
+	provider string
+	requests int64
+	failures int64
+	tokens   int64
+	lastUsed time.Time
+	models   map[string]*labelStats
+	hourly   rollingWindow
+	today    rollingWindow
+}
+
+// addAccount counts detail towards the credential that served it, under the model it is
+// stored at.
+func (a *statsAggregate) addAccount(p placement, detail RequestDetail, tokens int64) {
+	if detail.AuthIndex == "" {
+		return
+	}
+	if a.accounts == nil {
+		a.accounts = make(map[string]*accountStats)
+	}
+	stats := a.accounts[detail.AuthIndex]
+	if stats == nil {
+		stats = &accountStats{
+			models: make(map[string]*labelStats),
+			hourly: newRollingWindow(hourlyWindowBuckets, false),
+			today:  newRollingWindow(1, true),
+		}
+		a.accounts[detail.AuthIndex] = stats
+	}
+	stats.requests++
+	stats.tokens += tokens
+	if detail.Failed {
+		stats.failures++
+	}
+	if !detail.Timestamp.Before(stats.lastUsed) {
+		stats.lastUsed = detail.Timestamp
+		if detail.Provider != "" {
+			stats.provider = detail.Provider
+		}
+	}
+	model := stats.models[p.modelName]
+	if model == nil {
+		model = &labelStats{}
+		stats.models[p.modelName] = model
+	}
+	model.requests++
+	model.tokens += tokens
+	if detail.Failed {
+		model.failures++
+	}
+	stats.hourly.add(p.now, detail.Timestamp, detail.Failed, tokens)
+	stats.today.add(p.now, detail.Timestamp, detail.Failed, tokens)
+}
+
+// accountsSnapshot copies the account aggregates as of now.
+func (a *statsAggregate) accountsSnapshot(now time.Time) map[string]AccountSnapshot {
+	if len(a.accounts) == 0 {
+		return nil
+	}
+	out := make(map[string]AccountSnapshot, len(a.accounts))
+	for authIndex, stats := range a.accounts {
+		snapshot := AccountSnapshot{
+			Provider:      stats.provider,
+			TotalRequests: stats.requests,
+			FailureCount:  stats.failures,
+			TotalTokens:   stats.tokens,
+			LastUsedAt:    stats.lastUsed,
+			Models:        make(map[string]AccountModelSnapshot, len(stats.models)),
+		}
+		_, snapshot.Last24Hours = stats.hourly.snapshot(now)
+		_, snapshot.Today = stats.today.snapshot(now)
+		for modelName, model := range stats.models {
+			snapshot.Models[modelName] = AccountModelSnapshot{TotalRequests: model.requests, FailureCount: model.failures, TotalTokens: model.tokens}
+		}
+		out[authIndex] = snapshot
+	}
+	return out
+}
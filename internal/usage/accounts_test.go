@@ -0,0 +1,47 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestSnapshotBreaksUsageDownByAccount(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	now := time.Now()
+	stats := NewRequestStatistics()
+	record := func(authIndex, model string, age time.Duration, tokens int64, failed bool) {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey: "key", Provider: "codex", AuthIndex: authIndex, Model: model, RequestedAt: now.Add(-age),
+			Failed: failed, Detail: coreusage.Detail{TotalTokens: tokens},
+		})
+	}
+	record("1", "gpt-5", 48*time.Hour, 100, false)
+	record("1", "gpt-5", time.Minute, 10, false)
+	record("1", "gpt-5-mini", time.Minute, 5, true)
+	record("2", "gpt-5", time.Minute, 1, false)
+	record("", "gpt-5", time.Minute, 1000, false)
+
+	accounts := stats.Snapshot().Accounts
+	if len(accounts) != 2 {
+		t.Fatalf("accounts = %v, want the two auth indexes only", accounts)
+	}
+	first := accounts["1"]
+	if first.Provider != "codex" || first.TotalRequests != 3 || first.FailureCount != 1 || first.TotalTokens != 115 {
+		t.Fatalf("account 1 = %+v, want 3 codex requests, 1 failed, 115 tokens", first)
+	}
+	if first.Last24Hours.Requests != 2 || first.Last24Hours.Tokens != 15 {
+		t.Fatalf("account 1 last 24 hours = %+v, want the 2 recent requests", first.Last24Hours)
+	}
+	if model := first.Models["gpt-5"]; model.TotalRequests != 2 || model.TotalTokens != 110 {
+		t.Fatalf("account 1 gpt-5 = %+v, want 2 requests and 110 tokens", model)
+	}
+	if summary := stats.AuthUsage()["1"]; summary.Requests != 3 || summary.Last24hRequests != 2 || summary.Last24hTokens != 15 {
+		t.Fatalf("AuthUsage of account 1 = %+v", summary)
+	}
+}
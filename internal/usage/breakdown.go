@@ -0,0 +1,142 @@
+package usage
+
+import (
+	"sort"
+	"time"
+)
+
+// ModelUsage summarises one model across the API keys that used it.
+type ModelUsage struct {
+	Model         string `json:"model"`
+	TotalRequests int64  `json:"total_requests"`
+	FailureCount  int64  `json:"failure_count"`
+	TotalTokens   int64  `json:"total_tokens"`
+	// APIKeys is how many API keys used the model.
+	APIKeys int `json:"api_keys"`
+	// EstimatedCost is set when the snapshot carries estimated costs.
+	EstimatedCost float64 `json:"estimated_cost_usd,omitempty"`
+	// Latency and FirstToken are the model's latency and time to first token histograms,
+	// with their percentiles.
+	Latency    *LatencyHistogram `json:"latency,omitempty"`
+	FirstToken *LatencyHistogram `json:"first_token,omitempty"`
+}
+
+// KeyUsage summarises one API key, with its models.
+type KeyUsage struct {
+	APIKey         string       `json:"api_key"`
+	TotalRequests  int64        `json:"total_requests"`
+	FailureCount   int64        `json:"failure_count"`
+	TotalTokens    int64        `json:"total_tokens"`
+	DeniedRequests int64        `json:"denied_requests,omitempty"`
+	EstimatedCost  float64      `json:"estimated_cost_usd,omitempty"`
+	Models         []ModelUsage `json:"models"`
+}
+
+// ForAPIKey returns the part of the snapshot that belongs to apiKey: only its entry in APIs,
+// with the totals taken from it and the per-period, label and account aggregates rebuilt
+// from its details. Nothing about other keys is left, not even how many there are.
+func (s StatisticsSnapshot) ForAPIKey(apiKey string) StatisticsSnapshot {
+	agg := newStatsAggregate()
+	api, ok := s.APIs[apiKey]
+	if ok {
+		now := time.Now()
+		for modelName, model := range api.Models {
+			for _, detail := range model.Details {
+				agg.addPlaced(placement{apiName: apiKey, modelName: modelName, now: now}, apiKey, modelName, detail)
+			}
+		}
+	}
+	result := agg.snapshot()
+	result.APIs = make(map[string]APISnapshot, 1)
+	if !ok {
+		return result
+	}
+	result.APIs[apiKey] = api
+	// Details may have been archived; the key's own totals still cover them.
+	result.TotalRequests, result.TotalTokens, result.FailureCount = api.TotalRequests, api.TotalTokens, 0
+	for _, model := range api.Models {
+		result.FailureCount += model.FailureCount
+	}
+	result.SuccessCount = result.TotalRequests - result.FailureCount
+	return result
+}
+
+// ModelBreakdown returns the models of the snapshot summed over API keys, busiest first.
+func (s StatisticsSnapshot) ModelBreakdown() []ModelUsage {
+	byModel := make(map[string]*ModelUsage)
+	latencies := make(map[string]*LatencyHistogram)
+	firstTokens := make(map[string]*LatencyHistogram)
+	for _, api := range s.APIs {
+		for modelName, model := range api.Models {
+			row := byModel[modelName]
+			if row == nil {
+				row = &ModelUsage{Model: modelName}
+				byModel[modelName] = row
+			}
+			row.TotalRequests += model.TotalRequests
+			row.FailureCount += model.FailureCount
+			row.TotalTokens += model.TotalTokens
+			row.EstimatedCost += model.EstimatedCost
+			row.APIKeys++
+			mergeHistogram(latencies, modelName, model.Latency)
+			mergeHistogram(firstTokens, modelName, model.FirstToken)
+		}
+	}
+	out := make([]ModelUsage, 0, len(byModel))
+	for modelName, row := range byModel {
+		if histogram := latencies[modelName]; histogram != nil {
+			row.Latency = histogramSnapshot(*histogram)
+		}
+		if histogram := firstTokens[modelName]; histogram != nil {
+			row.FirstToken = histogramSnapshot(*histogram)
+		}
+		out = append(out, *row)
+	}
+	sortModelUsage(out)
+	return out
+}
+
+// KeyBreakdown returns the API keys of the snapshot with their models, busiest first. Keys
+// whose every request was denied are included.
+func (s StatisticsSnapshot) KeyBreakdown() []KeyUsage {
+	out := make([]KeyUsage, 0, len(s.APIs))
+	for apiKey, api := range s.APIs {
+		row := KeyUsage{
+			APIKey:         apiKey,
+			TotalRequests:  api.TotalRequests,
+			TotalTokens:    api.TotalTokens,
+			DeniedRequests: api.DeniedRequests,
+			EstimatedCost:  api.EstimatedCost,
+			Models:         make([]ModelUsage, 0, len(api.Models)),
+		}
+		for modelName, model := range api.Models {
+			row.FailureCount += model.FailureCount
+			row.Models = append(row.Models, ModelUsage{
+				Model:         modelName,
+				TotalRequests: model.TotalRequests,
+				FailureCount:  model.FailureCount,
+				TotalTokens:   model.TotalTokens,
+				APIKeys:       1,
+				EstimatedCost: model.EstimatedCost,
+			})
+		}
+		sortModelUsage(row.Models)
+		out = append(out, row)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TotalRequests != out[j].TotalRequests {
+			return out[i].TotalRequests > out[j].TotalRequests
+		}
+		return out[i].APIKey < out[j].APIKey
+	})
+	return out
+}
+
+func sortModelUsage(rows []ModelUsage) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].TotalRequests != rows[j].TotalRequests {
+			return rows[i].TotalRequests > rows[j].TotalRequests
+		}
+		return rows[i].Model < rows[j].Model
+	})
+}
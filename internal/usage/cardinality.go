@@ -0,0 +1,222 @@
+package usage
+
+import "time"
+
+// OverflowKey is the API key and model name under which requests are aggregated once the
+// number of distinct keys or models reaches the configured cap.
+const OverflowKey = "__other__"
+
+// overflowEvictIdle is how long an API key or model must go unseen before its slot may be
+// reclaimed for a new one. Its aggregates move to OverflowKey, so totals are unchanged.
+const overflowEvictIdle = time.Hour
+
+// CardinalityStats reports how many distinct API keys and models are tracked and how much
+// traffic was aggregated under OverflowKey because of the cap.
+type CardinalityStats struct {
+	// MaxKeys is the cap on distinct API keys and on distinct models per key; 0 is unlimited.
+	MaxKeys int `json:"max_keys"`
+	// APIKeys is the number of distinct API keys tracked, not counting OverflowKey.
+	APIKeys int `json:"api_keys"`
+	// MaxModelsPerKey is the highest number of distinct models tracked for one API key.
+	MaxModelsPerKey int `json:"max_models_per_key"`
+	// APIKeyOverflow counts requests recorded under OverflowKey instead of their API key.
+	APIKeyOverflow int64 `json:"api_key_overflow"`
+	// ModelOverflow counts requests recorded under OverflowKey instead of their model.
+	ModelOverflow int64 `json:"model_overflow"`
+	// Evictions counts idle keys and models whose aggregates were moved to OverflowKey.
+	Evictions int64 `json:"evictions"`
+	// LabelOverflow counts usage labels recorded under OverflowKey instead of their name or
+	// value.
+	LabelOverflow int64 `json:"label_overflow,omitempty"`
+}
+
+// SetStatisticsMaxKeys caps the distinct API keys, and models per key, in the shared store.
+func SetStatisticsMaxKeys(limit int) { defaultRequestStatistics.SetMaxKeys(limit) }
+
+// SetMaxKeys caps the distinct API keys, and the distinct models per key, that are tracked
+// separately; zero or negative removes the cap. Lowering the cap does not fold existing
+// entries; it only affects keys and models seen afterwards.
+func (s *RequestStatistics) SetMaxKeys(limit int) {
+	if s == nil {
+		return
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	s.foldMu.Lock()
+	s.agg.maxKeys = limit
+	s.foldMu.Unlock()
+}
+
+// placement is where one record is aggregated, and which idle entries make room for it.
+type placement struct {
+	apiName    string
+	modelName  string
+	evictAPI   string
+	evictModel string
+	// now is when the placement was decided, with a monotonic reading.
+	now time.Time
+}
+
+// place decides where a record for apiName and modelName goes without changing anything, so
+// MergeSnapshot can deduplicate against the names a record is actually stored under.
+func (a *statsAggregate) place(apiName, modelName string, now time.Time) placement {
+	p := placement{apiName: apiName, modelName: modelName, now: now}
+	api, ok := a.apis[apiName]
+	if !ok && a.atCap(len(a.apis), a.apis[OverflowKey] != nil, apiName) {
+		if victim, found := leastRecentlySeen(a.apis, now); found {
+			p.evictAPI = victim
+		} else {
+			p.apiName = OverflowKey
+			api = a.apis[OverflowKey]
+		}
+	}
+	if api == nil {
+		return p
+	}
+	if _, ok = api.Models[modelName]; !ok && a.atCap(len(api.Models), api.Models[OverflowKey] != nil, modelName) {
+		if victim, found := leastRecentlySeen(api.Models, now); found {
+			p.evictModel = victim
+		} else {
+			p.modelName = OverflowKey
+		}
+	}
+	return p
+}
+
+// atCap reports whether a new entry name cannot get its own slot in a dimension holding size
+// entries, hasOverflow telling whether one of them is OverflowKey.
+func (a *statsAggregate) atCap(size int, hasOverflow bool, name string) bool {
+	if a.maxKeys <= 0 || name == OverflowKey {
+		return false
+	}
+	if hasOverflow {
+		size--
+	}
+	return size >= a.maxKeys
+}
+
+// apply carries out the evictions of p and records the overflow, returning the entry the
+// record is aggregated into.
+func (a *statsAggregate) apply(p placement, requestedAPI, requestedModel string) *modelStats {
+	if p.evictAPI != "" {
+		a.evictAPI(p.evictAPI)
+	}
+	if p.apiName != requestedAPI {
+		a.cardinality.APIKeyOverflow++
+	}
+	api, ok := a.apis[p.apiName]
+	if !ok {
+		api = &apiStats{Models: make(map[string]*modelStats)}
+		a.apis[p.apiName] = api
+	}
+	if p.evictModel != "" {
+		a.cardinality.Evictions++
+		mergeModel(overflowModel(api), api.Models[p.evictModel])
+		delete(api.Models, p.evictModel)
+	}
+	if p.modelName != requestedModel {
+		a.cardinality.ModelOverflow++
+	}
+	model, ok := api.Models[p.modelName]
+	if !ok {
+		model = &modelStats{}
+		api.Models[p.modelName] = model
+	}
+	return model
+}
+
+// evictAPI moves the aggregates of an idle API key into OverflowKey.
+func (a *statsAggregate) evictAPI(name string) {
+	victim := a.apis[name]
+	delete(a.apis, name)
+	a.cardinality.Evictions++
+	other, ok := a.apis[OverflowKey]
+	if !ok {
+		other = &apiStats{Models: make(map[string]*modelStats)}
+		a.apis[OverflowKey] = other
+	}
+	other.TotalRequests += victim.TotalRequests
+	other.TotalTokens += victim.TotalTokens
+	if victim.lastSeen.After(other.lastSeen) {
+		other.lastSeen = victim.lastSeen
+	}
+	for modelName, model := range victim.Models {
+		target, exists := other.Models[modelName]
+		if !exists {
+			if a.atCap(len(other.Models), other.Models[OverflowKey] != nil, modelName) {
+				target = overflowModel(other)
+			} else {
+				target = &modelStats{}
+				other.Models[modelName] = target
+			}
+		}
+		mergeModel(target, model)
+	}
+}
+
+func overflowModel(api *apiStats) *modelStats {
+	model, ok := api.Models[OverflowKey]
+	if !ok {
+		model = &modelStats{}
+		api.Models[OverflowKey] = model
+	}
+	return model
+}
+
+// mergeModel adds src to dst. Details are appended, which keeps dst append-only.
+func mergeModel(dst, src *modelStats) {
+	dst.TotalRequests += src.TotalRequests
+	dst.TotalTokens += src.TotalTokens
+	dst.FailureCount += src.FailureCount
+	dst.latency.merge(src.latency)
+	dst.firstToken.merge(src.firstToken)
+	dst.Details = append(dst.Details, src.Details...)
+	if src.lastSeen.After(dst.lastSeen) {
+		dst.lastSeen = src.lastSeen
+	}
+}
+
+// leastRecentlySeen returns the entry, other than OverflowKey, seen longest ago if it has
+// been idle for at least overflowEvictIdle. Entries record when they were last seen with
+// observedAt, so idleness follows the monotonic clock and a wall clock stepped back cannot
+// make every entry look idle at once.
+func leastRecentlySeen[T interface{ seenAt() time.Time }](entries map[string]T, now time.Time) (string, bool) {
+	var oldestName string
+	var oldest time.Time
+	for name, entry := range entries {
+		if name == OverflowKey {
+			continue
+		}
+		if seen := entry.seenAt(); oldestName == "" || seen.Before(oldest) {
+			oldestName, oldest = name, seen
+		}
+	}
+	if oldestName == "" || now.Sub(oldest) < overflowEvictIdle {
+		return "", false
+	}
+	return oldestName, true
+}
+
+func (a *apiStats) seenAt() time.Time { return a.lastSeen }
+
+func (m *modelStats) seenAt() time.Time { return m.lastSeen }
+
+// cardinalitySnapshot reports the current cardinality of the aggregates.
+func (a *statsAggregate) cardinalitySnapshot() CardinalityStats {
+	stats := a.cardinality
+	stats.MaxKeys = a.maxKeys
+	for name, api := range a.apis {
+		if name != OverflowKey {
+			stats.APIKeys++
+		}
+		models := len(api.Models)
+		if api.Models[OverflowKey] != nil {
+			models--
+		}
+		if models > stats.MaxModelsPerKey {
+			stats.MaxModelsPerKey = models
+		}
+	}
+	return stats
+}
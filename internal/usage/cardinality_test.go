@@ -0,0 +1,103 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// recordKeys records one request per key, a millisecond apart from at, so a fold sees them in order.
+func recordKeys(stats *RequestStatistics, at time.Time, keys ...string) {
+	for i, key := range keys {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey: key, Model: "model-" + key, RequestedAt: at.Add(time.Duration(i) * time.Millisecond),
+			Detail: coreusage.Detail{TotalTokens: 10},
+		})
+	}
+}
+
+func TestRequestStatisticsOverflowKeepsTotals(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	stats := NewRequestStatistics()
+	stats.SetMaxKeys(2)
+	recordKeys(stats, time.Now(), "a", "b", "c", "d", "a")
+	snapshot := stats.Snapshot()
+
+	if _, ok := snapshot.APIs["c"]; ok {
+		t.Fatal("key beyond the cap got its own entry")
+	}
+	other := snapshot.APIs[OverflowKey]
+	if other.TotalRequests != 2 || other.TotalTokens != 20 || len(other.Models["model-c"].Details) != 1 {
+		t.Fatalf("unexpected overflow bucket %+v", other)
+	}
+	if snapshot.APIs["a"].TotalRequests != 2 || snapshot.TotalRequests != 5 || snapshot.TotalTokens != 50 {
+		t.Fatalf("totals changed: %+v", snapshot)
+	}
+	want := CardinalityStats{MaxKeys: 2, APIKeys: 2, MaxModelsPerKey: 2, APIKeyOverflow: 2}
+	if snapshot.Cardinality != want {
+		t.Fatalf("cardinality = %+v, want %+v", snapshot.Cardinality, want)
+	}
+}
+
+func TestRequestStatisticsEvictsIdleKeys(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	stats := NewRequestStatistics()
+	stats.SetMaxKeys(2)
+	recordKeys(stats, time.Now().Add(-3*time.Hour), "idle")
+	recordKeys(stats, time.Now().Add(-2*time.Hour), "older")
+	stats.Snapshot()
+	recordKeys(stats, time.Now(), "fresh", "newest")
+	snapshot := stats.Snapshot()
+
+	if _, ok := snapshot.APIs["idle"]; ok {
+		t.Fatal("least recently seen key was not evicted")
+	}
+	if _, ok := snapshot.APIs["older"]; ok {
+		t.Fatal("second idle key was not evicted")
+	}
+	if snapshot.APIs["fresh"].TotalRequests != 1 || snapshot.APIs["newest"].TotalRequests != 1 {
+		t.Fatalf("new keys did not get the reclaimed slots: %+v", snapshot.APIs)
+	}
+	other := snapshot.APIs[OverflowKey]
+	if other.TotalRequests != 2 || len(other.Models["model-idle"].Details) != 1 || len(other.Models["model-older"].Details) != 1 {
+		t.Fatalf("evicted aggregates missing from the overflow bucket: %+v", other)
+	}
+	if snapshot.Cardinality.Evictions != 2 || snapshot.Cardinality.APIKeyOverflow != 0 || snapshot.TotalRequests != 4 {
+		t.Fatalf("unexpected cardinality %+v with %d requests", snapshot.Cardinality, snapshot.TotalRequests)
+	}
+}
+
+func TestMergeSnapshotBeyondCapLosesNothing(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	source := NewRequestStatistics()
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		recordKeys(source, now.Add(time.Duration(i)*time.Second), fmt.Sprintf("key-%d", i))
+	}
+	persisted := source.Snapshot()
+
+	stats := NewRequestStatistics()
+	stats.SetMaxKeys(2)
+	if result := stats.MergeSnapshot(persisted); result.Added != 5 || result.Skipped != 0 {
+		t.Fatalf("first merge = %+v, want all 5 added", result)
+	}
+	if result := stats.MergeSnapshot(persisted); result.Added != 0 || result.Skipped != 5 {
+		t.Fatalf("second merge = %+v, want all 5 recognised as duplicates", result)
+	}
+	snapshot := stats.Snapshot()
+	if snapshot.TotalRequests != 5 || snapshot.TotalTokens != 50 || snapshot.APIs[OverflowKey].TotalRequests != 3 {
+		t.Fatalf("merged totals %d requests, %d tokens, %+v overflow", snapshot.TotalRequests, snapshot.TotalTokens, snapshot.APIs[OverflowKey])
+	}
+}
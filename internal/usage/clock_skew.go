@@ -0,0 +1,92 @@
+package usage
+
+import "time"
+
+// loadClockSkewTolerance is how far in the future a loaded timestamp may lie before it is
+// treated as written by a clock running ahead of this one.
+const loadClockSkewTolerance = 5 * time.Minute
+
+// latestZone is the timezone furthest ahead of UTC. No quota day or month in any reset
+// location can start after the current day there.
+var latestZone = time.FixedZone("UTC+14", 14*60*60)
+
+// clockSkewReport counts the entries of a usage file that lay in the future.
+type clockSkewReport struct {
+	savedAhead time.Duration
+	details    int
+	quotas     int
+	keyQuotas  int
+}
+
+func (r clockSkewReport) affected() int { return r.details + r.quotas + r.keyQuotas }
+
+// correctFutureTimestamps fixes the parts of payload dated after now plus
+// loadClockSkewTolerance. Request timestamps are moved back to at most now, so the requests
+// still count; quota counters for a day or month that has not begun are dropped, since they
+// would otherwise replace today's counters and then be reset on first use.
+func correctFutureTimestamps(payload *FileUsageData, now time.Time) clockSkewReport {
+	var report clockSkewReport
+	limit := now.Add(loadClockSkewTolerance)
+	if payload.SavedAt.After(limit) {
+		report.savedAhead = payload.SavedAt.Sub(now)
+	}
+
+	// A file saved ahead tells how far its clock ran ahead; moving future requests back by
+	// that much keeps their spacing. Any still in the future are set a nanosecond apart
+	// before now, so that identical requests stay distinct for deduplication.
+	shift := max(report.savedAhead, 0)
+	for _, api := range payload.Usage.APIs {
+		for _, model := range api.Models {
+			for i := range model.Details {
+				timestamp := model.Details[i].Timestamp
+				if !timestamp.After(limit) {
+					continue
+				}
+				report.details++
+				timestamp = timestamp.Add(-shift)
+				if timestamp.After(now) {
+					timestamp = now.Add(-time.Duration(report.details))
+				}
+				model.Details[i].Timestamp = timestamp
+			}
+		}
+	}
+
+	latestDay := limit.In(latestZone).Format("2006-01-02")
+	quotas := payload.Quotas[:0]
+	for _, snap := range payload.Quotas {
+		if snap.Day > latestDay {
+			report.quotas++
+			continue
+		}
+		quotas = append(quotas, snap)
+	}
+	payload.Quotas = quotas
+	for i := range payload.KeyQuotas {
+		snap := &payload.KeyQuotas[i]
+		future := false
+		if snap.Day > latestDay {
+			snap.Day, snap.DayTokens, snap.DayTopUp = "", 0, 0
+			future = true
+		}
+		if snap.Month > latestDay {
+			snap.Month, snap.MonthTokens, snap.MonthTopUp = "", 0, 0
+			future = true
+		}
+		if future {
+			report.keyQuotas++
+		}
+	}
+	return report
+}
+
+// observedAt maps a record timestamp onto this process's clock: the result has a monotonic
+// reading, so the idle time measured from it is unaffected by later steps of the wall
+// clock, and a timestamp from the future counts as now rather than as never idle.
+func observedAt(timestamp, now time.Time) time.Time {
+	age := now.Sub(timestamp)
+	if age < 0 {
+		age = 0
+	}
+	return now.Add(-age)
+}
@@ -0,0 +1,125 @@
+package usage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// loadSkewedFile writes payload to a usage file and loads it into fresh stores.
+func loadSkewedFile(t *testing.T, payload FileUsageData) *FileUsagePlugin {
+	t.Helper()
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	t.Cleanup(func() { SetStatisticsEnabled(enabled) })
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "usage.json")
+	if err = os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	plugin := NewFileUsagePlugin(path, 0, NewRequestStatistics())
+	plugin.quotas, plugin.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+	if err = plugin.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return plugin
+}
+
+func skewedUsage(details ...RequestDetail) StatisticsSnapshot {
+	return StatisticsSnapshot{APIs: map[string]APISnapshot{"key": {Models: map[string]ModelSnapshot{"model": {Details: details}}}}}
+}
+
+func TestLoadCorrectsFileFromClockAhead(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	ahead := 6 * time.Hour
+	future := RequestDetail{Timestamp: now.Add(ahead - time.Minute), Source: "s", Tokens: TokenStats{TotalTokens: 10}}
+	earlier := future
+	earlier.Timestamp = future.Timestamp.Add(-time.Minute)
+	inconsistent := RequestDetail{Timestamp: now.Add(3 * ahead), Source: "s", Tokens: TokenStats{TotalTokens: 5}}
+	later := inconsistent
+	later.Timestamp = inconsistent.Timestamp.Add(time.Second)
+	past := RequestDetail{Timestamp: now.Add(-time.Hour), Source: "s", Tokens: TokenStats{TotalTokens: 1}}
+	today := now.Format("2006-01-02")
+	nextDays := now.Add(72 * time.Hour).Format("2006-01-02")
+
+	plugin := loadSkewedFile(t, FileUsageData{
+		Version: fileUsageDataVersion,
+		SavedAt: now.Add(ahead),
+		// The last two lie further ahead than the file claims; moved to now, they must
+		// still not be mistaken for duplicates of each other.
+		Usage:     skewedUsage(past, future, earlier, inconsistent, later),
+		Quotas:    []QuotaCounterSnapshot{{Account: "a", Day: today, Requests: 3}, {Account: "b", Day: nextDays, Requests: 9}},
+		KeyQuotas: []KeyQuotaSnapshot{{APIKey: "k", Day: nextDays, DayTokens: 50, Month: today, MonthTokens: 70}},
+	})
+
+	snapshot := plugin.stats.Snapshot()
+	if snapshot.TotalRequests != 5 || snapshot.TotalTokens != 31 {
+		t.Fatalf("totals = %d requests, %d tokens, want 5 and 31", snapshot.TotalRequests, snapshot.TotalTokens)
+	}
+	limit := time.Now()
+	details := snapshot.APIs["key"].Models["model"].Details
+	for _, detail := range details {
+		if detail.Timestamp.After(limit) {
+			t.Fatalf("detail still in the future: %s", detail.Timestamp)
+		}
+	}
+	// Moving back by the skew keeps the request where it happened.
+	if got := details[0].Timestamp; !got.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("past detail moved to %s", got)
+	}
+	for day := range snapshot.RequestsByDay {
+		if day > limit.UTC().Format("2006-01-02") {
+			t.Fatalf("future day bucket %s", day)
+		}
+	}
+
+	quotas := plugin.quotas.Snapshot()
+	if len(quotas) != 1 || quotas[0].Account != "a" {
+		t.Fatalf("quota counters = %+v, want only today's", quotas)
+	}
+	keyQuotas := plugin.keyQuota.Snapshot()
+	if len(keyQuotas) != 1 || keyQuotas[0].Day != "" || keyQuotas[0].Month != today || keyQuotas[0].MonthTokens != 70 {
+		t.Fatalf("key quota counters = %+v, want the month kept and the future day dropped", keyQuotas)
+	}
+}
+
+func TestLoadKeepsFileFromClockBehind(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	behind := now.Add(-6 * time.Hour)
+	yesterday := now.Add(-24 * time.Hour).Format("2006-01-02")
+	plugin := loadSkewedFile(t, FileUsageData{
+		Version: fileUsageDataVersion,
+		SavedAt: behind,
+		Usage:   skewedUsage(RequestDetail{Timestamp: behind, Tokens: TokenStats{TotalTokens: 4}}),
+		Quotas:  []QuotaCounterSnapshot{{Account: "a", Day: yesterday, Requests: 2}},
+	})
+
+	details := plugin.stats.Snapshot().APIs["key"].Models["model"].Details
+	if len(details) != 1 || !details[0].Timestamp.Equal(behind) {
+		t.Fatalf("details = %+v, want the record loaded unchanged", details)
+	}
+	if quotas := plugin.quotas.Snapshot(); len(quotas) != 1 || quotas[0].Day != yesterday {
+		t.Fatalf("quota counters = %+v, want yesterday's kept", quotas)
+	}
+}
+
+func TestObservedAtMeasuresIdleOnMonotonicClock(t *testing.T) {
+	now := time.Now()
+	// Round(0) strips the monotonic reading, as decoding a file does.
+	if seen := observedAt(now.Add(3*time.Hour).Round(0), now); now.Sub(seen) != 0 {
+		t.Fatalf("future timestamp idle for %s, want 0", now.Sub(seen))
+	}
+	seen := observedAt(now.Add(-2*time.Hour).Round(0), now)
+	if idle := time.Since(seen); idle < 2*time.Hour || idle > 2*time.Hour+time.Minute {
+		t.Fatalf("past timestamp idle for %s, want 2h", idle)
+	}
+	if seen.Round(0) == seen {
+		t.Fatal("observed time carries no monotonic reading")
+	}
+}
@@ -0,0 +1,93 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// corruptBackupInfix and corruptBackupLayout name the backups of unreadable usage files:
+// "<file>.corrupt-20060102T150405Z", with "-2", "-3", ... appended when a second backup is
+// made within the same second.
+const (
+	corruptBackupInfix  = ".corrupt-"
+	corruptBackupLayout = "20060102T150405Z"
+)
+
+// moveCorruptAside renames the unreadable file at path to a fresh backup name and returns it.
+func moveCorruptAside(path string, now time.Time) (string, error) {
+	base := path + corruptBackupInfix + now.UTC().Format(corruptBackupLayout)
+	backup := base
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(backup); os.IsNotExist(err) {
+			break
+		}
+		backup = base + "-" + strconv.Itoa(n)
+	}
+	return backup, replaceFile(path, backup)
+}
+
+type corruptBackup struct {
+	path  string
+	taken time.Time
+	seq   int
+}
+
+// pruneCorruptBackups deletes all but the keep most recent backups of path. Backups are
+// ordered by the time in their name and then by their same-second counter, which differs
+// from name order once the counter reaches 10.
+func pruneCorruptBackups(path string, keep int) {
+	dir, prefix := filepath.Dir(path), filepath.Base(path)+corruptBackupInfix
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var backups []corruptBackup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if backup, ok := parseCorruptBackup(filepath.Join(dir, name), strings.TrimPrefix(name, prefix)); ok {
+			backups = append(backups, backup)
+		}
+	}
+	if len(backups) <= keep {
+		return
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].taken.Equal(backups[j].taken) {
+			return backups[i].taken.Before(backups[j].taken)
+		}
+		return backups[i].seq < backups[j].seq
+	})
+	for _, backup := range backups[:len(backups)-keep] {
+		if errRemove := os.Remove(backup.path); errRemove != nil {
+			log.Warnf("usage persistence: could not remove old corrupt backup %s: %v", backup.path, errRemove)
+			continue
+		}
+		log.Infof("usage persistence: removed old corrupt backup %s", backup.path)
+	}
+}
+
+// parseCorruptBackup reads the time and counter from a backup name suffix. Names that do not
+// follow corruptBackupLayout were not made by moveCorruptAside and are left alone.
+func parseCorruptBackup(path, suffix string) (corruptBackup, bool) {
+	stamp, counter, hasCounter := strings.Cut(suffix, "-")
+	taken, err := time.Parse(corruptBackupLayout, stamp)
+	if err != nil {
+		return corruptBackup{}, false
+	}
+	seq := 1
+	if hasCounter {
+		if seq, err = strconv.Atoi(counter); err != nil || seq < 2 {
+			return corruptBackup{}, false
+		}
+	}
+	return corruptBackup{path: path, taken: taken, seq: seq}, true
+}
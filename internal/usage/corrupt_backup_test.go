@@ -0,0 +1,92 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLoadPrunesCorruptBackupsByTimestamp(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "usage.json")
+	stamp := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC).Format(corruptBackupLayout)
+	older := time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC).Format(corruptBackupLayout)
+	names := []string{older, stamp, stamp + "-2", stamp + "-3", stamp + "-10", stamp + "-11", "not-a-backup"}
+	for _, name := range names {
+		if err := os.WriteFile(path+corruptBackupInfix+name, []byte("{"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every corrupt load adds one backup, made now and therefore the newest.
+	plugin := NewFileUsagePlugin(path, 0, NewRequestStatistics())
+	plugin.quotas, plugin.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+	plugin.SetMaxCorruptBackups(3)
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := plugin.Load(); err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+	}
+
+	matches, _ := filepath.Glob(path + corruptBackupInfix + "*")
+	sort.Strings(matches)
+	now := time.Now().UTC().Format("20060102")
+	var kept []string
+	for _, match := range matches {
+		kept = append(kept, filepath.Base(match)[len("usage.json"+corruptBackupInfix):])
+	}
+	if len(kept) != 4 || kept[3] != "not-a-backup" {
+		t.Fatalf("backups left = %v, want the 3 made now and the foreign file", kept)
+	}
+	for _, name := range kept[:3] {
+		if name[:8] != now {
+			t.Fatalf("backups left = %v, want only those made now", kept)
+		}
+	}
+}
+
+func TestLoadPrunesCorruptBackupsWithinOneSecondByCounter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	stamp := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC).Format(corruptBackupLayout)
+	// "-10" and "-11" sort before "-2" by name but were made after it.
+	for _, name := range []string{stamp, stamp + "-2", stamp + "-3", stamp + "-10", stamp + "-11"} {
+		if err := os.WriteFile(path+corruptBackupInfix+name, []byte("{"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(`{"version":1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	plugin := NewFileUsagePlugin(path, 0, NewRequestStatistics())
+	plugin.quotas, plugin.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+	plugin.SetMaxCorruptBackups(2)
+	if err := plugin.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for name, want := range map[string]bool{stamp: false, stamp + "-2": false, stamp + "-3": false, stamp + "-10": true, stamp + "-11": true} {
+		_, err := os.Stat(path + corruptBackupInfix + name)
+		if exists := err == nil; exists != want {
+			t.Fatalf("backup %s exists = %t, want %t", name, exists, want)
+		}
+	}
+}
+
+func TestLoadSkipsBackupOfEmptyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	plugin := NewFileUsagePlugin(path, 0, NewRequestStatistics())
+	plugin.quotas, plugin.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+	if err := plugin.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if matches, _ := filepath.Glob(path + corruptBackupInfix + "*"); len(matches) != 0 {
+		t.Fatalf("backups made of an empty file: %v", matches)
+	}
+}
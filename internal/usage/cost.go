@@ -0,0 +1,61 @@
+package usage
+
+import (
+	"sort"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// tokensPerPriceUnit is the token count model-pricing prices are given for.
+const tokensPerPriceUnit = 1_000_000
+
+// WithEstimatedCosts fills in the estimated spend of every model, key and the snapshot from
+// the request details and the prices priceFor returns. Models without a price cost nothing
+// and are listed in UnpricedModels. The estimate covers the requests whose details are kept:
+// with a shared Redis store those of this replica, after retention those not yet archived.
+func (s StatisticsSnapshot) WithEstimatedCosts(priceFor func(model string) (config.ModelPrice, bool)) StatisticsSnapshot {
+	if priceFor == nil {
+		return s
+	}
+	unpriced := make(map[string]struct{})
+	apis := make(map[string]APISnapshot, len(s.APIs))
+	s.EstimatedCost = 0
+	for apiName, api := range s.APIs {
+		models := make(map[string]ModelSnapshot, len(api.Models))
+		api.EstimatedCost = 0
+		for modelName, model := range api.Models {
+			model.EstimatedCost = 0
+			if price, ok := priceFor(modelName); ok {
+				for _, detail := range model.Details {
+					model.EstimatedCost += detailCost(price, detail.Tokens)
+				}
+			} else if len(model.Details) > 0 {
+				unpriced[modelName] = struct{}{}
+			}
+			api.EstimatedCost += model.EstimatedCost
+			models[modelName] = model
+		}
+		api.Models = models
+		s.EstimatedCost += api.EstimatedCost
+		apis[apiName] = api
+	}
+	s.APIs = apis
+	s.UnpricedModels = nil
+	for modelName := range unpriced {
+		s.UnpricedModels = append(s.UnpricedModels, modelName)
+	}
+	sort.Strings(s.UnpricedModels)
+	return s
+}
+
+// detailCost estimates one request. Cached tokens are charged at the cached-input price out
+// of the prompt tokens. Completion tokens are what the total counts beyond the prompt, so
+// reasoning is charged once whether the provider folds it into the output count or not.
+func detailCost(price config.ModelPrice, tokens TokenStats) float64 {
+	cached := min(max(tokens.CachedTokens, 0), tokens.InputTokens)
+	output := max(tokens.TotalTokens-tokens.InputTokens, tokens.OutputTokens)
+	cost := float64(tokens.InputTokens-cached)*price.Input +
+		float64(cached)*price.CachedInputPrice() +
+		float64(output)*price.Output
+	return cost / tokensPerPriceUnit
+}
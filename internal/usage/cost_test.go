@@ -0,0 +1,53 @@
+package usage
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestSnapshotEstimatedCosts(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	stats := NewRequestStatistics()
+	// Reasoning folded into the output count, as OpenAI reports it.
+	stats.Record(context.Background(), coreusage.Record{APIKey: "a", Model: "gpt-5", Detail: coreusage.Detail{
+		InputTokens: 1_000_000, CachedTokens: 400_000, OutputTokens: 100_000, ReasoningTokens: 60_000, TotalTokens: 1_100_000,
+	}})
+	// Reasoning counted apart from the output, as Gemini reports it.
+	stats.Record(context.Background(), coreusage.Record{APIKey: "b", Model: "gemini-2.5-pro", Detail: coreusage.Detail{
+		InputTokens: 200_000, OutputTokens: 50_000, ReasoningTokens: 50_000, TotalTokens: 300_000,
+	}})
+	stats.Record(context.Background(), coreusage.Record{APIKey: "b", Model: "local-llm", Detail: coreusage.Detail{TotalTokens: 10}})
+
+	cached := 0.125
+	cfg := &config.Config{ModelPricing: []config.ModelPrice{
+		{Model: "gpt-5*", Input: 1.25, Output: 10, CachedInput: &cached},
+		{Model: "gemini-*", Input: 2.5, Output: 15},
+	}}
+	snapshot := stats.Snapshot().WithEstimatedCosts(cfg.PriceFor)
+
+	// 0.6M input at 1.25, 0.4M cached at 0.125 and 0.1M completion at 10.
+	gpt := 0.75 + 0.05 + 1.0
+	// 0.2M input at 2.5 and 0.1M completion, reasoning included, at 15.
+	gemini := 0.5 + 1.5
+	for name, got := range map[string][2]float64{
+		"gpt-5 model":      {snapshot.APIs["a"].Models["gpt-5"].EstimatedCost, gpt},
+		"key b":            {snapshot.APIs["b"].EstimatedCost, gemini},
+		"snapshot":         {snapshot.EstimatedCost, gpt + gemini},
+		"unpriced model":   {snapshot.APIs["b"].Models["local-llm"].EstimatedCost, 0},
+		"original is kept": {stats.Snapshot().EstimatedCost, 0},
+	} {
+		if math.Abs(got[0]-got[1]) > 1e-9 {
+			t.Errorf("%s cost = %v, want %v", name, got[0], got[1])
+		}
+	}
+	if len(snapshot.UnpricedModels) != 1 || snapshot.UnpricedModels[0] != "local-llm" {
+		t.Fatalf("unpriced models = %v, want local-llm", snapshot.UnpricedModels)
+	}
+}
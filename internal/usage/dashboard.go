@@ -0,0 +1,141 @@
+package usage
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// DashboardTotals are all-time totals of the statistics store, and those of the last 24 hours.
+type DashboardTotals struct {
+	Requests int64 `json:"requests"`
+	Success  int64 `json:"success"`
+	Failure  int64 `json:"failure"`
+	Tokens   int64 `json:"tokens"`
+
+	Last24hRequests int64 `json:"last_24h_requests"`
+	Last24hTokens   int64 `json:"last_24h_tokens"`
+}
+
+// DashboardBucket is one interval of the request series.
+type DashboardBucket struct {
+	Start    time.Time `json:"start"`
+	Requests int64     `json:"requests"`
+	Failures int64     `json:"failures"`
+	Tokens   int64     `json:"tokens"`
+}
+
+// DashboardRow is one model or API key in a top-N table, counted over the window.
+type DashboardRow struct {
+	Name     string `json:"name"`
+	Requests int64  `json:"requests"`
+	Failures int64  `json:"failures"`
+	Tokens   int64  `json:"tokens"`
+	// Denied is the all-time count of requests refused by the key's scopes.
+	Denied int64 `json:"denied,omitempty"`
+}
+
+// DashboardData is the usage part of the management dashboard.
+type DashboardData struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Since       time.Time         `json:"since"`
+	Bucket      int64             `json:"bucket_seconds"`
+	Totals      DashboardTotals   `json:"totals"`
+	Series      []DashboardBucket `json:"series"`
+	Models      []DashboardRow    `json:"models"`
+	APIKeys     []DashboardRow    `json:"api_keys"`
+}
+
+// Dashboard buckets the requests recorded since since into intervals of bucket and ranks
+// models and API keys by requests in that window, keeping the top entries of each. Client
+// keys are masked. Windows reach back only as far as the retained request details.
+func (s *RequestStatistics) Dashboard(now, since time.Time, bucket time.Duration, top int) DashboardData {
+	snapshot := s.Snapshot()
+	start := since.Truncate(bucket)
+	count := int(now.Sub(start)/bucket) + 1
+	data := DashboardData{
+		GeneratedAt: now,
+		Since:       start,
+		Bucket:      int64(bucket / time.Second),
+		Totals: DashboardTotals{
+			Requests: snapshot.TotalRequests,
+			Success:  snapshot.SuccessCount,
+			Failure:  snapshot.FailureCount,
+			Tokens:   snapshot.TotalTokens,
+
+			Last24hRequests: snapshot.Last24Hours.Requests,
+			Last24hTokens:   snapshot.Last24Hours.Tokens,
+		},
+		Series: make([]DashboardBucket, count),
+	}
+	for i := range data.Series {
+		data.Series[i].Start = start.Add(time.Duration(i) * bucket)
+	}
+
+	models := make(map[string]*DashboardRow)
+	keys := make(map[string]*DashboardRow)
+	row := func(rows map[string]*DashboardRow, name string) *DashboardRow {
+		r := rows[name]
+		if r == nil {
+			r = &DashboardRow{Name: name}
+			rows[name] = r
+		}
+		return r
+	}
+	for apiKey, api := range snapshot.APIs {
+		keyRow := row(keys, apiKey)
+		keyRow.Denied = api.DeniedRequests
+		for modelName, model := range api.Models {
+			for _, detail := range model.Details {
+				if detail.Timestamp.Before(start) || detail.Timestamp.After(now) {
+					continue
+				}
+				tokens := billableTokens(detail)
+				b := &data.Series[int(detail.Timestamp.Sub(start)/bucket)]
+				modelRow := row(models, modelName)
+				for _, counter := range []*DashboardRow{modelRow, keyRow} {
+					counter.Requests++
+					counter.Tokens += tokens
+				}
+				b.Requests++
+				b.Tokens += tokens
+				if detail.Failed {
+					b.Failures++
+					modelRow.Failures++
+					keyRow.Failures++
+				}
+			}
+		}
+	}
+	data.Models = topRows(models, top)
+	data.APIKeys = topRows(keys, top)
+	for i := range data.APIKeys {
+		// Requests without a client key are listed by "METHOD /path", which needs no masking.
+		if name := data.APIKeys[i].Name; !strings.Contains(name, " ") {
+			data.APIKeys[i].Name = util.HideAPIKey(name)
+		}
+	}
+	return data
+}
+
+// topRows returns up to top rows with traffic or denials, busiest first.
+func topRows(rows map[string]*DashboardRow, top int) []DashboardRow {
+	out := make([]DashboardRow, 0, len(rows))
+	for _, r := range rows {
+		if r.Requests > 0 || r.Denied > 0 {
+			out = append(out, *r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Requests != out[j].Requests {
+			return out[i].Requests > out[j].Requests
+		}
+		return out[i].Name < out[j].Name
+	})
+	if top > 0 && len(out) > top {
+		out = out[:top]
+	}
+	return out
+}
@@ -0,0 +1,35 @@
+package usage
+
+// RecordDenied counts a request made with apiKey that was refused by the key's scopes.
+func (s *RequestStatistics) RecordDenied(apiKey string) {
+	if s == nil || apiKey == "" || !statisticsEnabled.Load() {
+		return
+	}
+	s.deniedMu.Lock()
+	defer s.deniedMu.Unlock()
+	if s.denied == nil {
+		s.denied = make(map[string]int64)
+	}
+	s.denied[apiKey]++
+}
+
+// applyDenied adds the denied-request counts to result, creating entries for keys whose
+// every request was denied.
+func (s *RequestStatistics) applyDenied(result *StatisticsSnapshot) {
+	s.deniedMu.Lock()
+	defer s.deniedMu.Unlock()
+	if len(s.denied) == 0 {
+		return
+	}
+	if result.APIs == nil {
+		result.APIs = make(map[string]APISnapshot, len(s.denied))
+	}
+	for apiKey, count := range s.denied {
+		api, ok := result.APIs[apiKey]
+		if !ok {
+			api.Models = make(map[string]ModelSnapshot)
+		}
+		api.DeniedRequests = count
+		result.APIs[apiKey] = api
+	}
+}
@@ -0,0 +1,165 @@
+package usage
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormatCSV is the export format accepted by ExportPersisted.
+const ExportFormatCSV = "csv"
+
+// exportColumns is the header of a CSV export, one request per row.
+var exportColumns = []string{
+	"timestamp", "api_key_hash", "model", "source", "auth_index", "request_type",
+	"failed", "cancelled", "cache_hit", "latency_ms",
+	"input_tokens", "output_tokens", "reasoning_tokens", "cached_tokens", "total_tokens",
+	"labels",
+}
+
+// exportRow is one request of an export.
+type exportRow struct {
+	apiName   string
+	modelName string
+	detail    RequestDetail
+}
+
+// ExportPersisted writes the persisted requests made in [from, to) to w, oldest first, and
+// returns how many it wrote. It reads the usage file at path, the other usage files in extra,
+// such as the fallback file, and the monthly archives retention wrote next to path, so the
+// export reaches back past keep-days; a zero from or to leaves that end open. Client keys are written as their hex SHA-256, as in the audit
+// log. format must be ExportFormatCSV.
+func ExportPersisted(w io.Writer, path string, extra []string, format string, from, to time.Time) (int, error) {
+	if format != ExportFormatCSV {
+		return 0, fmt.Errorf("unknown export format %q: use csv", format)
+	}
+	rows, err := persistedRows(path, extra, from, to)
+	if err != nil {
+		return 0, err
+	}
+	out := csv.NewWriter(w)
+	if err = out.Write(exportColumns); err != nil {
+		return 0, err
+	}
+	for _, row := range rows {
+		d := row.detail
+		record := []string{
+			d.Timestamp.UTC().Format(time.RFC3339Nano), hashAPIKey(row.apiName), row.modelName,
+			d.Source, d.AuthIndex, d.RequestType,
+			strconv.FormatBool(d.Failed), strconv.FormatBool(d.Cancelled), strconv.FormatBool(d.CacheHit),
+			strconv.FormatInt(d.LatencyMs, 10),
+			strconv.FormatInt(d.Tokens.InputTokens, 10), strconv.FormatInt(d.Tokens.OutputTokens, 10),
+			strconv.FormatInt(d.Tokens.ReasoningTokens, 10), strconv.FormatInt(d.Tokens.CachedTokens, 10),
+			strconv.FormatInt(d.Tokens.TotalTokens, 10),
+			strings.TrimSuffix(labelsKey(d.Labels), ";"),
+		}
+		if err = out.Write(record); err != nil {
+			return 0, err
+		}
+	}
+	out.Flush()
+	return len(rows), out.Error()
+}
+
+// persistedRows collects the requests in [from, to) from the usage files and the archives of
+// the months the range touches, dropping requests found in more than one of them.
+func persistedRows(path string, extra []string, from, to time.Time) ([]exportRow, error) {
+	var snapshots []StatisticsSnapshot
+	for _, file := range append([]string{path}, extra...) {
+		if file == "" {
+			continue
+		}
+		snapshot, err := readUsageFile(file)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	archives, err := filepath.Glob(archivePath(path, "*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, archive := range archives {
+		if !archiveInRange(archive, path, from, to) {
+			continue
+		}
+		snapshot, errRead := readArchive(archive)
+		if errRead != nil {
+			return nil, errRead
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	var rows []exportRow
+	seen := make(map[string]struct{})
+	for _, snapshot := range snapshots {
+		for apiName, api := range snapshot.APIs {
+			for modelName, model := range api.Models {
+				for _, detail := range model.Details {
+					if (!from.IsZero() && detail.Timestamp.Before(from)) || (!to.IsZero() && !detail.Timestamp.Before(to)) {
+						continue
+					}
+					key := dedupKey(apiName, modelName, detail)
+					if _, dup := seen[key]; dup {
+						continue
+					}
+					seen[key] = struct{}{}
+					rows = append(rows, exportRow{apiName: apiName, modelName: modelName, detail: detail})
+				}
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].detail.Timestamp.Before(rows[j].detail.Timestamp) })
+	return rows, nil
+}
+
+// archiveInRange reports whether the month of the archive at archive, named by archivePath,
+// overlaps [from, to). Archive months are UTC.
+func archiveInRange(archive, path string, from, to time.Time) bool {
+	prefix := strings.TrimSuffix(path, filepath.Ext(path)) + "-"
+	month, err := time.Parse(archiveMonthLayout, strings.TrimSuffix(strings.TrimPrefix(archive, prefix), ".json.gz"))
+	if err != nil {
+		return false
+	}
+	return (to.IsZero() || month.Before(to)) && (from.IsZero() || month.AddDate(0, 1, 0).After(from))
+}
+
+// readUsageFile returns the statistics in the usage file at path without changing it; a
+// missing or empty file holds none.
+func readUsageFile(path string) (StatisticsSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return StatisticsSnapshot{}, nil
+		}
+		return StatisticsSnapshot{}, err
+	}
+	if len(data) == 0 {
+		return StatisticsSnapshot{}, nil
+	}
+	var payload FileUsageData
+	if err = json.Unmarshal(data, &payload); err != nil {
+		return StatisticsSnapshot{}, fmt.Errorf("%s is unreadable: %w", path, err)
+	}
+	return payload.Usage, nil
+}
+
+// hashAPIKey returns the hex SHA-256 under which client keys leave the proxy. A key the
+// usage file holds hashed already is that hash.
+func hashAPIKey(apiKey string) string {
+	if hashed, ok := strings.CutPrefix(apiKey, hashedKeyPrefix); ok {
+		return hashed
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,50 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportPersistedReadsFileAndArchives(t *testing.T) {
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	// One request in January, archived, and two in March, kept in the file.
+	plugin := retentionPlugin(t, now, 60*day, 10*day, time.Hour)
+	plugin.SetRetention(30, 0)
+	plugin.applyRetention(now, true)
+	if err := plugin.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	var out bytes.Buffer
+	n, err := ExportPersisted(&out, plugin.path, nil, ExportFormatCSV, time.Time{}, time.Time{})
+	if err != nil || n != 3 {
+		t.Fatalf("ExportPersisted = %d, %v; want 3 requests", n, err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(rows) != 4 || strings.Join(rows[0], ",") != strings.Join(exportColumns, ",") {
+		t.Fatalf("csv has %d rows, header %v", len(rows), rows[0])
+	}
+	first := rows[1]
+	if first[0] != now.Add(-60*day).Format(time.RFC3339Nano) || first[1] != hashAPIKey("key") || first[2] != "gpt-5" || first[14] != "10" {
+		t.Fatalf("oldest row = %v, want the archived January request", first)
+	}
+	if strings.Contains(out.String(), ",key,") {
+		t.Fatalf("export contains the raw client key")
+	}
+
+	// The range skips the archive and the request after it.
+	n, err = ExportPersisted(&bytes.Buffer{}, plugin.path, nil, ExportFormatCSV, now.Add(-11*day), now.Add(-2*time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("ranged export = %d, %v; want 1 request", n, err)
+	}
+	if _, err = ExportPersisted(&bytes.Buffer{}, plugin.path, nil, "parquet", time.Time{}, time.Time{}); err == nil {
+		t.Fatalf("an unknown export format should be rejected")
+	}
+}
@@ -0,0 +1,166 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// fileUsageRecord is one request in a usage file.
+type fileUsageRecord struct {
+	API    string        `json:"api"`
+	Model  string        `json:"model"`
+	Detail RequestDetail `json:"detail"`
+}
+
+// fileUsageLayout is how FileUsageData is laid out on disk since version 2. Only the request
+// details are stored: the totals a snapshot derives from them are rebuilt when the file is
+// merged, so storing them would only make the file larger and able to disagree with itself.
+type fileUsageLayout struct {
+	Version     int                    `json:"version"`
+	SavedAt     time.Time              `json:"saved_at"`
+	Records     []fileUsageRecord      `json:"records"`
+	Quotas      []QuotaCounterSnapshot `json:"quotas,omitempty"`
+	KeyQuotas   []KeyQuotaSnapshot     `json:"key_quotas,omitempty"`
+	JournalMark int64                  `json:"journal_mark,omitempty"`
+}
+
+// fileUsageMigration rewrites the top-level keys of a usage file of one version into the
+// layout of the next. Migrations work on the raw JSON rather than on the current types, so
+// they keep reading the files they were written for as the types change.
+type fileUsageMigration func(raw map[string]json.RawMessage) error
+
+// fileUsageMigrations holds the migration from each earlier version to the one after it.
+// A change to the layout bumps fileUsageDataVersion and registers the migration from the
+// previous version here.
+var fileUsageMigrations = map[int]fileUsageMigration{
+	1: migrateFileUsageV1,
+}
+
+// MarshalJSON writes the data in the current layout.
+func (d FileUsageData) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fileUsageLayout{
+		Version:     fileUsageDataVersion,
+		SavedAt:     d.SavedAt,
+		Records:     usageRecords(d.Usage),
+		Quotas:      d.Quotas,
+		KeyQuotas:   d.KeyQuotas,
+		JournalMark: d.JournalMark,
+	})
+}
+
+// UnmarshalJSON reads a usage file of any version up to the current one, migrating older
+// layouts first. Version is left at the version the file was written with. Usage holds only
+// the details of the file; merge it into a store for totals.
+func (d *FileUsageData) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	fileVersion := 1
+	if encoded, ok := raw["version"]; ok {
+		if err := json.Unmarshal(encoded, &fileVersion); err != nil {
+			return fmt.Errorf("invalid version: %w", err)
+		}
+	}
+	if fileVersion < 1 || fileVersion > fileUsageDataVersion {
+		return fmt.Errorf("unsupported version %d", fileVersion)
+	}
+	if fileVersion < fileUsageDataVersion {
+		for version := fileVersion; version < fileUsageDataVersion; version++ {
+			migrate, ok := fileUsageMigrations[version]
+			if !ok {
+				return fmt.Errorf("no migration from version %d", version)
+			}
+			if err := migrate(raw); err != nil {
+				return fmt.Errorf("migrate from version %d: %w", version, err)
+			}
+			raw["version"] = json.RawMessage(fmt.Sprint(version + 1))
+		}
+		var err error
+		if data, err = json.Marshal(raw); err != nil {
+			return err
+		}
+	}
+
+	var layout fileUsageLayout
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return err
+	}
+	entries := make([]pendingDetail, 0, len(layout.Records))
+	for _, record := range layout.Records {
+		entries = append(entries, pendingDetail{apiName: record.API, modelName: record.Model, detail: record.Detail})
+	}
+	*d = FileUsageData{
+		Version:     fileVersion,
+		SavedAt:     layout.SavedAt,
+		Usage:       snapshotOf(entries),
+		Quotas:      layout.Quotas,
+		KeyQuotas:   layout.KeyQuotas,
+		JournalMark: layout.JournalMark,
+	}
+	return nil
+}
+
+// usageRecords flattens the details of snapshot, ordered by client key and model so that
+// unchanged statistics encode the same way.
+func usageRecords(snapshot StatisticsSnapshot) []fileUsageRecord {
+	records := make([]fileUsageRecord, 0)
+	for _, apiName := range sortedKeys(snapshot.APIs) {
+		models := snapshot.APIs[apiName].Models
+		for _, modelName := range sortedKeys(models) {
+			for _, detail := range models[modelName].Details {
+				records = append(records, fileUsageRecord{API: apiName, Model: modelName, Detail: detail})
+			}
+		}
+	}
+	return records
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// migrateFileUsageV1 replaces the full statistics snapshot of version 1 under "usage" with
+// the records of its details. Details keep their encoding.
+func migrateFileUsageV1(raw map[string]json.RawMessage) error {
+	var usage struct {
+		APIs map[string]struct {
+			Models map[string]struct {
+				Details []json.RawMessage `json:"details"`
+			} `json:"models"`
+		} `json:"apis"`
+	}
+	if encoded, ok := raw["usage"]; ok {
+		if err := json.Unmarshal(encoded, &usage); err != nil {
+			return fmt.Errorf("usage: %w", err)
+		}
+	}
+	type record struct {
+		API    string          `json:"api"`
+		Model  string          `json:"model"`
+		Detail json.RawMessage `json:"detail"`
+	}
+	records := make([]record, 0)
+	for _, apiName := range sortedKeys(usage.APIs) {
+		models := usage.APIs[apiName].Models
+		for _, modelName := range sortedKeys(models) {
+			for _, detail := range models[modelName].Details {
+				records = append(records, record{API: apiName, Model: modelName, Detail: detail})
+			}
+		}
+	}
+	encoded, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	delete(raw, "usage")
+	raw["records"] = encoded
+	return nil
+}
@@ -0,0 +1,84 @@
+package usage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// usageFileV1 is a file as version 1 wrote it: the full snapshot, totals included.
+const usageFileV1 = `{
+	"version": 1,
+	"saved_at": "2025-03-20T12:00:00Z",
+	"usage": {
+		"total_requests": 2, "success_count": 1, "failure_count": 1, "total_tokens": 30,
+		"apis": {"key": {"total_requests": 2, "total_tokens": 30, "models": {
+			"gpt-5": {"total_requests": 2, "total_tokens": 30, "details": [
+				{"timestamp": "2025-03-20T10:00:00Z", "source": "s", "auth_index": "1", "tokens": {"total_tokens": 10}, "failed": false},
+				{"timestamp": "2025-03-20T11:00:00Z", "source": "s", "auth_index": "1", "tokens": {"total_tokens": 20}, "failed": true}
+			]}
+		}}},
+		"requests_by_day": {"2025-03-20": 2}
+	},
+	"quotas": [{"account": "a", "provider": "codex", "day": "2025-03-20", "requests": 7}]
+}`
+
+func TestFileUsagePluginMigratesVersion1File(t *testing.T) {
+	for version := 1; version < fileUsageDataVersion; version++ {
+		if fileUsageMigrations[version] == nil {
+			t.Fatalf("no migration registered from version %d", version)
+		}
+	}
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+	path := filepath.Join(t.TempDir(), "usage.json")
+	if err := os.WriteFile(path, []byte(usageFileV1), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	plugin := NewFileUsagePlugin(path, 0, NewRequestStatistics())
+	plugin.quotas, plugin.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+	if err := plugin.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	snapshot := plugin.stats.Snapshot()
+	if snapshot.TotalRequests != 2 || snapshot.FailureCount != 1 || snapshot.TotalTokens != 30 {
+		t.Fatalf("loaded %d requests, %d failed, %d tokens; want 2, 1, 30", snapshot.TotalRequests, snapshot.FailureCount, snapshot.TotalTokens)
+	}
+	if quotas := plugin.quotas.Snapshot(); len(quotas) != 1 || quotas[0].Requests != 7 {
+		t.Fatalf("quota counters = %+v, want the version 1 counter", quotas)
+	}
+	if matches, _ := filepath.Glob(path + corruptBackupInfix + "*"); len(matches) != 0 {
+		t.Fatalf("version 1 file was moved aside: %v", matches)
+	}
+
+	if err := plugin.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw map[string]json.RawMessage
+	if err = json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if string(raw["version"]) != "2" || raw["usage"] != nil || raw["records"] == nil {
+		t.Fatalf("saved file is not in the version 2 layout: %s", data)
+	}
+	var saved FileUsageData
+	if err = json.Unmarshal(data, &saved); err != nil || len(usageRecords(saved.Usage)) != 2 {
+		t.Fatalf("decode saved file: %d records, %v; want 2", len(usageRecords(saved.Usage)), err)
+	}
+}
+
+func TestFileUsageDataRejectsNewerVersion(t *testing.T) {
+	var payload FileUsageData
+	err := json.Unmarshal([]byte(`{"version": 99, "records": []}`), &payload)
+	if err == nil || !strings.Contains(err.Error(), "unsupported version 99") {
+		t.Fatalf("decode of a newer version = %v, want unsupported version", err)
+	}
+}
@@ -0,0 +1,645 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/errorreport"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// fileUsageDataVersion is the current on-disk format version; fileUsageMigrations upgrades
+// files of earlier versions.
+const fileUsageDataVersion = 2
+
+// FileUsageData is the on-disk representation of persisted usage statistics. It is encoded
+// in the layout of fileUsageLayout.
+type FileUsageData struct {
+	Version int
+	SavedAt time.Time
+	// Usage holds the persisted requests. Only their details are stored, so a decoded Usage
+	// carries no totals.
+	Usage StatisticsSnapshot
+	// Quotas holds the daily per-account request counters used for quota-aware routing.
+	Quotas []QuotaCounterSnapshot
+	// KeyQuotas holds the per-client-key token counters behind api-key-settings quotas.
+	KeyQuotas []KeyQuotaSnapshot
+	// JournalMark names the last journal segment the file covers; see usageJournal.
+	JournalMark int64
+}
+
+// FileUsagePlugin persists a RequestStatistics store to a JSON file.
+// It implements coreusage.Plugin only to track whether new records arrived since the
+// last save; aggregation itself is performed by LoggerPlugin.
+type FileUsagePlugin struct {
+	path     string
+	interval time.Duration
+	stats    *RequestStatistics
+	quotas   *QuotaTracker
+	keyQuota *KeyQuotaTracker
+	// maxBackups is how many backups of unreadable files Load keeps.
+	maxBackups int
+	// fallback is where saves go while path cannot be written; empty disables it.
+	fallback string
+	health   saveHealth
+	// keepDays and maxFileBytes are the retention limits; zero disables either. lastSize is
+	// the size of the last file written, against which maxFileBytes is checked.
+	keepDays     int
+	maxFileBytes int64
+	lastSize     atomic.Int64
+	// journal is set by EnableJournal; loadedMark is the newest JournalMark Load read, which
+	// tells journal replay which segments the loaded quota counters include.
+	journal    *usageJournal
+	loadedMark int64
+	// remote, when set, is the object path is the working copy of; see SetRemoteFile.
+	remote *RemoteFile
+	// keyMode is how client keys are written; see SetKeyIdentifiers.
+	keyMode string
+
+	// stateMu makes Load's merge and restores one step with respect to the snapshots a save
+	// takes, so a file never holds merged usage next to quota counters not yet restored.
+	stateMu    sync.Mutex
+	saveMu     sync.Mutex
+	saveSeq    atomic.Uint64
+	writtenSeq uint64 // guarded by saveMu
+	dirty      atomic.Bool
+	started    atomic.Bool
+	// saveRequested holds at most one pending request, so triggers that arrive while a save
+	// runs are coalesced into a single follow-up save.
+	saveRequested chan struct{}
+	// reconfigure hands reloaded settings to the save worker, which owns interval and the
+	// retention limits while it runs; see Reconfigure.
+	reconfigure chan persistenceSettings
+	writeFile   func(path string, data []byte) error
+	lastSave    atomic.Int64 // unix nanoseconds of the last successful write
+
+	// election is set when replicas share the file; replicaPath is where this replica hands
+	// its statistics to the leader while it is a follower.
+	election    *leaderLock
+	replicaPath string
+
+	haltOnce sync.Once
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// NewFileUsagePlugin constructs a file-backed persistence plugin.
+//
+// Parameters:
+//   - path: The JSON file used to store statistics
+//   - interval: The periodic save interval; zero saves only on Stop
+//   - stats: The statistics store to persist; nil uses the shared store
+//
+// Returns:
+//   - *FileUsagePlugin: A plugin ready for Load and Start
+func NewFileUsagePlugin(path string, interval time.Duration, stats *RequestStatistics) *FileUsagePlugin {
+	if stats == nil {
+		stats = defaultRequestStatistics
+	}
+	return &FileUsagePlugin{
+		path:     path,
+		interval: interval,
+		stats:    stats,
+		quotas:   defaultQuotaTracker,
+		keyQuota: defaultKeyQuotaTracker,
+
+		maxBackups:    config.DefaultUsageCorruptBackups,
+		saveRequested: make(chan struct{}, 1),
+		reconfigure:   make(chan persistenceSettings, 1),
+		writeFile:     writeFileAtomic,
+		stopCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+}
+
+// HandleUsage implements coreusage.Plugin by marking the store as changed and, with the
+// journal enabled, journaling the record.
+func (p *FileUsagePlugin) HandleUsage(ctx context.Context, record coreusage.Record) {
+	if p == nil || (!statisticsEnabled.Load() && !p.keyQuota.Tracks(record.APIKey)) {
+		return
+	}
+	p.dirty.Store(true)
+	p.journal.append(ctx, record, p.keyMode)
+}
+
+// SetMaxCorruptBackups sets how many backups of unreadable files Load keeps; values below
+// one keep config.DefaultUsageCorruptBackups.
+func (p *FileUsagePlugin) SetMaxCorruptBackups(n int) {
+	if p == nil {
+		return
+	}
+	if n <= 0 {
+		n = config.DefaultUsageCorruptBackups
+	}
+	p.maxBackups = n
+}
+
+// SetFallbackFile sets the file statistics are saved to while the usage file cannot be
+// written. Load also merges it, so statistics saved there survive a restart. Call it before
+// Load.
+func (p *FileUsagePlugin) SetFallbackFile(path string) {
+	if p == nil || path == p.path {
+		return
+	}
+	p.fallback = path
+}
+
+// Load merges previously persisted statistics into the store.
+// A missing or empty file is not an error. A file that cannot be decoded is moved aside
+// so that the next save does not overwrite it, and loading continues with empty statistics.
+// Backups beyond the newest maxBackups are deleted. Entries dated in the future, written by
+// a clock running ahead, are corrected with a warning rather than loaded as they are.
+// A fallback file left by saves that could not reach the usage file is merged as well, and so
+// is the remote file set by SetRemoteFile.
+func (p *FileUsagePlugin) Load() error {
+	if p == nil || p.path == "" {
+		return nil
+	}
+	if err := p.loadFile(p.path); err != nil {
+		return err
+	}
+	if p.remote != nil {
+		if err := p.loadRemote(); err != nil {
+			return err
+		}
+	}
+	if p.fallback != "" {
+		if err := p.loadFile(p.fallback); err != nil {
+			return err
+		}
+	}
+	return p.replayJournal()
+}
+
+func (p *FileUsagePlugin) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("usage persistence: read %s: %w", path, err)
+	}
+	if len(data) == 0 {
+		// A crash during the first write leaves an empty file; there is nothing to keep.
+		log.Warnf("usage persistence: %s is empty; starting with empty statistics", path)
+		return nil
+	}
+	var payload FileUsageData
+	if err = json.Unmarshal(data, &payload); err != nil {
+		backup, errRename := moveCorruptAside(path, time.Now())
+		if errRename != nil {
+			return fmt.Errorf("usage persistence: %s is unreadable (%v) and could not be moved aside: %w", path, err, errRename)
+		}
+		log.Warnf("usage persistence: %s is unreadable (%v); moved to %s and starting with empty statistics", path, err, backup)
+		pruneCorruptBackups(path, p.maxBackups)
+		return nil
+	}
+	if payload.Version < fileUsageDataVersion {
+		log.Infof("usage persistence: %s has version %d; it is rewritten as version %d on the next save", path, payload.Version, fileUsageDataVersion)
+	}
+	skew := correctFutureTimestamps(&payload, time.Now())
+	if skew.savedAhead > 0 {
+		log.Warnf("usage persistence: %s was saved %s ahead of this clock", path, skew.savedAhead.Round(time.Second))
+	}
+	if n := skew.affected(); n > 0 {
+		log.Warnf("usage persistence: corrected %d future-dated entries in %s: %d requests moved back to now or earlier, %d quota and %d key quota counters dropped",
+			n, path, skew.details, skew.quotas, skew.keyQuotas)
+	}
+	p.stateMu.Lock()
+	p.loadedMark = max(p.loadedMark, payload.JournalMark)
+	result := p.stats.MergeSnapshot(payload.Usage)
+	p.quotas.Restore(payload.Quotas)
+	p.keyQuota.Restore(payload.KeyQuotas)
+	p.stateMu.Unlock()
+	log.Infof("usage persistence: loaded %d records from %s (%d skipped)", result.Added, path, result.Skipped)
+	pruneCorruptBackups(path, p.maxBackups)
+	return nil
+}
+
+// EnableLeaderElection lets this plugin share its file with other replicas: only the holder
+// of the lock file next to it saves, and the others write their statistics to a file of their
+// own that the leader merges on every save. The save interval is the lease of the lock, so it
+// must be positive. Call it before Start.
+func (p *FileUsagePlugin) EnableLeaderElection() {
+	if p == nil || p.path == "" || p.interval <= 0 {
+		return
+	}
+	p.election = newLeaderLock(p.path+".lock", p.interval)
+	p.replicaPath = p.path + replicaFileInfix + p.election.id + ".json"
+}
+
+// replicaFileInfix separates the usage file name from the replica ID in hand-over files.
+const replicaFileInfix = ".replica-"
+
+// Save writes the current statistics snapshot to disk atomically. Taking the snapshot is
+// cheap and encoding happens outside every lock, so recording continues during a save;
+// only the file write itself is serialised. When the file cannot be written and a fallback
+// file is set, the statistics are saved there instead and no error is returned.
+func (p *FileUsagePlugin) Save() error {
+	if p == nil || p.path == "" {
+		return nil
+	}
+	err := p.saveTo(p.path)
+	var errFallback error
+	if err != nil && p.fallback != "" {
+		errFallback = p.saveTo(p.fallback)
+	}
+	p.recordSave(err, p.fallback, errFallback)
+	if errFallback == nil && p.fallback != "" {
+		return nil
+	}
+	return err
+}
+
+func (p *FileUsagePlugin) saveTo(path string) error {
+	p.stateMu.Lock()
+	seq := p.saveSeq.Add(1)
+	p.dirty.Store(false)
+	payload := FileUsageData{
+		Version:     fileUsageDataVersion,
+		SavedAt:     time.Now().UTC(),
+		Usage:       diskUsage(p.keyMode, p.stats.Snapshot()),
+		Quotas:      p.quotas.Snapshot(),
+		KeyQuotas:   diskKeyQuotas(p.keyMode, p.keyQuota.Snapshot()),
+		JournalMark: p.journal.seal(seq),
+	}
+	p.stateMu.Unlock()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		p.dirty.Store(true)
+		return fmt.Errorf("usage persistence: encode: %w", err)
+	}
+
+	p.saveMu.Lock()
+	defer p.saveMu.Unlock()
+	if seq < p.writtenSeq {
+		// A concurrent save captured a newer snapshot and has already written it.
+		return nil
+	}
+	if err = p.writeFile(path, data); err != nil {
+		p.dirty.Store(true)
+		return fmt.Errorf("usage persistence: write %s: %w", path, err)
+	}
+	if p.remote != nil && path == p.path {
+		if err = p.remote.Upload(context.Background(), data); err != nil {
+			p.dirty.Store(true)
+			return fmt.Errorf("usage persistence: upload %s: %w", p.remote, err)
+		}
+	}
+	p.writtenSeq = seq
+	p.lastSave.Store(time.Now().UnixNano())
+	p.lastSize.Store(int64(len(data)))
+	p.journal.release(seq)
+	return nil
+}
+
+// persist saves the file, or with leader election hands the statistics to the leader unless
+// this replica is the leader, which first merges what the followers handed over. Details
+// past the retention limits are archived first.
+func (p *FileUsagePlugin) persist() error {
+	if p.election == nil {
+		p.applyRetention(time.Now(), true)
+		return p.Save()
+	}
+	if !p.election.isLeader() {
+		p.applyRetention(time.Now(), false)
+		err := p.saveTo(p.replicaPath)
+		p.recordSave(err, "", nil)
+		return err
+	}
+	p.mergeReplicaFiles()
+	p.applyRetention(time.Now(), true)
+	return p.Save()
+}
+
+// mergeReplicaFiles merges and removes the hand-over files of the followers. Followers write
+// their full statistics every interval and MergeSnapshot skips records it already holds, so a
+// file removed just before its replica rewrites it loses nothing.
+func (p *FileUsagePlugin) mergeReplicaFiles() {
+	dir, prefix := filepath.Dir(p.path), filepath.Base(p.path)+replicaFileInfix
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || len(name) <= len(prefix) || name[:len(prefix)] != prefix || filepath.Ext(name) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		data, errRead := os.ReadFile(path)
+		if errRead != nil {
+			continue
+		}
+		var payload FileUsageData
+		if errDecode := json.Unmarshal(data, &payload); errDecode != nil {
+			// Most likely written by a newer version; leave it for that leader.
+			log.Warnf("usage persistence: skipping unreadable replica file %s: %v", path, errDecode)
+			continue
+		}
+		if result := p.stats.MergeSnapshot(payload.Usage); result.Added > 0 {
+			log.Debugf("usage persistence: merged %d records from %s", result.Added, path)
+		}
+		_ = os.Remove(path)
+	}
+}
+
+// heartbeat renews or takes the leader lock. A replica that becomes leader loads what the
+// previous leader saved, so its first save carries everything forward.
+func (p *FileUsagePlugin) heartbeat() {
+	_, acquired, err := p.election.heartbeat()
+	if err != nil {
+		log.Warnf("usage persistence: leader election for %s: %v", p.path, err)
+		return
+	}
+	if !acquired {
+		return
+	}
+	log.Infof("usage persistence: replica %s is now the leader for %s", p.election.id, p.path)
+	if errLoad := p.Load(); errLoad != nil {
+		log.Errorf("%v", errLoad)
+	}
+	_ = os.Remove(p.replicaPath)
+	p.RequestSave()
+}
+
+// PersistenceStatus describes the usage persistence of this process.
+type PersistenceStatus struct {
+	Enabled        bool   `json:"enabled"`
+	File           string `json:"file,omitempty"`
+	LeaderElection bool   `json:"leader_election"`
+	// Role is standalone without leader election, otherwise leader or follower.
+	Role            string    `json:"role,omitempty"`
+	ReplicaID       string    `json:"replica_id,omitempty"`
+	Leader          string    `json:"leader,omitempty"`
+	LeaderHeartbeat time.Time `json:"leader_heartbeat,omitempty"`
+	// LastSave is the last write of the usage file, or of the hand-over file for a follower.
+	LastSave time.Time `json:"last_save,omitempty"`
+
+	// FallbackFile is where statistics are saved while the usage file cannot be written;
+	// UsingFallback is set while they are.
+	FallbackFile  string `json:"fallback_file,omitempty"`
+	UsingFallback bool   `json:"using_fallback,omitempty"`
+	// Failing is set while saves write nothing; NextAttempt is when the next one is made.
+	Failing     bool      `json:"failing,omitempty"`
+	NextAttempt time.Time `json:"next_attempt,omitempty"`
+	// LastSaveError is the last error writing the usage file, kept after recovery along with
+	// when it happened.
+	LastSaveError   string    `json:"last_save_error,omitempty"`
+	LastSaveErrorAt time.Time `json:"last_save_error_at,omitempty"`
+}
+
+// activeFilePlugin is the started plugin the management API reports on.
+var activeFilePlugin atomic.Pointer[FileUsagePlugin]
+
+// CurrentPersistenceStatus reports on the running usage persistence, if any.
+func CurrentPersistenceStatus() PersistenceStatus {
+	if p := activeFilePlugin.Load(); p != nil {
+		return p.Status()
+	}
+	return PersistenceStatus{}
+}
+
+// RequestPersistenceSave asks the running usage persistence, if any, to save soon.
+func RequestPersistenceSave() {
+	activeFilePlugin.Load().RequestSave()
+}
+
+// ResetStatistics resets stats and, when they are the statistics of the running usage
+// persistence, empties its journal and asks for a save, so that neither a crash nor a
+// restart brings the dropped requests back.
+func ResetStatistics(stats *RequestStatistics) int64 {
+	if p := activeFilePlugin.Load(); p != nil && p.stats == stats {
+		return p.resetStatistics()
+	}
+	return stats.Reset()
+}
+
+// resetStatistics resets the store, truncates the journal and asks for a save. The quota
+// counters are kept; until that save, those of requests since the last one are held in
+// memory only.
+func (p *FileUsagePlugin) resetStatistics() int64 {
+	p.stateMu.Lock()
+	cleared := p.stats.Reset()
+	p.journal.truncate()
+	p.dirty.Store(true)
+	p.stateMu.Unlock()
+	p.RequestSave()
+	return cleared
+}
+
+// Status reports the file, the election role, the last save and any save failure.
+func (p *FileUsagePlugin) Status() PersistenceStatus {
+	status := PersistenceStatus{Enabled: p != nil && p.path != ""}
+	if !status.Enabled {
+		return status
+	}
+	status.File = p.path
+	status.Role = PersistenceRoleStandalone
+	if last := p.lastSave.Load(); last > 0 {
+		status.LastSave = time.Unix(0, last).UTC()
+	}
+	status.FallbackFile = p.fallback
+	p.health.mu.Lock()
+	status.UsingFallback = p.health.state == saveFallback
+	status.Failing = p.health.state == saveFailing
+	if status.Failing {
+		status.NextAttempt = p.health.retryAt.UTC()
+	}
+	if p.health.lastErr != nil {
+		status.LastSaveError = p.health.lastErr.Error()
+		status.LastSaveErrorAt = p.health.lastErrAt.UTC()
+	}
+	p.health.mu.Unlock()
+	if p.election != nil {
+		leader, holder := p.election.state()
+		status.LeaderElection = true
+		status.ReplicaID = p.election.id
+		status.Role = PersistenceRoleFollower
+		if leader {
+			status.Role = PersistenceRoleLeader
+		}
+		status.Leader = holder.Owner
+		status.LeaderHeartbeat = holder.Heartbeat
+	}
+	return status
+}
+
+// Start launches the save worker. It saves every interval when new records arrived and
+// whenever RequestSave is called; with a zero interval there are no periodic saves and
+// statistics are otherwise saved only when Stop is called.
+func (p *FileUsagePlugin) Start() {
+	if p == nil || !p.started.CompareAndSwap(false, true) {
+		return
+	}
+	if p.interval <= 0 {
+		log.Infof("usage persistence: save-interval is 0, statistics will be saved to %s only on shutdown", p.path)
+	} else {
+		log.Infof("usage persistence: saving statistics to %s every %s", p.path, p.interval)
+	}
+	if p.election != nil {
+		// Settle the role before the first save.
+		p.heartbeat()
+	}
+	activeFilePlugin.Store(p)
+	go p.run()
+}
+
+// RequestSave asks the save worker for a save without waiting for it. Requests made while a
+// save is in progress result in one more save once it finishes. A request made before Start
+// is served when the worker starts; after Stop it has no effect.
+func (p *FileUsagePlugin) RequestSave() {
+	if p == nil {
+		return
+	}
+	select {
+	case p.saveRequested <- struct{}{}:
+	default:
+	}
+}
+
+// run is the only goroutine that saves while the plugin is running, so saves never overlap.
+// While saves write nothing it backs off, ignoring ticks and save requests until the next
+// attempt is due, and returns to the interval once a save succeeds.
+func (p *FileUsagePlugin) run() {
+	defer close(p.doneCh)
+	var tick, heartbeat, retry <-chan time.Time
+	var ticker *time.Ticker
+	resetTicker := func() {
+		if ticker != nil {
+			ticker.Stop()
+			ticker, tick = nil, nil
+		}
+		if p.interval > 0 {
+			ticker = time.NewTicker(p.interval)
+			tick = ticker.C
+		}
+	}
+	resetTicker()
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+	if p.election != nil {
+		// Three heartbeats per lease keep one slow write from costing the lock.
+		heartbeatTicker := time.NewTicker(p.interval / 3)
+		defer heartbeatTicker.Stop()
+		heartbeat = heartbeatTicker.C
+	}
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case settings := <-p.reconfigure:
+			if p.applySettings(settings) {
+				resetTicker()
+			}
+			continue
+		case <-heartbeat:
+			p.heartbeat()
+			continue
+		case <-tick:
+			// A leader saves on every tick to pick up what followers handed over.
+			if retry != nil || (p.election == nil && !p.dirty.Load()) {
+				continue
+			}
+		case <-p.saveRequested:
+			if retry != nil {
+				continue
+			}
+		case <-retry:
+		}
+		// Failures are logged by recordSave when saving starts or stops working.
+		_ = p.persist()
+		retry = nil
+		if delay := p.health.retryDelay(); delay > 0 {
+			retry = time.After(delay)
+		}
+	}
+}
+
+// Halt stops the save worker, waiting for a save in progress to finish, without the final
+// save: records keep being journaled and Stop saves them once the last requests are served.
+func (p *FileUsagePlugin) Halt() {
+	if p == nil {
+		return
+	}
+	p.haltOnce.Do(func() {
+		close(p.stopCh)
+		if p.started.Load() {
+			<-p.doneCh
+		}
+	})
+}
+
+// Stop halts the save worker, unless Halt already did, and performs a final save. A leader
+// then releases the lock so a follower takes over without waiting for the lease to run out.
+func (p *FileUsagePlugin) Stop() error {
+	if p == nil {
+		return nil
+	}
+	var err error
+	p.stopOnce.Do(func() {
+		p.Halt()
+		err = p.persist()
+		errorreport.CaptureError("usage-persistence", err)
+		p.journal.close()
+		if err == nil {
+			// The final save holds everything sealed; records that arrived after it stay in
+			// the journal for the next start.
+			p.journal.release(p.saveSeq.Load() + 1)
+		}
+		if p.election != nil {
+			p.election.release()
+		}
+		activeFilePlugin.CompareAndSwap(p, nil)
+	})
+	return err
+}
+
+// writeFileAtomic writes data to a temporary file in the target directory and moves it over path
+// with replaceFile, which also works on Windows when path already exists or is open for reading.
+// The temporary name carries a random suffix, so even writers outside the save worker never
+// share a file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	cleanup := func() { _ = os.Remove(tmpName) }
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		cleanup()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		cleanup()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		cleanup()
+		return err
+	}
+	if err = replaceFile(tmpName, path); err != nil {
+		cleanup()
+		return err
+	}
+	return nil
+}
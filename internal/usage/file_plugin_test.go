@@ -0,0 +1,255 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// recordLatencyP99 records usage through plugins while background runs in a loop, until at
+// least samples records and rounds background iterations are done or ten times samples
+// records are taken, and returns the 99th percentile time a single record took.
+func recordLatencyP99(plugins []coreusage.Plugin, samples int, rounds int64, background func()) time.Duration {
+	stop := make(chan struct{})
+	var iterations atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				background()
+				iterations.Add(1)
+			}
+		}
+	}()
+
+	ctx := context.Background()
+	latencies := make([]time.Duration, 0, samples)
+	for i := 0; len(latencies) < samples || (iterations.Load() < rounds && len(latencies) < 10*samples); i++ {
+		record := coreusage.Record{
+			APIKey: fmt.Sprintf("key-%d", i%4), Model: "gpt-5", RequestedAt: time.Now(),
+			Detail: coreusage.Detail{InputTokens: 10, OutputTokens: 20},
+		}
+		start := time.Now()
+		for _, plugin := range plugins {
+			plugin.HandleUsage(ctx, record)
+		}
+		latencies = append(latencies, time.Since(start))
+		if i%100 == 0 {
+			// Give the background loop a turn even with a single CPU.
+			runtime.Gosched()
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies[len(latencies)*99/100]
+}
+
+func TestFileUsagePluginSaveDoesNotBlockRecording(t *testing.T) {
+	if testing.Short() {
+		t.Skip("latency measurement")
+	}
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	stats := NewRequestStatistics()
+	base := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 50000; i++ {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey: fmt.Sprintf("key-%d", i%4), Model: fmt.Sprintf("model-%d", i%8), Source: "user@example.com",
+			RequestedAt: base.Add(time.Duration(i) * time.Second), Detail: coreusage.Detail{InputTokens: 100, OutputTokens: 50},
+		})
+	}
+	plugin := NewFileUsagePlugin(filepath.Join(t.TempDir(), "usage.json"), 0, stats)
+	plugin.quotas = NewQuotaTracker()
+	plugins := []coreusage.Plugin{&LoggerPlugin{stats: stats}, plugin}
+
+	// The baseline keeps the CPU just as busy encoding a snapshot, without going through
+	// Save, so the comparison isolates time spent waiting on the store.
+	encoded := stats.Snapshot()
+	baseline := recordLatencyP99(plugins, 5000, 5, func() { _, _ = json.Marshal(encoded) })
+
+	saves := 0
+	saving := recordLatencyP99(plugins, 5000, 5, func() {
+		if err := plugin.Save(); err != nil {
+			t.Errorf("Save: %v", err)
+		}
+		saves++
+	})
+	if limit := 3*baseline + 2*time.Millisecond; saving > limit {
+		t.Fatalf("p99 record latency %s while saving, %s baseline; want at most %s", saving, baseline, limit)
+	}
+	t.Logf("p99 record latency: %s baseline, %s with %d saves", baseline, saving, saves)
+}
+
+func TestFileUsagePluginCoalescesSaveRequests(t *testing.T) {
+	plugin := NewFileUsagePlugin(filepath.Join(t.TempDir(), "usage.json"), 0, NewRequestStatistics())
+	plugin.quotas = NewQuotaTracker()
+	var inFlight, maxInFlight, writes atomic.Int32
+	plugin.writeFile = func(path string, data []byte) error {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		writes.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return writeFileAtomic(path, data)
+	}
+	plugin.Start()
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			plugin.RequestSave()
+		}()
+	}
+	close(start)
+	wg.Wait()
+	if err := plugin.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	if got := maxInFlight.Load(); got != 1 {
+		t.Fatalf("%d saves overlapped, want saves to run one at a time", got)
+	}
+	// At most the save the triggers started, one coalesced follow-up and the final save.
+	if got := writes.Load(); got < 1 || got > 3 {
+		t.Fatalf("100 triggers caused %d writes, want between 1 and 3", got)
+	}
+	if err := plugin.Load(); err != nil {
+		t.Fatalf("Load after saves: %v", err)
+	}
+	matches, _ := filepath.Glob(plugin.path + ".tmp-*")
+	if len(matches) != 0 {
+		t.Fatalf("temporary files left behind: %v", matches)
+	}
+}
+
+// TestFileUsagePluginConcurrentLoadSaveAndRecord records, saves and reloads the same file
+// concurrently and expects both the store and the last saved file to hold every record
+// exactly once. Run it with -race.
+func TestFileUsagePluginConcurrentLoadSaveAndRecord(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	duration := 2 * time.Second
+	if testing.Short() {
+		duration = 200 * time.Millisecond
+	}
+	base := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	newRecord := func(i int64) coreusage.Record {
+		return coreusage.Record{
+			APIKey: fmt.Sprintf("key-%d", i%4), Model: fmt.Sprintf("model-%d", i%3),
+			RequestedAt: base.Add(time.Duration(i) * time.Millisecond), Detail: coreusage.Detail{InputTokens: 3, OutputTokens: 4},
+		}
+	}
+
+	// Seed the file with records the running store has never seen.
+	path := filepath.Join(t.TempDir(), "usage.json")
+	const seeded, maxRecorded = 500, 20000
+	seed := NewFileUsagePlugin(path, 0, NewRequestStatistics())
+	seed.quotas, seed.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+	for i := int64(0); i < seeded; i++ {
+		seed.stats.Record(context.Background(), newRecord(-1-i))
+	}
+	if err := seed.Save(); err != nil {
+		t.Fatalf("seed Save: %v", err)
+	}
+
+	stats := NewRequestStatistics()
+	plugin := NewFileUsagePlugin(path, 0, stats)
+	plugin.quotas, plugin.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+	plugins := []coreusage.Plugin{&LoggerPlugin{stats: stats}, plugin}
+	// As at startup, the file is loaded before anything saves over it; later loads race.
+	if err := plugin.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	stop := make(chan struct{})
+	var recorded atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				i := recorded.Add(1)
+				if i > maxRecorded {
+					recorded.Add(-1)
+					return
+				}
+				record := newRecord(i)
+				for _, p := range plugins {
+					p.HandleUsage(context.Background(), record)
+				}
+			}
+		}()
+	}
+	for _, op := range []func() error{plugin.Load, plugin.Save} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if err := op(); err != nil {
+					t.Errorf("persistence: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(duration)
+	close(stop)
+	wg.Wait()
+
+	want := seeded + recorded.Load()
+	if got := stats.Snapshot().TotalRequests; got != want {
+		t.Fatalf("store holds %d requests, want %d", got, want)
+	}
+	if err := plugin.Save(); err != nil {
+		t.Fatalf("final Save: %v", err)
+	}
+	restored := NewFileUsagePlugin(path, 0, NewRequestStatistics())
+	restored.quotas, restored.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+	if err := restored.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	snapshot := restored.stats.Snapshot()
+	if snapshot.TotalRequests != want || snapshot.TotalTokens != 7*want {
+		t.Fatalf("restored %d requests and %d tokens, want %d and %d", snapshot.TotalRequests, snapshot.TotalTokens, want, 7*want)
+	}
+}
@@ -0,0 +1,311 @@
+package usage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// journalSuffix names the journal next to the usage file. Each save seals the journal by
+// renaming it with "-<mark>" appended, the mark being the time of the seal in unix
+// nanoseconds, and starts a new one. The file records the mark of the save, so a replay
+// knows that segments up to it are covered by the file's quota counters.
+const journalSuffix = ".journal"
+
+// journalMaxLine bounds one journal line; longer lines are skipped on replay.
+const journalMaxLine = 1 << 20
+
+// journalEntry is one record in the journal: the detail as the statistics store holds it and
+// what the quota trackers need to count it again.
+type journalEntry struct {
+	API      string        `json:"api"`
+	Model    string        `json:"model"`
+	Detail   RequestDetail `json:"detail"`
+	Provider string        `json:"provider,omitempty"`
+	AuthID   string        `json:"auth_id,omitempty"`
+	APIKey   string        `json:"api_key,omitempty"`
+}
+
+// usageJournal appends every record to a file between saves, so that statistics survive a
+// crash or a kill that prevents the final save. Lines are written without fsync: they are
+// safe once the process dies, not when the machine does.
+type usageJournal struct {
+	path string
+
+	mu      sync.Mutex
+	file    *os.File
+	failing bool
+	// sealed are the segments closed by saves, with the save sequence that closed them;
+	// segments left by an earlier run have sequence zero.
+	sealed []sealedSegment
+}
+
+type sealedSegment struct {
+	path string
+	seq  uint64
+}
+
+// EnableJournal makes the plugin journal every record between saves and replay the journal
+// in Load. Call it before Load.
+func (p *FileUsagePlugin) EnableJournal() {
+	if p == nil || p.path == "" {
+		return
+	}
+	p.journal = &usageJournal{path: p.path + journalSuffix}
+}
+
+// append journals record with its client key written as keyMode tells diskKeyName and
+// diskQuotaKey.
+func (j *usageJournal) append(ctx context.Context, record coreusage.Record, keyMode string) {
+	if j == nil {
+		return
+	}
+	entry := newPendingDetail(ctx, record)
+	line, err := json.Marshal(journalEntry{
+		API: diskKeyName(keyMode, entry.apiName), Model: entry.modelName, Detail: entry.detail,
+		Provider: record.Provider, AuthID: record.AuthID, APIKey: diskQuotaKey(keyMode, record.APIKey),
+	})
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		j.file, err = os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	}
+	if err == nil {
+		_, err = j.file.Write(line)
+	}
+	if err != nil {
+		if !j.failing {
+			log.Warnf("usage persistence: journal %s cannot be written, statistics since the last save are not crash-safe: %v", j.path, err)
+		}
+		j.failing = true
+		return
+	}
+	if j.failing {
+		log.Infof("usage persistence: journal %s is written again", j.path)
+		j.failing = false
+	}
+}
+
+// seal closes the journal for the save with sequence seq and returns the mark of the save;
+// records arriving from now on go to a new journal. Without a journal the mark is zero.
+func (j *usageJournal) seal(seq uint64) int64 {
+	if j == nil {
+		return 0
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	mark := time.Now().UnixNano()
+	if j.file == nil {
+		return mark
+	}
+	_ = j.file.Close()
+	j.file = nil
+	segment := segmentPath(j.path, mark)
+	if err := os.Rename(j.path, segment); err != nil {
+		// The records stay in the journal for a later save to seal, so a replay would count
+		// them towards quotas again.
+		log.Warnf("usage persistence: seal journal %s: %v", j.path, err)
+		return mark
+	}
+	j.sealed = append(j.sealed, sealedSegment{path: segment, seq: seq})
+	return mark
+}
+
+func segmentPath(path string, mark int64) string {
+	return path + "-" + strconv.FormatInt(mark, 10)
+}
+
+// release removes the segments sealed before the save with sequence seq, which succeeded.
+// The segment that save sealed is kept until the next one succeeds: a record can be journaled
+// just before the snapshot and reach the statistics only after it.
+func (j *usageJournal) release(seq uint64) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	kept := j.sealed[:0]
+	for _, segment := range j.sealed {
+		if segment.seq >= seq {
+			kept = append(kept, segment)
+			continue
+		}
+		if err := os.Remove(segment.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("usage persistence: remove journal %s: %v", segment.path, err)
+			kept = append(kept, segment)
+		}
+	}
+	j.sealed = kept
+}
+
+// truncate removes the journal and every sealed segment, whose records a reset of the
+// statistics dropped. Records arriving from now on go to a new journal.
+func (j *usageJournal) truncate() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file != nil {
+		_ = j.file.Close()
+		j.file = nil
+	}
+	if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("usage persistence: remove journal %s: %v", j.path, err)
+	}
+	kept := j.sealed[:0]
+	for _, segment := range j.sealed {
+		if err := os.Remove(segment.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("usage persistence: remove journal %s: %v", segment.path, err)
+			kept = append(kept, segment)
+		}
+	}
+	j.sealed = kept
+}
+
+func (j *usageJournal) close() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file != nil {
+		_ = j.file.Close()
+		j.file = nil
+	}
+}
+
+// replayJournal merges the records journaled by an earlier run. Details the loaded files
+// already hold are skipped by MergeSnapshot; quota counters are only counted again for the
+// segments sealed after the newest loaded file, whose counters include the earlier ones. The
+// replayed segments are removed by the first successful save.
+func (p *FileUsagePlugin) replayJournal() error {
+	j := p.journal
+	if j == nil {
+		return nil
+	}
+	segments, err := filepath.Glob(j.path + "-*")
+	if err != nil {
+		return fmt.Errorf("usage persistence: list journals: %w", err)
+	}
+	sort.Strings(segments)
+	if _, errStat := os.Stat(j.path); errStat == nil {
+		// The journal the earlier run was writing; seal it so this run starts a new one.
+		segment := segmentPath(j.path, time.Now().UnixNano())
+		if errRename := os.Rename(j.path, segment); errRename != nil {
+			return fmt.Errorf("usage persistence: seal journal %s: %w", j.path, errRename)
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+
+	var entries []pendingDetail
+	var quotaRecords []coreusage.Record
+	skipped := 0
+	for _, segment := range segments {
+		read, bad, errRead := readJournal(segment)
+		if errRead != nil {
+			return fmt.Errorf("usage persistence: read journal %s: %w", segment, errRead)
+		}
+		skipped += bad
+		mark, _ := strconv.ParseInt(strings.TrimPrefix(segment, j.path+"-"), 10, 64)
+		for _, entry := range read {
+			entries = append(entries, pendingDetail{apiName: entry.API, modelName: entry.Model, detail: entry.Detail})
+			if mark > p.loadedMark {
+				quotaRecords = append(quotaRecords, entry.record())
+			}
+		}
+	}
+	p.stateMu.Lock()
+	var result MergeResult
+	if statisticsEnabled.Load() {
+		result = p.stats.MergeSnapshot(snapshotOf(entries))
+	}
+	for _, record := range quotaRecords {
+		p.quotas.HandleUsage(context.Background(), record)
+		p.keyQuota.HandleUsage(context.Background(), record)
+	}
+	p.stateMu.Unlock()
+
+	j.mu.Lock()
+	for _, segment := range segments {
+		j.sealed = append(j.sealed, sealedSegment{path: segment})
+	}
+	j.mu.Unlock()
+	if result.Added > 0 || len(quotaRecords) > 0 || skipped > 0 {
+		log.Warnf("usage persistence: replayed the journal of an unclean shutdown: %d of %d records restored, %d counted towards quotas, %d unreadable lines skipped",
+			result.Added, len(entries), len(quotaRecords), skipped)
+	}
+	p.dirty.Store(true)
+	return nil
+}
+
+// readJournal returns the entries of a journal segment and how many lines could not be read.
+// A crash can cut the last line short, so unreadable lines are skipped rather than fatal.
+func readJournal(path string) ([]journalEntry, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+	defer func() { _ = f.Close() }()
+	var entries []journalEntry
+	bad := 0
+	reader := bufio.NewReader(f)
+	for {
+		line, errLine := reader.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			var entry journalEntry
+			if len(line) > journalMaxLine || json.Unmarshal([]byte(line), &entry) != nil || entry.API == "" {
+				bad++
+			} else {
+				entries = append(entries, entry)
+			}
+		}
+		if errLine != nil {
+			break
+		}
+	}
+	return entries, bad, nil
+}
+
+// record rebuilds what the quota trackers count of the journaled request.
+func (e journalEntry) record() coreusage.Record {
+	tokens := e.Detail.Tokens
+	return coreusage.Record{
+		Provider:    e.Provider,
+		Model:       e.Model,
+		APIKey:      e.APIKey,
+		AuthID:      e.AuthID,
+		RequestedAt: e.Detail.Timestamp,
+		Failed:      e.Detail.Failed,
+		RequestType: e.Detail.RequestType,
+		Detail: coreusage.Detail{
+			InputTokens:     tokens.InputTokens,
+			OutputTokens:    tokens.OutputTokens,
+			ReasoningTokens: tokens.ReasoningTokens,
+			CachedTokens:    tokens.CachedTokens,
+			TotalTokens:     tokens.TotalTokens,
+		},
+	}
+}
@@ -0,0 +1,157 @@
+package usage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// journalRun starts a plugin over fresh stores, as a restarted process would, and loads path.
+func journalRun(t *testing.T, path string) *FileUsagePlugin {
+	t.Helper()
+	plugin := NewFileUsagePlugin(path, 0, NewRequestStatistics())
+	plugin.quotas, plugin.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+	plugin.keyQuota.SetLimits([]config.APIKeySettings{{APIKey: "client", Quota: &config.APIKeyQuota{DailyTokens: 1000}}})
+	plugin.EnableJournal()
+	if err := plugin.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return plugin
+}
+
+// publish delivers a record the way the usage manager does: statistics first, then quotas
+// and persistence.
+func publish(plugin *FileUsagePlugin, at time.Time, tokens int64) {
+	record := coreusage.Record{APIKey: "client", Model: "gpt-5", RequestedAt: at, Detail: coreusage.Detail{TotalTokens: tokens}}
+	plugin.stats.Record(context.Background(), record)
+	plugin.keyQuota.HandleUsage(context.Background(), record)
+	plugin.HandleUsage(context.Background(), record)
+}
+
+func TestFileUsagePluginReplaysJournalAfterCrash(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+	path := filepath.Join(t.TempDir(), "usage.json")
+	now := time.Now()
+
+	first := journalRun(t, path)
+	publish(first, now.Add(-time.Minute), 100)
+	if err := first.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	publish(first, now, 20)
+	// The process dies before the next save; only the journal holds the second request.
+	first.journal.close()
+
+	check := func(plugin *FileUsagePlugin, when string) {
+		t.Helper()
+		if got := plugin.stats.Snapshot().TotalRequests; got != 2 {
+			t.Fatalf("%s: store holds %d requests, want 2", when, got)
+		}
+		if status, _ := plugin.keyQuota.Status("client", now); status.Daily.Used != 120 {
+			t.Fatalf("%s: daily quota used = %d, want 120 counted once", when, status.Daily.Used)
+		}
+	}
+	second := journalRun(t, path)
+	check(second, "after the crash")
+	if err := second.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if segments, _ := filepath.Glob(path + journalSuffix + "*"); len(segments) != 0 {
+		t.Fatalf("journals left after a clean shutdown: %v", segments)
+	}
+	check(journalRun(t, path), "after a clean restart")
+}
+
+func TestFileUsagePluginResetTruncatesJournal(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+	path := filepath.Join(t.TempDir(), "usage.json")
+	now := time.Now()
+
+	first := journalRun(t, path)
+	publish(first, now.Add(-time.Minute), 100)
+	if err := first.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	publish(first, now, 20)
+	if cleared := first.resetStatistics(); cleared != 2 {
+		t.Fatalf("reset cleared %d requests, want 2", cleared)
+	}
+	publish(first, now, 5)
+	// The process dies before the save the reset asked for.
+	first.journal.close()
+
+	second := journalRun(t, path)
+	defer second.journal.close()
+	// The saved file still holds the first request; the journal only the one after the reset.
+	if got := second.stats.Snapshot().TotalRequests; got != 2 {
+		t.Fatalf("store holds %d requests after the crash, want the saved one and the one after the reset", got)
+	}
+	if segments, _ := filepath.Glob(path + journalSuffix + "-*"); len(segments) != 1 {
+		t.Fatalf("journal segments after replay = %v, want only the post-reset journal", segments)
+	}
+}
+
+func TestFileUsagePluginKeepsJournalUntilNextSave(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+	path := filepath.Join(t.TempDir(), "usage.json")
+
+	plugin := journalRun(t, path)
+	defer plugin.journal.close()
+	publish(plugin, time.Now(), 1)
+	plugin.writeFile = func(string, []byte) error { return os.ErrPermission }
+	if err := plugin.Save(); err == nil {
+		t.Fatalf("Save succeeded with a failing write")
+	}
+	plugin.writeFile = writeFileAtomic
+	publish(plugin, time.Now(), 1)
+	if err := plugin.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	// The segment sealed by the failed save goes with the successful one, the segment sealed
+	// by that save with the next one.
+	if segments, _ := filepath.Glob(path + journalSuffix + "-*"); len(segments) != 1 {
+		t.Fatalf("journal segments after a failed and a successful save: %v, want the last one", segments)
+	}
+	if err := plugin.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if segments, _ := filepath.Glob(path + journalSuffix + "-*"); len(segments) != 0 {
+		t.Fatalf("journal segments left after two successful saves: %v", segments)
+	}
+}
+
+func TestFileUsagePluginHaltDefersTheFinalSave(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+	path := filepath.Join(t.TempDir(), "usage.json")
+
+	plugin := journalRun(t, path)
+	plugin.Start()
+	plugin.Halt()
+	// Requests served while the service drains still reach the final save.
+	publish(plugin, time.Now(), 10)
+	plugin.RequestSave()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("a halted worker saved: %v", err)
+	}
+	if err := plugin.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	reloaded := journalRun(t, path)
+	defer reloaded.journal.close()
+	if got := reloaded.stats.Snapshot().TotalRequests; got != 1 {
+		t.Fatalf("final save holds %d requests, want the one served after Halt", got)
+	}
+}
@@ -0,0 +1,105 @@
+package usage
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// hashedKeyPrefix marks a client key written to disk as its SHA-256.
+const hashedKeyPrefix = "sha256:"
+
+func hashedKeyID(apiKey string) string { return hashedKeyPrefix + hashAPIKey(apiKey) }
+
+// SetKeyIdentifiers sets how client API keys are written to the file, its archives and the
+// journal: one of config.KeyIdentifiersRaw, KeyIdentifiersHash and KeyIdentifiersTruncate.
+// The statistics in memory keep the keys; statistics loaded back are counted under the
+// written identifiers, hashed ones until their key is used again. Call it before Load.
+func (p *FileUsagePlugin) SetKeyIdentifiers(mode string) {
+	if p == nil {
+		return
+	}
+	p.keyMode = mode
+}
+
+// clientKeyName reports whether apiName is a client key as recorded, rather than the route of
+// a request made without one, the overflow bucket or a key an earlier save hashed.
+func clientKeyName(apiName string) bool {
+	return apiName != "" && apiName != OverflowKey && apiName != "unknown" &&
+		!strings.HasPrefix(apiName, "/") && !strings.Contains(apiName, " ") &&
+		!strings.HasPrefix(apiName, hashedKeyPrefix)
+}
+
+// diskKeyName returns the name the statistics of apiName are written under. Names that are
+// not client keys, and names an earlier save already wrote, are kept as they are.
+func diskKeyName(mode, apiName string) string {
+	if !clientKeyName(apiName) {
+		return apiName
+	}
+	switch mode {
+	case config.KeyIdentifiersHash:
+		return hashedKeyID(apiName)
+	case config.KeyIdentifiersTruncate:
+		if strings.Contains(apiName, "...") {
+			return apiName
+		}
+		return util.HideAPIKey(apiName)
+	}
+	return apiName
+}
+
+// adoptHashed moves the statistics loaded under the hashed form of apiName to apiName when
+// that key is first seen, so a key written hashed is not listed twice once it is used again.
+func (a *statsAggregate) adoptHashed(apiName string) {
+	if _, ok := a.apis[apiName]; ok || !clientKeyName(apiName) {
+		return
+	}
+	hashed := hashedKeyID(apiName)
+	if api, ok := a.apis[hashed]; ok {
+		a.apis[apiName] = api
+		delete(a.apis, hashed)
+	}
+}
+
+// diskQuotaKey returns the key a quota counter is written under. Load matches the counter
+// back to its configured key, so unless keys are written raw it is hashed, even when usage is
+// written truncated. The global-quota counter is not a key and is kept.
+func diskQuotaKey(mode, apiKey string) string {
+	if mode == "" || mode == config.KeyIdentifiersRaw || apiKey == "" || apiKey == GlobalQuotaKey ||
+		strings.HasPrefix(apiKey, hashedKeyPrefix) {
+		return apiKey
+	}
+	return hashedKeyID(apiKey)
+}
+
+// diskUsage returns the details of snapshot under the names diskKeyName gives them; keys
+// whose truncated forms coincide have their details merged.
+func diskUsage(mode string, snapshot StatisticsSnapshot) StatisticsSnapshot {
+	if mode == "" || mode == config.KeyIdentifiersRaw {
+		return snapshot
+	}
+	out := StatisticsSnapshot{APIs: make(map[string]APISnapshot, len(snapshot.APIs))}
+	for apiName, api := range snapshot.APIs {
+		name := diskKeyName(mode, apiName)
+		merged, ok := out.APIs[name]
+		if !ok {
+			merged = APISnapshot{Models: make(map[string]ModelSnapshot, len(api.Models))}
+		}
+		for modelName, model := range api.Models {
+			into := merged.Models[modelName]
+			into.Details = append(into.Details, model.Details...)
+			merged.Models[modelName] = into
+		}
+		out.APIs[name] = merged
+	}
+	return out
+}
+
+// diskKeyQuotas returns snapshots with their keys as diskQuotaKey writes them.
+func diskKeyQuotas(mode string, snapshots []KeyQuotaSnapshot) []KeyQuotaSnapshot {
+	for i := range snapshots {
+		snapshots[i].APIKey = diskQuotaKey(mode, snapshots[i].APIKey)
+	}
+	return snapshots
+}
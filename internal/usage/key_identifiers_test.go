@@ -0,0 +1,85 @@
+package usage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+func TestFileUsagePluginHashesKeysOnDisk(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+	path := filepath.Join(t.TempDir(), "usage.json")
+	now := time.Now()
+
+	run := func() *FileUsagePlugin {
+		plugin := NewFileUsagePlugin(path, 0, NewRequestStatistics())
+		plugin.quotas, plugin.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+		plugin.keyQuota.SetLimits([]config.APIKeySettings{{APIKey: "client", Quota: &config.APIKeyQuota{DailyTokens: 1000}}})
+		plugin.SetKeyIdentifiers(config.KeyIdentifiersHash)
+		plugin.EnableJournal()
+		if err := plugin.Load(); err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		return plugin
+	}
+
+	first := run()
+	publish(first, now.Add(-time.Minute), 100)
+	if _, ok := first.stats.Snapshot().APIs["client"]; !ok {
+		t.Fatalf("store does not attribute the request to the key")
+	}
+	if err := first.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	publish(first, now, 20)
+	first.journal.close()
+	for _, file := range []string{path, path + journalSuffix} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		if bytes.Contains(data, []byte(`"client"`)) || !bytes.Contains(data, []byte(hashedKeyID("client"))) {
+			t.Fatalf("%s does not hold the key hashed: %s", filepath.Base(file), data)
+		}
+	}
+
+	second := run()
+	if got := second.stats.Snapshot().APIs[hashedKeyID("client")].TotalRequests; got != 2 {
+		t.Fatalf("loaded %d requests under the hashed key, want 2", got)
+	}
+	if status, _ := second.keyQuota.Status("client", now); status.Daily.Used != 120 {
+		t.Fatalf("daily quota used = %d, want the saved and journaled 120", status.Daily.Used)
+	}
+
+	// The next request with the key takes over what was loaded under its hash.
+	publish(second, now, 5)
+	apis := second.stats.Snapshot().APIs
+	if _, ok := apis[hashedKeyID("client")]; ok || apis["client"].TotalRequests != 3 {
+		t.Fatalf("after a new request the store holds %v, want the key once with 3 requests", apis)
+	}
+	second.journal.close()
+}
+
+func TestDiskUsageMergesTruncatedKeys(t *testing.T) {
+	detail := RequestDetail{Timestamp: time.Now()}
+	snapshot := StatisticsSnapshot{APIs: map[string]APISnapshot{
+		"sk-aaaa-1111-zzzz": {Models: map[string]ModelSnapshot{"gpt-5": {Details: []RequestDetail{detail}}}},
+		"sk-aaaa-2222-zzzz": {Models: map[string]ModelSnapshot{"gpt-5": {Details: []RequestDetail{detail}}}},
+		"POST /v1/chat":     {Models: map[string]ModelSnapshot{"gpt-5": {Details: []RequestDetail{detail}}}},
+	}}
+	out := diskUsage(config.KeyIdentifiersTruncate, snapshot)
+	truncated := util.HideAPIKey("sk-aaaa-1111-zzzz")
+	if len(out.APIs) != 2 || len(out.APIs[truncated].Models["gpt-5"].Details) != 2 || len(out.APIs["POST /v1/chat"].Models) != 1 {
+		t.Fatalf("disk usage = %+v, want both keys under %q and the route kept", out.APIs, truncated)
+	}
+	if again := diskKeyName(config.KeyIdentifiersTruncate, truncated); again != truncated {
+		t.Fatalf("truncating %q again gave %q", truncated, again)
+	}
+}
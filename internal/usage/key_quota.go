@@ -0,0 +1,524 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/notify"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+var defaultKeyQuotaTracker = NewKeyQuotaTracker()
+
+func init() {
+	coreusage.RegisterPlugin(defaultKeyQuotaTracker)
+}
+
+// GlobalQuotaKey is the key the global-quota is tracked and reported under; every request
+// with usage is charged to it as well as to its own key.
+const GlobalQuotaKey = "*"
+
+// GetKeyQuotaTracker returns the shared client key quota tracker.
+func GetKeyQuotaTracker() *KeyQuotaTracker { return defaultKeyQuotaTracker }
+
+// KeyQuotaTracker counts the tokens each client API key with a quota consumes per day and per
+// month, and its estimated spend per month. It implements coreusage.Plugin and counts whether or not usage statistics are
+// enabled, so that turning statistics off never lifts a budget.
+type KeyQuotaTracker struct {
+	mu       sync.Mutex
+	limits   map[string]config.APIKeyQuota
+	counters map[string]*keyQuotaCounter
+	// global is the global-quota, kept to re-add it when the key quotas are replaced.
+	global *config.APIKeyQuota
+	// priceFor prices the tokens charged against cost caps; nil prices nothing.
+	priceFor func(model string) (config.ModelPrice, bool)
+	// shared holds the tokens and top-ups counted since the last push to a RedisUsageStore;
+	// nil while no store shares the counters.
+	shared *usageDelta
+}
+
+type keyQuotaCounter struct {
+	day         string
+	dayTokens   int64
+	dayTopUp    int64
+	month       string
+	monthTokens int64
+	monthTopUp  int64
+	// monthCostMicros is the month's estimated spend in millionths of a dollar.
+	monthCostMicros int64
+}
+
+// KeyQuotaSnapshot is the persisted form of one key's counters.
+type KeyQuotaSnapshot struct {
+	APIKey      string `json:"api_key"`
+	Day         string `json:"day"`
+	DayTokens   int64  `json:"day_tokens"`
+	DayTopUp    int64  `json:"day_top_up,omitempty"`
+	Month       string `json:"month"`
+	MonthTokens int64  `json:"month_tokens"`
+	MonthTopUp  int64  `json:"month_top_up,omitempty"`
+	// MonthCostMicros is the month's estimated spend in millionths of a dollar.
+	MonthCostMicros int64 `json:"month_cost_micros,omitempty"`
+}
+
+// KeyQuotaPeriod is a key's position against one budget.
+type KeyQuotaPeriod struct {
+	Limit     int64     `json:"limit"`
+	TopUp     int64     `json:"top_up,omitempty"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// KeyQuotaCost is a key's position against its monthly cost cap, in US dollars.
+type KeyQuotaCost struct {
+	LimitUSD     float64   `json:"limit_usd"`
+	UsedUSD      float64   `json:"used_usd"`
+	RemainingUSD float64   `json:"remaining_usd"`
+	ResetsAt     time.Time `json:"resets_at"`
+}
+
+// KeyQuotaStatus is a key's position against its budgets; a nil period has no cap.
+type KeyQuotaStatus struct {
+	APIKey      string          `json:"api_key"`
+	Daily       *KeyQuotaPeriod `json:"daily,omitempty"`
+	Monthly     *KeyQuotaPeriod `json:"monthly,omitempty"`
+	MonthlyCost *KeyQuotaCost   `json:"monthly_cost,omitempty"`
+}
+
+// Exceeded reports whether a budget is used up and, if so, when every exhausted budget has
+// reset.
+func (s KeyQuotaStatus) Exceeded() (bool, time.Time) {
+	var resetsAt time.Time
+	for _, period := range []*KeyQuotaPeriod{s.Daily, s.Monthly} {
+		if period != nil && period.Remaining <= 0 && (resetsAt.IsZero() || period.ResetsAt.After(resetsAt)) {
+			resetsAt = period.ResetsAt
+		}
+	}
+	if cost := s.MonthlyCost; cost != nil && cost.RemainingUSD <= 0 && (resetsAt.IsZero() || cost.ResetsAt.After(resetsAt)) {
+		resetsAt = cost.ResetsAt
+	}
+	return !resetsAt.IsZero(), resetsAt
+}
+
+// NewKeyQuotaTracker constructs an empty tracker with no quotas configured.
+func NewKeyQuotaTracker() *KeyQuotaTracker {
+	return &KeyQuotaTracker{
+		limits:   make(map[string]config.APIKeyQuota),
+		counters: make(map[string]*keyQuotaCounter),
+	}
+}
+
+// SetLimits replaces the configured key quotas, typically on config load or reload. Counters
+// of keys that lost their quota are kept so that restoring the quota does not refill it.
+func (t *KeyQuotaTracker) SetLimits(settings []config.APIKeySettings) {
+	if t == nil {
+		return
+	}
+	limits := make(map[string]config.APIKeyQuota, len(settings))
+	for _, entry := range settings {
+		if entry.APIKey != "" && entry.Quota.Enabled() {
+			limits[entry.APIKey] = *entry.Quota
+		}
+	}
+	t.mu.Lock()
+	if t.global.Enabled() {
+		limits[GlobalQuotaKey] = *t.global
+	}
+	t.limits = limits
+	t.adoptHashedLocked()
+	t.mu.Unlock()
+}
+
+// SetGlobalLimit replaces the global-quota; nil or a quota capping nothing removes it. Its
+// counters are kept like those of a key that lost its quota.
+func (t *KeyQuotaTracker) SetGlobalLimit(quota *config.APIKeyQuota) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.global = nil
+	delete(t.limits, GlobalQuotaKey)
+	if quota.Enabled() {
+		global := *quota
+		t.global = &global
+		t.limits[GlobalQuotaKey] = global
+	}
+}
+
+// SetPricing sets how the tokens charged against monthly cost caps are priced, typically
+// Config.PriceFor. Until it is set, or for models it has no price for, usage costs nothing.
+func (t *KeyQuotaTracker) SetPricing(priceFor func(model string) (config.ModelPrice, bool)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.priceFor = priceFor
+	t.mu.Unlock()
+}
+
+// Tracks reports whether the usage of apiKey counts towards a quota, its own or the global one.
+func (t *KeyQuotaTracker) Tracks(apiKey string) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.limits[GlobalQuotaKey]; ok {
+		return true
+	}
+	_, ok := t.limits[apiKey]
+	return ok && apiKey != ""
+}
+
+// HandleUsage implements coreusage.Plugin by charging the record's tokens and estimated cost
+// to its key and to the global-quota.
+func (t *KeyQuotaTracker) HandleUsage(_ context.Context, record coreusage.Record) {
+	if t == nil || record.RequestType == coreusage.RequestTypeCountTokens {
+		return
+	}
+	detail := normaliseDetail(record.Detail)
+	if detail.TotalTokens <= 0 {
+		return
+	}
+	now := record.RequestedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var costMicros int64
+	if t.priceFor != nil {
+		if price, ok := t.priceFor(record.Model); ok {
+			costMicros = usdToMicros(detailCost(price, detail))
+		}
+	}
+	keys := []string{GlobalQuotaKey}
+	if apiKey := t.resolveLocked(record.APIKey); apiKey != "" && apiKey != GlobalQuotaKey {
+		keys = append(keys, apiKey)
+	}
+	for _, apiKey := range keys {
+		if quota, ok := t.limits[apiKey]; ok {
+			t.chargeLocked(apiKey, quota, detail.TotalTokens, costMicros, now)
+		}
+	}
+}
+
+// chargeLocked adds tokens and costMicros to the key's counter and warns about every budget
+// the charge takes past quota.WarnPercent. The caller holds t.mu.
+func (t *KeyQuotaTracker) chargeLocked(apiKey string, quota config.APIKeyQuota, tokens, costMicros int64, now time.Time) {
+	counter := t.counterLocked(apiKey, quota, now)
+	before := *counter
+	counter.dayTokens += tokens
+	counter.monthTokens += tokens
+	counter.monthCostMicros += costMicros
+	if t.shared != nil {
+		t.shared.incr(redisKeyQuotaDayTokens+counter.day, apiKey, tokens)
+		t.shared.incr(redisKeyQuotaMonthTokens+counter.month, apiKey, tokens)
+		if costMicros > 0 {
+			t.shared.incr(redisKeyQuotaMonthCost+counter.month, apiKey, costMicros)
+		}
+	}
+	if quota.WarnPercent <= 0 {
+		return
+	}
+	var crossed []string
+	if limit := quota.DailyTokens + counter.dayTopUp; quota.DailyTokens > 0 && crossesWarning(quota.WarnPercent, limit, before.dayTokens, counter.dayTokens) {
+		crossed = append(crossed, fmt.Sprintf("%d of %d daily tokens", counter.dayTokens, limit))
+	}
+	if limit := quota.MonthlyTokens + counter.monthTopUp; quota.MonthlyTokens > 0 && crossesWarning(quota.WarnPercent, limit, before.monthTokens, counter.monthTokens) {
+		crossed = append(crossed, fmt.Sprintf("%d of %d monthly tokens", counter.monthTokens, limit))
+	}
+	if crossesWarning(quota.WarnPercent, usdToMicros(quota.MonthlyCostUSD), before.monthCostMicros, counter.monthCostMicros) {
+		crossed = append(crossed, fmt.Sprintf("$%.2f of the $%.2f monthly budget", microsToUSD(counter.monthCostMicros), quota.MonthlyCostUSD))
+	}
+	account := "all client keys"
+	if apiKey != GlobalQuotaKey {
+		account = util.HideAPIKey(apiKey)
+	}
+	for _, used := range crossed {
+		log.Warnf("key quota: %s used %s, past the %d%% warning threshold", account, used, quota.WarnPercent)
+		notify.Publish(notify.Event{
+			Type:    config.NotifyUsageAlert,
+			Account: account,
+			Message: fmt.Sprintf("used %s; requests are refused once it is used up", used),
+		})
+	}
+}
+
+// crossesWarning reports whether usage going from before to after passes percent of limit.
+// A limit of zero has no threshold.
+func crossesWarning(percent int, limit, before, after int64) bool {
+	if limit <= 0 {
+		return false
+	}
+	threshold := int64(math.Ceil(float64(limit) * float64(percent) / 100))
+	return before < threshold && after >= threshold
+}
+
+func usdToMicros(usd float64) int64 { return int64(math.Round(usd * 1e6)) }
+
+func microsToUSD(micros int64) float64 { return float64(micros) / 1e6 }
+
+// counterLocked returns the key's counter rolled over to the periods containing now. The
+// caller holds t.mu.
+func (t *KeyQuotaTracker) counterLocked(apiKey string, quota config.APIKeyQuota, now time.Time) *keyQuotaCounter {
+	day, month := keyQuotaDay(quota, now), keyQuotaMonth(quota, now)
+	counter := t.counters[apiKey]
+	if counter == nil {
+		counter = &keyQuotaCounter{day: day, month: month}
+		t.counters[apiKey] = counter
+	}
+	if counter.day != day {
+		counter.day, counter.dayTokens, counter.dayTopUp = day, 0, 0
+	}
+	if counter.month != month {
+		counter.month, counter.monthTokens, counter.monthTopUp, counter.monthCostMicros = month, 0, 0, 0
+	}
+	return counter
+}
+
+// Status reports the key's position against its budgets. ok is false when it has no quota.
+func (t *KeyQuotaTracker) Status(apiKey string, now time.Time) (KeyQuotaStatus, bool) {
+	if t == nil || apiKey == "" {
+		return KeyQuotaStatus{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.statusLocked(apiKey, now)
+}
+
+func (t *KeyQuotaTracker) statusLocked(apiKey string, now time.Time) (KeyQuotaStatus, bool) {
+	quota, ok := t.limits[apiKey]
+	if !ok {
+		return KeyQuotaStatus{}, false
+	}
+	counter := t.counterLocked(apiKey, quota, now)
+	status := KeyQuotaStatus{APIKey: apiKey}
+	if quota.DailyTokens > 0 {
+		status.Daily = newKeyQuotaPeriod(quota.DailyTokens, counter.dayTopUp, counter.dayTokens, keyQuotaDayReset(quota, now))
+	}
+	if quota.MonthlyTokens > 0 {
+		status.Monthly = newKeyQuotaPeriod(quota.MonthlyTokens, counter.monthTopUp, counter.monthTokens, keyQuotaMonthReset(quota, now))
+	}
+	if quota.MonthlyCostUSD > 0 {
+		used := microsToUSD(counter.monthCostMicros)
+		status.MonthlyCost = &KeyQuotaCost{
+			LimitUSD:     quota.MonthlyCostUSD,
+			UsedUSD:      used,
+			RemainingUSD: max(quota.MonthlyCostUSD-used, 0),
+			ResetsAt:     keyQuotaMonthReset(quota, now),
+		}
+	}
+	return status, true
+}
+
+func newKeyQuotaPeriod(limit, topUp, used int64, resetsAt time.Time) *KeyQuotaPeriod {
+	return &KeyQuotaPeriod{
+		Limit:     limit,
+		TopUp:     topUp,
+		Used:      used,
+		Remaining: max(limit+topUp-used, 0),
+		ResetsAt:  resetsAt,
+	}
+}
+
+// Statuses reports every key with a quota, ordered by key.
+func (t *KeyQuotaTracker) Statuses(now time.Time) []KeyQuotaStatus {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]KeyQuotaStatus, 0, len(t.limits))
+	for apiKey := range t.limits {
+		if status, ok := t.statusLocked(apiKey, now); ok {
+			out = append(out, status)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].APIKey < out[j].APIKey })
+	return out
+}
+
+// TopUp grants the key extra tokens for the current day and month. The grants lapse when
+// their period resets. ok is false when the key has no quota.
+func (t *KeyQuotaTracker) TopUp(apiKey string, dailyTokens, monthlyTokens int64, now time.Time) (KeyQuotaStatus, bool) {
+	if t == nil {
+		return KeyQuotaStatus{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	quota, ok := t.limits[apiKey]
+	if !ok {
+		return KeyQuotaStatus{}, false
+	}
+	counter := t.counterLocked(apiKey, quota, now)
+	counter.dayTopUp += dailyTokens
+	counter.monthTopUp += monthlyTokens
+	if t.shared != nil {
+		t.shared.incr(redisKeyQuotaDayTopUp+counter.day, apiKey, dailyTokens)
+		t.shared.incr(redisKeyQuotaMonthTopUp+counter.month, apiKey, monthlyTokens)
+	}
+	log.Infof("key quota: topped up %s by %d daily and %d monthly tokens", util.HideAPIKey(apiKey), dailyTokens, monthlyTokens)
+	return t.statusLocked(apiKey, now)
+}
+
+// CarryOver gives newKey the quota of oldKey and what it has used of it so far, so that
+// rotating a key does not refill its budget. Unless keepOld, oldKey's quota and counters are
+// dropped; otherwise the two keys count apart from then on.
+func (t *KeyQuotaTracker) CarryOver(oldKey, newKey string, keepOld bool) {
+	if t == nil || oldKey == "" || newKey == "" || oldKey == newKey {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if quota, ok := t.limits[oldKey]; ok {
+		t.limits[newKey] = quota
+	}
+	if counter := t.counters[oldKey]; counter != nil {
+		carried := *counter
+		t.counters[newKey] = &carried
+		if t.shared != nil {
+			t.shared.incr(redisKeyQuotaDayTokens+carried.day, newKey, carried.dayTokens)
+			t.shared.incr(redisKeyQuotaDayTopUp+carried.day, newKey, carried.dayTopUp)
+			t.shared.incr(redisKeyQuotaMonthTokens+carried.month, newKey, carried.monthTokens)
+			t.shared.incr(redisKeyQuotaMonthTopUp+carried.month, newKey, carried.monthTopUp)
+			t.shared.incr(redisKeyQuotaMonthCost+carried.month, newKey, carried.monthCostMicros)
+		}
+	}
+	if !keepOld {
+		delete(t.limits, oldKey)
+		delete(t.counters, oldKey)
+	}
+}
+
+// Snapshot returns the counters for persistence.
+func (t *KeyQuotaTracker) Snapshot() []KeyQuotaSnapshot {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]KeyQuotaSnapshot, 0, len(t.counters))
+	for apiKey, counter := range t.counters {
+		out = append(out, KeyQuotaSnapshot{
+			APIKey:          apiKey,
+			Day:             counter.day,
+			DayTokens:       counter.dayTokens,
+			DayTopUp:        counter.dayTopUp,
+			Month:           counter.month,
+			MonthTokens:     counter.monthTokens,
+			MonthTopUp:      counter.monthTopUp,
+			MonthCostMicros: counter.monthCostMicros,
+		})
+	}
+	return out
+}
+
+// Restore merges persisted counters. For a period both sides cover, the larger usage wins, so
+// a restart never refills a budget. Counters persisted under a hashed key are merged into the
+// configured key with that hash; see SetKeyIdentifiers.
+func (t *KeyQuotaTracker) Restore(snapshots []KeyQuotaSnapshot) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, snap := range snapshots {
+		if snap.APIKey == "" {
+			continue
+		}
+		t.mergeLocked(t.resolveLocked(snap.APIKey), snap)
+	}
+}
+
+func (t *KeyQuotaTracker) mergeLocked(apiKey string, snap KeyQuotaSnapshot) {
+	counter := t.counters[apiKey]
+	if counter == nil {
+		counter = &keyQuotaCounter{}
+		t.counters[apiKey] = counter
+	}
+	switch {
+	case snap.Day > counter.day:
+		counter.day, counter.dayTokens, counter.dayTopUp = snap.Day, snap.DayTokens, snap.DayTopUp
+	case snap.Day == counter.day:
+		counter.dayTokens, counter.dayTopUp = max(counter.dayTokens, snap.DayTokens), max(counter.dayTopUp, snap.DayTopUp)
+	}
+	switch {
+	case snap.Month > counter.month:
+		counter.month, counter.monthTokens, counter.monthTopUp = snap.Month, snap.MonthTokens, snap.MonthTopUp
+		counter.monthCostMicros = snap.MonthCostMicros
+	case snap.Month == counter.month:
+		counter.monthTokens, counter.monthTopUp = max(counter.monthTokens, snap.MonthTokens), max(counter.monthTopUp, snap.MonthTopUp)
+		counter.monthCostMicros = max(counter.monthCostMicros, snap.MonthCostMicros)
+	}
+}
+
+// resolveLocked returns the configured key a hashed key stands for, or apiKey itself. A
+// hashed key matching none is kept until a key with its hash is configured. The caller holds
+// t.mu.
+func (t *KeyQuotaTracker) resolveLocked(apiKey string) string {
+	if !strings.HasPrefix(apiKey, hashedKeyPrefix) {
+		return apiKey
+	}
+	for configured := range t.limits {
+		if hashedKeyID(configured) == apiKey {
+			return configured
+		}
+	}
+	return apiKey
+}
+
+// adoptHashedLocked merges the counters kept under a hashed key into the configured key with
+// that hash. The caller holds t.mu.
+func (t *KeyQuotaTracker) adoptHashedLocked() {
+	for configured := range t.limits {
+		hashed := hashedKeyID(configured)
+		counter := t.counters[hashed]
+		if counter == nil {
+			continue
+		}
+		delete(t.counters, hashed)
+		t.mergeLocked(configured, KeyQuotaSnapshot{
+			Day: counter.day, DayTokens: counter.dayTokens, DayTopUp: counter.dayTopUp,
+			Month: counter.month, MonthTokens: counter.monthTokens, MonthTopUp: counter.monthTopUp,
+			MonthCostMicros: counter.monthCostMicros,
+		})
+	}
+}
+
+func keyQuotaDay(quota config.APIKeyQuota, now time.Time) string {
+	return now.In(quota.ResetLocation()).Format("2006-01-02")
+}
+
+func keyQuotaDayReset(quota config.APIKeyQuota, now time.Time) time.Time {
+	local := now.In(quota.ResetLocation())
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, local.Location())
+}
+
+// keyQuotaMonthStart returns the start of the quota month containing now.
+func keyQuotaMonthStart(quota config.APIKeyQuota, now time.Time) time.Time {
+	local := now.In(quota.ResetLocation())
+	start := time.Date(local.Year(), local.Month(), quota.ResetDay(), 0, 0, 0, 0, local.Location())
+	if local.Before(start) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start
+}
+
+func keyQuotaMonth(quota config.APIKeyQuota, now time.Time) string {
+	return keyQuotaMonthStart(quota, now).Format("2006-01-02")
+}
+
+func keyQuotaMonthReset(quota config.APIKeyQuota, now time.Time) time.Time {
+	return keyQuotaMonthStart(quota, now).AddDate(0, 1, 0)
+}
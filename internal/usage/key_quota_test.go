@@ -0,0 +1,122 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestKeyQuotaTrackerEnforcesAndResets(t *testing.T) {
+	tracker := NewKeyQuotaTracker()
+	tracker.SetLimits([]config.APIKeySettings{{
+		APIKey: "client",
+		Quota:  &config.APIKeyQuota{DailyTokens: 100, MonthlyTokens: 1000, MonthlyResetDay: 15},
+	}})
+	day := time.Date(2026, 3, 20, 10, 0, 0, 0, time.UTC)
+	record := func(at time.Time, tokens int64) {
+		tracker.HandleUsage(context.Background(), coreusage.Record{
+			APIKey:      "client",
+			RequestedAt: at,
+			Detail:      coreusage.Detail{TotalTokens: tokens},
+		})
+	}
+
+	record(day, 60)
+	record(day, 50)
+	status, _ := tracker.Status("client", day)
+	exceeded, resetsAt := status.Exceeded()
+	if !exceeded || !resetsAt.Equal(time.Date(2026, 3, 21, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("exceeded=%v resetsAt=%s, want daily reset", exceeded, resetsAt)
+	}
+	if status.Monthly.Used != 110 || !status.Monthly.ResetsAt.Equal(time.Date(2026, 4, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("monthly = %+v", status.Monthly)
+	}
+
+	status, _ = tracker.TopUp("client", 50, 0, day)
+	if exceeded, _ = status.Exceeded(); exceeded || status.Daily.Remaining != 40 {
+		t.Fatalf("after top-up: %+v", status.Daily)
+	}
+
+	next := day.AddDate(0, 0, 1)
+	status, _ = tracker.Status("client", next)
+	if status.Daily.Used != 0 || status.Daily.TopUp != 0 || status.Monthly.Used != 110 {
+		t.Fatalf("next day: daily %+v monthly %+v", status.Daily, status.Monthly)
+	}
+
+	if _, ok := tracker.Status("unlimited", day); ok {
+		t.Fatalf("key without quota reported a status")
+	}
+}
+
+func TestKeyQuotaRestoreKeepsLargerUsage(t *testing.T) {
+	quota := config.APIKeyQuota{DailyTokens: 100}
+	now := time.Date(2026, 3, 20, 10, 0, 0, 0, time.UTC)
+	tracker := NewKeyQuotaTracker()
+	tracker.SetLimits([]config.APIKeySettings{{APIKey: "client", Quota: &quota}})
+	tracker.HandleUsage(context.Background(), coreusage.Record{APIKey: "client", RequestedAt: now, Detail: coreusage.Detail{TotalTokens: 30}})
+
+	tracker.Restore([]KeyQuotaSnapshot{
+		{APIKey: "client", Day: keyQuotaDay(quota, now), DayTokens: 80, Month: keyQuotaMonth(quota, now), MonthTokens: 20},
+	})
+	status, _ := tracker.Status("client", now)
+	if status.Daily.Used != 80 {
+		t.Fatalf("daily used = %d, want 80", status.Daily.Used)
+	}
+	if snap := tracker.Snapshot(); len(snap) != 1 || snap[0].MonthTokens != 30 {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+}
+
+func TestKeyQuotaCostCapAndGlobalQuota(t *testing.T) {
+	cfg := &config.Config{ModelPricing: []config.ModelPrice{{Model: "gpt-5", Input: 10, Output: 10}}}
+	tracker := NewKeyQuotaTracker()
+	tracker.SetPricing(cfg.PriceFor)
+	tracker.SetGlobalLimit(&config.APIKeyQuota{DailyTokens: 250_000})
+	tracker.SetLimits([]config.APIKeySettings{{APIKey: "client", Quota: &config.APIKeyQuota{MonthlyCostUSD: 1.5, WarnPercent: 50}}})
+	now := time.Date(2026, 3, 20, 10, 0, 0, 0, time.UTC)
+	record := func(apiKey, model string) {
+		tracker.HandleUsage(context.Background(), coreusage.Record{APIKey: apiKey, Model: model, RequestedAt: now, Detail: coreusage.Detail{TotalTokens: 100_000}})
+	}
+
+	record("client", "gpt-5")
+	status, _ := tracker.Status("client", now)
+	if exceeded, _ := status.Exceeded(); exceeded || status.MonthlyCost == nil || status.MonthlyCost.UsedUSD != 1 {
+		t.Fatalf("after $1: %+v", status.MonthlyCost)
+	}
+	record("client", "unpriced")
+	record("client", "gpt-5")
+	status, _ = tracker.Status("client", now)
+	if exceeded, resetsAt := status.Exceeded(); !exceeded || status.MonthlyCost.UsedUSD != 2 || !resetsAt.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("after $2: exceeded=%v %+v", exceeded, status.MonthlyCost)
+	}
+
+	record("", "unpriced")
+	global, ok := tracker.Status(GlobalQuotaKey, now)
+	if exceeded, _ := global.Exceeded(); !ok || !exceeded || global.Daily.Used != 400_000 {
+		t.Fatalf("global = %+v, want every request charged and the cap hit", global.Daily)
+	}
+	if !tracker.Tracks("unlimited") {
+		t.Fatalf("usage of a key without its own quota is not counted towards the global one")
+	}
+
+	restored := NewKeyQuotaTracker()
+	restored.SetLimits([]config.APIKeySettings{{APIKey: "client", Quota: &config.APIKeyQuota{MonthlyCostUSD: 1.5}}})
+	restored.Restore(tracker.Snapshot())
+	if status, _ = restored.Status("client", now); status.MonthlyCost.UsedUSD != 2 {
+		t.Fatalf("restored spend = %v, want 2", status.MonthlyCost.UsedUSD)
+	}
+}
+
+func TestCrossesWarning(t *testing.T) {
+	for _, tc := range []struct {
+		before, after int64
+		want          bool
+	}{{0, 79, false}, {0, 80, true}, {79, 200, true}, {80, 90, false}} {
+		if got := crossesWarning(80, 100, tc.before, tc.after); got != tc.want {
+			t.Fatalf("%d -> %d: crosses = %v, want %v", tc.before, tc.after, got, tc.want)
+		}
+	}
+}
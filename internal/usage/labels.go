@@ -0,0 +1,147 @@
+package usage
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// LabelSnapshot summarises the requests carrying one value of a usage label.
+type LabelSnapshot struct {
+	TotalRequests int64 `json:"total_requests"`
+	FailureCount  int64 `json:"failure_count"`
+	TotalTokens   int64 `json:"total_tokens"`
+}
+
+// labelStats aggregates one value of a usage label.
+type labelStats struct {
+	requests int64
+	failures int64
+	tokens   int64
+}
+
+// addLabels counts detail under each of its labels and returns the labels as stored. Label
+// names and the values of each name are capped like API keys: past the cap a new one is
+// counted, and stored in the detail, as OverflowKey, so neither the aggregates nor the details
+// grow without bound. Label aggregates are not evicted, since labels carry no activity time.
+func (a *statsAggregate) addLabels(detail RequestDetail, tokens int64) map[string]string {
+	if len(detail.Labels) == 0 {
+		return detail.Labels
+	}
+	if a.labels == nil {
+		a.labels = make(map[string]map[string]*labelStats)
+	}
+	stored := detail.Labels
+	copied := false
+	for name, value := range detail.Labels {
+		placedName, placedValue := name, value
+		values, ok := a.labels[name]
+		if !ok && a.atCap(len(a.labels), a.labels[OverflowKey] != nil, name) {
+			placedName = OverflowKey
+			values = a.labels[OverflowKey]
+		}
+		if values == nil {
+			values = make(map[string]*labelStats)
+			a.labels[placedName] = values
+		}
+		stats, ok := values[value]
+		if !ok && a.atCap(len(values), values[OverflowKey] != nil, value) {
+			placedValue = OverflowKey
+			stats = values[OverflowKey]
+		}
+		if stats == nil {
+			stats = &labelStats{}
+			values[placedValue] = stats
+		}
+		stats.requests++
+		stats.tokens += tokens
+		if detail.Failed {
+			stats.failures++
+		}
+
+		if placedName == name && placedValue == value {
+			continue
+		}
+		a.cardinality.LabelOverflow++
+		if !copied {
+			// Details in the store are never changed in place; the record keeps its map.
+			stored = make(map[string]string, len(detail.Labels))
+			for k, v := range detail.Labels {
+				stored[k] = v
+			}
+			copied = true
+		}
+		delete(stored, name)
+		stored[placedName] = placedValue
+	}
+	return stored
+}
+
+// labelsSnapshot copies the label aggregates.
+func (a *statsAggregate) labelsSnapshot() map[string]map[string]LabelSnapshot {
+	if len(a.labels) == 0 {
+		return nil
+	}
+	out := make(map[string]map[string]LabelSnapshot, len(a.labels))
+	for name, values := range a.labels {
+		snapshots := make(map[string]LabelSnapshot, len(values))
+		for value, stats := range values {
+			snapshots[value] = LabelSnapshot{TotalRequests: stats.requests, FailureCount: stats.failures, TotalTokens: stats.tokens}
+		}
+		out[name] = snapshots
+	}
+	return out
+}
+
+// labelsKey renders labels in a stable order for deduplication.
+func labelsKey(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(labels[name])
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+// FilterByLabels returns the statistics of the requests carrying every label in filter.
+// Totals, per-key, per-model, per-period and label aggregates are recomputed from the
+// matching details; with a shared Redis store the result covers this replica only. An empty
+// filter returns the snapshot unchanged.
+func (s StatisticsSnapshot) FilterByLabels(filter map[string]string) StatisticsSnapshot {
+	if len(filter) == 0 {
+		return s
+	}
+	agg := newStatsAggregate()
+	now := time.Now()
+	for apiName, api := range s.APIs {
+		for modelName, model := range api.Models {
+			for _, detail := range model.Details {
+				if matchesLabels(detail.Labels, filter) {
+					agg.addPlaced(placement{apiName: apiName, modelName: modelName, now: now}, apiName, modelName, detail)
+				}
+			}
+		}
+	}
+	result := agg.snapshot()
+	result.Cardinality = s.Cardinality
+	return result
+}
+
+func matchesLabels(labels, filter map[string]string) bool {
+	for name, value := range filter {
+		if got, ok := labels[name]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
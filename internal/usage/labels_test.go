@@ -0,0 +1,98 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func recordLabelled(stats *RequestStatistics, labels map[string]string, tokens int64, at time.Time) {
+	stats.Record(context.Background(), coreusage.Record{
+		APIKey: "key", Model: "model", RequestedAt: at, Labels: labels,
+		Detail: coreusage.Detail{InputTokens: tokens, TotalTokens: tokens},
+	})
+}
+
+func TestRequestStatisticsAggregatesLabels(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	stats := NewRequestStatistics()
+	now := time.Now()
+	recordLabelled(stats, map[string]string{"team": "infra", "project": "a"}, 10, now)
+	recordLabelled(stats, map[string]string{"team": "infra", "project": "b"}, 20, now.Add(time.Second))
+	recordLabelled(stats, map[string]string{"team": "web"}, 5, now.Add(2*time.Second))
+	recordLabelled(stats, nil, 1, now.Add(3*time.Second))
+
+	snapshot := stats.Snapshot()
+	if got := snapshot.Labels["team"]["infra"]; got.TotalRequests != 2 || got.TotalTokens != 30 {
+		t.Fatalf("team=infra = %+v, want 2 requests and 30 tokens", got)
+	}
+	if got := snapshot.Labels["project"]; len(got) != 2 || got["b"].TotalTokens != 20 {
+		t.Fatalf("project labels = %+v", got)
+	}
+
+	filtered := snapshot.FilterByLabels(map[string]string{"team": "infra"})
+	if filtered.TotalRequests != 2 || filtered.TotalTokens != 30 || filtered.APIs["key"].Models["model"].TotalRequests != 2 {
+		t.Fatalf("filtered totals = %d requests, %d tokens", filtered.TotalRequests, filtered.TotalTokens)
+	}
+	if got := filtered.Labels["team"]; len(got) != 1 {
+		t.Fatalf("filtered team labels = %+v, want only infra", got)
+	}
+	if both := snapshot.FilterByLabels(map[string]string{"team": "infra", "project": "a"}); both.TotalRequests != 1 {
+		t.Fatalf("filter on two labels matched %d requests, want 1", both.TotalRequests)
+	}
+	if none := snapshot.FilterByLabels(map[string]string{"team": "missing"}); none.TotalRequests != 0 || len(none.APIs) != 0 {
+		t.Fatalf("filter on an unknown value matched %d requests", none.TotalRequests)
+	}
+
+	// Labels travel with the details, so a persisted snapshot restores them.
+	restored := NewRequestStatistics()
+	if result := restored.MergeSnapshot(snapshot); result.Added != 4 {
+		t.Fatalf("merged %d records, want 4", result.Added)
+	}
+	if got := restored.Snapshot().Labels["team"]["infra"].TotalRequests; got != 2 {
+		t.Fatalf("restored team=infra requests = %d, want 2", got)
+	}
+}
+
+func TestRequestStatisticsCapsLabelValues(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	stats := NewRequestStatistics()
+	stats.SetMaxKeys(2)
+	now := time.Now()
+	labels := map[string]string{"team": "c"}
+	for i, team := range []string{"a", "b", "c", "d"} {
+		if team == "c" {
+			recordLabelled(stats, labels, 1, now.Add(time.Duration(i)*time.Second))
+			continue
+		}
+		recordLabelled(stats, map[string]string{"team": team}, 1, now.Add(time.Duration(i)*time.Second))
+	}
+	recordLabelled(stats, map[string]string{"one": "x", "two": "y", "three": "z"}, 1, now.Add(time.Minute))
+
+	snapshot := stats.Snapshot()
+	teams := snapshot.Labels["team"]
+	if len(teams) != 3 || teams[OverflowKey].TotalRequests != 2 {
+		t.Fatalf("team labels = %+v, want a, b and two requests under %s", teams, OverflowKey)
+	}
+	if len(snapshot.Labels) != 3 || snapshot.Labels[OverflowKey] == nil {
+		t.Fatalf("label names = %v, want two names and %s", snapshot.Labels, OverflowKey)
+	}
+	if snapshot.Cardinality.LabelOverflow != 4 {
+		t.Fatalf("label overflow = %d, want 4", snapshot.Cardinality.LabelOverflow)
+	}
+	if labels["team"] != "c" {
+		t.Fatalf("the record's labels were changed to %v", labels)
+	}
+	details := snapshot.APIs["key"].Models["model"].Details
+	if got := details[2].Labels["team"]; got != OverflowKey {
+		t.Fatalf("stored label of an overflowing value = %q, want %s", got, OverflowKey)
+	}
+}
@@ -0,0 +1,125 @@
+package usage
+
+import (
+	"math"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds, in seconds, of the request latency histograms. They
+// span quick cached answers up to long generations.
+var LatencyBuckets = [...]float64{0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// Percentiles are estimated from logarithmic buckets, latencySteps per doubling from one
+// millisecond up to 2^latencyOctaves milliseconds (about 70 minutes), so an estimate is
+// within about 10% of the true value.
+const (
+	latencySteps   = 4
+	latencyOctaves = 22
+)
+
+// LatencyHistogram counts requests by how long they took. Generation requests recorded
+// without a latency, such as those loaded from files written before latencies were kept, are
+// not counted.
+type LatencyHistogram struct {
+	// Buckets[i] counts the requests that took at most LatencyBuckets[i] seconds and more
+	// than the bound before it; the last element counts the slower ones.
+	Buckets [len(LatencyBuckets) + 1]int64 `json:"buckets"`
+	Count   int64                          `json:"count"`
+	// SumSeconds is the total time the counted requests took.
+	SumSeconds float64 `json:"sum_seconds"`
+	// P50Seconds, P95Seconds and P99Seconds are the estimated latency percentiles, set in
+	// snapshots.
+	P50Seconds float64 `json:"p50_seconds,omitempty"`
+	P95Seconds float64 `json:"p95_seconds,omitempty"`
+	P99Seconds float64 `json:"p99_seconds,omitempty"`
+
+	// fine counts the requests in the logarithmic buckets the percentiles come from.
+	fine [latencySteps * latencyOctaves]int64
+}
+
+// observe counts one request of the given latency.
+func (h *LatencyHistogram) observe(latency time.Duration) {
+	seconds := latency.Seconds()
+	i := 0
+	for i < len(LatencyBuckets) && seconds > LatencyBuckets[i] {
+		i++
+	}
+	h.Buckets[i]++
+	h.Count++
+	h.SumSeconds += seconds
+	h.fine[fineLatencyBucket(latency)]++
+}
+
+// merge adds the counts of other.
+func (h *LatencyHistogram) merge(other LatencyHistogram) {
+	for i, n := range other.Buckets {
+		h.Buckets[i] += n
+	}
+	h.Count += other.Count
+	h.SumSeconds += other.SumSeconds
+	for i, n := range other.fine {
+		h.fine[i] += n
+	}
+}
+
+// withPercentiles returns a copy of h with the percentile fields set.
+func (h LatencyHistogram) withPercentiles() LatencyHistogram {
+	h.P50Seconds, h.P95Seconds, h.P99Seconds = h.quantile(0.5), h.quantile(0.95), h.quantile(0.99)
+	return h
+}
+
+// histogramSnapshot returns a copy of h with its percentiles for a snapshot, or nil when it
+// counted nothing.
+func histogramSnapshot(h LatencyHistogram) *LatencyHistogram {
+	if h.Count == 0 {
+		return nil
+	}
+	out := h.withPercentiles()
+	return &out
+}
+
+// quantile estimates the latency below which the fraction q of the counted requests fall, as
+// the geometric middle of the bucket holding it.
+func (h *LatencyHistogram) quantile(q float64) float64 {
+	var total int64
+	for _, n := range h.fine {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(total)))
+	var seen int64
+	for i, n := range h.fine {
+		if seen += n; seen >= rank {
+			return math.Exp2((float64(i)+0.5)/latencySteps) / 1000
+		}
+	}
+	return math.Exp2(latencyOctaves) / 1000
+}
+
+func fineLatencyBucket(latency time.Duration) int {
+	ms := float64(latency) / float64(time.Millisecond)
+	if ms <= 1 {
+		return 0
+	}
+	return min(int(math.Log2(ms)*latencySteps), latencySteps*latencyOctaves-1)
+}
+
+// detailFirstToken returns the time to first token a detail adds to the histograms, or false
+// when it was not streamed or not measured.
+func detailFirstToken(detail RequestDetail) (time.Duration, bool) {
+	if detail.FirstTokenMs <= 0 || detail.RequestType != "" {
+		return 0, false
+	}
+	return time.Duration(detail.FirstTokenMs) * time.Millisecond, true
+}
+
+// detailLatency returns the latency a detail adds to the histograms, or false when it adds
+// none: token counting calls and details without a recorded latency.
+func detailLatency(detail RequestDetail) (time.Duration, bool) {
+	if detail.LatencyMs <= 0 || detail.RequestType != "" {
+		return 0, false
+	}
+	return time.Duration(detail.LatencyMs) * time.Millisecond, true
+}
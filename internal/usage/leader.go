@@ -0,0 +1,175 @@
+package usage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Roles reported in PersistenceStatus.
+const (
+	PersistenceRoleStandalone = "standalone"
+	PersistenceRoleLeader     = "leader"
+	PersistenceRoleFollower   = "follower"
+)
+
+// leaderRecord is the content of the lock file.
+type leaderRecord struct {
+	Owner     string    `json:"owner"`
+	Heartbeat time.Time `json:"heartbeat"`
+}
+
+// leaderLock is a lease on a lock file in a directory every replica mounts. The holder
+// rewrites its heartbeat well within the lease; a lock whose heartbeat is older than the lease
+// is taken over by the next replica that looks.
+type leaderLock struct {
+	path  string
+	id    string
+	lease time.Duration
+	now   func() time.Time
+
+	mu     sync.Mutex
+	leader bool
+	holder leaderRecord
+}
+
+func newLeaderLock(path string, lease time.Duration) *leaderLock {
+	return &leaderLock{path: path, id: newReplicaID(), lease: lease, now: time.Now}
+}
+
+// newReplicaID names this process in the lock file: host, pid and a random suffix so that a
+// restarted process never mistakes its predecessor's lock for its own.
+func newReplicaID() string {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "replica"
+	}
+	var suffix [4]byte
+	_, _ = rand.Read(suffix[:])
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix[:]))
+}
+
+// heartbeat renews the lock when this replica holds it, or takes it when it is free or
+// expired. It reports whether this replica is the leader and whether it just became one.
+func (l *leaderLock) heartbeat() (leader bool, acquired bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	current, errRead := readLeaderRecord(l.path)
+	switch {
+	case errRead == nil && current.Owner == l.id:
+		err = l.write(leaderRecord{Owner: l.id, Heartbeat: now}, false)
+	case errRead == nil && now.Sub(current.Heartbeat) <= l.lease:
+		l.leader, l.holder = false, current
+		return false, false, nil
+	case errRead != nil && !errors.Is(errRead, os.ErrNotExist) && !errors.Is(errRead, errLeaderRecordInvalid):
+		l.leader = false
+		return false, false, errRead
+	default:
+		// Free, expired or unreadable: remove whatever is there and race for it.
+		if errRead == nil || errors.Is(errRead, errLeaderRecordInvalid) {
+			_ = os.Remove(l.path)
+		}
+		err = l.write(leaderRecord{Owner: l.id, Heartbeat: now}, true)
+		if errors.Is(err, os.ErrExist) {
+			// Another replica got there first.
+			l.leader = false
+			l.holder, _ = readLeaderRecord(l.path)
+			return false, false, nil
+		}
+	}
+	if err != nil {
+		l.leader = false
+		return false, false, err
+	}
+	acquired = !l.leader
+	l.leader, l.holder = true, leaderRecord{Owner: l.id, Heartbeat: now}
+	return true, acquired, nil
+}
+
+// write stores record in the lock file. With exclusive set it fails with os.ErrExist when a
+// lock file exists, which makes taking a free lock atomic: the file is written under a
+// temporary name and hard-linked into place.
+func (l *leaderLock) write(record leaderRecord, exclusive bool) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if !exclusive {
+		return writeFileAtomic(l.path, data)
+	}
+	dir := filepath.Dir(l.path)
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(l.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() { _ = os.Remove(tmpName) }()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Link(tmpName, l.path)
+}
+
+// isLeader reports whether the last heartbeat found this replica holding the lock. It checks
+// the file again so a leader that lost its lock while stalled does not save once more.
+func (l *leaderLock) isLeader() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.leader {
+		return false
+	}
+	current, err := readLeaderRecord(l.path)
+	if err != nil || current.Owner != l.id {
+		l.leader = false
+		l.holder = current
+		return false
+	}
+	return true
+}
+
+// release gives up the lock if this replica holds it, so a follower can take over at once.
+func (l *leaderLock) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.leader {
+		return
+	}
+	l.leader = false
+	if current, err := readLeaderRecord(l.path); err == nil && current.Owner == l.id {
+		_ = os.Remove(l.path)
+	}
+}
+
+func (l *leaderLock) state() (leader bool, holder leaderRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.leader, l.holder
+}
+
+var errLeaderRecordInvalid = errors.New("invalid lock file")
+
+func readLeaderRecord(path string) (leaderRecord, error) {
+	var record leaderRecord
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return record, err
+	}
+	if err = json.Unmarshal(data, &record); err != nil || record.Owner == "" {
+		return leaderRecord{}, errLeaderRecordInvalid
+	}
+	return record, nil
+}
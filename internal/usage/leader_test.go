@@ -0,0 +1,93 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestLeaderLockFailsOverAfterLease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json.lock")
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	a, b := newLeaderLock(path, time.Minute), newLeaderLock(path, time.Minute)
+	a.now = func() time.Time { return now }
+	b.now = func() time.Time { return now.Add(30 * time.Second) }
+
+	if leader, acquired, err := a.heartbeat(); err != nil || !leader || !acquired {
+		t.Fatalf("first replica should take the free lock: %t %t %v", leader, acquired, err)
+	}
+	if leader, _, err := b.heartbeat(); err != nil || leader {
+		t.Fatalf("second replica took a live lock: %t %v", leader, err)
+	}
+	if _, holder := b.state(); holder.Owner != a.id {
+		t.Fatalf("follower should see the leader, got %q", holder.Owner)
+	}
+
+	// a stops renewing; once its heartbeat is older than the lease b takes over.
+	b.now = func() time.Time { return now.Add(61 * time.Second) }
+	if leader, acquired, err := b.heartbeat(); err != nil || !leader || !acquired {
+		t.Fatalf("expired lock not taken over: %t %t %v", leader, acquired, err)
+	}
+	if a.isLeader() {
+		t.Fatal("the stalled leader should notice it lost the lock before saving")
+	}
+	b.release()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("release should remove the lock file, stat: %v", err)
+	}
+}
+
+func TestLeaderMergesFollowerStatistics(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	path := filepath.Join(t.TempDir(), "usage.json")
+	newReplicaPlugin := func() *FileUsagePlugin {
+		plugin := NewFileUsagePlugin(path, time.Minute, NewRequestStatistics())
+		plugin.quotas = NewQuotaTracker()
+		plugin.EnableLeaderElection()
+		plugin.heartbeat()
+		return plugin
+	}
+	leader, follower := newReplicaPlugin(), newReplicaPlugin()
+	if got := leader.Status().Role; got != PersistenceRoleLeader {
+		t.Fatalf("first replica role = %s", got)
+	}
+	if status := follower.Status(); status.Role != PersistenceRoleFollower || status.Leader != leader.election.id {
+		t.Fatalf("unexpected follower status %+v", status)
+	}
+
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	leader.stats.Record(context.Background(), coreusage.Record{APIKey: "key", Model: "gpt-5", RequestedAt: now})
+	follower.stats.Record(context.Background(), coreusage.Record{APIKey: "key", Model: "gpt-5", RequestedAt: now.Add(time.Second)})
+	for round := 0; round < 2; round++ {
+		// Repeated hand-overs of the same records must not count them twice.
+		if err := follower.persist(); err != nil {
+			t.Fatalf("follower persist: %v", err)
+		}
+		if err := leader.persist(); err != nil {
+			t.Fatalf("leader persist: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read usage file: %v", err)
+	}
+	var saved FileUsageData
+	if err = json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("decode usage file: %v", err)
+	}
+	if got := len(usageRecords(saved.Usage)); got != 2 {
+		t.Fatalf("saved %d requests, want both replicas' records once", got)
+	}
+	if _, err = os.Stat(follower.replicaPath); !os.IsNotExist(err) {
+		t.Fatalf("merged hand-over file should be removed, stat: %v", err)
+	}
+}
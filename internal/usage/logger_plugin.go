@@ -6,6 +6,8 @@ package usage
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -56,21 +58,79 @@ func SetStatisticsEnabled(enabled bool) { statisticsEnabled.Store(enabled) }
 // StatisticsEnabled reports the current recording state.
 func StatisticsEnabled() bool { return statisticsEnabled.Load() }
 
+// statShardCount is the number of independently locked shards concurrent writers spread over.
+const statShardCount = 32
+
 // RequestStatistics maintains aggregated request metrics in memory.
+//
+// Writers only append to a pending list in a random shard. Readers take the pending lists by
+// swapping them out, one shard lock at a time, and fold them into the aggregates under foldMu,
+// which writers never take; a slow reader therefore never holds up recording.
 type RequestStatistics struct {
-	mu sync.RWMutex
+	shards [statShardCount]*statsShard
+
+	foldMu sync.Mutex
+	agg    *statsAggregate
+
+	// shared, when set, holds the totals of every replica; Snapshot reports those instead
+	// of the local aggregates.
+	shared atomic.Pointer[RedisUsageStore]
+
+	// denied counts requests refused by API key scopes, per key. They never reach an
+	// upstream, so they are kept apart from the usage aggregates.
+	deniedMu sync.Mutex
+	denied   map[string]int64
+}
 
+// statsShard buffers records between folds.
+type statsShard struct {
+	mu      sync.Mutex
+	pending []pendingDetail
+}
+
+type pendingDetail struct {
+	apiName   string
+	modelName string
+	detail    RequestDetail
+}
+
+// statsAggregate holds the folded totals and per-key, per-model and per-period aggregates.
+// It is only accessed under RequestStatistics.foldMu.
+type statsAggregate struct {
 	totalRequests int64
 	successCount  int64
 	failureCount  int64
 	totalTokens   int64
+	cacheHits     int64
 
-	apis map[string]*apiStats
+	apis        map[string]*apiStats
+	maxKeys     int
+	cardinality CardinalityStats
 
 	requestsByDay  map[string]int64
 	requestsByHour map[int]int64
 	tokensByDay    map[string]int64
 	tokensByHour   map[int]int64
+	hourly         rollingWindow
+	daily          rollingWindow
+
+	// labels aggregates requests by usage label name and value; nil until a record has labels.
+	labels map[string]map[string]*labelStats
+	// accounts aggregates requests by the auth index of the credential that served them; nil
+	// until a record names one.
+	accounts map[string]*accountStats
+}
+
+func newStatsAggregate() *statsAggregate {
+	return &statsAggregate{
+		apis:           make(map[string]*apiStats),
+		requestsByDay:  make(map[string]int64),
+		requestsByHour: make(map[int]int64),
+		tokensByDay:    make(map[string]int64),
+		tokensByHour:   make(map[int]int64),
+		hourly:         newRollingWindow(hourlyWindowBuckets, false),
+		daily:          newRollingWindow(dailyWindowBuckets, true),
+	}
 }
 
 // apiStats holds aggregated metrics for a single API key.
@@ -78,13 +138,20 @@ type apiStats struct {
 	TotalRequests int64
 	TotalTokens   int64
 	Models        map[string]*modelStats
+	lastSeen      time.Time
 }
 
 // modelStats holds aggregated metrics for a specific model within an API.
 type modelStats struct {
 	TotalRequests int64
 	TotalTokens   int64
-	Details       []RequestDetail
+	FailureCount  int64
+	latency       LatencyHistogram
+	firstToken    LatencyHistogram
+	// Details is append-only: snapshots share its backing array up to their length, so
+	// elements already written must never be changed in place.
+	Details  []RequestDetail
+	lastSeen time.Time
 }
 
 // RequestDetail stores the timestamp and token usage for a single request.
@@ -94,6 +161,22 @@ type RequestDetail struct {
 	AuthIndex string     `json:"auth_index"`
 	Tokens    TokenStats `json:"tokens"`
 	Failed    bool       `json:"failed"`
+	// Provider is the provider of the credential that served the request.
+	Provider string `json:"provider,omitempty"`
+	// Cancelled is set when the client disconnected first; Tokens are then partial.
+	Cancelled bool `json:"cancelled,omitempty"`
+	// CacheHit is set when the response came from the response cache; Tokens are then zero.
+	CacheHit bool `json:"cache_hit,omitempty"`
+	// RequestType is empty for generation and "count_tokens" for token counting calls.
+	RequestType string `json:"request_type,omitempty"`
+	// Labels are the usage labels of the request, with names and values past the cap
+	// replaced by OverflowKey.
+	Labels map[string]string `json:"labels,omitempty"`
+	// LatencyMs is how long the request took in milliseconds; zero when it was not measured.
+	LatencyMs int64 `json:"latency_ms,omitempty"`
+	// FirstTokenMs is how long a streamed request took until its first chunk, in milliseconds;
+	// zero when it was not streamed or not measured.
+	FirstTokenMs int64 `json:"first_token_ms,omitempty"`
 }
 
 // TokenStats captures the token usage breakdown for a request.
@@ -111,6 +194,9 @@ type StatisticsSnapshot struct {
 	SuccessCount  int64 `json:"success_count"`
 	FailureCount  int64 `json:"failure_count"`
 	TotalTokens   int64 `json:"total_tokens"`
+	// CacheHits counts requests served from the response cache; they are included in the
+	// success count and carry no tokens.
+	CacheHits int64 `json:"cache_hits,omitempty"`
 
 	APIs map[string]APISnapshot `json:"apis"`
 
@@ -118,6 +204,28 @@ type StatisticsSnapshot struct {
 	RequestsByHour map[string]int64 `json:"requests_by_hour"`
 	TokensByDay    map[string]int64 `json:"tokens_by_day"`
 	TokensByHour   map[string]int64 `json:"tokens_by_hour"`
+
+	// Hourly and Daily are rolling windows of the last 24 hours and 30 days, oldest first;
+	// Last24Hours sums Hourly. Unlike the maps above they are recent trends rather than
+	// all-time totals, and with a shared Redis store they cover this replica only.
+	Hourly      []UsageBucket `json:"hourly,omitempty"`
+	Daily       []UsageBucket `json:"daily,omitempty"`
+	Last24Hours UsageBucket   `json:"last_24_hours"`
+
+	// Labels aggregates requests by usage label name and then value.
+	Labels map[string]map[string]LabelSnapshot `json:"labels,omitempty"`
+
+	// Accounts aggregates requests by the auth index of the upstream credential that served
+	// them. With a shared Redis store they cover this replica only.
+	Accounts map[string]AccountSnapshot `json:"accounts,omitempty"`
+
+	// Cardinality reports the key cap and how much traffic was aggregated under OverflowKey.
+	Cardinality CardinalityStats `json:"cardinality"`
+
+	// EstimatedCost is the estimated spend in US dollars, set by WithEstimatedCosts.
+	EstimatedCost float64 `json:"estimated_cost_usd,omitempty"`
+	// UnpricedModels lists the models with requests but no model-pricing entry.
+	UnpricedModels []string `json:"unpriced_models,omitempty"`
 }
 
 // APISnapshot summarises metrics for a single API key.
@@ -125,12 +233,25 @@ type APISnapshot struct {
 	TotalRequests int64                    `json:"total_requests"`
 	TotalTokens   int64                    `json:"total_tokens"`
 	Models        map[string]ModelSnapshot `json:"models"`
+	// DeniedRequests counts requests refused because they were outside the key's scopes.
+	// They are not part of TotalRequests.
+	DeniedRequests int64 `json:"denied_requests,omitempty"`
+	// EstimatedCost is the key's estimated spend in US dollars.
+	EstimatedCost float64 `json:"estimated_cost_usd,omitempty"`
 }
 
 // ModelSnapshot summarises metrics for a specific model.
 type ModelSnapshot struct {
-	TotalRequests int64           `json:"total_requests"`
-	TotalTokens   int64           `json:"total_tokens"`
+	TotalRequests int64 `json:"total_requests"`
+	TotalTokens   int64 `json:"total_tokens"`
+	// FailureCount counts the failed requests among TotalRequests.
+	FailureCount int64 `json:"failure_count,omitempty"`
+	// Latency is the latency histogram of the model's generation requests.
+	Latency *LatencyHistogram `json:"latency,omitempty"`
+	// FirstToken is the histogram of the time to first token of its streamed requests.
+	FirstToken *LatencyHistogram `json:"first_token,omitempty"`
+	// EstimatedCost is the model's estimated spend in US dollars.
+	EstimatedCost float64         `json:"estimated_cost_usd,omitempty"`
 	Details       []RequestDetail `json:"details"`
 }
 
@@ -141,16 +262,14 @@ func GetRequestStatistics() *RequestStatistics { return defaultRequestStatistics
 
 // NewRequestStatistics constructs an empty statistics store.
 func NewRequestStatistics() *RequestStatistics {
-	return &RequestStatistics{
-		apis:           make(map[string]*apiStats),
-		requestsByDay:  make(map[string]int64),
-		requestsByHour: make(map[int]int64),
-		tokensByDay:    make(map[string]int64),
-		tokensByHour:   make(map[int]int64),
+	s := &RequestStatistics{agg: newStatsAggregate()}
+	for i := range s.shards {
+		s.shards[i] = &statsShard{}
 	}
+	return s
 }
 
-// Record ingests a new usage record and updates the aggregates.
+// Record ingests a new usage record. It is aggregated on the next read of the statistics.
 func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record) {
 	if s == nil {
 		return
@@ -158,148 +277,269 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 	if !statisticsEnabled.Load() {
 		return
 	}
+	entry := newPendingDetail(ctx, record)
+	shard := s.shards[rand.IntN(statShardCount)]
+	shard.mu.Lock()
+	shard.pending = append(shard.pending, entry)
+	shard.mu.Unlock()
+}
+
+// newPendingDetail resolves the API key, model and outcome a record is aggregated under.
+func newPendingDetail(ctx context.Context, record coreusage.Record) pendingDetail {
 	timestamp := record.RequestedAt
 	if timestamp.IsZero() {
 		timestamp = time.Now()
 	}
 	detail := normaliseDetail(record.Detail)
-	totalTokens := detail.TotalTokens
+	requestDetail := RequestDetail{
+		Timestamp:    timestamp,
+		Source:       record.Source,
+		AuthIndex:    record.AuthIndex,
+		Provider:     record.Provider,
+		Tokens:       detail,
+		Cancelled:    record.Cancelled,
+		CacheHit:     record.CacheHit,
+		RequestType:  record.RequestType,
+		Labels:       record.Labels,
+		LatencyMs:    record.Latency.Milliseconds(),
+		FirstTokenMs: record.FirstTokenLatency.Milliseconds(),
+	}
 	statsKey := record.APIKey
 	if statsKey == "" {
 		statsKey = resolveAPIIdentifier(ctx, record)
 	}
 	failed := record.Failed
-	if !failed {
+	if !failed && !record.Cancelled {
 		failed = !resolveSuccess(ctx)
 	}
-	success := !failed
 	modelName := record.Model
 	if modelName == "" {
 		modelName = "unknown"
 	}
-	dayKey := timestamp.Format("2006-01-02")
-	hourKey := timestamp.Hour()
+	requestDetail.Failed = failed
+	return pendingDetail{apiName: statsKey, modelName: modelName, detail: requestDetail}
+}
+
+// fold moves every pending record into the aggregates. The caller holds s.foldMu.
+func (s *RequestStatistics) fold() {
+	var batch []pendingDetail
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		pending := shard.pending
+		shard.pending = nil
+		shard.mu.Unlock()
+		batch = append(batch, pending...)
+	}
+	// Shards interleave requests; keep details in chronological order within each fold.
+	sort.SliceStable(batch, func(i, j int) bool {
+		return batch[i].detail.Timestamp.Before(batch[j].detail.Timestamp)
+	})
+	for _, entry := range batch {
+		s.agg.add(entry.apiName, entry.modelName, entry.detail)
+	}
+}
+
+// add adds one request to the aggregates, under OverflowKey when a cap is reached.
+func (a *statsAggregate) add(apiName, modelName string, detail RequestDetail) {
+	a.adoptHashed(apiName)
+	a.addPlaced(a.place(apiName, modelName, time.Now()), apiName, modelName, detail)
+}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (a *statsAggregate) addPlaced(p placement, apiName, modelName string, detail RequestDetail) {
+	tokens := billableTokens(detail)
 
-	s.totalRequests++
-	if success {
-		s.successCount++
+	a.totalRequests++
+	if detail.Failed {
+		a.failureCount++
 	} else {
-		s.failureCount++
+		a.successCount++
 	}
-	s.totalTokens += totalTokens
-
-	stats, ok := s.apis[statsKey]
-	if !ok {
-		stats = &apiStats{Models: make(map[string]*modelStats)}
-		s.apis[statsKey] = stats
+	a.totalTokens += tokens
+	if detail.CacheHit {
+		a.cacheHits++
 	}
-	s.updateAPIStats(stats, modelName, RequestDetail{
-		Timestamp: timestamp,
-		Source:    record.Source,
-		AuthIndex: record.AuthIndex,
-		Tokens:    detail,
-		Failed:    failed,
-	})
-
-	s.requestsByDay[dayKey]++
-	s.requestsByHour[hourKey]++
-	s.tokensByDay[dayKey] += totalTokens
-	s.tokensByHour[hourKey] += totalTokens
-}
 
-func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail RequestDetail) {
+	modelStatsValue := a.apply(p, apiName, modelName)
+	seen := observedAt(detail.Timestamp, p.now)
+	stats := a.apis[p.apiName]
 	stats.TotalRequests++
-	stats.TotalTokens += detail.Tokens.TotalTokens
-	modelStatsValue, ok := stats.Models[model]
-	if !ok {
-		modelStatsValue = &modelStats{}
-		stats.Models[model] = modelStatsValue
+	stats.TotalTokens += tokens
+	if seen.After(stats.lastSeen) {
+		stats.lastSeen = seen
 	}
 	modelStatsValue.TotalRequests++
-	modelStatsValue.TotalTokens += detail.Tokens.TotalTokens
+	modelStatsValue.TotalTokens += tokens
+	if detail.Failed {
+		modelStatsValue.FailureCount++
+	}
+	if latency, ok := detailLatency(detail); ok {
+		modelStatsValue.latency.observe(latency)
+	}
+	if firstToken, ok := detailFirstToken(detail); ok {
+		modelStatsValue.firstToken.observe(firstToken)
+	}
+	detail.Labels = a.addLabels(detail, tokens)
+	a.addAccount(p, detail, tokens)
 	modelStatsValue.Details = append(modelStatsValue.Details, detail)
+	if seen.After(modelStatsValue.lastSeen) {
+		modelStatsValue.lastSeen = seen
+	}
+
+	a.addPeriods(p.now, detail, tokens)
 }
 
-// Snapshot returns a copy of the aggregated metrics for external consumption.
+// addPeriods counts detail in the per-day and per-hour aggregates and the rolling windows.
+func (a *statsAggregate) addPeriods(now time.Time, detail RequestDetail, tokens int64) {
+	dayKey := detail.Timestamp.Format("2006-01-02")
+	hourKey := detail.Timestamp.Hour()
+	a.requestsByDay[dayKey]++
+	a.requestsByHour[hourKey]++
+	a.tokensByDay[dayKey] += tokens
+	a.tokensByHour[hourKey] += tokens
+	a.hourly.add(now, detail.Timestamp, detail.Failed, tokens)
+	a.daily.add(now, detail.Timestamp, detail.Failed, tokens)
+}
+
+// Snapshot returns a consistent view of the aggregated metrics for external consumption.
+// Details slices share their backing arrays with the store and must be treated as read-only;
+// appending to them is safe. With a shared Redis store the totals cover every replica while
+// details remain those recorded here; if Redis cannot be read the local view is returned.
 func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
-	result := StatisticsSnapshot{}
 	if s == nil {
-		return result
+		return StatisticsSnapshot{}
 	}
+	result := s.localSnapshot()
+	if shared := s.shared.Load(); shared != nil {
+		shared.overlay(&result)
+	}
+	s.applyDenied(&result)
+	return result
+}
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	result.TotalRequests = s.totalRequests
-	result.SuccessCount = s.successCount
-	result.FailureCount = s.failureCount
-	result.TotalTokens = s.totalTokens
+func (s *RequestStatistics) localSnapshot() StatisticsSnapshot {
+	s.foldMu.Lock()
+	defer s.foldMu.Unlock()
+	s.fold()
+	return s.agg.snapshot()
+}
 
-	result.APIs = make(map[string]APISnapshot, len(s.apis))
-	for apiName, stats := range s.apis {
+// snapshot copies the aggregates; details are shared as described on Snapshot.
+func (a *statsAggregate) snapshot() StatisticsSnapshot {
+	result := StatisticsSnapshot{}
+	result.TotalRequests = a.totalRequests
+	result.SuccessCount = a.successCount
+	result.FailureCount = a.failureCount
+	result.TotalTokens = a.totalTokens
+	result.CacheHits = a.cacheHits
+	result.Cardinality = a.cardinalitySnapshot()
+	now := time.Now()
+	result.Hourly, result.Last24Hours = a.hourly.snapshot(now)
+	result.Daily, _ = a.daily.snapshot(now)
+
+	result.APIs = make(map[string]APISnapshot, len(a.apis))
+	for apiName, stats := range a.apis {
 		apiSnapshot := APISnapshot{
 			TotalRequests: stats.TotalRequests,
 			TotalTokens:   stats.TotalTokens,
 			Models:        make(map[string]ModelSnapshot, len(stats.Models)),
 		}
 		for modelName, modelStatsValue := range stats.Models {
-			requestDetails := make([]RequestDetail, len(modelStatsValue.Details))
-			copy(requestDetails, modelStatsValue.Details)
+			details := modelStatsValue.Details
 			apiSnapshot.Models[modelName] = ModelSnapshot{
 				TotalRequests: modelStatsValue.TotalRequests,
 				TotalTokens:   modelStatsValue.TotalTokens,
-				Details:       requestDetails,
+				FailureCount:  modelStatsValue.FailureCount,
+				Latency:       histogramSnapshot(modelStatsValue.latency),
+				FirstToken:    histogramSnapshot(modelStatsValue.firstToken),
+				// Capping the capacity makes an append by the caller copy instead of
+				// writing into the store's array.
+				Details: details[:len(details):len(details)],
 			}
 		}
 		result.APIs[apiName] = apiSnapshot
 	}
 
-	result.RequestsByDay = make(map[string]int64, len(s.requestsByDay))
-	for k, v := range s.requestsByDay {
+	result.RequestsByDay = make(map[string]int64, len(a.requestsByDay))
+	for k, v := range a.requestsByDay {
 		result.RequestsByDay[k] = v
 	}
-
-	result.RequestsByHour = make(map[string]int64, len(s.requestsByHour))
-	for hour, v := range s.requestsByHour {
-		key := formatHour(hour)
-		result.RequestsByHour[key] = v
+	result.RequestsByHour = make(map[string]int64, len(a.requestsByHour))
+	for hour, v := range a.requestsByHour {
+		result.RequestsByHour[formatHour(hour)] += v
 	}
-
-	result.TokensByDay = make(map[string]int64, len(s.tokensByDay))
-	for k, v := range s.tokensByDay {
+	result.TokensByDay = make(map[string]int64, len(a.tokensByDay))
+	for k, v := range a.tokensByDay {
 		result.TokensByDay[k] = v
 	}
-
-	result.TokensByHour = make(map[string]int64, len(s.tokensByHour))
-	for hour, v := range s.tokensByHour {
-		key := formatHour(hour)
-		result.TokensByHour[key] = v
+	result.TokensByHour = make(map[string]int64, len(a.tokensByHour))
+	for hour, v := range a.tokensByHour {
+		result.TokensByHour[formatHour(hour)] += v
 	}
-
+	result.Labels = a.labelsSnapshot()
+	result.Accounts = a.accountsSnapshot(now)
 	return result
 }
 
+// AuthUsageSummary aggregates recorded requests for one credential.
+type AuthUsageSummary struct {
+	Requests    int64     `json:"requests"`
+	Failures    int64     `json:"failures"`
+	TotalTokens int64     `json:"total_tokens"`
+	LastUsedAt  time.Time `json:"last_used_at,omitempty"`
+	// Last24hRequests and Last24hTokens count the requests of the last 24 hours.
+	Last24hRequests int64 `json:"last_24h_requests"`
+	Last24hTokens   int64 `json:"last_24h_tokens"`
+}
+
+// AuthUsage summarises recorded requests per credential, keyed by auth index.
+func (s *RequestStatistics) AuthUsage() map[string]AuthUsageSummary {
+	out := make(map[string]AuthUsageSummary)
+	if s == nil {
+		return out
+	}
+	s.foldMu.Lock()
+	defer s.foldMu.Unlock()
+	s.fold()
+	for authIndex, account := range s.agg.accountsSnapshot(time.Now()) {
+		out[authIndex] = AuthUsageSummary{
+			Requests:        account.TotalRequests,
+			Failures:        account.FailureCount,
+			TotalTokens:     account.TotalTokens,
+			LastUsedAt:      account.LastUsedAt,
+			Last24hRequests: account.Last24Hours.Requests,
+			Last24hTokens:   account.Last24Hours.Tokens,
+		}
+	}
+	return out
+}
+
 type MergeResult struct {
 	Added   int64 `json:"added"`
 	Skipped int64 `json:"skipped"`
 }
 
 // MergeSnapshot merges an exported statistics snapshot into the current store.
-// Existing data is preserved and duplicate request details are skipped.
+// Existing data is preserved and duplicate request details are skipped. The whole merge runs
+// under foldMu, like the aggregation of a snapshot, so a concurrent Snapshot sees either none
+// or all of the merged records, totals and details alike.
 func (s *RequestStatistics) MergeSnapshot(snapshot StatisticsSnapshot) MergeResult {
 	result := MergeResult{}
 	if s == nil {
 		return result
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.foldMu.Lock()
+	defer s.foldMu.Unlock()
+	s.fold()
 
+	now := time.Now()
+	shared := s.shared.Load()
+	var added []pendingDetail
+	for apiName := range snapshot.APIs {
+		s.agg.adoptHashed(strings.TrimSpace(apiName))
+	}
 	seen := make(map[string]struct{})
-	for apiName, stats := range s.apis {
+	for apiName, stats := range s.agg.apis {
 		if stats == nil {
 			continue
 		}
@@ -318,13 +558,6 @@ func (s *RequestStatistics) MergeSnapshot(snapshot StatisticsSnapshot) MergeResu
 		if apiName == "" {
 			continue
 		}
-		stats, ok := s.apis[apiName]
-		if !ok || stats == nil {
-			stats = &apiStats{Models: make(map[string]*modelStats)}
-			s.apis[apiName] = stats
-		} else if stats.Models == nil {
-			stats.Models = make(map[string]*modelStats)
-		}
 		for modelName, modelSnapshot := range apiSnapshot.Models {
 			modelName = strings.TrimSpace(modelName)
 			if modelName == "" {
@@ -335,49 +568,68 @@ func (s *RequestStatistics) MergeSnapshot(snapshot StatisticsSnapshot) MergeResu
 				if detail.Timestamp.IsZero() {
 					detail.Timestamp = time.Now()
 				}
-				key := dedupKey(apiName, modelName, detail)
+				// Deduplicate on where the record would be stored, so records loaded into
+				// OverflowKey are recognised when the same file is merged again.
+				placed := s.agg.place(apiName, modelName, now)
+				key := dedupKey(placed.apiName, placed.modelName, detail)
 				if _, exists := seen[key]; exists {
 					result.Skipped++
 					continue
 				}
 				seen[key] = struct{}{}
-				s.recordImported(apiName, modelName, stats, detail)
+				s.agg.addPlaced(placed, apiName, modelName, detail)
 				result.Added++
+				if shared != nil {
+					added = append(added, pendingDetail{apiName: apiName, modelName: modelName, detail: detail})
+				}
 			}
 		}
 	}
-
+	// Imported records count towards the shared totals like freshly recorded ones.
+	shared.enqueue(added)
 	return result
 }
 
-func (s *RequestStatistics) recordImported(apiName, modelName string, stats *apiStats, detail RequestDetail) {
-	totalTokens := detail.Tokens.TotalTokens
-	if totalTokens < 0 {
-		totalTokens = 0
-	}
+// Reset drops every recorded request and denied-request count and returns how many requests
+// were dropped. Quota counters are kept, and so are the totals of a shared Redis store, which
+// every replica adds to.
+func (s *RequestStatistics) Reset() int64 {
+	if s == nil {
+		return 0
+	}
+	s.foldMu.Lock()
+	s.fold()
+	cleared := s.agg.totalRequests
+	fresh := newStatsAggregate()
+	fresh.maxKeys = s.agg.maxKeys
+	// Snapshots taken earlier keep the old details; the new aggregates share none of them.
+	s.agg = fresh
+	s.foldMu.Unlock()
+
+	s.deniedMu.Lock()
+	s.denied = nil
+	s.deniedMu.Unlock()
+	return cleared
+}
 
-	s.totalRequests++
-	if detail.Failed {
-		s.failureCount++
-	} else {
-		s.successCount++
+// billableTokens is the token total a request contributes to the aggregates. Token
+// counting calls are listed with their counted tokens but consume none.
+func billableTokens(detail RequestDetail) int64 {
+	if detail.RequestType == coreusage.RequestTypeCountTokens || detail.Tokens.TotalTokens < 0 {
+		return 0
 	}
-	s.totalTokens += totalTokens
-
-	s.updateAPIStats(stats, modelName, detail)
-
-	dayKey := detail.Timestamp.Format("2006-01-02")
-	hourKey := detail.Timestamp.Hour()
-
-	s.requestsByDay[dayKey]++
-	s.requestsByHour[hourKey]++
-	s.tokensByDay[dayKey] += totalTokens
-	s.tokensByHour[hourKey] += totalTokens
+	return detail.Tokens.TotalTokens
 }
 
 func dedupKey(apiName, modelName string, detail RequestDetail) string {
 	timestamp := detail.Timestamp.UTC().Format(time.RFC3339Nano)
 	tokens := normaliseTokenStats(detail.Tokens)
+	if detail.RequestType != "" {
+		apiName += "|" + detail.RequestType
+	}
+	if len(detail.Labels) > 0 {
+		modelName += "|" + labelsKey(detail.Labels)
+	}
 	return fmt.Sprintf(
 		"%s|%s|%s|%s|%s|%t|%d|%d|%d|%d|%d",
 		apiName,
@@ -0,0 +1,167 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestRequestStatisticsCountTokensAreNotBilled(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	stats := NewRequestStatistics()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	stats.Record(context.Background(), coreusage.Record{
+		APIKey: "key", Model: "gpt-5", RequestedAt: now,
+		Detail: coreusage.Detail{InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+	})
+	stats.Record(context.Background(), coreusage.Record{
+		APIKey: "key", Model: "gpt-5", RequestedAt: now, RequestType: coreusage.RequestTypeCountTokens,
+		Detail: coreusage.Detail{InputTokens: 10, TotalTokens: 10},
+	})
+
+	snapshot := stats.Snapshot()
+	if snapshot.TotalRequests != 2 || snapshot.TotalTokens != 15 {
+		t.Fatalf("requests = %d, tokens = %d; want 2 requests and 15 tokens", snapshot.TotalRequests, snapshot.TotalTokens)
+	}
+	details := snapshot.APIs["key"].Models["gpt-5"].Details
+	counted := 0
+	for _, detail := range details {
+		if detail.RequestType == coreusage.RequestTypeCountTokens && detail.Tokens.InputTokens == 10 {
+			counted++
+		}
+	}
+	if len(details) != 2 || counted != 1 {
+		t.Fatalf("unexpected details %+v", details)
+	}
+
+	// Both records share a timestamp and token counts; the request type keeps them apart.
+	result := NewRequestStatistics().MergeSnapshot(snapshot)
+	if result.Added != 2 {
+		t.Fatalf("merge added %d records, want 2", result.Added)
+	}
+}
+
+func TestRequestStatisticsSnapshotConsistentUnderLoad(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	stats := NewRequestStatistics()
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				stats.Record(context.Background(), coreusage.Record{
+					APIKey: fmt.Sprintf("key-%d", w%3), Model: "gpt-5", RequestedAt: time.Now(),
+					Failed: i%5 == 0, Detail: coreusage.Detail{TotalTokens: 3},
+				})
+			}
+		}(w)
+	}
+	for i := 0; i < 50; i++ {
+		snapshot := stats.Snapshot()
+		var requests, tokens, details int64
+		for _, api := range snapshot.APIs {
+			requests += api.TotalRequests
+			tokens += api.TotalTokens
+			for _, model := range api.Models {
+				details += int64(len(model.Details))
+			}
+		}
+		if snapshot.TotalRequests != requests || requests != details || snapshot.TotalTokens != tokens ||
+			snapshot.SuccessCount+snapshot.FailureCount != snapshot.TotalRequests {
+			t.Fatalf("inconsistent snapshot: totals %d/%d tokens, %d requests, %d details, %d+%d outcomes",
+				snapshot.TotalTokens, tokens, snapshot.TotalRequests, details, snapshot.SuccessCount, snapshot.FailureCount)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// singleLockStatistics is the previous RequestStatistics layout, one mutex around all maps,
+// kept as the baseline for BenchmarkRequestStatisticsRecord.
+type singleLockStatistics struct {
+	mu            sync.Mutex
+	totalRequests int64
+	totalTokens   int64
+	apis          map[string]*apiStats
+	requestsByDay map[string]int64
+	tokensByDay   map[string]int64
+}
+
+func (s *singleLockStatistics) record(apiName, modelName string, detail RequestDetail) {
+	dayKey := detail.Timestamp.Format("2006-01-02")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.totalRequests++
+	s.totalTokens += detail.Tokens.TotalTokens
+	stats, ok := s.apis[apiName]
+	if !ok {
+		stats = &apiStats{Models: make(map[string]*modelStats)}
+		s.apis[apiName] = stats
+	}
+	model, ok := stats.Models[modelName]
+	if !ok {
+		model = &modelStats{}
+		stats.Models[modelName] = model
+	}
+	model.TotalRequests++
+	model.Details = append(model.Details, detail)
+	s.requestsByDay[dayKey]++
+	s.tokensByDay[dayKey] += detail.Tokens.TotalTokens
+}
+
+func BenchmarkRequestStatisticsRecord(b *testing.B) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	record := coreusage.Record{APIKey: "key", Model: "gpt-5", RequestedAt: time.Now(), Detail: coreusage.Detail{TotalTokens: 42}}
+	detail := RequestDetail{Timestamp: record.RequestedAt, Tokens: TokenStats{TotalTokens: 42}}
+	for _, goroutines := range []int{1, 8, 64} {
+		b.Run(fmt.Sprintf("single-lock/goroutines-%d", goroutines), func(b *testing.B) {
+			stats := &singleLockStatistics{apis: make(map[string]*apiStats), requestsByDay: make(map[string]int64), tokensByDay: make(map[string]int64)}
+			runParallel(b, goroutines, func() { stats.record("key", "gpt-5", detail) })
+		})
+		b.Run(fmt.Sprintf("sharded/goroutines-%d", goroutines), func(b *testing.B) {
+			stats := NewRequestStatistics()
+			runParallel(b, goroutines, func() { stats.Record(context.Background(), record) })
+		})
+	}
+}
+
+// runParallel calls fn b.N times in total, split across the given number of goroutines.
+func runParallel(b *testing.B, goroutines int, fn func()) {
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	per := b.N / goroutines
+	for g := 0; g < goroutines; g++ {
+		n := per
+		if g == 0 {
+			n += b.N % goroutines
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				fn()
+			}
+		}()
+	}
+	wg.Wait()
+}
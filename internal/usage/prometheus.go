@@ -0,0 +1,126 @@
+package usage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WritePrometheus renders snapshot in the Prometheus text exposition format. Series are
+// labelled by model only: API keys stay out of the metrics.
+func WritePrometheus(w io.Writer, snapshot StatisticsSnapshot) error {
+	out := bufio.NewWriter(w)
+	writeMetricHeader(out, "cliproxy_requests_total", "counter", "Requests recorded, by result.")
+	fmt.Fprintf(out, "cliproxy_requests_total{result=\"success\"} %d\n", snapshot.SuccessCount)
+	fmt.Fprintf(out, "cliproxy_requests_total{result=\"failure\"} %d\n", snapshot.FailureCount)
+	writeMetricHeader(out, "cliproxy_tokens_total", "counter", "Tokens recorded.")
+	fmt.Fprintf(out, "cliproxy_tokens_total %d\n", snapshot.TotalTokens)
+	writeMetricHeader(out, "cliproxy_cache_hits_total", "counter", "Requests served from the response cache.")
+	fmt.Fprintf(out, "cliproxy_cache_hits_total %d\n", snapshot.CacheHits)
+
+	requests := make(map[string]int64)
+	tokens := make(map[string]int64)
+	failures := make(map[string]int64)
+	latencies := make(map[string]*LatencyHistogram)
+	firstTokens := make(map[string]*LatencyHistogram)
+	for _, api := range snapshot.APIs {
+		for model, stats := range api.Models {
+			requests[model] += stats.TotalRequests
+			tokens[model] += stats.TotalTokens
+			failures[model] += stats.FailureCount
+			mergeHistogram(latencies, model, stats.Latency)
+			mergeHistogram(firstTokens, model, stats.FirstToken)
+		}
+	}
+	models := make([]string, 0, len(requests))
+	for model := range requests {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	writeMetricHeader(out, "cliproxy_model_requests_total", "counter", "Requests recorded, by model.")
+	for _, model := range models {
+		fmt.Fprintf(out, "cliproxy_model_requests_total{model=\"%s\"} %d\n", escapeLabelValue(model), requests[model])
+	}
+	writeMetricHeader(out, "cliproxy_model_tokens_total", "counter", "Tokens recorded, by model.")
+	for _, model := range models {
+		fmt.Fprintf(out, "cliproxy_model_tokens_total{model=\"%s\"} %d\n", escapeLabelValue(model), tokens[model])
+	}
+	writeMetricHeader(out, "cliproxy_model_failures_total", "counter", "Failed requests recorded, by model.")
+	for _, model := range models {
+		fmt.Fprintf(out, "cliproxy_model_failures_total{model=\"%s\"} %d\n", escapeLabelValue(model), failures[model])
+	}
+	writeMetricHeader(out, "cliproxy_request_duration_seconds", "histogram", "Latency of generation requests, by model.")
+	for _, model := range models {
+		if histogram := latencies[model]; histogram != nil {
+			writeHistogram(out, "cliproxy_request_duration_seconds", escapeLabelValue(model), histogram)
+		}
+	}
+	writeMetricHeader(out, "cliproxy_request_duration_quantile_seconds", "gauge", "Estimated latency percentiles of generation requests, by model.")
+	for _, model := range models {
+		writeQuantiles(out, "cliproxy_request_duration_quantile_seconds", escapeLabelValue(model), latencies[model])
+	}
+	writeMetricHeader(out, "cliproxy_time_to_first_token_seconds", "histogram", "Time until the first chunk of streamed generation requests, by model.")
+	for _, model := range models {
+		if histogram := firstTokens[model]; histogram != nil {
+			writeHistogram(out, "cliproxy_time_to_first_token_seconds", escapeLabelValue(model), histogram)
+		}
+	}
+	writeMetricHeader(out, "cliproxy_time_to_first_token_quantile_seconds", "gauge", "Estimated time to first token percentiles of streamed requests, by model.")
+	for _, model := range models {
+		writeQuantiles(out, "cliproxy_time_to_first_token_quantile_seconds", escapeLabelValue(model), firstTokens[model])
+	}
+	return out.Flush()
+}
+
+func mergeHistogram(into map[string]*LatencyHistogram, model string, histogram *LatencyHistogram) {
+	if histogram == nil {
+		return
+	}
+	if into[model] == nil {
+		into[model] = &LatencyHistogram{}
+	}
+	into[model].merge(*histogram)
+}
+
+// writeQuantiles writes the estimated p50, p95 and p99 of one model's histogram, if any.
+func writeQuantiles(out *bufio.Writer, name, model string, histogram *LatencyHistogram) {
+	if histogram == nil {
+		return
+	}
+	for _, q := range []struct {
+		label string
+		value float64
+	}{{"0.5", 0.5}, {"0.95", 0.95}, {"0.99", 0.99}} {
+		fmt.Fprintf(out, "%s{model=\"%s\",quantile=\"%s\"} %s\n", name, model, q.label, strconv.FormatFloat(histogram.quantile(q.value), 'g', -1, 64))
+	}
+}
+
+// writeHistogram writes the cumulative buckets, sum and count of one model's histogram.
+func writeHistogram(out *bufio.Writer, name, model string, histogram *LatencyHistogram) {
+	var cumulative int64
+	for i, bound := range LatencyBuckets {
+		cumulative += histogram.Buckets[i]
+		fmt.Fprintf(out, "%s_bucket{model=\"%s\",le=\"%s\"} %d\n", name, model, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(out, "%s_bucket{model=\"%s\",le=\"+Inf\"} %d\n", name, model, histogram.Count)
+	fmt.Fprintf(out, "%s_sum{model=\"%s\"} %s\n", name, model, strconv.FormatFloat(histogram.SumSeconds, 'g', -1, 64))
+	fmt.Fprintf(out, "%s_count{model=\"%s\"} %d\n", name, model, histogram.Count)
+}
+
+// WriteMetrics renders the statistics of s with WritePrometheus. Like Pushgateway.Push it
+// reports this replica's own statistics, never the totals shared through usage-redis, so that
+// the series of several scraped replicas add up.
+func (s *RequestStatistics) WriteMetrics(w io.Writer) error {
+	return WritePrometheus(w, s.localSnapshot())
+}
+
+func writeMetricHeader(out *bufio.Writer, name, kind, help string) {
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string { return labelValueEscaper.Replace(value) }
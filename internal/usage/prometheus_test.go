@@ -0,0 +1,100 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestWritePrometheusReportsFailuresAndLatency(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	stats := NewRequestStatistics()
+	ctx := context.Background()
+	// The same model under two keys is reported as one series.
+	stats.Record(ctx, coreusage.Record{APIKey: "a", Model: "gpt-5", Latency: 300 * time.Millisecond, Detail: coreusage.Detail{TotalTokens: 10}})
+	stats.Record(ctx, coreusage.Record{APIKey: "b", Model: "gpt-5", Latency: 7 * time.Second, Failed: true})
+	stats.Record(ctx, coreusage.Record{APIKey: "b", Model: "gpt-5", Latency: 10 * time.Minute, Detail: coreusage.Detail{TotalTokens: 5}})
+	// Token counting calls and records without a latency stay out of the histogram.
+	stats.Record(ctx, coreusage.Record{APIKey: "a", Model: "gpt-5", Latency: time.Second, RequestType: coreusage.RequestTypeCountTokens, Detail: coreusage.Detail{InputTokens: 3}})
+	stats.Record(ctx, coreusage.Record{APIKey: "a", Model: "gpt-5", Detail: coreusage.Detail{TotalTokens: 1}})
+
+	var out bytes.Buffer
+	if err := stats.WriteMetrics(&out); err != nil {
+		t.Fatalf("WriteMetrics: %v", err)
+	}
+	body := out.String()
+	for _, want := range []string{
+		`cliproxy_model_requests_total{model="gpt-5"} 5`,
+		`cliproxy_model_failures_total{model="gpt-5"} 1`,
+		`cliproxy_request_duration_seconds_bucket{model="gpt-5",le="0.25"} 0`,
+		`cliproxy_request_duration_seconds_bucket{model="gpt-5",le="0.5"} 1`,
+		`cliproxy_request_duration_seconds_bucket{model="gpt-5",le="10"} 2`,
+		`cliproxy_request_duration_seconds_bucket{model="gpt-5",le="300"} 2`,
+		`cliproxy_request_duration_seconds_bucket{model="gpt-5",le="+Inf"} 3`,
+		`cliproxy_request_duration_seconds_sum{model="gpt-5"} 607.3`,
+		`cliproxy_request_duration_seconds_count{model="gpt-5"} 3`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+
+	// Histograms are rebuilt from the latencies kept in the details.
+	restored := NewRequestStatistics()
+	restored.MergeSnapshot(stats.Snapshot())
+	histogram := restored.Snapshot().APIs["b"].Models["gpt-5"].Latency
+	if histogram == nil || histogram.Count != 2 {
+		t.Fatalf("restored histogram = %+v, want two requests", histogram)
+	}
+}
+
+func TestLatencyPercentilesAndTimeToFirstToken(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	stats := NewRequestStatistics()
+	ctx := context.Background()
+	// 100 requests of 1..100 ms, under two keys, all streamed with a first token after 40 ms.
+	for i := 1; i <= 100; i++ {
+		key := "a"
+		if i%2 == 0 {
+			key = "b"
+		}
+		stats.Record(ctx, coreusage.Record{APIKey: key, Model: "gpt-5", Latency: time.Duration(i) * time.Millisecond, FirstTokenLatency: 40 * time.Millisecond})
+	}
+	near := func(got, want float64) bool { return got > want*0.88 && got < want*1.12 }
+
+	models := stats.Snapshot().ModelBreakdown()
+	if len(models) != 1 || models[0].Latency == nil || models[0].FirstToken == nil {
+		t.Fatalf("model breakdown = %+v, want one model with both histograms", models)
+	}
+	latency := models[0].Latency
+	if !near(latency.P50Seconds, 0.050) || !near(latency.P95Seconds, 0.095) || !near(latency.P99Seconds, 0.099) {
+		t.Fatalf("percentiles = %g/%g/%g, want about 50/95/99 ms", latency.P50Seconds, latency.P95Seconds, latency.P99Seconds)
+	}
+	if first := models[0].FirstToken; first.Count != 100 || !near(first.P99Seconds, 0.040) {
+		t.Fatalf("first token = %+v, want 100 requests at about 40 ms", first)
+	}
+
+	var out bytes.Buffer
+	if err := stats.WriteMetrics(&out); err != nil {
+		t.Fatalf("WriteMetrics: %v", err)
+	}
+	for _, want := range []string{
+		`cliproxy_request_duration_quantile_seconds{model="gpt-5",quantile="0.95"} `,
+		`cliproxy_time_to_first_token_seconds_count{model="gpt-5"} 100`,
+		`cliproxy_time_to_first_token_quantile_seconds{model="gpt-5",quantile="0.5"} `,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("metrics missing %q:\n%s", want, out.String())
+		}
+	}
+}
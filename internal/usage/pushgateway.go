@@ -0,0 +1,181 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// pushTimeout bounds a single request to the Pushgateway.
+const pushTimeout = 10 * time.Second
+
+// pushMaxBackoff caps the delay between pushes while the Pushgateway keeps failing.
+const pushMaxBackoff = 5 * time.Minute
+
+// Pushgateway pushes the usage metrics to a Prometheus Pushgateway group on its own interval.
+// Pushes run one at a time on a single goroutine; after a failure the delay doubles, up to
+// pushMaxBackoff, and returns to the interval once a push succeeds.
+type Pushgateway struct {
+	groupURL string
+	headers  map[string]string
+	interval time.Duration
+	deleteOn bool
+	stats    *RequestStatistics
+	client   *http.Client
+
+	// failures counts consecutive failed pushes; only the push loop touches it.
+	failures int
+
+	started  atomic.Bool
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// NewPushgateway builds a pusher for cfg that reports stats, nil meaning the shared
+// statistics store.
+func NewPushgateway(cfg config.PushgatewayConfig, stats *RequestStatistics) *Pushgateway {
+	if stats == nil {
+		stats = defaultRequestStatistics
+	}
+	return &Pushgateway{
+		groupURL: pushGroupURL(cfg),
+		headers:  cfg.Headers,
+		interval: cfg.IntervalDuration(),
+		deleteOn: cfg.DeleteOnShutdown,
+		stats:    stats,
+		client:   &http.Client{Timeout: pushTimeout},
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+}
+
+// pushGroupURL returns <url>/metrics/job/<job>/instance/<instance>. Label values holding a
+// slash, which the path cannot carry, use the base64 form the Pushgateway accepts.
+func pushGroupURL(cfg config.PushgatewayConfig) string {
+	segment := func(name, value string) string {
+		if value == "" || strings.Contains(value, "/") {
+			return name + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+		}
+		return name + "/" + value
+	}
+	base := strings.TrimRight(strings.TrimSpace(cfg.URL), "/")
+	return base + "/metrics/" + segment("job", cfg.JobOrDefault()) + "/" + segment("instance", cfg.InstanceOrDefault())
+}
+
+// Start launches the push loop.
+func (p *Pushgateway) Start() {
+	if p == nil || !p.started.CompareAndSwap(false, true) {
+		return
+	}
+	log.Infof("metrics: pushing to %s every %s", p.groupURL, p.interval)
+	go p.run()
+}
+
+func (p *Pushgateway) run() {
+	defer close(p.doneCh)
+	timer := time.NewTimer(p.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case <-timer.C:
+			timer.Reset(p.pushAndBackoff())
+		}
+	}
+}
+
+// pushAndBackoff pushes once and returns how long to wait before the next push.
+func (p *Pushgateway) pushAndBackoff() time.Duration {
+	err := p.Push(context.Background())
+	if err == nil {
+		if p.failures > 0 {
+			log.Infof("metrics: push to %s succeeded again", p.groupURL)
+		}
+		p.failures = 0
+		return p.interval
+	}
+	if p.failures == 0 {
+		log.Warnf("metrics: %v; retrying with backoff", err)
+	}
+	p.failures++
+	delay := p.interval
+	for i := 0; i < p.failures && delay < pushMaxBackoff; i++ {
+		delay *= 2
+	}
+	return max(min(delay, pushMaxBackoff), p.interval)
+}
+
+// Push replaces the group's metrics with the current statistics. Only this replica's own
+// statistics are pushed, never the totals shared through usage-redis, so that the instance
+// groups of several replicas add up.
+func (p *Pushgateway) Push(ctx context.Context) error {
+	var body bytes.Buffer
+	if err := WritePrometheus(&body, p.stats.localSnapshot()); err != nil {
+		return fmt.Errorf("push to %s: %w", p.groupURL, err)
+	}
+	return p.do(ctx, http.MethodPut, &body)
+}
+
+// Delete removes the group from the Pushgateway.
+func (p *Pushgateway) Delete(ctx context.Context) error {
+	return p.do(ctx, http.MethodDelete, nil)
+}
+
+func (p *Pushgateway) do(ctx context.Context, method string, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, method, p.groupURL, body)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", strings.ToLower(method), p.groupURL, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	}
+	for name, value := range p.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", strings.ToLower(method), p.groupURL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: status %d: %s", strings.ToLower(method), p.groupURL, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// Stop ends the push loop, pushes the final statistics and, with delete-on-shutdown, then
+// deletes the group.
+func (p *Pushgateway) Stop() error {
+	if p == nil {
+		return nil
+	}
+	var err error
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+		if p.started.Load() {
+			<-p.doneCh
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+		defer cancel()
+		err = p.Push(ctx)
+		if p.deleteOn {
+			if errDelete := p.Delete(ctx); err == nil {
+				err = errDelete
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,91 @@
+package usage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestPushgatewayPushesGroupAndDeletesOnStop(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	var mu sync.Mutex
+	var calls []string
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		calls = append(calls, r.Method+" "+r.URL.Path+" "+r.Header.Get("Authorization"))
+		if r.Method == http.MethodPut {
+			body = string(data)
+		}
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	stats := NewRequestStatistics()
+	stats.Record(context.Background(), coreusage.Record{APIKey: "key", Model: `gpt-"5"`, Detail: coreusage.Detail{TotalTokens: 42}})
+	pusher := NewPushgateway(config.PushgatewayConfig{
+		URL:              server.URL + "/",
+		Instance:         "10.0.0.1/eu",
+		Headers:          map[string]string{"Authorization": "Bearer t"},
+		DeleteOnShutdown: true,
+	}, stats)
+	if err := pusher.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	group := "/metrics/job/cli-proxy-api/instance@base64/MTAuMC4wLjEvZXU"
+	if len(calls) != 2 || calls[0] != "PUT "+group+" Bearer t" || calls[1] != "DELETE "+group+" Bearer t" {
+		t.Fatalf("expected a final push then a delete of the group, got %v", calls)
+	}
+	for _, want := range []string{
+		"cliproxy_requests_total{result=\"success\"} 1\n",
+		"cliproxy_tokens_total 42\n",
+		`cliproxy_model_requests_total{model="gpt-\"5\""} 1` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("pushed metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestPushgatewayBacksOffWhileFailing(t *testing.T) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	pusher := NewPushgateway(config.PushgatewayConfig{URL: server.URL, Interval: "1m"}, NewRequestStatistics())
+
+	var delays []time.Duration
+	for i := 0; i < 5; i++ {
+		delays = append(delays, pusher.pushAndBackoff())
+	}
+	want := []time.Duration{2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i := range want {
+		if delays[i] != want[i] {
+			t.Fatalf("delays = %v, want %v", delays, want)
+		}
+	}
+	healthy.Store(true)
+	if delay := pusher.pushAndBackoff(); delay != time.Minute {
+		t.Fatalf("a successful push should reset the delay to the interval, got %s", delay)
+	}
+}
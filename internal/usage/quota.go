@@ -0,0 +1,264 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/notify"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+var defaultQuotaTracker = NewQuotaTracker()
+
+func init() {
+	coreusage.RegisterPlugin(defaultQuotaTracker)
+}
+
+// GetQuotaTracker returns the shared daily quota tracker.
+func GetQuotaTracker() *QuotaTracker { return defaultQuotaTracker }
+
+// QuotaTracker counts requests per account per provider quota day and tells the router
+// which accounts are close to their declared daily limit. It implements coreusage.Plugin
+// to receive records and coreauth.QuotaGuard to filter credentials.
+type QuotaTracker struct {
+	mu       sync.Mutex
+	limits   map[string]config.DailyQuota
+	counters map[string]*quotaCounter
+	// shared holds the requests counted since the last push to a RedisUsageStore; nil while
+	// no store shares the counters.
+	shared *usageDelta
+}
+
+type quotaCounter struct {
+	provider string
+	day      string
+	count    int64
+	warned   bool
+}
+
+// QuotaCounterSnapshot is the persisted form of one account's daily counter.
+type QuotaCounterSnapshot struct {
+	Account  string `json:"account"`
+	Provider string `json:"provider"`
+	Day      string `json:"day"`
+	Requests int64  `json:"requests"`
+}
+
+// QuotaStatus describes an account's position against its daily limit.
+type QuotaStatus struct {
+	Limit     int64     `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	Margin    int64     `json:"margin"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// NewQuotaTracker constructs an empty tracker with no limits configured.
+func NewQuotaTracker() *QuotaTracker {
+	return &QuotaTracker{
+		limits:   make(map[string]config.DailyQuota),
+		counters: make(map[string]*quotaCounter),
+	}
+}
+
+// SetLimits replaces the configured provider limits, typically on config load or reload.
+func (t *QuotaTracker) SetLimits(quotas []config.DailyQuota) {
+	if t == nil {
+		return
+	}
+	limits := make(map[string]config.DailyQuota, len(quotas))
+	for _, quota := range quotas {
+		provider := strings.ToLower(strings.TrimSpace(quota.Provider))
+		if provider == "" || quota.DailyRequests <= 0 {
+			continue
+		}
+		limits[provider] = quota
+	}
+	t.mu.Lock()
+	t.limits = limits
+	t.mu.Unlock()
+}
+
+// HandleUsage implements coreusage.Plugin by counting the request against its account.
+func (t *QuotaTracker) HandleUsage(_ context.Context, record coreusage.Record) {
+	if t == nil {
+		return
+	}
+	if record.RequestType == coreusage.RequestTypeCountTokens {
+		// Token counting does not draw on an account's generation quota.
+		return
+	}
+	account := quotaAccountKey(record.AuthID)
+	if account == "" {
+		return
+	}
+	provider := strings.ToLower(strings.TrimSpace(record.Provider))
+	now := record.RequestedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	quota := t.quotaFor(provider)
+	day := quotaDay(quota, now)
+	counter := t.counters[account]
+	if counter == nil || counter.day != day {
+		counter = &quotaCounter{provider: provider, day: day}
+		t.counters[account] = counter
+	}
+	counter.count++
+	if t.shared != nil {
+		t.shared.incr(redisQuotaHash(day), account, 1)
+	}
+	if quota.DailyRequests > 0 && !counter.warned && quota.DailyRequests-counter.count <= quota.Margin {
+		counter.warned = true
+		log.Infof("daily quota: account %s used %d of %d %s requests; skipping it until the quota resets", account, counter.count, quota.DailyRequests, provider)
+		notify.Publish(notify.Event{
+			Type:     config.NotifyUsageAlert,
+			Account:  account,
+			Provider: provider,
+			Message:  fmt.Sprintf("used %d of %d daily requests; out of rotation until %s", counter.count, quota.DailyRequests, quotaResetAt(quota, now).Format(time.RFC3339)),
+		})
+	}
+}
+
+// NearLimit implements coreauth.QuotaGuard.
+func (t *QuotaTracker) NearLimit(auth *coreauth.Auth, now time.Time) (bool, time.Time) {
+	status, ok := t.Status(auth, now)
+	if !ok {
+		return false, time.Time{}
+	}
+	return status.Remaining <= status.Margin, status.ResetsAt
+}
+
+// Status reports the account's daily usage against its limit. ok is false when no limit applies.
+func (t *QuotaTracker) Status(auth *coreauth.Auth, now time.Time) (QuotaStatus, bool) {
+	if t == nil || auth == nil {
+		return QuotaStatus{}, false
+	}
+	provider := strings.ToLower(strings.TrimSpace(auth.Provider))
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	quota := t.quotaFor(provider)
+	if override := metadataDailyRequests(auth.Metadata); override > 0 {
+		quota.DailyRequests = override
+	}
+	if quota.DailyRequests <= 0 {
+		return QuotaStatus{}, false
+	}
+	var used int64
+	if counter := t.counters[quotaAccountKey(auth.ID)]; counter != nil && counter.day == quotaDay(quota, now) {
+		used = counter.count
+	}
+	remaining := quota.DailyRequests - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return QuotaStatus{
+		Limit:     quota.DailyRequests,
+		Used:      used,
+		Remaining: remaining,
+		Margin:    quota.Margin,
+		ResetsAt:  quotaResetAt(quota, now),
+	}, true
+}
+
+// Snapshot returns the counters for persistence.
+func (t *QuotaTracker) Snapshot() []QuotaCounterSnapshot {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]QuotaCounterSnapshot, 0, len(t.counters))
+	for account, counter := range t.counters {
+		out = append(out, QuotaCounterSnapshot{
+			Account:  account,
+			Provider: counter.provider,
+			Day:      counter.day,
+			Requests: counter.count,
+		})
+	}
+	return out
+}
+
+// Restore merges persisted counters, keeping the larger count when both sides cover the
+// same quota day so a restart never lowers the day's usage.
+func (t *QuotaTracker) Restore(snapshots []QuotaCounterSnapshot) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, snap := range snapshots {
+		if snap.Account == "" || snap.Day == "" {
+			continue
+		}
+		current := t.counters[snap.Account]
+		if current != nil && current.day > snap.Day {
+			continue
+		}
+		if current != nil && current.day == snap.Day && current.count >= snap.Requests {
+			continue
+		}
+		t.counters[snap.Account] = &quotaCounter{provider: snap.Provider, day: snap.Day, count: snap.Requests}
+	}
+}
+
+// quotaFor returns the configured limit for provider; callers must hold t.mu.
+func (t *QuotaTracker) quotaFor(provider string) config.DailyQuota {
+	if quota, ok := t.limits[provider]; ok {
+		return quota
+	}
+	return config.DailyQuota{Provider: provider}
+}
+
+// quotaAccountKey maps Gemini virtual project auths ("parent::project") to their parent,
+// since the provider counts the quota against the Google account rather than the project.
+func quotaAccountKey(authID string) string {
+	authID = strings.TrimSpace(authID)
+	if parent, _, found := strings.Cut(authID, "::"); found {
+		return parent
+	}
+	return authID
+}
+
+func quotaDay(quota config.DailyQuota, now time.Time) string {
+	return now.In(quota.ResetLocation()).Format("2006-01-02")
+}
+
+func quotaResetAt(quota config.DailyQuota, now time.Time) time.Time {
+	local := now.In(quota.ResetLocation())
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	return midnight.AddDate(0, 0, 1)
+}
+
+// metadataDailyRequests reads the per-account "daily_requests" override from auth metadata.
+func metadataDailyRequests(metadata map[string]any) int64 {
+	if metadata == nil {
+		return 0
+	}
+	raw, ok := metadata["daily_requests"]
+	if !ok {
+		raw = metadata["daily-requests"]
+	}
+	switch v := raw.(type) {
+	case float64:
+		return int64(v)
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case string:
+		parsed, _ := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return parsed
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestQuotaTrackerNearLimitAndReset(t *testing.T) {
+	tracker := NewQuotaTracker()
+	tracker.SetLimits([]config.DailyQuota{{Provider: "gemini-cli", DailyRequests: 3, Margin: 1}})
+	pacific, _ := time.LoadLocation("America/Los_Angeles")
+	now := time.Date(2026, 3, 10, 23, 0, 0, 0, pacific)
+	auth := &coreauth.Auth{ID: "user.json", Provider: "gemini-cli"}
+
+	// Requests through a virtual project auth count against the parent account.
+	record := coreusage.Record{Provider: "gemini-cli", AuthID: "user.json::proj-a", RequestedAt: now}
+	tracker.HandleUsage(context.Background(), record)
+	if near, _ := tracker.NearLimit(auth, now); near {
+		t.Fatal("account should stay in rotation after one of three requests")
+	}
+	tracker.HandleUsage(context.Background(), record)
+	near, resetAt := tracker.NearLimit(auth, now)
+	if !near {
+		t.Fatal("account should be skipped once remaining requests reach the margin")
+	}
+	if want := time.Date(2026, 3, 11, 0, 0, 0, 0, pacific); !resetAt.Equal(want) {
+		t.Fatalf("resetAt = %s, want %s", resetAt, want)
+	}
+	if near, _ = tracker.NearLimit(auth, now.Add(2*time.Hour)); near {
+		t.Fatal("counter should reset at midnight Pacific")
+	}
+}
+
+func TestQuotaTrackerIgnoresCountTokens(t *testing.T) {
+	tracker := NewQuotaTracker()
+	tracker.SetLimits([]config.DailyQuota{{Provider: "gemini", DailyRequests: 1}})
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	auth := &coreauth.Auth{ID: "gemini.json", Provider: "gemini"}
+
+	record := coreusage.Record{Provider: "gemini", AuthID: "gemini.json", RequestedAt: now, RequestType: coreusage.RequestTypeCountTokens}
+	tracker.HandleUsage(context.Background(), record)
+	if status, _ := tracker.Status(auth, now); status.Used != 0 {
+		t.Fatalf("token counting used %d requests of the daily quota", status.Used)
+	}
+}
+
+func TestQuotaTrackerMetadataOverrideAndRestore(t *testing.T) {
+	tracker := NewQuotaTracker()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	auth := &coreauth.Auth{ID: "codex.json", Provider: "codex", Metadata: map[string]any{"daily_requests": float64(10)}}
+	tracker.Restore([]QuotaCounterSnapshot{{Account: "codex.json", Provider: "codex", Day: "2026-03-10", Requests: 7}})
+
+	status, ok := tracker.Status(auth, now)
+	if !ok {
+		t.Fatal("expected the auth file limit to apply without provider config")
+	}
+	if status.Used != 7 || status.Remaining != 3 || status.Limit != 10 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if _, ok = tracker.Status(&coreauth.Auth{ID: "other.json", Provider: "codex"}, now); ok {
+		t.Fatal("accounts without a limit should report no quota")
+	}
+}
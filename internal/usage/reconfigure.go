@@ -0,0 +1,88 @@
+package usage
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// persistenceSettings are the usage-persistence settings a running plugin takes over from a
+// reloaded configuration.
+type persistenceSettings struct {
+	interval      time.Duration
+	keepDays      int
+	maxFileSizeMB int
+}
+
+// Reconfigure applies a reloaded usage-persistence section. The save interval, except under
+// leader election where it is the lease, and the retention limits change in place; a save in
+// progress finishes under the old ones. The other settings decide where statistics live and
+// what Load read, so their changes are logged and wait for a restart.
+func (p *FileUsagePlugin) Reconfigure(previous, next config.UsagePersistence) {
+	if p == nil || reflect.DeepEqual(previous, next) {
+		return
+	}
+	if fields := persistenceRestartFields(previous, next, p.election != nil); len(fields) > 0 {
+		log.Warnf("usage persistence: changes to %s take effect after a restart", strings.Join(fields, ", "))
+	}
+	settings := persistenceSettings{keepDays: next.KeepDays, maxFileSizeMB: next.MaxFileSizeMB}
+	if p.election != nil {
+		settings.interval = -1
+	} else {
+		interval, err := next.ResolveSaveInterval()
+		if err != nil {
+			log.Warnf("usage persistence: %v; keeping the current save interval", err)
+			interval = -1
+		}
+		settings.interval = interval
+	}
+	if !p.started.Load() {
+		p.applySettings(settings)
+		return
+	}
+	// Only the latest settings matter to the worker.
+	select {
+	case <-p.reconfigure:
+	default:
+	}
+	p.reconfigure <- settings
+}
+
+// applySettings takes over settings, a negative interval keeping the current one, and reports
+// whether the interval changed. While the plugin runs only the save worker calls it.
+func (p *FileUsagePlugin) applySettings(settings persistenceSettings) bool {
+	p.SetRetention(settings.keepDays, settings.maxFileSizeMB)
+	if settings.interval < 0 || settings.interval == p.interval {
+		return false
+	}
+	p.interval = settings.interval
+	if p.interval > 0 {
+		log.Infof("usage persistence: now saving statistics to %s every %s", p.path, p.interval)
+	} else {
+		log.Infof("usage persistence: save-interval is 0, statistics will be saved to %s only on shutdown", p.path)
+	}
+	return true
+}
+
+// persistenceRestartFields names the settings that differ between previous and next and are
+// only read at startup.
+func persistenceRestartFields(previous, next config.UsagePersistence, leaderElection bool) []string {
+	var fields []string
+	add := func(name string, changed bool) {
+		if changed {
+			fields = append(fields, name)
+		}
+	}
+	add("file", previous.File != next.File)
+	add("spool-dir", previous.SpoolDir != next.SpoolDir)
+	add("leader-election", previous.LeaderElection != next.LeaderElection)
+	add("save-interval", leaderElection && previous.SaveInterval != next.SaveInterval)
+	add("max-corrupt-backups", previous.MaxCorruptBackups != next.MaxCorruptBackups)
+	add("persist-fallback", previous.PersistFallback != next.PersistFallback)
+	add("journal", previous.Journal != next.Journal)
+	add("key-identifiers", previous.KeyIdentifierMode() != next.KeyIdentifierMode())
+	return fields
+}
@@ -0,0 +1,47 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestFileUsagePluginReconfigureChangesSaveInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	plugin := NewFileUsagePlugin(path, 0, NewRequestStatistics())
+	plugin.quotas, plugin.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+	plugin.Start()
+	defer func() { _ = plugin.Stop() }()
+
+	previous := config.UsagePersistence{File: path, SaveInterval: "0"}
+	next := previous
+	next.SaveInterval, next.KeepDays = "10ms", 30
+	plugin.Reconfigure(previous, next)
+	plugin.dirty.Store(true)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no periodic save after the save interval was reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPersistenceRestartFields(t *testing.T) {
+	previous := config.UsagePersistence{File: "a.json", SaveInterval: "1m", KeepDays: 7}
+	next := config.UsagePersistence{File: "b.json", SaveInterval: "2m", KeepDays: 30, Journal: true}
+	got := persistenceRestartFields(previous, next, false)
+	if len(got) != 2 || got[0] != "file" || got[1] != "journal" {
+		t.Fatalf("restart fields = %v, want file and journal", got)
+	}
+	if got = persistenceRestartFields(previous, next, true); len(got) != 3 || got[1] != "save-interval" {
+		t.Fatalf("restart fields under leader election = %v, want the save interval too", got)
+	}
+}
@@ -0,0 +1,256 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// Hashes holding the quota counters, one per period: the period's day or month start is
+// appended, and fields are account IDs or client API keys.
+const (
+	redisQuotaRequests       = "quota:requests:"
+	redisKeyQuotaDayTokens   = "keyquota:day-tokens:"
+	redisKeyQuotaDayTopUp    = "keyquota:day-topup:"
+	redisKeyQuotaMonthTokens = "keyquota:month-tokens:"
+	redisKeyQuotaMonthTopUp  = "keyquota:month-topup:"
+	redisKeyQuotaMonthCost   = "keyquota:month-cost-micros:"
+)
+
+// redisQuotaDayTTL and redisQuotaMonthTTL are how long a period's hash outlives its last
+// write. Each write renews them, so they only need to cover the rest of the period.
+const (
+	redisQuotaDayTTL   = 48 * time.Hour
+	redisQuotaMonthTTL = 35 * 24 * time.Hour
+)
+
+func redisQuotaHash(day string) string { return redisQuotaRequests + day }
+
+func redisQuotaTTL(hash string) time.Duration {
+	if strings.HasPrefix(hash, redisKeyQuotaMonthTokens) || strings.HasPrefix(hash, redisKeyQuotaMonthTopUp) ||
+		strings.HasPrefix(hash, redisKeyQuotaMonthCost) {
+		return redisQuotaMonthTTL
+	}
+	return redisQuotaDayTTL
+}
+
+// startSharing makes the tracker collect what it counts for a RedisUsageStore.
+func (t *QuotaTracker) startSharing() {
+	t.mu.Lock()
+	if t.shared == nil {
+		t.shared = newUsageDelta()
+	}
+	t.mu.Unlock()
+}
+
+func (t *QuotaTracker) stopSharing() {
+	t.mu.Lock()
+	t.shared = nil
+	t.mu.Unlock()
+}
+
+// takeShared returns what was counted since the last call. The store keeps it in its flush
+// batch until the batch is applied.
+func (t *QuotaTracker) takeShared() *usageDelta {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return takeDelta(&t.shared)
+}
+
+// sharedHashes lists the hashes of the quota days current at now for the configured
+// providers and those of the accounts counted here.
+func (t *QuotaTracker) sharedHashes(now time.Time) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	days := map[string]struct{}{quotaDay(config.DailyQuota{}, now): {}}
+	for provider := range t.limits {
+		days[quotaDay(t.quotaFor(provider), now)] = struct{}{}
+	}
+	for _, counter := range t.counters {
+		days[quotaDay(t.quotaFor(counter.provider), now)] = struct{}{}
+	}
+	hashes := make([]string, 0, len(days))
+	for day := range days {
+		hashes = append(hashes, redisQuotaHash(day))
+	}
+	return hashes
+}
+
+// applyShared sets the counters to the totals of every replica, plus what was counted here
+// since they were pushed. A counter never goes down, so losing the Redis data does not
+// refill a day's quota.
+func (t *QuotaTracker) applyShared(values map[string]map[string]int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for hash, accounts := range values {
+		day, ok := strings.CutPrefix(hash, redisQuotaRequests)
+		if !ok {
+			continue
+		}
+		for account, n := range accounts {
+			n += pendingField(t.shared, hash, account)
+			counter := t.counters[account]
+			switch {
+			case counter == nil || counter.day < day:
+				var provider string
+				if counter != nil {
+					provider = counter.provider
+				}
+				t.counters[account] = &quotaCounter{provider: provider, day: day, count: n}
+			case counter.day == day:
+				counter.count = max(counter.count, n)
+			}
+		}
+	}
+}
+
+// startSharing makes the tracker collect what it counts for a RedisUsageStore.
+func (t *KeyQuotaTracker) startSharing() {
+	t.mu.Lock()
+	if t.shared == nil {
+		t.shared = newUsageDelta()
+	}
+	t.mu.Unlock()
+}
+
+func (t *KeyQuotaTracker) stopSharing() {
+	t.mu.Lock()
+	t.shared = nil
+	t.mu.Unlock()
+}
+
+// takeShared returns what was counted since the last call. The store keeps it in its flush
+// batch until the batch is applied.
+func (t *KeyQuotaTracker) takeShared() *usageDelta {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return takeDelta(&t.shared)
+}
+
+// sharedHashes lists the hashes of the days and months current at now for the keys with a
+// quota.
+func (t *KeyQuotaTracker) sharedHashes(now time.Time) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seen := make(map[string]struct{})
+	var hashes []string
+	for _, quota := range t.limits {
+		day, month := keyQuotaDay(quota, now), keyQuotaMonth(quota, now)
+		for _, hash := range []string{
+			redisKeyQuotaDayTokens + day, redisKeyQuotaDayTopUp + day,
+			redisKeyQuotaMonthTokens + month, redisKeyQuotaMonthTopUp + month, redisKeyQuotaMonthCost + month,
+		} {
+			if _, ok := seen[hash]; !ok {
+				seen[hash] = struct{}{}
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+	return hashes
+}
+
+// applyShared sets the counters of the keys with a quota to the totals of every replica, plus
+// what was counted here since they were pushed. Like Restore it keeps the larger value, so
+// losing the Redis data never refills a budget.
+func (t *KeyQuotaTracker) applyShared(values map[string]map[string]int64, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for hash, keys := range values {
+		for apiKey, n := range keys {
+			quota, ok := t.limits[apiKey]
+			if !ok {
+				continue
+			}
+			n += pendingField(t.shared, hash, apiKey)
+			counter := t.counterLocked(apiKey, quota, now)
+			switch hash {
+			case redisKeyQuotaDayTokens + counter.day:
+				counter.dayTokens = max(counter.dayTokens, n)
+			case redisKeyQuotaDayTopUp + counter.day:
+				counter.dayTopUp = max(counter.dayTopUp, n)
+			case redisKeyQuotaMonthTokens + counter.month:
+				counter.monthTokens = max(counter.monthTokens, n)
+			case redisKeyQuotaMonthTopUp + counter.month:
+				counter.monthTopUp = max(counter.monthTopUp, n)
+			case redisKeyQuotaMonthCost + counter.month:
+				counter.monthCostMicros = max(counter.monthCostMicros, n)
+			}
+		}
+	}
+}
+
+// takeDelta swaps *delta for an empty one and returns it, or nil when sharing is off.
+func takeDelta(delta **usageDelta) *usageDelta {
+	taken := *delta
+	if taken != nil {
+		*delta = newUsageDelta()
+	}
+	return taken
+}
+
+func pendingField(delta *usageDelta, hash, field string) int64 {
+	if delta == nil {
+		return 0
+	}
+	return delta.fields[hash][field]
+}
+
+// queueQuotaIncrements adds the quota counters taken from the trackers to a flush
+// transaction, renewing the expiry of every hash it writes.
+func (r *RedisUsageStore) queueQuotaIncrements(ctx context.Context, pipe redis.Pipeliner, deltas ...*usageDelta) {
+	for _, delta := range deltas {
+		if delta == nil {
+			continue
+		}
+		for hash, fields := range delta.fields {
+			for field, n := range fields {
+				pipe.HIncrBy(ctx, r.prefix+hash, field, n)
+			}
+			pipe.Expire(ctx, r.prefix+hash, redisQuotaTTL(hash))
+		}
+	}
+}
+
+// pullQuotas reads the quota counters of the current periods and hands them to the trackers,
+// so that every replica routes and enforces budgets on the traffic of all of them.
+func (r *RedisUsageStore) pullQuotas(ctx context.Context) error {
+	now := time.Now()
+	hashes := append(r.quotas.sharedHashes(now), r.keyQuota.sharedHashes(now)...)
+	if len(hashes) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	cmds := make(map[string]*redis.MapStringStringCmd, len(hashes))
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, hash := range hashes {
+			cmds[hash] = pipe.HGetAll(ctx, r.prefix+hash)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("usage-redis: read quota counters: %w", err)
+	}
+	values := make(map[string]map[string]int64, len(cmds))
+	for hash, cmd := range cmds {
+		fields := make(map[string]int64, len(cmd.Val()))
+		for field, raw := range cmd.Val() {
+			n, errParse := strconv.ParseInt(raw, 10, 64)
+			if errParse != nil {
+				log.Debugf("usage-redis: ignoring quota counter %s %s=%q", hash, field, raw)
+				continue
+			}
+			fields[field] = n
+		}
+		values[hash] = fields
+	}
+	r.quotas.applyShared(values)
+	r.keyQuota.applyShared(values, now)
+	return nil
+}
@@ -0,0 +1,520 @@
+package usage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// redisTimeout bounds every round trip, so an outage slows a flush or a read by at most this.
+const redisTimeout = 2 * time.Second
+
+// redisPendingRecordsCap bounds the records buffered between two flushes.
+const redisPendingRecordsCap = 100000
+
+// redisBatchKey prefixes the marker a flush transaction sets for its batch, so a retry of a
+// batch whose EXEC reply was lost finds it applied. The markers expire after redisBatchTTL.
+const (
+	redisBatchKey = "batch:"
+	redisBatchTTL = 24 * time.Hour
+)
+
+// Hashes under the key prefix. Model fields join the API key and model with redisFieldSep.
+const (
+	redisTotalsKey        = "totals"
+	redisAPIRequestsKey   = "api:requests"
+	redisAPITokensKey     = "api:tokens"
+	redisModelRequestsKey = "model:requests"
+	redisModelTokensKey   = "model:tokens"
+	redisDayRequestsKey   = "day:requests"
+	redisDayTokensKey     = "day:tokens"
+	redisHourRequestsKey  = "hour:requests"
+	redisHourTokensKey    = "hour:tokens"
+	redisFieldSep         = "\x1f"
+)
+
+// Fields of the totals hash.
+const (
+	redisTotalRequests    = "requests"
+	redisTotalSuccess     = "success"
+	redisTotalFailure     = "failure"
+	redisTotalTokensField = "tokens"
+	redisTotalCacheHits   = "cache_hits"
+)
+
+var redisHashes = []string{
+	redisTotalsKey,
+	redisAPIRequestsKey, redisAPITokensKey,
+	redisModelRequestsKey, redisModelTokensKey,
+	redisDayRequestsKey, redisDayTokensKey,
+	redisHourRequestsKey, redisHourTokensKey,
+}
+
+// RedisUsageStore shares usage totals between replicas. It implements coreusage.Plugin:
+// records are buffered and pushed as hash increments every flush interval, and a
+// RequestStatistics it is attached to reads the shared totals back in Snapshot.
+//
+// The daily account quotas and the client key quotas are shared the same way: every flush
+// pushes what the trackers counted and reads back the totals of the current periods, so
+// routing and budgets follow the traffic of every replica within one flush interval.
+//
+// While Redis is unreachable the increments accumulate locally and are pushed together once
+// a flush succeeds again, so no replica's traffic is lost to an outage.
+type RedisUsageStore struct {
+	client   *redis.Client
+	prefix   string
+	interval time.Duration
+	stats    *RequestStatistics
+	quotas   *QuotaTracker
+	keyQuota *KeyQuotaTracker
+
+	mu      sync.Mutex
+	pending []pendingDetail
+	dropped int64
+
+	flushMu sync.Mutex
+	// unsent holds increments not yet part of a batch; guarded by flushMu.
+	unsent *usageDelta
+	// inflight is the batch a failed flush tried to push. It may have been applied, so it is
+	// retried unchanged before anything newer; guarded by flushMu.
+	inflight *redisBatch
+	// names are the API keys and models this replica has written, capped like the local
+	// aggregates; guarded by flushMu.
+	names    map[string]map[string]struct{}
+	degraded bool
+
+	started  atomic.Bool
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// redisBatch is one flush transaction: the usage and quota increments pushed together under
+// a random id.
+type redisBatch struct {
+	id        string
+	delta     *usageDelta
+	quotas    *usageDelta
+	keyQuotas *usageDelta
+}
+
+// NewRedisUsageStore builds a store for cfg that reports into stats, nil meaning the shared
+// statistics store. It does not connect until the first flush.
+func NewRedisUsageStore(cfg config.UsageRedis, stats *RequestStatistics) (*RedisUsageStore, error) {
+	if stats == nil {
+		stats = defaultRequestStatistics
+	}
+	options := &redis.Options{
+		Addr:         strings.TrimSpace(cfg.Address),
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  redisTimeout,
+		ReadTimeout:  redisTimeout,
+		WriteTimeout: redisTimeout,
+	}
+	if cfg.TLS.Enable {
+		tlsConfig, err := redisTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		options.TLSConfig = tlsConfig
+	}
+	return &RedisUsageStore{
+		client:   redis.NewClient(options),
+		prefix:   cfg.KeyPrefixOrDefault(),
+		interval: cfg.FlushIntervalDuration(),
+		stats:    stats,
+		quotas:   defaultQuotaTracker,
+		keyQuota: defaultKeyQuotaTracker,
+		unsent:   newUsageDelta(),
+		names:    make(map[string]map[string]struct{}),
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}, nil
+}
+
+func redisTLSConfig(cfg config.UsageRedisTLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         strings.TrimSpace(cfg.ServerName),
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if caFile := strings.TrimSpace(cfg.CAFile); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("usage-redis: read tls.ca-file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("usage-redis: tls.ca-file %s holds no PEM certificates", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// HandleUsage implements coreusage.Plugin by buffering the record for the next flush.
+func (r *RedisUsageStore) HandleUsage(ctx context.Context, record coreusage.Record) {
+	if r == nil || !statisticsEnabled.Load() {
+		return
+	}
+	r.enqueue([]pendingDetail{newPendingDetail(ctx, record)})
+}
+
+// enqueue buffers entries for the next flush. The buffer is capped so that a stuck flush
+// cannot grow it without bound; records beyond the cap only reach the local statistics.
+func (r *RedisUsageStore) enqueue(entries []pendingDetail) {
+	if r == nil || len(entries) == 0 {
+		return
+	}
+	r.mu.Lock()
+	room := redisPendingRecordsCap - len(r.pending)
+	if room < len(entries) {
+		r.dropped += int64(len(entries) - max(room, 0))
+		entries = entries[:max(room, 0)]
+	}
+	r.pending = append(r.pending, entries...)
+	r.mu.Unlock()
+}
+
+// Ping checks that Redis is reachable.
+func (r *RedisUsageStore) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	return r.client.Ping(ctx).Err()
+}
+
+// Start attaches the store to its statistics and launches the flush worker.
+func (r *RedisUsageStore) Start() {
+	if r == nil || !r.started.CompareAndSwap(false, true) {
+		return
+	}
+	r.stats.shared.Store(r)
+	r.quotas.startSharing()
+	r.keyQuota.startSharing()
+	log.Infof("usage-redis: sharing usage statistics under %q, flushing every %s", r.prefix, r.interval)
+	go r.run()
+}
+
+func (r *RedisUsageStore) run() {
+	defer close(r.doneCh)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stopCh:
+			return
+		case <-ticker.C:
+			_ = r.Flush(context.Background())
+		}
+	}
+}
+
+// Stop halts the flush worker, pushes what is left, detaches the store and closes the
+// connection.
+func (r *RedisUsageStore) Stop() error {
+	if r == nil {
+		return nil
+	}
+	var err error
+	r.stopOnce.Do(func() {
+		close(r.stopCh)
+		if r.started.Load() {
+			<-r.doneCh
+		}
+		err = r.Flush(context.Background())
+		r.stats.shared.CompareAndSwap(r, nil)
+		r.quotas.stopSharing()
+		r.keyQuota.stopSharing()
+		if errClose := r.client.Close(); err == nil {
+			err = errClose
+		}
+	})
+	return err
+}
+
+// Flush pushes the buffered records and quota counters, and whatever earlier failed flushes
+// left over, to Redis in one MULTI/EXEC transaction, so a failure never applies part of a
+// batch. Each batch is applied at most once, even when a retry follows a lost EXEC reply.
+// It then reads the shared quota counters back.
+func (r *RedisUsageStore) Flush(ctx context.Context) error {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+	if err := r.flushLocked(ctx); err != nil {
+		return err
+	}
+	return r.pullQuotas(ctx)
+}
+
+func (r *RedisUsageStore) flushLocked(ctx context.Context) error {
+	r.mu.Lock()
+	batch := r.pending
+	r.pending = nil
+	dropped := r.dropped
+	r.dropped = 0
+	r.mu.Unlock()
+	if dropped > 0 {
+		log.Warnf("usage-redis: %d records exceeded the local buffer and are missing from the shared totals", dropped)
+	}
+
+	maxKeys := r.stats.maxKeysLimit()
+	for _, entry := range batch {
+		apiName, modelName := r.placeNames(entry.apiName, entry.modelName, maxKeys)
+		r.unsent.add(apiName, modelName, entry.detail)
+	}
+
+	if r.inflight != nil {
+		if err := r.commit(ctx, r.inflight); err != nil {
+			return err
+		}
+		r.inflight = nil
+	}
+	quotas, keyQuotas := r.quotas.takeShared(), r.keyQuota.takeShared()
+	if r.unsent.empty() && (quotas == nil || quotas.empty()) && (keyQuotas == nil || keyQuotas.empty()) {
+		return nil
+	}
+	r.inflight = &redisBatch{id: newRedisBatchID(), delta: r.unsent, quotas: quotas, keyQuotas: keyQuotas}
+	r.unsent = newUsageDelta()
+	if err := r.commit(ctx, r.inflight); err != nil {
+		return err
+	}
+	r.inflight = nil
+	return nil
+}
+
+// commit applies batch unless its marker shows an earlier attempt already did. The marker is
+// watched, so an earlier EXEC landing late aborts this one instead of repeating it.
+func (r *RedisUsageStore) commit(ctx context.Context, batch *redisBatch) error {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	marker := r.prefix + redisBatchKey + batch.id
+	err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+		applied, errExists := tx.Exists(ctx, marker).Result()
+		if errExists != nil || applied > 0 {
+			return errExists
+		}
+		_, errExec := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, marker, 1, redisBatchTTL)
+			for hash, fields := range batch.delta.fields {
+				for field, n := range fields {
+					pipe.HIncrBy(ctx, r.prefix+hash, field, n)
+				}
+			}
+			r.queueQuotaIncrements(ctx, pipe, batch.quotas, batch.keyQuotas)
+			return nil
+		})
+		return errExec
+	}, marker)
+	if err != nil {
+		if !r.degraded {
+			r.degraded = true
+			log.Warnf("usage-redis: cannot reach redis (%v); counting locally until it is back", err)
+		}
+		return fmt.Errorf("usage-redis: flush: %w", err)
+	}
+	if r.degraded {
+		r.degraded = false
+		log.Infof("usage-redis: connection restored, pushed %d records counted during the outage", batch.delta.records)
+	}
+	return nil
+}
+
+// newRedisBatchID returns a random id for a flush batch.
+func newRedisBatchID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// placeNames keeps the distinct API keys, and models per key, this replica writes within
+// maxKeys, sending the rest to OverflowKey. Unlike the local aggregates nothing is evicted,
+// since other replicas may still be writing the same fields.
+func (r *RedisUsageStore) placeNames(apiName, modelName string, maxKeys int) (string, string) {
+	models, ok := r.names[apiName]
+	if !ok {
+		if maxKeys > 0 && len(r.names) >= maxKeys {
+			apiName = OverflowKey
+		}
+		if models, ok = r.names[apiName]; !ok {
+			models = make(map[string]struct{})
+			r.names[apiName] = models
+		}
+	}
+	if _, ok = models[modelName]; !ok && maxKeys > 0 && len(models) >= maxKeys {
+		modelName = OverflowKey
+	}
+	models[modelName] = struct{}{}
+	return apiName, modelName
+}
+
+// overlay replaces the totals in a local snapshot with the shared ones. Details stay local.
+// It flushes first so this replica's latest records are included, and leaves the snapshot
+// untouched when Redis cannot be read.
+func (r *RedisUsageStore) overlay(result *StatisticsSnapshot) {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+	_ = r.flushLocked(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	cmds := make(map[string]*redis.MapStringStringCmd, len(redisHashes))
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, hash := range redisHashes {
+			cmds[hash] = pipe.HGetAll(ctx, r.prefix+hash)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Debugf("usage-redis: reading shared statistics failed, reporting local ones: %v", err)
+		return
+	}
+	shared := newUsageDelta()
+	for hash, cmd := range cmds {
+		for field, raw := range cmd.Val() {
+			n, errParse := strconv.ParseInt(raw, 10, 64)
+			if errParse != nil {
+				continue
+			}
+			shared.incr(hash, field, n)
+		}
+	}
+	// Increments a failed flush still holds belong to this replica's view as well.
+	if r.inflight != nil {
+		shared.merge(r.inflight.delta)
+	}
+	shared.merge(r.unsent)
+	shared.applyTo(result)
+}
+
+// maxKeysLimit returns the cap set with SetMaxKeys.
+func (s *RequestStatistics) maxKeysLimit() int {
+	s.foldMu.Lock()
+	defer s.foldMu.Unlock()
+	return s.agg.maxKeys
+}
+
+// usageDelta holds hash field increments keyed by hash and field.
+type usageDelta struct {
+	fields  map[string]map[string]int64
+	records int64
+}
+
+func newUsageDelta() *usageDelta {
+	return &usageDelta{fields: make(map[string]map[string]int64)}
+}
+
+func (d *usageDelta) empty() bool { return len(d.fields) == 0 }
+
+func (d *usageDelta) incr(hash, field string, n int64) {
+	fields, ok := d.fields[hash]
+	if !ok {
+		fields = make(map[string]int64)
+		d.fields[hash] = fields
+	}
+	fields[field] += n
+}
+
+func (d *usageDelta) merge(other *usageDelta) {
+	for hash, fields := range other.fields {
+		for field, n := range fields {
+			d.incr(hash, field, n)
+		}
+	}
+	d.records += other.records
+}
+
+// add records one request the way statsAggregate.addPlaced counts it.
+func (d *usageDelta) add(apiName, modelName string, detail RequestDetail) {
+	tokens := billableTokens(detail)
+	outcome := redisTotalSuccess
+	if detail.Failed {
+		outcome = redisTotalFailure
+	}
+	d.incr(redisTotalsKey, redisTotalRequests, 1)
+	d.incr(redisTotalsKey, outcome, 1)
+	d.incr(redisTotalsKey, redisTotalTokensField, tokens)
+	if detail.CacheHit {
+		d.incr(redisTotalsKey, redisTotalCacheHits, 1)
+	}
+	d.incr(redisAPIRequestsKey, apiName, 1)
+	d.incr(redisAPITokensKey, apiName, tokens)
+	modelField := apiName + redisFieldSep + modelName
+	d.incr(redisModelRequestsKey, modelField, 1)
+	d.incr(redisModelTokensKey, modelField, tokens)
+	day := detail.Timestamp.Format("2006-01-02")
+	hour := formatHour(detail.Timestamp.Hour())
+	d.incr(redisDayRequestsKey, day, 1)
+	d.incr(redisDayTokensKey, day, tokens)
+	d.incr(redisHourRequestsKey, hour, 1)
+	d.incr(redisHourTokensKey, hour, tokens)
+	d.records++
+}
+
+// applyTo overwrites the totals of result with the delta's, keeping the local details of
+// every API key and model that appears in both.
+func (d *usageDelta) applyTo(result *StatisticsSnapshot) {
+	totals := d.fields[redisTotalsKey]
+	result.TotalRequests = totals[redisTotalRequests]
+	result.SuccessCount = totals[redisTotalSuccess]
+	result.FailureCount = totals[redisTotalFailure]
+	result.TotalTokens = totals[redisTotalTokensField]
+	result.CacheHits = totals[redisTotalCacheHits]
+
+	local := result.APIs
+	result.APIs = make(map[string]APISnapshot, len(d.fields[redisAPIRequestsKey]))
+	for apiName, requests := range d.fields[redisAPIRequestsKey] {
+		result.APIs[apiName] = APISnapshot{
+			TotalRequests: requests,
+			TotalTokens:   d.fields[redisAPITokensKey][apiName],
+			Models:        make(map[string]ModelSnapshot),
+		}
+	}
+	for field, requests := range d.fields[redisModelRequestsKey] {
+		apiName, modelName, ok := strings.Cut(field, redisFieldSep)
+		api, found := result.APIs[apiName]
+		if !ok || !found {
+			continue
+		}
+		details := local[apiName].Models[modelName].Details
+		if details == nil {
+			// Served by other replicas only.
+			details = []RequestDetail{}
+		}
+		// Failures and latencies, like details, are those of this replica.
+		api.Models[modelName] = ModelSnapshot{
+			TotalRequests: requests,
+			TotalTokens:   d.fields[redisModelTokensKey][field],
+			FailureCount:  local[apiName].Models[modelName].FailureCount,
+			Latency:       local[apiName].Models[modelName].Latency,
+			FirstToken:    local[apiName].Models[modelName].FirstToken,
+			Details:       details,
+		}
+	}
+	result.RequestsByDay = copyCounts(d.fields[redisDayRequestsKey])
+	result.TokensByDay = copyCounts(d.fields[redisDayTokensKey])
+	result.RequestsByHour = copyCounts(d.fields[redisHourRequestsKey])
+	result.TokensByHour = copyCounts(d.fields[redisHourTokensKey])
+}
+
+func copyCounts(src map[string]int64) map[string]int64 {
+	out := make(map[string]int64, len(src))
+	for k, v := range src {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,211 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// replica records into its own statistics and a RedisUsageStore, as LoggerPlugin and the
+// store's plugin registration do in one process.
+type replica struct {
+	stats *RequestStatistics
+	store *RedisUsageStore
+}
+
+func newReplica(t *testing.T, addr string) replica {
+	t.Helper()
+	stats := NewRequestStatistics()
+	store, err := NewRedisUsageStore(config.UsageRedis{Address: addr, KeyPrefix: "test:"}, stats)
+	if err != nil {
+		t.Fatalf("NewRedisUsageStore: %v", err)
+	}
+	stats.shared.Store(store)
+	t.Cleanup(func() { _ = store.Stop() })
+	return replica{stats: stats, store: store}
+}
+
+func (r replica) record(rec coreusage.Record) {
+	r.stats.Record(context.Background(), rec)
+	r.store.HandleUsage(context.Background(), rec)
+}
+
+func TestRedisUsageStoreSharesTotalsBetweenReplicas(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	server := miniredis.RunT(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	a, b := newReplica(t, server.Addr()), newReplica(t, server.Addr())
+
+	a.record(coreusage.Record{APIKey: "key", Model: "gpt-5", RequestedAt: now, Detail: coreusage.Detail{TotalTokens: 10}})
+	b.record(coreusage.Record{APIKey: "key", Model: "gpt-5", RequestedAt: now, Detail: coreusage.Detail{TotalTokens: 20}})
+	b.record(coreusage.Record{APIKey: "other", Model: "claude", RequestedAt: now, Failed: true})
+	if err := b.store.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	snapshot := a.stats.Snapshot()
+	if snapshot.TotalRequests != 3 || snapshot.FailureCount != 1 || snapshot.TotalTokens != 30 {
+		t.Fatalf("requests=%d failures=%d tokens=%d, want 3, 1 and 30", snapshot.TotalRequests, snapshot.FailureCount, snapshot.TotalTokens)
+	}
+	model := snapshot.APIs["key"].Models["gpt-5"]
+	if model.TotalRequests != 2 || model.TotalTokens != 30 || len(model.Details) != 1 {
+		t.Fatalf("shared model totals with local details expected, got %+v", model)
+	}
+	if other := snapshot.APIs["other"].Models["claude"]; other.TotalRequests != 1 || other.Details == nil {
+		t.Fatalf("model served only by the other replica: %+v", other)
+	}
+	if snapshot.RequestsByDay["2026-03-10"] != 3 || snapshot.TokensByHour["12"] != 30 {
+		t.Fatalf("unexpected periods: %v %v", snapshot.RequestsByDay, snapshot.TokensByHour)
+	}
+}
+
+func TestRedisUsageStoreReconcilesAfterOutage(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	server := miniredis.RunT(t)
+	a := newReplica(t, server.Addr())
+	a.record(coreusage.Record{APIKey: "key", Model: "gpt-5", Detail: coreusage.Detail{TotalTokens: 5}})
+	if err := a.store.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	server.Close()
+	a.record(coreusage.Record{APIKey: "key", Model: "gpt-5", Detail: coreusage.Detail{TotalTokens: 7}})
+	if err := a.store.Flush(context.Background()); err == nil {
+		t.Fatal("flush should fail while redis is down")
+	}
+	if snapshot := a.stats.Snapshot(); snapshot.TotalRequests != 2 || snapshot.TotalTokens != 12 {
+		t.Fatalf("during the outage the local view should be reported, got %d requests and %d tokens", snapshot.TotalRequests, snapshot.TotalTokens)
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	// After repeated dial failures the client redials in the background about once a second.
+	deadline := time.Now().Add(5 * time.Second)
+	for err := a.store.Flush(context.Background()); err != nil; err = a.store.Flush(context.Background()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Flush after recovery: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if got := server.HGet("test:"+redisTotalsKey, redisTotalTokensField); got != "12" {
+		t.Fatalf("shared tokens after recovery = %s, want 12", got)
+	}
+	if got := server.HGet("test:"+redisTotalsKey, redisTotalRequests); got != "2" {
+		t.Fatalf("shared requests after recovery = %s, want 2", got)
+	}
+}
+
+func TestRedisUsageStoreRetriedBatchAppliesOnce(t *testing.T) {
+	server := miniredis.RunT(t)
+	a := newReplica(t, server.Addr())
+	delta := newUsageDelta()
+	delta.add("key", "gpt-5", RequestDetail{Timestamp: time.Now(), Tokens: TokenStats{TotalTokens: 5}})
+	batch := &redisBatch{id: newRedisBatchID(), delta: delta}
+
+	// The first attempt is applied but its reply is lost, so the flush retries the batch.
+	if err := a.store.commit(context.Background(), batch); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	a.store.inflight = batch
+	if err := a.store.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := server.HGet("test:"+redisTotalsKey, redisTotalRequests); got != "1" {
+		t.Fatalf("shared requests after the retry = %s, want 1", got)
+	}
+	if a.store.inflight != nil {
+		t.Fatal("the retried batch should be done")
+	}
+}
+
+func TestRedisUsageStoreCapsDistinctKeys(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	server := miniredis.RunT(t)
+	a := newReplica(t, server.Addr())
+	a.stats.SetMaxKeys(1)
+	a.record(coreusage.Record{APIKey: "first", Model: "m"})
+	a.record(coreusage.Record{APIKey: "second", Model: "m"})
+	if err := a.store.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := server.HGet("test:"+redisAPIRequestsKey, OverflowKey); got != "1" {
+		t.Fatalf("second key should be counted under %s, got %q", OverflowKey, got)
+	}
+	if got := server.HGet("test:"+redisAPIRequestsKey, "second"); got != "" {
+		t.Fatalf("second key should not get its own field, got %q", got)
+	}
+}
+
+func TestRedisUsageStoreSharesQuotasBetweenReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	now := time.Now()
+	auth := &coreauth.Auth{ID: "user.json", Provider: "gemini-cli"}
+	keySettings := []config.APIKeySettings{{APIKey: "team", Quota: &config.APIKeyQuota{DailyTokens: 100}}}
+	type trackers struct {
+		replica
+		quotas   *QuotaTracker
+		keyQuota *KeyQuotaTracker
+	}
+	newTrackers := func() trackers {
+		r := trackers{replica: newReplica(t, server.Addr()), quotas: NewQuotaTracker(), keyQuota: NewKeyQuotaTracker()}
+		r.quotas.SetLimits([]config.DailyQuota{{Provider: "gemini-cli", DailyRequests: 3, Margin: 1}})
+		r.keyQuota.SetLimits(keySettings)
+		r.store.quotas, r.store.keyQuota = r.quotas, r.keyQuota
+		r.quotas.startSharing()
+		r.keyQuota.startSharing()
+		return r
+	}
+	a, b := newTrackers(), newTrackers()
+
+	a.quotas.HandleUsage(context.Background(), coreusage.Record{Provider: "gemini-cli", AuthID: "user.json", RequestedAt: now})
+	a.keyQuota.HandleUsage(context.Background(), coreusage.Record{APIKey: "team", RequestedAt: now, Detail: coreusage.Detail{TotalTokens: 60}})
+	b.quotas.HandleUsage(context.Background(), coreusage.Record{Provider: "gemini-cli", AuthID: "user.json", RequestedAt: now})
+	b.keyQuota.HandleUsage(context.Background(), coreusage.Record{APIKey: "team", RequestedAt: now, Detail: coreusage.Detail{TotalTokens: 30}})
+	b.keyQuota.TopUp("team", 50, 0, now)
+	for _, r := range []trackers{a, b, a} {
+		if err := r.store.Flush(context.Background()); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	}
+
+	// Each replica served one request, but together they reached the margin.
+	if near, _ := a.quotas.NearLimit(auth, now); !near {
+		t.Fatal("replica a should see the requests of replica b")
+	}
+	status, _ := a.keyQuota.Status("team", now)
+	if status.Daily.Used != 90 || status.Daily.TopUp != 50 || status.Daily.Remaining != 60 {
+		t.Fatalf("replica a daily budget = %+v, want 90 used of 100 plus a 50 top-up", status.Daily)
+	}
+
+	// Counting continues locally between flushes and is not counted twice once pushed.
+	a.keyQuota.HandleUsage(context.Background(), coreusage.Record{APIKey: "team", RequestedAt: now, Detail: coreusage.Detail{TotalTokens: 5}})
+	if status, _ = a.keyQuota.Status("team", now); status.Daily.Used != 95 {
+		t.Fatalf("replica a used %d tokens before the next flush, want 95", status.Daily.Used)
+	}
+	for _, r := range []trackers{a, b} {
+		if err := r.store.Flush(context.Background()); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	}
+	if status, _ = b.keyQuota.Status("team", now); status.Daily.Used != 95 {
+		t.Fatalf("replica b used %d tokens, want 95", status.Daily.Used)
+	}
+	if ttl := server.TTL("test:" + redisKeyQuotaDayTokens + keyQuotaDay(*keySettings[0].Quota, now)); ttl != redisQuotaDayTTL {
+		t.Fatalf("daily key quota hash expires in %s, want %s", ttl, redisQuotaDayTTL)
+	}
+}
@@ -0,0 +1,225 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cloudconfig"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// remoteTimeout bounds one download or upload of a remote usage file.
+const remoteTimeout = time.Minute
+
+// gcsReadWriteScope is the OAuth scope used to read and replace GCS objects.
+const gcsReadWriteScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// RemoteFile is a usage file kept in object storage, s3://bucket/key or gs://bucket/object.
+// An upload replaces the whole object at once, so readers see the previous file or the new
+// one and never a partial write, as with the rename of a local save.
+type RemoteFile struct {
+	raw    string
+	bucket string
+	object string
+	s3     *minio.Client
+	client *http.Client
+	tokens oauth2.TokenSource
+}
+
+// NewRemoteFile prepares the client for rawURL. Credentials come from the environment as for
+// a cloud-deploy configuration: the AWS environment, shared credentials file or instance role
+// for s3://, and Google application default credentials for gs://, where
+// STORAGE_EMULATOR_HOST points at an emulator without credentials.
+func NewRemoteFile(rawURL string) (*RemoteFile, error) {
+	raw := strings.TrimSpace(rawURL)
+	location, err := url.Parse(raw)
+	if err != nil || (location.Scheme != "s3" && location.Scheme != "gs") ||
+		location.Host == "" || strings.Trim(location.Path, "/") == "" {
+		return nil, errors.New("remote usage file must be s3://bucket/key or gs://bucket/object")
+	}
+	r := &RemoteFile{
+		raw:    raw,
+		bucket: location.Host,
+		object: strings.TrimPrefix(location.Path, "/"),
+		client: &http.Client{Timeout: remoteTimeout},
+	}
+	if location.Scheme == "s3" {
+		if r.s3, err = cloudconfig.NewS3Client(); err != nil {
+			return nil, err
+		}
+	} else if os.Getenv("STORAGE_EMULATOR_HOST") == "" {
+		if r.tokens, err = google.DefaultTokenSource(context.Background(), gcsReadWriteScope); err != nil {
+			return nil, fmt.Errorf("google credentials: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// String returns the URL of the file.
+func (r *RemoteFile) String() string { return r.raw }
+
+// Download writes the remote file to path and reports whether it exists; a missing object
+// leaves path as it is.
+func (r *RemoteFile) Download(ctx context.Context, path string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, remoteTimeout)
+	defer cancel()
+	var (
+		body io.ReadCloser
+		err  error
+	)
+	if r.s3 != nil {
+		body, err = r.getS3(ctx)
+	} else {
+		body, err = r.getGCS(ctx)
+	}
+	if err != nil || body == nil {
+		return false, err
+	}
+	defer func() { _ = body.Close() }()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return false, err
+	}
+	return true, writeFileAtomic(path, data)
+}
+
+// Upload replaces the remote file with data.
+func (r *RemoteFile) Upload(ctx context.Context, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, remoteTimeout)
+	defer cancel()
+	if r.s3 != nil {
+		_, err := r.s3.PutObject(ctx, r.bucket, r.object, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: "application/json"})
+		return err
+	}
+	endpoint := r.gcsBase() + "/upload/storage/v1/b/" + url.PathEscape(r.bucket) + "/o?uploadType=media&name=" + url.QueryEscape(r.object)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+func (r *RemoteFile) getS3(ctx context.Context) (io.ReadCloser, error) {
+	object, err := r.s3.GetObject(ctx, r.bucket, r.object, minio.GetObjectOptions{})
+	if err == nil {
+		// GetObject is lazy; Stat surfaces missing objects and denied credentials here.
+		_, err = object.Stat()
+	}
+	if err != nil {
+		if object != nil {
+			_ = object.Close()
+		}
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return object, nil
+}
+
+func (r *RemoteFile) getGCS(ctx context.Context) (io.ReadCloser, error) {
+	endpoint := r.gcsBase() + "/storage/v1/b/" + url.PathEscape(r.bucket) + "/o/" + url.PathEscape(r.object) + "?alt=media"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.do(req)
+	if errors.Is(err, errRemoteNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (r *RemoteFile) gcsBase() string {
+	base := strings.TrimSpace(os.Getenv("STORAGE_EMULATOR_HOST"))
+	if base == "" {
+		return "https://storage.googleapis.com"
+	}
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	return strings.TrimRight(base, "/")
+}
+
+var errRemoteNotFound = errors.New("object not found")
+
+// do sends a GCS request with the credentials, if any, and turns a status other than 200 into
+// an error.
+func (r *RemoteFile) do(req *http.Request) (*http.Response, error) {
+	if r.tokens != nil {
+		token, err := r.tokens.Token()
+		if err != nil {
+			return nil, fmt.Errorf("google credentials: %w", err)
+		}
+		token.SetAuthHeader(req)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errRemoteNotFound
+	}
+	return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+}
+
+// SetRemoteFile makes the plugin's file the working copy of remote: Load merges the remote
+// file along with it, and every save of the file uploads it. Call it before Load.
+func (p *FileUsagePlugin) SetRemoteFile(remote *RemoteFile) {
+	if p == nil || p.path == "" {
+		return
+	}
+	p.remote = remote
+}
+
+// remoteCopySuffix names the file Load downloads the remote file to, next to the working copy.
+const remoteCopySuffix = ".remote"
+
+// loadRemote merges the remote file. It is downloaded next to the working copy rather than
+// over it, since a working copy whose upload failed holds newer statistics. A failed
+// download fails Load: saving without the remote statistics would replace them.
+func (p *FileUsagePlugin) loadRemote() error {
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o700); err != nil {
+		return fmt.Errorf("usage persistence: create %s: %w", filepath.Dir(p.path), err)
+	}
+	copyPath := p.path + remoteCopySuffix
+	found, err := p.remote.Download(context.Background(), copyPath)
+	if err != nil {
+		return fmt.Errorf("usage persistence: download %s: %w", p.remote, err)
+	}
+	if !found {
+		log.Infof("usage persistence: %s does not exist yet; it is created by the first save", p.remote)
+		return nil
+	}
+	if err = p.loadFile(copyPath); err != nil {
+		return err
+	}
+	_ = os.Remove(copyPath)
+	return nil
+}
@@ -0,0 +1,89 @@
+package usage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// fakeGCS serves the media download and upload endpoints of the GCS JSON API.
+type fakeGCS struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	failGet bool
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/bucket/o":
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Query().Get("name")] = data
+	case r.Method == http.MethodGet && f.failGet:
+		http.Error(w, "backend error", http.StatusServiceUnavailable)
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[r.URL.Path[len("/storage/v1/b/bucket/o/"):]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func TestFileUsagePluginKeepsStatisticsInRemoteFile(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+	gcs := &fakeGCS{objects: make(map[string][]byte)}
+	server := httptest.NewServer(gcs)
+	defer server.Close()
+	t.Setenv("STORAGE_EMULATOR_HOST", server.URL)
+
+	// Each run starts on a fresh disk, as a container without a volume does.
+	run := func() (*FileUsagePlugin, error) {
+		remote, err := NewRemoteFile("gs://bucket/usage.json")
+		if err != nil {
+			t.Fatalf("NewRemoteFile: %v", err)
+		}
+		plugin := NewFileUsagePlugin(filepath.Join(t.TempDir(), "usage.json"), 0, NewRequestStatistics())
+		plugin.quotas, plugin.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+		plugin.SetRemoteFile(remote)
+		return plugin, plugin.Load()
+	}
+
+	first, err := run()
+	if err != nil {
+		t.Fatalf("Load without a remote file: %v", err)
+	}
+	first.stats.Record(context.Background(), coreusage.Record{APIKey: "key", Model: "gpt-5", RequestedAt: time.Now(), Detail: coreusage.Detail{TotalTokens: 10}})
+	if err = first.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if len(gcs.objects["usage.json"]) == 0 {
+		t.Fatalf("save did not upload the file")
+	}
+
+	second, err := run()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := second.stats.Snapshot().TotalRequests; got != 1 {
+		t.Fatalf("fresh run loaded %d requests from the remote file, want 1", got)
+	}
+
+	gcs.failGet = true
+	if _, err = run(); err == nil {
+		t.Fatalf("Load succeeded although the remote file could not be downloaded")
+	}
+}
@@ -0,0 +1,21 @@
+package usage
+
+import "time"
+
+// replaceRetryDelays spaces out further replace attempts while another process, typically
+// an editor, backup agent or antivirus scanner, briefly holds the destination open.
+var replaceRetryDelays = []time.Duration{10 * time.Millisecond, 50 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond}
+
+// replaceFile atomically moves src over dst, replacing dst if it exists. Attempts that fail
+// because dst is in use are retried for about a second before the error is returned.
+func replaceFile(src, dst string) error {
+	err := renameReplace(src, dst)
+	for _, delay := range replaceRetryDelays {
+		if err == nil || !isFileInUse(err) {
+			break
+		}
+		time.Sleep(delay)
+		err = renameReplace(src, dst)
+	}
+	return err
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package usage
+
+import "os"
+
+// renameReplace moves src over dst; rename(2) replaces dst atomically even while it is open.
+func renameReplace(src, dst string) error { return os.Rename(src, dst) }
+
+// isFileInUse is always false: open handles never block a rename here.
+func isFileInUse(error) bool { return false }
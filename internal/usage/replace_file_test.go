@@ -0,0 +1,65 @@
+package usage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomicRepeatedlyReplacesDestination(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	for i := 0; i < 20; i++ {
+		want := fmt.Sprintf(`{"save":%d}`, i)
+		if err := writeFileAtomic(path, []byte(want)); err != nil {
+			t.Fatalf("save %d: %v", i, err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read after save %d: %v", i, err)
+		}
+		if string(got) != want {
+			t.Fatalf("after save %d file holds %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestReplaceFileWhileDestinationIsOpen(t *testing.T) {
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "usage.json")
+	src := filepath.Join(dir, "usage.json.tmp")
+	if err := os.WriteFile(dst, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := os.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A reader that lets go shortly after must not make the save fail where the platform
+	// refuses to replace an open file.
+	released := make(chan struct{})
+	go func() {
+		defer close(released)
+		time.Sleep(30 * time.Millisecond)
+		_ = reader.Close()
+	}()
+	defer func() { <-released }()
+
+	if err = replaceFile(src, dst); err != nil {
+		t.Fatalf("replaceFile: %v", err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "new" {
+		t.Fatalf("destination holds %q, want %q", got, "new")
+	}
+	if _, err = os.Stat(src); !os.IsNotExist(err) {
+		t.Fatalf("source still present after replace: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build windows
+
+package usage
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// renameReplace moves src over dst with MoveFileEx, asking for the data to reach the disk
+// before it returns so a crash cannot leave dst pointing at an empty file.
+func renameReplace(src, dst string) error {
+	from, err := windows.UTF16PtrFromString(src)
+	if err != nil {
+		return err
+	}
+	to, err := windows.UTF16PtrFromString(dst)
+	if err != nil {
+		return err
+	}
+	if err = windows.MoveFileEx(from, to, windows.MOVEFILE_REPLACE_EXISTING|windows.MOVEFILE_WRITE_THROUGH); err != nil {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: err}
+	}
+	return nil
+}
+
+// isFileInUse reports whether err means another handle on the file blocks the replace, which
+// Windows returns as access denied when the reader did not open it with FILE_SHARE_DELETE.
+func isFileInUse(err error) bool {
+	return errors.Is(err, windows.ERROR_ACCESS_DENIED) ||
+		errors.Is(err, windows.ERROR_SHARING_VIOLATION) ||
+		errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
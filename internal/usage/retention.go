@@ -0,0 +1,263 @@
+package usage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// archiveMonthLayout names the monthly archives of expired details:
+// "<file without extension>-2006-01.json.gz", the month being that of the requests in UTC.
+const archiveMonthLayout = "2006-01"
+
+// archiveSizeTarget is the share of max-file-size-mb a trim aims for, so that the file does
+// not grow past the cap again after a few more requests.
+const archiveSizeTarget = 0.9
+
+// SetRetention sets how many days of details are kept and the size in MB the usage file is
+// kept under; zero disables either. Details past them are archived before each save. Call it
+// before Start.
+func (p *FileUsagePlugin) SetRetention(keepDays, maxFileSizeMB int) {
+	if p == nil {
+		return
+	}
+	p.keepDays = max(keepDays, 0)
+	p.maxFileBytes = int64(max(maxFileSizeMB, 0)) << 20
+}
+
+// applyRetention moves the details older than keepDays, counted in whole days of the daily
+// buckets so that no day is left with part of its requests, and the oldest ones when the last
+// save wrote more than maxFileBytes, out of the store into their monthly archives. Details of
+// a month whose archive cannot be written are put back and tried again at the next save.
+// Without archive the expired details are only dropped; a follower does so, since it handed
+// them to the leader, which archives them, long before they expire.
+func (p *FileUsagePlugin) applyRetention(now time.Time, archive bool) {
+	var cutoff time.Time
+	if p.keepDays > 0 {
+		cutoff = rollingWindow{count: p.keepDays, daily: true}.start(now)
+	}
+	if archive && p.maxFileBytes > 0 {
+		if size := p.lastSize.Load(); size > p.maxFileBytes {
+			// Each detail is taken to use an equal share of the file.
+			sized := p.stats.cutoffKeeping(archiveSizeTarget * float64(p.maxFileBytes) / float64(size))
+			if sized.After(cutoff) {
+				cutoff = sized
+			}
+		}
+	}
+	if cutoff.IsZero() {
+		return
+	}
+	expired := p.stats.prune(cutoff)
+	if len(expired) == 0 || !archive {
+		return
+	}
+
+	months := make(map[string][]pendingDetail)
+	for _, entry := range expired {
+		month := entry.detail.Timestamp.UTC().Format(archiveMonthLayout)
+		months[month] = append(months[month], entry)
+	}
+	archived := 0
+	for month, entries := range months {
+		path := archivePath(p.path, month)
+		if err := p.writeArchive(path, entries, now); err != nil {
+			log.Errorf("usage persistence: archive %d records to %s: %v; keeping them until the next save", len(entries), path, err)
+			p.stats.restore(entries)
+			continue
+		}
+		archived += len(entries)
+	}
+	if archived > 0 {
+		log.Infof("usage persistence: archived %d records dated before %s", archived, cutoff.UTC().Format(time.RFC3339))
+	}
+}
+
+// archivePath returns the archive of the given month for the usage file at path.
+func archivePath(path, month string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "-" + month + ".json.gz"
+}
+
+// writeArchive adds entries to the archive at path, which holds a gzipped usage file.
+// Records the archive already holds are not added twice, so an archive write repeated after
+// a failed save changes nothing. An archive that cannot be read is moved aside like an
+// unreadable usage file and started afresh.
+func (p *FileUsagePlugin) writeArchive(path string, entries []pendingDetail, now time.Time) error {
+	archive := NewRequestStatistics()
+	previous, err := readArchive(path)
+	if errors.Is(err, errUnreadableArchive) {
+		backup, errRename := moveCorruptAside(path, now)
+		if errRename != nil {
+			return fmt.Errorf("%w and could not be moved aside: %v", err, errRename)
+		}
+		log.Warnf("usage persistence: %v; moved to %s and starting a new archive", err, backup)
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	archive.MergeSnapshot(previous)
+	archive.MergeSnapshot(diskUsage(p.keyMode, snapshotOf(entries)))
+
+	data, err := json.Marshal(FileUsageData{
+		Version: fileUsageDataVersion,
+		SavedAt: now.UTC(),
+		Usage:   archive.Snapshot(),
+	})
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err = zw.Write(data); err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("compress: %w", err)
+	}
+	return p.writeFile(path, buf.Bytes())
+}
+
+var errUnreadableArchive = errors.New("unreadable archive")
+
+// readArchive returns the statistics held by the archive at path; a missing archive holds
+// none.
+func readArchive(path string) (StatisticsSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return StatisticsSnapshot{}, nil
+		}
+		return StatisticsSnapshot{}, err
+	}
+	defer func() { _ = f.Close() }()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return StatisticsSnapshot{}, fmt.Errorf("%w %s: %v", errUnreadableArchive, path, err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return StatisticsSnapshot{}, fmt.Errorf("%w %s: %v", errUnreadableArchive, path, err)
+	}
+	var payload FileUsageData
+	if err = json.Unmarshal(data, &payload); err != nil {
+		return StatisticsSnapshot{}, fmt.Errorf("%w %s: %v", errUnreadableArchive, path, err)
+	}
+	return payload.Usage, nil
+}
+
+// snapshotOf lays entries out as a snapshot holding only their details, which is all
+// MergeSnapshot reads.
+func snapshotOf(entries []pendingDetail) StatisticsSnapshot {
+	snapshot := StatisticsSnapshot{APIs: make(map[string]APISnapshot)}
+	for _, entry := range entries {
+		api, ok := snapshot.APIs[entry.apiName]
+		if !ok {
+			api = APISnapshot{Models: make(map[string]ModelSnapshot)}
+		}
+		model := api.Models[entry.modelName]
+		model.Details = append(model.Details, entry.detail)
+		api.Models[entry.modelName] = model
+		snapshot.APIs[entry.apiName] = api
+	}
+	return snapshot
+}
+
+// prune removes the details dated before cutoff and returns them. The totals, per key,
+// per model and overall, as well as the label and account aggregates, keep counting the
+// removed requests, so they only ever grow while the process runs; the per-day and per-hour
+// aggregates and the rolling windows are rebuilt from the details kept. Keys and models stay
+// even when none of their details do.
+func (s *RequestStatistics) prune(cutoff time.Time) []pendingDetail {
+	s.foldMu.Lock()
+	defer s.foldMu.Unlock()
+	s.fold()
+	var removed []pendingDetail
+	for apiName, api := range s.agg.apis {
+		for modelName, model := range api.Models {
+			var kept []RequestDetail
+			for _, detail := range model.Details {
+				if detail.Timestamp.Before(cutoff) {
+					removed = append(removed, pendingDetail{apiName: apiName, modelName: modelName, detail: detail})
+				} else {
+					kept = append(kept, detail)
+				}
+			}
+			if len(kept) != len(model.Details) {
+				// Snapshots taken earlier keep the old details; the new slice shares none of them.
+				model.Details = kept
+			}
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	s.agg.rebuildPeriods()
+	return removed
+}
+
+// restore puts back details prune removed without counting them in the totals again, which
+// still include them.
+func (s *RequestStatistics) restore(entries []pendingDetail) {
+	s.foldMu.Lock()
+	defer s.foldMu.Unlock()
+	s.fold()
+	now := time.Now()
+	for _, entry := range entries {
+		model := s.agg.apply(s.agg.place(entry.apiName, entry.modelName, now), entry.apiName, entry.modelName)
+		model.Details = append(model.Details, entry.detail)
+	}
+	s.agg.rebuildPeriods()
+}
+
+// rebuildPeriods recomputes the per-day and per-hour aggregates and the rolling windows from
+// the details held.
+func (a *statsAggregate) rebuildPeriods() {
+	a.requestsByDay, a.requestsByHour = make(map[string]int64), make(map[int]int64)
+	a.tokensByDay, a.tokensByHour = make(map[string]int64), make(map[int]int64)
+	a.hourly = newRollingWindow(hourlyWindowBuckets, false)
+	a.daily = newRollingWindow(dailyWindowBuckets, true)
+	now := time.Now()
+	for _, api := range a.apis {
+		for _, model := range api.Models {
+			for _, detail := range model.Details {
+				a.addPeriods(now, detail, billableTokens(detail))
+			}
+		}
+	}
+}
+
+// cutoffKeeping returns the time before which details must go so that the given share of
+// them, the newest, is kept, or the zero time when all of them can stay.
+func (s *RequestStatistics) cutoffKeeping(share float64) time.Time {
+	s.foldMu.Lock()
+	defer s.foldMu.Unlock()
+	s.fold()
+	var timestamps []time.Time
+	for _, api := range s.agg.apis {
+		for _, model := range api.Models {
+			for _, detail := range model.Details {
+				timestamps = append(timestamps, detail.Timestamp)
+			}
+		}
+	}
+	keep := int(share * float64(len(timestamps)))
+	if keep >= len(timestamps) {
+		return time.Time{}
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i].After(timestamps[j]) })
+	if keep <= 0 {
+		return timestamps[0].Add(time.Nanosecond)
+	}
+	return timestamps[keep-1]
+}
@@ -0,0 +1,146 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// retentionPlugin returns a plugin over a fresh store holding one request at each of the
+// given ages.
+func retentionPlugin(t *testing.T, now time.Time, ages ...time.Duration) *FileUsagePlugin {
+	t.Helper()
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	t.Cleanup(func() { SetStatisticsEnabled(enabled) })
+
+	stats := NewRequestStatistics()
+	for i, age := range ages {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey: "key", Model: "gpt-5", RequestedAt: now.Add(-age),
+			Detail: coreusage.Detail{TotalTokens: int64(10 + i)},
+		})
+	}
+	plugin := NewFileUsagePlugin(filepath.Join(t.TempDir(), "usage.json"), 0, stats)
+	plugin.quotas, plugin.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+	return plugin
+}
+
+func archivedRequests(t *testing.T, path string) int64 {
+	t.Helper()
+	snapshot, err := readArchive(path)
+	if err != nil {
+		t.Fatalf("read archive %s: %v", path, err)
+	}
+	return int64(len(usageRecords(snapshot)))
+}
+
+// heldDetails counts the details the store holds, which retention removes while the totals
+// keep counting them.
+func heldDetails(plugin *FileUsagePlugin) int {
+	return len(usageRecords(plugin.stats.Snapshot()))
+}
+
+func TestFileUsagePluginArchivesExpiredDetails(t *testing.T) {
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	// Two requests in January, one in February and two within the last thirty days.
+	plugin := retentionPlugin(t, now, 60*day, 58*day, 40*day, 10*day, time.Hour)
+	plugin.SetRetention(30, 0)
+	expired := plugin.stats.Snapshot()
+
+	plugin.applyRetention(now, true)
+	snapshot := plugin.stats.Snapshot()
+	if held := heldDetails(plugin); held != 2 || len(snapshot.RequestsByDay) != 2 {
+		t.Fatalf("after retention the store holds %d details and days %v; want the last two", held, snapshot.RequestsByDay)
+	}
+	// The totals stay cumulative, so counters exported from them never go down.
+	if snapshot.TotalRequests != 5 || snapshot.TotalTokens != 10+11+12+13+14 || snapshot.APIs["key"].TotalRequests != 5 ||
+		snapshot.APIs["key"].Models["gpt-5"].TotalTokens != 60 {
+		t.Fatalf("after retention the totals are %d requests and %d tokens, want all five kept", snapshot.TotalRequests, snapshot.TotalTokens)
+	}
+	if got := archivedRequests(t, archivePath(plugin.path, "2025-01")); got != 2 {
+		t.Fatalf("January archive holds %d requests, want 2", got)
+	}
+	if got := archivedRequests(t, archivePath(plugin.path, "2025-02")); got != 1 {
+		t.Fatalf("February archive holds %d requests, want 1", got)
+	}
+
+	// Expired requests handed over again, for example by a follower, are archived once.
+	plugin.stats.MergeSnapshot(expired)
+	plugin.applyRetention(now, true)
+	if got := archivedRequests(t, archivePath(plugin.path, "2025-01")); got != 2 {
+		t.Fatalf("January archive holds %d requests after a second pass, want 2", got)
+	}
+	if got := heldDetails(plugin); got != 2 {
+		t.Fatalf("store holds %d details after a second pass, want 2", got)
+	}
+}
+
+func TestFileUsagePluginExpiresWholeDays(t *testing.T) {
+	now := time.Date(2025, 3, 20, 0, 30, 0, 0, time.Local)
+	// Two requests early on March 18 and one early on March 19.
+	plugin := retentionPlugin(t, now, 48*time.Hour+10*time.Minute, 47*time.Hour+50*time.Minute, 20*time.Hour+20*time.Minute)
+	plugin.SetRetention(2, 0)
+
+	plugin.applyRetention(now, true)
+	snapshot := plugin.stats.Snapshot()
+	if held := heldDetails(plugin); held != 1 || len(snapshot.RequestsByDay) != 1 || snapshot.RequestsByDay["2025-03-19"] != 1 {
+		t.Fatalf("after retention the store holds %d details and days %v; want only March 19", held, snapshot.RequestsByDay)
+	}
+	if got := archivedRequests(t, archivePath(plugin.path, "2025-03")); got != 2 {
+		t.Fatalf("March archive holds %d requests, want both of March 18", got)
+	}
+}
+
+func TestFileUsagePluginKeepsDetailsWhenArchiveFails(t *testing.T) {
+	now := time.Now()
+	plugin := retentionPlugin(t, now, 40*24*time.Hour, time.Hour)
+	plugin.SetRetention(30, 0)
+	plugin.writeFile = func(string, []byte) error { return errors.New("read-only file system") }
+
+	plugin.applyRetention(now, true)
+	snapshot := plugin.stats.Snapshot()
+	if got := heldDetails(plugin); got != 2 || snapshot.TotalRequests != 2 || len(snapshot.RequestsByDay) != 2 {
+		t.Fatalf("store holds %d details, %d requests and days %v after a failed archive, want both counted once",
+			got, snapshot.TotalRequests, snapshot.RequestsByDay)
+	}
+}
+
+func TestFileUsagePluginFollowerDropsExpiredDetails(t *testing.T) {
+	now := time.Now()
+	plugin := retentionPlugin(t, now, 40*24*time.Hour, time.Hour)
+	plugin.SetRetention(30, 0)
+
+	plugin.applyRetention(now, false)
+	if got := heldDetails(plugin); got != 1 {
+		t.Fatalf("follower holds %d details, want 1", got)
+	}
+	if snapshot, _ := readArchive(archivePath(plugin.path, now.AddDate(0, 0, -40).UTC().Format(archiveMonthLayout))); snapshot.TotalRequests != 0 {
+		t.Fatalf("follower archived %d requests, want none", snapshot.TotalRequests)
+	}
+}
+
+func TestFileUsagePluginTrimsOversizedFile(t *testing.T) {
+	now := time.Now()
+	plugin := retentionPlugin(t, now, 5*time.Hour, 4*time.Hour, 3*time.Hour, 2*time.Hour, time.Hour)
+	plugin.SetRetention(0, 1)
+	if err := plugin.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	plugin.applyRetention(now, true)
+	if got := heldDetails(plugin); got != 5 {
+		t.Fatalf("store holds %d details under the cap, want all 5", got)
+	}
+
+	// A file twice the cap keeps the newest 45 percent, here two of five.
+	plugin.lastSize.Store(2 << 20)
+	plugin.applyRetention(now, true)
+	if got := heldDetails(plugin); got != 2 {
+		t.Fatalf("store holds %d details after trimming, want 2", got)
+	}
+}
@@ -0,0 +1,111 @@
+package usage
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/errorreport"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// saveRetryBase is the wait after a first failed save when there is no save interval.
+const saveRetryBase = 30 * time.Second
+
+// saveBackoffCap bounds the wait between save attempts while no save succeeds. A save
+// interval above it is used as is.
+const saveBackoffCap = 30 * time.Minute
+
+// saveState tells where the last save attempt got the statistics.
+type saveState int
+
+const (
+	saveHealthy  saveState = iota // written to the usage file
+	saveFallback                  // written to the fallback file only
+	saveFailing                   // written nowhere
+)
+
+// saveHealth follows the outcome of saves. It logs at error level only when saving starts
+// failing, moves to the fallback file, or recovers, so a full disk is not filled further by
+// one log line per attempt.
+type saveHealth struct {
+	mu        sync.Mutex
+	state     saveState
+	failures  int // consecutive attempts that wrote nothing
+	lastErr   error
+	lastErrAt time.Time
+	retryAt   time.Time
+}
+
+// recordSave notes the outcome of a save attempt: err is the error writing the usage file, and
+// fallbackErr the error writing fallback when that was tried. It removes the fallback file
+// once the usage file can be written again.
+func (p *FileUsagePlugin) recordSave(err error, fallback string, fallbackErr error) {
+	h := &p.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	previous := h.state
+	switch {
+	case err == nil:
+		h.state, h.failures, h.retryAt = saveHealthy, 0, time.Time{}
+	case fallback != "" && fallbackErr == nil:
+		h.state, h.failures, h.retryAt = saveFallback, 0, time.Time{}
+		h.lastErr, h.lastErrAt = err, time.Now()
+	default:
+		h.state = saveFailing
+		h.failures++
+		h.lastErr, h.lastErrAt = err, time.Now()
+		h.retryAt = h.lastErrAt.Add(saveBackoff(p.interval, h.failures))
+	}
+	if h.state == previous {
+		if err != nil {
+			log.Debugf("usage persistence: save attempt %d failed: %v", h.failures, err)
+		}
+		return
+	}
+
+	switch h.state {
+	case saveHealthy:
+		log.Infof("usage persistence: saving to %s works again", p.path)
+		if previous == saveFallback {
+			if errRemove := os.Remove(fallback); errRemove != nil && !os.IsNotExist(errRemove) {
+				log.Warnf("usage persistence: remove fallback file %s: %v", fallback, errRemove)
+			}
+		}
+	case saveFallback:
+		log.Errorf("%v; saving to fallback file %s until it recovers", err, fallback)
+		errorreport.CaptureError("usage-persistence", err)
+	case saveFailing:
+		if fallbackErr != nil {
+			log.Errorf("%v (fallback: %v); statistics are no longer persisted, retrying with backoff", err, fallbackErr)
+		} else {
+			log.Errorf("%v; statistics are no longer persisted, retrying with backoff", err)
+		}
+		errorreport.CaptureError("usage-persistence", err)
+	}
+}
+
+// retryDelay is how long the save worker waits before its next attempt, or zero when the
+// last attempt wrote the statistics somewhere and saves follow the normal schedule.
+func (h *saveHealth) retryDelay() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.state != saveFailing {
+		return 0
+	}
+	return max(time.Until(h.retryAt), 0)
+}
+
+// saveBackoff doubles the wait with every consecutive failure, starting from interval.
+func saveBackoff(interval time.Duration, failures int) time.Duration {
+	delay := interval
+	if delay <= 0 {
+		delay = saveRetryBase
+	}
+	limit := max(saveBackoffCap, delay)
+	for i := 1; i < failures && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
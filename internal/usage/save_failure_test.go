@@ -0,0 +1,152 @@
+package usage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestSaveBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		interval time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{5 * time.Minute, 1, 5 * time.Minute},
+		{5 * time.Minute, 3, 20 * time.Minute},
+		{5 * time.Minute, 10, saveBackoffCap},
+		{0, 1, saveRetryBase},
+		{0, 2, 2 * saveRetryBase},
+		{time.Hour, 4, time.Hour},
+	} {
+		if got := saveBackoff(tc.interval, tc.failures); got != tc.want {
+			t.Fatalf("saveBackoff(%s, %d) = %s, want %s", tc.interval, tc.failures, got, tc.want)
+		}
+	}
+}
+
+// failingWrites makes writes to path fail with ENOSPC while full is set and counts the
+// attempts.
+func failingWrites(path string, full *atomic.Bool, attempts *atomic.Int32) func(string, []byte) error {
+	return func(target string, data []byte) error {
+		if target == path {
+			attempts.Add(1)
+			if full.Load() {
+				return &os.PathError{Op: "write", Path: target, Err: syscall.ENOSPC}
+			}
+		}
+		return writeFileAtomic(target, data)
+	}
+}
+
+func TestFileUsagePluginSaveFailureBacksOffAndRecovers(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	interval := 20 * time.Millisecond
+	plugin := NewFileUsagePlugin(filepath.Join(t.TempDir(), "usage.json"), interval, NewRequestStatistics())
+	plugin.quotas, plugin.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+	var full atomic.Bool
+	var attempts atomic.Int32
+	full.Store(true)
+	plugin.writeFile = failingWrites(plugin.path, &full, &attempts)
+	plugin.HandleUsage(context.Background(), coreusage.Record{APIKey: "key"})
+	plugin.Start()
+	defer func() { _ = plugin.Stop() }()
+
+	time.Sleep(30 * interval)
+	// Backing off from 20ms doubles to 40ms, 80ms and 160ms, so about five attempts fit into
+	// the time thirty ticks take.
+	if got := attempts.Load(); got < 2 || got > 8 {
+		t.Fatalf("%d save attempts during 30 intervals of failure, want a backed off handful", got)
+	}
+	status := plugin.Status()
+	if !status.Failing || status.LastSaveError == "" || !status.LastSave.IsZero() {
+		t.Fatalf("status while failing = %+v", status)
+	}
+	if !strings.Contains(status.LastSaveError, syscall.ENOSPC.Error()) {
+		t.Fatalf("last save error = %q, want ENOSPC", status.LastSaveError)
+	}
+
+	full.Store(false)
+	deadline := time.Now().Add(5 * time.Second)
+	for plugin.Status().Failing {
+		if time.Now().After(deadline) {
+			t.Fatal("saving did not recover")
+		}
+		time.Sleep(interval)
+	}
+	status = plugin.Status()
+	if status.LastSave.IsZero() || status.LastSaveError == "" || !status.NextAttempt.IsZero() {
+		t.Fatalf("status after recovery = %+v, want a save and the last error kept", status)
+	}
+	if _, err := os.Stat(plugin.path); err != nil {
+		t.Fatalf("usage file after recovery: %v", err)
+	}
+
+	// Back on the normal interval, a new record is saved within a few ticks.
+	before := attempts.Load()
+	plugin.HandleUsage(context.Background(), coreusage.Record{APIKey: "key"})
+	time.Sleep(5 * interval)
+	if attempts.Load() == before {
+		t.Fatal("no save after recovery on the normal interval")
+	}
+}
+
+func TestFileUsagePluginFallsBackWhileFileUnwritable(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	path := filepath.Join(t.TempDir(), "usage.json")
+	fallback := filepath.Join(t.TempDir(), "usage.json")
+	stats := NewRequestStatistics()
+	stats.Record(context.Background(), coreusage.Record{APIKey: "key", Model: "model", RequestedAt: time.Now()})
+	plugin := NewFileUsagePlugin(path, time.Minute, stats)
+	plugin.quotas, plugin.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+	plugin.SetFallbackFile(fallback)
+	var full atomic.Bool
+	var attempts atomic.Int32
+	full.Store(true)
+	plugin.writeFile = failingWrites(path, &full, &attempts)
+
+	if err := plugin.Save(); err != nil {
+		t.Fatalf("Save with a writable fallback: %v", err)
+	}
+	if status := plugin.Status(); !status.UsingFallback || status.Failing || status.FallbackFile != fallback {
+		t.Fatalf("status = %+v, want saving to the fallback", status)
+	}
+	if plugin.health.retryDelay() != 0 {
+		t.Fatal("saves to the fallback should keep the normal interval")
+	}
+
+	// A restart picks up what was saved to the fallback.
+	reloaded := NewFileUsagePlugin(path, time.Minute, NewRequestStatistics())
+	reloaded.quotas, reloaded.keyQuota = NewQuotaTracker(), NewKeyQuotaTracker()
+	reloaded.SetFallbackFile(fallback)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := reloaded.stats.Snapshot().TotalRequests; got != 1 {
+		t.Fatalf("reloaded %d requests, want the one saved to the fallback", got)
+	}
+
+	full.Store(false)
+	if err := plugin.Save(); err != nil {
+		t.Fatalf("Save after recovery: %v", err)
+	}
+	if _, err := os.Stat(fallback); !os.IsNotExist(err) {
+		t.Fatalf("fallback file after recovery: %v, want it removed", err)
+	}
+	if status := plugin.Status(); status.UsingFallback || status.Failing {
+		t.Fatalf("status after recovery = %+v", status)
+	}
+}
@@ -0,0 +1,308 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// webhookTimeout bounds a single POST to the usage webhook.
+const webhookTimeout = 10 * time.Second
+
+// webhookMaxBackoff caps the delay between attempts while the webhook keeps failing.
+const webhookMaxBackoff = 5 * time.Minute
+
+// WebhookEvent is one usage record as posted to the usage webhook.
+type WebhookEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	// APIKeyHash is the hex SHA-256 of the client API key, as written to the audit log, so
+	// that events can be attributed without the key leaving the proxy.
+	APIKeyHash  string            `json:"api_key_hash,omitempty"`
+	Provider    string            `json:"provider,omitempty"`
+	Model       string            `json:"model"`
+	Source      string            `json:"source,omitempty"`
+	AuthIndex   string            `json:"auth_index,omitempty"`
+	RequestType string            `json:"request_type,omitempty"`
+	Failed      bool              `json:"failed"`
+	Cancelled   bool              `json:"cancelled,omitempty"`
+	CacheHit    bool              `json:"cache_hit,omitempty"`
+	LatencyMs   int64             `json:"latency_ms,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Tokens      TokenStats        `json:"tokens"`
+}
+
+// WebhookBatch is the body of one POST to the usage webhook.
+type WebhookBatch struct {
+	// ID identifies the batch and is also sent as the Idempotency-Key header. A batch is
+	// retried with the same ID, so the receiver can drop one it already took.
+	ID     string         `json:"id"`
+	SentAt time.Time      `json:"sent_at"`
+	Events []WebhookEvent `json:"events"`
+}
+
+// WebhookUsagePlugin posts usage records to an HTTP endpoint in JSON batches. It implements
+// coreusage.Plugin: records are buffered and sent from a single goroutine once a batch is
+// full or the flush interval passes. After a failure the delay doubles, up to
+// webhookMaxBackoff, and the failed batch is sent again first; a 4xx answer other than 408
+// and 429 rejects the batch for good, so it is dropped rather than retried.
+type WebhookUsagePlugin struct {
+	url       string
+	headers   map[string]string
+	batchSize int
+	maxBuffer int
+	interval  time.Duration
+	client    *http.Client
+
+	mu      sync.Mutex
+	pending []WebhookEvent
+	dropped int64
+	// wake holds at most one signal that a batch is full.
+	wake chan struct{}
+
+	flushMu sync.Mutex
+	// inflight is the batch that failed to go out last; guarded by flushMu.
+	inflight *WebhookBatch
+	// failures counts consecutive failed flushes; only the flush loop touches it.
+	failures int
+
+	started  atomic.Bool
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// NewWebhookUsagePlugin builds a plugin posting to the webhook cfg describes.
+func NewWebhookUsagePlugin(cfg config.UsageWebhook) *WebhookUsagePlugin {
+	return &WebhookUsagePlugin{
+		url:       strings.TrimSpace(cfg.URL),
+		headers:   cfg.Headers,
+		batchSize: cfg.BatchLimit(),
+		maxBuffer: cfg.BufferLimit(),
+		interval:  cfg.FlushIntervalDuration(),
+		client:    &http.Client{Timeout: webhookTimeout},
+		wake:      make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}
+}
+
+// HandleUsage implements coreusage.Plugin by buffering the record for the next batch. Once
+// the buffer holds maxBuffer records the oldest are dropped.
+func (p *WebhookUsagePlugin) HandleUsage(ctx context.Context, record coreusage.Record) {
+	if p == nil {
+		return
+	}
+	event := newWebhookEvent(ctx, record)
+	p.mu.Lock()
+	p.pending = append(p.pending, event)
+	if over := len(p.pending) - p.maxBuffer; over > 0 {
+		p.pending = p.pending[over:]
+		p.dropped += int64(over)
+	}
+	full := len(p.pending) >= p.batchSize
+	p.mu.Unlock()
+	if full {
+		select {
+		case p.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func newWebhookEvent(ctx context.Context, record coreusage.Record) WebhookEvent {
+	entry := newPendingDetail(ctx, record)
+	event := WebhookEvent{
+		Timestamp:   entry.detail.Timestamp.UTC(),
+		Provider:    record.Provider,
+		Model:       entry.modelName,
+		Source:      entry.detail.Source,
+		AuthIndex:   entry.detail.AuthIndex,
+		RequestType: entry.detail.RequestType,
+		Failed:      entry.detail.Failed,
+		Cancelled:   entry.detail.Cancelled,
+		CacheHit:    entry.detail.CacheHit,
+		LatencyMs:   entry.detail.LatencyMs,
+		Labels:      entry.detail.Labels,
+		Tokens:      entry.detail.Tokens,
+	}
+	if record.APIKey != "" {
+		event.APIKeyHash = hashAPIKey(record.APIKey)
+	}
+	return event
+}
+
+// Start launches the flush loop.
+func (p *WebhookUsagePlugin) Start() {
+	if p == nil || !p.started.CompareAndSwap(false, true) {
+		return
+	}
+	log.Infof("usage-webhook: posting usage records in batches of up to %d, at least every %s", p.batchSize, p.interval)
+	go p.run()
+}
+
+func (p *WebhookUsagePlugin) run() {
+	defer close(p.doneCh)
+	timer := time.NewTimer(p.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case <-p.wake:
+			if p.failures > 0 {
+				// Backing off; the timer brings the next attempt.
+				continue
+			}
+		case <-timer.C:
+		}
+		timer.Reset(p.flushAndBackoff())
+	}
+}
+
+// flushAndBackoff flushes once and returns how long to wait before the next flush.
+func (p *WebhookUsagePlugin) flushAndBackoff() time.Duration {
+	err := p.Flush(context.Background())
+	if err == nil {
+		if p.failures > 0 {
+			log.Infof("usage-webhook: delivery works again")
+		}
+		p.failures = 0
+		return p.interval
+	}
+	if p.failures == 0 {
+		log.Warnf("usage-webhook: %v; retrying with backoff", err)
+	}
+	p.failures++
+	delay := p.interval
+	for i := 0; i < p.failures && delay < webhookMaxBackoff; i++ {
+		delay *= 2
+	}
+	return max(min(delay, webhookMaxBackoff), p.interval)
+}
+
+// Flush posts the buffered records, a batch at a time, and stops at the first batch that
+// fails; that batch goes out first on the next flush.
+func (p *WebhookUsagePlugin) Flush(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+	for {
+		if p.inflight == nil {
+			if p.inflight = p.nextBatch(); p.inflight == nil {
+				return nil
+			}
+		}
+		rejected, err := p.post(ctx, p.inflight)
+		if err != nil && !rejected {
+			return err
+		}
+		if rejected {
+			log.Errorf("usage-webhook: %v; dropping batch %s of %d records", err, p.inflight.ID, len(p.inflight.Events))
+		}
+		p.inflight = nil
+	}
+}
+
+// nextBatch takes up to batchSize records from the buffer, or returns nil when it is empty.
+func (p *WebhookUsagePlugin) nextBatch() *WebhookBatch {
+	p.mu.Lock()
+	n := min(len(p.pending), p.batchSize)
+	events := append([]WebhookEvent(nil), p.pending[:n]...)
+	p.pending = append(p.pending[:0:0], p.pending[n:]...)
+	dropped := p.dropped
+	p.dropped = 0
+	p.mu.Unlock()
+	if dropped > 0 {
+		log.Warnf("usage-webhook: dropped %d records that exceeded the buffer while the endpoint was failing", dropped)
+	}
+	if n == 0 {
+		return nil
+	}
+	return &WebhookBatch{ID: uuid.NewString(), Events: events}
+}
+
+// post sends batch. rejected is set when the endpoint refused it in a way a retry cannot
+// change.
+func (p *WebhookUsagePlugin) post(ctx context.Context, batch *WebhookBatch) (rejected bool, err error) {
+	batch.SentAt = time.Now().UTC()
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return true, fmt.Errorf("encode batch: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return true, fmt.Errorf("build request: %w", redactURLError(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", batch.ID)
+	for name, value := range p.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("post batch: %w", redactURLError(err))
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("post batch: status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	permanent := resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests
+	return permanent, err
+}
+
+// redactURLError drops the URL from a client error, keeping what went wrong: webhook URLs
+// often embed their secret token.
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// Stop ends the flush loop and posts what is left. Records still undelivered when the final
+// flush fails are lost, and an error reports how many.
+func (p *WebhookUsagePlugin) Stop() error {
+	if p == nil {
+		return nil
+	}
+	var err error
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+		if p.started.Load() {
+			<-p.doneCh
+		}
+		if err = p.Flush(context.Background()); err != nil {
+			p.flushMu.Lock()
+			p.mu.Lock()
+			lost := len(p.pending)
+			p.mu.Unlock()
+			if p.inflight != nil {
+				lost += len(p.inflight.Events)
+			}
+			p.flushMu.Unlock()
+			err = fmt.Errorf("usage-webhook: %w; %d records were not delivered", err, lost)
+		}
+	})
+	return err
+}
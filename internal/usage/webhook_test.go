@@ -0,0 +1,107 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestWebhookUsagePluginRetriesBatchWithSameID(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	var mu sync.Mutex
+	var batches []WebhookBatch
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch WebhookBatch
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("decode batch: %v", err)
+		}
+		mu.Lock()
+		batches = append(batches, batch)
+		keys = append(keys, r.Header.Get("Idempotency-Key")+" "+r.Header.Get("Authorization"))
+		mu.Unlock()
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	plugin := NewWebhookUsagePlugin(config.UsageWebhook{
+		URL:       server.URL,
+		Headers:   map[string]string{"Authorization": "Bearer t"},
+		BatchSize: 2,
+	})
+	for _, model := range []string{"gpt-5", "claude-sonnet", "gemini-pro"} {
+		plugin.HandleUsage(context.Background(), coreusage.Record{
+			APIKey: "secret-key", Provider: "openai", Model: model,
+			Detail: coreusage.Detail{TotalTokens: 7},
+		})
+	}
+
+	if err := plugin.Flush(context.Background()); err == nil || !strings.Contains(err.Error(), "status 503") {
+		t.Fatalf("Flush against a failing endpoint returned %v, want a 503 error", err)
+	}
+	status.Store(http.StatusOK)
+	if err := plugin.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 3 {
+		t.Fatalf("got %d posts, want a failed batch, its retry and the rest", len(batches))
+	}
+	if batches[0].ID != batches[1].ID || keys[0] != keys[1] || keys[0] != batches[0].ID+" Bearer t" {
+		t.Fatalf("retry did not reuse the batch id: ids %s/%s, headers %v", batches[0].ID, batches[1].ID, keys)
+	}
+	if batches[2].ID == batches[0].ID || len(batches[1].Events) != 2 || len(batches[2].Events) != 1 {
+		t.Fatalf("unexpected batches after recovery: %+v", batches[1:])
+	}
+	event := batches[1].Events[0]
+	if event.Model != "gpt-5" || event.Provider != "openai" || event.Tokens.TotalTokens != 7 {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if len(event.APIKeyHash) != 64 || strings.Contains(event.APIKeyHash, "secret-key") {
+		t.Fatalf("api_key_hash = %q, want the hex SHA-256 of the key", event.APIKeyHash)
+	}
+}
+
+func TestWebhookUsagePluginDropsRejectedBatchesAndOldestRecords(t *testing.T) {
+	var posts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	plugin := NewWebhookUsagePlugin(config.UsageWebhook{URL: server.URL, BatchSize: 2, MaxBuffer: 3})
+	for _, model := range []string{"a", "b", "c", "d", "e"} {
+		plugin.HandleUsage(context.Background(), coreusage.Record{Model: model})
+	}
+	plugin.mu.Lock()
+	var models []string
+	for _, event := range plugin.pending {
+		models = append(models, event.Model)
+	}
+	plugin.mu.Unlock()
+	if strings.Join(models, ",") != "c,d,e" {
+		t.Fatalf("buffer holds %v, want the newest three records", models)
+	}
+
+	if err := plugin.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := posts.Load(); got != 2 {
+		t.Fatalf("got %d posts, want each rejected batch sent once", got)
+	}
+	if plugin.inflight != nil || len(plugin.pending) != 0 {
+		t.Fatalf("rejected batches were kept for a retry")
+	}
+}
@@ -0,0 +1,101 @@
+package usage
+
+import "time"
+
+// Rolling windows reported in the snapshot: the current hour and the 23 before it, today and
+// the 29 days before it.
+const (
+	hourlyWindowBuckets = 24
+	dailyWindowBuckets  = 30
+)
+
+// UsageBucket counts the requests of one hour or day, or of a whole window.
+type UsageBucket struct {
+	Start    time.Time `json:"start"`
+	Requests int64     `json:"requests"`
+	Failures int64     `json:"failures"`
+	Tokens   int64     `json:"tokens"`
+}
+
+// bucketCounts holds one bucket of a rolling window; the start is its key.
+type bucketCounts struct {
+	requests int64
+	failures int64
+	tokens   int64
+}
+
+// rollingWindow keeps the buckets of the last count hours or days. Requests older than the
+// window are not counted, and buckets that fall out of it are dropped as new ones open, so it
+// never holds more than count buckets for long.
+type rollingWindow struct {
+	count   int
+	daily   bool
+	buckets map[int64]*bucketCounts
+}
+
+func newRollingWindow(count int, daily bool) rollingWindow {
+	return rollingWindow{count: count, daily: daily, buckets: make(map[int64]*bucketCounts)}
+}
+
+// bucketStart returns the start of the bucket holding t: the hour, or the local day.
+func (w rollingWindow) bucketStart(t time.Time) time.Time {
+	if w.daily {
+		year, month, day := t.In(time.Local).Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	}
+	return t.Truncate(time.Hour)
+}
+
+// start returns the start of the oldest bucket in the window ending at now.
+func (w rollingWindow) start(now time.Time) time.Time {
+	current := w.bucketStart(now)
+	if w.daily {
+		return current.AddDate(0, 0, 1-w.count)
+	}
+	return current.Add(-time.Duration(w.count-1) * time.Hour)
+}
+
+func (w rollingWindow) add(now, timestamp time.Time, failed bool, tokens int64) {
+	start := w.start(now)
+	if timestamp.Before(start) {
+		return
+	}
+	key := w.bucketStart(timestamp).Unix()
+	bucket := w.buckets[key]
+	if bucket == nil {
+		for old := range w.buckets {
+			if old < start.Unix() {
+				delete(w.buckets, old)
+			}
+		}
+		bucket = &bucketCounts{}
+		w.buckets[key] = bucket
+	}
+	bucket.requests++
+	bucket.tokens += tokens
+	if failed {
+		bucket.failures++
+	}
+}
+
+// snapshot returns the window ending at now, oldest bucket first, with empty buckets included,
+// and the sum of its buckets.
+func (w rollingWindow) snapshot(now time.Time) ([]UsageBucket, UsageBucket) {
+	out := make([]UsageBucket, w.count)
+	start := w.start(now)
+	total := UsageBucket{Start: start}
+	for i := range out {
+		bucketStart := start.Add(time.Duration(i) * time.Hour)
+		if w.daily {
+			bucketStart = start.AddDate(0, 0, i)
+		}
+		out[i].Start = bucketStart
+		if counts := w.buckets[bucketStart.Unix()]; counts != nil {
+			out[i].Requests, out[i].Failures, out[i].Tokens = counts.requests, counts.failures, counts.tokens
+			total.Requests += counts.requests
+			total.Failures += counts.failures
+			total.Tokens += counts.tokens
+		}
+	}
+	return out, total
+}
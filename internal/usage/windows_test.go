@@ -0,0 +1,49 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestSnapshotRollingWindows(t *testing.T) {
+	enabled := StatisticsEnabled()
+	SetStatisticsEnabled(true)
+	defer SetStatisticsEnabled(enabled)
+
+	stats := NewRequestStatistics()
+	now := time.Now()
+	for _, age := range []time.Duration{0, 2 * time.Hour, 25 * time.Hour, 40 * 24 * time.Hour} {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey: "key", Model: "gpt-5", RequestedAt: now.Add(-age), Failed: age == 0,
+			Detail: coreusage.Detail{TotalTokens: 10},
+		})
+	}
+
+	snapshot := stats.Snapshot()
+	if len(snapshot.Hourly) != hourlyWindowBuckets || len(snapshot.Daily) != dailyWindowBuckets {
+		t.Fatalf("windows hold %d hours and %d days", len(snapshot.Hourly), len(snapshot.Daily))
+	}
+	if last := snapshot.Hourly[hourlyWindowBuckets-1]; last.Requests != 1 || last.Failures != 1 || !last.Start.Equal(now.Truncate(time.Hour)) {
+		t.Fatalf("current hour = %+v, want the newest request", last)
+	}
+	if got := snapshot.Last24Hours; got.Requests != 2 || got.Tokens != 20 {
+		t.Fatalf("last 24 hours = %+v, want 2 requests and 20 tokens", got)
+	}
+	var days int64
+	for _, day := range snapshot.Daily {
+		days += day.Requests
+	}
+	if days != 3 || snapshot.TotalRequests != 4 {
+		t.Fatalf("daily window holds %d of %d requests, want all but the 40-day-old one", days, snapshot.TotalRequests)
+	}
+
+	// The windows are rebuilt from the details when a persisted snapshot is loaded.
+	restored := NewRequestStatistics()
+	restored.MergeSnapshot(snapshot)
+	if got := restored.Snapshot().Last24Hours.Requests; got != 2 {
+		t.Fatalf("restored last 24 hours hold %d requests, want 2", got)
+	}
+}
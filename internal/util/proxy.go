@@ -4,52 +4,71 @@
 package util
 
 import (
-	"context"
-	"net"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
-	log "github.com/sirupsen/logrus"
-	"golang.org/x/net/proxy"
 )
 
 // SetProxy configures the provided HTTP client with proxy settings from the configuration.
 // It supports SOCKS5, HTTP, and HTTPS proxies. The function modifies the client's transport
 // to route requests through the configured proxy server.
 func SetProxy(cfg *config.SDKConfig, httpClient *http.Client) *http.Client {
-	var transport *http.Transport
-	// Attempt to parse the proxy URL from the configuration.
-	proxyURL, errParse := url.Parse(cfg.ProxyURL)
-	if errParse == nil {
-		// Handle different proxy schemes.
-		if proxyURL.Scheme == "socks5" {
-			// Configure SOCKS5 proxy with optional authentication.
-			var proxyAuth *proxy.Auth
-			if proxyURL.User != nil {
-				username := proxyURL.User.Username()
-				password, _ := proxyURL.User.Password()
-				proxyAuth = &proxy.Auth{User: username, Password: password}
-			}
-			dialer, errSOCKS5 := proxy.SOCKS5("tcp", proxyURL.Host, proxyAuth, proxy.Direct)
-			if errSOCKS5 != nil {
-				log.Errorf("create SOCKS5 dialer failed: %v", errSOCKS5)
-				return httpClient
-			}
-			// Set up a custom transport using the SOCKS5 dialer.
-			transport = &http.Transport{
-				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-					return dialer.Dial(network, addr)
-				},
-			}
-		} else if proxyURL.Scheme == "http" || proxyURL.Scheme == "https" {
-			// Configure HTTP or HTTPS proxy.
-			transport = &http.Transport{Proxy: http.ProxyURL(proxyURL)}
-		}
-	}
-	// If a new transport was created, apply it to the HTTP client.
-	if transport != nil {
-		httpClient.Transport = transport
+	if cfg == nil || strings.TrimSpace(cfg.ProxyURL) == "" {
+		return httpClient
 	}
+	transport, err := ProxyTransport(cfg.ProxyURL)
+	if err != nil {
+		log.Errorf("%v", err)
+		return httpClient
+	}
+	httpClient.Transport = transport
 	return httpClient
 }
+
+// ValidateProxyURL reports whether raw is empty or a proxy URL with a supported scheme
+// (http, https or socks5) and a host.
+func ValidateProxyURL(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("parse proxy URL failed: %w", err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q (use http, https or socks5)", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("proxy URL %s has no host", RedactProxyURL(raw))
+	}
+	return nil
+}
+
+// ProxyTransport returns the shared transport for the given proxy URL, creating it on first use.
+// It is the transport requests without a provider use; see SharedTransport.
+func ProxyTransport(raw string) (*http.Transport, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, fmt.Errorf("proxy URL is empty")
+	}
+	transport, err := sharedTransport("", raw)
+	if err != nil {
+		return nil, err
+	}
+	return transport.base, nil
+}
+
+// RedactProxyURL hides proxy credentials so the URL can be logged.
+func RedactProxyURL(raw string) string {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || parsed.User == nil {
+		return raw
+	}
+	return parsed.Scheme + "://***@" + parsed.Host
+}
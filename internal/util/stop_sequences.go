@@ -0,0 +1,45 @@
+package util
+
+import (
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// OpenAIMaxStopSequences is the number of stop sequences OpenAI-style APIs accept.
+	OpenAIMaxStopSequences = 4
+	// GeminiMaxStopSequences is the number of stop sequences Gemini accepts.
+	GeminiMaxStopSequences = 5
+)
+
+// StopSequences reads a stop parameter given either as a single string or as an array of
+// strings. Empty entries are dropped.
+func StopSequences(value gjson.Result) []string {
+	if !value.Exists() {
+		return nil
+	}
+	var stops []string
+	if value.IsArray() {
+		value.ForEach(func(_, item gjson.Result) bool {
+			if s := item.String(); s != "" {
+				stops = append(stops, s)
+			}
+			return true
+		})
+		return stops
+	}
+	if value.Type == gjson.String && value.String() != "" {
+		stops = append(stops, value.String())
+	}
+	return stops
+}
+
+// LimitStopSequences trims stops to the first limit entries, logging a warning naming the
+// upstream when sequences are dropped.
+func LimitStopSequences(stops []string, limit int, upstream string) []string {
+	if limit <= 0 || len(stops) <= limit {
+		return stops
+	}
+	log.Warnf("%s accepts at most %d stop sequences; dropping %d of %d", upstream, limit, len(stops)-limit, len(stops))
+	return stops[:limit]
+}
@@ -4,7 +4,6 @@
 package util
 
 import (
-	"bytes"
 	"fmt"
 	"strings"
 
@@ -115,11 +114,25 @@ func DeleteKey(jsonStr, keyName string) string {
 //   - Unicode escapes (\uXXXX) inside single-quoted strings are forwarded.
 //   - The function does not attempt to fix other non-JSON features beyond quotes.
 func FixJSON(input string) string {
-	var out bytes.Buffer
+	var fixer JSONQuoteFixer
+	return fixer.Write(input) + fixer.Close()
+}
+
+// JSONQuoteFixer applies FixJSON to a document that arrives in fragments, such as tool call
+// arguments streamed by an upstream, so each fragment can be forwarded as soon as it arrives.
+// Only the quoting state is kept between fragments.
+type JSONQuoteFixer struct {
+	inDouble bool
+	inSingle bool
+	escaped  bool // applies within the current string state
+	// hexLeft counts the \uXXXX digits still to forward inside a single-quoted string.
+	hexLeft int
+}
 
-	inDouble := false
-	inSingle := false
-	escaped := false // applies within the current string state
+// Write converts the next fragment and returns the output it produces.
+func (f *JSONQuoteFixer) Write(fragment string) string {
+	var out strings.Builder
+	out.Grow(len(fragment))
 
 	// Helper to write a rune, escaping double quotes when inside a converted
 	// single-quoted string (which becomes a double-quoted string in output).
@@ -132,32 +145,38 @@ func FixJSON(input string) string {
 		out.WriteRune(r)
 	}
 
-	runes := []rune(input)
-	for i := 0; i < len(runes); i++ {
-		r := runes[i]
+	for _, r := range fragment {
+		if f.hexLeft > 0 {
+			if (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F') {
+				out.WriteRune(r)
+				f.hexLeft--
+				continue
+			}
+			f.hexLeft = 0
+		}
 
-		if inDouble {
+		if f.inDouble {
 			out.WriteRune(r)
-			if escaped {
+			if f.escaped {
 				// end of escape sequence in a standard JSON string
-				escaped = false
+				f.escaped = false
 				continue
 			}
 			if r == '\\' {
-				escaped = true
+				f.escaped = true
 				continue
 			}
 			if r == '"' {
-				inDouble = false
+				f.inDouble = false
 			}
 			continue
 		}
 
-		if inSingle {
-			if escaped {
+		if f.inSingle {
+			if f.escaped {
 				// Handle common escape sequences after a backslash within a
 				// single-quoted string
-				escaped = false
+				f.escaped = false
 				switch r {
 				case 'n', 'r', 't', 'b', 'f', '/', '"':
 					// Keep the backslash and the character (except for '"' which
@@ -171,20 +190,10 @@ func FixJSON(input string) string {
 					// \' inside single-quoted becomes a literal '
 					out.WriteRune('\'')
 				case 'u':
-					// Forward \uXXXX if possible
+					// Forward \uXXXX, copying up to 4 hex digits as they arrive
 					out.WriteByte('\\')
 					out.WriteByte('u')
-					// Copy up to next 4 hex digits if present
-					for k := 0; k < 4 && i+1 < len(runes); k++ {
-						peek := runes[i+1]
-						// simple hex check
-						if (peek >= '0' && peek <= '9') || (peek >= 'a' && peek <= 'f') || (peek >= 'A' && peek <= 'F') {
-							out.WriteRune(peek)
-							i++
-						} else {
-							break
-						}
-					}
+					f.hexLeft = 4
 				default:
 					// Unknown escape: preserve the backslash and the char
 					out.WriteByte('\\')
@@ -194,12 +203,12 @@ func FixJSON(input string) string {
 			}
 
 			if r == '\\' { // start escape sequence
-				escaped = true
+				f.escaped = true
 				continue
 			}
 			if r == '\'' { // end of single-quoted string
 				out.WriteByte('"')
-				inSingle = false
+				f.inSingle = false
 				continue
 			}
 			// regular char inside converted string; escape double quotes
@@ -209,23 +218,26 @@ func FixJSON(input string) string {
 
 		// Outside any string
 		if r == '"' {
-			inDouble = true
+			f.inDouble = true
 			out.WriteRune(r)
 			continue
 		}
 		if r == '\'' { // start of non-standard single-quoted string
-			inSingle = true
+			f.inSingle = true
 			out.WriteByte('"')
 			continue
 		}
 		out.WriteRune(r)
 	}
+	return out.String()
+}
 
-	// If input ended while still inside a single-quoted string, close it to
-	// produce the best-effort valid JSON.
-	if inSingle {
-		out.WriteByte('"')
+// Close returns what must follow the last fragment: if the input ended while still inside a
+// single-quoted string, the closing quote that makes the output best-effort valid JSON.
+func (f *JSONQuoteFixer) Close() string {
+	if f.inSingle {
+		f.inSingle = false
+		return `"`
 	}
-
-	return out.String()
+	return ""
 }
@@ -0,0 +1,22 @@
+package util
+
+import "testing"
+
+func TestJSONQuoteFixerMatchesFixJSONAcrossFragments(t *testing.T) {
+	inputs := []string{
+		`{'a': 1, 'b': '2'}`,
+		`{"t": 'He said "hi"'}`,
+		`{'path': 'C:\\tmp\'s', "ok": "a\"b"}`,
+		`{'u': '\u00e9x', 'open': 'unterminated`,
+	}
+	for _, input := range inputs {
+		want := FixJSON(input)
+		for cut := 0; cut <= len(input); cut++ {
+			var fixer JSONQuoteFixer
+			got := fixer.Write(input[:cut]) + fixer.Write(input[cut:]) + fixer.Close()
+			if got != want {
+				t.Fatalf("split %q at %d: got %s, want %s", input, cut, got, want)
+			}
+		}
+	}
+}
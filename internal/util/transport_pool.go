@@ -0,0 +1,291 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"golang.org/x/net/proxy"
+)
+
+// TransportPoolStats reports the connections held by one shared upstream transport.
+type TransportPoolStats struct {
+	Provider string `json:"provider"`
+	// Proxy is the redacted proxy URL, empty for direct connections.
+	Proxy string `json:"proxy,omitempty"`
+	// Idle connections are open and waiting in the pool.
+	Idle int `json:"idle"`
+	// InUse connections are serving at least one request or stream.
+	InUse int `json:"in_use"`
+	// NewConnections counts every connection dialed since the transport was created.
+	NewConnections int64 `json:"new_connections"`
+	// ReusedConnections counts requests served by an already open connection.
+	ReusedConnections int64 `json:"reused_connections"`
+}
+
+type transportKey struct {
+	provider string
+	proxy    string
+}
+
+var (
+	transportMu       sync.Mutex
+	transportSettings config.HTTPTransportConfig
+	sharedTransports  = make(map[transportKey]*pooledTransport)
+)
+
+// ConfigureTransports applies new pool settings. Transports built with the old settings are
+// dropped from the cache and their idle connections closed; requests already using them finish
+// normally.
+func ConfigureTransports(cfg config.HTTPTransportConfig) {
+	transportMu.Lock()
+	if cfg == transportSettings {
+		transportMu.Unlock()
+		return
+	}
+	transportSettings = cfg
+	previous := sharedTransports
+	sharedTransports = make(map[transportKey]*pooledTransport)
+	transportMu.Unlock()
+
+	for _, transport := range previous {
+		transport.CloseIdleConnections()
+	}
+}
+
+// SharedTransport returns the long-lived transport for requests of provider through proxyURL,
+// creating it on first use. An empty proxyURL connects directly, honouring the proxy
+// environment variables like net/http's default transport.
+func SharedTransport(provider, proxyURL string) (http.RoundTripper, error) {
+	transport, err := sharedTransport(provider, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	return transport, nil
+}
+
+func sharedTransport(provider, proxyURL string) (*pooledTransport, error) {
+	key := transportKey{provider: strings.ToLower(strings.TrimSpace(provider)), proxy: strings.TrimSpace(proxyURL)}
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	if cached, ok := sharedTransports[key]; ok {
+		return cached, nil
+	}
+	transport, err := newPooledTransport(key, transportSettings)
+	if err != nil {
+		return nil, err
+	}
+	sharedTransports[key] = transport
+	return transport, nil
+}
+
+// TransportStats returns pool statistics for every shared transport, ordered by provider.
+func TransportStats() []TransportPoolStats {
+	transportMu.Lock()
+	transports := make([]*pooledTransport, 0, len(sharedTransports))
+	for _, transport := range sharedTransports {
+		transports = append(transports, transport)
+	}
+	transportMu.Unlock()
+
+	stats := make([]TransportPoolStats, 0, len(transports))
+	for _, transport := range transports {
+		stats = append(stats, transport.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Provider != stats[j].Provider {
+			return stats[i].Provider < stats[j].Provider
+		}
+		return stats[i].Proxy < stats[j].Proxy
+	})
+	return stats
+}
+
+// pooledTransport wraps an *http.Transport and tracks the connections it dials, so pool
+// usage can be reported without reaching into net/http internals.
+type pooledTransport struct {
+	key  transportKey
+	base *http.Transport
+
+	mu     sync.Mutex
+	conns  map[*trackedConn]struct{}
+	dials  atomic.Int64
+	reused atomic.Int64
+}
+
+func newPooledTransport(key transportKey, settings config.HTTPTransportConfig) (*pooledTransport, error) {
+	p := &pooledTransport{key: key, conns: make(map[*trackedConn]struct{})}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	base := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           p.trackDial(dialer.DialContext),
+		MaxIdleConnsPerHost:   settings.EffectiveMaxIdleConnsPerHost(),
+		IdleConnTimeout:       settings.EffectiveIdleConnTimeout(),
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     !settings.DisableHTTP2,
+	}
+	if key.proxy != "" {
+		if err := ValidateProxyURL(key.proxy); err != nil {
+			return nil, err
+		}
+		proxyURL, _ := url.Parse(key.proxy)
+		if proxyURL.Scheme == "socks5" {
+			// Configure SOCKS5 proxy with optional authentication.
+			var proxyAuth *proxy.Auth
+			if proxyURL.User != nil {
+				username := proxyURL.User.Username()
+				password, _ := proxyURL.User.Password()
+				proxyAuth = &proxy.Auth{User: username, Password: password}
+			}
+			socks, errSOCKS5 := proxy.SOCKS5("tcp", proxyURL.Host, proxyAuth, dialer)
+			if errSOCKS5 != nil {
+				return nil, fmt.Errorf("create SOCKS5 dialer failed: %w", errSOCKS5)
+			}
+			base.Proxy = nil
+			if contextDialer, ok := socks.(proxy.ContextDialer); ok {
+				base.DialContext = p.trackDial(contextDialer.DialContext)
+			} else {
+				base.DialContext = p.trackDial(func(_ context.Context, network, addr string) (net.Conn, error) {
+					return socks.Dial(network, addr)
+				})
+			}
+		} else {
+			// Configure HTTP or HTTPS proxy.
+			base.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	p.base = base
+	return p, nil
+}
+
+func (p *pooledTransport) trackDial(next func(context.Context, string, string) (net.Conn, error)) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		p.dials.Add(1)
+		tracked := &trackedConn{Conn: conn, pool: p}
+		p.mu.Lock()
+		p.conns[tracked] = struct{}{}
+		p.mu.Unlock()
+		return tracked, nil
+	}
+}
+
+// RoundTrip marks the connection serving req as in use until the response body is closed.
+func (p *pooledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var current atomic.Pointer[trackedConn]
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				p.reused.Add(1)
+			}
+			conn := unwrapTrackedConn(info.Conn)
+			if conn != nil {
+				conn.busy.Add(1)
+			}
+			// The transport retries some failures on a fresh connection.
+			if previous := current.Swap(conn); previous != nil {
+				previous.busy.Add(-1)
+			}
+		},
+	}
+	release := func() {
+		if conn := current.Swap(nil); conn != nil {
+			conn.busy.Add(-1)
+		}
+	}
+	resp, err := p.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		release()
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		// The caller owns the upgraded connection; it stays in use until closed.
+		return resp, nil
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// CloseIdleConnections closes the pooled connections that are not serving a request.
+func (p *pooledTransport) CloseIdleConnections() {
+	p.base.CloseIdleConnections()
+}
+
+func (p *pooledTransport) stats() TransportPoolStats {
+	stats := TransportPoolStats{
+		Provider:          p.key.provider,
+		NewConnections:    p.dials.Load(),
+		ReusedConnections: p.reused.Load(),
+	}
+	if p.key.proxy != "" {
+		stats.Proxy = RedactProxyURL(p.key.proxy)
+	}
+	p.mu.Lock()
+	for conn := range p.conns {
+		if conn.busy.Load() > 0 {
+			stats.InUse++
+		} else {
+			stats.Idle++
+		}
+	}
+	p.mu.Unlock()
+	return stats
+}
+
+// trackedConn removes itself from its pool's connection set when closed.
+type trackedConn struct {
+	net.Conn
+	pool *pooledTransport
+	busy atomic.Int32
+	once sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.pool.mu.Lock()
+		delete(c.pool.conns, c)
+		c.pool.mu.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+// unwrapTrackedConn finds the dialed connection below TLS wrappers.
+func unwrapTrackedConn(conn net.Conn) *trackedConn {
+	for conn != nil {
+		if tracked, ok := conn.(*trackedConn); ok {
+			return tracked
+		}
+		wrapper, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			return nil
+		}
+		conn = wrapper.NetConn()
+	}
+	return nil
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,119 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func streamingServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher, _ := w.(http.Flusher)
+		for i := 0; i < 3; i++ {
+			_, _ = fmt.Fprintf(w, "data: %d\n\n", i)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}))
+}
+
+func TestPooledTransportTracksConnections(t *testing.T) {
+	server := streamingServer()
+	defer server.Close()
+	transport, err := newPooledTransport(transportKey{provider: "test"}, config.HTTPTransportConfig{})
+	if err != nil {
+		t.Fatalf("newPooledTransport: %v", err)
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 3; i++ {
+		resp, errGet := client.Get(server.URL)
+		if errGet != nil {
+			t.Fatalf("request %d: %v", i, errGet)
+		}
+		if stats := transport.stats(); stats.InUse != 1 || stats.Idle != 0 {
+			t.Fatalf("open stream: got %+v, want one connection in use", stats)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	stats := transport.stats()
+	if stats.NewConnections != 1 || stats.ReusedConnections != 2 {
+		t.Fatalf("got %+v, want one dial reused by the next two requests", stats)
+	}
+	if stats.InUse != 0 || stats.Idle != 1 {
+		t.Fatalf("got %+v, want the connection back in the idle pool", stats)
+	}
+}
+
+func TestSharedTransportKeyedByProviderAndProxy(t *testing.T) {
+	defer ConfigureTransports(config.HTTPTransportConfig{})
+
+	codex, _ := SharedTransport("codex", "")
+	claude, _ := SharedTransport("claude", "")
+	codexProxied, _ := SharedTransport("codex", "http://10.0.0.2:8080")
+	if again, _ := SharedTransport("codex", " "); again != codex {
+		t.Fatal("expected the same transport for the same provider and proxy")
+	}
+	if codex == claude || codex == codexProxied {
+		t.Fatal("expected distinct transports per provider and per proxy")
+	}
+
+	ConfigureTransports(config.HTTPTransportConfig{MaxIdleConnsPerHost: 8})
+	retuned, _ := SharedTransport("codex", "")
+	if retuned == codex {
+		t.Fatal("expected new settings to replace cached transports")
+	}
+	if got := retuned.(*pooledTransport).base.MaxIdleConnsPerHost; got != 8 {
+		t.Fatalf("MaxIdleConnsPerHost = %d, want 8", got)
+	}
+}
+
+// BenchmarkConcurrentStreams runs 200 concurrent streams per iteration and reports the
+// connections dialed; compare net/http's per-host idle default with the pool default.
+func BenchmarkConcurrentStreams(b *testing.B) {
+	settings := map[string]config.HTTPTransportConfig{
+		"idle-per-host-2":  {MaxIdleConnsPerHost: 2},
+		"idle-per-host-32": {},
+	}
+	for name, cfg := range settings {
+		b.Run(name, func(b *testing.B) {
+			server := streamingServer()
+			defer server.Close()
+			transport, err := newPooledTransport(transportKey{provider: "bench"}, cfg)
+			if err != nil {
+				b.Fatalf("newPooledTransport: %v", err)
+			}
+			defer transport.CloseIdleConnections()
+			client := &http.Client{Transport: transport}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var wg sync.WaitGroup
+				for s := 0; s < 200; s++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						resp, errGet := client.Get(server.URL)
+						if errGet != nil {
+							return
+						}
+						_, _ = io.Copy(io.Discard, resp.Body)
+						_ = resp.Body.Close()
+					}()
+				}
+				wg.Wait()
+			}
+			b.ReportMetric(float64(transport.stats().NewConnections)/float64(b.N), "dials/op")
+		})
+	}
+}
@@ -12,7 +12,8 @@ import (
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
-	log "github.com/sirupsen/logrus"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/sirupsen/logrus"
 )
 
 var functionNameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9_.:-]`)
@@ -56,7 +57,11 @@ func SanitizeFunctionName(name string) string {
 
 // SetLogLevel configures the logrus log level based on the configuration.
 // It sets the log level to DebugLevel if debug mode is enabled, otherwise to InfoLevel.
+// An embedder's logger installed with log.SetLogger keeps the level it was given.
 func SetLogLevel(cfg *config.Config) {
+	if log.Injected() {
+		return
+	}
 	currentLevel := log.GetLevel()
 	var newLevel log.Level
 	if cfg.Debug {
@@ -66,7 +71,7 @@ func SetLogLevel(cfg *config.Config) {
 	}
 
 	if currentLevel != newLevel {
-		log.SetLevel(newLevel)
+		logrus.SetLevel(newLevel)
 		log.Infof("log level changed from %s to %s (debug=%t)", currentLevel, newLevel, cfg.Debug)
 	}
 }
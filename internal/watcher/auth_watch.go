@@ -0,0 +1,240 @@
+// auth_watch.go hardens auth directory hot-loading: it coalesces bursts of events per file,
+// retries files caught mid-write, and polls directories fsnotify cannot watch.
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/filecrypt"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+const (
+	// authEventDebounce coalesces the Create/Write/Chmod bursts editors and atomic writers emit.
+	authEventDebounce = 200 * time.Millisecond
+	// authParseRetryDelay and authParseRetries bound how long a half-written file is retried.
+	authParseRetryDelay = 250 * time.Millisecond
+	authParseRetries    = 4
+	// authPollInterval is the scan period for auth directories fsnotify cannot watch.
+	authPollInterval = 5 * time.Second
+)
+
+// authFileStat is the cheap fingerprint the poller compares between scans.
+type authFileStat struct {
+	size    int64
+	modTime time.Time
+}
+
+// isAuthFileEvent reports whether event concerns a JSON file in a watched auth directory.
+func (w *Watcher) isAuthFileEvent(event fsnotify.Event) bool {
+	normalized := w.normalizeAuthPath(event.Name)
+	if normalized == w.normalizeAuthPath(w.configPath) {
+		return false
+	}
+	return w.inAuthDir(normalized) && strings.HasSuffix(normalized, ".json")
+}
+
+// scheduleAuthEvent merges event into the pending operations for its file and (re)arms a
+// short timer; handleEvent runs once the file has been quiet for authEventDebounce.
+func (w *Watcher) scheduleAuthEvent(event fsnotify.Event) {
+	key := w.normalizeAuthPath(event.Name)
+	w.authEventMu.Lock()
+	defer w.authEventMu.Unlock()
+	if w.authEventTimers == nil {
+		w.authEventTimers = make(map[string]*time.Timer)
+		w.pendingAuthOps = make(map[string]fsnotify.Op)
+	}
+	w.pendingAuthOps[key] |= event.Op
+	if timer, ok := w.authEventTimers[key]; ok {
+		timer.Stop()
+	}
+	name := event.Name
+	w.authEventTimers[key] = time.AfterFunc(authEventDebounce, func() {
+		w.authEventMu.Lock()
+		op := w.pendingAuthOps[key]
+		delete(w.pendingAuthOps, key)
+		delete(w.authEventTimers, key)
+		w.authEventMu.Unlock()
+		if op != 0 {
+			w.handleEvent(fsnotify.Event{Name: name, Op: op})
+		}
+	})
+}
+
+func (w *Watcher) stopAuthEventTimers() {
+	w.authEventMu.Lock()
+	defer w.authEventMu.Unlock()
+	for key, timer := range w.authEventTimers {
+		timer.Stop()
+		delete(w.authEventTimers, key)
+	}
+	for key, timer := range w.parseRetryTimers {
+		timer.Stop()
+		delete(w.parseRetryTimers, key)
+	}
+	w.pendingAuthOps = nil
+	w.parseRetries = nil
+}
+
+// authFileComplete reports whether data parses as an auth file. Encrypted files that cannot
+// be opened with the current key count as complete; the synthesizer reports those.
+func authFileComplete(data []byte) bool {
+	plain, errDecrypt := filecrypt.Decrypt(data)
+	if errors.Is(errDecrypt, filecrypt.ErrKeyUnavailable) {
+		return true
+	}
+	if errDecrypt != nil {
+		return false
+	}
+	var metadata map[string]any
+	return json.Unmarshal(plain, &metadata) == nil
+}
+
+// retryIncompleteAuthFile re-reads path after a short delay, assuming a writer is still
+// filling it. After authParseRetries attempts the previously loaded credentials stay active.
+func (w *Watcher) retryIncompleteAuthFile(path string) {
+	key := w.normalizeAuthPath(path)
+	w.authEventMu.Lock()
+	defer w.authEventMu.Unlock()
+	if w.parseRetries == nil {
+		w.parseRetries = make(map[string]int)
+		w.parseRetryTimers = make(map[string]*time.Timer)
+	}
+	attempt := w.parseRetries[key] + 1
+	if attempt > authParseRetries {
+		delete(w.parseRetries, key)
+		delete(w.parseRetryTimers, key)
+		log.Warnf("auth file %s is not valid JSON after %d attempts; keeping previously loaded credentials", filepath.Base(path), authParseRetries)
+		return
+	}
+	w.parseRetries[key] = attempt
+	log.Debugf("auth file %s is incomplete, retrying (%d/%d)", filepath.Base(path), attempt, authParseRetries)
+	if timer, ok := w.parseRetryTimers[key]; ok {
+		timer.Stop()
+	}
+	w.parseRetryTimers[key] = time.AfterFunc(authParseRetryDelay, func() { w.addOrUpdateClient(path) })
+}
+
+func (w *Watcher) clearParseRetry(path string) {
+	key := w.normalizeAuthPath(path)
+	w.authEventMu.Lock()
+	delete(w.parseRetries, key)
+	if timer, ok := w.parseRetryTimers[key]; ok {
+		timer.Stop()
+		delete(w.parseRetryTimers, key)
+	}
+	w.authEventMu.Unlock()
+}
+
+// authIdentityForPath describes the accounts currently loaded from path for log lines.
+func (w *Watcher) authIdentityForPath(path string) string {
+	normalized := w.normalizeAuthPath(path)
+	w.clientsMutex.RLock()
+	defer w.clientsMutex.RUnlock()
+	for _, auth := range w.currentAuths {
+		if auth == nil || auth.Attributes == nil || w.normalizeAuthPath(auth.Attributes["path"]) != normalized {
+			continue
+		}
+		if _, virtual := auth.Attributes["gemini_virtual_parent"]; virtual {
+			continue
+		}
+		return fmt.Sprintf("%s account %s (id %s)", auth.Provider, auth.Label, auth.ID)
+	}
+	return filepath.Base(path)
+}
+
+// pollAuthDir adds dir to the set scanned by the polling fallback and starts the poller.
+func (w *Watcher) pollAuthDir(dir string) {
+	w.authEventMu.Lock()
+	if w.polledAuthDirs == nil {
+		w.polledAuthDirs = make(map[string]string)
+	}
+	w.polledAuthDirs[w.normalizeAuthPath(dir)] = dir
+	ctx := w.pollCtx
+	start := !w.polling && ctx != nil
+	if start {
+		w.polling = true
+	}
+	w.authEventMu.Unlock()
+	log.Warnf("falling back to polling auth directory %s every %s", dir, authPollInterval)
+	if start {
+		go w.pollAuthDirs(ctx)
+	}
+}
+
+func (w *Watcher) unpollAuthDir(dir string) {
+	w.authEventMu.Lock()
+	delete(w.polledAuthDirs, w.normalizeAuthPath(dir))
+	w.authEventMu.Unlock()
+}
+
+func (w *Watcher) pollAuthDirs(ctx context.Context) {
+	ticker := time.NewTicker(authPollInterval)
+	defer ticker.Stop()
+	defer func() {
+		w.authEventMu.Lock()
+		w.polling = false
+		w.authEventMu.Unlock()
+	}()
+	seen := make(map[string]authFileStat)
+	w.scanPolledAuthDirs(seen)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.scanPolledAuthDirs(seen)
+		}
+	}
+}
+
+// scanPolledAuthDirs compares the polled directories against seen and feeds differences
+// through the same debounced path as fsnotify events.
+func (w *Watcher) scanPolledAuthDirs(seen map[string]authFileStat) {
+	w.authEventMu.Lock()
+	dirs := make([]string, 0, len(w.polledAuthDirs))
+	for _, dir := range w.polledAuthDirs {
+		dirs = append(dirs, dir)
+	}
+	w.authEventMu.Unlock()
+
+	present := make(map[string]struct{})
+	for _, dir := range dirs {
+		entries, errRead := os.ReadDir(dir)
+		if errRead != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name()), ".json") {
+				continue
+			}
+			info, errInfo := entry.Info()
+			if errInfo != nil {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			key := w.normalizeAuthPath(path)
+			present[key] = struct{}{}
+			stat := authFileStat{size: info.Size(), modTime: info.ModTime()}
+			if prev, ok := seen[key]; ok && prev == stat {
+				continue
+			}
+			seen[key] = stat
+			w.scheduleAuthEvent(fsnotify.Event{Name: path, Op: fsnotify.Write})
+		}
+	}
+	for key := range seen {
+		if _, ok := present[key]; !ok {
+			delete(seen, key)
+			w.scheduleAuthEvent(fsnotify.Event{Name: key, Op: fsnotify.Remove})
+		}
+	}
+}
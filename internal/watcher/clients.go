@@ -14,9 +14,9 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
-	log "github.com/sirupsen/logrus"
 )
 
 func (w *Watcher) reloadClients(rescanAuth bool, affectedOAuthProviders []string, forceAuthRefresh bool) {
@@ -72,9 +72,7 @@ func (w *Watcher) reloadClients(rescanAuth bool, affectedOAuthProviders []string
 		w.clientsMutex.Lock()
 
 		w.lastAuthHashes = make(map[string]string)
-		if resolvedAuthDir, errResolveAuthDir := util.ResolveAuthDir(cfg.AuthDir); errResolveAuthDir != nil {
-			log.Errorf("failed to resolve auth directory for hash cache: %v", errResolveAuthDir)
-		} else if resolvedAuthDir != "" {
+		for _, resolvedAuthDir := range w.resolvedAuthDirs(cfg) {
 			_ = filepath.Walk(resolvedAuthDir, func(path string, info fs.FileInfo, err error) error {
 				if err != nil {
 					return nil
@@ -122,6 +120,11 @@ func (w *Watcher) addOrUpdateClient(path string) {
 		log.Debugf("ignoring empty auth file: %s", filepath.Base(path))
 		return
 	}
+	if !authFileComplete(data) {
+		w.retryIncompleteAuthFile(path)
+		return
+	}
+	w.clearParseRetry(path)
 
 	sum := sha256.Sum256(data)
 	curHash := hex.EncodeToString(sum[:])
@@ -141,11 +144,17 @@ func (w *Watcher) addOrUpdateClient(path string) {
 		return
 	}
 
+	_, known := w.lastAuthHashes[normalized]
 	w.lastAuthHashes[normalized] = curHash
 
 	w.clientsMutex.Unlock() // Unlock before the callback
 
 	w.refreshAuthState(false)
+	if known {
+		log.Infof("auth updated: %s", w.authIdentityForPath(path))
+	} else {
+		log.Infof("auth added: %s", w.authIdentityForPath(path))
+	}
 
 	if w.reloadCallback != nil {
 		log.Debugf("triggering server update callback after add/update")
@@ -156,6 +165,8 @@ func (w *Watcher) addOrUpdateClient(path string) {
 
 func (w *Watcher) removeClient(path string) {
 	normalized := w.normalizeAuthPath(path)
+	identity := w.authIdentityForPath(path)
+	w.clearParseRetry(path)
 	w.clientsMutex.Lock()
 
 	cfg := w.config
@@ -164,6 +175,7 @@ func (w *Watcher) removeClient(path string) {
 	w.clientsMutex.Unlock() // Release the lock before the callback
 
 	w.refreshAuthState(false)
+	log.Infof("auth removed: %s; in-flight requests finish with the previous credentials", identity)
 
 	if w.reloadCallback != nil {
 		log.Debugf("triggering server update callback after removal")
@@ -176,35 +188,49 @@ func (w *Watcher) loadFileClients(cfg *config.Config) int {
 	authFileCount := 0
 	successfulAuthCount := 0
 
-	authDir, errResolveAuthDir := util.ResolveAuthDir(cfg.AuthDir)
+	for _, authDir := range w.resolvedAuthDirs(cfg) {
+		errWalk := filepath.Walk(authDir, func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				log.Debugf("error accessing path %s: %v", path, err)
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(strings.ToLower(info.Name()), ".json") {
+				authFileCount++
+				log.Debugf("processing auth file %d: %s", authFileCount, filepath.Base(path))
+				if data, errCreate := os.ReadFile(path); errCreate == nil && len(data) > 0 {
+					successfulAuthCount++
+				}
+			}
+			return nil
+		})
+
+		if errWalk != nil {
+			log.Errorf("error walking auth directory %s: %v", authDir, errWalk)
+		}
+	}
+	log.Debugf("auth directory scan complete - found %d .json files, %d readable", authFileCount, successfulAuthCount)
+	return authFileCount
+}
+
+// resolvedAuthDirs returns the configured auth directories for scanning. The first entry
+// follows cfg.AuthDir so rescans pick up a changed primary directory.
+func (w *Watcher) resolvedAuthDirs(cfg *config.Config) []string {
+	primary, errResolveAuthDir := util.ResolveAuthDir(cfg.AuthDir)
 	if errResolveAuthDir != nil {
 		log.Errorf("failed to resolve auth directory: %v", errResolveAuthDir)
-		return 0
+		primary = ""
 	}
-	if authDir == "" {
-		return 0
+	var dirs []string
+	if primary != "" {
+		dirs = append(dirs, primary)
 	}
-
-	errWalk := filepath.Walk(authDir, func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			log.Debugf("error accessing path %s: %v", path, err)
-			return err
+	skip := map[string]struct{}{w.normalizeAuthPath(primary): {}, w.normalizeAuthPath(w.authDir): {}}
+	for _, dir := range w.authDirectories(cfg) {
+		if _, ok := skip[w.normalizeAuthPath(dir)]; !ok {
+			dirs = append(dirs, dir)
 		}
-		if !info.IsDir() && strings.HasSuffix(strings.ToLower(info.Name()), ".json") {
-			authFileCount++
-			log.Debugf("processing auth file %d: %s", authFileCount, filepath.Base(path))
-			if data, errCreate := os.ReadFile(path); errCreate == nil && len(data) > 0 {
-				successfulAuthCount++
-			}
-		}
-		return nil
-	})
-
-	if errWalk != nil {
-		log.Errorf("error walking auth directory: %v", errWalk)
 	}
-	log.Debugf("auth directory scan complete - found %d .json files, %d readable", authFileCount, successfulAuthCount)
-	return authFileCount
+	return dirs
 }
 
 func BuildAPIKeyClients(cfg *config.Config) (int, int, int, int, int) {
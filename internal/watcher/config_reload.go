@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"os"
 	"reflect"
+	"slices"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
@@ -14,7 +15,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher/diff"
 	"gopkg.in/yaml.v3"
 
-	log "github.com/sirupsen/logrus"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 )
 
 func (w *Watcher) stopConfigReloadTimer() {
@@ -86,6 +87,24 @@ func (w *Watcher) reloadConfig() bool {
 		log.Errorf("failed to reload config: %v", errLoadConfig)
 		return false
 	}
+	w.applyConfig(newConfig)
+	return true
+}
+
+// ApplyConfig reloads clients from newConfig as if it had just been read from the config
+// file, returning once the reload callback has run. The file itself is not touched.
+func (w *Watcher) ApplyConfig(newConfig *config.Config) {
+	if newConfig == nil {
+		return
+	}
+	w.applyConfig(newConfig)
+}
+
+// applyConfig adopts newConfig and reloads the clients it affects. Concurrent reloads
+// from the file and from ApplyConfig are applied one at a time.
+func (w *Watcher) applyConfig(newConfig *config.Config) {
+	w.applyMu.Lock()
+	defer w.applyMu.Unlock()
 
 	if w.mirroredAuthDir != "" {
 		newConfig.AuthDir = w.mirroredAuthDir
@@ -100,6 +119,10 @@ func (w *Watcher) reloadConfig() bool {
 	w.clientsMutex.Lock()
 	var oldConfig *config.Config
 	_ = yaml.Unmarshal(w.oldConfigYaml, &oldConfig)
+	if oldConfig != nil && w.config != nil {
+		// AuthDir is resolved at runtime and not part of the YAML snapshot.
+		oldConfig.AuthDir = w.config.AuthDir
+	}
 	w.oldConfigYaml, _ = yaml.Marshal(newConfig)
 	w.config = newConfig
 	w.clientsMutex.Unlock()
@@ -126,10 +149,12 @@ func (w *Watcher) reloadConfig() bool {
 		}
 	}
 
-	authDirChanged := oldConfig == nil || oldConfig.AuthDir != newConfig.AuthDir
+	authDirChanged := oldConfig == nil || !slices.Equal(oldConfig.AuthDirectories(), newConfig.AuthDirectories())
+	if authDirChanged {
+		w.syncWatchedAuthDirs(newConfig)
+	}
 	forceAuthRefresh := oldConfig != nil && (oldConfig.ForceModelPrefix != newConfig.ForceModelPrefix || !reflect.DeepEqual(oldConfig.OAuthModelAlias, newConfig.OAuthModelAlias))
 
 	log.Infof("config successfully reloaded, triggering client reload")
 	w.reloadClients(authDirChanged, affectedOAuthProviders, forceAuthRefresh)
-	return true
 }
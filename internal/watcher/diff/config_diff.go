@@ -21,18 +21,72 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.Port != newCfg.Port {
 		changes = append(changes, fmt.Sprintf("port: %d -> %d", oldCfg.Port, newCfg.Port))
 	}
-	if oldCfg.AuthDir != newCfg.AuthDir {
-		changes = append(changes, fmt.Sprintf("auth-dir: %s -> %s", oldCfg.AuthDir, newCfg.AuthDir))
+	if oldCfg.TLS.Enable != newCfg.TLS.Enable {
+		changes = append(changes, fmt.Sprintf("tls.enable: %t -> %t (applies after restart)", oldCfg.TLS.Enable, newCfg.TLS.Enable))
+	}
+	if oldCfg.TLS.Cert != newCfg.TLS.Cert || oldCfg.TLS.Key != newCfg.TLS.Key {
+		changes = append(changes, fmt.Sprintf("tls.cert/key: %s, %s -> %s, %s (applies after restart)", oldCfg.TLS.Cert, oldCfg.TLS.Key, newCfg.TLS.Cert, newCfg.TLS.Key))
+	}
+	if !reflect.DeepEqual(oldCfg.TLS.ACME, newCfg.TLS.ACME) {
+		changes = append(changes, fmt.Sprintf("tls.acme.domains: %v -> %v (applies after restart)", oldCfg.TLS.ACME.Domains, newCfg.TLS.ACME.Domains))
+	}
+	if oldCfg.TLS.ClientAuth.CA != newCfg.TLS.ClientAuth.CA || oldCfg.TLS.ClientAuth.Optional != newCfg.TLS.ClientAuth.Optional {
+		changes = append(changes, fmt.Sprintf("tls.client-auth: ca %q optional %t -> ca %q optional %t (applies after restart)", oldCfg.TLS.ClientAuth.CA, oldCfg.TLS.ClientAuth.Optional, newCfg.TLS.ClientAuth.CA, newCfg.TLS.ClientAuth.Optional))
+	}
+	if !reflect.DeepEqual(oldCfg.TLS.ClientAuth.Identities, newCfg.TLS.ClientAuth.Identities) {
+		changes = append(changes, fmt.Sprintf("tls.client-auth.identities: %d -> %d entries", len(oldCfg.TLS.ClientAuth.Identities), len(newCfg.TLS.ClientAuth.Identities)))
+	}
+	if oldDirs, newDirs := strings.Join(oldCfg.AuthDirectories(), ", "), strings.Join(newCfg.AuthDirectories(), ", "); oldDirs != newDirs {
+		changes = append(changes, fmt.Sprintf("auth-dir: %s -> %s", oldDirs, newDirs))
 	}
 	if oldCfg.Debug != newCfg.Debug {
 		changes = append(changes, fmt.Sprintf("debug: %t -> %t", oldCfg.Debug, newCfg.Debug))
 	}
+	if oldCfg.DebugPprof != newCfg.DebugPprof {
+		changes = append(changes, fmt.Sprintf("debug-pprof: %t -> %t", oldCfg.DebugPprof, newCfg.DebugPprof))
+	}
 	if oldCfg.LoggingToFile != newCfg.LoggingToFile {
 		changes = append(changes, fmt.Sprintf("logging-to-file: %t -> %t", oldCfg.LoggingToFile, newCfg.LoggingToFile))
 	}
 	if oldCfg.UsageStatisticsEnabled != newCfg.UsageStatisticsEnabled {
 		changes = append(changes, fmt.Sprintf("usage-statistics-enabled: %t -> %t", oldCfg.UsageStatisticsEnabled, newCfg.UsageStatisticsEnabled))
 	}
+	if oldCfg.UsageStatisticsMaxKeys != newCfg.UsageStatisticsMaxKeys {
+		changes = append(changes, fmt.Sprintf("usage-statistics-max-keys: %d -> %d", oldCfg.UsageStatisticsMaxKeys, newCfg.UsageStatisticsMaxKeys))
+	}
+	if !reflect.DeepEqual(oldCfg.UsageLabels.Headers, newCfg.UsageLabels.Headers) {
+		changes = append(changes, fmt.Sprintf("usage-labels.headers: %v -> %v", oldCfg.UsageLabels.Headers, newCfg.UsageLabels.Headers))
+	}
+	if !reflect.DeepEqual(oldCfg.UsageLabels.TrustedSources, newCfg.UsageLabels.TrustedSources) {
+		changes = append(changes, fmt.Sprintf("usage-labels.trusted-sources: %v -> %v", oldCfg.UsageLabels.TrustedSources, newCfg.UsageLabels.TrustedSources))
+	}
+	if oldCfg.UsageLabels.MaxValueLength != newCfg.UsageLabels.MaxValueLength {
+		changes = append(changes, fmt.Sprintf("usage-labels.max-value-length: %d -> %d", oldCfg.UsageLabels.MaxValueLength, newCfg.UsageLabels.MaxValueLength))
+	}
+	if oldCfg.UsageRedis.Address != newCfg.UsageRedis.Address || oldCfg.UsageRedis.KeyPrefixOrDefault() != newCfg.UsageRedis.KeyPrefixOrDefault() {
+		changes = append(changes, fmt.Sprintf("usage-redis: %s prefix %q -> %s prefix %q (applies after restart)", oldCfg.UsageRedis.Address, oldCfg.UsageRedis.KeyPrefixOrDefault(), newCfg.UsageRedis.Address, newCfg.UsageRedis.KeyPrefixOrDefault()))
+	}
+	if oldCfg.UsageWebhook.URL != newCfg.UsageWebhook.URL {
+		changes = append(changes, "usage-webhook.url: updated (applies after restart)")
+	}
+	if !reflect.DeepEqual(oldCfg.ModelPricing, newCfg.ModelPricing) {
+		changes = append(changes, fmt.Sprintf("model-pricing: %d -> %d entries", len(oldCfg.ModelPricing), len(newCfg.ModelPricing)))
+	}
+	if oldCfg.Metrics.Endpoint != newCfg.Metrics.Endpoint {
+		changes = append(changes, fmt.Sprintf("metrics.endpoint: %t -> %t", oldCfg.Metrics.Endpoint, newCfg.Metrics.Endpoint))
+	}
+	if oldCfg.Metrics.Token != newCfg.Metrics.Token {
+		changes = append(changes, "metrics.token: updated")
+	}
+	if oldCfg.Metrics.Pushgateway.URL != newCfg.Metrics.Pushgateway.URL || oldCfg.Metrics.Pushgateway.Interval != newCfg.Metrics.Pushgateway.Interval {
+		changes = append(changes, fmt.Sprintf("metrics.pushgateway: %s every %q -> %s every %q (applies after restart)", oldCfg.Metrics.Pushgateway.URL, oldCfg.Metrics.Pushgateway.Interval, newCfg.Metrics.Pushgateway.URL, newCfg.Metrics.Pushgateway.Interval))
+	}
+	if oldCfg.ModelCacheTTL != newCfg.ModelCacheTTL {
+		changes = append(changes, fmt.Sprintf("model-cache-ttl: %q -> %q", oldCfg.ModelCacheTTL, newCfg.ModelCacheTTL))
+	}
+	if oldCfg.SecretRefreshInterval != newCfg.SecretRefreshInterval {
+		changes = append(changes, fmt.Sprintf("secret-refresh-interval: %q -> %q", oldCfg.SecretRefreshInterval, newCfg.SecretRefreshInterval))
+	}
 	if oldCfg.DisableCooling != newCfg.DisableCooling {
 		changes = append(changes, fmt.Sprintf("disable-cooling: %t -> %t", oldCfg.DisableCooling, newCfg.DisableCooling))
 	}
@@ -42,6 +96,56 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.LogsMaxTotalSizeMB != newCfg.LogsMaxTotalSizeMB {
 		changes = append(changes, fmt.Sprintf("logs-max-total-size-mb: %d -> %d", oldCfg.LogsMaxTotalSizeMB, newCfg.LogsMaxTotalSizeMB))
 	}
+	if oldCfg.AuditLog.Enabled != newCfg.AuditLog.Enabled {
+		changes = append(changes, fmt.Sprintf("audit-log.enabled: %t -> %t", oldCfg.AuditLog.Enabled, newCfg.AuditLog.Enabled))
+	}
+	if !reflect.DeepEqual(oldCfg.AuditLog.RedactPatterns, newCfg.AuditLog.RedactPatterns) || oldCfg.AuditLog.StripImages != newCfg.AuditLog.StripImages {
+		changes = append(changes, fmt.Sprintf("audit-log redaction: %d patterns -> %d patterns, strip-images %t -> %t", len(oldCfg.AuditLog.RedactPatterns), len(newCfg.AuditLog.RedactPatterns), oldCfg.AuditLog.StripImages, newCfg.AuditLog.StripImages))
+	}
+	if oldCfg.LogOutput() != newCfg.LogOutput() {
+		changes = append(changes, fmt.Sprintf("logging.output: %s -> %s", oldCfg.LogOutput(), newCfg.LogOutput()))
+	}
+	if oldCfg.Logging.Syslog != newCfg.Logging.Syslog {
+		changes = append(changes, fmt.Sprintf("logging.syslog: %s %q -> %s %q", oldCfg.Logging.Syslog.NetworkOrDefault(), oldCfg.Logging.Syslog.Address, newCfg.Logging.Syslog.NetworkOrDefault(), newCfg.Logging.Syslog.Address))
+	}
+	if oldCfg.LogSampling != newCfg.LogSampling {
+		changes = append(changes, fmt.Sprintf("log-sampling: rate %d -> %d, burst %d -> %d, interval %q -> %q",
+			oldCfg.LogSampling.Rate, newCfg.LogSampling.Rate, oldCfg.LogSampling.Burst, newCfg.LogSampling.Burst,
+			oldCfg.LogSampling.Interval, newCfg.LogSampling.Interval))
+	}
+	if oldCfg.Tracing.Enabled != newCfg.Tracing.Enabled {
+		changes = append(changes, fmt.Sprintf("tracing.enabled: %t -> %t", oldCfg.Tracing.Enabled, newCfg.Tracing.Enabled))
+	}
+	if oldCfg.Tracing.Endpoint != newCfg.Tracing.Endpoint {
+		changes = append(changes, fmt.Sprintf("tracing.endpoint: %s -> %s", formatProxyURL(oldCfg.Tracing.Endpoint), formatProxyURL(newCfg.Tracing.Endpoint)))
+	}
+	if oldCfg.Tracing.ServiceNameOrDefault() != newCfg.Tracing.ServiceNameOrDefault() {
+		changes = append(changes, fmt.Sprintf("tracing.service-name: %s -> %s", oldCfg.Tracing.ServiceNameOrDefault(), newCfg.Tracing.ServiceNameOrDefault()))
+	}
+	if oldCfg.Tracing.Ratio() != newCfg.Tracing.Ratio() {
+		changes = append(changes, fmt.Sprintf("tracing.sample-ratio: %v -> %v", oldCfg.Tracing.Ratio(), newCfg.Tracing.Ratio()))
+	}
+	if !reflect.DeepEqual(oldCfg.Tracing.Headers, newCfg.Tracing.Headers) {
+		changes = append(changes, fmt.Sprintf("tracing.headers: %d -> %d entries", len(oldCfg.Tracing.Headers), len(newCfg.Tracing.Headers)))
+	}
+	if oldCfg.Sentry.DSN != newCfg.Sentry.DSN {
+		changes = append(changes, fmt.Sprintf("sentry.dsn: %t -> %t (redacted)", oldCfg.Sentry.Enabled(), newCfg.Sentry.Enabled()))
+	}
+	if oldCfg.Sentry.Environment != newCfg.Sentry.Environment {
+		changes = append(changes, fmt.Sprintf("sentry.environment: %s -> %s", oldCfg.Sentry.Environment, newCfg.Sentry.Environment))
+	}
+	if oldCfg.Sentry.QueueSizeOrDefault() != newCfg.Sentry.QueueSizeOrDefault() {
+		changes = append(changes, fmt.Sprintf("sentry.queue-size: %d -> %d", oldCfg.Sentry.QueueSizeOrDefault(), newCfg.Sentry.QueueSizeOrDefault()))
+	}
+	if !reflect.DeepEqual(oldCfg.Notifications.Webhooks, newCfg.Notifications.Webhooks) {
+		changes = append(changes, fmt.Sprintf("notifications.webhooks: updated (%d -> %d entries, redacted)", len(oldCfg.Notifications.Webhooks), len(newCfg.Notifications.Webhooks)))
+	}
+	if !reflect.DeepEqual(oldCfg.Notifications.Events, newCfg.Notifications.Events) {
+		changes = append(changes, "notifications.events: updated")
+	}
+	if oldCfg.Notifications.RateLimit != newCfg.Notifications.RateLimit {
+		changes = append(changes, fmt.Sprintf("notifications.rate-limit: %q -> %q", oldCfg.Notifications.RateLimit, newCfg.Notifications.RateLimit))
+	}
 	if oldCfg.ErrorLogsMaxFiles != newCfg.ErrorLogsMaxFiles {
 		changes = append(changes, fmt.Sprintf("error-logs-max-files: %d -> %d", oldCfg.ErrorLogsMaxFiles, newCfg.ErrorLogsMaxFiles))
 	}
@@ -51,6 +155,15 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.MaxRetryInterval != newCfg.MaxRetryInterval {
 		changes = append(changes, fmt.Sprintf("max-retry-interval: %d -> %d", oldCfg.MaxRetryInterval, newCfg.MaxRetryInterval))
 	}
+	if !reflect.DeepEqual(oldCfg.UpstreamHeaders, newCfg.UpstreamHeaders) {
+		changes = append(changes, fmt.Sprintf("upstream-headers: %d providers -> %d providers", len(oldCfg.UpstreamHeaders), len(newCfg.UpstreamHeaders)))
+	}
+	if oldCfg.ResponseCache != newCfg.ResponseCache {
+		changes = append(changes, fmt.Sprintf("response-cache: enabled %t -> %t, ttl %q -> %q, max-entries %d -> %d, max-bytes %d -> %d", oldCfg.ResponseCache.Enabled, newCfg.ResponseCache.Enabled, oldCfg.ResponseCache.TTL, newCfg.ResponseCache.TTL, oldCfg.ResponseCache.MaxEntries, newCfg.ResponseCache.MaxEntries, oldCfg.ResponseCache.MaxBytes, newCfg.ResponseCache.MaxBytes))
+	}
+	if oldCfg.TransientRetry != newCfg.TransientRetry {
+		changes = append(changes, fmt.Sprintf("transient-retry: %d retries with %q backoff -> %d retries with %q backoff", oldCfg.TransientRetry.MaxRetries, oldCfg.TransientRetry.Backoff, newCfg.TransientRetry.MaxRetries, newCfg.TransientRetry.Backoff))
+	}
 	if oldCfg.ProxyURL != newCfg.ProxyURL {
 		changes = append(changes, fmt.Sprintf("proxy-url: %s -> %s", formatProxyURL(oldCfg.ProxyURL), formatProxyURL(newCfg.ProxyURL)))
 	}
@@ -60,6 +173,12 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.ForceModelPrefix != newCfg.ForceModelPrefix {
 		changes = append(changes, fmt.Sprintf("force-model-prefix: %t -> %t", oldCfg.ForceModelPrefix, newCfg.ForceModelPrefix))
 	}
+	if oldCfg.NormalizedReasoningOutput() != newCfg.NormalizedReasoningOutput() {
+		changes = append(changes, fmt.Sprintf("reasoning-output: %s -> %s", oldCfg.NormalizedReasoningOutput(), newCfg.NormalizedReasoningOutput()))
+	}
+	if oldCfg.HTTPTransport != newCfg.HTTPTransport {
+		changes = append(changes, fmt.Sprintf("http-transport: %+v -> %+v", oldCfg.HTTPTransport, newCfg.HTTPTransport))
+	}
 	if oldCfg.NonStreamKeepAliveInterval != newCfg.NonStreamKeepAliveInterval {
 		changes = append(changes, fmt.Sprintf("nonstream-keepalive-interval: %d -> %d", oldCfg.NonStreamKeepAliveInterval, newCfg.NonStreamKeepAliveInterval))
 	}
@@ -75,6 +194,17 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.Routing.Strategy != newCfg.Routing.Strategy {
 		changes = append(changes, fmt.Sprintf("routing.strategy: %s -> %s", oldCfg.Routing.Strategy, newCfg.Routing.Strategy))
 	}
+	if !reflect.DeepEqual(oldCfg.Routing.DailyQuotas, newCfg.Routing.DailyQuotas) {
+		changes = append(changes, fmt.Sprintf("routing.daily-quotas: updated (%d -> %d entries)", len(oldCfg.Routing.DailyQuotas), len(newCfg.Routing.DailyQuotas)))
+	}
+
+	if !reflect.DeepEqual(oldCfg.UsagePersistence, newCfg.UsagePersistence) {
+		changes = append(changes, "usage-persistence: updated")
+	}
+
+	if !reflect.DeepEqual(oldCfg.MaxOutputTokens, newCfg.MaxOutputTokens) {
+		changes = append(changes, fmt.Sprintf("max-output-tokens: updated (%d -> %d rules)", len(oldCfg.MaxOutputTokens.Rules), len(newCfg.MaxOutputTokens.Rules)))
+	}
 
 	// API keys (redacted) and counts
 	if len(oldCfg.APIKeys) != len(newCfg.APIKeys) {
@@ -82,6 +212,12 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	} else if !reflect.DeepEqual(trimStrings(oldCfg.APIKeys), trimStrings(newCfg.APIKeys)) {
 		changes = append(changes, "api-keys: values updated (count unchanged, redacted)")
 	}
+	if !reflect.DeepEqual(oldCfg.APIKeySettings, newCfg.APIKeySettings) {
+		changes = append(changes, fmt.Sprintf("api-key-settings: updated (%d -> %d entries, redacted)", len(oldCfg.APIKeySettings), len(newCfg.APIKeySettings)))
+	}
+	if !reflect.DeepEqual(oldCfg.GlobalQuota, newCfg.GlobalQuota) {
+		changes = append(changes, "global-quota: updated")
+	}
 	if len(oldCfg.GeminiKey) != len(newCfg.GeminiKey) {
 		changes = append(changes, fmt.Sprintf("gemini-api-key count: %d -> %d", len(oldCfg.GeminiKey), len(newCfg.GeminiKey)))
 	} else {
@@ -244,6 +380,23 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.RemoteManagement.DisableControlPanel != newCfg.RemoteManagement.DisableControlPanel {
 		changes = append(changes, fmt.Sprintf("remote-management.disable-control-panel: %t -> %t", oldCfg.RemoteManagement.DisableControlPanel, newCfg.RemoteManagement.DisableControlPanel))
 	}
+	if !reflect.DeepEqual(oldCfg.Management.AllowedIPs, newCfg.Management.AllowedIPs) {
+		changes = append(changes, fmt.Sprintf("management.allowed-ips: %v -> %v", oldCfg.Management.AllowedIPs, newCfg.Management.AllowedIPs))
+	}
+	if !reflect.DeepEqual(oldCfg.Management.DeniedIPs, newCfg.Management.DeniedIPs) {
+		changes = append(changes, fmt.Sprintf("management.denied-ips: %v -> %v", oldCfg.Management.DeniedIPs, newCfg.Management.DeniedIPs))
+	}
+	if !reflect.DeepEqual(oldCfg.APIAccess, newCfg.APIAccess) {
+		changes = append(changes, fmt.Sprintf("api-access: allowed-ips %v denied-ips %v trusted-proxies %v -> allowed-ips %v denied-ips %v trusted-proxies %v",
+			oldCfg.APIAccess.AllowedIPs, oldCfg.APIAccess.DeniedIPs, oldCfg.APIAccess.TrustedProxies,
+			newCfg.APIAccess.AllowedIPs, newCfg.APIAccess.DeniedIPs, newCfg.APIAccess.TrustedProxies))
+	}
+	if !reflect.DeepEqual(oldCfg.Management.TrustedProxies, newCfg.Management.TrustedProxies) {
+		changes = append(changes, fmt.Sprintf("management.trusted-proxies: %v -> %v", oldCfg.Management.TrustedProxies, newCfg.Management.TrustedProxies))
+	}
+	if oldCfg.Management.Listen != newCfg.Management.Listen {
+		changes = append(changes, fmt.Sprintf("management.listen: %q -> %q (applies after restart)", oldCfg.Management.Listen, newCfg.Management.Listen))
+	}
 	oldPanelRepo := strings.TrimSpace(oldCfg.RemoteManagement.PanelGitHubRepository)
 	newPanelRepo := strings.TrimSpace(newCfg.RemoteManagement.PanelGitHubRepository)
 	if oldPanelRepo != newPanelRepo {
@@ -6,6 +6,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -13,7 +14,8 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
-	log "github.com/sirupsen/logrus"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 )
 
 func matchProvider(provider string, targets []string) (string, bool) {
@@ -33,11 +35,25 @@ func (w *Watcher) start(ctx context.Context) error {
 	}
 	log.Debugf("watching config file: %s", w.configPath)
 
+	w.authEventMu.Lock()
+	w.pollCtx = ctx
+	w.authEventMu.Unlock()
 	if errAddAuthDir := w.watcher.Add(w.authDir); errAddAuthDir != nil {
-		log.Errorf("failed to watch auth directory %s: %v", w.authDir, errAddAuthDir)
-		return errAddAuthDir
+		// Directories on filesystems without inotify support are polled instead.
+		if info, errStat := os.Stat(w.authDir); errStat != nil || !info.IsDir() {
+			log.Errorf("failed to watch auth directory %s: %v", w.authDir, errAddAuthDir)
+			return errAddAuthDir
+		}
+		log.Warnf("failed to watch auth directory %s: %v", w.authDir, errAddAuthDir)
+		w.pollAuthDir(w.authDir)
+	} else {
+		log.Debugf("watching auth directory: %s", w.authDir)
 	}
-	log.Debugf("watching auth directory: %s", w.authDir)
+	w.clientsMutex.Lock()
+	w.watchedAuthDirs = map[string]string{w.normalizeAuthPath(w.authDir): w.authDir}
+	cfg := w.config
+	w.clientsMutex.Unlock()
+	w.syncWatchedAuthDirs(cfg)
 
 	go w.processEvents(ctx)
 
@@ -54,11 +70,20 @@ func (w *Watcher) processEvents(ctx context.Context) {
 			if !ok {
 				return
 			}
+			if w.isAuthFileEvent(event) {
+				w.scheduleAuthEvent(event)
+				continue
+			}
 			w.handleEvent(event)
 		case errWatch, ok := <-w.watcher.Errors:
 			if !ok {
 				return
 			}
+			if errors.Is(errWatch, fsnotify.ErrEventOverflow) {
+				log.Warnf("file watcher dropped events; rescanning auth directories")
+				w.reloadClients(true, nil, false)
+				continue
+			}
 			log.Errorf("file watcher error: %v", errWatch)
 		}
 	}
@@ -69,10 +94,9 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 	configOps := fsnotify.Write | fsnotify.Create | fsnotify.Rename
 	normalizedName := w.normalizeAuthPath(event.Name)
 	normalizedConfigPath := w.normalizeAuthPath(w.configPath)
-	normalizedAuthDir := w.normalizeAuthPath(w.authDir)
 	isConfigEvent := normalizedName == normalizedConfigPath && event.Op&configOps != 0
 	authOps := fsnotify.Create | fsnotify.Write | fsnotify.Remove | fsnotify.Rename
-	isAuthJSON := strings.HasPrefix(normalizedName, normalizedAuthDir) && strings.HasSuffix(normalizedName, ".json") && event.Op&authOps != 0
+	isAuthJSON := w.inAuthDir(normalizedName) && strings.HasSuffix(normalizedName, ".json") && event.Op&authOps != 0
 	if !isConfigEvent && !isAuthJSON {
 		// Ignore unrelated files (e.g., cookie snapshots *.cookie) and other noise.
 		return
@@ -102,7 +126,7 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 				log.Debugf("auth file unchanged (hash match), skipping reload: %s", filepath.Base(event.Name))
 				return
 			}
-			log.Infof("auth file changed (%s): %s, processing incrementally", event.Op.String(), filepath.Base(event.Name))
+			log.Debugf("auth file changed (%s): %s, processing incrementally", event.Op.String(), filepath.Base(event.Name))
 			w.addOrUpdateClient(event.Name)
 			return
 		}
@@ -110,7 +134,7 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 			log.Debugf("ignoring remove for unknown auth file: %s", filepath.Base(event.Name))
 			return
 		}
-		log.Infof("auth file changed (%s): %s, processing incrementally", event.Op.String(), filepath.Base(event.Name))
+		log.Debugf("auth file changed (%s): %s, processing incrementally", event.Op.String(), filepath.Base(event.Name))
 		w.removeClient(event.Name)
 		return
 	}
@@ -119,11 +143,68 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 			log.Debugf("auth file unchanged (hash match), skipping reload: %s", filepath.Base(event.Name))
 			return
 		}
-		log.Infof("auth file changed (%s): %s, processing incrementally", event.Op.String(), filepath.Base(event.Name))
+		log.Debugf("auth file changed (%s): %s, processing incrementally", event.Op.String(), filepath.Base(event.Name))
 		w.addOrUpdateClient(event.Name)
 	}
 }
 
+// inAuthDir reports whether a normalized path lies in one of the watched auth directories.
+func (w *Watcher) inAuthDir(normalizedPath string) bool {
+	if strings.HasPrefix(normalizedPath, w.normalizeAuthPath(w.authDir)) {
+		return true
+	}
+	w.clientsMutex.RLock()
+	defer w.clientsMutex.RUnlock()
+	for dir := range w.watchedAuthDirs {
+		if strings.HasPrefix(normalizedPath, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// syncWatchedAuthDirs starts watching newly configured auth directories and stops watching
+// removed ones. A missing secondary directory is logged rather than treated as fatal.
+func (w *Watcher) syncWatchedAuthDirs(cfg *config.Config) {
+	dirs := w.authDirectories(cfg)
+	want := make(map[string]string, len(dirs))
+	for _, dir := range dirs {
+		want[w.normalizeAuthPath(dir)] = dir
+	}
+
+	w.clientsMutex.Lock()
+	defer w.clientsMutex.Unlock()
+	if w.watchedAuthDirs == nil {
+		// Not started yet; start() registers the directories.
+		return
+	}
+	primary := w.normalizeAuthPath(w.authDir)
+	for key, dir := range w.watchedAuthDirs {
+		if _, ok := want[key]; ok || key == primary {
+			continue
+		}
+		_ = w.watcher.Remove(dir)
+		w.unpollAuthDir(dir)
+		delete(w.watchedAuthDirs, key)
+		log.Infof("stopped watching auth directory: %s", dir)
+	}
+	for key, dir := range want {
+		if _, ok := w.watchedAuthDirs[key]; ok {
+			continue
+		}
+		if errAdd := w.watcher.Add(dir); errAdd != nil {
+			log.Warnf("failed to watch auth directory %s: %v", dir, errAdd)
+			if info, errStat := os.Stat(dir); errStat == nil && info.IsDir() {
+				w.watchedAuthDirs[key] = dir
+				w.pollAuthDir(dir)
+			}
+			continue
+		}
+		w.watchedAuthDirs[key] = dir
+		log.Infof("watching auth directory: %s", dir)
+	}
+}
+
 func (w *Watcher) authFileUnchanged(path string) (bool, error) {
 	data, errRead := os.ReadFile(path)
 	if errRead != nil {
@@ -63,6 +63,9 @@ func (s *ConfigSynthesizer) synthesizeGeminiKeys(ctx *SynthesisContext) []*corea
 		if entry.Priority != 0 {
 			attrs["priority"] = strconv.Itoa(entry.Priority)
 		}
+		if entry.Weight > 0 {
+			attrs["weight"] = strconv.Itoa(entry.Weight)
+		}
 		if base != "" {
 			attrs["base_url"] = base
 		}
@@ -110,6 +113,9 @@ func (s *ConfigSynthesizer) synthesizeClaudeKeys(ctx *SynthesisContext) []*corea
 		if ck.Priority != 0 {
 			attrs["priority"] = strconv.Itoa(ck.Priority)
 		}
+		if ck.Weight > 0 {
+			attrs["weight"] = strconv.Itoa(ck.Weight)
+		}
 		if base != "" {
 			attrs["base_url"] = base
 		}
@@ -157,6 +163,9 @@ func (s *ConfigSynthesizer) synthesizeCodexKeys(ctx *SynthesisContext) []*coreau
 		if ck.Priority != 0 {
 			attrs["priority"] = strconv.Itoa(ck.Priority)
 		}
+		if ck.Weight > 0 {
+			attrs["weight"] = strconv.Itoa(ck.Weight)
+		}
 		if ck.BaseURL != "" {
 			attrs["base_url"] = ck.BaseURL
 		}
@@ -215,6 +224,9 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 			if compat.Priority != 0 {
 				attrs["priority"] = strconv.Itoa(compat.Priority)
 			}
+			if entry.Weight > 0 {
+				attrs["weight"] = strconv.Itoa(entry.Weight)
+			}
 			if key != "" {
 				attrs["api_key"] = key
 			}
@@ -294,6 +306,9 @@ func (s *ConfigSynthesizer) synthesizeVertexCompat(ctx *SynthesisContext) []*cor
 		if compat.Priority != 0 {
 			attrs["priority"] = strconv.Itoa(compat.Priority)
 		}
+		if compat.Weight > 0 {
+			attrs["weight"] = strconv.Itoa(compat.Weight)
+		}
 		if key != "" {
 			attrs["api_key"] = key
 		}
@@ -12,6 +12,8 @@ type SynthesisContext struct {
 	Config *config.Config
 	// AuthDir is the directory containing auth files
 	AuthDir string
+	// AuthDirs lists every directory to scan in order; when empty only AuthDir is scanned
+	AuthDirs []string
 	// Now is the current time for timestamps
 	Now time.Time
 	// IDGenerator generates stable IDs for auth entries
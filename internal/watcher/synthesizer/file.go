@@ -2,12 +2,19 @@ package synthesizer
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/filecrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/geminicli"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 )
@@ -21,22 +28,45 @@ func NewFileSynthesizer() *FileSynthesizer {
 	return &FileSynthesizer{}
 }
 
-// Synthesize generates Auth entries from auth files in the auth directory.
+// Synthesize generates Auth entries from auth files in every configured auth directory.
+// When the same account appears in more than one directory only one copy is kept,
+// chosen by the auth-dir-duplicates policy.
 func (s *FileSynthesizer) Synthesize(ctx *SynthesisContext) ([]*coreauth.Auth, error) {
 	out := make([]*coreauth.Auth, 0, 16)
-	if ctx == nil || ctx.AuthDir == "" {
+	if ctx == nil {
 		return out, nil
 	}
+	dirs := ctx.AuthDirs
+	if len(dirs) == 0 {
+		if ctx.AuthDir == "" {
+			return out, nil
+		}
+		dirs = []string{ctx.AuthDir}
+	}
 
-	entries, err := os.ReadDir(ctx.AuthDir)
+	var groups [][]*coreauth.Auth
+	for _, dir := range dirs {
+		groups = append(groups, synthesizeAuthDir(ctx, dir)...)
+	}
+	for _, group := range dedupeAuthGroups(groups, ctx.Config.AuthDirDuplicatePolicy()) {
+		out = append(out, group...)
+	}
+	return out, nil
+}
+
+// synthesizeAuthDir returns one group per auth file in dir: the file's auth followed by any
+// virtual auths derived from it.
+func synthesizeAuthDir(ctx *SynthesisContext, dir string) [][]*coreauth.Auth {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		// Not an error if directory doesn't exist
-		return out, nil
+		return nil
 	}
 
 	now := ctx.Now
 	cfg := ctx.Config
 
+	var groups [][]*coreauth.Auth
 	for _, e := range entries {
 		if e.IsDir() {
 			continue
@@ -45,9 +75,18 @@ func (s *FileSynthesizer) Synthesize(ctx *SynthesisContext) ([]*coreauth.Auth, e
 		if !strings.HasSuffix(strings.ToLower(name), ".json") {
 			continue
 		}
-		full := filepath.Join(ctx.AuthDir, name)
-		data, errRead := os.ReadFile(full)
-		if errRead != nil || len(data) == 0 {
+		full := filepath.Join(dir, name)
+		data, errRead := filecrypt.ReadFile(full)
+		if errRead != nil {
+			if errors.Is(errRead, filecrypt.ErrKeyUnavailable) || errors.Is(errRead, filecrypt.ErrDecrypt) {
+				if _, seen := warnedUnreadable.LoadOrStore(full, struct{}{}); !seen {
+					log.Warnf("skipping auth file %s: %v", full, errRead)
+				}
+			}
+			continue
+		}
+		warnedUnreadable.Delete(full)
+		if len(data) == 0 {
 			continue
 		}
 		var metadata map[string]any
@@ -66,15 +105,14 @@ func (s *FileSynthesizer) Synthesize(ctx *SynthesisContext) ([]*coreauth.Auth, e
 		if email, _ := metadata["email"].(string); email != "" {
 			label = email
 		}
-		// Use relative path under authDir as ID to stay consistent with the file-based token store
+		// Use the relative path under the writable auth dir as ID to stay consistent with the
+		// file-based token store; files in the other auth dirs keep their full path so the ID
+		// names the directory too.
 		id := full
-		if rel, errRel := filepath.Rel(ctx.AuthDir, full); errRel == nil && rel != "" {
-			id = rel
-		}
-
-		proxyURL := ""
-		if p, ok := metadata["proxy_url"].(string); ok {
-			proxyURL = p
+		if dir == ctx.AuthDir {
+			if rel, errRel := filepath.Rel(dir, full); errRel == nil && rel != "" {
+				id = rel
+			}
 		}
 
 		prefix := ""
@@ -100,28 +138,101 @@ func (s *FileSynthesizer) Synthesize(ctx *SynthesisContext) ([]*coreauth.Auth, e
 			Status:   status,
 			Disabled: disabled,
 			Attributes: map[string]string{
-				"source": full,
-				"path":   full,
+				"source":   full,
+				"path":     full,
+				"auth_dir": dir,
 			},
-			ProxyURL:  proxyURL,
 			Metadata:  metadata,
 			CreatedAt: now,
 			UpdatedAt: now,
 		}
+		if weight, ok := metadata["weight"].(float64); ok && weight >= 1 {
+			a.Attributes["weight"] = strconv.Itoa(int(weight))
+		}
+		if priority, ok := metadata["priority"].(float64); ok && priority == math.Trunc(priority) {
+			a.Attributes["priority"] = strconv.Itoa(int(priority))
+		}
 		ApplyAuthExcludedModelsMeta(a, cfg, nil, "oauth")
+		errProxy := a.ApplyMetadataProxyURL()
+		if errProxy != nil {
+			log.Errorf("auth file %s: account disabled until proxy_url is fixed: %v", full, errProxy)
+		}
 		if provider == "gemini-cli" {
 			if virtuals := SynthesizeGeminiVirtualAuths(a, metadata, now); len(virtuals) > 0 {
 				for _, v := range virtuals {
 					ApplyAuthExcludedModelsMeta(v, cfg, nil, "oauth")
+					_ = v.ApplyMetadataProxyURL()
 				}
-				out = append(out, a)
-				out = append(out, virtuals...)
+				groups = append(groups, append([]*coreauth.Auth{a}, virtuals...))
 				continue
 			}
 		}
-		out = append(out, a)
+		groups = append(groups, []*coreauth.Auth{a})
 	}
-	return out, nil
+	return groups
+}
+
+// warnedDuplicates remembers reported duplicate pairs so repeated rescans stay quiet.
+var warnedDuplicates sync.Map
+
+// warnedUnreadable remembers encrypted files already reported as undecryptable.
+var warnedUnreadable sync.Map
+
+// dedupeAuthGroups drops auth files that describe an account already loaded from another
+// directory, or that would reuse its ID. Group order is preserved.
+func dedupeAuthGroups(groups [][]*coreauth.Auth, policy string) [][]*coreauth.Auth {
+	if len(groups) < 2 {
+		return groups
+	}
+	keepLast := policy != config.AuthDirDuplicatesFirst
+	kept := make([]bool, len(groups))
+	byIdentity := make(map[string]int, len(groups))
+	byID := make(map[string]int, len(groups))
+	for n := range groups {
+		i := n
+		if keepLast {
+			i = len(groups) - 1 - n
+		}
+		primary := groups[i][0]
+		identity := authFileIdentity(primary)
+		winner, dup := byIdentity[identity]
+		if !dup {
+			winner, dup = byID[primary.ID]
+		}
+		if dup {
+			warnDuplicateAuth(primary, groups[winner][0])
+			continue
+		}
+		kept[i] = true
+		byIdentity[identity] = i
+		byID[primary.ID] = i
+	}
+	out := make([][]*coreauth.Auth, 0, len(groups))
+	for i, group := range groups {
+		if kept[i] {
+			out = append(out, group)
+		}
+	}
+	return out
+}
+
+// authFileIdentity identifies the account behind an auth file independently of its location.
+func authFileIdentity(a *coreauth.Auth) string {
+	email, _ := a.Metadata["email"].(string)
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return a.Provider + "|" + a.ID
+	}
+	project, _ := a.Metadata["project_id"].(string)
+	return a.Provider + "|" + email + "|" + strings.TrimSpace(project)
+}
+
+func warnDuplicateAuth(dropped, winner *coreauth.Auth) {
+	droppedPath, winnerPath := dropped.Attributes["path"], winner.Attributes["path"]
+	if _, seen := warnedDuplicates.LoadOrStore(droppedPath+"|"+winnerPath, struct{}{}); seen {
+		return
+	}
+	log.Warnf("auth file %s duplicates account %s from %s; ignoring it", droppedPath, winner.Label, winnerPath)
 }
 
 // SynthesizeGeminiVirtualAuths creates virtual Auth entries for multi-project Gemini credentials.
@@ -167,6 +278,14 @@ func SynthesizeGeminiVirtualAuths(primary *coreauth.Auth, metadata map[string]an
 		if authPath != "" {
 			attrs["path"] = authPath
 		}
+		if dir := primary.Attributes["auth_dir"]; dir != "" {
+			attrs["auth_dir"] = dir
+		}
+		for _, key := range []string{"weight", "priority"} {
+			if value := primary.Attributes[key]; value != "" {
+				attrs[key] = value
+			}
+		}
 		metadataCopy := map[string]any{
 			"email":             email,
 			"project_id":        projectID,
@@ -121,6 +121,44 @@ func TestFileSynthesizer_Synthesize_ValidAuthFile(t *testing.T) {
 	}
 }
 
+func TestFileSynthesizer_Synthesize_WeightAndPriority(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]map[string]any{
+		"paid.json":  {"type": "claude", "email": "paid@example.com", "weight": 3, "priority": 10},
+		"free.json":  {"type": "claude", "email": "free@example.com", "priority": -1},
+		"plain.json": {"type": "claude", "email": "plain@example.com", "priority": 1.5},
+	}
+	for name, authData := range files {
+		data, _ := json.Marshal(authData)
+		if err := os.WriteFile(filepath.Join(tempDir, name), data, 0644); err != nil {
+			t.Fatalf("failed to write auth file: %v", err)
+		}
+	}
+
+	auths, err := NewFileSynthesizer().Synthesize(&SynthesisContext{
+		Config:      &config.Config{},
+		AuthDir:     tempDir,
+		Now:         time.Now(),
+		IDGenerator: NewStableIDGenerator(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := make(map[string]map[string]string)
+	for _, a := range auths {
+		got[a.Label] = a.Attributes
+	}
+	if attrs := got["paid@example.com"]; attrs["weight"] != "3" || attrs["priority"] != "10" {
+		t.Errorf("paid account attributes = %v, want weight 3 and priority 10", attrs)
+	}
+	if attrs := got["free@example.com"]; attrs["priority"] != "-1" {
+		t.Errorf("free account priority = %q, want -1", attrs["priority"])
+	}
+	if _, ok := got["plain@example.com"]["priority"]; ok {
+		t.Errorf("a fractional priority should be ignored")
+	}
+}
+
 func TestFileSynthesizer_Synthesize_GeminiProviderMapping(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -252,6 +290,57 @@ func TestFileSynthesizer_Synthesize_RelativeID(t *testing.T) {
 	}
 }
 
+func TestFileSynthesizer_Synthesize_MultipleAuthDirs(t *testing.T) {
+	personal := t.TempDir()
+	work := t.TempDir()
+	write := func(dir, name string, data map[string]any) {
+		raw, _ := json.Marshal(data)
+		if err := os.WriteFile(filepath.Join(dir, name), raw, 0644); err != nil {
+			t.Fatalf("failed to write auth file: %v", err)
+		}
+	}
+	write(personal, "me.json", map[string]any{"type": "claude", "email": "me@example.com"})
+	write(personal, "shared.json", map[string]any{"type": "codex", "email": "team@example.com"})
+	write(work, "shared-copy.json", map[string]any{"type": "codex", "email": "team@example.com"})
+
+	for _, tc := range []struct {
+		policy  string
+		wantDir string
+	}{
+		{policy: "", wantDir: work},
+		{policy: config.AuthDirDuplicatesFirst, wantDir: personal},
+	} {
+		ctx := &SynthesisContext{
+			Config:      &config.Config{AuthDirDuplicates: tc.policy},
+			AuthDir:     personal,
+			AuthDirs:    []string{personal, work},
+			Now:         time.Now(),
+			IDGenerator: NewStableIDGenerator(),
+		}
+		auths, err := NewFileSynthesizer().Synthesize(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(auths) != 2 {
+			t.Fatalf("policy %q: expected 2 auths after dedupe, got %d", tc.policy, len(auths))
+		}
+		for _, a := range auths {
+			if a.Attributes["auth_dir"] == "" {
+				t.Fatalf("auth %s has no auth_dir label", a.ID)
+			}
+			if wantID := filepath.Join(work, "shared-copy.json"); a.Attributes["auth_dir"] == work && a.ID != wantID {
+				t.Fatalf("auth from the second dir has ID %q, want %q", a.ID, wantID)
+			}
+			if a.Attributes["auth_dir"] == personal && filepath.IsAbs(a.ID) {
+				t.Fatalf("auth from the writable dir has ID %q, want a relative one", a.ID)
+			}
+			if a.Provider == "codex" && a.Attributes["auth_dir"] != tc.wantDir {
+				t.Fatalf("policy %q: kept codex auth from %s, want %s", tc.policy, a.Attributes["auth_dir"], tc.wantDir)
+			}
+		}
+	}
+}
+
 func TestFileSynthesizer_Synthesize_PrefixValidation(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -339,8 +428,10 @@ func TestSynthesizeGeminiVirtualAuths_MultiProject(t *testing.T) {
 		Prefix:   "test-prefix",
 		ProxyURL: "http://proxy.local",
 		Attributes: map[string]string{
-			"source": "test-source",
-			"path":   "/path/to/auth",
+			"source":   "test-source",
+			"path":     "/path/to/auth",
+			"weight":   "2",
+			"priority": "5",
 		},
 	}
 	metadata := map[string]any{
@@ -401,6 +492,9 @@ func TestSynthesizeGeminiVirtualAuths_MultiProject(t *testing.T) {
 		if v.Attributes["gemini_virtual_project"] != projectIDs[i] {
 			t.Errorf("expected gemini_virtual_project=%s, got %s", projectIDs[i], v.Attributes["gemini_virtual_project"])
 		}
+		if v.Attributes["weight"] != "2" || v.Attributes["priority"] != "5" {
+			t.Errorf("expected weight and priority inherited from the primary, got %v", v.Attributes)
+		}
 		if !strings.Contains(v.Label, "["+projectIDs[i]+"]") {
 			t.Errorf("expected label to contain [%s], got %s", projectIDs[i], v.Label)
 		}
@@ -10,11 +10,12 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"gopkg.in/yaml.v3"
 
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
-	log "github.com/sirupsen/logrus"
 )
 
 // storePersister captures persistence-capable token store methods used by the watcher.
@@ -34,6 +35,7 @@ type Watcher struct {
 	config            *config.Config
 	clientsMutex      sync.RWMutex
 	configReloadMu    sync.Mutex
+	applyMu           sync.Mutex
 	configReloadTimer *time.Timer
 	reloadCallback    func(*config.Config)
 	watcher           *fsnotify.Watcher
@@ -50,7 +52,16 @@ type Watcher struct {
 	dispatchCancel    context.CancelFunc
 	storePersister    storePersister
 	mirroredAuthDir   string
+	watchedAuthDirs   map[string]string
 	oldConfigYaml     []byte
+	authEventMu       sync.Mutex
+	authEventTimers   map[string]*time.Timer
+	pendingAuthOps    map[string]fsnotify.Op
+	parseRetries      map[string]int
+	parseRetryTimers  map[string]*time.Timer
+	polledAuthDirs    map[string]string
+	pollCtx           context.Context
+	polling           bool
 }
 
 // AuthUpdateAction represents the type of change detected in auth sources.
@@ -115,6 +126,7 @@ func (w *Watcher) Start(ctx context.Context) error {
 func (w *Watcher) Stop() error {
 	w.stopDispatch()
 	w.stopConfigReloadTimer()
+	w.stopAuthEventTimers()
 	return w.watcher.Close()
 }
 
@@ -143,5 +155,39 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 	w.clientsMutex.RLock()
 	cfg := w.config
 	w.clientsMutex.RUnlock()
-	return snapshotCoreAuths(cfg, w.authDir)
+	return snapshotCoreAuths(cfg, w.authDir, w.authDirectories(cfg))
+}
+
+// authDirectories returns the resolved auth directories to scan, in config order.
+// The directory the watcher was created with is always included; mirrored stores
+// only ever use their own directory.
+func (w *Watcher) authDirectories(cfg *config.Config) []string {
+	if w.mirroredAuthDir != "" || cfg == nil {
+		return []string{w.authDir}
+	}
+	dirs := make([]string, 0, len(cfg.AuthDirs)+1)
+	seen := make(map[string]struct{}, len(cfg.AuthDirs)+1)
+	add := func(dir string) {
+		if dir == cfg.AuthDir {
+			dir = w.authDir
+		} else if resolved, errResolve := util.ResolveAuthDir(dir); errResolve == nil {
+			dir = resolved
+		}
+		key := w.normalizeAuthPath(dir)
+		if key == "" {
+			return
+		}
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		dirs = append(dirs, dir)
+	}
+	for _, dir := range cfg.AuthDirectories() {
+		add(dir)
+	}
+	if _, ok := seen[w.normalizeAuthPath(w.authDir)]; !ok {
+		dirs = append([]string{w.authDir}, dirs...)
+	}
+	return dirs
 }
@@ -1488,3 +1488,94 @@ func TestScheduleProcessEventsStopsOnContextDone(t *testing.T) {
 func hexString(data []byte) string {
 	return strings.ToLower(fmt.Sprintf("%x", data))
 }
+
+func TestScheduleAuthEventCoalescesBursts(t *testing.T) {
+	authDir := t.TempDir()
+	authFile := filepath.Join(authDir, "burst.json")
+	if err := os.WriteFile(authFile, []byte(`{"type":"demo"}`), 0o644); err != nil {
+		t.Fatalf("failed to write auth file: %v", err)
+	}
+
+	var reloads int32
+	w := &Watcher{
+		authDir:        authDir,
+		lastAuthHashes: make(map[string]string),
+		reloadCallback: func(*config.Config) { atomic.AddInt32(&reloads, 1) },
+	}
+	w.SetConfig(&config.Config{AuthDir: authDir})
+	defer w.stopAuthEventTimers()
+
+	for _, op := range []fsnotify.Op{fsnotify.Create, fsnotify.Write, fsnotify.Chmod, fsnotify.Write} {
+		w.scheduleAuthEvent(fsnotify.Event{Name: authFile, Op: op})
+	}
+	time.Sleep(authEventDebounce + 200*time.Millisecond)
+	if got := atomic.LoadInt32(&reloads); got != 1 {
+		t.Fatalf("expected one reload for a burst of events, got %d", got)
+	}
+}
+
+func TestAddOrUpdateClientRetriesHalfWrittenFile(t *testing.T) {
+	authDir := t.TempDir()
+	authFile := filepath.Join(authDir, "partial.json")
+	if err := os.WriteFile(authFile, []byte(`{"type":"de`), 0o644); err != nil {
+		t.Fatalf("failed to write auth file: %v", err)
+	}
+
+	var reloads int32
+	w := &Watcher{
+		authDir:        authDir,
+		lastAuthHashes: make(map[string]string),
+		reloadCallback: func(*config.Config) { atomic.AddInt32(&reloads, 1) },
+	}
+	w.SetConfig(&config.Config{AuthDir: authDir})
+	defer w.stopAuthEventTimers()
+
+	w.addOrUpdateClient(authFile)
+	if atomic.LoadInt32(&reloads) != 0 || len(w.lastAuthHashes) != 0 {
+		t.Fatal("a half-written file must not be loaded")
+	}
+	if err := os.WriteFile(authFile, []byte(`{"type":"demo"}`), 0o644); err != nil {
+		t.Fatalf("failed to finish auth file: %v", err)
+	}
+	time.Sleep(authParseRetryDelay + 200*time.Millisecond)
+	if got := atomic.LoadInt32(&reloads); got != 1 {
+		t.Fatalf("expected the retry to load the completed file, got %d reloads", got)
+	}
+}
+
+func TestScanPolledAuthDirsDetectsChanges(t *testing.T) {
+	authDir := t.TempDir()
+	authFile := filepath.Join(authDir, "polled.json")
+	if err := os.WriteFile(authFile, []byte(`{"type":"demo"}`), 0o644); err != nil {
+		t.Fatalf("failed to write auth file: %v", err)
+	}
+
+	var reloads int32
+	w := &Watcher{
+		authDir:        authDir,
+		lastAuthHashes: make(map[string]string),
+		reloadCallback: func(*config.Config) { atomic.AddInt32(&reloads, 1) },
+		polledAuthDirs: map[string]string{filepath.Clean(authDir): authDir},
+	}
+	w.SetConfig(&config.Config{AuthDir: authDir})
+	defer w.stopAuthEventTimers()
+
+	seen := make(map[string]authFileStat)
+	w.scanPolledAuthDirs(seen)
+	time.Sleep(authEventDebounce + 200*time.Millisecond)
+	if got := atomic.LoadInt32(&reloads); got != 1 {
+		t.Fatalf("expected poll to load the new file, got %d reloads", got)
+	}
+
+	if err := os.Remove(authFile); err != nil {
+		t.Fatalf("failed to remove auth file: %v", err)
+	}
+	w.scanPolledAuthDirs(seen)
+	time.Sleep(authEventDebounce + replaceCheckDelay + 200*time.Millisecond)
+	if got := atomic.LoadInt32(&reloads); got != 2 {
+		t.Fatalf("expected poll to retire the deleted file, got %d reloads", got)
+	}
+	if w.isKnownAuthFile(authFile) {
+		t.Fatal("deleted auth file should no longer be tracked")
+	}
+}
@@ -22,6 +22,10 @@ type Result struct {
 	Metadata  map[string]string
 }
 
+// MetadataUsageLabelPrefix marks Result.Metadata entries that are usage labels: an entry
+// "usage-label.team" labels the request's usage with team, like a usage-labels header.
+const MetadataUsageLabelPrefix = "usage-label."
+
 // ProviderFactory builds a provider from configuration data.
 type ProviderFactory func(cfg *config.AccessProvider, root *config.SDKConfig) (Provider, error)
 
@@ -74,7 +78,7 @@ func BuildProviders(root *config.SDKConfig) ([]Provider, error) {
 		}
 		providers = append(providers, provider)
 	}
-	if len(providers) == 0 {
+	if config.KeepsInlineAPIKeys(root.Access.Providers) {
 		if inline := config.MakeInlineAPIKeyProvider(root.APIKeys); inline != nil {
 			provider, err := BuildProvider(inline, root)
 			if err != nil {
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+// CountTokensWithFallback counts the input tokens of a request in the handler's format. The
+// count comes from the routed provider's native count API when it has one; when the
+// upstream is unavailable or returns no count, a local tokenizer estimate is used instead.
+// The response is always {"input_tokens":N,"estimated":bool}. Invalid requests are still
+// reported as errors rather than estimated.
+func (h *BaseAPIHandler) CountTokensWithFallback(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	resp, errMsg := h.ExecuteCountWithAuthManager(ctx, handlerType, modelName, rawJSON, alt)
+	if errMsg == nil {
+		if count := gjson.GetBytes(resp, "input_tokens"); count.Exists() {
+			if !gjson.GetBytes(resp, "estimated").Exists() {
+				resp, _ = sjson.SetBytes(resp, "estimated", false)
+			}
+			return resp, nil
+		}
+	} else if !countFallbackAllowed(errMsg.StatusCode) {
+		return nil, errMsg
+	}
+
+	count := estimateInputTokens(handlerType, modelName, rawJSON)
+	usage.PublishRecord(ctx, usage.Record{
+		Model:       modelName,
+		Labels:      executor.UsageLabelsFromContext(ctx),
+		RequestedAt: time.Now(),
+		Estimated:   true,
+		RequestType: usage.RequestTypeCountTokens,
+		Detail:      usage.Detail{InputTokens: count, TotalTokens: count},
+	})
+	return []byte(fmt.Sprintf(`{"input_tokens":%d,"estimated":true}`, count)), nil
+}
+
+// countFallbackAllowed reports whether a failed upstream count should be replaced by an
+// estimate: the upstream was unreachable, rate limited, or failed on its side.
+func countFallbackAllowed(status int) bool {
+	return status == 0 || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// estimateInputTokens converts the request to chat completions and counts it locally.
+func estimateInputTokens(handlerType, modelName string, rawJSON []byte) int64 {
+	request := rawJSON
+	if handlerType != "openai" {
+		request = sdktranslator.TranslateRequest(sdktranslator.FromString(handlerType), sdktranslator.FromString("openai"), modelName, rawJSON, false)
+	}
+	count, _ := executor.EstimateOpenAIChatUsage(modelName, request, "")
+	return count
+}
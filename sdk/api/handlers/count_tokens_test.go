@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestCountTokensWithFallbackEstimatesWhenUpstreamCannotCount(t *testing.T) {
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(&failOnceStreamExecutor{})
+	auth := &coreauth.Auth{ID: "count-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "count-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager)
+	raw := []byte(`{"model":"count-model","messages":[{"role":"user","content":"How many tokens is this sentence?"}]}`)
+	resp, errMsg := handler.CountTokensWithFallback(context.Background(), "openai", "count-model", raw, "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if gjson.GetBytes(resp, "input_tokens").Int() <= 0 || !gjson.GetBytes(resp, "estimated").Bool() {
+		t.Fatalf("expected a positive local estimate, got %s", resp)
+	}
+}
@@ -6,22 +6,30 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/errorreport"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/multimodal"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	geminicommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
 	"golang.org/x/net/context"
 )
 
@@ -52,6 +60,17 @@ const (
 	defaultStreamingBootstrapRetries = 0
 )
 
+// StaleModelsHeader is set to "true" on model list responses while some upstream model list
+// is served from cache because refreshing it failed.
+const StaleModelsHeader = "X-CPA-Models-Stale"
+
+// SetModelListHeaders flags a model list response that includes stale upstream data.
+func SetModelListHeaders(c *gin.Context) {
+	if registry.UpstreamModelCache().Stale() {
+		c.Header(StaleModelsHeader, "true")
+	}
+}
+
 // BuildErrorResponseBody builds an OpenAI-compatible JSON error response body.
 // If errText is already valid JSON, it is returned as-is to preserve upstream error payloads.
 func BuildErrorResponseBody(status int, errText string) []byte {
@@ -155,6 +174,16 @@ func requestExecutionMetadata(ctx context.Context) map[string]any {
 	return map[string]any{idempotencyKeyMetadataKey: key}
 }
 
+// setRequestModel tags the request with the model it asked for, for the request log line.
+func setRequestModel(ctx context.Context, modelName string) {
+	if ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok {
+		logging.SetGinRequestModel(ginCtx, modelName)
+	}
+}
+
 func mergeMetadata(base, overlay map[string]any) map[string]any {
 	if len(base) == 0 && len(overlay) == 0 {
 		return nil
@@ -255,6 +284,7 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 			parentCtx = logging.WithRequestID(parentCtx, requestID)
 		}
 	}
+	parentCtx = tracing.Inherit(parentCtx, requestCtx)
 	newCtx, cancel := context.WithCancel(parentCtx)
 	if requestCtx != nil && requestCtx != parentCtx {
 		go func() {
@@ -385,7 +415,12 @@ func appendAPIResponse(c *gin.Context, data []byte) {
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
+	setRequestModel(ctx, modelName)
+	providers, normalizedModel, errMsg := h.requestProviders(ctx, modelName)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	rawJSON, errMsg = h.preparePayload(ctx, handlerType, providers, rawJSON)
 	if errMsg != nil {
 		return nil, errMsg
 	}
@@ -416,15 +451,21 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 				addon = hdr.Clone()
 			}
 		}
+		reportUpstreamFailure(ctx, providers, normalizedModel, status, err)
 		return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 	}
-	return cloneBytes(resp.Payload), nil
+	return newReasoningFilter(h.Cfg, handlerType).response(cloneBytes(resp.Payload)), nil
 }
 
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
+	setRequestModel(ctx, modelName)
+	providers, normalizedModel, errMsg := h.requestProviders(ctx, modelName)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	rawJSON, errMsg = h.preparePayload(ctx, handlerType, providers, rawJSON)
 	if errMsg != nil {
 		return nil, errMsg
 	}
@@ -455,6 +496,7 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 				addon = hdr.Clone()
 			}
 		}
+		reportUpstreamFailure(ctx, providers, normalizedModel, status, err)
 		return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 	}
 	return cloneBytes(resp.Payload), nil
@@ -463,7 +505,11 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 // ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
-	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
+	setRequestModel(ctx, modelName)
+	providers, normalizedModel, errMsg := h.requestProviders(ctx, modelName)
+	if errMsg == nil {
+		rawJSON, errMsg = h.preparePayload(ctx, handlerType, providers, rawJSON)
+	}
 	if errMsg != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		errChan <- errMsg
@@ -498,6 +544,7 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 				addon = hdr.Clone()
 			}
 		}
+		reportUpstreamFailure(ctx, providers, normalizedModel, status, err)
 		errChan <- &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 		close(errChan)
 		return nil, errChan
@@ -510,6 +557,7 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		sentPayload := false
 		bootstrapRetries := 0
 		maxBootstrapRetries := StreamingBootstrapRetries(h.Cfg)
+		reasoning := newReasoningFilter(h.Cfg, handlerType)
 
 		sendErr := func(msg *interfaces.ErrorMessage) bool {
 			if ctx == nil {
@@ -578,6 +626,7 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 							retryChunks, retryErr := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
 							if retryErr == nil {
 								chunks = retryChunks
+								reasoning = newReasoningFilter(h.Cfg, handlerType)
 								continue outer
 							}
 							streamErr = retryErr
@@ -596,12 +645,13 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 							addon = hdr.Clone()
 						}
 					}
+					reportUpstreamFailure(ctx, providers, normalizedModel, status, streamErr)
 					_ = sendErr(&interfaces.ErrorMessage{StatusCode: status, Error: streamErr, Addon: addon})
 					return
 				}
-				if len(chunk.Payload) > 0 {
+				if payload := reasoning.chunk(chunk.Payload); len(payload) > 0 {
 					sentPayload = true
-					if okSendData := sendData(cloneBytes(chunk.Payload)); !okSendData {
+					if okSendData := sendData(cloneBytes(payload)); !okSendData {
 						return
 					}
 				}
@@ -611,6 +661,15 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	return dataChan, errChan
 }
 
+// reportUpstreamFailure sends a server-side failure that is left after every retry to error
+// reporting. Client disconnects and 4xx answers are not reported.
+func reportUpstreamFailure(ctx context.Context, providers []string, model string, status int, err error) {
+	if !errorreport.Enabled() || status < http.StatusInternalServerError || errors.Is(err, context.Canceled) {
+		return
+	}
+	errorreport.CaptureUpstreamFailure(ctx, strings.Join(providers, ","), model, status, err)
+}
+
 func statusFromError(err error) int {
 	if err == nil {
 		return 0
@@ -623,6 +682,40 @@ func statusFromError(err error) int {
 	return 0
 }
 
+// MaxRequestBodyBytes is the largest request body middleware reads before a handler runs, as
+// the key scope check does to find the requested model; larger bodies get 413.
+const MaxRequestBodyBytes = 32 << 20
+
+// AllowedProvidersContextKey is the gin context key under which the auth middleware stores
+// the providers, as a []string, the client's key may be dispatched to. Requests without it
+// may use every provider serving the model.
+const AllowedProvidersContextKey = "allowedProviders"
+
+// requestProviders resolves the model like getRequestDetails and narrows its providers to
+// those the client's key may use, answering 403 when none is left.
+func (h *BaseAPIHandler) requestProviders(ctx context.Context, modelName string) ([]string, string, *interfaces.ErrorMessage) {
+	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
+	if errMsg != nil || ctx == nil {
+		return providers, normalizedModel, errMsg
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return providers, normalizedModel, nil
+	}
+	allowed, ok := ginCtx.Get(AllowedProvidersContextKey)
+	if !ok {
+		return providers, normalizedModel, nil
+	}
+	allowedList, _ := allowed.([]string)
+	scoped := slices.DeleteFunc(slices.Clone(providers), func(provider string) bool {
+		return !slices.ContainsFunc(allowedList, func(name string) bool { return strings.EqualFold(name, provider) })
+	})
+	if len(scoped) == 0 {
+		return nil, "", &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: fmt.Errorf("this API key is not allowed to use the providers serving model %s", modelName)}
+	}
+	return scoped, normalizedModel, nil
+}
+
 func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string, normalizedModel string, err *interfaces.ErrorMessage) {
 	resolvedModelName := modelName
 	initialSuffix := thinking.ParseSuffix(modelName)
@@ -659,6 +752,57 @@ func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string
 	return providers, resolvedModelName, nil
 }
 
+// preparePayload rejects requests the candidate providers cannot honour and prepares
+// image inputs before translation.
+func (h *BaseAPIHandler) preparePayload(ctx context.Context, handlerType string, providers []string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	if errMsg := checkResponseFormat(handlerType, providers, rawJSON); errMsg != nil {
+		return nil, errMsg
+	}
+	return h.prepareImages(ctx, handlerType, providers, rawJSON)
+}
+
+// checkResponseFormat validates an OpenAI json_schema response_format against the schema
+// subset Gemini supports when a Gemini-family provider may serve the request.
+func checkResponseFormat(handlerType string, providers []string, rawJSON []byte) *interfaces.ErrorMessage {
+	if handlerType != "openai" || gjson.GetBytes(rawJSON, "response_format.type").String() != "json_schema" {
+		return nil
+	}
+	if !slices.ContainsFunc(providers, isGeminiFamilyProvider) {
+		return nil
+	}
+	if _, err := geminicommon.ConvertJSONSchema(gjson.GetBytes(rawJSON, "response_format.json_schema.schema")); err != nil {
+		return &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("response_format.json_schema: %w", err)}
+	}
+	return nil
+}
+
+func isGeminiFamilyProvider(provider string) bool {
+	switch strings.ToLower(provider) {
+	case "gemini", "gemini-cli", "vertex", "aistudio", "antigravity":
+		return true
+	}
+	return false
+}
+
+// prepareImages enforces the image size limit and, when a candidate provider only accepts
+// inline image data, downloads remote image URLs before translation.
+func (h *BaseAPIHandler) prepareImages(ctx context.Context, handlerType string, providers []string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	proxyURL := ""
+	if h.Cfg != nil {
+		proxyURL = h.Cfg.ProxyURL
+	}
+	out, err := multimodal.Prepare(ctx, handlerType, rawJSON, multimodal.RequiresInlineImages(providers), proxyURL)
+	if err == nil {
+		return out, nil
+	}
+	var tooLarge *multimodal.ImageTooLargeError
+	var invalid *multimodal.InputError
+	if errors.As(err, &tooLarge) || errors.As(err, &invalid) {
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: err}
+	}
+	return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+}
+
 func cloneBytes(src []byte) []byte {
 	if len(src) == 0 {
 		return nil
@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
@@ -116,3 +121,34 @@ func TestGetRequestDetails_PreservesSuffix(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestProvidersHonoursKeyScope(t *testing.T) {
+	modelRegistry := registry.GetGlobalRegistry()
+	for _, client := range []struct{ id, provider string }{
+		{"test-scoped-providers-gemini", "gemini"},
+		{"test-scoped-providers-vertex", "vertex"},
+	} {
+		modelRegistry.RegisterClient(client.id, client.provider, []*registry.ModelInfo{{ID: "scoped-flash", Created: time.Now().Unix()}})
+		id := client.id
+		t.Cleanup(func() { modelRegistry.UnregisterClient(id) })
+	}
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, coreauth.NewManager(nil, nil, nil))
+	request := func(allowed []string) ([]string, *interfaces.ErrorMessage) {
+		ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		if allowed != nil {
+			ginCtx.Set(AllowedProvidersContextKey, allowed)
+		}
+		providers, _, errMsg := handler.requestProviders(context.WithValue(context.Background(), "gin", ginCtx), "scoped-flash")
+		return providers, errMsg
+	}
+
+	if providers, errMsg := request(nil); errMsg != nil || len(providers) != 2 {
+		t.Fatalf("unscoped: providers %v, err %v", providers, errMsg)
+	}
+	if providers, errMsg := request([]string{"Vertex"}); errMsg != nil || !reflect.DeepEqual(providers, []string{"vertex"}) {
+		t.Fatalf("scoped to vertex: providers %v, err %v", providers, errMsg)
+	}
+	if _, errMsg := request([]string{"claude"}); errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("scoped to claude: err %v, want 403", errMsg)
+	}
+}
@@ -57,6 +57,7 @@ func (h *OpenAIResponsesAPIHandler) Models() []map[string]any {
 // It returns a list of available AI models with their capabilities
 // and specifications in OpenAIResponses-compatible format.
 func (h *OpenAIResponsesAPIHandler) OpenAIResponsesModels(c *gin.Context) {
+	handlers.SetModelListHeaders(c)
 	c.JSON(http.StatusOK, gin.H{
 		"object": "list",
 		"data":   h.Models(),
@@ -0,0 +1,147 @@
+package openai
+
+import (
+	"context"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// streamUsageTracker implements stream_options.include_usage for chat completion streams.
+// Usage is taken from the record the executor publishes, so the final chunk shows the same
+// numbers the usage statistics store. Usage attached to content chunks is nulled out and
+// reported once, in a trailing chunk with empty choices, as OpenAI does.
+type streamUsageTracker struct {
+	capture  *usage.Capture
+	estimate bool
+	request  []byte
+
+	id         string
+	model      string
+	created    int64
+	completion strings.Builder
+	chunkUsage string
+	// upstreamFinal is set when an OpenAI-compatible upstream already sent the usage chunk.
+	upstreamFinal bool
+}
+
+// newStreamUsageTracker returns nil unless the request asked for usage in the stream.
+func newStreamUsageTracker(cfg *config.SDKConfig, rawJSON []byte) *streamUsageTracker {
+	if !gjson.GetBytes(rawJSON, "stream_options.include_usage").Bool() {
+		return nil
+	}
+	return &streamUsageTracker{
+		estimate: cfg == nil || !cfg.Streaming.OmitMissingUsage,
+		request:  rawJSON,
+		model:    gjson.GetBytes(rawJSON, "model").String(),
+	}
+}
+
+// attach installs the usage capture on the context handed to the executors.
+func (t *streamUsageTracker) attach(ctx context.Context) context.Context {
+	if t == nil {
+		return ctx
+	}
+	ctx, t.capture = usage.WithCapture(ctx, t.estimate)
+	return ctx
+}
+
+// release publishes a usage record still held for estimation; it is safe to call twice.
+func (t *streamUsageTracker) release(ctx context.Context) {
+	if t != nil {
+		t.capture.Release(ctx, usage.Detail{}, false)
+	}
+}
+
+// filter inspects one chunk before it is written and strips its usage.
+func (t *streamUsageTracker) filter(chunk []byte) []byte {
+	if t == nil || !gjson.ValidBytes(chunk) {
+		return chunk
+	}
+	root := gjson.ParseBytes(chunk)
+	if id := root.Get("id").String(); id != "" {
+		t.id = id
+	}
+	if model := root.Get("model").String(); model != "" {
+		t.model = model
+	}
+	if created := root.Get("created").Int(); created > 0 {
+		t.created = created
+	}
+	choices := root.Get("choices")
+	usageNode := root.Get("usage")
+	if choices.IsArray() && len(choices.Array()) == 0 && usageNode.IsObject() {
+		t.upstreamFinal = true
+		return chunk
+	}
+	choices.ForEach(func(_, choice gjson.Result) bool {
+		delta := choice.Get("delta")
+		t.completion.WriteString(delta.Get("content").String())
+		t.completion.WriteString(delta.Get("reasoning_content").String())
+		delta.Get("tool_calls").ForEach(func(_, call gjson.Result) bool {
+			t.completion.WriteString(call.Get("function.name").String())
+			t.completion.WriteString(call.Get("function.arguments").String())
+			return true
+		})
+		return true
+	})
+	if usageNode.IsObject() {
+		t.chunkUsage = usageNode.Raw
+		chunk, _ = sjson.SetRawBytes(chunk, "usage", []byte("null"))
+	}
+	return chunk
+}
+
+// finalChunk returns the usage chunk to send before [DONE], or nil when none applies.
+func (t *streamUsageTracker) finalChunk(ctx context.Context) []byte {
+	if t == nil || t.upstreamFinal {
+		t.release(ctx)
+		return nil
+	}
+	var usageJSON string
+	if detail, ok := t.capture.Detail(); ok {
+		usageJSON = openAIUsageJSON(detail, false)
+	} else if t.chunkUsage != "" {
+		usageJSON = t.chunkUsage
+	} else if t.estimate {
+		prompt, output := executor.EstimateOpenAIChatUsage(t.model, t.request, t.completion.String())
+		detail := usage.Detail{InputTokens: prompt, OutputTokens: output, TotalTokens: prompt + output}
+		t.capture.Release(ctx, detail, true)
+		usageJSON = openAIUsageJSON(detail, true)
+	}
+	t.release(ctx)
+	if usageJSON == "" {
+		return nil
+	}
+	out := `{"id":"","object":"chat.completion.chunk","created":0,"model":"","choices":[]}`
+	out, _ = sjson.Set(out, "id", t.id)
+	out, _ = sjson.Set(out, "created", t.created)
+	out, _ = sjson.Set(out, "model", t.model)
+	out, _ = sjson.SetRaw(out, "usage", usageJSON)
+	return []byte(out)
+}
+
+func openAIUsageJSON(detail usage.Detail, estimated bool) string {
+	total := detail.TotalTokens
+	if total == 0 {
+		total = detail.InputTokens + detail.OutputTokens + detail.ReasoningTokens
+	}
+	out := `{"prompt_tokens":0,"completion_tokens":0,"total_tokens":0}`
+	out, _ = sjson.Set(out, "prompt_tokens", detail.InputTokens)
+	out, _ = sjson.Set(out, "completion_tokens", detail.OutputTokens)
+	out, _ = sjson.Set(out, "total_tokens", total)
+	if detail.CachedTokens > 0 {
+		out, _ = sjson.Set(out, "prompt_tokens_details.cached_tokens", detail.CachedTokens)
+	}
+	if detail.ReasoningTokens > 0 {
+		out, _ = sjson.Set(out, "completion_tokens_details.reasoning_tokens", detail.ReasoningTokens)
+	}
+	if estimated {
+		out, _ = sjson.Set(out, "estimated", true)
+	}
+	return out
+}
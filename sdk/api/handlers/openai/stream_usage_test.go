@@ -0,0 +1,44 @@
+package openai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/tidwall/gjson"
+)
+
+func TestStreamUsageTrackerReportsPublishedUsage(t *testing.T) {
+	tracker := newStreamUsageTracker(nil, []byte(`{"model":"m","stream":true,"stream_options":{"include_usage":true}}`))
+	ctx := tracker.attach(context.Background())
+
+	chunk := tracker.filter([]byte(`{"id":"c1","created":7,"model":"m","choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+	if usageNode := gjson.GetBytes(chunk, "usage"); usageNode.Type != gjson.Null {
+		t.Fatalf("content chunks must not carry usage, got %s", usageNode.Raw)
+	}
+	usage.PublishRecord(ctx, usage.Record{Provider: "claude", Detail: usage.Detail{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}})
+
+	final := tracker.finalChunk(ctx)
+	if gjson.GetBytes(final, "choices.#").Int() != 0 || gjson.GetBytes(final, "id").String() != "c1" {
+		t.Fatalf("unexpected final chunk: %s", final)
+	}
+	if got := gjson.GetBytes(final, "usage.total_tokens").Int(); got != 15 {
+		t.Fatalf("total_tokens = %d, want the published 15", got)
+	}
+}
+
+func TestStreamUsageTrackerEstimatesMissingUsage(t *testing.T) {
+	tracker := newStreamUsageTracker(nil, []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hello there"}],"stream_options":{"include_usage":true}}`))
+	ctx := tracker.attach(context.Background())
+	tracker.filter([]byte(`{"id":"c1","choices":[{"index":0,"delta":{"content":"general kenobi"}}]}`))
+	usage.PublishRecord(ctx, usage.Record{Provider: "gemini"})
+
+	final := tracker.finalChunk(ctx)
+	if !gjson.GetBytes(final, "usage.estimated").Bool() || gjson.GetBytes(final, "usage.completion_tokens").Int() == 0 {
+		t.Fatalf("expected estimated usage, got %s", final)
+	}
+
+	if newStreamUsageTracker(nil, []byte(`{"stream":true}`)) != nil {
+		t.Fatalf("tracker must only be created when include_usage is requested")
+	}
+}
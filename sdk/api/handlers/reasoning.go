@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// reasoningFilter removes upstream reasoning from responses when reasoning-output is
+// "strip". It works on the client-facing format, after translation, so every provider is
+// covered by one implementation per client API. Responses API output is left alone because
+// its reasoning items carry encrypted state clients send back on the next turn.
+type reasoningFilter struct {
+	handlerType string
+
+	// Claude streams renumber content blocks once thinking blocks are removed.
+	droppedBlocks map[int64]bool
+	blockShift    map[int64]int64
+	dropped       int64
+}
+
+// newReasoningFilter returns nil unless reasoning is stripped for this client format.
+func newReasoningFilter(cfg *config.SDKConfig, handlerType string) *reasoningFilter {
+	if cfg.NormalizedReasoningOutput() != config.ReasoningOutputStrip {
+		return nil
+	}
+	switch handlerType {
+	case "openai", "claude", "gemini", "gemini-cli":
+		return &reasoningFilter{
+			handlerType:   handlerType,
+			droppedBlocks: make(map[int64]bool),
+			blockShift:    make(map[int64]int64),
+		}
+	}
+	return nil
+}
+
+// response strips reasoning from a complete non-streaming response.
+func (f *reasoningFilter) response(payload []byte) []byte {
+	if f == nil || !gjson.ValidBytes(payload) {
+		return payload
+	}
+	switch f.handlerType {
+	case "openai":
+		gjson.GetBytes(payload, "choices").ForEach(func(key, _ gjson.Result) bool {
+			payload, _ = sjson.DeleteBytes(payload, "choices."+key.String()+".message.reasoning_content")
+			return true
+		})
+	case "claude":
+		var kept []string
+		removed := false
+		gjson.GetBytes(payload, "content").ForEach(func(_, block gjson.Result) bool {
+			if isClaudeThinkingBlock(block) {
+				removed = true
+			} else {
+				kept = append(kept, block.Raw)
+			}
+			return true
+		})
+		if removed {
+			payload, _ = sjson.SetRawBytes(payload, "content", []byte("["+strings.Join(kept, ",")+"]"))
+		}
+	default:
+		payload, _ = f.stripGeminiThoughts(payload)
+	}
+	return payload
+}
+
+// chunk strips reasoning from one streamed chunk. It returns nil when nothing is left to send.
+func (f *reasoningFilter) chunk(chunk []byte) []byte {
+	if f == nil || len(chunk) == 0 {
+		return chunk
+	}
+	switch f.handlerType {
+	case "openai":
+		return f.openAIChunk(chunk)
+	case "claude":
+		return f.claudeChunk(chunk)
+	default:
+		if !gjson.ValidBytes(chunk) {
+			return chunk
+		}
+		out, empty := f.stripGeminiThoughts(chunk)
+		if empty {
+			return nil
+		}
+		return out
+	}
+}
+
+func (f *reasoningFilter) openAIChunk(chunk []byte) []byte {
+	if !gjson.ValidBytes(chunk) {
+		return chunk
+	}
+	stripped := false
+	keep := false
+	gjson.GetBytes(chunk, "choices").ForEach(func(key, choice gjson.Result) bool {
+		if choice.Get("delta.reasoning_content").Exists() {
+			chunk, _ = sjson.DeleteBytes(chunk, "choices."+key.String()+".delta.reasoning_content")
+			stripped = true
+		}
+		if choice.Get("finish_reason").Type != gjson.Null && choice.Get("finish_reason").Exists() {
+			keep = true
+		}
+		choice.Get("delta").ForEach(func(field, value gjson.Result) bool {
+			if name := field.String(); name != "role" && name != "reasoning_content" && value.Type != gjson.Null {
+				keep = true
+			}
+			return !keep
+		})
+		return true
+	})
+	if stripped && !keep && !gjson.GetBytes(chunk, "usage").IsObject() {
+		return nil
+	}
+	return chunk
+}
+
+// claudeChunk drops thinking blocks from Claude SSE output and shifts the indices of the
+// blocks after them so the client still sees a contiguous sequence.
+func (f *reasoningFilter) claudeChunk(chunk []byte) []byte {
+	events := bytes.Split(chunk, []byte("\n\n"))
+	out := make([][]byte, 0, len(events))
+	changed := false
+	for _, event := range events {
+		dataStart := bytes.Index(event, []byte("data:"))
+		if dataStart < 0 {
+			out = append(out, event)
+			continue
+		}
+		data := bytes.TrimSpace(event[dataStart+len("data:"):])
+		root := gjson.ParseBytes(data)
+		index := root.Get("index")
+		switch root.Get("type").String() {
+		case "content_block_start":
+			if isClaudeThinkingBlock(root.Get("content_block")) {
+				f.droppedBlocks[index.Int()] = true
+				f.dropped++
+				changed = true
+				continue
+			}
+			f.blockShift[index.Int()] = f.dropped
+		case "content_block_delta", "content_block_stop":
+			if f.droppedBlocks[index.Int()] {
+				changed = true
+				continue
+			}
+		}
+		if shift := f.blockShift[index.Int()]; index.Exists() && shift > 0 {
+			data, _ = sjson.SetBytes(data, "index", index.Int()-shift)
+			event = append(append(bytes.Clone(event[:dataStart]), "data: "...), data...)
+			changed = true
+		}
+		out = append(out, event)
+	}
+	if !changed {
+		return chunk
+	}
+	joined := bytes.Join(out, []byte("\n\n"))
+	if len(bytes.TrimSpace(joined)) == 0 {
+		return nil
+	}
+	return joined
+}
+
+// stripGeminiThoughts removes thought parts and reports whether the chunk carried nothing else.
+func (f *reasoningFilter) stripGeminiThoughts(payload []byte) ([]byte, bool) {
+	prefix := ""
+	if f.handlerType == "gemini-cli" || gjson.GetBytes(payload, "response.candidates").Exists() {
+		prefix = "response."
+	}
+	removed := false
+	hasContent := false
+	gjson.GetBytes(payload, prefix+"candidates").ForEach(func(ci, candidate gjson.Result) bool {
+		if candidate.Get("finishReason").Exists() {
+			hasContent = true
+		}
+		var kept []string
+		dropped := false
+		candidate.Get("content.parts").ForEach(func(_, part gjson.Result) bool {
+			if part.Get("thought").Bool() {
+				dropped = true
+			} else {
+				kept = append(kept, part.Raw)
+			}
+			return true
+		})
+		if len(kept) > 0 {
+			hasContent = true
+		}
+		if dropped {
+			removed = true
+			path := prefix + "candidates." + ci.String() + ".content.parts"
+			payload, _ = sjson.SetRawBytes(payload, path, []byte("["+strings.Join(kept, ",")+"]"))
+		}
+		return true
+	})
+	if gjson.GetBytes(payload, prefix+"usageMetadata").Exists() {
+		hasContent = true
+	}
+	return payload, removed && !hasContent
+}
+
+func isClaudeThinkingBlock(block gjson.Result) bool {
+	switch block.Get("type").String() {
+	case "thinking", "redacted_thinking":
+		return true
+	}
+	return false
+}
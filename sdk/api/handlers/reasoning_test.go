@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func stripReasoningConfig() *sdkconfig.SDKConfig {
+	return &sdkconfig.SDKConfig{ReasoningOutput: sdkconfig.ReasoningOutputStrip}
+}
+
+func TestReasoningFilterDisabledByDefault(t *testing.T) {
+	if newReasoningFilter(&sdkconfig.SDKConfig{}, "openai") != nil {
+		t.Fatal("reasoning must be exposed unless reasoning-output is strip")
+	}
+	chunk := []byte(`{"choices":[{"delta":{"reasoning_content":"hm"}}]}`)
+	var f *reasoningFilter
+	if string(f.chunk(chunk)) != string(chunk) {
+		t.Fatal("a nil filter must pass chunks through")
+	}
+}
+
+func TestReasoningFilterOpenAI(t *testing.T) {
+	f := newReasoningFilter(stripReasoningConfig(), "openai")
+	if out := f.chunk([]byte(`{"choices":[{"index":0,"delta":{"role":"assistant","content":null,"reasoning_content":"hm"},"finish_reason":null}]}`)); out != nil {
+		t.Fatalf("reasoning-only chunk must be dropped, got %s", out)
+	}
+	out := f.chunk([]byte(`{"choices":[{"index":0,"delta":{"content":"hi","reasoning_content":"hm"},"finish_reason":null}]}`))
+	if gjson.GetBytes(out, "choices.0.delta.reasoning_content").Exists() || gjson.GetBytes(out, "choices.0.delta.content").String() != "hi" {
+		t.Fatalf("unexpected chunk: %s", out)
+	}
+	resp := f.response([]byte(`{"choices":[{"message":{"content":"hi","reasoning_content":"hm"}}]}`))
+	if gjson.GetBytes(resp, "choices.0.message.reasoning_content").Exists() {
+		t.Fatalf("unexpected response: %s", resp)
+	}
+}
+
+func TestReasoningFilterClaudeRenumbersBlocks(t *testing.T) {
+	f := newReasoningFilter(stripReasoningConfig(), "claude")
+	stream := "event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"thinking\",\"thinking\":\"\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"hm\"}}\n\n" +
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n"
+	if out := f.chunk([]byte(stream)); out != nil {
+		t.Fatalf("thinking block must be dropped, got %q", out)
+	}
+	out := string(f.chunk([]byte("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n")))
+	if !strings.HasPrefix(out, "event: content_block_start\ndata: ") || !strings.Contains(out, `"index":0`) {
+		t.Fatalf("text block must move to index 0, got %q", out)
+	}
+	out = string(f.chunk([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n")))
+	if !strings.Contains(out, `"index":0`) || !strings.HasSuffix(out, "\n\n") {
+		t.Fatalf("text delta must move to index 0, got %q", out)
+	}
+
+	resp := f.response([]byte(`{"content":[{"type":"thinking","thinking":"hm"},{"type":"text","text":"hi"}]}`))
+	if got := gjson.GetBytes(resp, "content.#.type").Raw; got != `["text"]` {
+		t.Fatalf("content types = %s", got)
+	}
+}
+
+func TestReasoningFilterGemini(t *testing.T) {
+	f := newReasoningFilter(stripReasoningConfig(), "gemini")
+	if out := f.chunk([]byte(`{"candidates":[{"content":{"parts":[{"text":"hm","thought":true}]}}]}`)); out != nil {
+		t.Fatalf("thought-only chunk must be dropped, got %s", out)
+	}
+	out := f.chunk([]byte(`{"candidates":[{"content":{"parts":[{"text":"hm","thought":true},{"text":"hi"}]}}]}`))
+	if got := gjson.GetBytes(out, "candidates.0.content.parts.#.text").Raw; got != `["hi"]` {
+		t.Fatalf("parts = %s", got)
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// maxPooledSSEBuffer bounds the framing buffers kept for reuse.
+const maxPooledSSEBuffer = 64 << 10
+
+var sseBufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// WriteSSEData writes chunk to w as a single "data: <chunk>\n\n" event. The event is framed
+// in a pooled buffer that is reused once Write returns; io.Writer implementations must not
+// retain the slice, so the buffer never outlives the write.
+func WriteSSEData(w io.Writer, chunk []byte) error {
+	buf := sseBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	buf.Grow(len(chunk) + len("data: \n\n"))
+	buf.WriteString("data: ")
+	buf.Write(chunk)
+	buf.WriteString("\n\n")
+	_, err := w.Write(buf.Bytes())
+	if buf.Cap() <= maxPooledSSEBuffer {
+		sseBufferPool.Put(buf)
+	}
+	return err
+}
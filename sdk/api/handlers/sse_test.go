@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWriteSSEDataConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for s := 0; s < 32; s++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			var out bytes.Buffer
+			var want strings.Builder
+			for i := 0; i < 100; i++ {
+				chunk := fmt.Sprintf(`{"stream":%d,"chunk":%d}`, id, i)
+				_ = WriteSSEData(&out, []byte(chunk))
+				want.WriteString("data: " + chunk + "\n\n")
+			}
+			if out.String() != want.String() {
+				t.Errorf("stream %d: framed output diverged", id)
+			}
+		}(s)
+	}
+	wg.Wait()
+}
+
+// BenchmarkWriteSSEData frames a 500-chunk stream, comparing fmt.Fprintf with the pooled writer.
+func BenchmarkWriteSSEData(b *testing.B) {
+	chunks := make([][]byte, 500)
+	for i := range chunks {
+		chunks[i] = []byte(fmt.Sprintf(`{"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"token %d "}}]}`, i))
+	}
+	b.Run("fprintf", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, chunk := range chunks {
+				_, _ = fmt.Fprintf(io.Discard, "data: %s\n\n", string(chunk))
+			}
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, chunk := range chunks {
+				_ = WriteSSEData(io.Discard, chunk)
+			}
+		}
+	})
+}
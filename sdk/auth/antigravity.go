@@ -11,10 +11,10 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/antigravity"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/browser"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
-	log "github.com/sirupsen/logrus"
 )
 
 // AntigravityAuthenticator implements OAuth login for the antigravity provider.
@@ -47,7 +47,11 @@ func (AntigravityAuthenticator) Login(ctx context.Context, cfg *config.Config, o
 	callbackPort := antigravity.CallbackPort
 	if opts.CallbackPort > 0 {
 		callbackPort = opts.CallbackPort
+	} else if opts.CallbackPort == CallbackPortAuto {
+		// The callback server reports the OS-assigned port back.
+		callbackPort = 0
 	}
+	timeout := callbackTimeout(opts)
 
 	authSvc := antigravity.NewAntigravityAuth(cfg, nil)
 
@@ -56,7 +60,7 @@ func (AntigravityAuthenticator) Login(ctx context.Context, cfg *config.Config, o
 		return nil, fmt.Errorf("antigravity: failed to generate state: %w", err)
 	}
 
-	srv, port, cbChan, errServer := startAntigravityCallbackServer(callbackPort)
+	srv, port, cbChan, errServer := startAntigravityCallbackServer(opts.CallbackHost, callbackPort)
 	if errServer != nil {
 		return nil, fmt.Errorf("antigravity: failed to start callback server: %w", errServer)
 	}
@@ -85,10 +89,11 @@ func (AntigravityAuthenticator) Login(ctx context.Context, cfg *config.Config, o
 		fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
 	}
 
+	misc.PrintOAuthCallbackListening(opts.CallbackHost, port, "/oauth-callback")
 	fmt.Println("Waiting for antigravity authentication callback...")
 
 	var cbRes callbackResult
-	timeoutTimer := time.NewTimer(5 * time.Minute)
+	timeoutTimer := time.NewTimer(timeout)
 	defer timeoutTimer.Stop()
 
 	var manualPromptTimer *time.Timer
@@ -134,7 +139,7 @@ waitForCallback:
 			}
 			break waitForCallback
 		case <-timeoutTimer.C:
-			return nil, fmt.Errorf("antigravity: authentication timed out")
+			return nil, misc.OAuthCallbackTimeoutError("antigravity", timeout, port)
 		}
 	}
 
@@ -220,12 +225,12 @@ type callbackResult struct {
 	State string
 }
 
-func startAntigravityCallbackServer(port int) (*http.Server, int, <-chan callbackResult, error) {
-	if port <= 0 {
+// startAntigravityCallbackServer listens on host:port; port 0 lets the OS pick one.
+func startAntigravityCallbackServer(host string, port int) (*http.Server, int, <-chan callbackResult, error) {
+	if port < 0 {
 		port = antigravity.CallbackPort
 	}
-	addr := fmt.Sprintf(":%d", port)
-	listener, err := net.Listen("tcp", addr)
+	listener, err := net.Listen("tcp", misc.OAuthCallbackListenAddr(host, port))
 	if err != nil {
 		return nil, 0, nil, err
 	}
@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"time"
+
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+)
+
+// resolveCallbackPort picks the callback port for a login. Providers with a registered,
+// fixed redirect URI (dynamic == false) keep their default when a free port is requested.
+func resolveCallbackPort(provider string, opts *LoginOptions, defaultPort int, dynamic bool) (int, error) {
+	switch {
+	case opts.CallbackPort > 0:
+		return opts.CallbackPort, nil
+	case opts.CallbackPort == CallbackPortAuto && dynamic:
+		return misc.FreeOAuthCallbackPort(opts.CallbackHost)
+	case opts.CallbackPort == CallbackPortAuto:
+		log.Warnf("%s only accepts its registered redirect port; using %d", provider, defaultPort)
+	}
+	return defaultPort, nil
+}
+
+func callbackTimeout(opts *LoginOptions) time.Duration {
+	if opts != nil && opts.CallbackTimeout > 0 {
+		return opts.CallbackTimeout
+	}
+	return misc.DefaultOAuthCallbackTimeout
+}
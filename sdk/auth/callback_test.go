@@ -0,0 +1,28 @@
+package auth
+
+import "testing"
+
+func TestResolveCallbackPort(t *testing.T) {
+	port, err := resolveCallbackPort("claude", &LoginOptions{CallbackPort: CallbackPortAuto}, 54545, false)
+	if err != nil || port != 54545 {
+		t.Fatalf("fixed redirect provider: got %d, %v; want default port", port, err)
+	}
+
+	port, err = resolveCallbackPort("gemini", &LoginOptions{CallbackPort: CallbackPortAuto, CallbackHost: "127.0.0.1"}, 8085, true)
+	if err != nil {
+		t.Fatalf("auto port: %v", err)
+	}
+	if port <= 0 {
+		t.Fatalf("auto port: got %d, want a positive port", port)
+	}
+
+	port, err = resolveCallbackPort("gemini", &LoginOptions{CallbackPort: 9999}, 8085, true)
+	if err != nil || port != 9999 {
+		t.Fatalf("explicit port: got %d, %v; want 9999", port, err)
+	}
+
+	port, err = resolveCallbackPort("gemini", &LoginOptions{}, 8085, true)
+	if err != nil || port != 8085 {
+		t.Fatalf("unset port: got %d, %v; want 8085", port, err)
+	}
+}
@@ -11,10 +11,10 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/browser"
 	// legacy client removed
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
-	log "github.com/sirupsen/logrus"
 )
 
 // ClaudeAuthenticator implements the OAuth login flow for Anthropic Claude accounts.
@@ -47,10 +47,11 @@ func (a *ClaudeAuthenticator) Login(ctx context.Context, cfg *config.Config, opt
 		opts = &LoginOptions{}
 	}
 
-	callbackPort := a.CallbackPort
-	if opts.CallbackPort > 0 {
-		callbackPort = opts.CallbackPort
+	callbackPort, err := resolveCallbackPort("claude", opts, a.CallbackPort, false)
+	if err != nil {
+		return nil, err
 	}
+	timeout := callbackTimeout(opts)
 
 	pkceCodes, err := claude.GeneratePKCECodes()
 	if err != nil {
@@ -63,6 +64,7 @@ func (a *ClaudeAuthenticator) Login(ctx context.Context, cfg *config.Config, opt
 	}
 
 	oauthServer := claude.NewOAuthServer(callbackPort)
+	oauthServer.SetHost(opts.CallbackHost)
 	if err = oauthServer.Start(); err != nil {
 		if strings.Contains(err.Error(), "already in use") {
 			return nil, claude.NewAuthenticationError(claude.ErrPortInUse, err)
@@ -101,6 +103,7 @@ func (a *ClaudeAuthenticator) Login(ctx context.Context, cfg *config.Config, opt
 		fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
 	}
 
+	misc.PrintOAuthCallbackListening(opts.CallbackHost, callbackPort, "/callback")
 	fmt.Println("Waiting for Claude authentication callback...")
 
 	callbackCh := make(chan *claude.OAuthResult, 1)
@@ -108,7 +111,7 @@ func (a *ClaudeAuthenticator) Login(ctx context.Context, cfg *config.Config, opt
 	manualDescription := ""
 
 	go func() {
-		result, errWait := oauthServer.WaitForCallback(5 * time.Minute)
+		result, errWait := oauthServer.WaitForCallback(timeout)
 		if errWait != nil {
 			callbackErrCh <- errWait
 			return
@@ -132,7 +135,7 @@ waitForCallback:
 			break waitForCallback
 		case err = <-callbackErrCh:
 			if strings.Contains(err.Error(), "timeout") {
-				return nil, claude.NewAuthenticationError(claude.ErrCallbackTimeout, err)
+				return nil, claude.NewAuthenticationError(claude.ErrCallbackTimeout, misc.OAuthCallbackTimeoutError("claude", timeout, callbackPort))
 			}
 			return nil, err
 		case <-manualPromptC:
@@ -145,7 +148,7 @@ waitForCallback:
 				break waitForCallback
 			case err = <-callbackErrCh:
 				if strings.Contains(err.Error(), "timeout") {
-					return nil, claude.NewAuthenticationError(claude.ErrCallbackTimeout, err)
+					return nil, claude.NewAuthenticationError(claude.ErrCallbackTimeout, misc.OAuthCallbackTimeoutError("claude", timeout, callbackPort))
 				}
 				return nil, err
 			default:
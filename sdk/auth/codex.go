@@ -13,10 +13,10 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/browser"
 	// legacy client removed
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
-	log "github.com/sirupsen/logrus"
 )
 
 // CodexAuthenticator implements the OAuth login flow for Codex accounts.
@@ -49,10 +49,11 @@ func (a *CodexAuthenticator) Login(ctx context.Context, cfg *config.Config, opts
 		opts = &LoginOptions{}
 	}
 
-	callbackPort := a.CallbackPort
-	if opts.CallbackPort > 0 {
-		callbackPort = opts.CallbackPort
+	callbackPort, err := resolveCallbackPort("codex", opts, a.CallbackPort, false)
+	if err != nil {
+		return nil, err
 	}
+	timeout := callbackTimeout(opts)
 
 	pkceCodes, err := codex.GeneratePKCECodes()
 	if err != nil {
@@ -65,6 +66,7 @@ func (a *CodexAuthenticator) Login(ctx context.Context, cfg *config.Config, opts
 	}
 
 	oauthServer := codex.NewOAuthServer(callbackPort)
+	oauthServer.SetHost(opts.CallbackHost)
 	if err = oauthServer.Start(); err != nil {
 		if strings.Contains(err.Error(), "already in use") {
 			return nil, codex.NewAuthenticationError(codex.ErrPortInUse, err)
@@ -102,6 +104,7 @@ func (a *CodexAuthenticator) Login(ctx context.Context, cfg *config.Config, opts
 		fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
 	}
 
+	misc.PrintOAuthCallbackListening(opts.CallbackHost, callbackPort, "/auth/callback")
 	fmt.Println("Waiting for Codex authentication callback...")
 
 	callbackCh := make(chan *codex.OAuthResult, 1)
@@ -109,7 +112,7 @@ func (a *CodexAuthenticator) Login(ctx context.Context, cfg *config.Config, opts
 	manualDescription := ""
 
 	go func() {
-		result, errWait := oauthServer.WaitForCallback(5 * time.Minute)
+		result, errWait := oauthServer.WaitForCallback(timeout)
 		if errWait != nil {
 			callbackErrCh <- errWait
 			return
@@ -133,7 +136,7 @@ waitForCallback:
 			break waitForCallback
 		case err = <-callbackErrCh:
 			if strings.Contains(err.Error(), "timeout") {
-				return nil, codex.NewAuthenticationError(codex.ErrCallbackTimeout, err)
+				return nil, codex.NewAuthenticationError(codex.ErrCallbackTimeout, misc.OAuthCallbackTimeoutError("codex", timeout, callbackPort))
 			}
 			return nil, err
 		case <-manualPromptC:
@@ -146,7 +149,7 @@ waitForCallback:
 				break waitForCallback
 			case err = <-callbackErrCh:
 				if strings.Contains(err.Error(), "timeout") {
-					return nil, codex.NewAuthenticationError(codex.ErrCallbackTimeout, err)
+					return nil, codex.NewAuthenticationError(codex.ErrCallbackTimeout, misc.OAuthCallbackTimeoutError("codex", timeout, callbackPort))
 				}
 				return nil, err
 			default:
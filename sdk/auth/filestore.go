@@ -12,6 +12,10 @@ import (
 	"sync"
 	"time"
 
+	baseauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/filecrypt"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 )
 
@@ -20,6 +24,8 @@ type FileTokenStore struct {
 	mu      sync.Mutex
 	dirLock sync.RWMutex
 	baseDir string
+	// extraDirs are the other auth directories scanned by List, in order.
+	extraDirs []string
 }
 
 // NewFileTokenStore creates a token store that saves credentials to disk through the
@@ -35,6 +41,23 @@ func (s *FileTokenStore) SetBaseDir(dir string) {
 	s.dirLock.Unlock()
 }
 
+// SetAuthDirs sets every directory List scans and Delete resolves IDs against. New files are
+// still written to the base directory.
+func (s *FileTokenStore) SetAuthDirs(dirs []string) {
+	s.dirLock.Lock()
+	defer s.dirLock.Unlock()
+	s.extraDirs = nil
+	for _, dir := range dirs {
+		dir = strings.TrimSpace(dir)
+		if resolved, errResolve := util.ResolveAuthDir(dir); errResolve == nil {
+			dir = resolved
+		}
+		if dir != "" {
+			s.extraDirs = append(s.extraDirs, dir)
+		}
+	}
+}
+
 // Save persists token storage and metadata to the resolved auth file path.
 func (s *FileTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (string, error) {
 	if auth == nil {
@@ -64,7 +87,7 @@ func (s *FileTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (str
 
 	switch {
 	case auth.Storage != nil:
-		if err = auth.Storage.SaveTokenToFile(path); err != nil {
+		if err = saveStorage(auth.Storage, path); err != nil {
 			return "", err
 		}
 	case auth.Metadata != nil:
@@ -74,25 +97,19 @@ func (s *FileTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (str
 			return "", fmt.Errorf("auth filestore: marshal metadata failed: %w", errMarshal)
 		}
 		if existing, errRead := os.ReadFile(path); errRead == nil {
-			if jsonEqual(existing, raw) {
+			// Rewrite an unchanged plaintext file once a key is configured so it gets encrypted.
+			upToDate := filecrypt.IsEncrypted(existing) || !filecrypt.Enabled()
+			if plain, errDecrypt := filecrypt.Decrypt(existing); errDecrypt == nil && upToDate && jsonEqual(plain, raw) {
 				return path, nil
 			}
-			file, errOpen := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0o600)
-			if errOpen != nil {
-				return "", fmt.Errorf("auth filestore: open existing failed: %w", errOpen)
-			}
-			if _, errWrite := file.Write(raw); errWrite != nil {
-				_ = file.Close()
-				return "", fmt.Errorf("auth filestore: write existing failed: %w", errWrite)
-			}
-			if errClose := file.Close(); errClose != nil {
-				return "", fmt.Errorf("auth filestore: close existing failed: %w", errClose)
-			}
-			return path, nil
 		} else if !os.IsNotExist(errRead) {
 			return "", fmt.Errorf("auth filestore: read existing failed: %w", errRead)
 		}
-		if errWrite := os.WriteFile(path, raw, 0o600); errWrite != nil {
+		sealed, errSeal := filecrypt.Seal(raw)
+		if errSeal != nil {
+			return "", fmt.Errorf("auth filestore: encrypt failed: %w", errSeal)
+		}
+		if errWrite := filecrypt.WriteFileAtomic(path, sealed, 0o600); errWrite != nil {
 			return "", fmt.Errorf("auth filestore: write file failed: %w", errWrite)
 		}
 	default:
@@ -111,34 +128,45 @@ func (s *FileTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (str
 	return path, nil
 }
 
-// List enumerates all auth JSON files under the configured directory.
+// List enumerates all auth JSON files under the configured directories. Files in the base
+// directory are identified by their relative path, files in the other directories by their
+// full path.
 func (s *FileTokenStore) List(ctx context.Context) ([]*cliproxyauth.Auth, error) {
-	dir := s.baseDirSnapshot()
+	dir, extraDirs := s.dirsSnapshot()
 	if dir == "" {
 		return nil, fmt.Errorf("auth filestore: directory not configured")
 	}
 	entries := make([]*cliproxyauth.Auth, 0)
-	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, walkErr error) error {
-		if walkErr != nil {
-			return walkErr
-		}
-		if d.IsDir() {
-			return nil
+	for i, root := range append([]string{dir}, extraDirs...) {
+		idBase := ""
+		if i == 0 {
+			idBase = dir
 		}
-		if !strings.HasSuffix(strings.ToLower(d.Name()), ".json") {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if !strings.HasSuffix(strings.ToLower(d.Name()), ".json") {
+				return nil
+			}
+			auth, err := s.readAuthFile(path, idBase)
+			if err != nil {
+				return nil
+			}
+			if auth != nil {
+				entries = append(entries, auth)
+			}
 			return nil
-		}
-		auth, err := s.readAuthFile(path, dir)
+		})
 		if err != nil {
-			return nil
-		}
-		if auth != nil {
-			entries = append(entries, auth)
+			if i > 0 && os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
 		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
 	}
 	return entries, nil
 }
@@ -159,11 +187,24 @@ func (s *FileTokenStore) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// resolveDeletePath maps an ID to its file. A relative ID is looked up in every auth
+// directory; a relative path found in none of them is used as given.
 func (s *FileTokenStore) resolveDeletePath(id string) (string, error) {
-	if strings.ContainsRune(id, os.PathSeparator) || filepath.IsAbs(id) {
+	if filepath.IsAbs(id) {
+		return id, nil
+	}
+	dir, extraDirs := s.dirsSnapshot()
+	if dir != "" {
+		for _, root := range append([]string{dir}, extraDirs...) {
+			candidate := filepath.Join(root, id)
+			if _, errStat := os.Stat(candidate); errStat == nil {
+				return candidate, nil
+			}
+		}
+	}
+	if strings.ContainsRune(id, os.PathSeparator) {
 		return id, nil
 	}
-	dir := s.baseDirSnapshot()
 	if dir == "" {
 		return "", fmt.Errorf("auth filestore: directory not configured")
 	}
@@ -171,7 +212,7 @@ func (s *FileTokenStore) resolveDeletePath(id string) (string, error) {
 }
 
 func (s *FileTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth, error) {
-	data, err := os.ReadFile(path)
+	data, err := filecrypt.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read file: %w", err)
 	}
@@ -201,9 +242,8 @@ func (s *FileTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth,
 				if errFetch == nil && strings.TrimSpace(fetchedProjectID) != "" {
 					metadata["project_id"] = strings.TrimSpace(fetchedProjectID)
 					if raw, errMarshal := json.Marshal(metadata); errMarshal == nil {
-						if file, errOpen := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0o600); errOpen == nil {
-							_, _ = file.Write(raw)
-							_ = file.Close()
+						if sealed, errSeal := filecrypt.Seal(raw); errSeal == nil {
+							_ = filecrypt.WriteFileAtomic(path, sealed, 0o600)
 						}
 					}
 				}
@@ -237,6 +277,9 @@ func (s *FileTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth,
 	if email, ok := metadata["email"].(string); ok && email != "" {
 		auth.Attributes["email"] = email
 	}
+	if errProxy := auth.ApplyMetadataProxyURL(); errProxy != nil {
+		log.Errorf("auth file %s: account disabled until proxy_url is fixed: %v", path, errProxy)
+	}
 	return auth, nil
 }
 
@@ -298,6 +341,19 @@ func (s *FileTokenStore) labelFor(metadata map[string]any) string {
 	return ""
 }
 
+// dirsSnapshot returns the base directory and the other auth directories, without the base.
+func (s *FileTokenStore) dirsSnapshot() (string, []string) {
+	s.dirLock.RLock()
+	defer s.dirLock.RUnlock()
+	extra := make([]string, 0, len(s.extraDirs))
+	for _, dir := range s.extraDirs {
+		if filepath.Clean(dir) != filepath.Clean(s.baseDir) {
+			extra = append(extra, dir)
+		}
+	}
+	return s.baseDir, extra
+}
+
 func (s *FileTokenStore) baseDirSnapshot() string {
 	s.dirLock.RLock()
 	defer s.dirLock.RUnlock()
@@ -366,3 +422,32 @@ func deepEqualJSON(a, b any) bool {
 		return false
 	}
 }
+
+// saveStorage writes a provider token storage to path, encrypting it when a key is configured.
+// The token is then encoded and sealed in memory, so no plaintext ever reaches the disk.
+func saveStorage(storage baseauth.TokenStorage, path string) error {
+	if !filecrypt.Enabled() {
+		return storage.SaveTokenToFile(path)
+	}
+	var plain []byte
+	var err error
+	if marshaler, ok := storage.(baseauth.TokenMarshaler); ok {
+		plain, err = marshaler.MarshalToken()
+	} else {
+		plain, err = json.Marshal(storage)
+	}
+	if err != nil {
+		return fmt.Errorf("auth filestore: encode token failed: %w", err)
+	}
+	if len(plain) == 0 {
+		return nil
+	}
+	sealed, err := filecrypt.Encrypt(plain)
+	if err != nil {
+		return fmt.Errorf("auth filestore: encrypt failed: %w", err)
+	}
+	if err = filecrypt.WriteFileAtomic(path, sealed, 0o600); err != nil {
+		return fmt.Errorf("auth filestore: write file failed: %w", err)
+	}
+	return nil
+}
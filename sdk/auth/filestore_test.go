@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/claude"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/filecrypt"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestSaveEncryptsStorageWithoutPlaintextFile(t *testing.T) {
+	filecrypt.SetKey("test-secret")
+	t.Cleanup(func() { filecrypt.SetKey("") })
+
+	dir := t.TempDir()
+	store := NewFileTokenStore()
+	store.SetBaseDir(dir)
+	auth := &cliproxyauth.Auth{
+		ID:       "claude-user.json",
+		FileName: "claude-user.json",
+		Provider: "claude",
+		Storage:  &claude.ClaudeTokenStorage{RefreshToken: "rt", Email: "user@example.com"},
+	}
+	path, err := store.Save(context.Background(), auth)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != filepath.Base(path) {
+		t.Fatalf("auth dir holds %v, want only %s", entries, filepath.Base(path))
+	}
+	raw, _ := os.ReadFile(path)
+	if !filecrypt.IsEncrypted(raw) {
+		t.Fatalf("saved file is not encrypted: %s", raw)
+	}
+	plain, err := filecrypt.Decrypt(raw)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	var saved map[string]any
+	if err = json.Unmarshal(plain, &saved); err != nil || saved["type"] != "claude" || saved["refresh_token"] != "rt" {
+		t.Fatalf("decrypted token = %s (%v)", plain, err)
+	}
+}
+
+func TestListAndDeleteAcrossAuthDirs(t *testing.T) {
+	base := t.TempDir()
+	extra := t.TempDir()
+	for _, path := range []string{filepath.Join(base, "a.json"), filepath.Join(extra, "b.json")} {
+		if err := os.WriteFile(path, []byte(`{"type":"claude"}`), 0o600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	store := NewFileTokenStore()
+	store.SetBaseDir(base)
+	store.SetAuthDirs([]string{base, extra})
+
+	auths, err := store.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	ids := make(map[string]bool)
+	for _, a := range auths {
+		ids[a.ID] = true
+	}
+	extraID := filepath.Join(extra, "b.json")
+	if len(ids) != 2 || !ids["a.json"] || !ids[extraID] {
+		t.Fatalf("listed ids = %v, want a.json and %s", ids, extraID)
+	}
+
+	if err = store.Delete(context.Background(), "b.json"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, errStat := os.Stat(extraID); !os.IsNotExist(errStat) {
+		t.Fatalf("b.json in the extra dir was not deleted: %v", errStat)
+	}
+	if _, errStat := os.Stat(filepath.Join(base, "a.json")); errStat != nil {
+		t.Fatalf("a.json should be kept: %v", errStat)
+	}
+}
@@ -43,11 +43,18 @@ func (a *GeminiAuthenticator) Login(ctx context.Context, cfg *config.Config, opt
 		ts.ProjectID = opts.ProjectID
 	}
 
+	callbackPort, err := resolveCallbackPort("gemini", opts, gemini.DefaultCallbackPort, true)
+	if err != nil {
+		return nil, err
+	}
+
 	geminiAuth := gemini.NewGeminiAuth()
-	_, err := geminiAuth.GetAuthenticatedClient(ctx, &ts, cfg, &gemini.WebLoginOptions{
-		NoBrowser:    opts.NoBrowser,
-		CallbackPort: opts.CallbackPort,
-		Prompt:       opts.Prompt,
+	_, err = geminiAuth.GetAuthenticatedClient(ctx, &ts, cfg, &gemini.WebLoginOptions{
+		NoBrowser:       opts.NoBrowser,
+		CallbackPort:    callbackPort,
+		CallbackHost:    opts.CallbackHost,
+		CallbackTimeout: opts.CallbackTimeout,
+		Prompt:          opts.Prompt,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("gemini authentication failed: %w", err)
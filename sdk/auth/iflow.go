@@ -9,10 +9,10 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/iflow"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/browser"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
-	log "github.com/sirupsen/logrus"
 )
 
 // IFlowAuthenticator implements the OAuth login flow for iFlow accounts.
@@ -42,14 +42,16 @@ func (a *IFlowAuthenticator) Login(ctx context.Context, cfg *config.Config, opts
 		opts = &LoginOptions{}
 	}
 
-	callbackPort := iflow.CallbackPort
-	if opts.CallbackPort > 0 {
-		callbackPort = opts.CallbackPort
+	callbackPort, errPort := resolveCallbackPort("iflow", opts, iflow.CallbackPort, true)
+	if errPort != nil {
+		return nil, errPort
 	}
+	timeout := callbackTimeout(opts)
 
 	authSvc := iflow.NewIFlowAuth(cfg)
 
 	oauthServer := iflow.NewOAuthServer(callbackPort)
+	oauthServer.SetHost(opts.CallbackHost)
 	if err := oauthServer.Start(); err != nil {
 		if strings.Contains(err.Error(), "already in use") {
 			return nil, fmt.Errorf("iflow authentication server port in use: %w", err)
@@ -87,13 +89,14 @@ func (a *IFlowAuthenticator) Login(ctx context.Context, cfg *config.Config, opts
 		fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
 	}
 
+	misc.PrintOAuthCallbackListening(opts.CallbackHost, callbackPort, "/oauth2callback")
 	fmt.Println("Waiting for iFlow authentication callback...")
 
 	callbackCh := make(chan *iflow.OAuthResult, 1)
 	callbackErrCh := make(chan error, 1)
 
 	go func() {
-		result, errWait := oauthServer.WaitForCallback(5 * time.Minute)
+		result, errWait := oauthServer.WaitForCallback(timeout)
 		if errWait != nil {
 			callbackErrCh <- errWait
 			return
@@ -116,7 +119,7 @@ waitForCallback:
 		case result = <-callbackCh:
 			break waitForCallback
 		case err = <-callbackErrCh:
-			return nil, fmt.Errorf("iflow auth: callback wait failed: %w", err)
+			return nil, iflowCallbackWaitError(err, timeout, callbackPort)
 		case <-manualPromptC:
 			manualPromptC = nil
 			if manualPromptTimer != nil {
@@ -126,7 +129,7 @@ waitForCallback:
 			case result = <-callbackCh:
 				break waitForCallback
 			case err = <-callbackErrCh:
-				return nil, fmt.Errorf("iflow auth: callback wait failed: %w", err)
+				return nil, iflowCallbackWaitError(err, timeout, callbackPort)
 			default:
 			}
 			input, errPrompt := opts.Prompt("Paste the iFlow callback URL (or press Enter to keep waiting): ")
@@ -189,3 +192,10 @@ waitForCallback:
 		},
 	}, nil
 }
+
+func iflowCallbackWaitError(err error, timeout time.Duration, port int) error {
+	if strings.Contains(err.Error(), "timeout") {
+		return misc.OAuthCallbackTimeoutError("iflow", timeout, port)
+	}
+	return fmt.Errorf("iflow auth: callback wait failed: %w", err)
+}
@@ -14,13 +14,22 @@ var ErrRefreshNotSupported = errors.New("cliproxy auth: refresh not supported")
 // LoginOptions captures generic knobs shared across authenticators.
 // Provider-specific logic can inspect Metadata for extra parameters.
 type LoginOptions struct {
-	NoBrowser    bool
-	ProjectID    string
+	NoBrowser bool
+	ProjectID string
+	// CallbackPort overrides the provider's callback port. Zero keeps the provider default and
+	// CallbackPortAuto picks a free port where the provider accepts dynamic redirect URIs.
 	CallbackPort int
-	Metadata     map[string]string
-	Prompt       func(prompt string) (string, error)
+	// CallbackHost is the interface the callback server binds to; empty means all interfaces.
+	CallbackHost string
+	// CallbackTimeout bounds the wait for the browser redirect; zero means five minutes.
+	CallbackTimeout time.Duration
+	Metadata        map[string]string
+	Prompt          func(prompt string) (string, error)
 }
 
+// CallbackPortAuto requests a free, OS-assigned callback port.
+const CallbackPortAuto = -1
+
 // Authenticator manages login and optional refresh flows for a provider.
 type Authenticator interface {
 	Provider() string
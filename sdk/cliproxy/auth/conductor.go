@@ -16,12 +16,16 @@ import (
 
 	"github.com/google/uuid"
 	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
-	log "github.com/sirupsen/logrus"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ProviderExecutor defines the contract required by Manager to execute provider calls.
@@ -47,11 +51,12 @@ type RefreshEvaluator interface {
 }
 
 const (
-	refreshCheckInterval  = 5 * time.Second
-	refreshPendingBackoff = time.Minute
-	refreshFailureBackoff = 5 * time.Minute
-	quotaBackoffBase      = time.Second
-	quotaBackoffMax       = 30 * time.Minute
+	refreshCheckInterval      = 5 * time.Second
+	refreshPendingBackoff     = time.Minute
+	refreshFailureBackoffBase = 30 * time.Second
+	refreshFailureBackoffMax  = 30 * time.Minute
+	quotaBackoffBase          = time.Second
+	quotaBackoffMax           = 30 * time.Minute
 )
 
 var quotaCooldownDisabled atomic.Bool
@@ -142,8 +147,27 @@ type Manager struct {
 	// Optional HTTP RoundTripper provider injected by host.
 	rtProvider RoundTripperProvider
 
+	// quotaGuard optionally skips credentials that are close to a provider-side quota.
+	quotaGuard QuotaGuard
+
 	// Auto refresh state
+	refreshMu     sync.Mutex
 	refreshCancel context.CancelFunc
+	refreshWG     sync.WaitGroup
+
+	// inFlight counts executing requests per auth ID (*atomic.Int64).
+	inFlight sync.Map
+
+	// transientRetries counts retried transient upstream failures per provider (*atomic.Int64).
+	transientRetries sync.Map
+
+	// statusHandler is notified of auth status changes; see SetStatusChangeHandler.
+	statusHandler atomic.Pointer[StatusChangeHandler]
+
+	// Health check state
+	healthMu     sync.Mutex
+	healthCancel context.CancelFunc
+	healthWG     sync.WaitGroup
 }
 
 // NewManager constructs a manager with optional custom selector and hook.
@@ -165,6 +189,7 @@ func NewManager(store Store, selector Selector, hook Hook) *Manager {
 	// atomic.Value requires non-nil initial value.
 	manager.runtimeConfig.Store(&internalconfig.Config{})
 	manager.apiKeyModelAlias.Store(apiKeyModelAliasTable(nil))
+	manager.bindSelectorLoad(selector)
 	return manager
 }
 
@@ -175,6 +200,7 @@ func (m *Manager) SetSelector(selector Selector) {
 	if selector == nil {
 		selector = &RoundRobinSelector{}
 	}
+	m.bindSelectorLoad(selector)
 	m.mu.Lock()
 	m.selector = selector
 	m.mu.Unlock()
@@ -429,16 +455,32 @@ func (m *Manager) Update(ctx context.Context, auth *Auth) (*Auth, error) {
 		return nil, nil
 	}
 	m.mu.Lock()
-	if existing, ok := m.auths[auth.ID]; ok && existing != nil && !auth.indexAssigned && auth.Index == "" {
+	existing, ok := m.auths[auth.ID]
+	if ok && existing != nil && !auth.indexAssigned && auth.Index == "" {
 		auth.Index = existing.Index
 		auth.indexAssigned = existing.indexAssigned
 	}
+	if ok && existing != nil {
+		// Health is owned by the probe loop; callers updating an auth never carry it.
+		auth.Health = existing.Health
+	}
+	newlyDisabled := ok && existing != nil && !authDisabled(existing) && authDisabled(auth)
+	var oldStatus Status
+	if ok && existing != nil {
+		oldStatus = existing.Status
+	}
 	auth.EnsureIndex()
 	m.auths[auth.ID] = auth.Clone()
 	m.mu.Unlock()
 	m.rebuildAPIKeyModelAliasFromRuntimeConfig()
 	_ = m.persist(ctx, auth)
 	m.hook.OnAuthUpdated(ctx, auth.Clone())
+	if ok && existing != nil {
+		m.emitStatusChange(ctx, auth.ID, auth.Provider, oldStatus, auth.Status)
+	}
+	if newlyDisabled {
+		notifyAccountDisabled(auth)
+	}
 	return auth.Clone(), nil
 }
 
@@ -569,10 +611,17 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 	routeModel := req.Model
 	opts = ensureRequestedModelMetadata(opts, routeModel)
 	tried := make(map[string]struct{})
+	transient := m.newTransientRetry()
 	var lastErr error
+	attempt := 0
 	for {
-		auth, executor, provider, errPick := m.pickNextMixed(ctx, providers, routeModel, opts, tried)
+		auth, executor, provider, errPick := m.pickNextMixedTraced(ctx, providers, routeModel, opts, tried)
 		if errPick != nil {
+			if resumed, errWait := transient.resume(ctx, tried); errWait != nil {
+				return cliproxyexecutor.Response{}, errWait
+			} else if resumed {
+				continue
+			}
 			if lastErr != nil {
 				return cliproxyexecutor.Response{}, lastErr
 			}
@@ -592,12 +641,22 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 		execReq.Model = rewriteModelForAuth(routeModel, auth)
 		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
 		execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
+		attempt++
+		var attemptSpan trace.Span
+		execCtx, attemptSpan = startAttemptSpan(execCtx, attempt, auth, provider, routeModel, execReq.Model)
+		release := m.beginRequest(auth.ID)
 		resp, errExec := executor.Execute(execCtx, auth, execReq, opts)
+		release()
+		endSpanWithStatus(attemptSpan, errExec)
 		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
 		if errExec != nil {
 			if errCtx := execCtx.Err(); errCtx != nil {
 				return cliproxyexecutor.Response{}, errCtx
 			}
+			if transient.hold(ctx, auth, provider, errExec) {
+				lastErr = errExec
+				continue
+			}
 			result.Error = &Error{Message: errExec.Error()}
 			var se cliproxyexecutor.StatusError
 			if errors.As(errExec, &se) && se != nil {
@@ -623,8 +682,9 @@ func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string,
 	opts = ensureRequestedModelMetadata(opts, routeModel)
 	tried := make(map[string]struct{})
 	var lastErr error
+	attempt := 0
 	for {
-		auth, executor, provider, errPick := m.pickNextMixed(ctx, providers, routeModel, opts, tried)
+		auth, executor, provider, errPick := m.pickNextMixedTraced(ctx, providers, routeModel, opts, tried)
 		if errPick != nil {
 			if lastErr != nil {
 				return cliproxyexecutor.Response{}, lastErr
@@ -645,7 +705,13 @@ func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string,
 		execReq.Model = rewriteModelForAuth(routeModel, auth)
 		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
 		execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
+		attempt++
+		var attemptSpan trace.Span
+		execCtx, attemptSpan = startAttemptSpan(execCtx, attempt, auth, provider, routeModel, execReq.Model)
+		release := m.beginRequest(auth.ID)
 		resp, errExec := executor.CountTokens(execCtx, auth, execReq, opts)
+		release()
+		endSpanWithStatus(attemptSpan, errExec)
 		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
 		if errExec != nil {
 			if errCtx := execCtx.Err(); errCtx != nil {
@@ -675,10 +741,17 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 	routeModel := req.Model
 	opts = ensureRequestedModelMetadata(opts, routeModel)
 	tried := make(map[string]struct{})
+	transient := m.newTransientRetry()
 	var lastErr error
+	attempt := 0
 	for {
-		auth, executor, provider, errPick := m.pickNextMixed(ctx, providers, routeModel, opts, tried)
+		auth, executor, provider, errPick := m.pickNextMixedTraced(ctx, providers, routeModel, opts, tried)
 		if errPick != nil {
+			if resumed, errWait := transient.resume(ctx, tried); errWait != nil {
+				return nil, errWait
+			} else if resumed {
+				continue
+			}
 			if lastErr != nil {
 				return nil, lastErr
 			}
@@ -698,11 +771,23 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 		execReq.Model = rewriteModelForAuth(routeModel, auth)
 		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
 		execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
+		attempt++
+		var attemptSpan trace.Span
+		execCtx, attemptSpan = startAttemptSpan(execCtx, attempt, auth, provider, routeModel, execReq.Model)
+		// Each attempt times its own first token: an attempt that failed produced none.
+		execCtx = coreusage.WithFirstToken(execCtx)
+		release := m.beginRequest(auth.ID)
 		chunks, errStream := executor.ExecuteStream(execCtx, auth, execReq, opts)
 		if errStream != nil {
+			release()
+			endSpanWithStatus(attemptSpan, errStream)
 			if errCtx := execCtx.Err(); errCtx != nil {
 				return nil, errCtx
 			}
+			if transient.hold(ctx, auth, provider, errStream) {
+				lastErr = errStream
+				continue
+			}
 			rerr := &Error{Message: errStream.Error()}
 			var se cliproxyexecutor.StatusError
 			if errors.As(errStream, &se) && se != nil {
@@ -715,13 +800,32 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 			continue
 		}
 		out := make(chan cliproxyexecutor.StreamChunk)
+		_, streamSpan := tracing.Start(execCtx, "upstream.stream", trace.SpanKindInternal)
 		go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
 			defer close(out)
+			defer release()
 			var failed bool
+			var streamErr error
+			var chunkCount int
 			forward := true
+			defer func() {
+				if streamSpan.IsRecording() {
+					streamSpan.SetAttributes(attribute.Int("cliproxy.stream.chunks", chunkCount), attribute.Bool("cliproxy.stream.client_gone", !forward))
+				}
+				endSpanWithStatus(streamSpan, streamErr)
+				endSpanWithStatus(attemptSpan, streamErr)
+			}()
 			for chunk := range streamChunks {
+				chunkCount++
 				if chunk.Err != nil && !failed {
 					failed = true
+					streamErr = chunk.Err
+					if streamCtx != nil && streamCtx.Err() != nil {
+						// The client went away and its context aborted the upstream read; that
+						// says nothing about the credential.
+						forward = false
+						continue
+					}
 					rerr := &Error{Message: chunk.Err.Error()}
 					var se cliproxyexecutor.StatusError
 					if errors.As(chunk.Err, &se) && se != nil {
@@ -1142,10 +1246,14 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	suspendReason := ""
 	clearModelQuota := false
 	setModelQuota := false
+	var oldStatus, newStatus Status
+	provider := ""
 
 	m.mu.Lock()
 	if auth, ok := m.auths[result.AuthID]; ok && auth != nil {
 		now := time.Now()
+		oldStatus = auth.Status
+		provider = auth.Provider
 
 		if result.Success {
 			if result.Model != "" {
@@ -1212,6 +1320,7 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 						NextRecoverAt: next,
 						BackoffLevel:  backoffLevel,
 					}
+					logCooldown(auth, result.Model, next)
 					suspendReason = "quota"
 					shouldSuspendModel = true
 					setModelQuota = true
@@ -1234,6 +1343,7 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 			}
 		}
 
+		newStatus = auth.Status
 		_ = m.persist(ctx, auth)
 	}
 	m.mu.Unlock()
@@ -1250,6 +1360,7 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 		registry.GetGlobalRegistry().SuspendClientModel(result.AuthID, result.Model, suspendReason)
 	}
 
+	m.emitStatusChange(ctx, result.AuthID, provider, oldStatus, newStatus)
 	m.hook.OnResult(ctx, result)
 }
 
@@ -1470,6 +1581,7 @@ func applyAuthFailureState(auth *Auth, resultErr *Error, retryAfter *time.Durati
 		}
 		auth.Quota.NextRecoverAt = next
 		auth.NextRetryAfter = next
+		logCooldown(auth, "", next)
 	case 408, 500, 502, 503, 504:
 		auth.StatusMessage = "transient upstream error"
 		if quotaCooldownDisabledForAuth(auth) {
@@ -1484,6 +1596,18 @@ func applyAuthFailureState(auth *Auth, resultErr *Error, retryAfter *time.Durati
 	}
 }
 
+// logCooldown reports that an auth (optionally scoped to a model) is cooling down after a 429.
+func logCooldown(auth *Auth, model string, until time.Time) {
+	if auth == nil || until.IsZero() {
+		return
+	}
+	if model != "" {
+		log.Infof("account %s cooling until %s for model %s", auth.ID, until.Format(time.RFC3339), model)
+		return
+	}
+	log.Infof("account %s cooling until %s", auth.ID, until.Format(time.RFC3339))
+}
+
 // nextQuotaCooldown returns the next cooldown duration and updated backoff level for repeated quota errors.
 func nextQuotaCooldown(prevLevel int, disableCooling bool) (time.Duration, int) {
 	if prevLevel < 0 {
@@ -1545,6 +1669,9 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		}
 	}
 	registryRef := registry.GetGlobalRegistry()
+	modelFiltered := false
+	now := time.Now()
+	var quotaSkipped quotaSkip
 	for _, candidate := range m.auths {
 		if candidate.Provider != provider || candidate.Disabled {
 			continue
@@ -1553,12 +1680,22 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 			continue
 		}
 		if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(candidate.ID, modelKey) {
+			modelFiltered = true
+			continue
+		}
+		if m.skipForQuota(candidate, now, &quotaSkipped) {
 			continue
 		}
 		candidates = append(candidates, candidate)
 	}
 	if len(candidates) == 0 {
 		m.mu.RUnlock()
+		if quotaSkipped.count > 0 {
+			return nil, nil, quotaSkipped.err(modelKey, provider, now)
+		}
+		if modelFiltered {
+			return nil, nil, newModelNotAvailableError(modelKey)
+		}
 		return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
 	selected, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
@@ -1607,6 +1744,9 @@ func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model s
 		}
 	}
 	registryRef := registry.GetGlobalRegistry()
+	modelFiltered := false
+	now := time.Now()
+	var quotaSkipped quotaSkip
 	for _, candidate := range m.auths {
 		if candidate == nil || candidate.Disabled {
 			continue
@@ -1625,12 +1765,22 @@ func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model s
 			continue
 		}
 		if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(candidate.ID, modelKey) {
+			modelFiltered = true
+			continue
+		}
+		if m.skipForQuota(candidate, now, &quotaSkipped) {
 			continue
 		}
 		candidates = append(candidates, candidate)
 	}
 	if len(candidates) == 0 {
 		m.mu.RUnlock()
+		if quotaSkipped.count > 0 {
+			return nil, nil, "", quotaSkipped.err(modelKey, "", now)
+		}
+		if modelFiltered {
+			return nil, nil, "", newModelNotAvailableError(modelKey)
+		}
 		return nil, nil, "", &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
 	selected, errPick := m.selector.Pick(ctx, "mixed", model, opts, candidates)
@@ -1690,13 +1840,14 @@ func (m *Manager) StartAutoRefresh(parent context.Context, interval time.Duratio
 	} else {
 		interval = refreshCheckInterval
 	}
-	if m.refreshCancel != nil {
-		m.refreshCancel()
-		m.refreshCancel = nil
-	}
+	m.StopAutoRefresh()
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
 	ctx, cancel := context.WithCancel(parent)
 	m.refreshCancel = cancel
+	m.refreshWG.Add(1)
 	go func() {
+		defer m.refreshWG.Done()
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 		m.checkRefreshes(ctx)
@@ -1711,22 +1862,29 @@ func (m *Manager) StartAutoRefresh(parent context.Context, interval time.Duratio
 	}()
 }
 
-// StopAutoRefresh cancels the background refresh loop, if running.
+// StopAutoRefresh cancels the background refresh loop, if running, and waits for
+// the loop and any in-flight refreshes to return.
 func (m *Manager) StopAutoRefresh() {
-	if m.refreshCancel != nil {
-		m.refreshCancel()
-		m.refreshCancel = nil
+	m.refreshMu.Lock()
+	cancel := m.refreshCancel
+	m.refreshCancel = nil
+	m.refreshMu.Unlock()
+	if cancel == nil {
+		return
 	}
+	cancel()
+	m.refreshWG.Wait()
 }
 
 func (m *Manager) checkRefreshes(ctx context.Context) {
 	// log.Debugf("checking refreshes")
 	now := time.Now()
+	margin := m.refreshMargin()
 	snapshot := m.snapshotAuths()
 	for _, a := range snapshot {
 		typ, _ := a.AccountInfo()
 		if typ != "api_key" {
-			if !m.shouldRefresh(a, now) {
+			if !m.shouldRefresh(a, now, margin) {
 				continue
 			}
 			log.Debugf("checking refresh for %s, %s, %s", a.Provider, a.ID, typ)
@@ -1737,11 +1895,21 @@ func (m *Manager) checkRefreshes(ctx context.Context) {
 			if !m.markRefreshPending(a.ID, now) {
 				continue
 			}
-			go m.refreshAuth(ctx, a.ID)
+			m.refreshWG.Add(1)
+			go func(id string) {
+				defer m.refreshWG.Done()
+				m.refreshAuth(ctx, id)
+			}(a.ID)
 		}
 	}
 }
 
+// refreshMargin returns the configured lead time before expiry for background refreshes.
+func (m *Manager) refreshMargin() time.Duration {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	return cfg.AuthRefreshMarginDuration()
+}
+
 func (m *Manager) snapshotAuths() []*Auth {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -1752,7 +1920,9 @@ func (m *Manager) snapshotAuths() []*Auth {
 	return out
 }
 
-func (m *Manager) shouldRefresh(a *Auth, now time.Time) bool {
+// shouldRefresh reports whether a background refresh is due. margin raises the
+// provider refresh lead so tokens are renewed at least that long before expiry.
+func (m *Manager) shouldRefresh(a *Auth, now time.Time, margin time.Duration) bool {
 	if a == nil || a.Disabled {
 		return false
 	}
@@ -1792,6 +1962,9 @@ func (m *Manager) shouldRefresh(a *Auth, now time.Time) bool {
 	if lead == nil {
 		return false
 	}
+	if *lead < margin {
+		lead = &margin
+	}
 	if *lead <= 0 {
 		if hasExpiry && !expiry.IsZero() {
 			return now.After(expiry)
@@ -1995,13 +2168,27 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	log.Debugf("refreshed %s, %s, %v", auth.Provider, auth.ID, err)
 	now := time.Now()
 	if err != nil {
+		var oldStatus, newStatus Status
 		m.mu.Lock()
 		if current := m.auths[id]; current != nil {
-			current.NextRefreshAfter = now.Add(refreshFailureBackoff)
+			oldStatus = current.Status
+			current.RefreshFailures++
+			backoff := nextRefreshFailureBackoff(current.RefreshFailures)
+			current.NextRefreshAfter = now.Add(backoff)
 			current.LastError = &Error{Message: err.Error()}
+			if !current.Disabled {
+				current.Status = StatusDegraded
+				current.StatusMessage = "background refresh failed"
+			}
 			m.auths[id] = current
+			log.Warnf("refresh failed for %s, %s (attempt %d), retrying in %s: %v", auth.Provider, auth.ID, current.RefreshFailures, backoff, err)
+			if refreshFailedPermanently(err, current.RefreshFailures) {
+				notifyRefreshFailed(current, err)
+			}
+			newStatus = current.Status
 		}
 		m.mu.Unlock()
+		m.emitStatusChange(ctx, id, auth.Provider, oldStatus, newStatus)
 		return
 	}
 	if updated == nil {
@@ -2014,11 +2201,31 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	}
 	updated.LastRefreshedAt = now
 	updated.NextRefreshAfter = time.Time{}
+	updated.RefreshFailures = 0
+	if updated.Status == StatusDegraded {
+		updated.Status = StatusActive
+		updated.StatusMessage = ""
+	}
 	updated.LastError = nil
 	updated.UpdatedAt = now
 	_, _ = m.Update(ctx, updated)
 }
 
+// nextRefreshFailureBackoff returns the exponential delay before retrying a failed refresh.
+func nextRefreshFailureBackoff(failures int) time.Duration {
+	if failures < 1 {
+		failures = 1
+	}
+	backoff := refreshFailureBackoffBase
+	for i := 1; i < failures; i++ {
+		backoff *= 2
+		if backoff >= refreshFailureBackoffMax {
+			return refreshFailureBackoffMax
+		}
+	}
+	return backoff
+}
+
 func (m *Manager) executorFor(provider string) ProviderExecutor {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type refreshTestExecutor struct {
+	provider string
+	err      error
+}
+
+func (e *refreshTestExecutor) Identifier() string { return e.provider }
+
+func (e *refreshTestExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *refreshTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, nil
+}
+
+func (e *refreshTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return auth, nil
+}
+
+func (e *refreshTestExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *refreshTestExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func TestNextRefreshFailureBackoff_GrowsAndCaps(t *testing.T) {
+	if got := nextRefreshFailureBackoff(1); got != refreshFailureBackoffBase {
+		t.Fatalf("first failure backoff = %s, want %s", got, refreshFailureBackoffBase)
+	}
+	if got := nextRefreshFailureBackoff(3); got != 4*refreshFailureBackoffBase {
+		t.Fatalf("third failure backoff = %s, want %s", got, 4*refreshFailureBackoffBase)
+	}
+	if got := nextRefreshFailureBackoff(100); got != refreshFailureBackoffMax {
+		t.Fatalf("backoff should cap at %s, got %s", refreshFailureBackoffMax, got)
+	}
+}
+
+func TestManager_ShouldRefresh_MarginRaisesProviderLead(t *testing.T) {
+	lead := time.Minute
+	RegisterRefreshLeadProvider("refresh-margin-test", func() *time.Duration { return &lead })
+
+	m := NewManager(nil, nil, nil)
+	now := time.Now()
+	auth := &Auth{
+		ID:       "margin",
+		Provider: "refresh-margin-test",
+		Metadata: map[string]any{"expired": now.Add(3 * time.Minute).Format(time.RFC3339)},
+	}
+	if m.shouldRefresh(auth, now, 0) {
+		t.Fatalf("expiry beyond provider lead should not refresh without margin")
+	}
+	if !m.shouldRefresh(auth, now, 5*time.Minute) {
+		t.Fatalf("expiry inside margin should trigger refresh")
+	}
+}
+
+func TestManager_RefreshAuth_FailureDegradesAndBacksOff(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(&refreshTestExecutor{provider: "refresh-fail-test", err: errors.New("token endpoint down")})
+	if _, err := m.Register(context.Background(), &Auth{ID: "a1", Provider: "refresh-fail-test", Status: StatusActive}); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+
+	m.refreshAuth(context.Background(), "a1")
+	m.refreshAuth(context.Background(), "a1")
+
+	got, ok := m.GetByID("a1")
+	if !ok {
+		t.Fatalf("auth missing after refresh")
+	}
+	if got.Status != StatusDegraded {
+		t.Fatalf("status = %q, want %q", got.Status, StatusDegraded)
+	}
+	if got.RefreshFailures != 2 {
+		t.Fatalf("refresh failures = %d, want 2", got.RefreshFailures)
+	}
+	if wait := time.Until(got.NextRefreshAfter); wait <= refreshFailureBackoffBase {
+		t.Fatalf("expected backoff to grow beyond %s after two failures, got %s", refreshFailureBackoffBase, wait)
+	}
+}
+
+func TestManager_StopAutoRefresh_WaitsForLoop(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.StartAutoRefresh(context.Background(), time.Second)
+	done := make(chan struct{})
+	go func() {
+		m.StopAutoRefresh()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("StopAutoRefresh did not return")
+	}
+	// A second stop is a no-op.
+	m.StopAutoRefresh()
+}
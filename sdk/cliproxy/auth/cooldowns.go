@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"sort"
+	"time"
+)
+
+// CooldownEntry describes an auth, or one of its models, that is sitting out after a quota error.
+type CooldownEntry struct {
+	AuthID       string    `json:"auth_id"`
+	Provider     string    `json:"provider"`
+	Label        string    `json:"label,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	Until        time.Time `json:"until"`
+	BackoffLevel int       `json:"backoff_level,omitempty"`
+}
+
+// Cooldowns lists active quota cooldowns, soonest reset first. Expired entries are omitted.
+func (m *Manager) Cooldowns() []CooldownEntry {
+	now := time.Now()
+	m.mu.RLock()
+	var out []CooldownEntry
+	for _, a := range m.auths {
+		if a == nil {
+			continue
+		}
+		// Auth-level quota is aggregated from model states when those exist.
+		if len(a.ModelStates) == 0 && a.Quota.Exceeded && a.Quota.NextRecoverAt.After(now) {
+			out = append(out, CooldownEntry{
+				AuthID:       a.ID,
+				Provider:     a.Provider,
+				Label:        a.Label,
+				Reason:       a.Quota.Reason,
+				Until:        a.Quota.NextRecoverAt,
+				BackoffLevel: a.Quota.BackoffLevel,
+			})
+		}
+		for model, state := range a.ModelStates {
+			if state == nil || !state.Quota.Exceeded || !state.NextRetryAfter.After(now) {
+				continue
+			}
+			out = append(out, CooldownEntry{
+				AuthID:       a.ID,
+				Provider:     a.Provider,
+				Label:        a.Label,
+				Model:        model,
+				Reason:       state.Quota.Reason,
+				Until:        state.NextRetryAfter,
+				BackoffLevel: state.Quota.BackoffLevel,
+			})
+		}
+	}
+	m.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Until.Equal(out[j].Until) {
+			return out[i].Until.Before(out[j].Until)
+		}
+		if out[i].AuthID != out[j].AuthID {
+			return out[i].AuthID < out[j].AuthID
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
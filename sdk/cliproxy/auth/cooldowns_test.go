@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestManager_Cooldowns_ReportsRetryAfterDeadline(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	if _, err := m.Register(context.Background(), &Auth{ID: "c1", Provider: "cool-test"}); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	retryAfter := 2 * time.Minute
+	m.MarkResult(context.Background(), Result{
+		AuthID:     "c1",
+		Provider:   "cool-test",
+		Model:      "m1",
+		Success:    false,
+		RetryAfter: &retryAfter,
+		Error:      &Error{HTTPStatus: 429, Message: "rate limited"},
+	})
+
+	cooldowns := m.Cooldowns()
+	if len(cooldowns) != 1 {
+		t.Fatalf("cooldowns = %+v, want one entry", cooldowns)
+	}
+	entry := cooldowns[0]
+	if entry.AuthID != "c1" || entry.Model != "m1" {
+		t.Fatalf("unexpected cooldown entry %+v", entry)
+	}
+	if wait := time.Until(entry.Until); wait <= time.Minute || wait > retryAfter {
+		t.Fatalf("cooldown wait = %s, want close to %s", wait, retryAfter)
+	}
+
+	got, _ := m.GetByID("c1")
+	if blocked, reason, next := isAuthBlockedForModel(got, "m1", time.Now()); !blocked || reason != blockReasonCooldown || !next.Equal(entry.Until) {
+		t.Fatalf("cooling auth should be blocked until %s, got blocked=%v reason=%v next=%s", entry.Until, blocked, reason, next)
+	}
+}
@@ -30,3 +30,13 @@ func (e *Error) StatusCode() int {
 	}
 	return e.HTTPStatus
 }
+
+// newModelNotAvailableError reports that no registered account may serve the model.
+// The message intentionally omits which accounts were considered.
+func newModelNotAvailableError(model string) *Error {
+	return &Error{
+		Code:       "model_not_available",
+		Message:    "model " + model + " is not available on any configured account",
+		HTTPStatus: 404,
+	}
+}
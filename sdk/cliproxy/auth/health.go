@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+)
+
+// healthTickInterval is how often the health loop looks for due probes.
+const healthTickInterval = 5 * time.Second
+
+// ErrHealthProbeUnsupported is returned by a HealthProber that cannot probe a specific auth,
+// for example an OAuth credential on a provider that only supports probing API keys.
+var ErrHealthProbeUnsupported = errors.New("health probe unsupported for this credential")
+
+// HealthProber is implemented by executors that can cheaply verify a credential,
+// such as by listing models or introspecting a token.
+type HealthProber interface {
+	ProbeHealth(ctx context.Context, auth *Auth) error
+}
+
+// HealthState tracks the result of background health probes for an auth.
+type HealthState struct {
+	// Unhealthy removes the auth from rotation until a probe succeeds again.
+	Unhealthy bool `json:"unhealthy"`
+	// ConsecutiveFailures counts probe failures since the last success.
+	ConsecutiveFailures int `json:"consecutive_failures,omitempty"`
+	// LastCheckedAt is when the last probe completed.
+	LastCheckedAt time.Time `json:"last_checked_at,omitempty"`
+	// NextCheckAt is when the next probe is due.
+	NextCheckAt time.Time `json:"next_check_at,omitempty"`
+	// LastError holds the most recent probe error message.
+	LastError string `json:"last_error,omitempty"`
+	// Unsupported records that the executor cannot probe this credential, which therefore
+	// says nothing about whether its provider is down.
+	Unsupported bool `json:"unsupported,omitempty"`
+}
+
+// AuthHealth pairs an auth identity with its health state for reporting.
+type AuthHealth struct {
+	ID       string      `json:"id"`
+	Provider string      `json:"provider"`
+	Label    string      `json:"label,omitempty"`
+	Health   HealthState `json:"health"`
+}
+
+// StartHealthChecks launches the background health probe loop. Probes only run while
+// health-check.enabled is set in the runtime config, so the loop can be started unconditionally.
+// Starting a new loop cancels the previous one.
+func (m *Manager) StartHealthChecks(parent context.Context) {
+	m.StopHealthChecks()
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+	ctx, cancel := context.WithCancel(parent)
+	m.healthCancel = cancel
+	m.healthWG.Add(1)
+	go func() {
+		defer m.healthWG.Done()
+		ticker := time.NewTicker(healthTickInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.checkHealth(ctx)
+			}
+		}
+	}()
+}
+
+// StopHealthChecks cancels the health probe loop and waits for in-flight probes.
+func (m *Manager) StopHealthChecks() {
+	m.healthMu.Lock()
+	cancel := m.healthCancel
+	m.healthCancel = nil
+	m.healthMu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	m.healthWG.Wait()
+}
+
+func (m *Manager) healthConfig() internalconfig.HealthCheckConfig {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	if cfg == nil {
+		return internalconfig.HealthCheckConfig{}
+	}
+	return cfg.HealthCheck
+}
+
+func (m *Manager) checkHealth(ctx context.Context) {
+	hc := m.healthConfig()
+	if !hc.Enabled {
+		return
+	}
+	now := time.Now()
+	for _, a := range m.snapshotAuths() {
+		if a.Disabled || now.Before(a.Health.NextCheckAt) {
+			continue
+		}
+		prober, ok := m.executorFor(a.Provider).(HealthProber)
+		if !ok {
+			continue
+		}
+		if !m.markHealthCheckPending(a.ID, now, hc) {
+			continue
+		}
+		m.healthWG.Add(1)
+		go func(id string, auth *Auth) {
+			defer m.healthWG.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, hc.TimeoutDuration())
+			defer cancel()
+			err := prober.ProbeHealth(probeCtx, auth)
+			if ctx.Err() != nil {
+				return
+			}
+			m.recordHealthResult(id, err, time.Now(), hc)
+		}(a.ID, a)
+	}
+}
+
+// markHealthCheckPending pushes NextCheckAt forward so a slow probe is not launched twice.
+func (m *Manager) markHealthCheckPending(id string, now time.Time, hc internalconfig.HealthCheckConfig) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	auth, ok := m.auths[id]
+	if !ok || auth == nil || auth.Disabled || now.Before(auth.Health.NextCheckAt) {
+		return false
+	}
+	auth.Health.NextCheckAt = now.Add(hc.TimeoutDuration() + healthTickInterval)
+	return true
+}
+
+// recordHealthResult applies a probe outcome to the auth's health state.
+func (m *Manager) recordHealthResult(id string, probeErr error, now time.Time, hc internalconfig.HealthCheckConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	auth, ok := m.auths[id]
+	if !ok || auth == nil {
+		return
+	}
+	health := &auth.Health
+	health.LastCheckedAt = now
+	health.Unsupported = errors.Is(probeErr, ErrHealthProbeUnsupported)
+	switch {
+	case probeErr == nil:
+		if health.Unhealthy {
+			log.Infof("auth %s (%s) passed health check, returning to rotation", auth.ID, auth.Provider)
+		}
+		health.Unhealthy = false
+		health.ConsecutiveFailures = 0
+		health.LastError = ""
+		health.NextCheckAt = now.Add(hc.IntervalDuration())
+	case errors.Is(probeErr, ErrHealthProbeUnsupported):
+		health.NextCheckAt = now.Add(hc.IntervalDuration())
+	default:
+		health.ConsecutiveFailures++
+		health.LastError = probeErr.Error()
+		if !health.Unhealthy && health.ConsecutiveFailures >= hc.Threshold() {
+			health.Unhealthy = true
+			log.Warnf("auth %s (%s) failed %d consecutive health checks, removing from rotation: %v", auth.ID, auth.Provider, health.ConsecutiveFailures, probeErr)
+		}
+		if health.Unhealthy {
+			health.NextCheckAt = now.Add(hc.RecoveryIntervalDuration())
+		} else {
+			health.NextCheckAt = now.Add(hc.IntervalDuration())
+		}
+	}
+}
+
+// HealthStates returns the health state of every registered auth, sorted by provider and ID.
+func (m *Manager) HealthStates() []AuthHealth {
+	m.mu.RLock()
+	out := make([]AuthHealth, 0, len(m.auths))
+	for _, a := range m.auths {
+		if a == nil {
+			continue
+		}
+		out = append(out, AuthHealth{ID: a.ID, Provider: a.Provider, Label: a.Label, Health: a.Health})
+	}
+	m.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// ProvidersDown lists providers whose enabled, probed auths are all marked unhealthy. Auths
+// whose executor cannot probe them are left out, as nothing is known about their health.
+func (m *Manager) ProvidersDown() []string {
+	m.mu.RLock()
+	total := make(map[string]int)
+	unhealthy := make(map[string]int)
+	for _, a := range m.auths {
+		if a == nil || a.Disabled || a.Health.Unsupported {
+			continue
+		}
+		if _, ok := m.executors[a.Provider].(HealthProber); !ok {
+			continue
+		}
+		provider := strings.ToLower(strings.TrimSpace(a.Provider))
+		total[provider]++
+		if a.Health.Unhealthy {
+			unhealthy[provider]++
+		}
+	}
+	m.mu.RUnlock()
+	var down []string
+	for provider, count := range total {
+		if count > 0 && unhealthy[provider] == count {
+			down = append(down, provider)
+		}
+	}
+	sort.Strings(down)
+	return down
+}
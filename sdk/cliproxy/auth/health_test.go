@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// healthTestExecutor is an executor that can probe its auths.
+type healthTestExecutor struct {
+	refreshTestExecutor
+}
+
+func (e *healthTestExecutor) ProbeHealth(context.Context, *Auth) error { return nil }
+
+func TestManager_RecordHealthResult_ThresholdAndRecovery(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(&healthTestExecutor{refreshTestExecutor{provider: "health-test"}})
+	if _, err := m.Register(context.Background(), &Auth{ID: "h1", Provider: "health-test"}); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	hc := internalconfig.HealthCheckConfig{Enabled: true, FailureThreshold: 2}
+	now := time.Now()
+	probeErr := errors.New("401 unauthorized")
+
+	m.recordHealthResult("h1", probeErr, now, hc)
+	if got, _ := m.GetByID("h1"); got.Health.Unhealthy {
+		t.Fatalf("auth should stay healthy below the failure threshold")
+	}
+	if got, _ := m.GetByID("h1"); !got.Health.NextCheckAt.Equal(now.Add(hc.IntervalDuration())) {
+		t.Fatalf("healthy auth should be re-probed after the regular interval")
+	}
+
+	m.recordHealthResult("h1", probeErr, now, hc)
+	got, _ := m.GetByID("h1")
+	if !got.Health.Unhealthy || got.Health.ConsecutiveFailures != 2 {
+		t.Fatalf("health = %+v, want unhealthy after 2 failures", got.Health)
+	}
+	if !got.Health.NextCheckAt.Equal(now.Add(hc.RecoveryIntervalDuration())) {
+		t.Fatalf("unhealthy auth should be re-probed after the recovery interval")
+	}
+	if blocked, reason, _ := isAuthBlockedForModel(got, "", now); !blocked || reason != blockReasonUnhealthy {
+		t.Fatalf("unhealthy auth should be blocked, got blocked=%v reason=%v", blocked, reason)
+	}
+	if down := m.ProvidersDown(); len(down) != 1 || down[0] != "health-test" {
+		t.Fatalf("providers down = %v, want [health-test]", down)
+	}
+
+	m.recordHealthResult("h1", nil, now, hc)
+	got, _ = m.GetByID("h1")
+	if got.Health.Unhealthy || got.Health.ConsecutiveFailures != 0 || got.Health.LastError != "" {
+		t.Fatalf("health = %+v, want recovered", got.Health)
+	}
+	if down := m.ProvidersDown(); len(down) != 0 {
+		t.Fatalf("providers down = %v, want none after recovery", down)
+	}
+}
+
+func TestManager_ProvidersDown_RequiresAllAuthsUnhealthy(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(&healthTestExecutor{refreshTestExecutor{provider: "p"}})
+	_, _ = m.Register(context.Background(), &Auth{ID: "a", Provider: "p", Health: HealthState{Unhealthy: true}})
+	_, _ = m.Register(context.Background(), &Auth{ID: "b", Provider: "p"})
+	if down := m.ProvidersDown(); len(down) != 0 {
+		t.Fatalf("providers down = %v, want none while one auth is healthy", down)
+	}
+}
+
+func TestManager_RecordHealthResult_UnsupportedIsIgnored(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	_, _ = m.Register(context.Background(), &Auth{ID: "u", Provider: "p"})
+	hc := internalconfig.HealthCheckConfig{Enabled: true, FailureThreshold: 1}
+	m.recordHealthResult("u", ErrHealthProbeUnsupported, time.Now(), hc)
+	if got, _ := m.GetByID("u"); got.Health.Unhealthy || got.Health.ConsecutiveFailures != 0 {
+		t.Fatalf("unsupported probe should not count as failure, got %+v", got.Health)
+	}
+}
+
+func TestManager_ProvidersDown_IgnoresUnprobedAuths(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(&refreshTestExecutor{provider: "no-prober"})
+	m.RegisterExecutor(&healthTestExecutor{refreshTestExecutor{provider: "p"}})
+	_, _ = m.Register(context.Background(), &Auth{ID: "a", Provider: "no-prober", Health: HealthState{Unhealthy: true}})
+	_, _ = m.Register(context.Background(), &Auth{ID: "b", Provider: "p", Health: HealthState{Unhealthy: true}})
+	_, _ = m.Register(context.Background(), &Auth{ID: "c", Provider: "p", Health: HealthState{Unsupported: true}})
+	if down := m.ProvidersDown(); len(down) != 1 || down[0] != "p" {
+		t.Fatalf("providers down = %v, want only the probed provider p", down)
+	}
+}
+
+func TestManager_Update_KeepsHealth(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	_, _ = m.Register(context.Background(), &Auth{ID: "h", Provider: "p"})
+	hc := internalconfig.HealthCheckConfig{Enabled: true, FailureThreshold: 1}
+	m.recordHealthResult("h", errors.New("401 unauthorized"), time.Now(), hc)
+
+	if _, err := m.Update(context.Background(), &Auth{ID: "h", Provider: "p", Label: "renamed"}); err != nil {
+		t.Fatalf("update auth: %v", err)
+	}
+	if got, _ := m.GetByID("h"); !got.Health.Unhealthy || got.Health.LastError == "" {
+		t.Fatalf("health after update = %+v, want the probe result kept", got.Health)
+	}
+}
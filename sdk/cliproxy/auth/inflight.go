@@ -0,0 +1,38 @@
+package auth
+
+import "sync/atomic"
+
+// loadAwareSelector is implemented by selectors that need per-auth in-flight counts.
+type loadAwareSelector interface {
+	bindLoad(load func(authID string) int64)
+}
+
+// InFlight returns the number of requests currently executing with the given auth.
+func (m *Manager) InFlight(authID string) int64 {
+	if m == nil {
+		return 0
+	}
+	if counter, ok := m.inFlight.Load(authID); ok {
+		return counter.(*atomic.Int64).Load()
+	}
+	return 0
+}
+
+// beginRequest increments the in-flight counter for an auth and returns the matching release.
+func (m *Manager) beginRequest(authID string) func() {
+	value, _ := m.inFlight.LoadOrStore(authID, &atomic.Int64{})
+	counter := value.(*atomic.Int64)
+	counter.Add(1)
+	var released atomic.Bool
+	return func() {
+		if released.CompareAndSwap(false, true) {
+			counter.Add(-1)
+		}
+	}
+}
+
+func (m *Manager) bindSelectorLoad(selector Selector) {
+	if aware, ok := selector.(loadAwareSelector); ok {
+		aware.bindLoad(m.InFlight)
+	}
+}
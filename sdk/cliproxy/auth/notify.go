@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/notify"
+)
+
+// notifyErrorLimit caps the error text quoted in a notification.
+const notifyErrorLimit = 200
+
+func authDisabled(auth *Auth) bool {
+	return auth.Disabled || auth.Status == StatusDisabled
+}
+
+// notifyAccountName names auth in notifications by label or file name, never by token.
+func notifyAccountName(auth *Auth) string {
+	if label := strings.TrimSpace(auth.Label); label != "" {
+		return label
+	}
+	if auth.FileName != "" {
+		return filepath.Base(auth.FileName)
+	}
+	return auth.EnsureIndex()
+}
+
+func notifyAccountDisabled(auth *Auth) {
+	reason := strings.TrimSpace(auth.StatusMessage)
+	if reason == "" {
+		reason = "taken out of rotation"
+	}
+	notify.Publish(notify.Event{
+		Type:     internalconfig.NotifyAccountDisabled,
+		Account:  notifyAccountName(auth),
+		Provider: auth.Provider,
+		Message:  reason,
+	})
+}
+
+// refreshFailedPermanently reports whether a refresh failure needs an operator: the provider
+// rejected the refresh token outright, or retries have reached the longest backoff. Both fire
+// once per failure streak.
+func refreshFailedPermanently(err error, failures int) bool {
+	switch statusCodeFromError(err) {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden:
+		if failures == 1 {
+			return true
+		}
+	}
+	return nextRefreshFailureBackoff(failures) == refreshFailureBackoffMax &&
+		nextRefreshFailureBackoff(failures-1) < refreshFailureBackoffMax
+}
+
+func notifyRefreshFailed(auth *Auth, err error) {
+	text := err.Error()
+	if len(text) > notifyErrorLimit {
+		text = text[:notifyErrorLimit] + "..."
+	}
+	notify.Publish(notify.Event{
+		Type:     internalconfig.NotifyRefreshFailed,
+		Account:  notifyAccountName(auth),
+		Provider: auth.Provider,
+		Message:  fmt.Sprintf("token refresh failed %d times in a row: %s", auth.RefreshFailures, text),
+	})
+}
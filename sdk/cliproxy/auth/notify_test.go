@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/notify"
+)
+
+type refreshStatusError struct{ code int }
+
+func (e refreshStatusError) Error() string   { return "invalid_grant" }
+func (e refreshStatusError) StatusCode() int { return e.code }
+
+func TestRefreshFailedPermanentlyFiresOncePerStreak(t *testing.T) {
+	var fired []int
+	for failures := 1; failures <= 20; failures++ {
+		if refreshFailedPermanently(errors.New("connection reset"), failures) {
+			fired = append(fired, failures)
+		}
+	}
+	if len(fired) != 1 || nextRefreshFailureBackoff(fired[0]) != refreshFailureBackoffMax {
+		t.Fatalf("transient failures fired at %v, want once when the backoff reaches its cap", fired)
+	}
+	if !refreshFailedPermanently(refreshStatusError{code: 401}, 1) {
+		t.Fatal("a rejected refresh token should notify on the first failure")
+	}
+	if refreshFailedPermanently(refreshStatusError{code: 401}, 2) {
+		t.Fatal("a rejected refresh token should notify only once")
+	}
+}
+
+func TestManagerUpdateNotifiesWhenAccountIsDisabled(t *testing.T) {
+	bodies := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer server.Close()
+	notify.Configure(internalconfig.NotificationsConfig{Webhooks: []internalconfig.NotificationWebhook{{URL: server.URL}}})
+	defer notify.Configure(internalconfig.NotificationsConfig{})
+
+	m := NewManager(nil, nil, nil)
+	if _, err := m.Register(context.Background(), &Auth{ID: "notify-a", Provider: "notify-test", Label: "ops@example.com"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	current, _ := m.GetByID("notify-a")
+	current.StatusMessage = "unrelated change"
+	if _, err := m.Update(context.Background(), current); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	current.Disabled = true
+	current.Status = StatusDisabled
+	current.StatusMessage = "disabled via management API"
+	if _, err := m.Update(context.Background(), current); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	select {
+	case body := <-bodies:
+		if !strings.Contains(body, "account disabled: ops@example.com (notify-test) - disabled via management API") {
+			t.Fatalf("unexpected notification: %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification for the disabled account")
+	}
+	select {
+	case body := <-bodies:
+		t.Fatalf("only the disabling update should notify, also got: %s", body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,47 @@
+package auth
+
+import "time"
+
+// QuotaGuard reports whether an auth is close enough to a provider-side quota that the
+// router should leave it alone until the quota resets. resetAt is when it becomes usable again.
+type QuotaGuard interface {
+	NearLimit(auth *Auth, now time.Time) (near bool, resetAt time.Time)
+}
+
+// SetQuotaGuard registers the guard consulted while picking credentials; nil disables it.
+func (m *Manager) SetQuotaGuard(guard QuotaGuard) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.quotaGuard = guard
+	m.mu.Unlock()
+}
+
+// quotaSkip tracks credentials skipped for quota reasons while building a candidate list.
+type quotaSkip struct {
+	count   int
+	resetAt time.Time
+}
+
+// skipForQuota reports whether the guard wants candidate left out, remembering the earliest reset.
+// Callers must hold m.mu.
+func (m *Manager) skipForQuota(candidate *Auth, now time.Time, state *quotaSkip) bool {
+	if m.quotaGuard == nil {
+		return false
+	}
+	near, resetAt := m.quotaGuard.NearLimit(candidate, now)
+	if !near {
+		return false
+	}
+	state.count++
+	if !resetAt.IsZero() && (state.resetAt.IsZero() || resetAt.Before(state.resetAt)) {
+		state.resetAt = resetAt
+	}
+	return true
+}
+
+// err returns the error reported when every candidate was skipped for quota reasons.
+func (s quotaSkip) err(model, provider string, now time.Time) error {
+	return newModelCooldownError(model, provider, s.resetAt.Sub(now))
+}
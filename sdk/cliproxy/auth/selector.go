@@ -26,12 +26,68 @@ type RoundRobinSelector struct {
 // rolling-window subscription caps (e.g. chat message limits).
 type FillFirstSelector struct{}
 
+// WeightedSelector distributes requests proportionally to each credential's "weight"
+// attribute using smooth weighted round-robin, so the sequence is deterministic.
+type WeightedSelector struct {
+	mu      sync.Mutex
+	current map[string]map[string]int
+}
+
+// LeastLoadedSelector picks the credential with the fewest in-flight requests.
+// Ties fall back to round-robin order.
+type LeastLoadedSelector struct {
+	mu      sync.Mutex
+	cursors map[string]int
+	load    func(authID string) int64
+}
+
+// Supported selection strategy names.
+const (
+	StrategyRoundRobin  = "round-robin"
+	StrategyFillFirst   = "fill-first"
+	StrategyWeighted    = "weighted"
+	StrategyLeastLoaded = "least-loaded"
+)
+
+// NormalizeStrategy maps strategy aliases to their canonical name.
+// It returns false for unknown strategies.
+func NormalizeStrategy(strategy string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(strategy)) {
+	case "", "round-robin", "roundrobin", "rr":
+		return StrategyRoundRobin, true
+	case "fill-first", "fillfirst", "ff":
+		return StrategyFillFirst, true
+	case "weighted", "weighted-round-robin", "wrr":
+		return StrategyWeighted, true
+	case "least-loaded", "leastloaded", "least-connections", "ll":
+		return StrategyLeastLoaded, true
+	default:
+		return "", false
+	}
+}
+
+// NewSelectorForStrategy builds the selector for a strategy name; unknown names use round-robin.
+func NewSelectorForStrategy(strategy string) Selector {
+	normalized, _ := NormalizeStrategy(strategy)
+	switch normalized {
+	case StrategyFillFirst:
+		return &FillFirstSelector{}
+	case StrategyWeighted:
+		return &WeightedSelector{}
+	case StrategyLeastLoaded:
+		return &LeastLoadedSelector{}
+	default:
+		return &RoundRobinSelector{}
+	}
+}
+
 type blockReason int
 
 const (
 	blockReasonNone blockReason = iota
 	blockReasonCooldown
 	blockReasonDisabled
+	blockReasonUnhealthy
 	blockReasonOther
 )
 
@@ -214,6 +270,109 @@ func (s *FillFirstSelector) Pick(ctx context.Context, provider, model string, op
 	return available[0], nil
 }
 
+// Pick selects the next auth so that, over time, each auth serves a share proportional to its weight.
+func (s *WeightedSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = opts
+	now := time.Now()
+	available, err := getAvailableAuths(auths, provider, model, now)
+	if err != nil {
+		return nil, err
+	}
+	key := provider + ":" + model
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		s.current = make(map[string]map[string]int)
+	}
+	current := s.current[key]
+	if current == nil {
+		current = make(map[string]int)
+		s.current[key] = current
+	}
+	total := 0
+	var best *Auth
+	for _, candidate := range available {
+		weight := authWeight(candidate)
+		total += weight
+		current[candidate.ID] += weight
+		if best == nil || current[candidate.ID] > current[best.ID] {
+			best = candidate
+		}
+	}
+	current[best.ID] -= total
+	// Drop state for auths that have left the pool so a returning auth starts fresh.
+	if len(current) > len(available) {
+		present := make(map[string]struct{}, len(available))
+		for _, candidate := range available {
+			present[candidate.ID] = struct{}{}
+		}
+		for id := range current {
+			if _, ok := present[id]; !ok {
+				delete(current, id)
+			}
+		}
+	}
+	return best, nil
+}
+
+// Pick selects the available auth with the fewest in-flight requests.
+func (s *LeastLoadedSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = opts
+	now := time.Now()
+	available, err := getAvailableAuths(auths, provider, model, now)
+	if err != nil {
+		return nil, err
+	}
+	key := provider + ":" + model
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cursors == nil {
+		s.cursors = make(map[string]int)
+	}
+	offset := s.cursors[key]
+	if offset >= 2_147_483_640 {
+		offset = 0
+	}
+	s.cursors[key] = offset + 1
+	var best *Auth
+	var bestLoad int64
+	for i := range available {
+		candidate := available[(offset+i)%len(available)]
+		var load int64
+		if s.load != nil {
+			load = s.load(candidate.ID)
+		}
+		if best == nil || load < bestLoad {
+			best, bestLoad = candidate, load
+		}
+	}
+	return best, nil
+}
+
+func (s *LeastLoadedSelector) bindLoad(load func(authID string) int64) {
+	s.mu.Lock()
+	s.load = load
+	s.mu.Unlock()
+}
+
+// authWeight reads the "weight" attribute; missing or non-positive values count as 1.
+func authWeight(auth *Auth) int {
+	if auth == nil || auth.Attributes == nil {
+		return 1
+	}
+	raw := strings.TrimSpace(auth.Attributes["weight"])
+	if raw == "" {
+		return 1
+	}
+	parsed, err := strconv.Atoi(raw)
+	if err != nil || parsed <= 0 {
+		return 1
+	}
+	return parsed
+}
+
 func isAuthBlockedForModel(auth *Auth, model string, now time.Time) (bool, blockReason, time.Time) {
 	if auth == nil {
 		return true, blockReasonOther, time.Time{}
@@ -221,6 +380,9 @@ func isAuthBlockedForModel(auth *Auth, model string, now time.Time) (bool, block
 	if auth.Disabled || auth.Status == StatusDisabled {
 		return true, blockReasonDisabled, time.Time{}
 	}
+	if auth.Health.Unhealthy {
+		return true, blockReasonUnhealthy, time.Time{}
+	}
 	if model != "" {
 		if len(auth.ModelStates) > 0 {
 			if state, ok := auth.ModelStates[model]; ok && state != nil {
@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -175,3 +176,124 @@ func TestRoundRobinSelectorPick_Concurrent(t *testing.T) {
 	default:
 	}
 }
+
+func TestWeightedSelectorPick_DistributionMatchesWeights(t *testing.T) {
+	t.Parallel()
+
+	selector := &WeightedSelector{}
+	auths := []*Auth{
+		{ID: "paid", Attributes: map[string]string{"weight": "3"}},
+		{ID: "free"},
+	}
+
+	counts := make(map[string]int)
+	const requests = 4000
+	for i := 0; i < requests; i++ {
+		got, err := selector.Pick(context.Background(), "claude", "sonnet", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() #%d error = %v", i, err)
+		}
+		counts[got.ID]++
+	}
+	if counts["paid"] != 3000 || counts["free"] != 1000 {
+		t.Fatalf("distribution = %v, want paid=3000 free=1000", counts)
+	}
+}
+
+func TestWeightedSelectorPick_SmoothSequence(t *testing.T) {
+	t.Parallel()
+
+	selector := &WeightedSelector{}
+	auths := []*Auth{
+		{ID: "a", Attributes: map[string]string{"weight": "5"}},
+		{ID: "b", Attributes: map[string]string{"weight": "1"}},
+		{ID: "c", Attributes: map[string]string{"weight": "1"}},
+	}
+
+	want := []string{"a", "a", "b", "a", "c", "a", "a"}
+	for i, id := range want {
+		got, err := selector.Pick(context.Background(), "claude", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() #%d error = %v", i, err)
+		}
+		if got.ID != id {
+			t.Fatalf("Pick() #%d auth.ID = %q, want %q", i, got.ID, id)
+		}
+	}
+}
+
+func TestWeightedSelectorPick_SkipsUnhealthyAndCooling(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	selector := &WeightedSelector{}
+	auths := []*Auth{
+		{ID: "heavy", Attributes: map[string]string{"weight": "10"}, Health: HealthState{Unhealthy: true}},
+		{ID: "cooling", Attributes: map[string]string{"weight": "10"}, Unavailable: true, NextRetryAfter: now.Add(time.Minute), Quota: QuotaState{Exceeded: true}},
+		{ID: "light"},
+	}
+
+	for i := 0; i < 10; i++ {
+		got, err := selector.Pick(context.Background(), "claude", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() #%d error = %v", i, err)
+		}
+		if got.ID != "light" {
+			t.Fatalf("Pick() #%d auth.ID = %q, want %q", i, got.ID, "light")
+		}
+	}
+}
+
+func TestLeastLoadedSelectorPick_PrefersFewestInFlight(t *testing.T) {
+	t.Parallel()
+
+	m := NewManager(nil, &LeastLoadedSelector{}, nil)
+	selector := m.selector
+	auths := []*Auth{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	releaseA := m.beginRequest("a")
+	releaseB1 := m.beginRequest("b")
+	releaseB2 := m.beginRequest("b")
+	defer releaseB1()
+	defer releaseB2()
+
+	got, err := selector.Pick(context.Background(), "codex", "", cliproxyexecutor.Options{}, auths)
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	if got.ID != "c" {
+		t.Fatalf("Pick() auth.ID = %q, want %q", got.ID, "c")
+	}
+
+	_ = m.beginRequest("c")
+	releaseA()
+	releaseA()
+	if m.InFlight("a") != 0 {
+		t.Fatalf("InFlight(a) = %d, want 0 after release", m.InFlight("a"))
+	}
+	got, err = selector.Pick(context.Background(), "codex", "", cliproxyexecutor.Options{}, auths)
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	if got.ID != "a" {
+		t.Fatalf("Pick() auth.ID = %q, want %q", got.ID, "a")
+	}
+}
+
+func TestNewSelectorForStrategy(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]Selector{
+		"":             &RoundRobinSelector{},
+		"fill-first":   &FillFirstSelector{},
+		"weighted":     &WeightedSelector{},
+		"least-loaded": &LeastLoadedSelector{},
+		"bogus":        &RoundRobinSelector{},
+	}
+	for strategy, want := range cases {
+		got := NewSelectorForStrategy(strategy)
+		if fmt.Sprintf("%T", got) != fmt.Sprintf("%T", want) {
+			t.Fatalf("NewSelectorForStrategy(%q) = %T, want %T", strategy, got, want)
+		}
+	}
+}
@@ -12,6 +12,8 @@ const (
 	StatusPending Status = "pending"
 	// StatusRefreshing indicates the auth is undergoing a refresh flow.
 	StatusRefreshing Status = "refreshing"
+	// StatusDegraded indicates the auth is still usable but its last background refresh failed.
+	StatusDegraded Status = "degraded"
 	// StatusError indicates the auth is temporarily unavailable due to errors.
 	StatusError Status = "error"
 	// StatusDisabled marks the auth as intentionally disabled.
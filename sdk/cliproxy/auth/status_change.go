@@ -0,0 +1,40 @@
+package auth
+
+import "context"
+
+// StatusChange describes an auth moving from one status to another.
+type StatusChange struct {
+	AuthID   string
+	Provider string
+	Old      Status
+	New      Status
+}
+
+// StatusChangeHandler observes status changes. It runs synchronously on the goroutine that
+// changed the status, often a request, so it must return quickly.
+type StatusChangeHandler func(ctx context.Context, change StatusChange)
+
+// SetStatusChangeHandler installs the handler called, outside the manager lock, whenever an
+// auth's Status changes. A nil handler removes it.
+func (m *Manager) SetStatusChangeHandler(handler StatusChangeHandler) {
+	if m == nil {
+		return
+	}
+	if handler == nil {
+		m.statusHandler.Store(nil)
+		return
+	}
+	m.statusHandler.Store(&handler)
+}
+
+// emitStatusChange calls the status handler when old and next differ.
+func (m *Manager) emitStatusChange(ctx context.Context, id, provider string, old, next Status) {
+	if old == next {
+		return
+	}
+	handler := m.statusHandler.Load()
+	if handler == nil {
+		return
+	}
+	(*handler)(ctx, StatusChange{AuthID: id, Provider: provider, Old: old, New: next})
+}
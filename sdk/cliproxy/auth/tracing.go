@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// pickNextMixedTraced runs pickNextMixed inside an account-selection span.
+func (m *Manager) pickNextMixedTraced(ctx context.Context, providers []string, model string, opts cliproxyexecutor.Options, tried map[string]struct{}) (*Auth, ProviderExecutor, string, error) {
+	_, span := tracing.Start(ctx, "auth.select", trace.SpanKindInternal)
+	auth, executor, provider, err := m.pickNextMixed(ctx, providers, model, opts, tried)
+	if span.IsRecording() {
+		span.SetAttributes(tracing.AttrModel.String(model), attribute.Int("cliproxy.auth.excluded", len(tried)))
+		if auth != nil {
+			span.SetAttributes(tracing.AttrProvider.String(provider), tracing.AttrAccountHash.String(auth.EnsureIndex()))
+		}
+	}
+	tracing.End(span, err)
+	return auth, executor, provider, err
+}
+
+// startAttemptSpan begins the span of one upstream attempt. Failover shows up as several
+// attempt spans under the same request, numbered from 1.
+func startAttemptSpan(ctx context.Context, attempt int, auth *Auth, provider, routeModel, upstreamModel string) (context.Context, trace.Span) {
+	ctx, span := tracing.Start(ctx, "upstream.attempt", trace.SpanKindClient)
+	if span.IsRecording() {
+		span.SetAttributes(
+			tracing.AttrAttempt.Int(attempt),
+			tracing.AttrProvider.String(provider),
+			tracing.AttrAccountHash.String(auth.EnsureIndex()),
+			tracing.AttrModel.String(routeModel),
+			tracing.AttrUpstreamModel.String(upstreamModel),
+		)
+	}
+	return ctx, span
+}
+
+// endSpanWithStatus ends span, recording err and the upstream HTTP status it carries.
+func endSpanWithStatus(span trace.Span, err error) {
+	if err != nil && span.IsRecording() {
+		var se cliproxyexecutor.StatusError
+		if errors.As(err, &se) && se != nil {
+			span.SetAttributes(tracing.AttrStatusCode.Int(se.StatusCode()))
+		}
+	}
+	tracing.End(span, err)
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type tracingTestStatusError struct{ code int }
+
+func (e tracingTestStatusError) Error() string   { return http.StatusText(e.code) }
+func (e tracingTestStatusError) StatusCode() int { return e.code }
+
+// tracingTestExecutor rejects the auth trace-limited with a 429 and serves the others.
+type tracingTestExecutor struct{ refreshTestExecutor }
+
+func (e *tracingTestExecutor) Execute(ctx context.Context, auth *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if auth.ID == "trace-limited" {
+		return cliproxyexecutor.Response{}, tracingTestStatusError{code: http.StatusTooManyRequests}
+	}
+	tracing.RecordUsage(ctx, 12, 34, 0, 0, 46)
+	return cliproxyexecutor.Response{Payload: []byte("{}")}, nil
+}
+
+func (e *tracingTestExecutor) ExecuteStream(ctx context.Context, auth *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	if auth.ID == "trace-limited" {
+		return nil, tracingTestStatusError{code: http.StatusTooManyRequests}
+	}
+	out := make(chan cliproxyexecutor.StreamChunk, 3)
+	out <- cliproxyexecutor.StreamChunk{Payload: []byte("a")}
+	out <- cliproxyexecutor.StreamChunk{Payload: []byte("b")}
+	tracing.RecordUsage(ctx, 1, 2, 0, 0, 3)
+	close(out)
+	return out, nil
+}
+
+func newTracingTestManager(t *testing.T) (*Manager, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	tracing.UseTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { tracing.UseTracerProvider(nil) })
+
+	m := NewManager(nil, &tracingFirstSelector{order: []string{"trace-limited", "trace-ok"}}, nil)
+	m.RegisterExecutor(&tracingTestExecutor{refreshTestExecutor{provider: "trace-test"}})
+	for _, id := range []string{"trace-limited", "trace-ok"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "trace-test"}); err != nil {
+			t.Fatalf("Register %s: %v", id, err)
+		}
+		registry.GetGlobalRegistry().RegisterClient(id, "trace-test", []*registry.ModelInfo{{ID: "gpt-5"}})
+		clientID := id
+		t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(clientID) })
+	}
+	return m, recorder
+}
+
+// tracingFirstSelector picks auths in a fixed order so the failover is deterministic.
+type tracingFirstSelector struct{ order []string }
+
+func (s *tracingFirstSelector) Pick(_ context.Context, _ string, _ string, _ cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	for _, id := range s.order {
+		for _, auth := range auths {
+			if auth.ID == id {
+				return auth, nil
+			}
+		}
+	}
+	return nil, &Error{Code: "auth_not_found", Message: "no auth available"}
+}
+
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestManagerExecuteTracesEachFailoverAttempt(t *testing.T) {
+	m, recorder := newTracingTestManager(t)
+	ctx, root := tracing.Start(context.Background(), "request", 0)
+	if _, err := m.Execute(ctx, []string{"trace-test"}, cliproxyexecutor.Request{Model: "gpt-5"}, cliproxyexecutor.Options{}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	root.End()
+
+	var attempts, selects []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "upstream.attempt":
+			attempts = append(attempts, span)
+		case "auth.select":
+			selects = append(selects, span)
+		}
+		if span.Name() != "request" && span.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Fatalf("span %s is not a child of the request span", span.Name())
+		}
+	}
+	if len(attempts) != 2 || len(selects) != 2 {
+		t.Fatalf("got %d attempt and %d selection spans, want 2 of each", len(attempts), len(selects))
+	}
+	failed, served := attempts[0], attempts[1]
+	if failed.Status().Code != codes.Error || spanAttr(failed, tracing.AttrStatusCode).AsInt64() != http.StatusTooManyRequests {
+		t.Fatalf("first attempt status = %v, code %v; want an error with 429", failed.Status(), spanAttr(failed, tracing.AttrStatusCode))
+	}
+	if served.Status().Code == codes.Error || spanAttr(served, tracing.AttrAttempt).AsInt64() != 2 {
+		t.Fatalf("second attempt should succeed as attempt 2, got %v and attempt %v", served.Status(), spanAttr(served, tracing.AttrAttempt))
+	}
+	if got := spanAttr(served, tracing.AttrTotalTokens).AsInt64(); got != 46 {
+		t.Fatalf("served attempt records %d total tokens, want 46", got)
+	}
+	if spanAttr(served, tracing.AttrModel).AsString() != "gpt-5" || spanAttr(served, tracing.AttrAccountHash).AsString() == "" {
+		t.Fatalf("served attempt lacks model or account hash: %v", served.Attributes())
+	}
+}
+
+func TestManagerExecuteStreamEndsSpansWhenStreamCompletes(t *testing.T) {
+	m, recorder := newTracingTestManager(t)
+	chunks, err := m.ExecuteStream(context.Background(), []string{"trace-test"}, cliproxyexecutor.Request{Model: "gpt-5"}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("ExecuteStream: %v", err)
+	}
+	for _, span := range recorder.Ended() {
+		if span.Name() == "upstream.stream" {
+			t.Fatal("stream span ended before the stream was read")
+		}
+	}
+	for range chunks {
+	}
+
+	var stream, attempt sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch {
+		case span.Name() == "upstream.stream":
+			stream = span
+		case span.Name() == "upstream.attempt" && span.Status().Code != codes.Error:
+			attempt = span
+		}
+	}
+	if stream == nil || attempt == nil {
+		t.Fatalf("missing stream or successful attempt span among %d spans", len(recorder.Ended()))
+	}
+	if stream.Parent().SpanID() != attempt.SpanContext().SpanID() {
+		t.Fatal("stream span is not a child of its upstream attempt")
+	}
+	if got := spanAttr(stream, "cliproxy.stream.chunks").AsInt64(); got != 2 {
+		t.Fatalf("stream span counted %d chunks, want 2", got)
+	}
+	if got := spanAttr(attempt, tracing.AttrTotalTokens).AsInt64(); got != 3 {
+		t.Fatalf("attempt records %d total tokens, want 3", got)
+	}
+}
+
+func TestManagerExecuteWithoutTracingCreatesNoSpans(t *testing.T) {
+	m, recorder := newTracingTestManager(t)
+	tracing.UseTracerProvider(nil)
+	if _, err := m.Execute(context.Background(), []string{"trace-test"}, cliproxyexecutor.Request{Model: "gpt-5"}, cliproxyexecutor.Options{}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := len(recorder.Ended()); got != 0 {
+		t.Fatalf("recorded %d spans with tracing disabled", got)
+	}
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// maxTransientBackoff caps the doubling delay between transient retries.
+const maxTransientBackoff = 5 * time.Second
+
+// TransientRetryCounts returns how many transient upstream failures were retried, per
+// provider, since the process started.
+func (m *Manager) TransientRetryCounts() map[string]int64 {
+	out := make(map[string]int64)
+	if m == nil {
+		return out
+	}
+	m.transientRetries.Range(func(key, value any) bool {
+		out[key.(string)] = value.(*atomic.Int64).Load()
+		return true
+	})
+	return out
+}
+
+// transientRetry tracks one request's transient-retry budget. A credential that failed
+// transiently is held back rather than marked failed, so other credentials are tried first;
+// once none is left the held ones are offered again after a backoff.
+type transientRetry struct {
+	m       *Manager
+	left    int
+	backoff time.Duration
+	retries int
+	held    []string
+}
+
+func (m *Manager) newTransientRetry() *transientRetry {
+	r := &transientRetry{m: m}
+	if cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config); cfg != nil {
+		r.left = cfg.TransientRetry.Retries()
+		r.backoff = cfg.TransientRetry.BackoffDuration()
+	}
+	return r
+}
+
+// hold reports whether the failure of auth is retried later. It is when the error is
+// transient and the budget is not used up; the caller then skips marking the result.
+func (r *transientRetry) hold(ctx context.Context, auth *Auth, provider string, err error) bool {
+	if r == nil || r.left <= 0 || !isTransientUpstreamError(err) {
+		return false
+	}
+	r.left--
+	r.retries++
+	r.held = append(r.held, auth.ID)
+	value, _ := r.m.transientRetries.LoadOrStore(provider, &atomic.Int64{})
+	value.(*atomic.Int64).Add(1)
+	logEntryWithRequestID(ctx).Warnf("transient upstream failure on %s account %s, retrying (%d left): %v", provider, auth.ID, r.left, err)
+	return true
+}
+
+// resume waits out the backoff and makes the held credentials selectable again. It returns
+// false when nothing is held.
+func (r *transientRetry) resume(ctx context.Context, tried map[string]struct{}) (bool, error) {
+	if r == nil || len(r.held) == 0 {
+		return false, nil
+	}
+	if err := waitForCooldown(ctx, r.delay()); err != nil {
+		return false, err
+	}
+	for _, id := range r.held {
+		delete(tried, id)
+	}
+	r.held = r.held[:0]
+	return true, nil
+}
+
+// delay doubles the base backoff per retry so far and picks a point in its upper half.
+func (r *transientRetry) delay() time.Duration {
+	d := r.backoff
+	for i := 1; i < r.retries && d < maxTransientBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxTransientBackoff)
+	return d/2 + rand.N(d/2+1)
+}
+
+// isTransientUpstreamError reports failures a retry on a fresh connection is likely to fix.
+func isTransientUpstreamError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	switch statusCodeFromError(err) {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, 529:
+		return true
+	case 0:
+	default:
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "GOAWAY") || strings.Contains(msg, "connection reset by peer") ||
+		strings.Contains(msg, "server closed idle connection")
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"syscall"
+	"testing"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// flakyExecutor fails each call with the next queued error and serves once the queue is empty.
+type flakyExecutor struct {
+	refreshTestExecutor
+	mu    sync.Mutex
+	errs  []error
+	calls []string
+}
+
+func (e *flakyExecutor) Execute(_ context.Context, auth *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = append(e.calls, auth.ID)
+	if len(e.errs) > 0 {
+		err := e.errs[0]
+		e.errs = e.errs[1:]
+		return cliproxyexecutor.Response{}, err
+	}
+	return cliproxyexecutor.Response{Payload: []byte("{}")}, nil
+}
+
+func newFlakyManager(t *testing.T, retries int, ids []string, errs ...error) (*Manager, *flakyExecutor) {
+	t.Helper()
+	m := NewManager(nil, &tracingFirstSelector{order: ids}, nil)
+	m.SetConfig(&internalconfig.Config{TransientRetry: internalconfig.TransientRetryConfig{MaxRetries: retries, Backoff: "1ms"}})
+	executor := &flakyExecutor{refreshTestExecutor: refreshTestExecutor{provider: "flaky"}, errs: errs}
+	m.RegisterExecutor(executor)
+	for _, id := range ids {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "flaky"}); err != nil {
+			t.Fatalf("Register %s: %v", id, err)
+		}
+		registry.GetGlobalRegistry().RegisterClient(id, "flaky", []*registry.ModelInfo{{ID: "flaky-model"}})
+		clientID := id
+		t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(clientID) })
+	}
+	return m, executor
+}
+
+func TestExecuteRetriesTransientFailures(t *testing.T) {
+	reset := fmt.Errorf("read tcp: %w", syscall.ECONNRESET)
+	m, executor := newFlakyManager(t, 2, []string{"flaky-a", "flaky-b"}, reset, tracingTestStatusError{code: http.StatusServiceUnavailable})
+	if _, err := m.Execute(context.Background(), []string{"flaky"}, cliproxyexecutor.Request{Model: "flaky-model"}, cliproxyexecutor.Options{}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	// The other account is tried first, then the first one again.
+	if want := []string{"flaky-a", "flaky-b", "flaky-a"}; fmt.Sprint(executor.calls) != fmt.Sprint(want) {
+		t.Fatalf("calls = %v, want %v", executor.calls, want)
+	}
+	if got := m.TransientRetryCounts()["flaky"]; got != 2 {
+		t.Fatalf("transient retries = %d, want 2", got)
+	}
+	for _, id := range []string{"flaky-a", "flaky-b"} {
+		if auth, _ := m.GetByID(id); auth.LastError != nil {
+			t.Fatalf("%s marked failed by a retried transient error: %+v", id, auth.LastError)
+		}
+	}
+}
+
+func TestExecuteDoesNotRetryOtherFailures(t *testing.T) {
+	m, executor := newFlakyManager(t, 2, []string{"flaky-only"}, tracingTestStatusError{code: http.StatusBadRequest})
+	if _, err := m.Execute(context.Background(), []string{"flaky"}, cliproxyexecutor.Request{Model: "flaky-model"}, cliproxyexecutor.Options{}); err == nil {
+		t.Fatal("Execute succeeded after a 400")
+	}
+	if len(executor.calls) != 1 || len(m.TransientRetryCounts()) != 0 {
+		t.Fatalf("calls = %v, retries = %v; want a single attempt", executor.calls, m.TransientRetryCounts())
+	}
+
+	m, executor = newFlakyManager(t, 1, []string{"flaky-only"}, tracingTestStatusError{code: http.StatusBadGateway}, tracingTestStatusError{code: http.StatusBadGateway})
+	if _, err := m.Execute(context.Background(), []string{"flaky"}, cliproxyexecutor.Request{Model: "flaky-model"}, cliproxyexecutor.Options{}); err == nil {
+		t.Fatal("Execute succeeded past the retry budget")
+	}
+	if len(executor.calls) != 2 {
+		t.Fatalf("calls = %v, want the original attempt and one retry", executor.calls)
+	}
+}
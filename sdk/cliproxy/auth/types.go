@@ -10,6 +10,7 @@ import (
 	"time"
 
 	baseauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 )
 
 // Auth encapsulates the runtime state and metadata associated with a single credential.
@@ -56,6 +57,10 @@ type Auth struct {
 	NextRefreshAfter time.Time `json:"next_refresh_after"`
 	// NextRetryAfter is the earliest time a retry should retrigger.
 	NextRetryAfter time.Time `json:"next_retry_after"`
+	// RefreshFailures counts consecutive background refresh failures for backoff.
+	RefreshFailures int `json:"refresh_failures,omitempty"`
+	// Health records background health probe results.
+	Health HealthState `json:"health"`
 	// ModelStates tracks per-model runtime availability data.
 	ModelStates map[string]*ModelState `json:"model_states,omitempty"`
 
@@ -180,6 +185,27 @@ func (m *ModelState) Clone() *ModelState {
 	return &copyState
 }
 
+// ApplyMetadataProxyURL loads the per-account proxy override from metadata key "proxy_url"
+// (or "proxy-url") into ProxyURL. A malformed URL or unsupported scheme disables the auth,
+// because falling back to the global proxy could send its traffic out the wrong way.
+func (a *Auth) ApplyMetadataProxyURL() error {
+	if a == nil || a.Metadata == nil {
+		return nil
+	}
+	raw, ok := a.Metadata["proxy_url"].(string)
+	if !ok {
+		raw, _ = a.Metadata["proxy-url"].(string)
+	}
+	a.ProxyURL = strings.TrimSpace(raw)
+	if err := util.ValidateProxyURL(a.ProxyURL); err != nil {
+		a.Disabled = true
+		a.Status = StatusDisabled
+		a.StatusMessage = "invalid proxy_url: " + err.Error()
+		return err
+	}
+	return nil
+}
+
 func (a *Auth) ProxyInfo() string {
 	if a == nil {
 		return ""
@@ -4,14 +4,17 @@
 package cliproxy
 
 import (
+	"context"
 	"fmt"
-	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/sirupsen/logrus"
 )
 
 // Builder constructs a Service instance with customizable providers.
@@ -33,8 +36,11 @@ type Builder struct {
 	// watcherFactory creates file watcher instances.
 	watcherFactory WatcherFactory
 
-	// hooks provides lifecycle callbacks.
-	hooks Hooks
+	// hooks provides lifecycle callbacks, in registration order.
+	hooks []Hooks
+
+	// usagePlugins receive usage records for the lifetime of the service.
+	usagePlugins []usage.Plugin
 
 	// authManager handles legacy authentication operations.
 	authManager *sdkAuth.Manager
@@ -47,11 +53,22 @@ type Builder struct {
 
 	// serverOptions contains additional server configuration options.
 	serverOptions []api.ServerOption
+
+	// logger receives the service's log output instead of the logrus standard logger.
+	logger *logrus.Logger
+
+	// noListener keeps Run from opening the HTTP listener.
+	noListener bool
 }
 
 // Hooks allows callers to plug into service lifecycle stages.
 // These callbacks provide opportunities to perform custom initialization
 // and cleanup operations during service startup and shutdown.
+//
+// Any field may be nil. When several Hooks are registered, each stage calls them in
+// registration order; a hook that panics is logged and skipped, so the remaining hooks and
+// the service itself carry on. Hooks that take a context get one with a deadline of
+// HookTimeout.
 type Hooks struct {
 	// OnBeforeStart is called before the service starts, allowing configuration
 	// modifications or additional setup.
@@ -60,6 +77,17 @@ type Hooks struct {
 	// OnAfterStart is called after the service has started successfully,
 	// providing access to the service instance for additional operations.
 	OnAfterStart func(*Service)
+
+	// OnBeforeStop is called when shutdown begins, while the HTTP listener is still open.
+	OnBeforeStop func(ctx context.Context, s *Service)
+
+	// OnConfigReload is called after a reloaded configuration has been applied.
+	OnConfigReload func(ctx context.Context, oldCfg, newCfg *config.Config)
+
+	// OnAccountStateChange is called when an account's status changes, for example from
+	// active to error after a failed request. It runs on the goroutine that changed the
+	// status, often a request, so it must return quickly.
+	OnAccountStateChange func(ctx context.Context, accountID string, oldState, newState coreauth.Status)
 }
 
 // NewBuilder creates a Builder with default dependencies left unset.
@@ -113,9 +141,24 @@ func (b *Builder) WithWatcherFactory(factory WatcherFactory) *Builder {
 	return b
 }
 
-// WithHooks registers lifecycle hooks executed around service startup.
+// WithHooks registers lifecycle hooks. It may be called more than once; every registered
+// set is kept and called in registration order.
 func (b *Builder) WithHooks(h Hooks) *Builder {
-	b.hooks = h
+	b.hooks = append(b.hooks, h)
+	return b
+}
+
+// WithUsagePlugins attaches usage plugins to the service. They are registered and started,
+// in the given order, after the OnAfterStart hooks; on shutdown they are stopped in reverse
+// order once every queued record has been delivered. Plugins implementing
+// usage.PluginStarter or usage.PluginStopper get those calls; the others only receive
+// records. It may be called more than once.
+func (b *Builder) WithUsagePlugins(plugins ...usage.Plugin) *Builder {
+	for _, plugin := range plugins {
+		if plugin != nil {
+			b.usagePlugins = append(b.usagePlugins, plugin)
+		}
+	}
 	return b
 }
 
@@ -152,6 +195,22 @@ func (b *Builder) WithLocalManagementPassword(password string) *Builder {
 	return b
 }
 
+// WithLogger sends everything the proxy logs to logger instead of the logrus standard
+// logger. The logger's level, formatter and output are respected as given: the service
+// neither applies the configured debug level or log output to it nor changes the standard
+// logger. The logger is process-wide and takes effect when Build is called.
+func (b *Builder) WithLogger(logger *logrus.Logger) *Builder {
+	b.logger = logger
+	return b
+}
+
+// WithoutListener makes Run serve requests only through Service.Handler and
+// Service.RoundTripper, without opening the configured host and port.
+func (b *Builder) WithoutListener() *Builder {
+	b.noListener = true
+	return b
+}
+
 // Build validates inputs, applies defaults, and returns a ready-to-run service.
 func (b *Builder) Build() (*Service, error) {
 	if b.cfg == nil {
@@ -160,6 +219,9 @@ func (b *Builder) Build() (*Service, error) {
 	if b.configPath == "" {
 		return nil, fmt.Errorf("cliproxy: configuration path is required")
 	}
+	if b.logger != nil {
+		log.SetLogger(b.logger)
+	}
 
 	tokenProvider := b.tokenProvider
 	if tokenProvider == nil {
@@ -198,18 +260,15 @@ func (b *Builder) Build() (*Service, error) {
 		if dirSetter, ok := tokenStore.(interface{ SetBaseDir(string) }); ok && b.cfg != nil {
 			dirSetter.SetBaseDir(b.cfg.AuthDir)
 		}
+		if dirsSetter, ok := tokenStore.(interface{ SetAuthDirs([]string) }); ok && b.cfg != nil {
+			dirsSetter.SetAuthDirs(b.cfg.AuthDirectories())
+		}
 
 		strategy := ""
 		if b.cfg != nil {
-			strategy = strings.ToLower(strings.TrimSpace(b.cfg.Routing.Strategy))
-		}
-		var selector coreauth.Selector
-		switch strategy {
-		case "fill-first", "fillfirst", "ff":
-			selector = &coreauth.FillFirstSelector{}
-		default:
-			selector = &coreauth.RoundRobinSelector{}
+			strategy = b.cfg.Routing.Strategy
 		}
+		selector := coreauth.NewSelectorForStrategy(strategy)
 
 		coreManager = coreauth.NewManager(tokenStore, selector, nil)
 	}
@@ -225,10 +284,12 @@ func (b *Builder) Build() (*Service, error) {
 		apiKeyProvider: apiKeyProvider,
 		watcherFactory: watcherFactory,
 		hooks:          b.hooks,
+		usagePlugins:   b.usagePlugins,
 		authManager:    authManager,
 		accessManager:  accessManager,
 		coreManager:    coreManager,
 		serverOptions:  append([]api.ServerOption(nil), b.serverOptions...),
+		noListener:     b.noListener,
 	}
 	return service, nil
 }
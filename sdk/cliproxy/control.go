@@ -0,0 +1,169 @@
+package cliproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+var (
+	// ErrServiceNotStarted is returned by Reload until Run has finished starting the service.
+	ErrServiceNotStarted = errors.New("cliproxy: service has not started")
+	// ErrServiceStopped is returned by Reload once shutdown has begun.
+	ErrServiceStopped = errors.New("cliproxy: service has stopped")
+)
+
+// ServiceState is the lifecycle stage reported by Status.
+type ServiceState string
+
+const (
+	// StateNotStarted means Run has not been called.
+	StateNotStarted ServiceState = "not-started"
+	// StateStarting means Run is bringing the service up.
+	StateStarting ServiceState = "starting"
+	// StateRunning means the service is serving requests.
+	StateRunning ServiceState = "running"
+	// StateStopped means shutdown has begun or finished.
+	StateStopped ServiceState = "stopped"
+)
+
+const (
+	stateNotStarted int32 = iota
+	stateStarting
+	stateRunning
+	stateStopped
+)
+
+var serviceStates = [...]ServiceState{StateNotStarted, StateStarting, StateRunning, StateStopped}
+
+// Status is a point-in-time view of a service. Only State is set unless the service is
+// running.
+type Status struct {
+	State ServiceState
+
+	// Address is the host:port the HTTP server listens on; it is empty for a service
+	// built WithoutListener.
+	Address string
+
+	// StartedAt is when the service finished starting; Uptime is the time since.
+	StartedAt time.Time
+	Uptime    time.Duration
+
+	// Accounts counts the loaded accounts per provider.
+	Accounts map[string]AccountCounts
+
+	// Usage holds the request totals recorded so far.
+	Usage UsageTotals
+}
+
+// AccountCounts counts the accounts of one provider.
+type AccountCounts struct {
+	// Total counts every loaded account, disabled ones included.
+	Total int
+	// Active counts the enabled accounts whose status is active.
+	Active int
+}
+
+// UsageTotals are the service-wide usage counters.
+type UsageTotals struct {
+	Requests  int64
+	Successes int64
+	Failures  int64
+	Tokens    int64
+	CacheHits int64
+}
+
+// Reload applies cfg the way a change to the config file would: cfg is normalized as if it
+// had been loaded from a file, a plaintext management key is hashed, and a config that fails
+// validation is rejected with the current one kept. Routing, quotas, clients and the accounts
+// derived from the configuration are then updated and the OnConfigReload hooks run before
+// it returns. The service keeps cfg, which must not be modified afterwards; the config file
+// is not rewritten. Reloads are applied one at a time.
+func (s *Service) Reload(ctx context.Context, cfg *config.Config) error {
+	if s == nil {
+		return errors.New("cliproxy: service is nil")
+	}
+	if cfg == nil {
+		return errors.New("cliproxy: reload configuration is nil")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := cfg.Normalize(); err != nil {
+		return fmt.Errorf("cliproxy: invalid reload configuration: %w", err)
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	switch s.state.Load() {
+	case stateRunning:
+	case stateStopped:
+		return ErrServiceStopped
+	default:
+		return ErrServiceNotStarted
+	}
+
+	if !s.watcher.ApplyConfig(cfg) {
+		// A custom watcher that cannot reload still gets the new config, but accounts
+		// derived from it are only refreshed by that watcher's next reload.
+		s.watcher.SetConfig(cfg)
+		s.reload(cfg)
+	}
+	return nil
+}
+
+// Status reports the service's state and, while it is running, its listener address,
+// accounts, usage totals and uptime. It is safe to call at any time.
+func (s *Service) Status() Status {
+	if s == nil {
+		return Status{State: StateNotStarted}
+	}
+	state := s.state.Load()
+	status := Status{State: serviceStates[state]}
+	if state != stateRunning {
+		return status
+	}
+
+	s.cfgMu.RLock()
+	cfg := s.cfg
+	s.cfgMu.RUnlock()
+	if cfg != nil && !s.noListener {
+		status.Address = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	}
+	status.StartedAt = s.startedAt
+	status.Uptime = time.Since(s.startedAt)
+
+	status.Accounts = make(map[string]AccountCounts)
+	if s.coreManager != nil {
+		for _, auth := range s.coreManager.List() {
+			counts := status.Accounts[auth.Provider]
+			counts.Total++
+			if !auth.Disabled && auth.Status == coreauth.StatusActive {
+				counts.Active++
+			}
+			status.Accounts[auth.Provider] = counts
+		}
+	}
+
+	if stats := internalusage.GetRequestStatistics(); stats != nil {
+		snapshot := stats.Snapshot()
+		status.Usage = UsageTotals{
+			Requests:  snapshot.TotalRequests,
+			Successes: snapshot.SuccessCount,
+			Failures:  snapshot.FailureCount,
+			Tokens:    snapshot.TotalTokens,
+			CacheHits: snapshot.CacheHits,
+		}
+	}
+	return status
+}
@@ -0,0 +1,110 @@
+package cliproxy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestReloadFollowsLifecycle(t *testing.T) {
+	var applied, reloaded, set *config.Config
+	s := &Service{
+		cfg:     &config.Config{Port: 8317},
+		watcher: &WatcherWrapper{applyConfig: func(cfg *config.Config) { applied = cfg }},
+		reload:  func(cfg *config.Config) { reloaded = cfg },
+	}
+	ctx := context.Background()
+	next := &config.Config{Port: 9000}
+
+	if err := s.Reload(ctx, next); !errors.Is(err, ErrServiceNotStarted) {
+		t.Fatalf("Reload before start = %v, want ErrServiceNotStarted", err)
+	}
+	s.state.Store(stateRunning)
+	if err := s.Reload(ctx, nil); err == nil {
+		t.Fatal("Reload(nil) succeeded")
+	}
+	if err := s.Reload(ctx, next); err != nil || applied != next || reloaded != nil {
+		t.Fatalf("Reload = %v (applied %p, reloaded %p), want it applied through the watcher", err, applied, reloaded)
+	}
+
+	// A watcher that cannot reload gets the config and the service reloads itself.
+	s.watcher = &WatcherWrapper{setConfig: func(cfg *config.Config) { set = cfg }}
+	if err := s.Reload(ctx, next); err != nil || set != next || reloaded != next {
+		t.Fatalf("fallback Reload = %v (set %p, reloaded %p), want both %p", err, set, reloaded, next)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := s.Reload(cancelled, next); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Reload with cancelled context = %v, want context.Canceled", err)
+	}
+	s.state.Store(stateStopped)
+	if err := s.Reload(ctx, next); !errors.Is(err, ErrServiceStopped) {
+		t.Fatalf("Reload after stop = %v, want ErrServiceStopped", err)
+	}
+}
+
+func TestReloadNormalizesAndValidates(t *testing.T) {
+	var applied *config.Config
+	s := &Service{watcher: &WatcherWrapper{applyConfig: func(cfg *config.Config) { applied = cfg }}}
+	s.state.Store(stateRunning)
+	ctx := context.Background()
+
+	invalid := &config.Config{Port: 9000}
+	invalid.HealthCheck.FailureThreshold = -1
+	if err := s.Reload(ctx, invalid); err == nil || applied != nil {
+		t.Fatalf("Reload of an invalid config = %v (applied %p), want it rejected", err, applied)
+	}
+
+	next := &config.Config{Port: 9000}
+	next.RemoteManagement.SecretKey = "plain-secret"
+	next.OAuthExcludedModels = map[string][]string{" Claude ": {" claude-3 "}}
+	if err := s.Reload(ctx, next); err != nil || applied != next {
+		t.Fatalf("Reload = %v, want it applied", err)
+	}
+	if !strings.HasPrefix(next.RemoteManagement.SecretKey, "$2") {
+		t.Fatalf("secret key was not hashed: %q", next.RemoteManagement.SecretKey)
+	}
+	if got := next.OAuthExcludedModels["claude"]; len(got) != 1 || got[0] != "claude-3" {
+		t.Fatalf("excluded models = %v, want them normalized", next.OAuthExcludedModels)
+	}
+}
+
+func TestStatusReportsRunningService(t *testing.T) {
+	manager := coreauth.NewManager(nil, nil, nil)
+	ctx := context.Background()
+	for _, auth := range []*coreauth.Auth{
+		{ID: "c1", Provider: "claude", Status: coreauth.StatusActive},
+		{ID: "c2", Provider: "claude", Status: coreauth.StatusActive, Disabled: true},
+		{ID: "g1", Provider: "gemini", Status: coreauth.StatusError},
+	} {
+		if _, err := manager.Register(ctx, auth); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+	s := &Service{cfg: &config.Config{Host: "127.0.0.1", Port: 8317}, coreManager: manager}
+
+	if got := s.Status(); got.State != StateNotStarted || got.Accounts != nil {
+		t.Fatalf("Status before start = %+v, want only the not-started state", got)
+	}
+
+	s.startedAt = time.Now().Add(-time.Minute)
+	s.state.Store(stateRunning)
+	got := s.Status()
+	if got.State != StateRunning || got.Address != "127.0.0.1:8317" || got.Uptime < time.Minute {
+		t.Fatalf("Status = %+v", got)
+	}
+	if got.Accounts["claude"] != (AccountCounts{Total: 2, Active: 1}) || got.Accounts["gemini"] != (AccountCounts{Total: 1}) {
+		t.Fatalf("Accounts = %+v", got.Accounts)
+	}
+
+	s.state.Store(stateStopped)
+	if got := s.Status(); got.State != StateStopped || got.Address != "" {
+		t.Fatalf("Status after stop = %+v, want only the stopped state", got)
+	}
+}
@@ -0,0 +1,87 @@
+package cliproxy
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// HookTimeout bounds the context handed to each hook that takes one.
+const HookTimeout = 10 * time.Second
+
+// runHooks calls call once per registered Hooks, in registration order. Each call gets its
+// own context, derived from parent and bounded by HookTimeout, and a panic ends only that
+// call.
+func (s *Service) runHooks(parent context.Context, stage string, call func(ctx context.Context, h Hooks)) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	for i, h := range s.hooks {
+		func() {
+			ctx, cancel := context.WithTimeout(parent, HookTimeout)
+			defer cancel()
+			defer func() {
+				if r := recover(); r != nil {
+					log.Errorf("cliproxy: %s hook %d panicked: %v\n%s", stage, i, r, debug.Stack())
+				}
+			}()
+			call(ctx, h)
+		}()
+	}
+}
+
+func (s *Service) hookBeforeStart(cfg *config.Config) {
+	s.runHooks(context.Background(), "OnBeforeStart", func(_ context.Context, h Hooks) {
+		if h.OnBeforeStart != nil {
+			h.OnBeforeStart(cfg)
+		}
+	})
+}
+
+func (s *Service) hookAfterStart() {
+	s.runHooks(context.Background(), "OnAfterStart", func(_ context.Context, h Hooks) {
+		if h.OnAfterStart != nil {
+			h.OnAfterStart(s)
+		}
+	})
+}
+
+func (s *Service) hookBeforeStop(ctx context.Context) {
+	s.runHooks(ctx, "OnBeforeStop", func(ctx context.Context, h Hooks) {
+		if h.OnBeforeStop != nil {
+			h.OnBeforeStop(ctx, s)
+		}
+	})
+}
+
+func (s *Service) hookConfigReload(oldCfg, newCfg *config.Config) {
+	s.runHooks(context.Background(), "OnConfigReload", func(ctx context.Context, h Hooks) {
+		if h.OnConfigReload != nil {
+			h.OnConfigReload(ctx, oldCfg, newCfg)
+		}
+	})
+}
+
+// hookAccountStateChange forwards auth status changes from the core manager. The request
+// context is not inherited: a state change outlives the request that caused it.
+func (s *Service) hookAccountStateChange(_ context.Context, change coreauth.StatusChange) {
+	s.runHooks(context.Background(), "OnAccountStateChange", func(ctx context.Context, h Hooks) {
+		if h.OnAccountStateChange != nil {
+			h.OnAccountStateChange(ctx, change.AuthID, change.Old, change.New)
+		}
+	})
+}
+
+// hasAccountStateHooks reports whether any registered Hooks observes account state.
+func (s *Service) hasAccountStateHooks() bool {
+	for _, h := range s.hooks {
+		if h.OnAccountStateChange != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package cliproxy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestHooksRunInOrderAndSurvivePanics(t *testing.T) {
+	var calls []string
+	stopHook := func(name string, panics bool) Hooks {
+		return Hooks{OnBeforeStop: func(ctx context.Context, _ *Service) {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Errorf("%s: context without deadline", name)
+			}
+			calls = append(calls, name)
+			if panics {
+				panic("boom")
+			}
+		}}
+	}
+	builder := NewBuilder().
+		WithHooks(stopHook("first", false)).
+		WithHooks(stopHook("second", true)).
+		WithHooks(Hooks{}).
+		WithHooks(stopHook("third", false))
+	s := &Service{hooks: builder.hooks}
+
+	s.hookBeforeStop(context.Background())
+	if got := strings.Join(calls, ","); got != "first,second,third" {
+		t.Fatalf("OnBeforeStop calls = %s, want first,second,third", got)
+	}
+}
+
+func TestAccountStateChangeHook(t *testing.T) {
+	type change struct {
+		id       string
+		old, new coreauth.Status
+	}
+	var changes []change
+	s := &Service{hooks: []Hooks{{OnAccountStateChange: func(_ context.Context, id string, oldState, newState coreauth.Status) {
+		changes = append(changes, change{id, oldState, newState})
+	}}}}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.SetStatusChangeHandler(s.hookAccountStateChange)
+
+	ctx := context.Background()
+	if _, err := manager.Register(ctx, &coreauth.Auth{ID: "a1", Provider: "claude", Status: coreauth.StatusActive}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	manager.MarkResult(ctx, coreauth.Result{AuthID: "a1", Provider: "claude", Success: false, Error: &coreauth.Error{HTTPStatus: 500, Message: "boom"}})
+	// A second failure keeps the error state and reports nothing.
+	manager.MarkResult(ctx, coreauth.Result{AuthID: "a1", Provider: "claude", Success: false, Error: &coreauth.Error{HTTPStatus: 500, Message: "boom"}})
+	manager.MarkResult(ctx, coreauth.Result{AuthID: "a1", Provider: "claude", Success: true})
+
+	want := []change{{"a1", coreauth.StatusActive, coreauth.StatusError}, {"a1", coreauth.StatusError, coreauth.StatusActive}}
+	if len(changes) != len(want) {
+		t.Fatalf("state changes = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("state changes = %v, want %v", changes, want)
+		}
+	}
+}
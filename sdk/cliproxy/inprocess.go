@@ -0,0 +1,168 @@
+package cliproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// inProcessRemoteAddr is the peer address in-process requests carry. They come from the
+// embedding program itself, so they are treated as loopback traffic.
+const inProcessRemoteAddr = "127.0.0.1:0"
+
+// Handler returns the proxy's fully configured router: API key authentication, request
+// translation, routing and usage accounting all apply, exactly as for requests arriving
+// on the listener. Streaming responses are flushed as they are produced. Until Run has
+// started the service, and once shutdown has begun, it answers 503.
+func (s *Service) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s == nil || s.state.Load() != stateRunning {
+			writeServiceUnavailable(w)
+			return
+		}
+		s.server.Handler().ServeHTTP(w, r)
+	})
+}
+
+// RoundTripper returns a transport that dispatches requests straight to Handler, so an
+// http.Client or an SDK client built on one can talk to the proxy without a network hop.
+// The scheme and host of request URLs are ignored. Closing a response body cancels the
+// request, as a client disconnecting would.
+func (s *Service) RoundTripper() http.RoundTripper {
+	return &inProcessTransport{handler: s.Handler()}
+}
+
+func writeServiceUnavailable(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_ = json.NewEncoder(w).Encode(handlers.ErrorResponse{Error: handlers.ErrorDetail{
+		Message: "proxy service is not running",
+		Type:    "server_error",
+	}})
+}
+
+// inProcessTransport serves each request by running handler on its own goroutine and
+// piping the response body back to the caller.
+type inProcessTransport struct {
+	handler http.Handler
+}
+
+// RoundTrip implements http.RoundTripper. It returns once the handler has written the
+// response header, flushed, or finished.
+func (t *inProcessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	inner := req.Clone(ctx)
+	inner.RequestURI = req.URL.RequestURI()
+	if inner.Host == "" {
+		inner.Host = req.URL.Host
+	}
+	inner.RemoteAddr = inProcessRemoteAddr
+	if inner.Body == nil {
+		inner.Body = http.NoBody
+	}
+
+	reader, writer := io.Pipe()
+	w := &pipeResponseWriter{header: make(http.Header), body: writer, ready: make(chan struct{})}
+	go func() {
+		defer func() {
+			_ = inner.Body.Close()
+			if r := recover(); r != nil {
+				err := fmt.Errorf("cliproxy: in-process handler panicked: %v", r)
+				w.fail(err)
+				_ = writer.CloseWithError(err)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			_ = writer.Close()
+		}()
+		t.handler.ServeHTTP(w, inner)
+	}()
+
+	select {
+	case <-w.ready:
+	case <-ctx.Done():
+		cancel()
+		_ = reader.CloseWithError(ctx.Err())
+		return nil, ctx.Err()
+	}
+	if w.err != nil {
+		cancel()
+		return nil, w.err
+	}
+
+	contentLength := int64(-1)
+	if value := w.sent.Get("Content-Length"); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			contentLength = n
+		}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.status, http.StatusText(w.status)),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.sent,
+		Body:          &inProcessBody{PipeReader: reader, cancel: cancel},
+		ContentLength: contentLength,
+		Request:       req,
+	}, nil
+}
+
+// pipeResponseWriter is the http.ResponseWriter in-process requests are served with.
+// The header is handed over on the first WriteHeader, Write or Flush; the body is
+// streamed through a pipe, so every write waits for the caller to read it.
+type pipeResponseWriter struct {
+	header http.Header
+	body   *io.PipeWriter
+
+	once   sync.Once
+	ready  chan struct{}
+	status int
+	sent   http.Header
+	err    error
+}
+
+func (w *pipeResponseWriter) Header() http.Header { return w.header }
+
+func (w *pipeResponseWriter) WriteHeader(status int) {
+	w.once.Do(func() {
+		w.status = status
+		w.sent = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+func (w *pipeResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// Flush implements http.Flusher. Writes already reach the caller unbuffered, so it only
+// makes sure the header has been sent.
+func (w *pipeResponseWriter) Flush() { w.WriteHeader(http.StatusOK) }
+
+// fail ends the request with err if no header has been sent yet.
+func (w *pipeResponseWriter) fail(err error) {
+	w.once.Do(func() {
+		w.err = err
+		close(w.ready)
+	})
+}
+
+// inProcessBody cancels the in-process request when the caller closes the body.
+type inProcessBody struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (b *inProcessBody) Close() error {
+	b.cancel()
+	return b.PipeReader.Close()
+}
@@ -0,0 +1,101 @@
+package cliproxy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestInProcessTransportStreamsAndCancels(t *testing.T) {
+	release := make(chan struct{})
+	cancelled := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.RemoteAddr != inProcessRemoteAddr {
+			t.Errorf("request path %q from %q", r.URL.Path, r.RemoteAddr)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: first\n")
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = io.WriteString(w, "data: second\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(cancelled)
+	})
+	client := &http.Client{Transport: &inProcessTransport{handler: handler}}
+
+	resp, err := client.Post("http://proxy.invalid/v1/chat/completions", "application/json", nil)
+	if err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("response %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	lines := bufio.NewReader(resp.Body)
+	// The first event arrives while the handler is still blocked.
+	if line, _ := lines.ReadString('\n'); line != "data: first\n" {
+		t.Fatalf("first line = %q", line)
+	}
+	close(release)
+	if line, _ := lines.ReadString('\n'); line != "data: second\n" {
+		t.Fatalf("second line = %q", line)
+	}
+	_ = resp.Body.Close()
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closing the body did not cancel the request")
+	}
+}
+
+func TestServiceHandlerServesConfiguredRouter(t *testing.T) {
+	configaccess.Register()
+	cfg := &config.Config{SDKConfig: config.SDKConfig{APIKeys: []string{"in-process-key"}}}
+	providers, err := sdkaccess.BuildProviders(&cfg.SDKConfig)
+	if err != nil {
+		t.Fatalf("BuildProviders: %v", err)
+	}
+	accessManager := sdkaccess.NewManager()
+	accessManager.SetProviders(providers)
+	s := &Service{cfg: cfg, noListener: true}
+	s.server = api.NewServer(cfg, coreauth.NewManager(nil, nil, nil), accessManager, filepath.Join(t.TempDir(), "config.yaml"))
+	client := &http.Client{Transport: s.RoundTripper()}
+
+	get := func(key string) int {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://proxy.invalid/v1/models", nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		resp, errDo := client.Do(req)
+		if errDo != nil {
+			t.Fatalf("GET /v1/models: %v", errDo)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := get("in-process-key"); got != http.StatusServiceUnavailable {
+		t.Fatalf("before start: status %d, want 503", got)
+	}
+	s.state.Store(stateRunning)
+	if got := get(""); got != http.StatusUnauthorized {
+		t.Fatalf("without key: status %d, want 401", got)
+	}
+	if got := get("in-process-key"); got != http.StatusOK {
+		t.Fatalf("with key: status %d, want 200", got)
+	}
+	if addr := s.Status().Address; addr != "" {
+		t.Fatalf("Status().Address = %q, want empty without a listener", addr)
+	}
+}
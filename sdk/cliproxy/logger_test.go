@@ -0,0 +1,68 @@
+package cliproxy
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestWithLoggerCapturesInternalLogging(t *testing.T) {
+	std := logrus.StandardLogger()
+	stdLevel := std.GetLevel()
+	global := test.NewLocal(std)
+	t.Cleanup(func() {
+		std.ReplaceHooks(make(logrus.LevelHooks))
+		log.SetLogger(nil)
+	})
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetLevel(logrus.WarnLevel)
+	logger.SetReportCaller(true)
+	captured := test.NewLocal(logger)
+
+	dir := t.TempDir()
+	cfg := &config.Config{Debug: true}
+	if _, err := NewBuilder().WithConfig(cfg).WithConfigPath(filepath.Join(dir, "config.yaml")).WithLogger(logger).Build(); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	// Applying the debug setting must leave both loggers' levels alone.
+	util.SetLogLevel(cfg)
+	path := filepath.Join(dir, "usage.json")
+	plugin := usage.NewFileUsagePlugin(path, 0, usage.NewRequestStatistics())
+	for _, content := range []string{`{"version":1}`, "not json"} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := plugin.Load(); err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+	}
+
+	if got := logger.GetLevel(); got != logrus.WarnLevel {
+		t.Fatalf("injected logger level = %s, want warning", got)
+	}
+	if got := std.GetLevel(); got != stdLevel {
+		t.Fatalf("standard logger level = %s, want %s", got, stdLevel)
+	}
+	entries := captured.AllEntries()
+	if len(entries) != 1 || !strings.Contains(entries[0].Message, "is unreadable") {
+		t.Fatalf("captured entries = %v, want only the corrupt-file warning", entries)
+	}
+	if caller := entries[0].Caller; caller == nil || filepath.Base(caller.File) != "file_plugin.go" {
+		t.Fatalf("caller = %+v, want file_plugin.go", caller)
+	}
+	if escaped := global.AllEntries(); len(escaped) != 0 {
+		t.Fatalf("%d entries escaped to the standard logger, first: %q", len(escaped), escaped[0].Message)
+	}
+}
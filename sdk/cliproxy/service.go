@@ -10,12 +10,14 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
-	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/wsrelay"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -23,7 +25,6 @@ import (
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
-	log "github.com/sirupsen/logrus"
 )
 
 // Service wraps the proxy server lifecycle so external programs can embed the CLI proxy.
@@ -48,8 +49,13 @@ type Service struct {
 	// watcherFactory creates file watcher instances.
 	watcherFactory WatcherFactory
 
-	// hooks provides lifecycle callbacks.
-	hooks Hooks
+	// hooks provides lifecycle callbacks, in registration order.
+	hooks []Hooks
+
+	// usagePlugins are the plugins attached with Builder.WithUsagePlugins; they are
+	// stopped on shutdown only if Run got as far as starting them.
+	usagePlugins        []usage.Plugin
+	usagePluginsStarted bool
 
 	// serverOptions contains additional server configuration options.
 	serverOptions []api.ServerOption
@@ -57,6 +63,9 @@ type Service struct {
 	// server is the HTTP API server instance.
 	server *api.Server
 
+	// noListener keeps the server from listening; requests arrive only through Handler.
+	noListener bool
+
 	// serverErr channel for server startup/shutdown errors.
 	serverErr chan error
 
@@ -84,6 +93,18 @@ type Service struct {
 	// shutdownOnce ensures shutdown is called only once.
 	shutdownOnce sync.Once
 
+	// state is the lifecycle stage reported by Status and checked by Reload.
+	state atomic.Int32
+
+	// startedAt is when Run finished starting the service.
+	startedAt time.Time
+
+	// reload applies a new configuration; it is the callback handed to the watcher.
+	reload func(*config.Config)
+
+	// reloadMu serialises Reload calls.
+	reloadMu sync.Mutex
+
 	// wsGateway manages websocket Gemini providers.
 	wsGateway *wsrelay.Manager
 }
@@ -97,6 +118,34 @@ func (s *Service) RegisterUsagePlugin(plugin usage.Plugin) {
 	usage.RegisterPlugin(plugin)
 }
 
+// startUsagePlugins starts and registers the plugins attached with WithUsagePlugins.
+func (s *Service) startUsagePlugins() {
+	s.usagePluginsStarted = true
+	for _, plugin := range s.usagePlugins {
+		if starter, ok := plugin.(usage.PluginStarter); ok {
+			starter.Start()
+		}
+		usage.RegisterPlugin(plugin)
+	}
+}
+
+// stopUsagePlugins stops the attached plugins in reverse order. The dispatcher has been
+// stopped first, so they have seen every record.
+func (s *Service) stopUsagePlugins() {
+	if !s.usagePluginsStarted {
+		return
+	}
+	for i := len(s.usagePlugins) - 1; i >= 0; i-- {
+		stopper, ok := s.usagePlugins[i].(usage.PluginStopper)
+		if !ok {
+			continue
+		}
+		if err := stopper.Stop(); err != nil {
+			log.Errorf("usage plugin %T failed to stop: %v", s.usagePlugins[i], err)
+		}
+	}
+}
+
 // newDefaultAuthManager creates a default authentication manager with all supported providers.
 func newDefaultAuthManager() *sdkAuth.Manager {
 	return sdkAuth.NewManager(
@@ -183,6 +232,8 @@ func (s *Service) handleAuthUpdate(ctx context.Context, update watcher.AuthUpdat
 		if update.Auth == nil || update.Auth.ID == "" {
 			return
 		}
+		// A reloaded credential may see a different model list upstream.
+		registry.UpstreamModelCache().Invalidate(update.Auth.ID)
 		s.applyCoreAuthAddOrUpdate(ctx, update.Auth)
 	case watcher.AuthUpdateActionDelete:
 		id := update.ID
@@ -301,6 +352,7 @@ func (s *Service) applyCoreAuthRemoval(ctx context.Context, id string) {
 		return
 	}
 	GlobalModelRegistry().UnregisterClient(id)
+	registry.UpstreamModelCache().Invalidate(id)
 	if existing, ok := s.coreManager.GetByID(id); ok && existing != nil {
 		existing.Disabled = true
 		existing.Status = coreauth.StatusDisabled
@@ -318,6 +370,29 @@ func (s *Service) applyRetryConfig(cfg *config.Config) {
 	s.coreManager.SetRetryConfig(cfg.RequestRetry, maxInterval)
 }
 
+// applyDailyQuotas pushes the configured daily limits to the quota tracker and lets the
+// core manager skip accounts that are about to run out.
+func (s *Service) applyDailyQuotas(cfg *config.Config) {
+	if s == nil || s.coreManager == nil || cfg == nil {
+		return
+	}
+	tracker := internalusage.GetQuotaTracker()
+	tracker.SetLimits(cfg.Routing.DailyQuotas)
+	s.coreManager.SetQuotaGuard(tracker)
+}
+
+// applyKeyQuotas pushes the per-client-key quotas, the global-quota and the model pricing their
+// cost caps use to the key quota tracker.
+func applyKeyQuotas(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	tracker := internalusage.GetKeyQuotaTracker()
+	tracker.SetPricing(cfg.PriceFor)
+	tracker.SetGlobalLimit(cfg.GlobalQuota)
+	tracker.SetLimits(cfg.APIKeySettings)
+}
+
 func openAICompatInfoFromAuth(a *coreauth.Auth) (providerKey string, compatName string, ok bool) {
 	if a == nil {
 		return "", "", false
@@ -417,11 +492,13 @@ func (s *Service) Run(ctx context.Context) error {
 		ctx = context.Background()
 	}
 
+	s.state.CompareAndSwap(stateNotStarted, stateStarting)
 	usage.StartDefault(ctx)
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer shutdownCancel()
 	defer func() {
+		// The shutdown budget starts when shutdown does, not when the service started.
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer shutdownCancel()
 		if err := s.Shutdown(shutdownCtx); err != nil {
 			log.Errorf("service shutdown returned error: %v", err)
 		}
@@ -432,7 +509,12 @@ func (s *Service) Run(ctx context.Context) error {
 	}
 
 	s.applyRetryConfig(s.cfg)
+	s.applyDailyQuotas(s.cfg)
+	applyKeyQuotas(s.cfg)
 
+	if s.coreManager != nil && s.hasAccountStateHooks() {
+		s.coreManager.SetStatusChangeHandler(s.hookAccountStateChange)
+	}
 	if s.coreManager != nil {
 		if errLoad := s.coreManager.Load(ctx); errLoad != nil {
 			log.Warnf("failed to load auth store: %v", errLoad)
@@ -485,32 +567,34 @@ func (s *Service) Run(ctx context.Context) error {
 		})
 	}
 
-	if s.hooks.OnBeforeStart != nil {
-		s.hooks.OnBeforeStart(s.cfg)
-	}
+	s.hookBeforeStart(s.cfg)
 
 	s.serverErr = make(chan error, 1)
-	go func() {
-		if errStart := s.server.Start(); errStart != nil {
-			s.serverErr <- errStart
-		} else {
-			s.serverErr <- nil
-		}
-	}()
-
-	time.Sleep(100 * time.Millisecond)
-	fmt.Printf("API server started successfully on: %s:%d\n", s.cfg.Host, s.cfg.Port)
+	if s.noListener {
+		log.Info("API server is serving in-process requests only")
+	} else {
+		go func() {
+			if errStart := s.server.Start(); errStart != nil {
+				s.serverErr <- errStart
+			} else {
+				s.serverErr <- nil
+			}
+		}()
 
-	if s.hooks.OnAfterStart != nil {
-		s.hooks.OnAfterStart(s)
+		time.Sleep(100 * time.Millisecond)
+		fmt.Printf("API server started successfully on: %s:%d\n", s.cfg.Host, s.cfg.Port)
 	}
 
+	s.hookAfterStart()
+	s.startUsagePlugins()
+
 	var watcherWrapper *WatcherWrapper
 	reloadCallback := func(newCfg *config.Config) {
 		previousStrategy := ""
 		s.cfgMu.RLock()
+		oldCfg := s.cfg
 		if s.cfg != nil {
-			previousStrategy = strings.ToLower(strings.TrimSpace(s.cfg.Routing.Strategy))
+			previousStrategy = s.cfg.Routing.Strategy
 		}
 		s.cfgMu.RUnlock()
 
@@ -523,29 +607,22 @@ func (s *Service) Run(ctx context.Context) error {
 			return
 		}
 
-		nextStrategy := strings.ToLower(strings.TrimSpace(newCfg.Routing.Strategy))
 		normalizeStrategy := func(strategy string) string {
-			switch strategy {
-			case "fill-first", "fillfirst", "ff":
-				return "fill-first"
-			default:
-				return "round-robin"
+			normalized, ok := coreauth.NormalizeStrategy(strategy)
+			if !ok {
+				return coreauth.StrategyRoundRobin
 			}
+			return normalized
 		}
 		previousStrategy = normalizeStrategy(previousStrategy)
-		nextStrategy = normalizeStrategy(nextStrategy)
+		nextStrategy := normalizeStrategy(newCfg.Routing.Strategy)
 		if s.coreManager != nil && previousStrategy != nextStrategy {
-			var selector coreauth.Selector
-			switch nextStrategy {
-			case "fill-first":
-				selector = &coreauth.FillFirstSelector{}
-			default:
-				selector = &coreauth.RoundRobinSelector{}
-			}
-			s.coreManager.SetSelector(selector)
+			s.coreManager.SetSelector(coreauth.NewSelectorForStrategy(nextStrategy))
 		}
 
 		s.applyRetryConfig(newCfg)
+		s.applyDailyQuotas(newCfg)
+		applyKeyQuotas(newCfg)
 		if s.server != nil {
 			s.server.UpdateClients(newCfg)
 		}
@@ -557,8 +634,10 @@ func (s *Service) Run(ctx context.Context) error {
 			s.coreManager.SetOAuthModelAlias(newCfg.OAuthModelAlias)
 		}
 		s.rebindExecutors()
+		s.hookConfigReload(oldCfg, newCfg)
 	}
 
+	s.reload = reloadCallback
 	watcherWrapper, err = s.watcherFactory(s.configPath, s.cfg.AuthDir, reloadCallback)
 	if err != nil {
 		return fmt.Errorf("cliproxy: failed to create watcher: %w", err)
@@ -582,8 +661,12 @@ func (s *Service) Run(ctx context.Context) error {
 		interval := 15 * time.Minute
 		s.coreManager.StartAutoRefresh(context.Background(), interval)
 		log.Infof("core auth auto-refresh started (interval=%s)", interval)
+		s.coreManager.StartHealthChecks(context.Background())
 	}
 
+	s.startedAt = time.Now()
+	s.state.CompareAndSwap(stateStarting, stateRunning)
+
 	select {
 	case <-ctx.Done():
 		log.Debug("service context cancelled, shutting down...")
@@ -611,6 +694,9 @@ func (s *Service) Shutdown(ctx context.Context) error {
 		if ctx == nil {
 			ctx = context.Background()
 		}
+		s.state.Store(stateStopped)
+
+		s.hookBeforeStop(ctx)
 
 		// legacy refresh loop removed; only stopping core auth manager below
 
@@ -619,6 +705,7 @@ func (s *Service) Shutdown(ctx context.Context) error {
 		}
 		if s.coreManager != nil {
 			s.coreManager.StopAutoRefresh()
+			s.coreManager.StopHealthChecks()
 		}
 		if s.watcher != nil {
 			if err := s.watcher.Stop(); err != nil {
@@ -653,6 +740,7 @@ func (s *Service) Shutdown(ctx context.Context) error {
 		}
 
 		usage.StopDefault()
+		s.stopUsagePlugins()
 	})
 	return shutdownErr
 }
@@ -853,6 +941,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 			}
 		}
 	}
+	models = applyAuthModelRestrictions(models, a)
 	models = applyOAuthModelAlias(s.cfg, provider, authKind, models)
 	if len(models) > 0 {
 		key := provider
@@ -1039,6 +1128,56 @@ func applyExcludedModels(models []*ModelInfo, excluded []string) []*ModelInfo {
 	return filtered
 }
 
+// applyAuthModelRestrictions filters models by the per-account "models" allow patterns and
+// "exclude-models" deny patterns declared in an auth file. Patterns match upstream model IDs.
+func applyAuthModelRestrictions(models []*ModelInfo, a *coreauth.Auth) []*ModelInfo {
+	if len(models) == 0 || a == nil || len(a.Metadata) == 0 {
+		return models
+	}
+	allowed := metadataStringList(a.Metadata, "models")
+	excluded := metadataStringList(a.Metadata, "exclude-models")
+	excluded = append(excluded, metadataStringList(a.Metadata, "excluded-models")...)
+	if len(allowed) > 0 {
+		filtered := make([]*ModelInfo, 0, len(models))
+		for _, model := range models {
+			if model == nil {
+				continue
+			}
+			modelID := strings.ToLower(strings.TrimSpace(model.ID))
+			for _, pattern := range allowed {
+				if matchWildcard(strings.ToLower(pattern), modelID) {
+					filtered = append(filtered, model)
+					break
+				}
+			}
+		}
+		models = filtered
+	}
+	return applyExcludedModels(models, excluded)
+}
+
+// metadataStringList reads a list of non-empty strings from auth metadata.
+func metadataStringList(metadata map[string]any, key string) []string {
+	var out []string
+	switch raw := metadata[key].(type) {
+	case []string:
+		for _, item := range raw {
+			if trimmed := strings.TrimSpace(item); trimmed != "" {
+				out = append(out, trimmed)
+			}
+		}
+	case []any:
+		for _, item := range raw {
+			if str, ok := item.(string); ok {
+				if trimmed := strings.TrimSpace(str); trimmed != "" {
+					out = append(out, trimmed)
+				}
+			}
+		}
+	}
+	return out
+}
+
 func applyModelPrefixes(models []*ModelInfo, prefix string, forceModelPrefix bool) []*ModelInfo {
 	trimmedPrefix := strings.TrimSpace(prefix)
 	if trimmedPrefix == "" || len(models) == 0 {
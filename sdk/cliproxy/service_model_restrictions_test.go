@@ -0,0 +1,39 @@
+package cliproxy
+
+import (
+	"testing"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestApplyAuthModelRestrictions_AllowAndExclude(t *testing.T) {
+	models := []*ModelInfo{
+		{ID: "claude-opus-4-5"},
+		{ID: "claude-sonnet-4-5"},
+		{ID: "claude-sonnet-4-5-thinking"},
+		{ID: "claude-haiku-4-5"},
+	}
+	auth := &coreauth.Auth{
+		ID: "workspace.json",
+		Metadata: map[string]any{
+			"models":         []any{"claude-sonnet-*", "claude-haiku-*"},
+			"exclude-models": []any{"*-thinking"},
+		},
+	}
+
+	out := applyAuthModelRestrictions(models, auth)
+	got := make([]string, 0, len(out))
+	for _, model := range out {
+		got = append(got, model.ID)
+	}
+	if len(got) != 2 || got[0] != "claude-sonnet-4-5" || got[1] != "claude-haiku-4-5" {
+		t.Fatalf("restricted models = %v, want [claude-sonnet-4-5 claude-haiku-4-5]", got)
+	}
+}
+
+func TestApplyAuthModelRestrictions_NoMetadataKeepsModels(t *testing.T) {
+	models := []*ModelInfo{{ID: "gpt-5"}, {ID: "gpt-5-codex"}}
+	if out := applyAuthModelRestrictions(models, &coreauth.Auth{ID: "apikey"}); len(out) != 2 {
+		t.Fatalf("expected models to be untouched without restrictions, got %d", len(out))
+	}
+}
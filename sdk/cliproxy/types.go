@@ -86,6 +86,7 @@ type WatcherWrapper struct {
 	stop  func() error
 
 	setConfig             func(cfg *config.Config)
+	applyConfig           func(cfg *config.Config)
 	snapshotAuths         func() []*coreauth.Auth
 	setUpdateQueue        func(queue chan<- watcher.AuthUpdate)
 	dispatchRuntimeUpdate func(update watcher.AuthUpdate) bool
@@ -115,6 +116,16 @@ func (w *WatcherWrapper) SetConfig(cfg *config.Config) {
 	w.setConfig(cfg)
 }
 
+// ApplyConfig reloads from cfg the way a config file change would, returning false when
+// the underlying watcher cannot.
+func (w *WatcherWrapper) ApplyConfig(cfg *config.Config) bool {
+	if w == nil || w.applyConfig == nil {
+		return false
+	}
+	w.applyConfig(cfg)
+	return true
+}
+
 // DispatchRuntimeAuthUpdate forwards runtime auth updates (e.g., websocket providers)
 // into the watcher-managed auth update queue when available.
 // Returns true if the update was enqueued successfully.
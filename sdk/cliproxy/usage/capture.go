@@ -0,0 +1,83 @@
+package usage
+
+import (
+	"context"
+	"sync"
+)
+
+type captureKey struct{}
+
+// Capture observes the usage records published while one client request is served, so the
+// handler can report exactly the numbers that reach the usage plugins.
+type Capture struct {
+	mu        sync.Mutex
+	detail    Detail
+	captured  bool
+	holdEmpty bool
+	pending   *Record
+	released  bool
+}
+
+// WithCapture attaches a new Capture to ctx. When holdEmpty is set, a successful record
+// without token counts is held until Release so the caller can fill in an estimate.
+func WithCapture(ctx context.Context, holdEmpty bool) (context.Context, *Capture) {
+	capture := &Capture{holdEmpty: holdEmpty}
+	return context.WithValue(ctx, captureKey{}, capture), capture
+}
+
+func captureFromContext(ctx context.Context) *Capture {
+	if ctx == nil {
+		return nil
+	}
+	capture, _ := ctx.Value(captureKey{}).(*Capture)
+	return capture
+}
+
+// Detail returns the token counts of the last successful record carrying usage.
+func (c *Capture) Detail() (Detail, bool) {
+	if c == nil {
+		return Detail{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.detail, c.captured
+}
+
+// Release publishes a held record with detail; estimated marks the counts as locally computed.
+// Records published after Release are no longer held. Calling Release again is a no-op.
+func (c *Capture) Release(ctx context.Context, detail Detail, estimated bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.released = true
+	c.mu.Unlock()
+	if pending == nil {
+		return
+	}
+	pending.Detail = detail
+	pending.Estimated = estimated && detail != (Detail{})
+	DefaultManager().Publish(ctx, *pending)
+}
+
+// observe records the usage of record and reports whether it was held back.
+func (c *Capture) observe(record Record) bool {
+	if record.Failed {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if record.Detail != (Detail{}) {
+		c.detail = record.Detail
+		c.captured = true
+		return false
+	}
+	if !c.holdEmpty || c.released {
+		return false
+	}
+	held := record
+	c.pending = &held
+	return true
+}
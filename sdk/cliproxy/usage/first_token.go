@@ -0,0 +1,44 @@
+package usage
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+type firstTokenKey struct{}
+
+// firstToken holds when the stream of one upstream attempt produced its first chunk, in unix
+// nanoseconds; zero until then.
+type firstToken struct {
+	at atomic.Int64
+}
+
+// WithFirstToken attaches to ctx a slot for the time the stream served under it produces its
+// first chunk. Publish turns a marked time into Record.FirstTokenLatency.
+func WithFirstToken(ctx context.Context) context.Context {
+	return context.WithValue(ctx, firstTokenKey{}, &firstToken{})
+}
+
+// MarkFirstToken records now as the time of the first chunk of the stream behind ctx, unless
+// an earlier chunk was marked. It does nothing without WithFirstToken.
+func MarkFirstToken(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+	if slot, _ := ctx.Value(firstTokenKey{}).(*firstToken); slot != nil && slot.at.Load() == 0 {
+		slot.at.CompareAndSwap(0, time.Now().UnixNano())
+	}
+}
+
+func firstTokenAt(ctx context.Context) (time.Time, bool) {
+	if ctx == nil {
+		return time.Time{}, false
+	}
+	slot, _ := ctx.Value(firstTokenKey{}).(*firstToken)
+	if slot == nil {
+		return time.Time{}, false
+	}
+	at := slot.at.Load()
+	return time.Unix(0, at), at != 0
+}
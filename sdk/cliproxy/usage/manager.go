@@ -5,9 +5,13 @@ import (
 	"sync"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	log "github.com/router-for-me/CLIProxyAPI/v6/internal/log"
 )
 
+// RequestTypeCountTokens marks records for token counting calls, which consume no
+// generation tokens.
+const RequestTypeCountTokens = "count_tokens"
+
 // Record contains the usage statistics captured for a single provider request.
 type Record struct {
 	Provider    string
@@ -18,7 +22,26 @@ type Record struct {
 	Source      string
 	RequestedAt time.Time
 	Failed      bool
-	Detail      Detail
+	// Cancelled marks requests the client abandoned before the upstream finished; Detail
+	// holds whatever usage had been reported up to then.
+	Cancelled bool
+	// CacheHit marks requests answered from the response cache without calling the upstream.
+	CacheHit bool
+	// Estimated marks token counts computed locally because the upstream reported none.
+	Estimated bool
+	// RequestType is empty for generation requests and RequestTypeCountTokens for token counting.
+	RequestType string
+	// Labels attributes the request to caller-defined groups, such as a team or project. They
+	// come from the usage-labels headers of trusted sources; nil when there are none.
+	Labels map[string]string
+	// Latency is how long the request took until its usage was published. Publish fills it
+	// in from RequestedAt when it is zero.
+	Latency time.Duration
+	// FirstTokenLatency is how long a streamed request took until its first chunk was ready
+	// for the client; zero when it was not streamed or not measured. Publish fills it in from
+	// MarkFirstToken when it is zero.
+	FirstTokenLatency time.Duration
+	Detail            Detail
 }
 
 // Detail holds the token usage breakdown.
@@ -35,6 +58,18 @@ type Plugin interface {
 	HandleUsage(ctx context.Context, record Record)
 }
 
+// PluginStarter is implemented by plugins with background work to start before they
+// receive records.
+type PluginStarter interface {
+	Start()
+}
+
+// PluginStopper is implemented by plugins that flush or release resources on shutdown.
+// Stop is called once every queued record has been delivered.
+type PluginStopper interface {
+	Stop() error
+}
+
 type queueItem struct {
 	ctx    context.Context
 	record Record
@@ -45,6 +80,8 @@ type Manager struct {
 	once     sync.Once
 	stopOnce sync.Once
 	cancel   context.CancelFunc
+	// done is closed when the dispatcher has delivered the last queued record.
+	done chan struct{}
 
 	mu     sync.Mutex
 	cond   *sync.Cond
@@ -73,11 +110,16 @@ func (m *Manager) Start(ctx context.Context) {
 		}
 		var workerCtx context.Context
 		workerCtx, m.cancel = context.WithCancel(ctx)
-		go m.run(workerCtx)
+		m.done = make(chan struct{})
+		go func() {
+			defer close(m.done)
+			m.run(workerCtx)
+		}()
 	})
 }
 
-// Stop stops the dispatcher and drains the queue.
+// Stop stops the dispatcher and waits until the records already queued are delivered.
+// Records published afterwards are discarded.
 func (m *Manager) Stop() {
 	if m == nil {
 		return
@@ -91,9 +133,16 @@ func (m *Manager) Stop() {
 		m.mu.Unlock()
 		m.cond.Broadcast()
 	})
+	// Taking once orders this read of done after Start, and keeps a later Start from
+	// launching a dispatcher nothing would stop.
+	m.once.Do(func() {})
+	if m.done != nil {
+		<-m.done
+	}
 }
 
-// Register appends a plugin to the delivery list.
+// Register appends a plugin to the delivery list. Every record is delivered to the plugins
+// one after another in registration order, and each plugin sees records in publish order.
 func (m *Manager) Register(plugin Plugin) {
 	if m == nil || plugin == nil {
 		return
@@ -109,6 +158,14 @@ func (m *Manager) Publish(ctx context.Context, record Record) {
 	if m == nil {
 		return
 	}
+	if record.Latency == 0 && !record.RequestedAt.IsZero() {
+		record.Latency = time.Since(record.RequestedAt)
+	}
+	if record.FirstTokenLatency == 0 && !record.RequestedAt.IsZero() {
+		if at, ok := firstTokenAt(ctx); ok && at.After(record.RequestedAt) {
+			record.FirstTokenLatency = at.Sub(record.RequestedAt)
+		}
+	}
 	// ensure worker is running even if Start was not called explicitly
 	m.Start(context.Background())
 	m.mu.Lock()
@@ -171,8 +228,14 @@ func DefaultManager() *Manager { return defaultManager }
 // RegisterPlugin registers a plugin on the default manager.
 func RegisterPlugin(plugin Plugin) { DefaultManager().Register(plugin) }
 
-// PublishRecord publishes a record using the default manager.
-func PublishRecord(ctx context.Context, record Record) { DefaultManager().Publish(ctx, record) }
+// PublishRecord publishes a record using the default manager. A Capture attached to ctx
+// observes the record first and may hold back a record without token counts.
+func PublishRecord(ctx context.Context, record Record) {
+	if capture := captureFromContext(ctx); capture != nil && capture.observe(record) {
+		return
+	}
+	DefaultManager().Publish(ctx, record)
+}
 
 // StartDefault starts the default manager's dispatcher.
 func StartDefault(ctx context.Context) { DefaultManager().Start(ctx) }
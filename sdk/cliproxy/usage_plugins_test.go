@@ -0,0 +1,83 @@
+package cliproxy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// lifecycleUsagePlugin records the records it receives and appends its lifecycle calls to
+// a shared log.
+type lifecycleUsagePlugin struct {
+	name string
+	mu   *sync.Mutex
+	log  *[]string
+	seen map[string]int
+}
+
+func (p *lifecycleUsagePlugin) HandleUsage(_ context.Context, record usage.Record) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seen[record.AuthID]++
+}
+
+func (p *lifecycleUsagePlugin) Start() { p.event("start") }
+
+func (p *lifecycleUsagePlugin) Stop() error {
+	p.event("stop")
+	return nil
+}
+
+func (p *lifecycleUsagePlugin) event(what string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	*p.log = append(*p.log, p.name+" "+what)
+}
+
+// handleOnlyPlugin implements nothing beyond usage.Plugin.
+type handleOnlyPlugin struct{ records chan usage.Record }
+
+func (p handleOnlyPlugin) HandleUsage(_ context.Context, record usage.Record) { p.records <- record }
+
+// TestWithUsagePluginsDeliversEveryRecordOnce attaches two lifecycle plugins and a plain one,
+// and expects them started in order, stopped in reverse order after every record published
+// before shutdown was delivered, and each record seen exactly once. Shutdown stops the
+// default usage dispatcher, so this is the only test in the package that publishes records.
+func TestWithUsagePluginsDeliversEveryRecordOnce(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	first := &lifecycleUsagePlugin{name: "first", mu: &mu, log: &events, seen: map[string]int{}}
+	second := &lifecycleUsagePlugin{name: "second", mu: &mu, log: &events, seen: map[string]int{}}
+	const records = 200
+	plain := handleOnlyPlugin{records: make(chan usage.Record, records)}
+	builder := NewBuilder().WithUsagePlugins(first, plain).WithUsagePlugins(second)
+	s := &Service{usagePlugins: builder.usagePlugins}
+
+	s.startUsagePlugins()
+	for i := 0; i < records; i++ {
+		usage.PublishRecord(context.Background(), usage.Record{AuthID: fmt.Sprintf("usage-plugin-%d", i)})
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if got, want := fmt.Sprint(events), "[first start second start second stop first stop]"; got != want {
+		t.Fatalf("lifecycle = %s, want %s", got, want)
+	}
+	for _, p := range []*lifecycleUsagePlugin{first, second} {
+		if len(p.seen) != records {
+			t.Fatalf("%s saw %d distinct records, want %d", p.name, len(p.seen), records)
+		}
+		for id, n := range p.seen {
+			if n != 1 {
+				t.Fatalf("%s saw %s %d times", p.name, id, n)
+			}
+		}
+	}
+	if got := len(plain.records); got != records {
+		t.Fatalf("plain plugin saw %d records, want %d", got, records)
+	}
+}
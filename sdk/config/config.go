@@ -13,8 +13,28 @@ type AccessProvider = internalconfig.AccessProvider
 type Config = internalconfig.Config
 
 type StreamingConfig = internalconfig.StreamingConfig
+type HTTPTransportConfig = internalconfig.HTTPTransportConfig
+type LoggingConfig = internalconfig.LoggingConfig
+type SyslogConfig = internalconfig.SyslogConfig
+type LogSamplingConfig = internalconfig.LogSamplingConfig
+type TracingConfig = internalconfig.TracingConfig
+type AuditLogConfig = internalconfig.AuditLogConfig
+type SentryConfig = internalconfig.SentryConfig
+type NotificationsConfig = internalconfig.NotificationsConfig
+type NotificationWebhook = internalconfig.NotificationWebhook
+type UsageLabels = internalconfig.UsageLabels
+type UsageRedis = internalconfig.UsageRedis
+type UsageRedisTLS = internalconfig.UsageRedisTLS
+type UsageWebhook = internalconfig.UsageWebhook
+type ModelPrice = internalconfig.ModelPrice
+type MetricsConfig = internalconfig.MetricsConfig
+type PushgatewayConfig = internalconfig.PushgatewayConfig
 type TLSConfig = internalconfig.TLSConfig
 type RemoteManagement = internalconfig.RemoteManagement
+type ManagementConfig = internalconfig.ManagementConfig
+type TransientRetryConfig = internalconfig.TransientRetryConfig
+type UpstreamHeaderRules = internalconfig.UpstreamHeaderRules
+type ResponseCacheConfig = internalconfig.ResponseCacheConfig
 type AmpCode = internalconfig.AmpCode
 type OAuthModelAlias = internalconfig.OAuthModelAlias
 type PayloadConfig = internalconfig.PayloadConfig
@@ -34,15 +54,48 @@ type OpenAICompatibilityModel = internalconfig.OpenAICompatibilityModel
 type TLS = internalconfig.TLSConfig
 
 const (
-	AccessProviderTypeConfigAPIKey = internalconfig.AccessProviderTypeConfigAPIKey
-	DefaultAccessProviderName      = internalconfig.DefaultAccessProviderName
-	DefaultPanelGitHubRepository   = internalconfig.DefaultPanelGitHubRepository
+	AccessProviderTypeConfigAPIKey   = internalconfig.AccessProviderTypeConfigAPIKey
+	AccessProviderTypeJWT            = internalconfig.AccessProviderTypeJWT
+	DefaultAccessProviderName        = internalconfig.DefaultAccessProviderName
+	DefaultPanelGitHubRepository     = internalconfig.DefaultPanelGitHubRepository
+	ReasoningOutputExpose            = internalconfig.ReasoningOutputExpose
+	ReasoningOutputStrip             = internalconfig.ReasoningOutputStrip
+	DefaultMaxIdleConnsPerHost       = internalconfig.DefaultMaxIdleConnsPerHost
+	DefaultIdleConnTimeout           = internalconfig.DefaultIdleConnTimeout
+	DefaultUsageStatisticsMaxKeys    = internalconfig.DefaultUsageStatisticsMaxKeys
+	DefaultModelCacheTTL             = internalconfig.DefaultModelCacheTTL
+	LogOutputStdout                  = internalconfig.LogOutputStdout
+	LogOutputFile                    = internalconfig.LogOutputFile
+	LogOutputSyslog                  = internalconfig.LogOutputSyslog
+	LogOutputJournald                = internalconfig.LogOutputJournald
+	DefaultSyslogFacility            = internalconfig.DefaultSyslogFacility
+	DefaultSyslogTag                 = internalconfig.DefaultSyslogTag
+	DefaultAuditLogMaxSizeMB         = internalconfig.DefaultAuditLogMaxSizeMB
+	DefaultAuditLogMaxBackups        = internalconfig.DefaultAuditLogMaxBackups
+	DefaultAuditLogMaxBodyBytes      = internalconfig.DefaultAuditLogMaxBodyBytes
+	DefaultLogSamplingBurst          = internalconfig.DefaultLogSamplingBurst
+	DefaultLogSamplingInterval       = internalconfig.DefaultLogSamplingInterval
+	DefaultTracingServiceName        = internalconfig.DefaultTracingServiceName
+	DefaultTracingSampleRatio        = internalconfig.DefaultTracingSampleRatio
+	DefaultSentryQueueSize           = internalconfig.DefaultSentryQueueSize
+	DefaultNotificationRateLimit     = internalconfig.DefaultNotificationRateLimit
+	DefaultUsageRedisKeyPrefix       = internalconfig.DefaultUsageRedisKeyPrefix
+	DefaultUsageRedisFlushInterval   = internalconfig.DefaultUsageRedisFlushInterval
+	DefaultUsageWebhookBatchSize     = internalconfig.DefaultUsageWebhookBatchSize
+	DefaultUsageWebhookFlushInterval = internalconfig.DefaultUsageWebhookFlushInterval
+	DefaultUsageWebhookMaxBuffer     = internalconfig.DefaultUsageWebhookMaxBuffer
+	DefaultPushgatewayJob            = internalconfig.DefaultPushgatewayJob
+	DefaultPushgatewayInterval       = internalconfig.DefaultPushgatewayInterval
 )
 
 func MakeInlineAPIKeyProvider(keys []string) *AccessProvider {
 	return internalconfig.MakeInlineAPIKeyProvider(keys)
 }
 
+func KeepsInlineAPIKeys(providers []AccessProvider) bool {
+	return internalconfig.KeepsInlineAPIKeys(providers)
+}
+
 func LoadConfig(configFile string) (*Config, error) { return internalconfig.LoadConfig(configFile) }
 
 func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
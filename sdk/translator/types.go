@@ -11,6 +11,8 @@ type RequestTransform func(model string, rawJSON []byte, stream bool) []byte
 // ResponseStreamTransform is a function type that converts a streaming response from a source schema to a target schema.
 // It takes a context, the model name, the raw JSON of the original and converted requests, the raw JSON of the current response chunk, and an optional parameter.
 // It returns a slice of strings, where each string is a chunk of the converted streaming response.
+// The byte slices are read-only and rawJSON is reused for the next chunk once the call returns,
+// so implementations must copy anything kept in param.
 type ResponseStreamTransform func(ctx context.Context, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string
 
 // ResponseNonStreamTransform is a function type that converts a non-streaming response from a source schema to a target schema.